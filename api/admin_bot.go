@@ -352,6 +352,15 @@ func BotTokenAdd(c *fiber.Ctx) error {
 		reloadOneBotReverseRuntimeForBot(uid)
 	}
 
+	// 令牌本身不进入审计日志，仅记录过期时间
+	recordAudit(c, service.AuditEntry{
+		Action:     model.AuditActionBotTokenCreate,
+		TargetType: model.AuditTargetBot,
+		TargetID:   uid,
+		TargetName: item.Name,
+		After:      fiber.Map{"name": item.Name, "expiresAt": item.ExpiresAt},
+	})
+
 	return c.JSON(item)
 }
 
@@ -76,8 +76,15 @@ func AdminUserList(c *fiber.Ctx) error {
 		Offset(offset).Limit(pageSize).
 		Find(&items)
 
+	userIDs := make([]string, 0, len(items))
 	for _, i := range items {
 		i.RoleIds, _ = model.UserRoleMappingListByUserID(i.ID, "", "system")
+		userIDs = append(userIDs, i.ID)
+	}
+	if enabledMap, err := model.UserTwoFactorEnabledMap(userIDs); err == nil {
+		for _, i := range items {
+			i.TwoFactorEnabled = enabledMap[i.ID]
+		}
 	}
 
 	// 返回JSON响应
@@ -97,12 +104,14 @@ func AdminUserDisable(c *fiber.Ctx) error {
 		})
 	}
 
+	target := model.UserGet(userId)
 	err := model.UserSetDisable(userId, true)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "禁用用户失败",
 		})
 	}
+	recordAdminUserStatusAudit(c, model.AuditActionUserDisable, userId, target, true)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "用户已成功禁用",
@@ -117,12 +126,14 @@ func AdminUserEnable(c *fiber.Ctx) error {
 		})
 	}
 
+	target := model.UserGet(userId)
 	err := model.UserSetDisable(userId, false)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "启用用户失败",
 		})
 	}
+	recordAdminUserStatusAudit(c, model.AuditActionUserEnable, userId, target, false)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "用户已成功启用",
@@ -148,6 +159,7 @@ func AdminUserDelete(c *fiber.Ctx) error {
 		})
 	}
 
+	targetName := service.AuditUserDisplayName(userID)
 	err := service.AdminUserSoftDelete(userID, operator.ID)
 	if err != nil {
 		switch {
@@ -170,11 +182,71 @@ func AdminUserDelete(c *fiber.Ctx) error {
 		}
 	}
 
+	recordAudit(c, service.AuditEntry{
+		Action:     model.AuditActionUserDelete,
+		TargetType: model.AuditTargetUser,
+		TargetID:   userID,
+		TargetName: targetName,
+		Before:     fiber.Map{"deleted": false},
+		After:      fiber.Map{"deleted": true},
+	})
+
 	return c.JSON(fiber.Map{
 		"message": "用户已删除",
 	})
 }
 
+func recordAdminUserStatusAudit(c *fiber.Ctx, action string, userID string, target *model.UserModel, disabled bool) {
+	entry := service.AuditEntry{
+		Action:     action,
+		TargetType: model.AuditTargetUser,
+		TargetID:   userID,
+		After:      fiber.Map{"disabled": disabled},
+	}
+	if target != nil {
+		entry.TargetName = auditActorName(target)
+		entry.Before = fiber.Map{"disabled": target.Disabled}
+	}
+	recordAudit(c, entry)
+}
+
+// AdminUserTwoFactorReset 清除用户的两步验证，用户需重新绑定验证器
+func AdminUserTwoFactorReset(c *fiber.Ctx) error {
+	if !CanWithSystemRole(c, pm.PermFuncAdminUserEdit) {
+		return nil
+	}
+
+	userID := strings.TrimSpace(c.Query("id"))
+	if userID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "用户ID不能为空",
+		})
+	}
+
+	wasEnabled := model.UserTwoFactorIsEnabled(userID)
+	if err := service.TwoFactorAdminReset(userID); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "用户不存在"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "重置两步验证失败",
+		})
+	}
+
+	recordAudit(c, service.AuditEntry{
+		Action:     model.AuditActionUserTwoFactorReset,
+		TargetType: model.AuditTargetUser,
+		TargetID:   userID,
+		TargetName: service.AuditUserDisplayName(userID),
+		Before:     fiber.Map{"twoFactorEnabled": wasEnabled},
+		After:      fiber.Map{"twoFactorEnabled": false},
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "已重置该用户的两步验证",
+	})
+}
+
 func AdminUserResetPassword(c *fiber.Ctx) error {
 	uid := c.Query("id")
 	if uid == "" {
@@ -12,6 +12,7 @@ import (
 	"sealchat/pm"
 	"sealchat/protocol"
 	"sealchat/service"
+	"sealchat/service/broadcast"
 	"sealchat/utils"
 )
 
@@ -272,14 +273,19 @@ func LobbyAnnouncementDeleteHandler(c *fiber.Ctx) error {
 }
 
 func broadcastLobbyAnnouncementUpdated() {
-	userConnMap := getUserConnInfoMap()
-	if userConnMap == nil {
-		return
-	}
-	event := protocol.Event{
+	event := &protocol.Event{
 		Type:      protocol.EventLobbyAnnouncementUpdated,
 		Timestamp: time.Now().Unix(),
 	}
+	deliverLobbyEvent(getUserConnInfoMap(), event)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindLobby}, event)
+}
+
+// deliverLobbyEvent 投递给本节点全部已登录的非 BOT 连接
+func deliverLobbyEvent(userConnMap *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]], event *protocol.Event) {
+	if userConnMap == nil {
+		return
+	}
 	userConnMap.Range(func(_ string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		if connMap == nil {
 			return true
@@ -292,7 +298,7 @@ func broadcastLobbyAnnouncementUpdated() {
 				protocol.Event
 				Op protocol.Opcode `json:"op"`
 			}{
-				Event: event,
+				Event: *event,
 				Op:    protocol.OpEvent,
 			})
 			return true
@@ -361,9 +361,15 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	app.Use(logger.New())
 	app.Use(frontendCompressMiddleware(config.WebUrl))
 
+	app.Get(joinWebPath(config.WebUrl, "metrics"), MetricsExportHandler)
+
 	v1 := app.Group(joinWebPath(config.WebUrl, "api/v1"))
 	v1.Post("/user-signup", UserSignup)
 	v1.Post("/user-signin", UserSignin)
+	v1.Post("/user-signin/2fa", UserSigninTwoFactor)
+	v1.Get("/oidc/login", OIDCLogin)
+	v1.Get("/oidc/callback", OIDCCallback)
+	v1.Post("/oidc/exchange", OIDCExchange)
 	v1.Get("/captcha/new", CaptchaNew)
 	v1.Get("/captcha/:id.png", CaptchaImage)
 	v1.Get("/captcha/:id/reload", CaptchaReload)
@@ -400,7 +406,7 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	v1.Get("/webhook/channels/:channelId/digests/latest", WebhookAuthMiddleware, WebhookDigestLatest)
 	v1.Get("/webhook/worlds/:worldId/digests", WebhookWorldDigestList)
 	v1.Get("/webhook/worlds/:worldId/digests/latest", WebhookWorldDigestLatest)
-	v1.Post("/webhook/channels/:channelId/messages", WebhookAuthMiddleware, WebhookMessages)
+	v1.Post("/webhook/channels/:channelId/messages", WebhookAuthMiddleware, rateLimitMiddleware(utils.RateLimitRouteWebhookMessage, rateLimitWorldFromChannelParam("channelId")), WebhookMessages)
 	// 必须在 v1Auth.Use(SignCheckMiddleware) 之前注册。
 	// Fiber 同前缀 group middleware 会按注册顺序吞掉后续路由；若放在后面，playToken 请求会先被 SignCheckMiddleware 拦成 401。
 	v1.Get("/audio/stream/:id", OptionalSignCheckMiddleware, AudioAssetStream)
@@ -415,6 +421,17 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	v1Auth := v1.Group("")
 	v1Auth.Use(SignCheckMiddleware)
 	v1Auth.Post("/user-password-change", UserChangePassword)
+	v1Auth.Get("/user/2fa", UserTwoFactorStatus)
+	v1Auth.Post("/user/2fa/setup", UserTwoFactorSetup)
+	v1Auth.Post("/user/2fa/enable", UserTwoFactorEnable)
+	v1Auth.Post("/user/2fa/disable", UserTwoFactorDisable)
+	v1Auth.Post("/user/2fa/recovery-codes", UserTwoFactorRecoveryCodes)
+	v1Auth.Get("/user/sessions", UserSessionList)
+	v1Auth.Post("/user/sessions/revoke", UserSessionRevoke)
+	v1Auth.Post("/user/sessions/revoke-others", UserSessionRevokeOthers)
+	v1Auth.Post("/oidc/link", OIDCLinkStart)
+	v1Auth.Get("/oidc/identities", OIDCIdentityList)
+	v1Auth.Post("/oidc/unlink", OIDCUnlink)
 	v1Auth.Get("/user-info", UserInfo)
 	v1Auth.Post("/user-info-update", UserInfoUpdate)
 	v1Auth.Get("/user-lookup", UserLookup)
@@ -434,6 +451,17 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	v1Auth.Get("/user/ai-profiles", UserAIProfilesGet)
 	v1Auth.Post("/user/ai-profiles", UserAIProfilesUpsert)
 
+	// Notification preferences
+	v1Auth.Get("/notification-preferences", NotificationPreferencesList)
+	v1Auth.Post("/notification-preferences", NotificationPreferencesUpsert)
+	v1Auth.Delete("/notification-preferences", NotificationPreferencesDelete)
+
+	// Web Push
+	v1Auth.Get("/web-push/config", WebPushConfigGet)
+	v1Auth.Post("/web-push/subscriptions", WebPushSubscribe)
+	v1Auth.Post("/web-push/unsubscribe", WebPushUnsubscribe)
+	v1Auth.Post("/web-push/test", WebPushTestSend)
+
 	// User input stats
 	v1Auth.Get("/user/input-stats/overview", UserInputStatsOverview)
 	v1Auth.Get("/user/input-stats/by-world", UserInputStatsByWorld)
@@ -466,8 +494,8 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	v1Auth.Post("/upload-quick", UploadQuick)
 	v1Auth.Get("/attachments-list", AttachmentList)
 
-	v1Auth.Post("/attachment-upload", AttachmentUploadTempFile)
-	v1Auth.Post("/attachment-upload-quick", AttachmentUploadQuick)
+	v1Auth.Post("/attachment-upload", rateLimitMiddleware(utils.RateLimitRouteAttachmentUpload, nil), AttachmentUploadTempFile)
+	v1Auth.Post("/attachment-upload-quick", rateLimitMiddleware(utils.RateLimitRouteAttachmentUpload, nil), AttachmentUploadQuick)
 	v1Auth.Post("/attachment-import-from-url", AttachmentImportFromURL)
 	v1Auth.Post("/attachment-confirm", AttachmentSetConfirm)
 	v1Auth.Post("/attachments-delete", AttachmentDelete)
@@ -523,6 +551,11 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	diceMacros.Delete("/:macroId", ChannelDiceMacroDelete)
 	diceMacros.Post("/import", ChannelDiceMacroImport)
 
+	v1Auth.Get("/channels/:channelId/scheduled-messages", ChannelScheduledMessageList)
+	v1Auth.Post("/channels/:channelId/scheduled-messages", ChannelScheduledMessageCreate)
+	v1Auth.Put("/scheduled-messages/:id", ScheduledMessageUpdate)
+	v1Auth.Delete("/scheduled-messages/:id", ScheduledMessageCancel)
+
 	v1Auth.Get("/channels/:channelId/messages/search", ChannelMessageSearch)
 	v1Auth.Get("/channels/:channelId/messages/search/refine", ChannelMessageSearchRefine)
 	v1Auth.Post("/messages/:messageId/reactions", MessageReactionAdd)
@@ -630,7 +663,9 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	worldGroup.Get("", WorldList)
 	worldGroup.Post("/", WorldCreateHandler)
 	worldGroup.Post("", WorldCreateHandler)
+	worldGroup.Post("/bundle/import", WorldBundleImportHandler)
 	worldGroup.Get("/:worldId", WorldDetail)
+	worldGroup.Get("/:worldId/bundle", WorldBundleExportHandler)
 	worldGroup.Get("/:worldId/observer-link", WorldObserverLinkGetHandler)
 	worldGroup.Put("/:worldId/observer-link", WorldObserverLinkUpdateHandler)
 	worldGroup.Patch("/:worldId", WorldUpdateHandler)
@@ -652,6 +687,7 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	worldGroup.Delete("/:worldId/announcements/:announcementId", WorldAnnouncementDeleteHandler)
 	worldGroup.Post("/:worldId/announcements/:announcementId/mark-popup", WorldAnnouncementMarkPopupHandler)
 	worldGroup.Post("/:worldId/announcements/:announcementId/ack", WorldAnnouncementAckHandler)
+	worldGroup.Get("/:worldId/audit-logs", WorldAuditLogList)
 	worldGroup.Get("/:worldId/members", WorldMemberListHandler)
 	worldGroup.Delete("/:worldId/members/:userId", WorldMemberRemoveHandler)
 	worldGroup.Post("/:worldId/members/:userId/role", WorldMemberRoleHandler)
@@ -684,6 +720,7 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	v1Auth.Delete("/channels/archived", ChannelPermanentDelete)
 	v1Auth.Get("/channel-presence", ChannelPresence)
 	v1Auth.Get("/channels/:channelId/message-active-days", ChannelMessageActiveDaysHandler)
+	v1Auth.Get("/channels/:channelId/dice-stats", ChannelDiceLuckStats)
 	v1Auth.Get("/channels/:channelId/battle-reports", BattleReportList)
 	v1Auth.Post("/channels/:channelId/battle-reports", BattleReportCreate)
 	v1Auth.Post("/channels/:channelId/battle-reports/summarize-input", BattleReportSummarizeInput)
@@ -733,10 +770,12 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	v1AuthAdmin.Post("/admin/bot-token-delete", BotTokenDelete)
 	v1AuthAdmin.Post("/admin/bot-token-batch-delete", BotTokenBatchDelete)
 	v1AuthAdmin.Post("/admin/system-bots/cleanup-orphaned", CleanupOrphanSystemBots)
+	v1AuthAdmin.Get("/admin/audit-logs", AdminAuditLogList)
 	v1AuthAdmin.Get("/admin/user-list", AdminUserList)
 	v1AuthAdmin.Post("/admin/user-disable", AdminUserDisable)
 	v1AuthAdmin.Post("/admin/user-enable", AdminUserEnable)
 	v1AuthAdmin.Post("/admin/user-delete", AdminUserDelete)
+	v1AuthAdmin.Post("/admin/user-2fa-reset", AdminUserTwoFactorReset)
 	v1AuthAdmin.Post("/admin/user-password-reset", AdminUserResetPassword)
 	v1AuthAdmin.Post("/admin/user-role-link-by-user-id", AdminUserRoleLinkByUserId)
 	v1AuthAdmin.Post("/admin/user-role-unlink-by-user-id", AdminUserRoleUnlinkByUserId)
@@ -854,6 +893,12 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 		} else if payload.AllowWorldAudioWorkbench != nil {
 			newConfig.Audio.AllowWorldAudioWorkbench = *payload.AllowWorldAudioWorkbench
 		}
+		if newConfig.TwoFactor.RequireForAdmins {
+			// 避免开启后当前管理员自己被挡在管理功能之外
+			if cur := getCurUser(ctx); cur != nil && !model.UserTwoFactorIsEnabled(cur.ID) {
+				return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "请先为当前账号启用两步验证，再要求管理员启用"})
+			}
+		}
 		newConfig.ThemeManagement = utils.NormalizeThemeManagementConfig(newConfig.ThemeManagement)
 		if validateErr := utils.ValidateThemeManagementConfig(newConfig.ThemeManagement); validateErr != nil {
 			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": validateErr.Error()})
@@ -863,8 +908,10 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": validateErr.Error()})
 		}
 
+		previousConfig := sanitizeConfigForAdmin(appConfig)
 		appConfig = mergeConfigForWrite(appConfig, &newConfig)
 		utils.WriteConfig(appConfig)
+		recordConfigUpdateAudit(ctx, previousConfig, sanitizeConfigForAdmin(appConfig))
 		if manager := perfprofiler.Get(); manager != nil && appConfig != nil {
 			_ = manager.Reconfigure(perfprofiler.ConfigFromApp(appConfig.PerformanceProfiler))
 		}
@@ -893,6 +940,8 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) error {
 	websocketWorks(app, config.WebUrl)
 	oneBotWSWorks(app, config.WebUrl)
 	startOneBotReverseRuntimeForInit()
+	startScheduledMessageWorkerForInit()
+	startPollWorkerForInit()
 
 	return serveAppWithOptionalCertificateForInit(app, config)
 }
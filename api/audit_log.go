@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+// recordAudit 以当前请求的用户与 IP 作为操作者写入审计日志
+func recordAudit(c *fiber.Ctx, entry service.AuditEntry) {
+	if user := getCurUser(c); user != nil {
+		entry.ActorID = user.ID
+		entry.ActorName = auditActorName(user)
+	}
+	entry.IP = c.IP()
+	service.AuditRecord(entry)
+}
+
+// recordGatewayAudit WebSocket 请求的审计记录
+func recordGatewayAudit(ctx *ChatContext, entry service.AuditEntry) {
+	if ctx == nil || ctx.User == nil {
+		return
+	}
+	entry.ActorID = ctx.User.ID
+	entry.ActorName = auditActorName(ctx.User)
+	if ctx.ConnInfo != nil {
+		entry.IP = ctx.ConnInfo.ClientAddr
+	}
+	service.AuditRecord(entry)
+}
+
+// recordConfigUpdateAudit 配置写入只保存逐字段差异，传入的配置需已脱敏
+func recordConfigUpdateAudit(c *fiber.Ctx, before, after utils.AppConfig) {
+	recordAudit(c, service.AuditEntry{
+		Action:        model.AuditActionConfigUpdate,
+		TargetType:    model.AuditTargetConfig,
+		TargetID:      "app",
+		Before:        before,
+		After:         after,
+		OmitSnapshots: true,
+	})
+}
+
+func auditActorName(user *model.UserModel) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
+
+func parseAuditLogQuery(c *fiber.Ctx) model.AuditLogQuery {
+	return model.AuditLogQuery{
+		ActorID:    c.Query("actorId"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("targetId"),
+		WorldID:    c.Query("worldId"),
+		Keyword:    c.Query("keyword"),
+		StartMS:    int64(c.QueryInt("start", 0)),
+		EndMS:      int64(c.QueryInt("end", 0)),
+		Page:       c.QueryInt("page", 1),
+		PageSize:   c.QueryInt("pageSize", 20),
+	}
+}
+
+// AdminAuditLogList 平台管理员查询全部审计日志
+func AdminAuditLogList(c *fiber.Ctx) error {
+	if !CanWithSystemRole(c, pm.PermModAdmin) {
+		return nil
+	}
+	result, err := service.AuditLogList(parseAuditLogQuery(c))
+	if err != nil {
+		return wrapErrorStatus(c, fiber.StatusInternalServerError, err, "读取审计日志失败")
+	}
+	return c.JSON(result)
+}
+
+// WorldAuditLogList 世界拥有者查询本世界的审计日志
+func WorldAuditLogList(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	result, err := service.AuditLogListForWorld(c.Params("worldId"), user.ID, parseAuditLogQuery(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWorldPermission):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "仅世界拥有者可查看审计日志"})
+		case errors.Is(err, service.ErrWorldNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "世界不存在"})
+		default:
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "读取审计日志失败"})
+		}
+	}
+	return c.JSON(result)
+}
@@ -24,6 +24,7 @@ type battleReportRequest struct {
 	AIProviderID       string   `json:"aiProviderId"`
 	AIModel            string   `json:"aiModel"`
 	AIFeatureKey       string   `json:"aiFeatureKey"`
+	IncludeDiceStats   bool     `json:"includeDiceStats"`
 }
 
 type battleReportReorderRequest struct {
@@ -282,6 +283,7 @@ func BattleReportSummarize(c *fiber.Ctx) error {
 		ContextReportCount: req.ContextReportCount,
 		SourceChannelIDs:   req.SourceChannelIDs,
 		Source:             req.Source,
+		IncludeDiceStats:   req.IncludeDiceStats,
 		AIConfig:           cfg,
 		Runner:             runner,
 	})
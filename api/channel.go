@@ -778,6 +778,20 @@ func RolePermApply(c *fiber.Ctx) error {
 	// 更新角色权限
 	oldPerms := pm.ChannelRolePermsGet(req.RoleId)
 	pm.RolePermApply(req.RoleId, req.Permissions)
+	auditEntry := service.AuditEntry{
+		Action:     model.AuditActionRolePermApply,
+		TargetType: model.AuditTargetRole,
+		TargetID:   req.RoleId,
+		ChannelID:  chId,
+		Before:     fiber.Map{"permissions": oldPerms},
+		After:      fiber.Map{"permissions": req.Permissions},
+	}
+	if chId != "" {
+		if channel, err := model.ChannelGet(chId); err == nil && channel != nil {
+			auditEntry.WorldID = channel.WorldID
+		}
+	}
+	recordAudit(c, auditEntry)
 	if chId != "" && channelRoleVisibilityPermissionsChanged(oldPerms, req.Permissions) {
 		broadcastChannelTreeInvalidatedByChannelID(getCurUser(c), chId, "role-perm-visibility")
 	}
@@ -392,6 +392,7 @@ func apiChannelMemberListOnline(ctx *ChatContext, data *struct {
 				return true
 			})
 		}
+		arr = append(arr, remoteChannelPresenceUserIDs(data.ChannelId)...)
 		q = q.Where("id in ?", arr)
 	})
 }
@@ -413,12 +414,17 @@ func apiChannelMemberList(ctx *ChatContext, data *struct {
 }
 
 func apiChannelDefaultDiceUpdate(ctx *ChatContext, data *struct {
-	ChannelID       string `json:"channel_id"`
-	DefaultDiceExpr string `json:"default_dice_expr"`
+	ChannelID       string  `json:"channel_id"`
+	DefaultDiceExpr *string `json:"default_dice_expr"`
+	DiceRuleSystem  *string `json:"dice_rule_system"`
+	VerifiableDice  *bool   `json:"verifiable_dice_enabled"`
 }) (any, error) {
 	if data.ChannelID == "" {
 		return nil, fmt.Errorf("频道ID不能为空")
 	}
+	if data.DefaultDiceExpr == nil && data.DiceRuleSystem == nil && data.VerifiableDice == nil {
+		return nil, fmt.Errorf("没有需要更新的内容")
+	}
 	if !pm.CanWithChannelRole(ctx.User.ID, data.ChannelID, pm.PermFuncChannelManageInfo, pm.PermFuncChannelRoleLink) {
 		return nil, fmt.Errorf("您没有权限修改默认骰")
 	}
@@ -429,16 +435,32 @@ func apiChannelDefaultDiceUpdate(ctx *ChatContext, data *struct {
 	if channel.ID == "" {
 		return nil, fmt.Errorf("频道不存在")
 	}
-	normalized, err := service.NormalizeDefaultDiceExpr(data.DefaultDiceExpr)
-	if err != nil {
-		return nil, err
+	updates := map[string]any{}
+	if data.DefaultDiceExpr != nil {
+		normalized, err := service.NormalizeDefaultDiceExpr(*data.DefaultDiceExpr)
+		if err != nil {
+			return nil, err
+		}
+		updates["default_dice_expr"] = normalized
+		channel.DefaultDiceExpr = normalized
+	}
+	if data.DiceRuleSystem != nil {
+		ruleSystem, err := service.NormalizeDiceRuleSystem(*data.DiceRuleSystem)
+		if err != nil {
+			return nil, err
+		}
+		updates["dice_rule_system"] = ruleSystem
+		channel.DiceRuleSystem = ruleSystem
+	}
+	if data.VerifiableDice != nil {
+		updates["verifiable_dice_enabled"] = *data.VerifiableDice
+		channel.VerifiableDiceEnabled = *data.VerifiableDice
 	}
 	if err := model.GetDB().Model(&model.ChannelModel{}).
 		Where("id = ?", channel.ID).
-		Update("default_dice_expr", normalized).Error; err != nil {
+		Updates(updates).Error; err != nil {
 		return nil, err
 	}
-	channel.DefaultDiceExpr = normalized
 	channelData := channel.ToProtocolType()
 	characterEnabled, characterReason := GetChannelCharacterAPICapability(channel.ID, channel)
 	channelData.CharacterAPIEnabled = characterEnabled
@@ -456,7 +478,9 @@ func apiChannelDefaultDiceUpdate(ctx *ChatContext, data *struct {
 	return &struct {
 		ChannelID       string `json:"channel_id"`
 		DefaultDiceExpr string `json:"default_dice_expr"`
-	}{ChannelID: channel.ID, DefaultDiceExpr: normalized}, nil
+		DiceRuleSystem  string `json:"dice_rule_system"`
+		VerifiableDice  bool   `json:"verifiable_dice_enabled"`
+	}{ChannelID: channel.ID, DefaultDiceExpr: channel.DefaultDiceExpr, DiceRuleSystem: channel.DiceRuleSystem, VerifiableDice: channel.VerifiableDiceEnabled}, nil
 }
 
 func apiChannelFeatureUpdate(ctx *ChatContext, data *struct {
@@ -563,10 +563,13 @@ func apiMessageContext(ctx *ChatContext, data *struct {
 	beforeLimit := clampMessageContextWindow(data.Before, 12)
 	afterLimit := clampMessageContextWindow(data.After, 12)
 	canReadAllWhispers := canUserReadAllWhispersInChannel(ctx.User.ID, channelID)
+	var raw model.MessageModel
 	baseQuery := func() *gorm.DB {
 		q := db.Model(&model.MessageModel{}).
 			Where("channel_id = ?", channelID).
 			Where("is_deleted = ?", false)
+		// 上下文只取目标消息所在的时间线：主时间线不混入话题回复，话题回复只取同一话题
+		q = q.Where("thread_id = ?", raw.ThreadID)
 		q = applyWhisperVisibilityFilterWithReadAll(q, ctx.User.ID, canReadAllWhispers)
 		if !includeArchived {
 			q = q.Where("is_archived = ?", false)
@@ -579,7 +582,6 @@ func apiMessageContext(ctx *ChatContext, data *struct {
 		return q
 	}
 
-	var raw model.MessageModel
 	db.Model(&model.MessageModel{}).
 		Where("channel_id = ? AND id = ?", channelID, messageID).
 		Limit(1).
@@ -927,6 +929,16 @@ func apiMessageRemove(ctx *ChatContext, data *messageRemovePayload) (any, error)
 		_ = model.WebhookEventLogAppendForMessage(channelID, "message-removed", msg.ID)
 	}
 
+	threadIDs := make([]string, 0)
+	for _, msg := range messages {
+		if msg.ThreadID != "" {
+			threadIDs = append(threadIDs, msg.ThreadID)
+		}
+	}
+	for _, threadID := range lo.Uniq(threadIDs) {
+		refreshMessageThreadAndBroadcast(ctx, channelData, threadID, nil)
+	}
+
 	return &messageRemoveResult{
 		Success:    true,
 		MessageIDs: ids,
@@ -1826,25 +1838,31 @@ func apiMessageUnarchive(ctx *ChatContext, data *struct {
 }
 
 func apiMessageCreate(ctx *ChatContext, data *struct {
-	ChannelID         string   `json:"channel_id"`
-	QuoteID           string   `json:"quote_id"`
-	Content           string   `json:"content"`
-	WhisperTo         string   `json:"whisper_to"`
-	WhisperToIds      []string `json:"whisper_to_ids"`
-	ClientID          string   `json:"client_id"`
-	IdentityID        string   `json:"identity_id"`
-	IdentityVariantID string   `json:"identity_variant_id"`
-	ICMode            string   `json:"ic_mode"`
-	BeforeID          string   `json:"before_id"`
-	AfterID           string   `json:"after_id"`
-	DisplayOrder      *float64 `json:"display_order"`
-	TypingDurationMs  *int64   `json:"typing_duration_ms"`
+	ChannelID         string                   `json:"channel_id"`
+	QuoteID           string                   `json:"quote_id"`
+	ThreadID          string                   `json:"thread_id"`
+	Content           string                   `json:"content"`
+	WhisperTo         string                   `json:"whisper_to"`
+	WhisperToIds      []string                 `json:"whisper_to_ids"`
+	ClientID          string                   `json:"client_id"`
+	IdentityID        string                   `json:"identity_id"`
+	IdentityVariantID string                   `json:"identity_variant_id"`
+	ICMode            string                   `json:"ic_mode"`
+	BeforeID          string                   `json:"before_id"`
+	AfterID           string                   `json:"after_id"`
+	DisplayOrder      *float64                 `json:"display_order"`
+	TypingDurationMs  *int64                   `json:"typing_duration_ms"`
+	Poll              *service.PollCreateInput `json:"poll"`
 }) (any, error) {
 	echo := ctx.Echo
 	db := model.GetDB()
 	channelId := data.ChannelID
 	trimmedClientID := strings.TrimSpace(data.ClientID)
 
+	if err := gatewayRateLimit(ctx, utils.RateLimitRouteMessageCreate, channelId, trimmedClientID); err != nil {
+		return nil, err
+	}
+
 	var privateOtherUser string
 	botMsgContext := resolveBotMessageContext(ctx, channelId)
 
@@ -1927,6 +1945,16 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 		}
 	}
 
+	// 投票消息：正文为空时以问题作为正文，便于通知与搜索
+	if data.Poll != nil {
+		if err := service.NormalizePollInput(data.Poll); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(content) == "" {
+			content = protocol.EscapeSatoriText(data.Poll.Question)
+		}
+	}
+
 	member, err := model.MemberGetByUserIDAndChannelID(ctx.User.ID, data.ChannelID, ctx.User.Nickname)
 	if err != nil {
 		return nil, err
@@ -2020,10 +2048,27 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			return existingMessageData, nil
 		}
 	}
+	// 可验证骰点需要在掷骰前确定消息ID
+	messageID := utils.NewID()
 	var renderResult *service.DiceRenderResult
 	var isHiddenDice bool
-	if effectiveBuiltInDiceEnabled {
-		renderResult, err = service.RenderDiceContent(content, channel.DefaultDiceExpr, nil)
+	if effectiveBuiltInDiceEnabled && data.Poll == nil {
+		senderIdentityID := ""
+		if identity != nil {
+			senderIdentityID = identity.ID
+		}
+		loadCard := service.NewDiceCardLoader(ctx.User.ID, channelId, senderIdentityID)
+		var fairness *service.DiceFairnessContext
+		if channel.VerifiableDiceEnabled {
+			if fairness, err = service.NewDiceFairnessContext(channelId, messageID); err != nil {
+				return nil, err
+			}
+		}
+		renderResult, err = service.RenderDiceContentWithOptions(content, channel.DefaultDiceExpr, nil, service.DiceRenderOptions{
+			RuleSystem: channel.DiceRuleSystem,
+			LoadCard:   loadCard,
+			Fairness:   fairness,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -2177,6 +2222,24 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 		}
 	}
 
+	// 话题回复：机器人引用话题内消息回复时自动归入同一话题
+	threadID := strings.TrimSpace(data.ThreadID)
+	if threadID == "" && ctx.User.IsBot && quote.ThreadID != "" && quote.ChannelID == channelId {
+		threadID = quote.ThreadID
+	}
+	var threadRoot *model.MessageModel
+	if threadID != "" {
+		if threadRoot, err = loadMessageThreadRoot(channelId, threadID); err != nil {
+			return nil, err
+		}
+		if whisperTo != "" || len(whisperRecipientIDs) > 0 {
+			return nil, fmt.Errorf("话题内不支持悄悄话")
+		}
+	}
+	if data.Poll != nil && (whisperTo != "" || len(whisperRecipientIDs) > 0) {
+		return nil, fmt.Errorf("悄悄话不支持发起投票")
+	}
+
 	nowMs := time.Now().UnixMilli()
 	displayOrder := float64(nowMs)
 	hasExplicitDisplayOrder := data.DisplayOrder != nil && *data.DisplayOrder > 0
@@ -2226,15 +2289,19 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 	}
 
 	widgetData := service.BuildStateWidgetDataFromContent(content)
+	if data.Poll != nil {
+		widgetData = service.BuildPollWidgetData(data.Poll)
+	}
 
 	m := model.MessageModel{
 		StringPKBaseModel: model.StringPKBaseModel{
-			ID: utils.NewID(),
+			ID: messageID,
 		},
 		UserID:           ctx.User.ID,
 		ChannelID:        data.ChannelID,
 		MemberID:         member.ID,
 		QuoteID:          data.QuoteID,
+		ThreadID:         threadID,
 		Content:          content,
 		VisibleCharCount: contentstats.CountVisibleTextChars(content),
 		WidgetData:       widgetData,
@@ -2282,9 +2349,26 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			m.WhisperTargetMemberName = whisperMember.Nickname
 		}
 	}
-	createResult := db.Create(&m)
-	if createResult.Error != nil {
-		if trimmedClientID != "" && isUniqueConstraintError(createResult.Error) {
+	var createResult *gorm.DB
+	createMessage := func(tx *gorm.DB) error {
+		createResult = tx.Create(&m)
+		if createResult.Error != nil {
+			return createResult.Error
+		}
+		if data.Poll != nil {
+			return model.PollCreate(tx, service.NewPollModel(data.Poll, m.ID, channelId, ctx.User.ID))
+		}
+		return nil
+	}
+	var createErr error
+	if data.Poll != nil {
+		// 投票消息与投票记录同时写入，避免留下找不到投票的消息
+		createErr = db.Transaction(createMessage)
+	} else {
+		createErr = createMessage(db)
+	}
+	if createErr != nil {
+		if trimmedClientID != "" && isUniqueConstraintError(createErr) {
 			existingMessageData, err := findExistingByClientID(trimmedClientID)
 			if err != nil {
 				return nil, err
@@ -2294,7 +2378,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 				return existingMessageData, nil
 			}
 		}
-		return nil, createResult.Error
+		return nil, createErr
 	}
 	if len(whisperRecipientIDs) > 0 {
 		if err := model.CreateWhisperRecipients(m.ID, whisperRecipientIDs); err != nil {
@@ -2380,7 +2464,17 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
 		}
 
+		if threadID != "" {
+			refreshMessageThreadAndBroadcast(ctx, channelData, threadID, &m)
+		}
+
 		_ = model.WebhookEventLogAppendForMessage(data.ChannelID, "message-created", m.ID)
+		// 仅用户在客户端亲手发送时清空草稿；定时消息等代发不能覆盖创建者正在编辑的内容
+		if threadID == "" && ctx.ConnInfo != nil && ctx.User != nil && !ctx.User.IsBot {
+			if err := service.MessageDraftClearOnSend(ctx.User.ID, data.ChannelID); err != nil {
+				log.Printf("清空草稿失败 channel=%s user=%s err=%v", data.ChannelID, ctx.User.ID, err)
+			}
+		}
 		if renderResult != nil {
 			if err := model.MessageDiceRollReplace(m.ID, renderResult.Rolls); err != nil {
 				return nil, err
@@ -2391,6 +2485,11 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 				log.Printf("digest-push: 记录消息摘要窗口失败 channel=%s message=%s err=%v", channelID, message.ID, err)
 			}
 		}(data.ChannelID, m)
+		if whisperUser != nil {
+			notifyWebPushForMessage(ctx.User, channel, &m, content, lo.Uniq(append([]string{whisperUser.ID}, whisperRecipientIDs...)), &quote)
+		} else {
+			notifyWebPushForMessage(ctx.User, channel, &m, content, nil, &quote)
+		}
 
 		if isHiddenDice && len(channelId) < 30 {
 			go sendHiddenDicePrivateCopy(ctx, channelData, messageData)
@@ -2423,7 +2522,13 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			model.FriendRelationSetVisibleById(channel.ID)
 		}
 
-		if whisperUser != nil {
+		if threadID != "" {
+			// 话题回复只影响话题未读，根消息作者自动关注话题
+			if threadRoot.UserID != "" && threadRoot.UserID != ctx.User.ID {
+				_ = model.MessageThreadReadInit(data.ChannelID, threadID, threadRoot.UserID)
+			}
+			_ = model.MessageThreadReadSet(data.ChannelID, threadID, ctx.User.ID, m.CreatedAt)
+		} else if whisperUser != nil {
 			targets := make([]string, 0, len(whisperRecipientIDs)+1)
 			if whisperTo != "" {
 				targets = append(targets, whisperTo)
@@ -2437,10 +2542,14 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 				_ = model.ChannelReadInit(data.ChannelID, uid)
 				ctx.BroadcastToUserJSON(uid, buildMessageCreatedNoticePayload(data.ChannelID, content, uid))
 			}
+			receiptMsg := m
+			markOnlineReadersForMessage(ctx, &receiptMsg, targets)
 		} else if channel.PermType == "private" {
 			if privateOtherUser != "" {
 				_ = model.ChannelReadInit(data.ChannelID, privateOtherUser)
 				ctx.BroadcastToUserJSON(privateOtherUser, buildMessageCreatedNoticePayload(data.ChannelID, content, privateOtherUser))
+				receiptMsg := m
+				markOnlineReadersForMessage(ctx, &receiptMsg, []string{privateOtherUser})
 			}
 		} else {
 			// 给当前在线人都通知一遍
@@ -2461,6 +2570,8 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 
 			_ = model.ChannelReadInitInBatches(data.ChannelID, uids)
 			_ = model.ChannelReadSetInBatch([]string{data.ChannelID}, uidsOnline)
+			receiptMsg := m
+			go broadcastReadReceiptsForMessage(ctx, &receiptMsg, uidsOnline)
 
 			// 发送快速更新通知
 			onlineSet := make(map[string]struct{}, len(uidsOnline))
@@ -2509,6 +2620,7 @@ func apiMessageList(ctx *ChatContext, data *struct {
 	RoleIDs         []string `json:"role_ids"`
 	IncludeRoleless bool     `json:"include_roleless"`
 	Limit           int      `json:"limit"`
+	ThreadID        string   `json:"thread_id"` // 指定时只返回该话题内的回复
 }) (any, error) {
 	db := model.GetDB()
 
@@ -2538,10 +2650,19 @@ func apiMessageList(ctx *ChatContext, data *struct {
 		}
 	}
 
+	threadID := strings.TrimSpace(data.ThreadID)
+	if threadID != "" {
+		if _, err := loadMessageThreadRoot(channelId, threadID); err != nil {
+			return nil, err
+		}
+	}
+
 	var items []*model.MessageModel
 	canReadAllWhispers := canUserReadAllWhispersInChannel(ctx.User.ID, data.ChannelID)
 	q := db.Where("channel_id = ?", data.ChannelID)
 	q = q.Where("is_deleted = ?", false)
+	// 主时间线不包含话题回复
+	q = q.Where("thread_id = ?", threadID)
 	q = applyWhisperVisibilityFilterWithReadAll(q, ctx.User.ID, canReadAllWhispers)
 
 	if data.ArchivedOnly {
@@ -2666,7 +2787,14 @@ func apiMessageList(ctx *ChatContext, data *struct {
 	}, "id, content, created_at, user_id, is_revoked, is_deleted, whisper_to, channel_id, sender_member_name, sender_identity_id, sender_identity_variant_id, sender_identity_name, sender_identity_color, sender_identity_avatar_id, sender_identity_is_temporary, whisper_sender_member_id, whisper_sender_member_name, whisper_sender_user_name, whisper_sender_user_nick, whisper_target_member_id, whisper_target_member_name, whisper_target_user_name, whisper_target_user_nick")
 
 	if !ctx.IsReadOnly() && !hasCursor && data.Type != "time" {
-		_ = model.ChannelReadSet(data.ChannelID, ctx.User.ID)
+		if threadID != "" {
+			_ = model.MessageThreadReadSet(data.ChannelID, threadID, ctx.User.ID, time.Now())
+		} else {
+			prevMark, _ := model.ChannelReadMarkGet(data.ChannelID, ctx.User.ID)
+			if err := model.ChannelReadSet(data.ChannelID, ctx.User.ID); err == nil {
+				go broadcastReadReceipts(ctx, data.ChannelID, ctx.User.ID, prevMark, time.Now().UnixMilli())
+			}
+		}
 	}
 
 	var next string
@@ -2885,6 +3013,10 @@ func apiMessageUpdate(ctx *ChatContext, data *struct {
 	if !isAuthor && !isAdminEdit {
 		return nil, nil
 	}
+	var auditBefore map[string]any
+	if !isAuthor {
+		auditBefore = map[string]any{"content": msg.Content, "icMode": msg.ICMode, "identityId": msg.SenderIdentityID}
+	}
 	channelData := channel.ToProtocolType()
 	effectiveBotFeatureEnabled := service.IsBotFeatureEffectivelyEnabled(channel)
 	effectiveBuiltInDiceEnabled := service.IsBuiltInDiceEffectivelyEnabled(channel)
@@ -3077,7 +3209,17 @@ func apiMessageUpdate(ctx *ChatContext, data *struct {
 		if msg.ID != "" {
 			replayCacheKey = fmt.Sprintf("%s:%d", msg.ID, msg.UpdatedAt.UnixMilli())
 		}
-		renderResult, err = service.RenderDiceContentWithExisting(newContent, channel.DefaultDiceExpr, existingDiceRolls, msg.Content, replayCacheKey, nil)
+		var fairness *service.DiceFairnessContext
+		if channel.VerifiableDiceEnabled {
+			if fairness, err = service.NewDiceFairnessContext(msg.ChannelID, msg.ID); err != nil {
+				return nil, err
+			}
+		}
+		renderResult, err = service.RenderDiceContentWithExisting(newContent, channel.DefaultDiceExpr, existingDiceRolls, msg.Content, replayCacheKey, nil, service.DiceRenderOptions{
+			RuleSystem: channel.DiceRuleSystem,
+			LoadCard:   service.NewDiceCardLoader(msg.UserID, msg.ChannelID, msg.SenderIdentityID),
+			Fairness:   fairness,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -3188,6 +3330,18 @@ func apiMessageUpdate(ctx *ChatContext, data *struct {
 	if err != nil {
 		return nil, err
 	}
+	if auditBefore != nil {
+		recordGatewayAudit(ctx, service.AuditEntry{
+			Action:     model.AuditActionMessageAdminEdit,
+			TargetType: model.AuditTargetMessage,
+			TargetID:   msg.ID,
+			TargetName: service.AuditUserDisplayName(msg.UserID),
+			WorldID:    channel.WorldID,
+			ChannelID:  msg.ChannelID,
+			Before:     auditBefore,
+			After:      map[string]any{"content": msg.Content, "icMode": msg.ICMode, "identityId": msg.SenderIdentityID},
+		})
+	}
 	if effectiveBuiltInDiceEnabled {
 		if err := model.MessageDiceRollReplace(msg.ID, updatedDiceRolls); err != nil {
 			return nil, err
@@ -4621,8 +4775,13 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 	MessageID   string `json:"message_id"`
 	WidgetIndex int    `json:"widget_index"`
 	Operation   string `json:"operation"`
+	// Options 投票时选择的选项序号
+	Options []int `json:"options"`
 }) (any, error) {
-	if data.Operation != service.WidgetOperationRotate && data.Operation != service.WidgetOperationReveal {
+	switch data.Operation {
+	case service.WidgetOperationRotate, service.WidgetOperationReveal,
+		service.WidgetOperationPollVote, service.WidgetOperationPollClose:
+	default:
 		return nil, fmt.Errorf("unsupported operation: %s", data.Operation)
 	}
 
@@ -4667,7 +4826,7 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 		if msg.UserID != ctx.User.ID {
 			return nil, fmt.Errorf("forbidden")
 		}
-	} else {
+	} else if data.Operation == service.WidgetOperationRotate {
 		// Permission check:
 		// - no @ mention: allow channel/world member with read access (already verified above)
 		// - has @ mention: only sender, mentioned user, or world admin/owner
@@ -4711,7 +4870,13 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 	var newJSON string
 	var changed bool
 	var txErr error
-	if model.IsSQLite() {
+	if data.Operation == service.WidgetOperationPollVote {
+		// 投票只需要频道读取权限，结束投票的权限由 service 校验
+		newJSON, txErr = service.PollVote(msg.ID, ctx.User.ID, data.Options)
+		changed = txErr == nil
+	} else if data.Operation == service.WidgetOperationPollClose {
+		newJSON, changed, txErr = service.PollClose(msg.ID, ctx.User.ID)
+	} else if model.IsSQLite() {
 		txErr = db.Transaction(func(tx *gorm.DB) error {
 			var fresh model.MessageModel
 			if err := tx.Select("widget_data").Where("id = ?", msg.ID).Take(&fresh).Error; err != nil {
@@ -4751,9 +4916,21 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 		return nil, txErr
 	}
 
+	messageData := broadcastWidgetMessageUpdated(ctx, msg.ID, changed)
+	if data.Operation == service.WidgetOperationPollClose && changed {
+		broadcastPollClosed(ctx, messageData)
+	}
+
+	return &struct {
+		Message *protocol.Message `json:"message"`
+	}{Message: messageData}, nil
+}
+
+// broadcastWidgetMessageUpdated 重新加载消息并广播交互式更新，悄悄话只发给参与者
+func broadcastWidgetMessageUpdated(ctx *ChatContext, messageID string, changed bool) *protocol.Message {
 	// Reload full message for broadcast
 	var fullMsg model.MessageModel
-	db.Preload("User").Preload("Member").Where("id = ?", msg.ID).Limit(1).Find(&fullMsg)
+	model.GetDB().Preload("User").Preload("Member").Where("id = ?", messageID).Limit(1).Find(&fullMsg)
 	if fullMsg.IsWhisper {
 		fullMsg.WhisperTarget = model.UserGet(fullMsg.WhisperTo)
 		fullMsg.WhisperTargets = loadWhisperTargetsForMessage(fullMsg.ChannelID, fullMsg.ID, fullMsg.WhisperTarget)
@@ -4777,9 +4954,11 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 		Type:                protocol.EventMessageUpdated,
 		Message:             messageData,
 		Channel:             channelData,
-		User:                ctx.User.ToProtocolType(),
 		IsInteractiveUpdate: true,
 	}
+	if ctx.User != nil {
+		ev.User = ctx.User.ToProtocolType()
+	}
 
 	if changed {
 		if fullMsg.IsWhisper {
@@ -4799,7 +4978,5 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 		}
 	}
 
-	return &struct {
-		Message *protocol.Message `json:"message"`
-	}{Message: messageData}, nil
+	return messageData
 }
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"sealchat/protocol"
+	"sealchat/service/broadcast"
+	"sealchat/utils"
+)
+
+// 远端节点在线态快照的有效期，超过后视为该节点已离线（全量兜底广播会定期刷新）
+const remoteChannelPresenceTTL = (2*channelPresenceFullBroadcastIntervalSeconds + 15) * time.Second
+
+type remoteChannelPresenceEntry struct {
+	presence  []*protocol.ChannelPresence
+	updatedAt time.Time
+}
+
+var remoteChannelPresenceState = struct {
+	sync.Mutex
+	byChannel map[string]map[string]*remoteChannelPresenceEntry
+}{
+	byChannel: map[string]map[string]*remoteChannelPresenceEntry{},
+}
+
+// publishBroadcastEnvelope 将已在本节点投递过的数据发送到其他节点。
+func publishBroadcastEnvelope(env *broadcast.Envelope, data any) {
+	if env == nil || broadcast.Get() == nil {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[broadcast] 序列化广播负载失败: kind=%s err=%v", env.Kind, err)
+		return
+	}
+	env.Payload = payload
+	broadcast.Publish(env)
+}
+
+// subscribeBroadcastBus 接收其他节点的广播并投递到本节点的连接。
+func subscribeBroadcastBus(
+	channelUsersMap *utils.SyncMap[string, *utils.SyncSet[string]],
+	userId2ConnInfo *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]],
+) func() {
+	bus := broadcast.Get()
+	if bus == nil {
+		return func() {}
+	}
+	return bus.Subscribe(func(env *broadcast.Envelope) {
+		ctx := &ChatContext{
+			ChannelUsersMap: channelUsersMap,
+			UserId2ConnInfo: userId2ConnInfo,
+		}
+		ctx.handleRemoteBroadcast(env)
+	})
+}
+
+func (ctx *ChatContext) handleRemoteBroadcast(env *broadcast.Envelope) {
+	if ctx == nil || env == nil {
+		return
+	}
+	switch env.Kind {
+	case broadcast.KindUser:
+		raw := json.RawMessage(env.Payload)
+		for _, userID := range env.UserIDs {
+			ctx.deliverToUserJSON(userID, raw)
+		}
+	case broadcast.KindAll:
+		ctx.deliverJSON(json.RawMessage(env.Payload), env.ExcludeUserIDs)
+	case broadcast.KindPresence:
+		ctx.handleRemoteChannelPresence(env)
+	case broadcast.KindSessionRevoke:
+		var tokenIDs []string
+		if err := json.Unmarshal(env.Payload, &tokenIDs); err != nil {
+			log.Printf("[broadcast] 解析远端会话注销失败: %v", err)
+			return
+		}
+		for _, userID := range env.UserIDs {
+			closeLocalAccessTokenConnections(ctx.UserId2ConnInfo, userID, tokenIDs)
+		}
+	default:
+		event := &protocol.Event{}
+		if err := json.Unmarshal(env.Payload, event); err != nil {
+			log.Printf("[broadcast] 解析远端事件失败: kind=%s err=%v", env.Kind, err)
+			return
+		}
+		switch env.Kind {
+		case broadcast.KindEvent:
+			ctx.deliverEvent(event)
+		case broadcast.KindChannel:
+			ctx.deliverEventInChannel(env.ChannelID, event)
+		case broadcast.KindChannelExcept:
+			ctx.deliverEventInChannelExcept(env.ChannelID, env.ExcludeUserIDs, event)
+		case broadcast.KindChannelUsers:
+			ctx.deliverEventInChannelToUsers(env.ChannelID, env.UserIDs, event)
+		case broadcast.KindChannelBot:
+			ctx.deliverEventInChannelForBot(env.ChannelID, env.UserIDs, event)
+		case broadcast.KindChannelViewers:
+			deliverEventToChannelViewers(ctx.UserId2ConnInfo, env.ChannelID, event)
+		case broadcast.KindWorld:
+			deliverEventToWorld(ctx.UserId2ConnInfo, env.WorldID, event)
+		case broadcast.KindLobby:
+			deliverLobbyEvent(ctx.UserId2ConnInfo, event)
+		}
+	}
+}
+
+// publishLocalChannelPresence 把本节点的频道在线态快照发给其他节点，由它们合并后下发。
+func publishLocalChannelPresence(channelID string, local []*protocol.ChannelPresence) {
+	if channelID == "" {
+		return
+	}
+	if local == nil {
+		local = []*protocol.ChannelPresence{}
+	}
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindPresence, ChannelID: channelID}, local)
+}
+
+func (ctx *ChatContext) handleRemoteChannelPresence(env *broadcast.Envelope) {
+	if env.ChannelID == "" || env.NodeID == "" {
+		return
+	}
+	var presence []*protocol.ChannelPresence
+	if err := json.Unmarshal(env.Payload, &presence); err != nil {
+		log.Printf("[broadcast] 解析远端在线态失败: %v", err)
+		return
+	}
+	storeRemoteChannelPresence(env.ChannelID, env.NodeID, presence, time.Now())
+	if ctx.ChannelUsersMap == nil || ctx.UserId2ConnInfo == nil {
+		return
+	}
+	users, ok := ctx.ChannelUsersMap.Load(env.ChannelID)
+	if !ok || users == nil || users.Len() == 0 {
+		return
+	}
+	local := buildChannelPresenceSnapshot(env.ChannelID, ctx.ChannelUsersMap, ctx.UserId2ConnInfo)
+	event := &protocol.Event{
+		Type:      protocol.EventChannelPresenceUpdated,
+		Timestamp: time.Now().Unix(),
+		Channel:   &protocol.Channel{ID: env.ChannelID},
+		Presence:  mergeRemoteChannelPresence(env.ChannelID, local, time.Now()),
+	}
+	ctx.deliverEventInChannel(env.ChannelID, event)
+}
+
+func storeRemoteChannelPresence(channelID, nodeID string, presence []*protocol.ChannelPresence, now time.Time) {
+	remoteChannelPresenceState.Lock()
+	defer remoteChannelPresenceState.Unlock()
+	nodes := remoteChannelPresenceState.byChannel[channelID]
+	if len(presence) == 0 {
+		if nodes != nil {
+			delete(nodes, nodeID)
+			if len(nodes) == 0 {
+				delete(remoteChannelPresenceState.byChannel, channelID)
+			}
+		}
+		return
+	}
+	if nodes == nil {
+		nodes = map[string]*remoteChannelPresenceEntry{}
+		remoteChannelPresenceState.byChannel[channelID] = nodes
+	}
+	nodes[nodeID] = &remoteChannelPresenceEntry{presence: presence, updatedAt: now}
+}
+
+// mergeRemoteChannelPresence 合并本节点与其他节点的在线态，同一用户保留最新活跃的一条。
+func mergeRemoteChannelPresence(channelID string, local []*protocol.ChannelPresence, now time.Time) []*protocol.ChannelPresence {
+	remoteChannelPresenceState.Lock()
+	nodes := remoteChannelPresenceState.byChannel[channelID]
+	var remote []*protocol.ChannelPresence
+	for nodeID, entry := range nodes {
+		if entry == nil || now.Sub(entry.updatedAt) > remoteChannelPresenceTTL {
+			delete(nodes, nodeID)
+			continue
+		}
+		remote = append(remote, entry.presence...)
+	}
+	if nodes != nil && len(nodes) == 0 {
+		delete(remoteChannelPresenceState.byChannel, channelID)
+	}
+	remoteChannelPresenceState.Unlock()
+
+	if len(remote) == 0 {
+		return local
+	}
+	byUser := make(map[string]*protocol.ChannelPresence, len(local)+len(remote))
+	order := make([]string, 0, len(local)+len(remote))
+	for _, item := range append(append([]*protocol.ChannelPresence{}, local...), remote...) {
+		if item == nil || item.User == nil || item.User.ID == "" {
+			continue
+		}
+		existing, ok := byUser[item.User.ID]
+		if !ok {
+			byUser[item.User.ID] = item
+			order = append(order, item.User.ID)
+			continue
+		}
+		if item.LastSeen > existing.LastSeen {
+			merged := *item
+			merged.Focused = merged.Focused || existing.Focused
+			byUser[item.User.ID] = &merged
+		} else if item.Focused && !existing.Focused {
+			merged := *existing
+			merged.Focused = true
+			byUser[item.User.ID] = &merged
+		}
+	}
+	results := make([]*protocol.ChannelPresence, 0, len(order))
+	for _, userID := range order {
+		results = append(results, byUser[userID])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Focused != results[j].Focused {
+			return results[i].Focused
+		}
+		return results[i].Latency < results[j].Latency
+	})
+	return results
+}
+
+// remoteChannelPresenceUserIDs 返回其他节点上仍在该频道内的用户。
+func remoteChannelPresenceUserIDs(channelID string) []string {
+	merged := mergeRemoteChannelPresence(channelID, nil, time.Now())
+	ids := make([]string, 0, len(merged))
+	for _, item := range merged {
+		if item != nil && item.User != nil && item.User.ID != "" {
+			ids = append(ids, item.User.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service/broadcast"
+	"sealchat/utils"
+)
+
+func TestBroadcastEventInChannelReachesConnectionOnOtherNode(t *testing.T) {
+	broadcast.SetDefault(broadcast.NewLocalBus("node-a-" + utils.NewIDWithLength(6)))
+	defer broadcast.SetDefault(nil)
+
+	remoteConn, remoteClient, cleanup := newReadableChatTestConn(t)
+	defer cleanup()
+	remoteMap := &utils.SyncMap[*WsSyncConn, *ConnInfo]{}
+	remoteMap.Store(remoteConn, &ConnInfo{
+		Conn:          remoteConn,
+		User:          &model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: "remote-user"}},
+		ChannelId:     "channel-bus",
+		LastPingTime:  1,
+		LastAliveTime: 1,
+	})
+	remoteChannelUsers := &utils.SyncMap[string, *utils.SyncSet[string]]{}
+	remoteUserSet := &utils.SyncSet[string]{}
+	remoteUserSet.Add("remote-user")
+	remoteChannelUsers.Store("channel-bus", remoteUserSet)
+	remoteUserConns := &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	remoteUserConns.Store("remote-user", remoteMap)
+	remoteCtx := &ChatContext{
+		ChannelUsersMap: remoteChannelUsers,
+		UserId2ConnInfo: remoteUserConns,
+	}
+	remoteBus := broadcast.NewLocalBus("node-b-" + utils.NewIDWithLength(6))
+	defer remoteBus.Close()
+	remoteBus.Subscribe(remoteCtx.handleRemoteBroadcast)
+
+	localCtx := &ChatContext{
+		ChannelUsersMap: &utils.SyncMap[string, *utils.SyncSet[string]]{},
+		UserId2ConnInfo: &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{},
+	}
+	localCtx.BroadcastEventInChannel("channel-bus", &protocol.Event{
+		Type:    protocol.EventMessageCreated,
+		Message: &protocol.Message{ID: "msg-bus", Content: "across nodes"},
+	})
+
+	_ = remoteClient.SetReadDeadline(time.Now().Add(time.Second))
+	_, body, err := remoteClient.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected remote node connection to receive broadcast: %v", err)
+	}
+	var got struct {
+		Op      protocol.Opcode    `json:"op"`
+		Type    protocol.EventName `json:"type"`
+		Message *protocol.Message  `json:"message"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode payload failed: %v", err)
+	}
+	if got.Op != protocol.OpEvent || got.Type != protocol.EventMessageCreated {
+		t.Fatalf("unexpected event envelope: %+v", got)
+	}
+	if got.Message == nil || got.Message.ID != "msg-bus" || got.Message.Content != "across nodes" {
+		t.Fatalf("unexpected message payload: %#v", got.Message)
+	}
+}
+
+func TestMergeRemoteChannelPresenceKeepsLatestPerUserAndExpires(t *testing.T) {
+	channelID := "channel-merge-" + utils.NewIDWithLength(6)
+	now := time.Now()
+	local := []*protocol.ChannelPresence{
+		{User: &protocol.User{ID: "u1"}, LastSeen: 100, Focused: false, Latency: 30},
+	}
+	storeRemoteChannelPresence(channelID, "node-x", []*protocol.ChannelPresence{
+		{User: &protocol.User{ID: "u1"}, LastSeen: 200, Focused: true, Latency: 10},
+		{User: &protocol.User{ID: "u2"}, LastSeen: 150, Focused: false, Latency: 20},
+	}, now)
+	storeRemoteChannelPresence(channelID, "node-stale", []*protocol.ChannelPresence{
+		{User: &protocol.User{ID: "u3"}, LastSeen: 50},
+	}, now.Add(-2*remoteChannelPresenceTTL))
+
+	merged := mergeRemoteChannelPresence(channelID, local, now)
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 merged entries, got %d", len(merged))
+	}
+	if merged[0].User.ID != "u1" || merged[0].LastSeen != 200 || !merged[0].Focused {
+		t.Fatalf("expected newest focused u1 first, got %#v", merged[0])
+	}
+	if merged[1].User.ID != "u2" {
+		t.Fatalf("expected remote-only u2, got %#v", merged[1])
+	}
+
+	storeRemoteChannelPresence(channelID, "node-x", nil, now)
+	if ids := remoteChannelPresenceUserIDs(channelID); len(ids) != 0 {
+		t.Fatalf("expected empty remote snapshot to clear node, got %v", ids)
+	}
+}
+
+func TestWorldEventReachesConnectionOnOtherNode(t *testing.T) {
+	broadcast.SetDefault(broadcast.NewLocalBus("node-a-" + utils.NewIDWithLength(6)))
+	defer broadcast.SetDefault(nil)
+
+	inWorldConn, inWorldClient, cleanupIn := newReadableChatTestConn(t)
+	defer cleanupIn()
+	otherConn, otherClient, cleanupOther := newReadableChatTestConn(t)
+	defer cleanupOther()
+	remoteMap := &utils.SyncMap[*WsSyncConn, *ConnInfo]{}
+	remoteMap.Store(inWorldConn, &ConnInfo{Conn: inWorldConn, WorldId: "world-bus"})
+	remoteMap.Store(otherConn, &ConnInfo{Conn: otherConn, WorldId: "world-other"})
+	remoteUserConns := &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	remoteUserConns.Store("remote-user", remoteMap)
+	remoteCtx := &ChatContext{UserId2ConnInfo: remoteUserConns}
+	remoteBus := broadcast.NewLocalBus("node-b-" + utils.NewIDWithLength(6))
+	defer remoteBus.Close()
+	remoteBus.Subscribe(remoteCtx.handleRemoteBroadcast)
+
+	// 本节点没有连接，事件只能经总线到达
+	broadcastEventToWorld("world-bus", &protocol.Event{Type: protocol.EventWorldKeywordsUpdated})
+
+	_ = inWorldClient.SetReadDeadline(time.Now().Add(time.Second))
+	_, body, err := inWorldClient.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected world connection on other node to receive event: %v", err)
+	}
+	var got struct {
+		Op   protocol.Opcode    `json:"op"`
+		Type protocol.EventName `json:"type"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil || got.Op != protocol.OpEvent || got.Type != protocol.EventWorldKeywordsUpdated {
+		t.Fatalf("unexpected payload: %s err=%v", body, err)
+	}
+	_ = otherClient.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := otherClient.ReadMessage(); err == nil {
+		t.Fatal("connection in another world should not receive the event")
+	}
+}
+
+func TestSessionRevokeClosesConnectionOnOtherNode(t *testing.T) {
+	broadcast.SetDefault(broadcast.NewLocalBus("node-a-" + utils.NewIDWithLength(6)))
+	defer broadcast.SetDefault(nil)
+
+	revokedConn, revokedClient, cleanupRevoked := newReadableChatTestConn(t)
+	defer cleanupRevoked()
+	keptConn, keptClient, cleanupKept := newReadableChatTestConn(t)
+	defer cleanupKept()
+	user := &model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: "remote-user"}}
+	remoteMap := &utils.SyncMap[*WsSyncConn, *ConnInfo]{}
+	remoteMap.Store(revokedConn, &ConnInfo{Conn: revokedConn, User: user, AccessTokenID: "token-revoked"})
+	remoteMap.Store(keptConn, &ConnInfo{Conn: keptConn, User: user, AccessTokenID: "token-kept"})
+	remoteUserConns := &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	remoteUserConns.Store("remote-user", remoteMap)
+	remoteCtx := &ChatContext{
+		ChannelUsersMap: &utils.SyncMap[string, *utils.SyncSet[string]]{},
+		UserId2ConnInfo: remoteUserConns,
+	}
+	remoteBus := broadcast.NewLocalBus("node-b-" + utils.NewIDWithLength(6))
+	defer remoteBus.Close()
+	remoteBus.Subscribe(remoteCtx.handleRemoteBroadcast)
+
+	if closed := closeAccessTokenConnections("remote-user", []string{"token-revoked"}); closed != 0 {
+		t.Fatalf("no local connection should be closed, got %d", closed)
+	}
+
+	_ = revokedClient.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := revokedClient.ReadMessage(); err == nil || isTimeoutError(err) {
+		t.Fatalf("revoked session connection on the other node should be closed, got %v", err)
+	}
+	_ = keptClient.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := keptClient.ReadMessage(); !isTimeoutError(err) {
+		t.Fatalf("other sessions should stay connected, got %v", err)
+	}
+}
+
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -9,6 +9,8 @@ import (
 	"sealchat/model"
 	"sealchat/protocol"
 	"sealchat/service"
+	"sealchat/service/broadcast"
+	"sealchat/service/metrics/telemetry"
 	"sealchat/utils"
 )
 
@@ -173,6 +175,11 @@ func userHasChannelConnection(userId string, channelId string, userId2ConnInfo *
 }
 
 func (ctx *ChatContext) BroadcastToUserJSON(userId string, data any) {
+	ctx.deliverToUserJSON(userId, data)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindUser, UserIDs: []string{userId}}, data)
+}
+
+func (ctx *ChatContext) deliverToUserJSON(userId string, data any) {
 	connMap, _ := ctx.UserId2ConnInfo.Load(userId)
 	if connMap == nil {
 		return
@@ -184,6 +191,11 @@ func (ctx *ChatContext) BroadcastToUserJSON(userId string, data any) {
 }
 
 func (ctx *ChatContext) BroadcastJSON(data any, ignoredUserIds []string) {
+	ctx.deliverJSON(data, ignoredUserIds)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindAll, ExcludeUserIDs: ignoredUserIds}, data)
+}
+
+func (ctx *ChatContext) deliverJSON(data any, ignoredUserIds []string) {
 	ignoredMap := make(map[string]bool)
 	for _, id := range ignoredUserIds {
 		ignoredMap[id] = true
@@ -200,42 +212,54 @@ func (ctx *ChatContext) BroadcastJSON(data any, ignoredUserIds []string) {
 	})
 }
 
+// recordWSEventMetric 按事件名累计本节点发起的事件广播
+func recordWSEventMetric(data *protocol.Event) {
+	if data == nil {
+		return
+	}
+	telemetry.WSEvents.Inc(string(data.Type))
+}
+
 func (ctx *ChatContext) BroadcastEvent(data *protocol.Event) {
 	data.Timestamp = time.Now().Unix()
-	ctx.UserId2ConnInfo.Range(func(_ string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+	recordWSEventMetric(data)
+	ctx.deliverEvent(data)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindEvent}, data)
+}
+
+func (ctx *ChatContext) deliverEvent(data *protocol.Event) {
+	ctx.UserId2ConnInfo.Range(func(userId string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+		payload := newLazyEventPayload(userId, data)
 		connMap.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
-			writeConnJSONAndPrune(connMap, conn, struct {
-				protocol.Event
-				Op protocol.Opcode `json:"op"`
-			}{
-				// 协议规定: 事件中必须含有 channel，message，user
-				Event: *data,
-				Op:    protocol.OpEvent,
-			})
+			// 协议规定: 事件中必须含有 channel，message，user
+			writeConnJSONAndPrune(connMap, conn, payload())
 			return true
 		})
 		return true
 	})
+	recordDetachedGlobalEvent(data)
 }
 
 func (ctx *ChatContext) BroadcastEventInChannel(channelId string, data *protocol.Event) {
 	data.Timestamp = time.Now().Unix()
-	ctx.rangeChannelConnMaps(channelId, func(_ string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo], indexed bool) bool {
+	recordWSEventMetric(data)
+	ctx.deliverEventInChannel(channelId, data)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindChannel, ChannelID: channelId}, data)
+}
+
+func (ctx *ChatContext) deliverEventInChannel(channelId string, data *protocol.Event) {
+	ctx.rangeChannelConnMaps(channelId, func(userId string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo], indexed bool) bool {
+		payload := newLazyEventPayload(userId, data)
 		connMap.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info != nil && ((indexed && info.ChannelId == "") || info.ChannelId == channelId) {
-				writeConnJSONAndPrune(connMap, conn, struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					// 协议规定: 事件中必须含有 channel，message，user
-					Event: *data,
-					Op:    protocol.OpEvent,
-				})
+				// 协议规定: 事件中必须含有 channel，message，user
+				writeConnJSONAndPrune(connMap, conn, payload())
 			}
 			return true
 		})
 		return true
 	})
+	recordDetachedChannelEvent(channelId, data, nil)
 }
 
 func (ctx *ChatContext) BroadcastEventInChannelForBot(channelId string, data *protocol.Event) {
@@ -244,10 +268,20 @@ func (ctx *ChatContext) BroadcastEventInChannelForBot(channelId string, data *pr
 	}
 	data = normalizeEventForBot(data)
 	data.Timestamp = time.Now().Unix()
+	recordWSEventMetric(data)
 	botIDs, err := service.EventBotIDsByChannelId(channelId)
 	if err != nil {
 		return
 	}
+	ctx.deliverEventInChannelForBot(channelId, botIDs, data)
+	for _, botID := range botIDs {
+		getOneBotRuntime().publishProtocolEvent(botID, data, ctx.OneBotSessionID)
+	}
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindChannelBot, ChannelID: channelId, UserIDs: botIDs}, data)
+}
+
+// deliverEventInChannelForBot 仅向本节点持有的 BOT WebSocket 连接投递；OneBot 运行时由事件来源节点负责。
+func (ctx *ChatContext) deliverEventInChannelForBot(channelId string, botIDs []string, data *protocol.Event) {
 	for _, botID := range botIDs {
 		if x, ok := ctx.UserId2ConnInfo.Load(botID); ok {
 			var activeConn *WsSyncConn
@@ -280,7 +314,6 @@ func (ctx *ChatContext) BroadcastEventInChannelForBot(channelId string, data *pr
 				})
 			}
 		}
-		getOneBotRuntime().publishProtocolEvent(botID, data, ctx.OneBotSessionID)
 	}
 }
 
@@ -493,35 +526,47 @@ func normalizeWhisperTargetIDs(ids []string) []string {
 }
 
 func (ctx *ChatContext) BroadcastEventInChannelExcept(channelId string, ignoredUserIds []string, data *protocol.Event) {
+	data.Timestamp = time.Now().Unix()
+	recordWSEventMetric(data)
+	ctx.deliverEventInChannelExcept(channelId, ignoredUserIds, data)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindChannelExcept, ChannelID: channelId, ExcludeUserIDs: ignoredUserIds}, data)
+}
+
+func (ctx *ChatContext) deliverEventInChannelExcept(channelId string, ignoredUserIds []string, data *protocol.Event) {
 	ignoredMap := make(map[string]struct{}, len(ignoredUserIds))
 	for _, id := range ignoredUserIds {
 		ignoredMap[id] = struct{}{}
 	}
-	data.Timestamp = time.Now().Unix()
 	ctx.rangeChannelConnMaps(channelId, func(userId string, value *utils.SyncMap[*WsSyncConn, *ConnInfo], indexed bool) bool {
 		if _, ignored := ignoredMap[userId]; ignored {
 			return true
 		}
+		payload := newLazyEventPayload(userId, data)
 		value.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info != nil && ((indexed && info.ChannelId == "") || info.ChannelId == channelId) {
-				writeConnJSONAndPrune(value, conn, struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					Event: *data,
-					Op:    protocol.OpEvent,
-				})
+				writeConnJSONAndPrune(value, conn, payload())
 			}
 			return true
 		})
 		return true
 	})
+	recordDetachedChannelEvent(channelId, data, func(userId string) bool {
+		_, ignored := ignoredMap[userId]
+		return ignored
+	})
 }
 
 func (ctx *ChatContext) BroadcastEventInChannelToUsers(channelId string, userIds []string, data *protocol.Event) {
 	if len(userIds) == 0 {
 		return
 	}
+	data.Timestamp = time.Now().Unix()
+	recordWSEventMetric(data)
+	ctx.deliverEventInChannelToUsers(channelId, userIds, data)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindChannelUsers, ChannelID: channelId, UserIDs: userIds}, data)
+}
+
+func (ctx *ChatContext) deliverEventInChannelToUsers(channelId string, userIds []string, data *protocol.Event) {
 	targets := make(map[string]struct{}, len(userIds))
 	for _, id := range userIds {
 		targets[id] = struct{}{}
@@ -539,21 +584,16 @@ func (ctx *ChatContext) BroadcastEventInChannelToUsers(channelId string, userIds
 			})
 		}
 	}
-	data.Timestamp = time.Now().Unix()
 	for userId := range targets {
 		value, ok := ctx.UserId2ConnInfo.Load(userId)
-		if !ok || value == nil {
+		if !ok || value == nil || value.Len() == 0 {
+			recordDetachedUserEvent(userId, channelId, data)
 			continue
 		}
+		payload := newLazyEventPayload(userId, data)
 		value.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info != nil && (info.ChannelId == "" || info.ChannelId == channelId) {
-				writeConnJSONAndPrune(value, conn, struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					Event: *data,
-					Op:    protocol.OpEvent,
-				})
+				writeConnJSONAndPrune(value, conn, payload())
 			}
 			return true
 		})
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+// 断线重连事件重放配置
+const (
+	// 每用户保留的事件条数，超出后最旧事件被覆盖
+	eventReplayBufferSize = 512
+	// 用户全部连接断开后，继续为其最后所在频道缓存事件的时长
+	eventReplayDetachedTTL = 2 * time.Minute
+)
+
+type eventReplayEntry struct {
+	seq       int64
+	channelID string
+	payload   json.RawMessage
+}
+
+// userEventReplayRing 为单个用户维护有界事件缓冲与单调递增序号。
+// epoch 在缓冲重建时变化（服务重启、切换节点、缓冲过期），客户端据此判断序号是否可比。
+type userEventReplayRing struct {
+	mu              sync.Mutex
+	epoch           string
+	lastSeq         int64
+	entries         []eventReplayEntry
+	start           int
+	detachedChannel string
+	detachedUntil   int64
+}
+
+type eventReplayResume struct {
+	Epoch          string
+	Sequence       int64
+	ResyncRequired bool
+	Events         []json.RawMessage
+}
+
+var (
+	eventReplayRings             utils.SyncMap[string, *userEventReplayRing]
+	eventReplayDetachedByChannel utils.SyncMap[string, *utils.SyncSet[string]]
+)
+
+// replayEventPayload 是带序号的事件下发格式，seq 为 0 时省略以兼容旧客户端。
+type replayEventPayload struct {
+	protocol.Event
+	Op  protocol.Opcode `json:"op"`
+	Seq int64           `json:"seq,omitempty"`
+}
+
+// eventPayloadSeq 取出已编号事件负载的序号，非事件或未编号时返回 0
+func eventPayloadSeq(v any) int64 {
+	switch payload := v.(type) {
+	case json.RawMessage:
+		var head struct {
+			Seq int64 `json:"seq"`
+		}
+		if err := json.Unmarshal(payload, &head); err == nil {
+			return head.Seq
+		}
+	case replayEventPayload:
+		return payload.Seq
+	}
+	return 0
+}
+
+func newUserEventReplayRing() *userEventReplayRing {
+	return &userEventReplayRing{
+		epoch:   utils.NewIDWithLength(12),
+		entries: make([]eventReplayEntry, 0, 16),
+	}
+}
+
+func isReplayableEvent(data *protocol.Event) bool {
+	if data == nil {
+		return false
+	}
+	switch data.Type {
+	case protocol.EventTypingPreview, protocol.EventChannelPresenceUpdated:
+		return false
+	}
+	return true
+}
+
+func (r *userEventReplayRing) append(data *protocol.Event) (json.RawMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seq := r.lastSeq + 1
+	payload, err := json.Marshal(replayEventPayload{Event: *data, Op: protocol.OpEvent, Seq: seq})
+	if err != nil {
+		return nil, err
+	}
+	r.lastSeq = seq
+	entry := eventReplayEntry{seq: seq, payload: payload}
+	if data.Channel != nil {
+		entry.channelID = data.Channel.ID
+	}
+	if len(r.entries) < eventReplayBufferSize {
+		r.entries = append(r.entries, entry)
+	} else {
+		r.entries[r.start] = entry
+		r.start = (r.start + 1) % len(r.entries)
+	}
+	return payload, nil
+}
+
+// since 返回序号大于 after 的事件，以及取数时的最新序号；缺口超出缓冲范围时 ok 为 false。
+// 与实时投递一致，带频道的事件只补发 channelID 对应频道的，不带频道的事件总是补发。
+func (r *userEventReplayRing) since(after int64, channelID string) (events []json.RawMessage, last int64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last = r.lastSeq
+	if after > r.lastSeq || after < 0 {
+		return nil, last, false
+	}
+	if after == r.lastSeq {
+		return nil, last, true
+	}
+	oldest := r.lastSeq - int64(len(r.entries)) + 1
+	if after+1 < oldest {
+		return nil, last, false
+	}
+	events = make([]json.RawMessage, 0, r.lastSeq-after)
+	for i := 0; i < len(r.entries); i++ {
+		entry := r.entries[(r.start+i)%len(r.entries)]
+		if entry.seq <= after || (entry.channelID != "" && entry.channelID != channelID) {
+			continue
+		}
+		events = append(events, entry.payload)
+	}
+	return events, last, true
+}
+
+// newLazyEventPayload 返回投递给某用户的事件负载生成器：首次调用时才分配序号并写入缓冲，
+// 同一用户的多个连接共享同一序号，未实际投递的用户不会占用序号。
+func newLazyEventPayload(userID string, data *protocol.Event) func() any {
+	var payload any
+	return func() any {
+		if payload != nil {
+			return payload
+		}
+		if ring, ok := eventReplayRings.Load(userID); ok && ring != nil && isReplayableEvent(data) {
+			if raw, err := ring.append(data); err == nil {
+				payload = raw
+				return payload
+			}
+		}
+		payload = replayEventPayload{Event: *data, Op: protocol.OpEvent}
+		return payload
+	}
+}
+
+// recordDetachedChannelEvent 为刚断线、最后停留在该频道的用户缓存事件，等待其重连重放。
+func recordDetachedChannelEvent(channelID string, data *protocol.Event, skip func(userID string) bool) {
+	if channelID == "" || !isReplayableEvent(data) {
+		return
+	}
+	users, ok := eventReplayDetachedByChannel.Load(channelID)
+	if !ok || users == nil {
+		return
+	}
+	now := time.Now().UnixMilli()
+	users.Range(func(userID string) bool {
+		if skip != nil && skip(userID) {
+			return true
+		}
+		ring, ok := eventReplayRings.Load(userID)
+		if !ok || ring == nil {
+			users.Delete(userID)
+			return true
+		}
+		ring.mu.Lock()
+		active := ring.detachedChannel == channelID && ring.detachedUntil > now
+		ring.mu.Unlock()
+		if !active {
+			users.Delete(userID)
+			return true
+		}
+		_, _ = ring.append(data)
+		return true
+	})
+}
+
+// recordDetachedUserEvent 为指定的断线用户缓存定向事件（如悄悄话）。
+func recordDetachedUserEvent(userID string, channelID string, data *protocol.Event) {
+	if !isReplayableEvent(data) {
+		return
+	}
+	ring, ok := eventReplayRings.Load(userID)
+	if !ok || ring == nil {
+		return
+	}
+	ring.mu.Lock()
+	active := ring.detachedUntil > time.Now().UnixMilli() &&
+		(ring.detachedChannel == "" || channelID == "" || ring.detachedChannel == channelID)
+	ring.mu.Unlock()
+	if active {
+		_, _ = ring.append(data)
+	}
+}
+
+// recordDetachedGlobalEvent 为全部断线用户缓存全局事件。
+func recordDetachedGlobalEvent(data *protocol.Event) {
+	if !isReplayableEvent(data) {
+		return
+	}
+	now := time.Now().UnixMilli()
+	eventReplayRings.Range(func(_ string, ring *userEventReplayRing) bool {
+		if ring == nil {
+			return true
+		}
+		ring.mu.Lock()
+		active := ring.detachedUntil > now
+		ring.mu.Unlock()
+		if active {
+			_, _ = ring.append(data)
+		}
+		return true
+	})
+}
+
+// resumeEventReplay 在用户 identify 时调用：挂接（或新建）重放缓冲，并计算需要补发的事件。
+// lastSeq 为 0 表示全新会话，不做重放；channelID 为客户端重连后所在的频道，留空时沿用断线前的频道。
+// 返回的 Sequence 与补发事件取自同一时刻，序号不大于它的实时事件应由调用方丢弃。
+func resumeEventReplay(userID string, epoch string, lastSeq int64, channelID string) eventReplayResume {
+	ring, _ := eventReplayRings.LoadOrStore(userID, newUserEventReplayRing())
+	ring.mu.Lock()
+	detachedChannel := ring.detachedChannel
+	ring.detachedChannel = ""
+	ring.detachedUntil = 0
+	currentEpoch := ring.epoch
+	resume := eventReplayResume{Epoch: currentEpoch, Sequence: ring.lastSeq}
+	ring.mu.Unlock()
+	channelID = strings.TrimSpace(channelID)
+	if channelID == "" {
+		channelID = detachedChannel
+	}
+	if detachedChannel != "" {
+		if users, ok := eventReplayDetachedByChannel.Load(detachedChannel); ok && users != nil {
+			users.Delete(userID)
+		}
+	}
+
+	epoch = strings.TrimSpace(epoch)
+	if lastSeq > 0 || epoch != "" {
+		if epoch != currentEpoch {
+			resume.ResyncRequired = true
+		} else {
+			events, last, ok := ring.since(lastSeq, channelID)
+			resume.Sequence = last
+			if ok {
+				resume.Events = events
+			} else {
+				resume.ResyncRequired = true
+			}
+		}
+	}
+	return resume
+}
+
+// markEventReplayDetached 在用户最后一个连接断开时调用，开始断线期间的事件缓存窗口。
+func markEventReplayDetached(userID string, channelID string) {
+	ring, ok := eventReplayRings.Load(userID)
+	if !ok || ring == nil {
+		return
+	}
+	ring.mu.Lock()
+	ring.detachedChannel = channelID
+	ring.detachedUntil = time.Now().Add(eventReplayDetachedTTL).UnixMilli()
+	ring.mu.Unlock()
+	if channelID == "" {
+		return
+	}
+	users, _ := eventReplayDetachedByChannel.LoadOrStore(channelID, &utils.SyncSet[string]{})
+	users.Add(userID)
+}
+
+// sweepEventReplayRings 清理断线超时且无连接的用户缓冲。
+func sweepEventReplayRings(userId2ConnInfo *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]], now time.Time) int {
+	removed := 0
+	nowMs := now.UnixMilli()
+	eventReplayRings.Range(func(userID string, ring *userEventReplayRing) bool {
+		if ring == nil {
+			eventReplayRings.Delete(userID)
+			return true
+		}
+		if userId2ConnInfo != nil {
+			if connMap, ok := userId2ConnInfo.Load(userID); ok && connMap != nil && connMap.Len() > 0 {
+				return true
+			}
+		}
+		ring.mu.Lock()
+		expired := ring.detachedUntil <= nowMs
+		detachedChannel := ring.detachedChannel
+		ring.mu.Unlock()
+		if !expired {
+			return true
+		}
+		eventReplayRings.Delete(userID)
+		if detachedChannel != "" {
+			if users, ok := eventReplayDetachedByChannel.Load(detachedChannel); ok && users != nil {
+				users.Delete(userID)
+			}
+		}
+		removed++
+		return true
+	})
+	return removed
+}
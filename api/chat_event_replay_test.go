@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+func TestEventReplayRingReplaysMissedEventsAndSignalsResync(t *testing.T) {
+	userID := "replay-user-" + utils.NewIDWithLength(6)
+	defer eventReplayRings.Delete(userID)
+
+	first := resumeEventReplay(userID, "", 0, "")
+	if first.ResyncRequired || first.Sequence != 0 || first.Epoch == "" {
+		t.Fatalf("unexpected fresh resume: %+v", first)
+	}
+	ring, _ := eventReplayRings.Load(userID)
+	for i := 0; i < 3; i++ {
+		if _, err := ring.append(&protocol.Event{Type: protocol.EventMessageCreated}); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	resume := resumeEventReplay(userID, first.Epoch, 1, "")
+	if resume.ResyncRequired || resume.Sequence != 3 || len(resume.Events) != 2 {
+		t.Fatalf("expected two replayed events, got %+v", resume)
+	}
+	var got struct {
+		Op  protocol.Opcode `json:"op"`
+		Seq int64           `json:"seq"`
+	}
+	if err := json.Unmarshal(resume.Events[0], &got); err != nil || got.Op != protocol.OpEvent || got.Seq != 2 {
+		t.Fatalf("unexpected replay payload: %s", resume.Events[0])
+	}
+
+	if resume := resumeEventReplay(userID, "stale-epoch", 1, ""); !resume.ResyncRequired {
+		t.Fatalf("expected epoch mismatch to require resync")
+	}
+
+	for i := 0; i < eventReplayBufferSize+5; i++ {
+		_, _ = ring.append(&protocol.Event{Type: protocol.EventMessageUpdated})
+	}
+	if resume := resumeEventReplay(userID, first.Epoch, 3, ""); !resume.ResyncRequired {
+		t.Fatalf("expected overflowed gap to require resync")
+	}
+	latest := int64(3 + eventReplayBufferSize + 5)
+	if resume := resumeEventReplay(userID, first.Epoch, latest-eventReplayBufferSize, ""); resume.ResyncRequired || len(resume.Events) != eventReplayBufferSize {
+		t.Fatalf("expected full buffer replay, got resync=%v count=%d", resume.ResyncRequired, len(resume.Events))
+	}
+}
+
+func TestDeliverEventInChannelRecordsForDetachedUser(t *testing.T) {
+	userID := "detached-user-" + utils.NewIDWithLength(6)
+	channelID := "channel-replay-" + utils.NewIDWithLength(6)
+	defer eventReplayRings.Delete(userID)
+	defer eventReplayDetachedByChannel.Delete(channelID)
+
+	fresh := resumeEventReplay(userID, "", 0, "")
+	markEventReplayDetached(userID, channelID)
+
+	ctx := &ChatContext{
+		ChannelUsersMap: &utils.SyncMap[string, *utils.SyncSet[string]]{},
+		UserId2ConnInfo: &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{},
+	}
+	ctx.deliverEventInChannel(channelID, &protocol.Event{
+		Type:    protocol.EventMessageCreated,
+		Message: &protocol.Message{ID: "msg-missed"},
+	})
+	ctx.deliverEventInChannel(channelID, &protocol.Event{Type: protocol.EventTypingPreview})
+	ctx.deliverEventInChannel("other-channel", &protocol.Event{Type: protocol.EventMessageCreated})
+
+	resume := resumeEventReplay(userID, fresh.Epoch, 0, "")
+	if resume.ResyncRequired || len(resume.Events) != 1 {
+		t.Fatalf("expected one replayed event, got %+v", resume)
+	}
+	var got struct {
+		Seq     int64             `json:"seq"`
+		Message *protocol.Message `json:"message"`
+	}
+	if err := json.Unmarshal(resume.Events[0], &got); err != nil || got.Seq != 1 || got.Message == nil || got.Message.ID != "msg-missed" {
+		t.Fatalf("unexpected replayed event: %s", resume.Events[0])
+	}
+}
+
+func TestDeliverEventSharesSequenceAcrossUserConnections(t *testing.T) {
+	userID := "seq-user-" + utils.NewIDWithLength(6)
+	defer eventReplayRings.Delete(userID)
+	resumeEventReplay(userID, "", 0, "")
+
+	conn, client, cleanup := newReadableChatTestConn(t)
+	defer cleanup()
+	connMap := &utils.SyncMap[*WsSyncConn, *ConnInfo]{}
+	connMap.Store(conn, &ConnInfo{
+		Conn: conn,
+		User: &model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: userID}},
+	})
+	ctx := &ChatContext{
+		ChannelUsersMap: &utils.SyncMap[string, *utils.SyncSet[string]]{},
+		UserId2ConnInfo: &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{},
+	}
+	ctx.UserId2ConnInfo.Store(userID, connMap)
+	ctx.deliverEvent(&protocol.Event{Type: protocol.EventMessageCreated})
+
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, body, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	var got struct {
+		Seq int64 `json:"seq"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil || got.Seq != 1 {
+		t.Fatalf("expected sequenced event, got %s", body)
+	}
+	if removed := sweepEventReplayRings(ctx.UserId2ConnInfo, time.Now()); removed != 0 {
+		t.Fatalf("expected connected user's ring to be retained, removed=%d", removed)
+	}
+}
+
+func TestEventReplayFiltersByResumingChannel(t *testing.T) {
+	userID := "replay-channel-user-" + utils.NewIDWithLength(6)
+	defer eventReplayRings.Delete(userID)
+
+	first := resumeEventReplay(userID, "", 0, "")
+	ring, _ := eventReplayRings.Load(userID)
+	_, _ = ring.append(&protocol.Event{Type: protocol.EventMessageCreated, Channel: &protocol.Channel{ID: "ch-a"}})
+	_, _ = ring.append(&protocol.Event{Type: protocol.EventMessageCreated, Channel: &protocol.Channel{ID: "ch-b"}})
+	_, _ = ring.append(&protocol.Event{Type: protocol.EventLobbyAnnouncementUpdated})
+
+	resume := resumeEventReplay(userID, first.Epoch, 0, "ch-a")
+	if resume.ResyncRequired || resume.Sequence != 3 || len(resume.Events) != 2 {
+		t.Fatalf("expected ch-a and channel-less events only, got %+v", resume)
+	}
+	if seq := eventPayloadSeq(resume.Events[1]); seq != 3 {
+		t.Fatalf("expected channel-less event to be replayed, got seq %d", seq)
+	}
+}
+
+func TestHeldWritesDropReplayedSequences(t *testing.T) {
+	conn, client, cleanup := newReadableChatTestConn(t)
+	defer cleanup()
+
+	conn.holdWrites()
+	_ = conn.WriteJSON(json.RawMessage(`{"op":0,"type":"message-created","seq":2}`))
+	_ = conn.WriteJSON(replayEventPayload{Event: protocol.Event{Type: protocol.EventMessageCreated}, Op: protocol.OpEvent})
+	_ = conn.WriteJSON(json.RawMessage(`{"op":0,"type":"message-created","seq":3}`))
+	if err := conn.WriteJSONWithTimeout(map[string]any{"op": protocol.OpReady}, wsWriteTimeout); err != nil {
+		t.Fatalf("ready write failed: %v", err)
+	}
+	conn.releaseHeldWrites(2)
+	_ = conn.WriteJSON(json.RawMessage(`{"op":0,"type":"message-created","seq":4}`))
+
+	var seqs []int64
+	for i := 0; i < 4; i++ {
+		_ = client.SetReadDeadline(time.Now().Add(time.Second))
+		_, body, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %d failed: %v", i, err)
+		}
+		var got struct {
+			Op  protocol.Opcode `json:"op"`
+			Seq int64           `json:"seq"`
+		}
+		_ = json.Unmarshal(body, &got)
+		if i == 0 && got.Op != protocol.OpReady {
+			t.Fatalf("ready should be written before held events, got %s", body)
+		}
+		seqs = append(seqs, got.Seq)
+	}
+	if seqs[1] != 0 || seqs[2] != 3 || seqs[3] != 4 {
+		t.Fatalf("unexpected delivery order: %v", seqs)
+	}
+}
@@ -31,6 +31,8 @@ type chatExportRequest struct {
 	IncludeArchived     *bool             `json:"include_archived"`
 	IncludeImages       *bool             `json:"include_images"`
 	IncludeDiceCommand  *bool             `json:"include_dice_commands"`
+	IncludeThreads      *bool             `json:"include_thread_replies"`
+	IncludeRoundTrip    *bool             `json:"include_round_trip"`
 	WithoutTimestamp    *bool             `json:"without_timestamp"`
 	MergeMessages       *bool             `json:"merge_messages"`
 	Users               []string          `json:"users"`
@@ -213,6 +215,15 @@ func execChatExportCreate(userID string, req *chatExportRequest) (*chatExportRes
 	if req.IncludeDiceCommand != nil {
 		includeDiceCommand = *req.IncludeDiceCommand
 	}
+	includeThreadReplies := false
+	if req.IncludeThreads != nil {
+		includeThreadReplies = *req.IncludeThreads
+	}
+	// 可回导数据块会内嵌附件、体积较大，仅 JSON 导出且显式开启时附带
+	includeRoundTrip := false
+	if req.IncludeRoundTrip != nil && strings.EqualFold(format, "json") {
+		includeRoundTrip = *req.IncludeRoundTrip
+	}
 	mergeMessages := true
 	if req.MergeMessages != nil {
 		mergeMessages = *req.MergeMessages
@@ -234,6 +245,19 @@ func execChatExportCreate(userID string, req *chatExportRequest) (*chatExportRes
 		if err != nil {
 			return nil, err
 		}
+	} else if strings.EqualFold(format, "pdf") {
+		// PDF 发言人配色沿用频道导出配色：请求携带时以请求为准，否则读取已保存的配置
+		var err error
+		if len(req.TextColorizeMap) > 0 || len(req.TextColorizeNameMap) > 0 {
+			if textColorizeMap, err = normalizeExportColorMap(req.TextColorizeMap); err != nil {
+				return nil, err
+			}
+			if textColorizeNameMap, err = normalizeExportNameMap(req.TextColorizeNameMap); err != nil {
+				return nil, err
+			}
+		} else if textColorizeMap, textColorizeNameMap, err = loadExportColorMaps(userID, channelID); err != nil {
+			return nil, err
+		}
 	}
 
 	displaySettings := normalizeDisplaySettings(req.DisplaySettings)
@@ -249,6 +273,8 @@ func execChatExportCreate(userID string, req *chatExportRequest) (*chatExportRes
 		IncludeArchived:           includeArchived,
 		IncludeImages:             includeImages,
 		IncludeDiceCommand:        includeDiceCommand,
+		IncludeThreadReplies:      includeThreadReplies,
+		IncludeRoundTrip:          includeRoundTrip,
 		WithoutTimestamp:          withoutTimestamp,
 		MergeMessages:             mergeMessages,
 		TextColorizeBBCode:        textColorizeBBCode,
@@ -30,12 +30,55 @@ type ApiMsgPayload struct {
 type WsSyncConn struct {
 	*websocket.Conn
 	Mux sync.RWMutex
+
+	// 连接登记后到 Ready 与重放补发完成前，实时推送先暂存，避免插队或与补发重复
+	holdMu    sync.Mutex
+	holding   bool
+	heldQueue []interface{}
 }
 
 func (c *WsSyncConn) WriteJSON(v interface{}) error {
+	if c != nil {
+		c.holdMu.Lock()
+		if c.holding {
+			c.heldQueue = append(c.heldQueue, v)
+			c.holdMu.Unlock()
+			return nil
+		}
+		c.holdMu.Unlock()
+	}
 	return c.WriteJSONWithTimeout(v, wsWriteTimeout)
 }
 
+// holdWrites 开始暂存实时推送，须与 releaseHeldWrites 成对调用
+func (c *WsSyncConn) holdWrites() {
+	c.holdMu.Lock()
+	c.holding = true
+	c.holdMu.Unlock()
+}
+
+// releaseHeldWrites 按到达顺序放行暂存的推送，丢弃序号不大于 afterSeq 的事件（已补发或早于 Ready）。
+// 放行期间新到的推送继续排在队尾，直到队列清空才恢复直接写出。
+func (c *WsSyncConn) releaseHeldWrites(afterSeq int64) {
+	for {
+		c.holdMu.Lock()
+		queue := c.heldQueue
+		c.heldQueue = nil
+		if len(queue) == 0 {
+			c.holding = false
+			c.holdMu.Unlock()
+			return
+		}
+		c.holdMu.Unlock()
+		for _, v := range queue {
+			if seq := eventPayloadSeq(v); seq > 0 && seq <= afterSeq {
+				continue
+			}
+			_ = c.WriteJSONWithTimeout(v, wsWriteTimeout)
+		}
+	}
+}
+
 func (c *WsSyncConn) WriteJSONWithTimeout(v interface{}, timeout time.Duration) error {
 	if c == nil || c.Conn == nil {
 		return errors.New("websocket connection unavailable")
@@ -61,6 +104,7 @@ func (c *WsSyncConn) WriteJSONWithTimeout(v interface{}, timeout time.Duration)
 type ConnInfo struct {
 	User                    *model.UserModel
 	Conn                    *WsSyncConn
+	AccessTokenID           string // 建立连接时使用的登录会话，会话注销时据此断开
 	ClientAddr              string
 	LastPingTime            int64
 	LastAliveTime           int64
@@ -321,6 +365,8 @@ func websocketWorks(app *fiber.App, webUrl string) {
 	userId2ConnInfo := &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
 	channelUsersMapGlobal = channelUsersMap
 	userId2ConnInfoGlobal = userId2ConnInfo
+	// 多节点部署时，其他节点的广播经总线到达后投递给本节点连接。
+	subscribeBroadcastBus(channelUsersMap, userId2ConnInfo)
 
 	// 在线态兜底广播：事件驱动为主，周期性全量广播用于状态收敛。
 	go func() {
@@ -344,6 +390,11 @@ func websocketWorks(app *fiber.App, webUrl string) {
 		"message.list":               {},
 		"message.get":                {},
 		"message.context":            {},
+		"message.thread.list":        {},
+		"poll.votes.mine":            {},
+		"dice.fairness.windows":      {},
+		"dice.fairness.verify":       {},
+		"initiative.get":             {},
 	}
 
 	normalizeRemoteAddr := func(addr string) string {
@@ -403,6 +454,17 @@ func websocketWorks(app *fiber.App, webUrl string) {
 					observerSlug = strings.TrimSpace(slugValue)
 				}
 			}
+			var resumeSequence int64
+			switch v := m["sequence"].(type) {
+			case float64:
+				resumeSequence = int64(v)
+			case int:
+				resumeSequence = int64(v)
+			case int64:
+				resumeSequence = v
+			}
+			resumeEpoch, _ := m["epoch"].(string)
+			resumeChannelID, _ := m["channelId"].(string)
 			if observer && observerSlug != "" {
 				world, _, err := service.ResolveWorldObserverLink(observerSlug)
 				if err != nil || world == nil || strings.TrimSpace(world.ID) == "" {
@@ -521,8 +583,14 @@ func websocketWorks(app *fiber.App, webUrl string) {
 					}
 				}
 
+				accessTokenID := ""
+				if user.AccessToken != nil {
+					accessTokenID = user.AccessToken.ID
+					model.AccessTokenTouch(user.AccessToken, clientAddr)
+				}
 				curConnInfo = &ConnInfo{
 					Conn:            c,
+					AccessTokenID:   accessTokenID,
 					ClientAddr:      clientAddr,
 					LastPingTime:    time.Now().UnixMilli(),
 					LastAliveTime:   time.Now().UnixMilli(),
@@ -534,6 +602,8 @@ func websocketWorks(app *fiber.App, webUrl string) {
 					TypingIcMode:    "ic",
 					Focused:         true,
 				}
+				// 登记后即可收到实时推送，先暂存到 Ready 与补发写完为止
+				c.holdWrites()
 				m.Store(c, curConnInfo)
 
 				curUser = user
@@ -541,12 +611,31 @@ func websocketWorks(app *fiber.App, webUrl string) {
 					collector.RecordConnectionOpened(user.ID)
 					collector.RecordUserHeartbeat(user.ID)
 				}
-				_ = c.WriteJSON(protocol.GatewayPayloadStructure{
-					Op: protocol.OpReady,
-					Body: map[string]any{
-						"user": curUser,
-					},
-				})
+				readyBody := map[string]any{
+					"user": curUser,
+				}
+				var replayEvents []json.RawMessage
+				var replayedSeq int64
+				if !user.IsBot {
+					// 断线重连：客户端携带上次收到的事件序号、epoch 与所在频道，缺失事件在 Ready 之后补发
+					resume := resumeEventReplay(user.ID, resumeEpoch, resumeSequence, resumeChannelID)
+					replayEvents = resume.Events
+					replayedSeq = resume.Sequence
+					readyBody["sequence"] = resume.Sequence
+					readyBody["epoch"] = resume.Epoch
+					readyBody["resyncRequired"] = resume.ResyncRequired
+					readyBody["replayCount"] = len(resume.Events)
+				}
+				_ = c.WriteJSONWithTimeout(protocol.GatewayPayloadStructure{
+					Op:   protocol.OpReady,
+					Body: readyBody,
+				}, wsWriteTimeout)
+				for _, payload := range replayEvents {
+					if err := c.WriteJSONWithTimeout(payload, wsWriteTimeout); err != nil {
+						break
+					}
+				}
+				c.releaseHeldWrites(replayedSeq)
 				return
 			}
 		}
@@ -634,6 +723,9 @@ func websocketWorks(app *fiber.App, webUrl string) {
 			if cleanedCount > 0 {
 				log.Printf("[WS] 健康检查完成，清理了 %d 个僵尸连接", cleanedCount)
 			}
+			if removed := sweepEventReplayRings(userId2ConnInfo, time.Now()); removed > 0 {
+				log.Printf("[WS] 健康检查：回收 %d 个过期的事件重放缓冲", removed)
+			}
 		}
 	}()
 
@@ -655,7 +747,7 @@ func websocketWorks(app *fiber.App, webUrl string) {
 			curUser     *model.UserModel
 			curConnInfo *ConnInfo
 		)
-		c := &WsSyncConn{rawConn, sync.RWMutex{}}
+		c := &WsSyncConn{Conn: rawConn}
 		clientAddr := normalizeRemoteAddr(rawConn.RemoteAddr().String())
 		preAuthReleased := false
 		preAuthGlobalCount, preAuthAddrCount := addPreAuthConnection(clientAddr)
@@ -1022,6 +1114,12 @@ func websocketWorks(app *fiber.App, webUrl string) {
 					case "message.revoked.draft":
 						apiWrap(ctx, msg, apiMessageRevokedDraft)
 						solved = true
+					case "message.draft.get":
+						apiWrap(ctx, msg, apiMessageDraftGet)
+						solved = true
+					case "message.draft.save":
+						apiWrap(ctx, msg, apiMessageDraftSave)
+						solved = true
 					case "message.context":
 						apiWrap(ctx, msg, apiMessageContext)
 						solved = true
@@ -1046,6 +1144,48 @@ func websocketWorks(app *fiber.App, webUrl string) {
 					case "message.edit.history":
 						apiWrap(ctx, msg, apiMessageEditHistory)
 						solved = true
+					case "dice.fairness.windows":
+						apiWrap(ctx, msg, apiDiceFairnessWindows)
+						solved = true
+					case "dice.fairness.verify":
+						apiWrap(ctx, msg, apiDiceFairnessVerify)
+						solved = true
+					case "initiative.get":
+						apiWrap(ctx, msg, apiInitiativeGet)
+						solved = true
+					case "initiative.combatant.add":
+						apiWrap(ctx, msg, apiInitiativeCombatantAdd)
+						solved = true
+					case "initiative.combatant.update":
+						apiWrap(ctx, msg, apiInitiativeCombatantUpdate)
+						solved = true
+					case "initiative.combatant.remove":
+						apiWrap(ctx, msg, apiInitiativeCombatantRemove)
+						solved = true
+					case "initiative.roll":
+						apiWrap(ctx, msg, apiInitiativeRoll)
+						solved = true
+					case "initiative.turn":
+						apiWrap(ctx, msg, apiInitiativeTurn)
+						solved = true
+					case "initiative.condition.set":
+						apiWrap(ctx, msg, apiInitiativeConditionSet)
+						solved = true
+					case "message.read.list":
+						apiWrap(ctx, msg, apiMessageReadList)
+						solved = true
+					case "message.thread.list":
+						apiWrap(ctx, msg, apiMessageThreadList)
+						solved = true
+					case "message.thread.read":
+						apiWrap(ctx, msg, apiMessageThreadRead)
+						solved = true
+					case "message.thread.unread":
+						apiWrap(ctx, msg, apiMessageThreadUnread)
+						solved = true
+					case "poll.votes.mine":
+						apiWrap(ctx, msg, apiPollVotesMine)
+						solved = true
 					case "message.typing":
 						apiWrap(ctx, msg, apiMessageTyping)
 						solved = true
@@ -1223,5 +1363,11 @@ func websocketWorks(app *fiber.App, webUrl string) {
 				ctx.BroadcastChannelPresence(chId)
 			}
 		}
+		// 用户最后一个连接断开后，短时间内继续缓存其所在频道的事件，供重连时补发。
+		if curUser != nil && curConnInfo != nil && !curConnInfo.IsGuest && !curUser.IsBot {
+			if connMap, ok := userId2ConnInfo.Load(curUser.ID); !ok || connMap == nil || connMap.Len() == 0 {
+				markEventReplayDetached(curUser.ID, curConnInfo.ChannelId)
+			}
+		}
 	}))
 }
@@ -26,6 +26,12 @@ func sanitizeConfigForAdmin(cfg *utils.AppConfig) utils.AppConfig {
 	// log upload token
 	ret.LogUpload.Token = ""
 
+	// oidc client secret
+	ret.OIDC.ClientSecret = ""
+
+	// metrics scrape token
+	ret.MetricsExport.Token = ""
+
 	// s3 credentials
 	ret.Storage.S3.AccessKey = ""
 	ret.Storage.S3.SecretKey = ""
@@ -70,6 +76,12 @@ func mergeConfigForWrite(current *utils.AppConfig, incoming *utils.AppConfig) *u
 	if strings.TrimSpace(out.LogUpload.Token) == "" {
 		out.LogUpload.Token = current.LogUpload.Token
 	}
+	if strings.TrimSpace(out.OIDC.ClientSecret) == "" {
+		out.OIDC.ClientSecret = current.OIDC.ClientSecret
+	}
+	if strings.TrimSpace(out.MetricsExport.Token) == "" {
+		out.MetricsExport.Token = current.MetricsExport.Token
+	}
 	if strings.TrimSpace(out.Storage.S3.AccessKey) == "" {
 		out.Storage.S3.AccessKey = current.Storage.S3.AccessKey
 	}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"sealchat/model"
+	"sealchat/service"
+)
+
+// apiDiceFairnessWindows 列出频道最近的种子承诺，已结束的窗口附带公开的种子
+func apiDiceFairnessWindows(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	Limit     int    `json:"limit"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	items, err := service.DiceFairnessWindowList(channelID, data.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Items []*service.DiceFairnessWindowView `json:"items"`
+	}{Items: items}, nil
+}
+
+// apiDiceFairnessVerify 用公开数据复算消息中的掷骰
+func apiDiceFairnessVerify(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	messageID := strings.TrimSpace(data.MessageID)
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id 不能为空")
+	}
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+
+	var item model.MessageModel
+	canReadAllWhispers := canUserReadAllWhispersInChannel(ctx.User.ID, channelID)
+	q := model.GetDB().Where("channel_id = ? AND id = ?", channelID, messageID)
+	q = q.Where("is_deleted = ?", false)
+	q = applyWhisperVisibilityFilterWithReadAll(q, ctx.User.ID, canReadAllWhispers)
+	q.Limit(1).Find(&item)
+	if item.ID == "" {
+		return nil, fmt.Errorf("消息不存在")
+	}
+
+	results, err := service.DiceFairnessVerifyMessage(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		MessageID string                              `json:"message_id"`
+		Rolls     []*service.DiceFairnessVerifyResult `json:"rolls"`
+	}{MessageID: item.ID, Rolls: results}, nil
+}
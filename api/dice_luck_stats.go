@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/service"
+)
+
+// parseDiceLuckQuery 解析骰运统计参数。channelIds 为逗号分隔的附加频道，需逐个校验访问权限
+func parseDiceLuckQuery(c *fiber.Ctx, userID string) (service.DiceLuckQuery, int, error) {
+	var query service.DiceLuckQuery
+	channelID := strings.TrimSpace(c.Params("channelId"))
+	if channelID == "" {
+		return query, http.StatusBadRequest, fmt.Errorf("缺少频道ID")
+	}
+	channelIDs := []string{channelID}
+	seen := map[string]struct{}{channelID: {}}
+	for _, id := range splitIDs(c.Query("channelIds")) {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		channelIDs = append(channelIDs, id)
+	}
+	for _, id := range channelIDs {
+		if _, err := resolveChannelAccess(userID, id); err != nil {
+			switch err {
+			case fiber.ErrForbidden:
+				return query, http.StatusForbidden, fmt.Errorf("没有访问该频道的权限")
+			case fiber.ErrNotFound:
+				return query, http.StatusNotFound, fmt.Errorf("频道不存在")
+			}
+			return query, http.StatusInternalServerError, err
+		}
+	}
+	query.ChannelIDs = channelIDs
+
+	groupBy, err := service.NormalizeDiceLuckGroupBy(c.Query("groupBy"))
+	if err != nil {
+		return query, http.StatusBadRequest, err
+	}
+	query.GroupBy = groupBy
+	query.UserID = strings.TrimSpace(c.Query("userId"))
+	query.IdentityID = strings.TrimSpace(c.Query("identityId"))
+	if s := strings.TrimSpace(c.Query("start")); s != "" {
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			t := time.UnixMilli(ms)
+			query.StartTime = &t
+		}
+	}
+	if s := strings.TrimSpace(c.Query("end")); s != "" {
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			t := time.UnixMilli(ms)
+			query.EndTime = &t
+		}
+	}
+	return query, http.StatusOK, nil
+}
+
+// ChannelDiceLuckStats 频道骰运统计，format 为 json（默认）、csv 或 markdown
+func ChannelDiceLuckStats(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	query, status, err := parseDiceLuckQuery(c, user.ID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+	}
+	report, err := service.DiceLuckCompute(query)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	filename := fmt.Sprintf("dice-stats-%s-%s", query.ChannelIDs[0], time.Now().Format("20060102-150405"))
+	switch strings.ToLower(strings.TrimSpace(c.Query("format"))) {
+	case "csv":
+		data, err := service.DiceLuckReportCSV(report)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+		c.Set("Content-Type", "text/csv; charset=utf-8")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+		return c.Send(data)
+	case "markdown", "md":
+		c.Set("Content-Type", "text/markdown; charset=utf-8")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.md\"", filename))
+		return c.SendString(service.DiceLuckReportMarkdown(report))
+	}
+	return c.JSON(report)
+}
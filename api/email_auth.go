@@ -438,7 +438,7 @@ func EmailAuthSignupWithCode(c *fiber.Ctx) error {
 		}
 	}
 
-	token, err := model.UserGenerateAccessToken(user.ID)
+	token, err := issueUserAccessToken(c, user.ID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "生成令牌失败"})
 	}
@@ -308,6 +308,37 @@ func buildExportColorMapFromProfiles(profiles map[string]exportColorProfileEntry
 	return colors
 }
 
+func buildExportNameMapFromProfiles(profiles map[string]exportColorProfileEntry) map[string]string {
+	if len(profiles) == 0 {
+		return map[string]string{}
+	}
+	names := make(map[string]string, len(profiles))
+	for key, entry := range profiles {
+		if entry.Name == "" {
+			continue
+		}
+		names[key] = entry.Name
+	}
+	return names
+}
+
+// loadExportColorMaps 读取用户在频道保存的导出配色，并按同名角色复用其他频道的配置。
+func loadExportColorMaps(userID, channelID string) (map[string]string, map[string]string, error) {
+	record, err := model.ExportColorProfileGet(userID, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	profiles := map[string]exportColorProfileEntry{}
+	if record != nil {
+		profiles = parseExportColorProfileJSON(record.ColorsJSON)
+	}
+	resolved, err := resolveExportColorProfiles(userID, channelID, profiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buildExportColorMapFromProfiles(resolved), buildExportNameMapFromProfiles(resolved), nil
+}
+
 func normalizeExportProfileKey(rawKey string) string {
 	key := strings.TrimSpace(rawKey)
 	if !strings.HasPrefix(key, "identity:") {
@@ -13,6 +13,7 @@ import (
 	"sealchat/pm"
 	"sealchat/protocol"
 	"sealchat/service"
+	"sealchat/service/broadcast"
 	"sealchat/utils"
 )
 
@@ -139,22 +140,23 @@ func broadcastExternalGlossaryLibraryEvent(payload *externalGlossaryLibraryEvent
 		Type: protocol.EventExternalGlossariesUpdated,
 		Argv: &protocol.Argv{Options: options},
 	}
-	if userId2ConnInfoGlobal == nil {
-		return
-	}
-	userId2ConnInfoGlobal.Range(func(_ string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
-		conns.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
-			_ = conn.WriteJSON(struct {
-				protocol.Event
-				Op protocol.Opcode `json:"op"`
-			}{
-				Event: *event,
-				Op:    protocol.OpEvent,
+	data := struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		Event: *event,
+		Op:    protocol.OpEvent,
+	}
+	if userId2ConnInfoGlobal != nil {
+		userId2ConnInfoGlobal.Range(func(_ string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+			conns.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
+				_ = conn.WriteJSON(data)
+				return true
 			})
 			return true
 		})
-		return true
-	})
+	}
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindAll}, data)
 }
 
 func broadcastExternalGlossaryLibraryChanged(libraryIDs []string, operation, requestID string, forceReload bool) {
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+// broadcastInitiativeUpdated 广播先攻表的完整快照，客户端直接替换本地状态
+func broadcastInitiativeUpdated(ctx *ChatContext, action string, tracker *protocol.InitiativeTracker) {
+	if tracker == nil {
+		return
+	}
+	ev := &protocol.Event{
+		Type:    protocol.EventInitiativeUpdated,
+		Channel: &protocol.Channel{ID: tracker.ChannelID},
+		Initiative: &protocol.InitiativeEventPayload{
+			ChannelID: tracker.ChannelID,
+			Action:    action,
+			Tracker:   tracker,
+		},
+	}
+	if ctx.User != nil {
+		ev.User = ctx.User.ToProtocolType()
+	}
+	ctx.BroadcastEventInChannel(tracker.ChannelID, ev)
+	ctx.BroadcastEventInChannelForBot(tracker.ChannelID, ev)
+}
+
+func initiativeResult(ctx *ChatContext, action string, tracker *protocol.InitiativeTracker, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	broadcastInitiativeUpdated(ctx, action, tracker)
+	return tracker, nil
+}
+
+// apiInitiativeGet 获取频道先攻表，能阅读频道即可查看
+func apiInitiativeGet(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	tracker, err := service.InitiativeGet(channelID)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Tracker   *protocol.InitiativeTracker `json:"tracker"`
+		CanManage bool                        `json:"canManage"`
+	}{
+		Tracker:   tracker,
+		CanManage: !ctx.IsReadOnly() && service.InitiativeCanManage(channelID, ctx.User.ID),
+	}, nil
+}
+
+func apiInitiativeCombatantAdd(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	service.InitiativeCombatantInput
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	tracker, err := service.InitiativeCombatantAdd(channelID, ctx.User.ID, data.InitiativeCombatantInput)
+	return initiativeResult(ctx, "combatant.add", tracker, err)
+}
+
+func apiInitiativeCombatantUpdate(ctx *ChatContext, data *struct {
+	ChannelID   string `json:"channel_id"`
+	CombatantID string `json:"combatant_id"`
+	service.InitiativeCombatantInput
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	tracker, err := service.InitiativeCombatantUpdate(channelID, ctx.User.ID, data.CombatantID, data.InitiativeCombatantInput)
+	return initiativeResult(ctx, "combatant.update", tracker, err)
+}
+
+func apiInitiativeCombatantRemove(ctx *ChatContext, data *struct {
+	ChannelID   string `json:"channel_id"`
+	CombatantID string `json:"combatant_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	tracker, err := service.InitiativeCombatantRemove(channelID, ctx.User.ID, data.CombatantID)
+	return initiativeResult(ctx, "combatant.remove", tracker, err)
+}
+
+// apiInitiativeRoll 掷先攻，combatant_ids 为空时按身份决定掷骰对象，formula 可覆盖各自的公式
+func apiInitiativeRoll(ctx *ChatContext, data *struct {
+	ChannelID    string   `json:"channel_id"`
+	CombatantIDs []string `json:"combatant_ids"`
+	Formula      string   `json:"formula"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	tracker, err := service.InitiativeRoll(channelID, ctx.User.ID, data.CombatantIDs, data.Formula)
+	return initiativeResult(ctx, "roll", tracker, err)
+}
+
+// apiInitiativeTurn 回合操作：start / next / prev / end / clear
+func apiInitiativeTurn(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	Action    string `json:"action"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	action := strings.TrimSpace(data.Action)
+	if action == "" {
+		return nil, fmt.Errorf("action 不能为空")
+	}
+	tracker, err := service.InitiativeTurn(channelID, ctx.User.ID, action)
+	return initiativeResult(ctx, "turn."+action, tracker, err)
+}
+
+// apiInitiativeConditionSet 设置参战单位的状态，remove 为真时移除同名状态
+func apiInitiativeConditionSet(ctx *ChatContext, data *struct {
+	ChannelID   string `json:"channel_id"`
+	CombatantID string `json:"combatant_id"`
+	Name        string `json:"name"`
+	Rounds      int    `json:"rounds"`
+	Remove      bool   `json:"remove"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	tracker, err := service.InitiativeConditionSet(channelID, ctx.User.ID, data.CombatantID, data.Name, data.Rounds, data.Remove)
+	return initiativeResult(ctx, "condition.set", tracker, err)
+}
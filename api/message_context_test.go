@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestAPIMessageContextStaysOnTargetTimeline(t *testing.T) {
+	initMessageUpdateWhisperTestDB(t)
+
+	alice := createMessageUpdateWhisperTestUser(t, "ctx-alice-"+utils.NewIDWithLength(10))
+	bob := createMessageUpdateWhisperTestUser(t, "ctx-bob-"+utils.NewIDWithLength(10))
+	// 私聊频道 ID 即好友关系 ID，长度不少于 30 位时按好友关系判断权限
+	if err := model.GetDB().Create(&model.FriendModel{UserID1: alice.ID, UserID2: bob.ID, IsFriend: true}).Error; err != nil {
+		t.Fatalf("create friend relation failed: %v", err)
+	}
+	channelID := model.FriendRelationGet(alice.ID, bob.ID).ID
+
+	base := time.Now().Add(-time.Hour)
+	create := func(id, threadID string, offset int) {
+		msg := &model.MessageModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id, CreatedAt: base.Add(time.Duration(offset) * time.Second)},
+			ChannelID:         channelID,
+			UserID:            alice.ID,
+			Content:           id,
+			ICMode:            "ic",
+			ThreadID:          threadID,
+			DisplayOrder:      float64(offset),
+		}
+		if err := model.GetDB().Create(msg).Error; err != nil {
+			t.Fatalf("create message %s failed: %v", id, err)
+		}
+	}
+	create("m1", "", 1)
+	create("reply1", "m1", 2)
+	create("m2", "", 3)
+	create("reply2", "m1", 4)
+	create("m3", "", 5)
+
+	ctx := &ChatContext{
+		User:            alice,
+		ChannelUsersMap: &utils.SyncMap[string, *utils.SyncSet[string]]{},
+		UserId2ConnInfo: &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{},
+	}
+	loadIDs := func(messageID string) string {
+		t.Helper()
+		resp, err := apiMessageContext(ctx, &struct {
+			ChannelID       string `json:"channel_id"`
+			MessageID       string `json:"message_id"`
+			Before          int    `json:"before"`
+			After           int    `json:"after"`
+			IncludeArchived *bool  `json:"include_archived"`
+			IncludeOOC      *bool  `json:"include_ooc"`
+			ICOnly          bool   `json:"ic_only"`
+		}{ChannelID: channelID, MessageID: messageID, Before: 5, After: 5})
+		if err != nil {
+			t.Fatalf("apiMessageContext failed: %v", err)
+		}
+		raw, _ := json.Marshal(resp)
+		var payload struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			t.Fatalf("decode response failed: %v", err)
+		}
+		ids := make([]string, 0, len(payload.Data))
+		for _, item := range payload.Data {
+			ids = append(ids, item.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if got := loadIDs("m2"); got != "m1,m2,m3" {
+		t.Fatalf("main timeline context should skip thread replies, got %s", got)
+	}
+	if got := loadIDs("reply2"); got != "reply1,reply2" {
+		t.Fatalf("thread reply context should stay within the thread, got %s", got)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/service"
+)
+
+// checkMessageDraftChannelAccess 草稿只对可在频道发言的正式用户开放
+func checkMessageDraftChannelAccess(ctx *ChatContext, channelID string) error {
+	if channelID == "" {
+		return fmt.Errorf("channel_id 不能为空")
+	}
+	if ctx.IsReadOnly() || ctx.User == nil {
+		return fmt.Errorf("当前模式不支持草稿")
+	}
+	if len(channelID) < 30 {
+		if !pm.CanWithChannelRole(ctx.User.ID, channelID, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
+			return fmt.Errorf("无权限访问该频道")
+		}
+		return nil
+	}
+	fr, _ := model.FriendRelationGetByID(channelID)
+	if fr.ID == "" {
+		return fmt.Errorf("频道不存在")
+	}
+	return nil
+}
+
+// apiMessageDraftGet 读取当前用户在频道的云端草稿
+func apiMessageDraftGet(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkMessageDraftChannelAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	item, err := service.MessageDraftLoad(ctx.User.ID, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Draft *model.MessageDraftModel `json:"draft"`
+	}{Draft: item}, nil
+}
+
+// apiMessageDraftSave 保存草稿，客户端应做防抖；内容为空表示清空。
+// 若服务端已有更新的版本，applied 为 false 并返回该版本供客户端处理冲突
+func apiMessageDraftSave(ctx *ChatContext, data *service.MessageDraftInput) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkMessageDraftChannelAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	item, applied, err := service.MessageDraftSave(ctx.User.ID, data)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Applied bool                     `json:"applied"`
+		Draft   *model.MessageDraftModel `json:"draft"`
+	}{Applied: applied, Draft: item}, nil
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/protocol"
+)
+
+// loadMessageThreadRoot 校验并加载话题根消息：必须属于同一频道、未被删除、本身不是话题回复且不是悄悄话
+func loadMessageThreadRoot(channelID, rootID string) (*model.MessageModel, error) {
+	var root model.MessageModel
+	if err := model.GetDB().Where("id = ? AND channel_id = ?", rootID, channelID).Limit(1).Find(&root).Error; err != nil {
+		return nil, err
+	}
+	if root.ID == "" || root.IsDeleted {
+		return nil, fmt.Errorf("话题根消息不存在")
+	}
+	if root.ThreadID != "" {
+		return nil, fmt.Errorf("不能在话题回复下再开启话题")
+	}
+	if root.IsWhisper {
+		return nil, fmt.Errorf("悄悄话不能开启话题")
+	}
+	return &root, nil
+}
+
+// checkChannelReadAccess 与 message.get 一致：能阅读频道即可，话题、投票、先攻等频道内功能共用
+func checkChannelReadAccess(ctx *ChatContext, channelID string) error {
+	if channelID == "" {
+		return fmt.Errorf("channel_id 不能为空")
+	}
+	if ctx.IsReadOnly() {
+		_, err := checkReadOnlyChannelAccess(ctx, channelID)
+		return err
+	}
+	if len(channelID) < 30 {
+		if !pm.CanWithChannelRole(ctx.User.ID, channelID, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
+			return fmt.Errorf("无权限查看频道消息")
+		}
+		return nil
+	}
+	fr, _ := model.FriendRelationGetByID(channelID)
+	if fr.ID == "" {
+		return fmt.Errorf("频道不存在")
+	}
+	return nil
+}
+
+// refreshMessageThreadAndBroadcast 重新统计话题并向频道广播话题活动
+func refreshMessageThreadAndBroadcast(ctx *ChatContext, channelData *protocol.Channel, rootID string, reply *model.MessageModel) {
+	stats, err := model.MessageThreadRefreshStats(rootID)
+	if err != nil {
+		log.Printf("刷新话题统计失败 root=%s err=%v", rootID, err)
+		return
+	}
+	payload := &protocol.MessageThreadEventPayload{
+		RootID:     rootID,
+		ChannelID:  channelData.ID,
+		ReplyCount: stats.ReplyCount,
+	}
+	if stats.LastReplyAt != nil {
+		payload.LastReplyAt = stats.LastReplyAt.UnixMilli()
+	}
+	if reply != nil {
+		payload.LastReplyID = reply.ID
+		payload.LastReplyUserID = reply.UserID
+	}
+	ev := &protocol.Event{
+		Type:    protocol.EventMessageThreadUpdated,
+		Channel: channelData,
+		Thread:  payload,
+	}
+	if ctx.User != nil {
+		ev.User = ctx.User.ToProtocolType()
+	}
+	ctx.BroadcastEventInChannel(channelData.ID, ev)
+}
+
+// apiMessageThreadList 列出频道内有回复的话题，按最近回复排序，并附带当前用户的未读数
+func apiMessageThreadList(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	Limit     int    `json:"limit"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+
+	limit := data.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var items []*model.MessageModel
+	err := model.GetDB().
+		Where("channel_id = ? AND thread_id = '' AND thread_reply_count > 0 AND is_deleted = ?", channelID, false).
+		Order("thread_last_reply_at desc").
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username, nickname, avatar, is_bot")
+		}).
+		Preload("Member", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, nickname, channel_id")
+		}).
+		Limit(limit).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	hydrateMessagesForBroadcast(items)
+
+	unread := map[string]int64{}
+	if !ctx.IsReadOnly() {
+		if unread, err = model.MessageThreadUnreadCounts(channelID, ctx.User.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &struct {
+		Data   []*model.MessageModel `json:"data"`
+		Unread map[string]int64      `json:"unread"`
+	}{
+		Data:   items,
+		Unread: unread,
+	}, nil
+}
+
+// apiMessageThreadRead 将话题标记为已读
+func apiMessageThreadRead(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	ThreadID  string `json:"thread_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	root, err := loadMessageThreadRoot(channelID, strings.TrimSpace(data.ThreadID))
+	if err != nil {
+		return nil, err
+	}
+	if ctx.IsReadOnly() {
+		return &struct {
+			Success bool `json:"success"`
+		}{Success: true}, nil
+	}
+	if err := model.MessageThreadReadSet(channelID, root.ID, ctx.User.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &struct {
+		Success bool `json:"success"`
+	}{Success: true}, nil
+}
+
+// apiMessageThreadUnread 获取当前用户在频道内已关注话题的未读数
+func apiMessageThreadUnread(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	if !ctx.IsReadOnly() {
+		var err error
+		if counts, err = model.MessageThreadUnreadCounts(channelID, ctx.User.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &struct {
+		Unread map[string]int64 `json:"unread"`
+	}{Unread: counts}, nil
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/service/metrics"
+	"sealchat/service/metrics/telemetry"
+	"sealchat/utils"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// metricsExportAuthorized 校验抓取令牌，未启用或未配置令牌时一律拒绝
+func metricsExportAuthorized(c *fiber.Ctx, cfg utils.MetricsExportConfig) bool {
+	expected := strings.TrimSpace(cfg.Token)
+	if !cfg.Enabled || expected == "" {
+		return false
+	}
+	provided := strings.TrimSpace(c.Query("token"))
+	if auth := strings.TrimSpace(c.Get(fiber.HeaderAuthorization)); auth != "" {
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			provided = strings.TrimSpace(auth[7:])
+		}
+	}
+	if provided == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
+}
+
+// MetricsExportHandler 以 OpenMetrics 文本格式输出服务指标
+func MetricsExportHandler(c *fiber.Ctx) error {
+	cfg := utils.GetConfig()
+	if cfg == nil || !cfg.MetricsExport.Enabled {
+		return c.SendStatus(http.StatusNotFound)
+	}
+	if !metricsExportAuthorized(c, cfg.MetricsExport) {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="metrics"`)
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	var buf bytes.Buffer
+	if err := telemetry.WriteOpenMetrics(&buf, buildMetricsGauges()); err != nil {
+		return fiber.NewError(http.StatusInternalServerError, err.Error())
+	}
+	c.Set(fiber.HeaderContentType, openMetricsContentType)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(buf.Bytes())
+}
+
+func buildMetricsGauges() []telemetry.Gauge {
+	collector := metrics.Get()
+	ws := getWsConnectionSnapshot()
+	gauges := []telemetry.Gauge{
+		{Name: "sealchat_ws_connections", Help: "Authenticated WebSocket connections.", Value: float64(collector.CurrentConnectionCount())},
+		{Name: "sealchat_ws_preauth_connections", Help: "WebSocket connections waiting for authentication.", Value: float64(ws.PreAuthConnections)},
+		{Name: "sealchat_ws_guest_connections", Help: "Guest WebSocket connections.", Value: float64(ws.GuestConnections)},
+		{Name: "sealchat_ws_observer_connections", Help: "Observer WebSocket connections.", Value: float64(ws.ObserverConnections)},
+		{Name: "sealchat_online_users", Help: "Users active within the online window.", Value: float64(collector.CurrentOnlineUsers())},
+	}
+	// 以下数值来自周期采样，与 /status 页面保持一致
+	if sample, err := latestSample(); err == nil && sample != nil {
+		gauges = append(gauges,
+			telemetry.Gauge{Name: "sealchat_messages_per_minute", Help: "Message throughput over the last sampling interval.", Value: float64(sample.MessagesPerMinute)},
+			telemetry.Gauge{Name: "sealchat_registered_users", Help: "Active registered users.", Value: float64(sample.RegisteredUsers)},
+			telemetry.Gauge{Name: "sealchat_worlds", Help: "Worlds.", Value: float64(sample.WorldCount)},
+			telemetry.Gauge{Name: "sealchat_channels", Help: "Channels.", Value: float64(sample.ChannelCount)},
+			telemetry.Gauge{Name: "sealchat_private_channels", Help: "Private channels.", Value: float64(sample.PrivateChannelCount)},
+			telemetry.Gauge{Name: "sealchat_messages_stored", Help: "Messages stored.", Value: float64(sample.MessageCount)},
+			telemetry.Gauge{Name: "sealchat_attachments", Help: "Attachments stored.", Value: float64(sample.AttachmentCount)},
+			telemetry.Gauge{Name: "sealchat_attachment_bytes", Help: "Attachment storage size in bytes.", Value: float64(sample.AttachmentBytes)},
+			telemetry.Gauge{Name: "sealchat_metrics_sample_timestamp_seconds", Help: "Unix time of the latest metrics sample.", Value: float64(sample.TimestampMs) / float64(time.Second/time.Millisecond)},
+		)
+	}
+	return gauges
+}
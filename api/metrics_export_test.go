@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/utils"
+)
+
+func TestMetricsExportAuthorized(t *testing.T) {
+	cases := []struct {
+		name   string
+		cfg    utils.MetricsExportConfig
+		target string
+		header string
+		want   int
+	}{
+		{name: "disabled", cfg: utils.MetricsExportConfig{Enabled: false, Token: "secret"}, header: "Bearer secret", want: http.StatusUnauthorized},
+		{name: "empty token", cfg: utils.MetricsExportConfig{Enabled: true}, want: http.StatusUnauthorized},
+		{name: "missing credential", cfg: utils.MetricsExportConfig{Enabled: true, Token: "secret"}, want: http.StatusUnauthorized},
+		{name: "wrong bearer", cfg: utils.MetricsExportConfig{Enabled: true, Token: "secret"}, header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "bearer", cfg: utils.MetricsExportConfig{Enabled: true, Token: "secret"}, header: "bearer secret", want: http.StatusOK},
+		{name: "query", cfg: utils.MetricsExportConfig{Enabled: true, Token: "secret"}, target: "?token=secret", want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/metrics", func(c *fiber.Ctx) error {
+				if !metricsExportAuthorized(c, tc.cfg) {
+					return c.SendStatus(http.StatusUnauthorized)
+				}
+				return c.SendStatus(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/metrics"+tc.target, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, resp.StatusCode)
+			}
+		})
+	}
+}
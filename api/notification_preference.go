@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/service"
+)
+
+// NotificationPreferencesList 获取当前用户的全部通知偏好
+func NotificationPreferencesList(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	items, err := model.NotificationPreferenceList(user.ID)
+	if err != nil {
+		return wrapError(c, err, "获取通知设置失败")
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// notificationScopeAccessible 非全局范围需要是世界成员或能读取频道
+func notificationScopeAccessible(userID, scopeType, scopeID string) bool {
+	switch scopeType {
+	case model.NotificationScopeWorld:
+		return service.IsWorldMember(scopeID, userID)
+	case model.NotificationScopeChannel:
+		return service.CanReadChannelByUserId(userID, scopeID)
+	default:
+		return true
+	}
+}
+
+// NotificationPreferencesUpsert 保存某范围的通知级别、免打扰与静默时段
+func NotificationPreferencesUpsert(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var body service.NotificationPreferenceInput
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "请求参数错误"})
+	}
+	item, err := service.BuildNotificationPreference(user.ID, &body)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if !notificationScopeAccessible(user.ID, item.ScopeType, item.ScopeID) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": "无权访问该范围"})
+	}
+	if err := model.NotificationPreferenceUpsert(item); err != nil {
+		return wrapError(c, err, "保存通知设置失败")
+	}
+	return c.JSON(fiber.Map{"item": item})
+}
+
+// NotificationPreferencesDelete 删除某范围的设置，恢复为继承上级
+func NotificationPreferencesDelete(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	scopeType, scopeID, err := service.NormalizeNotificationScope(c.Query("scopeType"), strings.TrimSpace(c.Query("scopeId")))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err := model.NotificationPreferenceDelete(user.ID, scopeType, scopeID); err != nil {
+		return wrapError(c, err, "重置通知设置失败")
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/service"
+)
+
+func oidcCallbackURL(c *fiber.Ctx) string {
+	webURL := ""
+	if appConfig != nil {
+		webURL = appConfig.WebUrl
+	}
+	return service.OIDCResolveRedirectURL(c.BaseURL() + joinWebPath(webURL, "api/v1/oidc/callback"))
+}
+
+// oidcFrontendURL 拼出前端 hash 路由地址
+func oidcFrontendURL(route string, query url.Values) string {
+	webURL := ""
+	if appConfig != nil {
+		webURL = appConfig.WebUrl
+	}
+	target := joinWebPath(webURL) + "#" + route
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return target
+}
+
+func oidcErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrOIDCDisabled),
+		errors.Is(err, service.ErrOIDCNotConfigured),
+		errors.Is(err, service.ErrOIDCSignupDisabled),
+		errors.Is(err, service.ErrOIDCIdentityInUse),
+		errors.Is(err, service.ErrOIDCTokenInvalid),
+		errors.Is(err, service.ErrOIDCUserUnavailable),
+		errors.Is(err, model.ErrOIDCStateInvalid),
+		errors.Is(err, model.ErrOIDCTicketInvalid):
+		return err.Error()
+	default:
+		return "单点登录失败，请稍后重试"
+	}
+}
+
+// OIDCLogin 跳转到身份提供方授权页
+func OIDCLogin(c *fiber.Ctx) error {
+	authURL, err := service.OIDCBuildAuthURL(oidcCallbackURL(c), c.Query("redirect"), "")
+	if err != nil {
+		log.Printf("[oidc] 发起登录失败: %v", err)
+		return c.Redirect(oidcFrontendURL("/user/signin", url.Values{"oidcError": {oidcErrorMessage(err)}}), http.StatusFound)
+	}
+	return c.Redirect(authURL, http.StatusFound)
+}
+
+// OIDCCallback 身份提供方回调，完成后携带一次性票据跳回前端
+func OIDCCallback(c *fiber.Ctx) error {
+	failRedirect := func(msg string) error {
+		return c.Redirect(oidcFrontendURL("/user/signin", url.Values{"oidcError": {msg}}), http.StatusFound)
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		desc := strings.TrimSpace(c.Query("error_description"))
+		if desc == "" {
+			desc = errCode
+		}
+		return failRedirect("身份提供方拒绝了登录请求: " + desc)
+	}
+
+	result, err := service.OIDCHandleCallback(c.UserContext(), c.Query("code"), c.Query("state"), oidcCallbackURL(c))
+	if err != nil {
+		log.Printf("[oidc] 回调处理失败: %v", err)
+		return failRedirect(oidcErrorMessage(err))
+	}
+
+	if result.Linked {
+		route := result.RedirectPath
+		if route == "" {
+			route = "/"
+		}
+		return c.Redirect(oidcFrontendURL(route, nil), http.StatusFound)
+	}
+
+	query := url.Values{}
+	if result.RedirectPath != "" {
+		query.Set("redirect", result.RedirectPath)
+	}
+	// 外部登录同样需要通过两步验证
+	challenge, err := service.TwoFactorSigninBegin(result.User.ID, c.IP())
+	if err != nil {
+		return failRedirect("创建两步验证失败")
+	}
+	if challenge != "" {
+		query.Set("twoFactorChallenge", challenge)
+		return c.Redirect(oidcFrontendURL("/user/signin", query), http.StatusFound)
+	}
+	ticket, err := model.OIDCLoginTicketCreate(result.User.ID)
+	if err != nil {
+		return failRedirect("创建登录票据失败")
+	}
+	query.Set("oidcTicket", ticket)
+	return c.Redirect(oidcFrontendURL("/user/signin", query), http.StatusFound)
+}
+
+// OIDCExchange 以回调票据换取访问令牌
+func OIDCExchange(c *fiber.Ctx) error {
+	var req struct {
+		Ticket string `json:"ticket" form:"ticket"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Ticket) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "缺少登录票据"})
+	}
+	userID, err := model.OIDCLoginTicketConsume(strings.TrimSpace(req.Ticket))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": oidcErrorMessage(err)})
+	}
+	token, err := issueUserAccessToken(c, userID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "生成token失败",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message":                "登录成功",
+		"token":                  token,
+		"twoFactorSetupRequired": service.TwoFactorSetupPending(userID),
+	})
+}
+
+// OIDCLinkStart 已登录用户绑定外部身份，返回授权地址由前端跳转
+func OIDCLinkStart(c *fiber.Ctx) error {
+	var req struct {
+		Redirect string `json:"redirect" form:"redirect"`
+	}
+	_ = c.BodyParser(&req)
+	authURL, err := service.OIDCBuildAuthURL(oidcCallbackURL(c), req.Redirect, getCurUser(c).ID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": oidcErrorMessage(err)})
+	}
+	return c.JSON(fiber.Map{"url": authURL})
+}
+
+func OIDCIdentityList(c *fiber.Ctx) error {
+	items, err := model.UserOIDCIdentityListByUser(getCurUser(c).ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "获取关联身份失败"})
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+func OIDCUnlink(c *fiber.Ctx) error {
+	var req struct {
+		ID string `json:"id" form:"id"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.ID) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "缺少关联ID"})
+	}
+	if err := service.OIDCUnlink(getCurUser(c).ID, strings.TrimSpace(req.ID)); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "已解除关联"})
+}
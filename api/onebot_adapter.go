@@ -455,19 +455,21 @@ func oneBotActionSendIntoChannel(session *oneBotSession, channel *model.ChannelM
 		return map[string]any{"message_id": messageID}, nil
 	}
 	resp, err := apiMessageCreate(oneBotChatContext(session), &struct {
-		ChannelID         string   `json:"channel_id"`
-		QuoteID           string   `json:"quote_id"`
-		Content           string   `json:"content"`
-		WhisperTo         string   `json:"whisper_to"`
-		WhisperToIds      []string `json:"whisper_to_ids"`
-		ClientID          string   `json:"client_id"`
-		IdentityID        string   `json:"identity_id"`
-		IdentityVariantID string   `json:"identity_variant_id"`
-		ICMode            string   `json:"ic_mode"`
-		BeforeID          string   `json:"before_id"`
-		AfterID           string   `json:"after_id"`
-		DisplayOrder      *float64 `json:"display_order"`
-		TypingDurationMs  *int64   `json:"typing_duration_ms"`
+		ChannelID         string                   `json:"channel_id"`
+		QuoteID           string                   `json:"quote_id"`
+		ThreadID          string                   `json:"thread_id"`
+		Content           string                   `json:"content"`
+		WhisperTo         string                   `json:"whisper_to"`
+		WhisperToIds      []string                 `json:"whisper_to_ids"`
+		ClientID          string                   `json:"client_id"`
+		IdentityID        string                   `json:"identity_id"`
+		IdentityVariantID string                   `json:"identity_variant_id"`
+		ICMode            string                   `json:"ic_mode"`
+		BeforeID          string                   `json:"before_id"`
+		AfterID           string                   `json:"after_id"`
+		DisplayOrder      *float64                 `json:"display_order"`
+		TypingDurationMs  *int64                   `json:"typing_duration_ms"`
+		Poll              *service.PollCreateInput `json:"poll"`
 	}{
 		ChannelID: channel.ID,
 		QuoteID:   decoded.QuoteID,
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+// broadcastPollClosed 投票结束后向频道广播最终结果
+func broadcastPollClosed(ctx *ChatContext, messageData *protocol.Message) {
+	if messageData == nil || messageData.Channel == nil {
+		return
+	}
+	var msg model.MessageModel
+	model.GetDB().Select("id, widget_data").Where("id = ?", messageData.ID).Limit(1).Find(&msg)
+	state := service.ParsePollWidget(msg.WidgetData)
+	if state == nil || !state.Closed {
+		return
+	}
+	payload := &protocol.PollEventPayload{
+		MessageID:   messageData.ID,
+		ChannelID:   messageData.Channel.ID,
+		Question:    state.Question,
+		Options:     make([]*protocol.PollOptionResult, 0, len(state.Options)),
+		TotalVoters: state.TotalVoters,
+		ClosedAt:    state.ClosedAt,
+	}
+	for _, option := range state.Options {
+		payload.Options = append(payload.Options, &protocol.PollOptionResult{Text: option.Text, Count: option.Count})
+	}
+	ev := &protocol.Event{
+		Type:    protocol.EventPollClosed,
+		Channel: messageData.Channel,
+		Message: messageData,
+		Poll:    payload,
+	}
+	if ctx.User != nil {
+		ev.User = ctx.User.ToProtocolType()
+	}
+	ctx.BroadcastEventInChannel(payload.ChannelID, ev)
+	ctx.BroadcastEventInChannelForBot(payload.ChannelID, ev)
+}
+
+// apiPollVotesMine 查询当前用户在给定投票消息中的选择，用于渲染已选状态
+func apiPollVotesMine(ctx *ChatContext, data *struct {
+	ChannelID  string   `json:"channel_id"`
+	MessageIDs []string `json:"message_ids"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if err := checkChannelReadAccess(ctx, channelID); err != nil {
+		return nil, err
+	}
+	if len(data.MessageIDs) > 200 {
+		return nil, fmt.Errorf("message_ids 不能超过 200 个")
+	}
+	votes := map[string][]int{}
+	if ctx.IsReadOnly() || len(data.MessageIDs) == 0 {
+		return &struct {
+			Votes map[string][]int `json:"votes"`
+		}{Votes: votes}, nil
+	}
+
+	// 只返回属于该频道的投票，避免跨频道探测
+	var ids []string
+	if err := model.GetDB().Model(&model.PollModel{}).
+		Where("channel_id = ? AND message_id IN ?", channelID, data.MessageIDs).
+		Pluck("message_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	votes, err := service.PollMyVotes(ids, ctx.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Votes map[string][]int `json:"votes"`
+	}{Votes: votes}, nil
+}
+
+// notifyPollClosed 自动截止的投票没有发起连接，使用系统上下文广播
+func notifyPollClosed(messageID string) {
+	ctx := &ChatContext{
+		ChannelUsersMap: getChannelUsersMap(),
+		UserId2ConnInfo: getUserConnInfoMap(),
+	}
+	messageData := broadcastWidgetMessageUpdated(ctx, messageID, true)
+	broadcastPollClosed(ctx, messageData)
+}
+
+func startPollWorkerForInit() {
+	service.StartPollWorker(notifyPollClosed)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"testing"
+
+	"sealchat/model"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+func TestPollMessageRolledBackWhenPollCreateFails(t *testing.T) {
+	initMessageUpdateWhisperTestDB(t)
+	originalConfig := appConfig
+	appConfig = &utils.AppConfig{}
+	originalChannelUsers, originalUserConns := channelUsersMapGlobal, userId2ConnInfoGlobal
+	channelUsersMapGlobal = &utils.SyncMap[string, *utils.SyncSet[string]]{}
+	userId2ConnInfoGlobal = &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	defer func() {
+		appConfig = originalConfig
+		channelUsersMapGlobal, userId2ConnInfoGlobal = originalChannelUsers, originalUserConns
+	}()
+
+	alice := createMessageUpdateWhisperTestUser(t, "poll-alice-"+utils.NewIDWithLength(10))
+	bob := createMessageUpdateWhisperTestUser(t, "poll-bob-"+utils.NewIDWithLength(10))
+	if err := model.GetDB().Create(&model.FriendModel{UserID1: alice.ID, UserID2: bob.ID, IsFriend: true}).Error; err != nil {
+		t.Fatalf("create friend relation failed: %v", err)
+	}
+	channelID := model.FriendRelationGet(alice.ID, bob.ID).ID
+	if err := model.GetDB().Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: channelID},
+		Name:              "私聊",
+		PermType:          "private",
+		IsPrivate:         true,
+		Status:            "active",
+	}).Error; err != nil {
+		t.Fatalf("create private channel failed: %v", err)
+	}
+	// 去掉投票表，使投票记录写入失败
+	if err := model.GetDB().Migrator().DropTable(&model.PollModel{}); err != nil {
+		t.Fatalf("drop poll table failed: %v", err)
+	}
+
+	ctx := &ChatContext{
+		User:            alice,
+		ChannelUsersMap: channelUsersMapGlobal,
+		UserId2ConnInfo: userId2ConnInfoGlobal,
+	}
+	_, err := apiMessageCreate(ctx, &struct {
+		ChannelID         string                   `json:"channel_id"`
+		QuoteID           string                   `json:"quote_id"`
+		ThreadID          string                   `json:"thread_id"`
+		Content           string                   `json:"content"`
+		WhisperTo         string                   `json:"whisper_to"`
+		WhisperToIds      []string                 `json:"whisper_to_ids"`
+		ClientID          string                   `json:"client_id"`
+		IdentityID        string                   `json:"identity_id"`
+		IdentityVariantID string                   `json:"identity_variant_id"`
+		ICMode            string                   `json:"ic_mode"`
+		BeforeID          string                   `json:"before_id"`
+		AfterID           string                   `json:"after_id"`
+		DisplayOrder      *float64                 `json:"display_order"`
+		TypingDurationMs  *int64                   `json:"typing_duration_ms"`
+		Poll              *service.PollCreateInput `json:"poll"`
+	}{
+		ChannelID: channelID,
+		ICMode:    "ic",
+		Poll:      &service.PollCreateInput{Question: "今晚几点开团？", Options: []string{"八点", "九点"}},
+	})
+	if err == nil {
+		t.Fatal("poll message should fail when the poll row cannot be stored")
+	}
+
+	var count int64
+	if err := model.GetDB().Model(&model.MessageModel{}).Where("channel_id = ?", channelID).Count(&count).Error; err != nil {
+		t.Fatalf("count messages failed: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("poll message without a poll row should not be stored, got %d", count)
+	}
+}
@@ -82,15 +82,17 @@ func (ctx *ChatContext) BroadcastChannelPresenceNow(channelID string) {
 	if ctx == nil || ctx.UserId2ConnInfo == nil || ctx.ChannelUsersMap == nil || channelID == "" {
 		return
 	}
-	now := time.Now().UnixMilli()
+	now := time.Now()
 	presence := buildChannelPresenceSnapshot(channelID, ctx.ChannelUsersMap, ctx.UserId2ConnInfo)
+	// 其他节点只接收本节点的快照，各自合并后下发，避免合并结果在节点间来回覆盖。
+	publishLocalChannelPresence(channelID, presence)
 	event := &protocol.Event{
 		Type:      protocol.EventChannelPresenceUpdated,
-		Timestamp: now,
+		Timestamp: now.Unix(),
 		Channel:   &protocol.Channel{ID: channelID},
-		Presence:  presence,
+		Presence:  mergeRemoteChannelPresence(channelID, presence, now),
 	}
-	ctx.BroadcastEventInChannel(channelID, event)
+	ctx.deliverEventInChannel(channelID, event)
 }
 
 func ChannelPresence(c *fiber.Ctx) error {
@@ -116,6 +118,7 @@ func ChannelPresence(c *fiber.Ctx) error {
 	}
 
 	snapshot := buildChannelPresenceSnapshot(channelID, getChannelUsersMap(), getUserConnInfoMap())
+	snapshot = mergeRemoteChannelPresence(channelID, snapshot, time.Now())
 	return c.JSON(fiber.Map{
 		"data":       snapshot,
 		"updated_at": time.Now().UnixMilli(),
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+func rateLimitMessage(decision service.RateLimitDecision) string {
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf("操作过于频繁，请 %d 秒后重试", seconds)
+}
+
+// rateLimitMiddleware HTTP 接口限流，resolveWorld 用于匹配按世界覆盖的规则，可为空
+func rateLimitMiddleware(route string, resolveWorld func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		subject := service.RateLimitSubject{IP: c.IP()}
+		if user, ok := c.Locals("user").(*model.UserModel); ok && user != nil {
+			subject.UserID = user.ID
+		}
+		if resolveWorld != nil {
+			subject.WorldID = resolveWorld(c)
+		}
+		decision := service.RateLimitAllow(route, subject)
+		if decision.Allowed {
+			return c.Next()
+		}
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+		return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+			"error":        "rate_limited",
+			"message":      rateLimitMessage(decision),
+			"route":        decision.Route,
+			"scope":        decision.Scope,
+			"retryAfterMs": decision.RetryAfterMs(),
+		})
+	}
+}
+
+// rateLimitWorldFromChannelParam 从路径中的频道 ID 解析所属世界
+func rateLimitWorldFromChannelParam(param string) func(c *fiber.Ctx) string {
+	return func(c *fiber.Ctx) string {
+		channelID := strings.TrimSpace(c.Params(param))
+		if channelID == "" {
+			return ""
+		}
+		channel, err := model.ChannelGet(channelID)
+		if err != nil || channel == nil {
+			return ""
+		}
+		return channel.WorldID
+	}
+}
+
+// gatewayRateLimit WebSocket 接口限流，拒绝时向当前连接推送 rate-limited 事件并返回错误
+func gatewayRateLimit(ctx *ChatContext, route string, channelID string, clientID string) error {
+	if ctx == nil || ctx.User == nil {
+		return nil
+	}
+	subject := service.RateLimitSubject{UserID: ctx.User.ID}
+	if ctx.ConnInfo != nil {
+		subject.IP = ctx.ConnInfo.ClientAddr
+	}
+	if channelID != "" && len(channelID) < 30 {
+		if channel, err := model.ChannelGet(channelID); err == nil && channel != nil {
+			subject.WorldID = channel.WorldID
+		}
+	}
+	decision := service.RateLimitAllow(route, subject)
+	if decision.Allowed {
+		return nil
+	}
+	if ctx.Conn != nil {
+		event := protocol.Event{
+			Type:      protocol.EventRateLimited,
+			Timestamp: time.Now().Unix(),
+			RateLimit: &protocol.RateLimitEventPayload{
+				Route:        decision.Route,
+				Scope:        decision.Scope,
+				ChannelID:    channelID,
+				ClientID:     clientID,
+				RetryAfterMs: decision.RetryAfterMs(),
+				Burst:        decision.Burst,
+				PerMinute:    decision.PerMinute,
+			},
+		}
+		recordWSEventMetric(&event)
+		_ = ctx.Conn.WriteJSON(struct {
+			protocol.Event
+			Op protocol.Opcode `json:"op"`
+		}{Event: event, Op: protocol.OpEvent})
+	}
+	return fmt.Errorf("%s", rateLimitMessage(decision))
+}
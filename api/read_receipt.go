@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+// broadcastReadReceipts 阅读者的已读标记推进后，通知相关消息的发送者
+func broadcastReadReceipts(ctx *ChatContext, channelID, readerID string, prevMark, newMark int64) {
+	if ctx == nil || readerID == "" {
+		return
+	}
+	groups, err := service.ReadReceiptCollect(channelID, readerID, prevMark, newMark)
+	if err != nil {
+		log.Printf("计算已读回执失败 channel=%s user=%s err=%v", channelID, readerID, err)
+		return
+	}
+	for senderID, messageIDs := range groups {
+		sendReadReceiptEvent(ctx, senderID, &protocol.MessageReadEventPayload{
+			ChannelID:  channelID,
+			UserID:     readerID,
+			MessageIDs: messageIDs,
+			ReadAt:     newMark,
+		})
+	}
+}
+
+// broadcastReadReceiptsForMessage 新消息发出时，频道内在线成员被直接标记已读，向发送者回报
+func broadcastReadReceiptsForMessage(ctx *ChatContext, msg *model.MessageModel, readerIDs []string) {
+	if ctx == nil || msg == nil || len(readerIDs) == 0 || !service.ReadReceiptEnabled() {
+		return
+	}
+	if !service.ReadReceiptOptedIn(msg.UserID) || !service.ReadReceiptMessageEligible(msg) {
+		return
+	}
+	readAt := time.Now().UnixMilli()
+	for _, readerID := range readerIDs {
+		if readerID == "" || readerID == msg.UserID || !service.ReadReceiptOptedIn(readerID) {
+			continue
+		}
+		sendReadReceiptEvent(ctx, msg.UserID, &protocol.MessageReadEventPayload{
+			ChannelID:  msg.ChannelID,
+			UserID:     readerID,
+			MessageIDs: []string{msg.ID},
+			ReadAt:     readAt,
+		})
+	}
+}
+
+// markOnlineReadersForMessage 悄悄话与私聊的接收者若正停留在该频道，与普通频道一样直接标记已读；
+// 未启用已读回执时保持原有行为，不替接收者推进已读标记
+func markOnlineReadersForMessage(ctx *ChatContext, msg *model.MessageModel, candidates []string) {
+	if ctx == nil || ctx.ChannelUsersMap == nil || msg == nil || !service.ReadReceiptEnabled() {
+		return
+	}
+	userSet, ok := ctx.ChannelUsersMap.Load(msg.ChannelID)
+	if !ok || userSet == nil {
+		return
+	}
+	var online []string
+	for _, uid := range candidates {
+		if uid != "" && uid != msg.UserID && userSet.Exists(uid) {
+			online = append(online, uid)
+		}
+	}
+	if len(online) == 0 {
+		return
+	}
+	_ = model.ChannelReadSetInBatch([]string{msg.ChannelID}, online)
+	go broadcastReadReceiptsForMessage(ctx, msg, online)
+}
+
+func sendReadReceiptEvent(ctx *ChatContext, senderID string, payload *protocol.MessageReadEventPayload) {
+	ctx.BroadcastToUserJSON(senderID, struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		Event: protocol.Event{
+			Type:        protocol.EventMessageRead,
+			Timestamp:   time.Now().Unix(),
+			Channel:     &protocol.Channel{ID: payload.ChannelID},
+			User:        &protocol.User{ID: payload.UserID},
+			MessageRead: payload,
+		},
+		Op: protocol.OpEvent,
+	})
+}
+
+// apiMessageReadList 列出已读过某条消息的用户，仅消息发送者可查看
+func apiMessageReadList(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	messageID := strings.TrimSpace(data.MessageID)
+	if channelID == "" || messageID == "" {
+		return nil, fmt.Errorf("channel_id 与 message_id 不能为空")
+	}
+	var msg model.MessageModel
+	if err := model.GetDB().Where("id = ? AND channel_id = ?", messageID, channelID).Limit(1).Find(&msg).Error; err != nil {
+		return nil, err
+	}
+	if msg.ID == "" {
+		return nil, fmt.Errorf("消息不存在")
+	}
+	if msg.UserID != ctx.User.ID {
+		return nil, fmt.Errorf("只能查看自己发送消息的已读状态")
+	}
+	if !service.ReadReceiptOptedIn(ctx.User.ID) {
+		return nil, fmt.Errorf("请先开启已读回执")
+	}
+	readers, err := service.ReadReceiptList(&msg)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(readers))
+	for _, r := range readers {
+		userIDs = append(userIDs, r.UserID)
+	}
+	id2User := map[string]*model.UserModel{}
+	if len(userIDs) > 0 {
+		var users []*model.UserModel
+		model.GetDB().Select("id, username, nickname, avatar, is_bot").Where("id in ?", userIDs).Find(&users)
+		for _, u := range users {
+			id2User[u.ID] = u
+		}
+	}
+
+	type readerItem struct {
+		User       *protocol.User `json:"user"`
+		LastReadAt int64          `json:"lastReadAt"`
+	}
+	items := make([]readerItem, 0, len(readers))
+	for _, r := range readers {
+		user := &protocol.User{ID: r.UserID}
+		if u, ok := id2User[r.UserID]; ok {
+			user = u.ToProtocolType()
+		}
+		items = append(items, readerItem{User: user, LastReadAt: r.LastReadAt})
+	}
+	return &struct {
+		Readers []readerItem `json:"readers"`
+	}{Readers: items}, nil
+}
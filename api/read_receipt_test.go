@@ -0,0 +1,48 @@
+package api
+
+import (
+	"testing"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestMarkOnlineReadersSkippedWhenReadReceiptsDisabled(t *testing.T) {
+	initMessageUpdateWhisperTestDB(t)
+	if cfg := utils.GetConfig(); cfg != nil && cfg.ReadReceipts.Enabled {
+		cfg.ReadReceipts.Enabled = false
+		defer func() { cfg.ReadReceipts.Enabled = true }()
+	}
+
+	sender := createMessageUpdateWhisperTestUser(t, "rr-sender-"+utils.NewIDWithLength(10))
+	reader := createMessageUpdateWhisperTestUser(t, "rr-reader-"+utils.NewIDWithLength(10))
+	channelID := "rr-ch-" + utils.NewIDWithLength(10)
+	if err := model.ChannelReadInit(channelID, reader.ID); err != nil {
+		t.Fatalf("init read mark failed: %v", err)
+	}
+
+	online := &utils.SyncSet[string]{}
+	online.Add(sender.ID)
+	online.Add(reader.ID)
+	users := &utils.SyncMap[string, *utils.SyncSet[string]]{}
+	users.Store(channelID, online)
+	ctx := &ChatContext{
+		User:            sender,
+		ChannelUsersMap: users,
+		UserId2ConnInfo: &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{},
+	}
+	msg := &model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "rr-msg-" + utils.NewIDWithLength(10)},
+		ChannelID:         channelID,
+		UserID:            sender.ID,
+	}
+	markOnlineReadersForMessage(ctx, msg, []string{reader.ID})
+
+	mark, err := model.ChannelReadMarkGet(channelID, reader.ID)
+	if err != nil {
+		t.Fatalf("load read mark failed: %v", err)
+	}
+	if mark != 0 {
+		t.Fatalf("read mark should stay untouched when read receipts are disabled, got %d", mark)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+func mapScheduledMessageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrScheduledMessagePermission):
+		return fiber.StatusForbidden
+	case errors.Is(err, service.ErrScheduledMessageNotFound), errors.Is(err, service.ErrChannelNotFound):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusBadRequest
+	}
+}
+
+func scheduledMessageError(c *fiber.Ctx, err error) error {
+	message := err.Error()
+	switch {
+	case errors.Is(err, service.ErrScheduledMessagePermission):
+		message = "没有权限管理该定时消息"
+	case errors.Is(err, service.ErrScheduledMessageNotFound):
+		message = "定时消息不存在"
+	case errors.Is(err, service.ErrChannelNotFound):
+		message = "频道不存在"
+	}
+	return c.Status(mapScheduledMessageErrorStatus(err)).JSON(fiber.Map{"message": message})
+}
+
+func ChannelScheduledMessageList(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	items, err := service.ScheduledMessageList(user.ID, c.Params("channelId"), c.QueryBool("includeFinished"))
+	if err != nil {
+		return scheduledMessageError(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+func ChannelScheduledMessageCreate(c *fiber.Ctx) error {
+	var payload service.ScheduledMessageInput
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "请求参数解析失败"})
+	}
+	user := getCurUser(c)
+	item, err := service.ScheduledMessageCreate(user.ID, c.Params("channelId"), &payload)
+	if err != nil {
+		return scheduledMessageError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"item": item})
+}
+
+func ScheduledMessageUpdate(c *fiber.Ctx) error {
+	var payload service.ScheduledMessageInput
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "请求参数解析失败"})
+	}
+	user := getCurUser(c)
+	item, err := service.ScheduledMessageUpdate(user.ID, c.Params("id"), &payload)
+	if err != nil {
+		return scheduledMessageError(c, err)
+	}
+	return c.JSON(fiber.Map{"item": item})
+}
+
+func ScheduledMessageCancel(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	item, err := service.ScheduledMessageCancel(user.ID, c.Params("id"))
+	if err != nil {
+		return scheduledMessageError(c, err)
+	}
+	return c.JSON(fiber.Map{"item": item})
+}
+
+// deliverScheduledMessage 以创建者身份走 message.create 的完整流程发送，权限与身份在发送时重新校验
+func deliverScheduledMessage(item *model.ScheduledMessageModel, clientID string) (string, error) {
+	user := model.UserGet(item.UserID)
+	if user == nil || user.Disabled {
+		return "", errors.New("创建者账号不可用")
+	}
+	ctx := &ChatContext{
+		User:            user,
+		ChannelUsersMap: getChannelUsersMap(),
+		UserId2ConnInfo: getUserConnInfoMap(),
+	}
+	resp, err := apiMessageCreate(ctx, &struct {
+		ChannelID         string                   `json:"channel_id"`
+		QuoteID           string                   `json:"quote_id"`
+		ThreadID          string                   `json:"thread_id"`
+		Content           string                   `json:"content"`
+		WhisperTo         string                   `json:"whisper_to"`
+		WhisperToIds      []string                 `json:"whisper_to_ids"`
+		ClientID          string                   `json:"client_id"`
+		IdentityID        string                   `json:"identity_id"`
+		IdentityVariantID string                   `json:"identity_variant_id"`
+		ICMode            string                   `json:"ic_mode"`
+		BeforeID          string                   `json:"before_id"`
+		AfterID           string                   `json:"after_id"`
+		DisplayOrder      *float64                 `json:"display_order"`
+		TypingDurationMs  *int64                   `json:"typing_duration_ms"`
+		Poll              *service.PollCreateInput `json:"poll"`
+	}{
+		ChannelID:         item.ChannelID,
+		Content:           item.Content,
+		ClientID:          clientID,
+		IdentityID:        item.IdentityID,
+		IdentityVariantID: item.IdentityVariantID,
+		ICMode:            item.ICMode,
+	})
+	if err != nil {
+		return "", err
+	}
+	message, _ := resp.(*protocol.Message)
+	if message == nil || message.ID == "" {
+		return "", errors.New("消息发送被拒绝，可能已失去发送权限")
+	}
+	return message.ID, nil
+}
+
+func startScheduledMessageWorkerForInit() {
+	service.StartScheduledMessageWorker(deliverScheduledMessage)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+func TestDeliverScheduledMessageKeepsCreatorDraft(t *testing.T) {
+	initMessageUpdateWhisperTestDB(t)
+	originalConfig := appConfig
+	appConfig = &utils.AppConfig{}
+	originalChannelUsers, originalUserConns := channelUsersMapGlobal, userId2ConnInfoGlobal
+	channelUsersMapGlobal = &utils.SyncMap[string, *utils.SyncSet[string]]{}
+	userId2ConnInfoGlobal = &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	defer func() {
+		appConfig = originalConfig
+		channelUsersMapGlobal, userId2ConnInfoGlobal = originalChannelUsers, originalUserConns
+	}()
+
+	alice := createMessageUpdateWhisperTestUser(t, "sched-alice-"+utils.NewIDWithLength(10))
+	bob := createMessageUpdateWhisperTestUser(t, "sched-bob-"+utils.NewIDWithLength(10))
+	if err := model.GetDB().Create(&model.FriendModel{UserID1: alice.ID, UserID2: bob.ID, IsFriend: true}).Error; err != nil {
+		t.Fatalf("create friend relation failed: %v", err)
+	}
+	channelID := model.FriendRelationGet(alice.ID, bob.ID).ID
+	if err := model.GetDB().Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: channelID},
+		Name:              "私聊",
+		PermType:          "private",
+		IsPrivate:         true,
+		Status:            "active",
+	}).Error; err != nil {
+		t.Fatalf("create private channel failed: %v", err)
+	}
+
+	if _, _, err := service.MessageDraftSave(alice.ID, &service.MessageDraftInput{
+		ChannelID: channelID,
+		Mode:      "plain",
+		Content:   "还没写完的草稿",
+		UpdatedAt: time.Now().UnixMilli(),
+	}); err != nil {
+		t.Fatalf("save draft failed: %v", err)
+	}
+
+	messageID, err := deliverScheduledMessage(&model.ScheduledMessageModel{
+		ChannelID: channelID,
+		UserID:    alice.ID,
+		Content:   "定时发送的内容",
+		ICMode:    "ic",
+	}, "sched-"+utils.NewIDWithLength(8))
+	if err != nil || messageID == "" {
+		t.Fatalf("deliver scheduled message failed: id=%q err=%v", messageID, err)
+	}
+
+	draft, err := service.MessageDraftLoad(alice.ID, channelID)
+	if err != nil || draft == nil || draft.Content != "还没写完的草稿" {
+		t.Fatalf("scheduled delivery should not clear the creator's draft, got %+v err=%v", draft, err)
+	}
+}
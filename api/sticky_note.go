@@ -12,6 +12,7 @@ import (
 
 	"sealchat/model"
 	"sealchat/protocol"
+	"sealchat/service/broadcast"
 	"sealchat/utils"
 )
 
@@ -1159,12 +1160,15 @@ func BroadcastStickyNoteToChannel(channelID string, eventType protocol.EventName
 		StickyNote: payload,
 		Timestamp:  time.Now().UnixMilli(),
 	}
+	deliverEventToChannelViewers(getUserConnInfoMap(), channelID, event)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindChannelViewers, ChannelID: channelID}, event)
+}
 
-	userConnMap := getUserConnInfoMap()
+// deliverEventToChannelViewers 投递给本节点当前正在查看该频道的连接
+func deliverEventToChannelViewers(userConnMap *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]], channelID string, event *protocol.Event) {
 	if userConnMap == nil {
 		return
 	}
-
 	userConnMap.Range(func(userID string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		connMap.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info.ChannelId == channelID {
@@ -1192,33 +1196,34 @@ func BroadcastStickyNoteToUsers(userIDs []string, eventType protocol.EventName,
 	if payload != nil && payload.Note != nil && payload.Note.ChannelID != "" {
 		event.Channel = &protocol.Channel{ID: payload.Note.ChannelID}
 	}
+	data := struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		Event: *event,
+		Op:    protocol.OpEvent,
+	}
 
 	targetSet := make(map[string]bool)
 	for _, id := range userIDs {
 		targetSet[id] = true
 	}
 
-	userConnMap := getUserConnInfoMap()
-	if userConnMap == nil {
-		return
-	}
-
-	userConnMap.Range(func(userID string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
-		if !targetSet[userID] {
-			return true
-		}
-		connMap.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
-			_ = conn.WriteJSON(struct {
-				protocol.Event
-				Op protocol.Opcode `json:"op"`
-			}{
-				Event: *event,
-				Op:    protocol.OpEvent,
+	if userConnMap := getUserConnInfoMap(); userConnMap != nil {
+		userConnMap.Range(func(userID string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+			if !targetSet[userID] {
+				return true
+			}
+			connMap.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
+				_ = conn.WriteJSON(data)
+				return true
 			})
 			return true
 		})
-		return true
-	})
+	}
+	if len(targetSet) > 0 {
+		publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindUser, UserIDs: userIDs}, data)
+	}
 }
 
 // ========== 文件夹 API ==========
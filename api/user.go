@@ -183,7 +183,7 @@ func UserSignup(c *fiber.Ctx) error {
 		}
 	}
 
-	token, err := model.UserGenerateAccessToken(user.ID)
+	token, err := issueUserAccessToken(c, user.ID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "生成token失败",
@@ -242,15 +242,30 @@ func UserSignin(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
-	token, err := model.UserGenerateAccessToken(user.ID)
+	challenge, err := service.TwoFactorSigninBegin(user.ID, c.IP())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "创建两步验证失败",
+		})
+	}
+	if challenge != "" {
+		// 已启用两步验证，需携带 challenge 调用 /user-signin/2fa 完成登录
+		return c.JSON(fiber.Map{
+			"message":           "请输入两步验证码",
+			"twoFactorRequired": true,
+			"challenge":         challenge,
+		})
+	}
+	token, err := issueUserAccessToken(c, user.ID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "生成token失败",
 		})
 	}
 	return c.JSON(fiber.Map{
-		"message": "登录成功",
-		"token":   token,
+		"message":                "登录成功",
+		"token":                  token,
+		"twoFactorSetupRequired": service.TwoFactorSetupPending(user.ID),
 	})
 }
 
@@ -305,7 +320,7 @@ func UserChangePassword(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := model.UserGenerateAccessToken(user.ID)
+	token, err := issueUserAccessToken(c, user.ID)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "生成token失败",
@@ -9,6 +9,7 @@ import (
 
 	"sealchat/model"
 	"sealchat/pm"
+	"sealchat/service"
 	"sealchat/utils"
 )
 
@@ -131,6 +132,7 @@ func SignCheckMiddleware(c *fiber.Ctx) error {
 			)
 		}
 
+		model.AccessTokenTouch(user.AccessToken, c.IP())
 		if user.AccessToken != nil && user.AccessToken.ID != "" && shouldRefreshUserToken(token) {
 			if refreshed, refreshErr := model.UserRefreshAccessToken(user.AccessToken.ID); refreshErr == nil {
 				token = refreshed
@@ -163,5 +165,11 @@ func UserRoleAdminMiddleware(c *fiber.Ctx) error {
 	if !CanWithSystemRole(c, pm.PermModAdmin) {
 		return nil
 	}
+	if user := getCurUser(c); user != nil && service.TwoFactorSetupPending(user.ID) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"message":                "请先启用两步验证后再使用管理功能",
+			"twoFactorSetupRequired": true,
+		})
+	}
 	return c.Next()
 }
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/service"
+	"sealchat/service/broadcast"
+	"sealchat/utils"
+)
+
+// issueUserAccessToken 签发登录凭证并记录当前请求的设备信息
+func issueUserAccessToken(c *fiber.Ctx, userID string) (string, error) {
+	return model.UserGenerateAccessTokenWithMeta(userID, model.AccessTokenMeta{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	})
+}
+
+func currentAccessTokenID(c *fiber.Ctx) string {
+	user := getCurUser(c)
+	if user == nil || user.AccessToken == nil {
+		return ""
+	}
+	return user.AccessToken.ID
+}
+
+// closeAccessTokenConnections 断开使用指定会话建立的 WebSocket 连接，清理由连接退出流程完成。
+// 其他节点上的连接经广播总线通知断开，返回值只统计本节点
+func closeAccessTokenConnections(userID string, tokenIDs []string) int {
+	if len(tokenIDs) == 0 {
+		return 0
+	}
+	closed := closeLocalAccessTokenConnections(getUserConnInfoMap(), userID, tokenIDs)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindSessionRevoke, UserIDs: []string{userID}}, tokenIDs)
+	return closed
+}
+
+func closeLocalAccessTokenConnections(connMap *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]], userID string, tokenIDs []string) int {
+	if connMap == nil || len(tokenIDs) == 0 {
+		return 0
+	}
+	userConns, ok := connMap.Load(userID)
+	if !ok || userConns == nil {
+		return 0
+	}
+	revoked := make(map[string]struct{}, len(tokenIDs))
+	for _, id := range tokenIDs {
+		revoked[id] = struct{}{}
+	}
+	var targets []*WsSyncConn
+	userConns.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
+		if info == nil || info.AccessTokenID == "" {
+			return true
+		}
+		if _, hit := revoked[info.AccessTokenID]; hit {
+			targets = append(targets, conn)
+		}
+		return true
+	})
+	for _, conn := range targets {
+		_ = conn.Close()
+	}
+	if len(targets) > 0 {
+		log.Printf("[WS] 用户 %s 注销会话，断开连接 %d 个", userID, len(targets))
+	}
+	return len(targets)
+}
+
+func UserSessionList(c *fiber.Ctx) error {
+	items, err := service.UserSessionList(getCurUser(c).ID, currentAccessTokenID(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "获取登录设备失败"})
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// UserSessionRevoke 注销指定设备的登录状态
+func UserSessionRevoke(c *fiber.Ctx) error {
+	var req struct {
+		ID string `json:"id" form:"id"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.ID) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "缺少会话ID"})
+	}
+	user := getCurUser(c)
+	tokenIDs, err := service.UserSessionRevoke(user.ID, req.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserSessionNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "注销会话失败"})
+	}
+	closed := closeAccessTokenConnections(user.ID, tokenIDs)
+	current := slices.Contains(tokenIDs, currentAccessTokenID(c))
+	return c.JSON(fiber.Map{
+		"message":           "已注销该设备",
+		"closedConnections": closed,
+		"current":           current,
+	})
+}
+
+// UserSessionRevokeOthers 注销除当前设备外的所有登录状态
+func UserSessionRevokeOthers(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	currentID := currentAccessTokenID(c)
+	if currentID == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "当前凭证不支持该操作"})
+	}
+	tokenIDs, err := service.UserSessionRevokeOthers(user.ID, currentID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "注销会话失败"})
+	}
+	closed := closeAccessTokenConnections(user.ID, tokenIDs)
+	return c.JSON(fiber.Map{
+		"message":           "已注销其他设备",
+		"revoked":           len(tokenIDs),
+		"closedConnections": closed,
+	})
+}
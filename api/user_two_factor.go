@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/service"
+)
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" form:"code"`
+}
+
+func twoFactorErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorCodeInvalid):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "验证码错误或已使用"})
+	case errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, service.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, service.ErrTwoFactorNotPending),
+		errors.Is(err, model.ErrTwoFactorChallengeInvalid),
+		errors.Is(err, model.ErrTwoFactorChallengeMaxAttempts):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorRequired):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	default:
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "两步验证操作失败"})
+	}
+}
+
+func parseTwoFactorCode(c *fiber.Ctx) (string, bool) {
+	var req twoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return "", false
+	}
+	code := strings.TrimSpace(req.Code)
+	return code, code != ""
+}
+
+// UserSigninTwoFactor 登录第二步：校验密码登录返回的挑战与验证码/恢复码
+func UserSigninTwoFactor(c *fiber.Ctx) error {
+	var req struct {
+		Challenge string `json:"challenge" form:"challenge"`
+		Code      string `json:"code" form:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "请输入验证码",
+		})
+	}
+	user, err := service.TwoFactorSigninComplete(req.Challenge, req.Code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	token, err := issueUserAccessToken(c, user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "生成token失败",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message": "登录成功",
+		"token":   token,
+	})
+}
+
+func UserTwoFactorStatus(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	status, err := service.TwoFactorStatusGet(user.ID)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return c.JSON(status)
+}
+
+// UserTwoFactorSetup 生成新的密钥，返回 otpauth 链接供验证器应用扫码
+func UserTwoFactorSetup(c *fiber.Ctx) error {
+	setup, err := service.TwoFactorBeginSetup(getCurUser(c))
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return c.JSON(setup)
+}
+
+func UserTwoFactorEnable(c *fiber.Ctx) error {
+	code, ok := parseTwoFactorCode(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "请输入验证码"})
+	}
+	codes, err := service.TwoFactorConfirmSetup(getCurUser(c).ID, code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message":       "两步验证已启用",
+		"recoveryCodes": codes,
+	})
+}
+
+func UserTwoFactorDisable(c *fiber.Ctx) error {
+	code, ok := parseTwoFactorCode(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "请输入验证码"})
+	}
+	if err := service.TwoFactorDisable(getCurUser(c).ID, code); err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "两步验证已关闭"})
+}
+
+func UserTwoFactorRecoveryCodes(c *fiber.Ctx) error {
+	code, ok := parseTwoFactorCode(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "请输入验证码"})
+	}
+	codes, err := service.TwoFactorRegenerateRecoveryCodes(getCurUser(c).ID, code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message":       "恢复码已重新生成",
+		"recoveryCodes": codes,
+	})
+}
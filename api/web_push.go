@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/service"
+)
+
+const webPushMaxSubscriptionsPerUser = 20
+
+type webPushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// WebPushConfigGet 返回推送开关与 VAPID 公钥
+func WebPushConfigGet(c *fiber.Ctx) error {
+	if !service.WebPushEnabled() {
+		return c.JSON(fiber.Map{"enabled": false})
+	}
+	publicKey, err := service.WebPushVAPIDPublicKey()
+	if err != nil {
+		return wrapErrorStatus(c, http.StatusInternalServerError, err, "获取推送密钥失败")
+	}
+	return c.JSON(fiber.Map{"enabled": true, "publicKey": publicKey})
+}
+
+// WebPushSubscribe 保存当前设备的推送订阅
+func WebPushSubscribe(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	if !service.WebPushEnabled() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": service.ErrWebPushDisabled.Error()})
+	}
+	var body webPushSubscriptionRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "请求参数错误"})
+	}
+	if err := service.ValidateWebPushSubscription(body.Endpoint, body.Keys.P256dh, body.Keys.Auth); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	existing, err := model.WebPushSubscriptionListByUser(user.ID)
+	if err != nil {
+		return wrapError(c, err, "获取推送订阅失败")
+	}
+	if len(existing) >= webPushMaxSubscriptionsPerUser {
+		// 超出上限时淘汰最早的订阅
+		_ = model.WebPushSubscriptionDeleteByID(existing[0].ID)
+	}
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	item, err := model.WebPushSubscriptionUpsert(user.ID, body.Endpoint, body.Keys.P256dh, body.Keys.Auth, userAgent)
+	if err != nil {
+		return wrapError(c, err, "保存推送订阅失败")
+	}
+	return c.JSON(fiber.Map{"item": item})
+}
+
+// WebPushUnsubscribe 删除当前设备的推送订阅
+func WebPushUnsubscribe(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var body webPushSubscriptionRequest
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Endpoint) == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": "请求参数错误"})
+	}
+	if err := model.WebPushSubscriptionDelete(user.ID, body.Endpoint); err != nil {
+		return wrapError(c, err, "删除推送订阅失败")
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// WebPushTestSend 向当前用户的所有设备发送测试通知
+func WebPushTestSend(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	sent, err := service.WebPushSendTest(user.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrWebPushDisabled) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+		}
+		return wrapError(c, err, "发送测试通知失败")
+	}
+	return c.JSON(fiber.Map{"sent": sent})
+}
+
+// notifyWebPushForMessage 新消息创建后异步推送给被提及、悄悄话对象和被回复者
+func notifyWebPushForMessage(sender *model.UserModel, channel *model.ChannelModel, msg *model.MessageModel, content string, whisperIDs []string, quote *model.MessageModel) {
+	if sender == nil || channel == nil || msg == nil || !service.WebPushEnabled() {
+		return
+	}
+	mentionIDs := make([]string, 0)
+	replyTo := ""
+	// 悄悄话只推送给收件人，避免内容泄露给被提及或被引用的其他人
+	if len(whisperIDs) == 0 {
+		for id := range collectMentionTargetIDsFromContent(content) {
+			mentionIDs = append(mentionIDs, id)
+		}
+		// 机器人回复指令时会自动引用原消息，不视为回复推送
+		if quote != nil && quote.ID != "" && !sender.IsBot {
+			replyTo = quote.UserID
+		}
+	}
+	if len(mentionIDs) == 0 && len(whisperIDs) == 0 && replyTo == "" {
+		return
+	}
+	senderName := strings.TrimSpace(msg.SenderMemberName)
+	if senderName == "" {
+		senderName = sender.Nickname
+	}
+	if senderName == "" {
+		senderName = sender.Username
+	}
+	channelName := channel.Name
+	if channel.PermType == "private" {
+		channelName = "私聊"
+	}
+	event := &service.WebPushMessageEvent{
+		ChannelID:     channel.ID,
+		WorldID:       channel.WorldID,
+		ChannelName:   channelName,
+		MessageID:     msg.ID,
+		SenderID:      sender.ID,
+		SenderName:    senderName,
+		Content:       content,
+		MentionIDs:    mentionIDs,
+		WhisperIDs:    whisperIDs,
+		ReplyToUserID: replyTo,
+	}
+	go service.WebPushNotifyMessage(event)
+}
@@ -430,9 +430,21 @@ func webhookMessageCreate(c *fiber.Ctx, integration *model.ChannelWebhookIntegra
 		displayOrder = *req.Message.DisplayOrder
 	}
 
+	// 可验证骰点需要在掷骰前确定消息ID
+	messageID := utils.NewID()
 	var renderResult *service.DiceRenderResult
 	if service.IsBuiltInDiceEffectivelyEnabled(channel) {
-		renderResult, err = service.RenderDiceContent(content, channel.DefaultDiceExpr, nil)
+		var fairness *service.DiceFairnessContext
+		if channel.VerifiableDiceEnabled {
+			if fairness, err = service.NewDiceFairnessContext(channel.ID, messageID); err != nil {
+				return wrapError(c, err, "准备可验证骰点失败")
+			}
+		}
+		renderResult, err = service.RenderDiceContentWithOptions(content, channel.DefaultDiceExpr, nil, service.DiceRenderOptions{
+			RuleSystem: channel.DiceRuleSystem,
+			LoadCard:   service.NewDiceCardLoader(botUser.ID, channel.ID, identityID),
+			Fairness:   fairness,
+		})
 		if err != nil {
 			return wrapError(c, err, "渲染骰点失败")
 		}
@@ -442,7 +454,7 @@ func webhookMessageCreate(c *fiber.Ctx, integration *model.ChannelWebhookIntegra
 	}
 
 	msg := &model.MessageModel{
-		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		StringPKBaseModel: model.StringPKBaseModel{ID: messageID},
 		UserID:            botUser.ID,
 		ChannelID:         channel.ID,
 		MemberID:          member.ID,
@@ -478,6 +490,11 @@ func webhookMessageCreate(c *fiber.Ctx, integration *model.ChannelWebhookIntegra
 	if err := db.Create(msg).Error; err != nil {
 		return wrapError(c, err, "创建消息失败")
 	}
+	if renderResult != nil {
+		if err := model.MessageDiceRollReplace(msg.ID, renderResult.Rolls); err != nil {
+			return wrapError(c, err, "保存骰点记录失败")
+		}
+	}
 
 	if source != "" && externalID != "" {
 		_, _ = model.MessageExternalRefUpsert(channel.ID, source, externalID, msg.ID, integration.ID, externalActorID)
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestWebhookMessageCreateUsesVerifiableDice(t *testing.T) {
+	initMessageUpdateWhisperTestDB(t)
+	originalConfig := appConfig
+	appConfig = &utils.AppConfig{}
+	originalChannelUsers, originalUserConns := channelUsersMapGlobal, userId2ConnInfoGlobal
+	channelUsersMapGlobal = &utils.SyncMap[string, *utils.SyncSet[string]]{}
+	userId2ConnInfoGlobal = &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	defer func() {
+		appConfig = originalConfig
+		channelUsersMapGlobal, userId2ConnInfoGlobal = originalChannelUsers, originalUserConns
+	}()
+
+	bot := createMessageUpdateWhisperTestUser(t, "hook-bot-"+utils.NewIDWithLength(10))
+	channel := &model.ChannelModel{
+		StringPKBaseModel:     model.StringPKBaseModel{ID: "hook-ch-" + utils.NewIDWithLength(10)},
+		Name:                  "可验证骰点",
+		PermType:              "public",
+		Status:                "active",
+		BuiltInDiceEnabled:    true,
+		VerifiableDiceEnabled: true,
+		DefaultDiceExpr:       "d20",
+	}
+	if err := model.GetDB().Create(channel).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+	integration := &model.ChannelWebhookIntegrationModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "hook-int-" + utils.NewIDWithLength(10)},
+	}
+
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		return webhookMessageCreate(c, integration, bot, channel, &webhookWriteRequest{
+			Message: &webhookMessagePayload{Content: "攻击 {d20+3}"},
+		})
+	})
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("webhook create failed: status=%v err=%v", resp, err)
+	}
+
+	var msg model.MessageModel
+	if err := model.GetDB().Where("channel_id = ?", channel.ID).Limit(1).Find(&msg).Error; err != nil || msg.ID == "" {
+		t.Fatalf("load webhook message failed: %v", err)
+	}
+	rolls, err := model.MessageDiceRollListByMessageID(msg.ID)
+	if err != nil || len(rolls) != 1 {
+		t.Fatalf("expected one stored roll, got %d err=%v", len(rolls), err)
+	}
+	if rolls[0].FairWindowID == "" || rolls[0].FairInputs == "" {
+		t.Fatalf("webhook roll should carry fairness data: %+v", rolls[0])
+	}
+}
@@ -642,6 +642,7 @@ func WorldMemberRemoveHandler(c *fiber.Ctx) error {
 	}
 	worldID := c.Params("worldId")
 	targetUserID := c.Params("userId")
+	previousRole := service.GetWorldMemberRole(worldID, targetUserID)
 	if err := service.WorldRemoveMember(worldID, user.ID, targetUserID); err != nil {
 		switch {
 		case errors.Is(err, service.ErrWorldPermission):
@@ -654,6 +655,15 @@ func WorldMemberRemoveHandler(c *fiber.Ctx) error {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "操作失败"})
 		}
 	}
+	recordAudit(c, service.AuditEntry{
+		Action:     model.AuditActionWorldMemberRemove,
+		TargetType: model.AuditTargetUser,
+		TargetID:   targetUserID,
+		TargetName: service.AuditUserDisplayName(targetUserID),
+		WorldID:    worldID,
+		Before:     fiber.Map{"role": previousRole},
+		After:      fiber.Map{"role": nil},
+	})
 	return c.JSON(fiber.Map{"message": "已移除"})
 }
 
@@ -670,6 +680,7 @@ func WorldMemberRoleHandler(c *fiber.Ctx) error {
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
 	}
+	previousRole := service.GetWorldMemberRole(worldID, targetUserID)
 	if err := service.WorldUpdateMemberRole(worldID, user.ID, targetUserID, body.Role); err != nil {
 		switch {
 		case errors.Is(err, service.ErrWorldPermission):
@@ -682,6 +693,15 @@ func WorldMemberRoleHandler(c *fiber.Ctx) error {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "更新失败"})
 		}
 	}
+	recordAudit(c, service.AuditEntry{
+		Action:     model.AuditActionWorldMemberRole,
+		TargetType: model.AuditTargetUser,
+		TargetID:   targetUserID,
+		TargetName: service.AuditUserDisplayName(targetUserID),
+		WorldID:    worldID,
+		Before:     fiber.Map{"role": previousRole},
+		After:      fiber.Map{"role": strings.TrimSpace(body.Role)},
+	})
 	return c.JSON(fiber.Map{"message": "已更新"})
 }
 
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/service"
+)
+
+// worldBundleTempFile 在响应发送完毕后删除临时文件
+type worldBundleTempFile struct {
+	*os.File
+}
+
+func (f *worldBundleTempFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.File.Name())
+	return err
+}
+
+func WorldBundleExportHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	tempFile, err := os.CreateTemp("", "sealchat-world-bundle-*.zip")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "创建临时文件失败"})
+	}
+	file := &worldBundleTempFile{File: tempFile}
+	manifest, err := service.WorldBundleExport(c.Params("worldId"), user.ID, file)
+	if err != nil {
+		_ = file.Close()
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrWorldPermission):
+			status = fiber.StatusForbidden
+		case errors.Is(err, service.ErrWorldNotFound):
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "读取世界包失败"})
+	}
+	c.Attachment(service.WorldBundleFileName(manifest))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Context().SetBodyStream(file, int(size))
+	return nil
+}
+
+func WorldBundleImportHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "请上传世界包文件"})
+	}
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "无法打开文件"})
+	}
+	defer file.Close()
+	// userMap 为 JSON 对象：包内用户 ID -> 本站用户名，由服务端校验导入者是否有权映射
+	var userMapping map[string]string
+	if raw := strings.TrimSpace(c.FormValue("userMap")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &userMapping); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "用户映射格式错误"})
+		}
+	}
+	report, err := service.WorldBundleImport(file, header.Size, user.ID, userMapping)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if errors.Is(err, service.ErrWorldCreateForbidden) {
+			status = fiber.StatusForbidden
+		}
+		return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"report": report})
+}
@@ -10,6 +10,7 @@ import (
 	"sealchat/model"
 	"sealchat/protocol"
 	"sealchat/service"
+	"sealchat/service/broadcast"
 	"sealchat/utils"
 )
 
@@ -611,11 +612,17 @@ func broadcastWorldKeywordEvent(payload *worldKeywordEventPayload) {
 }
 
 func broadcastEventToWorld(worldID string, event *protocol.Event) {
-	if userId2ConnInfoGlobal == nil {
+	event.Timestamp = time.Now().Unix()
+	deliverEventToWorld(userId2ConnInfoGlobal, worldID, event)
+	publishBroadcastEnvelope(&broadcast.Envelope{Kind: broadcast.KindWorld, WorldID: worldID}, event)
+}
+
+// deliverEventToWorld 投递给本节点当前处于该世界的连接
+func deliverEventToWorld(userConnMap *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]], worldID string, event *protocol.Event) {
+	if userConnMap == nil {
 		return
 	}
-	event.Timestamp = time.Now().Unix()
-	userId2ConnInfoGlobal.Range(func(_ string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+	userConnMap.Range(func(_ string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		conns.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info != nil && info.WorldId == worldID {
 				_ = conn.WriteJSON(struct {
@@ -19,6 +19,7 @@ import (
 	"sealchat/model"
 	"sealchat/pm"
 	"sealchat/service"
+	"sealchat/service/broadcast"
 	"sealchat/service/metrics"
 	"sealchat/service/perfprofiler"
 	"sealchat/utils"
@@ -208,6 +209,11 @@ func main() {
 
 	pm.Init()
 
+	// 显式配置了跨节点总线却无法启动时直接退出，避免各节点悄悄退化为互不相通的单节点
+	if _, err := broadcast.Init(ctx, config.Broadcast, config.DSN); err != nil {
+		fatalWithStartupLock("初始化 %s 广播总线失败: %v", config.Broadcast.Backend, err)
+	}
+
 	service.SyncUpdateCurrentVersion(utils.BuildVersion)
 
 	storageManager, err := service.InitStorageManager(config.Storage)
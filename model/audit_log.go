@@ -0,0 +1,113 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	AuditActionUserDisable        = "user.disable"
+	AuditActionUserEnable         = "user.enable"
+	AuditActionUserDelete         = "user.delete"
+	AuditActionUserTwoFactorReset = "user.two_factor_reset"
+	AuditActionRolePermApply      = "role.perm_apply"
+	AuditActionMessageAdminEdit   = "message.admin_edit"
+	AuditActionWorldMemberRemove  = "world.member_remove"
+	AuditActionWorldMemberRole    = "world.member_role"
+	AuditActionBotTokenCreate     = "bot.token_create"
+	AuditActionConfigUpdate       = "config.update"
+)
+
+const (
+	AuditTargetUser    = "user"
+	AuditTargetRole    = "role"
+	AuditTargetMessage = "message"
+	AuditTargetBot     = "bot"
+	AuditTargetConfig  = "config"
+)
+
+// AuditLogModel 管理操作审计记录，Before/After 为操作前后的快照，Changes 为逐字段差异
+type AuditLogModel struct {
+	StringPKBaseModel
+	ActorID     string `json:"actorId" gorm:"size:100;index"`
+	ActorName   string `json:"actorName" gorm:"size:128"`
+	Action      string `json:"action" gorm:"size:64;index"`
+	TargetType  string `json:"targetType" gorm:"size:32;index:idx_audit_target,priority:1"`
+	TargetID    string `json:"targetId" gorm:"size:100;index:idx_audit_target,priority:2"`
+	TargetName  string `json:"targetName" gorm:"size:128"`
+	WorldID     string `json:"worldId" gorm:"size:100;index"`
+	ChannelID   string `json:"channelId" gorm:"size:100"`
+	IP          string `json:"ip" gorm:"size:64"`
+	BeforeJSON  string `json:"beforeJson" gorm:"type:text"`
+	AfterJSON   string `json:"afterJson" gorm:"type:text"`
+	ChangesJSON string `json:"changesJson" gorm:"type:text"`
+}
+
+func (*AuditLogModel) TableName() string {
+	return "audit_logs"
+}
+
+// AuditLogQuery 审计日志筛选条件，时间为毫秒时间戳
+type AuditLogQuery struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	WorldID    string
+	Keyword    string
+	StartMS    int64
+	EndMS      int64
+	Page       int
+	PageSize   int
+}
+
+func AuditLogCreate(item *AuditLogModel) error {
+	if item.ID == "" {
+		item.Init()
+	}
+	return db.Create(item).Error
+}
+
+func AuditLogList(q AuditLogQuery) ([]*AuditLogModel, int64, error) {
+	query := db.Model(&AuditLogModel{})
+	if value := strings.TrimSpace(q.ActorID); value != "" {
+		query = query.Where("actor_id = ?", value)
+	}
+	if value := strings.TrimSpace(q.Action); value != "" {
+		// 以 . 结尾时按前缀匹配，例如 user.
+		if strings.HasSuffix(value, ".") {
+			query = query.Where("action LIKE ?", value+"%")
+		} else {
+			query = query.Where("action = ?", value)
+		}
+	}
+	if value := strings.TrimSpace(q.TargetType); value != "" {
+		query = query.Where("target_type = ?", value)
+	}
+	if value := strings.TrimSpace(q.TargetID); value != "" {
+		query = query.Where("target_id = ?", value)
+	}
+	if value := strings.TrimSpace(q.WorldID); value != "" {
+		query = query.Where("world_id = ?", value)
+	}
+	if value := strings.TrimSpace(q.Keyword); value != "" {
+		like := "%" + value + "%"
+		query = query.Where("actor_name LIKE ? OR target_name LIKE ? OR actor_id = ? OR target_id = ?", like, like, value, value)
+	}
+	if q.StartMS > 0 {
+		query = query.Where("created_at >= ?", time.UnixMilli(q.StartMS))
+	}
+	if q.EndMS > 0 {
+		query = query.Where("created_at <= ?", time.UnixMilli(q.EndMS))
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []*AuditLogModel
+	err := query.Order("created_at DESC").
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Find(&items).Error
+	return items, total, err
+}
@@ -29,7 +29,9 @@ type ChannelModel struct {
 	UserID                  string `json:"userId"`                 // 创建者ID
 	PermType                string `json:"permType"`               // public 公开 non-public 非公开 private 私聊
 	DefaultDiceExpr         string `json:"defaultDiceExpr" gorm:"size:32;not null;default:d20"`
+	DiceRuleSystem          string `json:"diceRuleSystem" gorm:"size:16;not null;default:''"` // 检定规则系统：coc7 / dnd5e，空为不启用
 	BuiltInDiceEnabled      bool   `json:"builtInDiceEnabled" gorm:"default:true"`
+	VerifiableDiceEnabled   bool   `json:"verifiableDiceEnabled" gorm:"default:false"` // 可验证骰点：掷骰结果由预先承诺的种子派生
 	BotFeatureEnabled       bool   `json:"botFeatureEnabled" gorm:"default:false"`
 	PrimaryBotID            string `json:"primaryBotId" gorm:"size:100;index"`
 	EventBotIDsJSON         string `json:"-" gorm:"type:text"`
@@ -241,8 +243,10 @@ func (c *ChannelModel) ToProtocolType() *protocol.Channel {
 		Name:                    c.Name,
 		Type:                    channelType,
 		DefaultDiceExpr:         c.DefaultDiceExpr,
+		DiceRuleSystem:          c.DiceRuleSystem,
 		BotCommandPrefixes:      utils.GetConfiguredBotCommandPrefixes(),
 		BuiltInDiceEnabled:      c.BuiltInDiceEnabled,
+		VerifiableDiceEnabled:   c.VerifiableDiceEnabled,
 		BotFeatureEnabled:       c.BotFeatureEnabled,
 		PrimaryBotID:            c.PrimaryBotID,
 		EventBotIDs:             c.GetEventBotIDs(),
@@ -174,6 +174,15 @@ func ChannelReadSet(channelId, userId string) error {
 		}).Error
 }
 
+// ChannelReadMarkGet 获取用户在频道的已读时间（毫秒），无记录时返回 0
+func ChannelReadMarkGet(channelId, userId string) (int64, error) {
+	var record ChannelLatestReadModel
+	err := db.Select("id, message_time").
+		Where("channel_id = ? AND user_id = ?", channelId, userId).
+		Limit(1).Find(&record).Error
+	return record.MessageTime, err
+}
+
 // ChannelReadSetInBatch 批量设置已读，但要求已存在
 func ChannelReadSetInBatch(channelIds []string, userIds []string) error {
 	now := time.Now().UnixMilli()
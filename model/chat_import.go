@@ -79,12 +79,34 @@ type ChatImportPreviewResponse struct {
 
 // ParsedLogEntry 解析后的日志条目
 type ParsedLogEntry struct {
-	RawLine    string     `json:"rawLine"`
-	Timestamp  *time.Time `json:"timestamp,omitempty"`
-	RoleName   string     `json:"roleName"`
-	Content    string     `json:"content"`
-	IsOOC      bool       `json:"isOoc"`
-	LineNumber int        `json:"lineNumber"`
+	RawLine        string               `json:"rawLine"`
+	Timestamp      *time.Time           `json:"timestamp,omitempty"`
+	RoleName       string               `json:"roleName"`
+	Content        string               `json:"content"`
+	IsOOC          bool                 `json:"isOoc"`
+	LineNumber     int                  `json:"lineNumber"`
+	DiceRolls      []*ParsedLogDiceRoll `json:"diceRolls,omitempty"`      // 结构化日志中携带的掷骰结果
+	IsWhisper      bool                 `json:"isWhisper,omitempty"`      // 悄悄话（SealChat 回导）
+	WhisperTargets []string             `json:"whisperTargets,omitempty"` // 悄悄话对象名称
+	IsArchived     bool                 `json:"isArchived,omitempty"`
+
+	// 以下字段仅在 SealChat 回导时使用
+	RichContent  string  `json:"-"` // 原始消息内容（富文本/HTML），已内含骰子胶囊
+	DisplayOrder float64 `json:"-"`
+	RoleColor    string  `json:"-"`
+	RoleAvatar   string  `json:"-"` // 附件引用，形如 id:xxx
+}
+
+// ParsedLogDiceRoll 结构化日志中解析出的掷骰结果
+type ParsedLogDiceRoll struct {
+	SourceText      string `json:"sourceText"` // 在 Content 中对应的原文，导入时替换为骰子胶囊
+	Formula         string `json:"formula"`
+	ResultDetail    string `json:"resultDetail"`
+	ResultValueText string `json:"resultValueText"`
+	ResultText      string `json:"resultText"`
+	IsError         bool   `json:"isError"`
+	RuleSystem      string `json:"ruleSystem,omitempty"`
+	Outcome         string `json:"outcome,omitempty"`
 }
 
 // ChatImportTemplate 内置正则模板
@@ -94,4 +116,5 @@ type ChatImportTemplate struct {
 	Description string `json:"description"`
 	Pattern     string `json:"pattern"`
 	Example     string `json:"example"`
+	Structured  bool   `json:"structured,omitempty"` // 结构化格式（非逐行正则）
 }
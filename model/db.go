@@ -131,8 +131,17 @@ func DBInit(cfg *utils.AppConfig) {
 	StartMessageVisibleCharCountBackfillWorker()
 	db.AutoMigrate(&MessageWhisperRecipientModel{})
 	db.AutoMigrate(&MessageDiceRollModel{})
+	db.AutoMigrate(&DiceFairnessWindowModel{})
+	db.AutoMigrate(&InitiativeTrackerModel{}, &InitiativeCombatantModel{})
 	db.AutoMigrate(&MessageEditHistoryModel{})
 	db.AutoMigrate(&MessageArchiveLogModel{})
+	db.AutoMigrate(&AuditLogModel{})
+	db.AutoMigrate(&ScheduledMessageModel{})
+	db.AutoMigrate(&MessageThreadReadModel{})
+	db.AutoMigrate(&PollModel{}, &PollVoteModel{})
+	db.AutoMigrate(&WebPushVAPIDKeyModel{}, &WebPushSubscriptionModel{})
+	db.AutoMigrate(&NotificationPreferenceModel{})
+	db.AutoMigrate(&MessageDraftModel{})
 	db.AutoMigrate(&MessageReactionModel{}, &MessageReactionCountModel{})
 	db.AutoMigrate(&UserModel{})
 	db.AutoMigrate(&AccessTokenModel{})
@@ -179,6 +188,8 @@ func DBInit(cfg *utils.AppConfig) {
 	db.AutoMigrate(&StickyNoteModel{}, &StickyNoteUserStateModel{}, &StickyNoteFolderModel{})
 	db.AutoMigrate(&EmailNotificationSettingsModel{}, &EmailNotificationLogModel{})
 	db.AutoMigrate(&EmailVerificationCodeModel{})
+	db.AutoMigrate(&UserTwoFactorModel{}, &UserTwoFactorChallengeModel{})
+	db.AutoMigrate(&UserOIDCIdentityModel{}, &OIDCLoginStateModel{}, &OIDCLoginTicketModel{})
 	db.AutoMigrate(&CaptchaCapChallengeModel{}, &CaptchaCapTokenModel{})
 	db.AutoMigrate(&UpdateCheckState{})
 	db.AutoMigrate(&ConfigCurrentModel{}, &ConfigHistoryModel{})
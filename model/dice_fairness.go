@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// DiceFairnessWindowModel 可验证骰点的种子窗口。窗口内的掷骰均由 Seed 派生，
+// 窗口开始时只公开 SeedHash 作为承诺，窗口结束后才公开 Seed 供任何人复算
+type DiceFairnessWindowModel struct {
+	StringPKBaseModel
+	ChannelID   string    `json:"channelId" gorm:"size:100;not null;uniqueIndex:udx_dice_fairness_channel_start,priority:1"`
+	WindowStart time.Time `json:"windowStart" gorm:"not null;uniqueIndex:udx_dice_fairness_channel_start,priority:2"`
+	WindowEnd   time.Time `json:"windowEnd" gorm:"not null"`
+	SeedHash    string    `json:"seedHash" gorm:"size:64;not null"` // sha256(seed) 的十六进制
+	Seed        string    `json:"-" gorm:"size:64;not null"`        // 十六进制种子，窗口结束前不得对外返回
+}
+
+func (*DiceFairnessWindowModel) TableName() string {
+	return "dice_fairness_windows"
+}
+
+// DiceFairnessWindowGetOrCreate 获取频道在 item.WindowStart 开始的窗口，不存在时以 item 创建；
+// 并发创建时以先写入者为准
+func DiceFairnessWindowGetOrCreate(item *DiceFairnessWindowModel) (*DiceFairnessWindowModel, error) {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error; err != nil {
+		return nil, err
+	}
+	var saved DiceFairnessWindowModel
+	if err := db.Where("channel_id = ? AND window_start = ?", item.ChannelID, item.WindowStart).
+		Limit(1).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DiceFairnessWindowGetByIDs 批量获取窗口，按 ID 索引
+func DiceFairnessWindowGetByIDs(ids []string) (map[string]*DiceFairnessWindowModel, error) {
+	result := map[string]*DiceFairnessWindowModel{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var items []*DiceFairnessWindowModel
+	if err := db.Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		result[item.ID] = item
+	}
+	return result, nil
+}
+
+// DiceFairnessWindowList 按开始时间倒序列出频道的窗口
+func DiceFairnessWindowList(channelID string, limit int) ([]*DiceFairnessWindowModel, error) {
+	var items []*DiceFairnessWindowModel
+	err := db.Where("channel_id = ?", channelID).
+		Order("window_start desc").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const diceLuckBatchSize = 5000
+
+// DiceLuckFilter 骰运统计筛选条件，ChannelIDs 必填
+type DiceLuckFilter struct {
+	ChannelIDs []string
+	UserID     string
+	IdentityID string
+	StartTime  *time.Time
+	EndTime    *time.Time
+}
+
+// DiceLuckRollRow 参与统计的单个掷骰及其所属消息的发送者信息
+type DiceLuckRollRow struct {
+	ID              string    `gorm:"column:id"`
+	Formula         string    `gorm:"column:formula"`
+	ResultDetail    string    `gorm:"column:result_detail"`
+	ResultValueText string    `gorm:"column:result_value_text"`
+	RuleSystem      string    `gorm:"column:rule_system"`
+	Outcome         string    `gorm:"column:outcome"`
+	ChannelID       string    `gorm:"column:channel_id"`
+	UserID          string    `gorm:"column:user_id"`
+	IdentityID      string    `gorm:"column:sender_identity_id"`
+	IdentityName    string    `gorm:"column:sender_identity_name"`
+	MemberName      string    `gorm:"column:sender_member_name"`
+	CreatedAt       time.Time `gorm:"column:created_at"`
+}
+
+// DiceLuckScanRolls 分批遍历符合条件的掷骰。悄悄话（含暗骰）、已删除或撤回的消息与出错的掷骰不参与统计
+func DiceLuckScanRolls(f DiceLuckFilter, handle func([]DiceLuckRollRow) error) error {
+	if len(f.ChannelIDs) == 0 {
+		return nil
+	}
+	selectClause := strings.Join([]string{
+		"r.id",
+		"r.formula",
+		"r.result_detail",
+		"r.result_value_text",
+		"r.rule_system",
+		"r.outcome",
+		"m.channel_id",
+		"m.user_id",
+		"m.sender_identity_id",
+		"m.sender_identity_name",
+		"m.sender_member_name",
+		"m.created_at",
+	}, ", ")
+
+	lastID := ""
+	for {
+		q := db.Table("message_dice_rolls AS r").
+			Select(selectClause).
+			Joins("JOIN messages AS m ON m.id = r.message_id").
+			Where("m.channel_id IN ?", f.ChannelIDs).
+			Where("m.is_deleted = ? AND (m.is_revoked = ? OR m.is_revoked IS NULL)", false, false).
+			Where("m.is_whisper = ?", false).
+			Where("r.is_error = ?", false)
+		if f.UserID != "" {
+			q = q.Where("m.user_id = ?", f.UserID)
+		}
+		if f.IdentityID != "" {
+			q = q.Where("m.sender_identity_id = ?", f.IdentityID)
+		}
+		if f.StartTime != nil {
+			q = q.Where("m.created_at >= ?", *f.StartTime)
+		}
+		if f.EndTime != nil {
+			q = q.Where("m.created_at <= ?", *f.EndTime)
+		}
+		if lastID != "" {
+			q = q.Where("r.id > ?", lastID)
+		}
+
+		var batch []DiceLuckRollRow
+		if err := q.Order("r.id ASC").Limit(diceLuckBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := handle(batch); err != nil {
+			return err
+		}
+		if len(batch) < diceLuckBatchSize {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// InitiativeTrackerModel 频道的先攻追踪器，每个频道至多一个
+type InitiativeTrackerModel struct {
+	StringPKBaseModel
+	ChannelID          string `json:"channelId" gorm:"size:100;uniqueIndex"`
+	Active             bool   `json:"active"` // 战斗是否进行中
+	Round              int    `json:"round"`  // 当前轮数，未开始时为 0
+	CurrentCombatantID string `json:"currentCombatantId" gorm:"size:100"`
+	Version            int    `json:"version"` // 每次修改递增，用于并发写入检测
+	UpdatedBy          string `json:"updatedBy" gorm:"size:100"`
+}
+
+func (*InitiativeTrackerModel) TableName() string {
+	return "initiative_trackers"
+}
+
+// InitiativeCondition 参战单位身上的状态，Rounds 为剩余轮数，0 表示持续到手动移除
+type InitiativeCondition struct {
+	Name   string `json:"name"`
+	Rounds int    `json:"rounds"`
+}
+
+// InitiativeCombatantModel 先攻表中的参战单位，可关联频道角色，也可以是临时 NPC
+type InitiativeCombatantModel struct {
+	StringPKBaseModel
+	ChannelID         string                        `json:"channelId" gorm:"size:100;index"`
+	Name              string                        `json:"name" gorm:"size:100"`
+	IdentityID        string                        `json:"identityId" gorm:"size:100"`
+	UserID            string                        `json:"userId" gorm:"size:100"` // 控制者：角色所属用户，NPC 为添加者
+	IsNPC             bool                          `json:"isNpc"`
+	HasInitiative     bool                          `json:"hasInitiative"`
+	Initiative        int                           `json:"initiative"`
+	InitiativeFormula string                        `json:"initiativeFormula" gorm:"size:255"`
+	InitiativeDetail  string                        `json:"initiativeDetail" gorm:"type:text"`
+	Tiebreak          int                           `json:"tiebreak"`  // 先攻相同时按此降序，如敏捷值
+	SortOrder         int                           `json:"sortOrder"` // 仍相同时按加入顺序
+	Conditions        JSONList[InitiativeCondition] `json:"conditions" gorm:"type:json"`
+}
+
+func (*InitiativeCombatantModel) TableName() string {
+	return "initiative_combatants"
+}
+
+// InitiativeTrackerGet 获取频道的先攻追踪器，不存在时返回 nil
+func InitiativeTrackerGet(tx *gorm.DB, channelID string) (*InitiativeTrackerModel, error) {
+	if tx == nil {
+		tx = db
+	}
+	var item InitiativeTrackerModel
+	if err := tx.Where("channel_id = ?", channelID).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// InitiativeCombatantList 按加入顺序列出频道的参战单位
+func InitiativeCombatantList(tx *gorm.DB, channelID string) ([]*InitiativeCombatantModel, error) {
+	if tx == nil {
+		tx = db
+	}
+	var items []*InitiativeCombatantModel
+	err := tx.Where("channel_id = ?", channelID).Order("sort_order ASC, created_at ASC").Find(&items).Error
+	return items, err
+}
@@ -23,6 +23,7 @@ type MessageModel struct {
 	MemberID         string  `json:"member_id" gorm:"null;size:100"`
 	UserID           string  `json:"user_id" gorm:"null;size:100;uniqueIndex:idx_msg_client_dedupe,priority:2"`
 	QuoteID          string  `json:"quote_id" gorm:"null;size:100"`
+	ThreadID         string  `json:"thread_id" gorm:"size:100;not null;default:'';index:idx_msg_thread"` // 所属话题的根消息ID，为空表示主时间线消息
 	DisplayOrder     float64 `json:"display_order" gorm:"type:decimal(24,8);index:idx_msg_channel_order,priority:2"`
 	ClientID         *string `json:"client_id,omitempty" gorm:"size:100;uniqueIndex:idx_msg_client_dedupe,priority:3"`
 	VisibleCharCount int     `json:"visible_char_count" gorm:"default:0;index:idx_msg_visible_char_count"`
@@ -57,6 +58,10 @@ type MessageModel struct {
 	IsImported    bool       `json:"isImported" gorm:"default:false;index:idx_msg_imported"`
 	ImportJobID   string     `json:"importJobId" gorm:"size:100;index:idx_msg_import_job_id"`
 
+	// 话题根消息的冗余统计
+	ThreadReplyCount  int        `json:"thread_reply_count" gorm:"default:0"`
+	ThreadLastReplyAt *time.Time `json:"thread_last_reply_at"`
+
 	SenderMemberName          string                        `json:"sender_member_name"` // 用户在当时的名字
 	SenderIdentityID          string                        `json:"sender_identity_id" gorm:"size:100"`
 	SenderIdentityVariantID   string                        `json:"sender_identity_variant_id" gorm:"size:100"`
@@ -154,30 +159,37 @@ func (m *MessageModel) ToProtocolType2(channelData *protocol.Channel) *protocol.
 	if m.PinnedAt != nil {
 		pinnedAt = m.PinnedAt.UnixMilli()
 	}
+	var threadLastReplyAt int64
+	if m.ThreadLastReplyAt != nil {
+		threadLastReplyAt = m.ThreadLastReplyAt.UnixMilli()
+	}
 	msg := &protocol.Message{
-		ID:               m.ID,
-		Content:          m.Content,
-		Channel:          channelData,
-		CreatedAt:        m.CreatedAt.UnixMilli(),
-		UpdatedAt:        updatedAt,
-		DisplayOrder:     m.DisplayOrder,
-		IsWhisper:        m.IsWhisper,
-		IsEdited:         m.IsEdited,
-		EditCount:        m.EditCount,
-		EditedByUserId:   m.EditedByUserID,
-		EditedByUserName: m.EditedByUserName,
-		IcMode:           icMode,
-		IsArchived:       m.IsArchived,
-		ArchivedAt:       archivedAt,
-		ArchivedBy:       m.ArchivedBy,
-		ArchiveReason:    m.ArchiveReason,
-		IsPinned:         m.IsPinned,
-		PinnedAt:         pinnedAt,
-		PinnedBy:         m.PinnedBy,
-		IsDeleted:        m.IsDeleted,
-		DeletedAt:        deletedAt,
-		DeletedBy:        m.DeletedBy,
-		WidgetData:       m.WidgetData,
+		ID:                m.ID,
+		Content:           m.Content,
+		Channel:           channelData,
+		CreatedAt:         m.CreatedAt.UnixMilli(),
+		UpdatedAt:         updatedAt,
+		DisplayOrder:      m.DisplayOrder,
+		IsWhisper:         m.IsWhisper,
+		IsEdited:          m.IsEdited,
+		EditCount:         m.EditCount,
+		EditedByUserId:    m.EditedByUserID,
+		EditedByUserName:  m.EditedByUserName,
+		IcMode:            icMode,
+		IsArchived:        m.IsArchived,
+		ArchivedAt:        archivedAt,
+		ArchivedBy:        m.ArchivedBy,
+		ArchiveReason:     m.ArchiveReason,
+		IsPinned:          m.IsPinned,
+		PinnedAt:          pinnedAt,
+		PinnedBy:          m.PinnedBy,
+		IsDeleted:         m.IsDeleted,
+		DeletedAt:         deletedAt,
+		DeletedBy:         m.DeletedBy,
+		WidgetData:        m.WidgetData,
+		ThreadID:          m.ThreadID,
+		ThreadReplyCount:  m.ThreadReplyCount,
+		ThreadLastReplyAt: threadLastReplyAt,
 		WhisperTo: func() *protocol.User {
 			if m.WhisperTarget != nil {
 				return m.WhisperTarget.ToProtocolType()
@@ -548,7 +560,8 @@ func MessagesCountByChannelIDsAfterTime(channelIDs []string, updateTimes []time.
 
 	query := db.Model(&MessageModel{}).
 		Select("channel_id, count(*) as count").
-		Where("user_id <> ?", userID)
+		Where("user_id <> ?", userID).
+		Where("thread_id = ''") // 话题回复单独计算未读
 
 	// 使用gorm的条件构建器
 	conditions := db.Where("1 = 0") // 初始为false的条件
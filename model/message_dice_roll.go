@@ -13,6 +13,10 @@ type MessageDiceRollModel struct {
 	ResultValueText string `json:"result_value_text" gorm:"type:text"`
 	ResultText      string `json:"result_text" gorm:"type:text"`
 	IsError         bool   `json:"is_error" gorm:"default:false"`
+	RuleSystem      string `json:"rule_system,omitempty" gorm:"size:16"`     // 检定所用规则系统，普通掷骰为空
+	Outcome         string `json:"outcome,omitempty" gorm:"size:32"`         // 检定结果等级，如 hard_success、fumble
+	FairWindowID    string `json:"fair_window_id,omitempty" gorm:"size:100"` // 可验证骰点模式下所用的种子窗口
+	FairInputs      string `json:"fair_inputs,omitempty" gorm:"type:text"`   // 复算所需的公开输入（JSON），如引用的角色卡属性值
 }
 
 func (*MessageDiceRollModel) TableName() string {
@@ -34,6 +38,31 @@ func MessageDiceRollListByMessageID(messageID string) ([]*MessageDiceRollModel,
 	return items, err
 }
 
+// MessageDiceRollListByMessageIDs 批量查询多条消息的掷骰结果，按消息ID分组
+func MessageDiceRollListByMessageIDs(messageIDs []string) (map[string][]*MessageDiceRollModel, error) {
+	result := make(map[string][]*MessageDiceRollModel)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	const chunkSize = 500
+	for start := 0; start < len(messageIDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(messageIDs) {
+			end = len(messageIDs)
+		}
+		var items []*MessageDiceRollModel
+		if err := db.Where("message_id IN ?", messageIDs[start:end]).
+			Order("roll_index asc").
+			Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			result[item.MessageID] = append(result[item.MessageID], item)
+		}
+	}
+	return result, nil
+}
+
 // MessageDiceRollReplace 将指定消息的掷骰结果重写为 rolls
 func MessageDiceRollReplace(messageID string, rolls []*MessageDiceRollModel) error {
 	return db.Transaction(func(tx *gorm.DB) error {
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageDraftModel 跨设备同步的输入框草稿，每个用户在每个频道仅保留一份。
+// 消息发出后草稿不会立即删除，而是清空内容并刷新时间戳，以便拒绝其他设备上更旧的保存请求
+type MessageDraftModel struct {
+	StringPKBaseModel
+	UserID          string    `json:"userId" gorm:"size:100;not null;uniqueIndex:udx_message_draft_user_channel,priority:1"`
+	ChannelID       string    `json:"channelId" gorm:"size:100;not null;uniqueIndex:udx_message_draft_user_channel,priority:2"`
+	Mode            string    `json:"mode" gorm:"size:16"` // plain | rich
+	Content         string    `json:"content" gorm:"type:text"`
+	Extra           string    `json:"extra" gorm:"type:text"`                           // 客户端附加状态（JSON），如悄悄话对象、IC/OOC
+	ClientUpdatedAt int64     `json:"clientUpdatedAt" gorm:"not null;default:0"`        // 客户端编辑时间（毫秒），用于冲突判断
+	ExpiresAt       time.Time `json:"expiresAt" gorm:"index:idx_message_draft_expires"` // 过期后由定期清理删除
+}
+
+func (*MessageDraftModel) TableName() string {
+	return "message_drafts"
+}
+
+// MessageDraftGet 获取用户在频道的草稿，不存在时返回 nil
+func MessageDraftGet(userID, channelID string) (*MessageDraftModel, error) {
+	var item MessageDraftModel
+	if err := db.Where("user_id = ? AND channel_id = ?", userID, channelID).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// MessageDraftSave 仅当 item.ClientUpdatedAt 晚于已保存版本时写入；
+// 返回最终生效的草稿以及本次写入是否被采纳
+func MessageDraftSave(item *MessageDraftModel) (*MessageDraftModel, bool, error) {
+	var current MessageDraftModel
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND channel_id = ?", item.UserID, item.ChannelID).Limit(1).Find(&current).Error; err != nil {
+			return err
+		}
+		if current.ID != "" {
+			if item.ClientUpdatedAt <= current.ClientUpdatedAt {
+				return nil
+			}
+			applied = true
+			return tx.Model(&MessageDraftModel{}).Where("id = ?", current.ID).Updates(map[string]any{
+				"mode":              item.Mode,
+				"content":           item.Content,
+				"extra":             item.Extra,
+				"client_updated_at": item.ClientUpdatedAt,
+				"expires_at":        item.ExpiresAt,
+				"updated_at":        time.Now(),
+			}).Error
+		}
+		applied = true
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if !applied {
+		return &current, false, nil
+	}
+	saved, err := MessageDraftGet(item.UserID, item.ChannelID)
+	if err != nil {
+		return nil, false, err
+	}
+	return saved, saved != nil && saved.ClientUpdatedAt == item.ClientUpdatedAt, nil
+}
+
+// MessageDraftClear 清空草稿内容，at 为清空时间（毫秒）
+func MessageDraftClear(userID, channelID string, at int64, expiresAt time.Time) error {
+	_, _, err := MessageDraftSave(&MessageDraftModel{
+		UserID:          userID,
+		ChannelID:       channelID,
+		ClientUpdatedAt: at,
+		ExpiresAt:       expiresAt,
+	})
+	return err
+}
+
+// MessageDraftCleanupExpired 删除已过期的草稿
+func MessageDraftCleanupExpired(now time.Time) (int64, error) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	tx := db.Where("expires_at < ?", now).Delete(&MessageDraftModel{})
+	return tx.RowsAffected, tx.Error
+}
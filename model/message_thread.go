@@ -0,0 +1,113 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// MessageThreadReadModel 记录用户在话题内的已读位置，存在记录即视为关注该话题
+type MessageThreadReadModel struct {
+	StringPKBaseModel
+	ThreadID   string `json:"threadId" gorm:"size:100;uniqueIndex:idx_thread_read_user,priority:1"`
+	UserID     string `json:"userId" gorm:"size:100;uniqueIndex:idx_thread_read_user,priority:2;index"`
+	ChannelID  string `json:"channelId" gorm:"size:100;index"`
+	LastReadAt int64  `json:"lastReadAt"` // 毫秒时间戳
+}
+
+func (*MessageThreadReadModel) TableName() string {
+	return "message_thread_reads"
+}
+
+// MessageThreadStats 话题统计
+type MessageThreadStats struct {
+	ReplyCount  int
+	LastReplyAt *time.Time
+}
+
+// MessageThreadRefreshStats 重新统计话题回复数并回写到根消息
+func MessageThreadRefreshStats(rootID string) (*MessageThreadStats, error) {
+	var count int64
+	if err := db.Model(&MessageModel{}).
+		Where("thread_id = ? AND is_deleted = ?", rootID, false).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats := &MessageThreadStats{ReplyCount: int(count)}
+	if count > 0 {
+		var last MessageModel
+		if err := db.Select("id, created_at").
+			Where("thread_id = ? AND is_deleted = ?", rootID, false).
+			Order("created_at desc").Limit(1).Find(&last).Error; err != nil {
+			return nil, err
+		}
+		if last.ID != "" {
+			at := last.CreatedAt
+			stats.LastReplyAt = &at
+		}
+	}
+	err := db.Model(&MessageModel{}).Where("id = ?", rootID).Updates(map[string]any{
+		"thread_reply_count":   stats.ReplyCount,
+		"thread_last_reply_at": stats.LastReplyAt,
+	}).Error
+	return stats, err
+}
+
+// MessageThreadReadInit 让用户关注话题，已关注时不改变已读位置
+func MessageThreadReadInit(channelID, threadID, userID string) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&MessageThreadReadModel{
+		ThreadID:  threadID,
+		UserID:    userID,
+		ChannelID: channelID,
+	}).Error
+}
+
+// MessageThreadReadSet 标记话题已读到 at
+func MessageThreadReadSet(channelID, threadID, userID string, at time.Time) error {
+	ms := at.UnixMilli()
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "thread_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{"last_read_at": ms, "updated_at": time.Now()}),
+	}).Create(&MessageThreadReadModel{
+		ThreadID:   threadID,
+		UserID:     userID,
+		ChannelID:  channelID,
+		LastReadAt: ms,
+	}).Error
+}
+
+// MessageThreadUnreadCounts 统计用户在频道内已关注话题的未读回复数，仅返回有未读的话题
+func MessageThreadUnreadCounts(channelID, userID string) (map[string]int64, error) {
+	var reads []*MessageThreadReadModel
+	if err := db.Where("channel_id = ? AND user_id = ?", channelID, userID).Find(&reads).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	if len(reads) == 0 {
+		return counts, nil
+	}
+
+	var results []struct {
+		ThreadID string
+		Count    int64
+	}
+	conditions := db.Where("1 = 0")
+	for _, read := range reads {
+		conditions = conditions.Or(db.Where("thread_id = ? AND created_at > ?", read.ThreadID, time.UnixMilli(read.LastReadAt)))
+	}
+	err := db.Model(&MessageModel{}).
+		Select("thread_id, count(*) as count").
+		Where("user_id <> ? AND is_deleted = ?", userID, false).
+		Where(conditions).
+		Group("thread_id").
+		Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range results {
+		if item.Count > 0 {
+			counts[item.ThreadID] = item.Count
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"sealchat/utils"
+)
+
+func initMessageThreadTestDB(t *testing.T) {
+	t.Helper()
+	cfg := &utils.AppConfig{
+		DSN: fmt.Sprintf("file:model-message-thread-%s?mode=memory&cache=shared", utils.NewID()),
+		SQLite: utils.SQLiteConfig{
+			EnableWAL:       false,
+			TxLockImmediate: false,
+			ReadConnections: 1,
+			OptimizeOnInit:  false,
+		},
+	}
+	DBInit(cfg)
+}
+
+func createMessageThreadTestMessage(t *testing.T, id, userID, threadID string, at time.Time) {
+	t.Helper()
+	msg := &MessageModel{
+		StringPKBaseModel: StringPKBaseModel{ID: id, CreatedAt: at, UpdatedAt: at},
+		ChannelID:         "ch-thread",
+		UserID:            userID,
+		ThreadID:          threadID,
+		Content:           id,
+		DisplayOrder:      float64(at.UnixMilli()),
+	}
+	if err := db.Create(msg).Error; err != nil {
+		t.Fatalf("create message %s failed: %v", id, err)
+	}
+}
+
+func TestMessageThreadStatsAndUnread(t *testing.T) {
+	initMessageThreadTestDB(t)
+	base := time.Now().Add(-time.Hour)
+
+	createMessageThreadTestMessage(t, "root", "alice", "", base)
+	createMessageThreadTestMessage(t, "reply-1", "bob", "root", base.Add(time.Minute))
+	createMessageThreadTestMessage(t, "reply-2", "carol", "root", base.Add(2*time.Minute))
+
+	stats, err := MessageThreadRefreshStats("root")
+	if err != nil {
+		t.Fatalf("refresh stats failed: %v", err)
+	}
+	if stats.ReplyCount != 2 || stats.LastReplyAt == nil || !stats.LastReplyAt.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	var root MessageModel
+	db.Where("id = ?", "root").Limit(1).Find(&root)
+	if root.ThreadReplyCount != 2 || root.ThreadLastReplyAt == nil {
+		t.Fatalf("root should carry thread stats, got count=%d last=%v", root.ThreadReplyCount, root.ThreadLastReplyAt)
+	}
+
+	// 话题回复不计入频道未读
+	counts, err := MessagesCountByChannelIDsAfterTime([]string{"ch-thread"}, []time.Time{base.Add(-time.Second)}, "dave")
+	if err != nil {
+		t.Fatalf("channel unread failed: %v", err)
+	}
+	if counts["ch-thread"] != 1 {
+		t.Fatalf("channel unread should only count the root, got %d", counts["ch-thread"])
+	}
+
+	// 未关注的话题没有未读
+	unread, err := MessageThreadUnreadCounts("ch-thread", "alice")
+	if err != nil || len(unread) != 0 {
+		t.Fatalf("unfollowed thread should have no unread, got %v err=%v", unread, err)
+	}
+
+	if err := MessageThreadReadInit("ch-thread", "root", "alice"); err != nil {
+		t.Fatalf("read init failed: %v", err)
+	}
+	unread, _ = MessageThreadUnreadCounts("ch-thread", "alice")
+	if unread["root"] != 2 {
+		t.Fatalf("followed thread should count all replies, got %v", unread)
+	}
+
+	if err := MessageThreadReadSet("ch-thread", "root", "alice", base.Add(90*time.Second)); err != nil {
+		t.Fatalf("read set failed: %v", err)
+	}
+	// 重复初始化不应覆盖已读位置
+	if err := MessageThreadReadInit("ch-thread", "root", "alice"); err != nil {
+		t.Fatalf("read init failed: %v", err)
+	}
+	unread, _ = MessageThreadUnreadCounts("ch-thread", "alice")
+	if unread["root"] != 1 {
+		t.Fatalf("expected 1 unread reply after partial read, got %v", unread)
+	}
+
+	// 自己的回复不计入未读
+	unread, _ = MessageThreadUnreadCounts("ch-thread", "carol")
+	if len(unread) != 0 {
+		t.Fatalf("carol has not followed the thread, got %v", unread)
+	}
+	_ = MessageThreadReadInit("ch-thread", "root", "carol")
+	unread, _ = MessageThreadUnreadCounts("ch-thread", "carol")
+	if unread["root"] != 1 {
+		t.Fatalf("own replies should be excluded, got %v", unread)
+	}
+}
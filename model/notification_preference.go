@@ -0,0 +1,198 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	NotificationScopeGlobal  = "global"
+	NotificationScopeWorld   = "world"
+	NotificationScopeChannel = "channel"
+
+	NotificationLevelAll      = "all"
+	NotificationLevelMentions = "mentions"
+	NotificationLevelNone     = "none"
+
+	// NotificationKindMessage 频道内的普通新消息
+	NotificationKindMessage = "message"
+	// NotificationKindMention 与用户直接相关的消息：@提及、悄悄话、回复
+	NotificationKindMention = "mention"
+)
+
+// NotificationPreferenceModel 用户在全局/世界/频道范围内的通知偏好
+// 通知级别按 频道 → 世界 → 全局 逐级继承，Level 为空表示沿用上级；
+// 免打扰（SnoozeUntil）与静默时段在任一范围生效即屏蔽通知
+type NotificationPreferenceModel struct {
+	StringPKBaseModel
+	UserID            string     `json:"userId" gorm:"size:100;uniqueIndex:udx_notification_pref_scope,priority:1"`
+	ScopeType         string     `json:"scopeType" gorm:"size:16;uniqueIndex:udx_notification_pref_scope,priority:2"`
+	ScopeID           string     `json:"scopeId" gorm:"size:100;uniqueIndex:udx_notification_pref_scope,priority:3"` // 全局范围为空字符串
+	Level             string     `json:"level" gorm:"size:16"`
+	SnoozeUntil       *time.Time `json:"snoozeUntil"`
+	QuietHoursEnabled bool       `json:"quietHoursEnabled"`
+	QuietHoursStart   int        `json:"quietHoursStart"` // 当天第几分钟，0-1439
+	QuietHoursEnd     int        `json:"quietHoursEnd"`
+	Timezone          string     `json:"timezone" gorm:"size:64"` // IANA 时区，空则使用服务器时区
+}
+
+func (*NotificationPreferenceModel) TableName() string {
+	return "notification_preferences"
+}
+
+func notificationPreferenceDB(tx *gorm.DB) *gorm.DB {
+	if tx == nil {
+		return db
+	}
+	return tx
+}
+
+// NotificationPreferenceList 获取用户的全部通知偏好
+func NotificationPreferenceList(userID string) ([]*NotificationPreferenceModel, error) {
+	var items []*NotificationPreferenceModel
+	err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&items).Error
+	return items, err
+}
+
+// NotificationPreferenceUpsert 按 (用户, 范围) 创建或覆盖偏好
+func NotificationPreferenceUpsert(item *NotificationPreferenceModel) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing NotificationPreferenceModel
+		if err := tx.Where("user_id = ? AND scope_type = ? AND scope_id = ?", item.UserID, item.ScopeType, item.ScopeID).
+			Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID == "" {
+			item.Init()
+			return tx.Create(item).Error
+		}
+		item.ID = existing.ID
+		item.CreatedAt = existing.CreatedAt
+		return tx.Model(&NotificationPreferenceModel{}).Where("id = ?", existing.ID).Updates(map[string]any{
+			"level":               item.Level,
+			"snooze_until":        item.SnoozeUntil,
+			"quiet_hours_enabled": item.QuietHoursEnabled,
+			"quiet_hours_start":   item.QuietHoursStart,
+			"quiet_hours_end":     item.QuietHoursEnd,
+			"timezone":            item.Timezone,
+			"updated_at":          time.Now(),
+		}).Error
+	})
+}
+
+// NotificationPreferenceDelete 删除某范围的偏好，恢复为继承上级
+func NotificationPreferenceDelete(userID, scopeType, scopeID string) error {
+	return db.Where("user_id = ? AND scope_type = ? AND scope_id = ?", userID, scopeType, scopeID).
+		Delete(&NotificationPreferenceModel{}).Error
+}
+
+// NotificationPolicy 某用户在某频道上生效的通知策略
+type NotificationPolicy struct {
+	Level       string
+	SnoozeUntil *time.Time
+	quietHours  []*NotificationPreferenceModel
+}
+
+// InQuietHours 判断 now 是否落在任一范围的静默时段内（支持跨午夜）
+func (p *NotificationPolicy) InQuietHours(now time.Time) bool {
+	for _, item := range p.quietHours {
+		if item.QuietHoursStart == item.QuietHoursEnd {
+			continue
+		}
+		local := now
+		if tz := strings.TrimSpace(item.Timezone); tz != "" {
+			if loc, err := time.LoadLocation(tz); err == nil {
+				local = now.In(loc)
+			}
+		}
+		minute := local.Hour()*60 + local.Minute()
+		if item.QuietHoursStart < item.QuietHoursEnd {
+			if minute >= item.QuietHoursStart && minute < item.QuietHoursEnd {
+				return true
+			}
+		} else if minute >= item.QuietHoursStart || minute < item.QuietHoursEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// Snoozed 判断是否处于免打扰期间
+func (p *NotificationPolicy) Snoozed(now time.Time) bool {
+	return p.SnoozeUntil != nil && now.Before(*p.SnoozeUntil)
+}
+
+// AllowsLevel 仅按通知级别判断，不考虑免打扰与静默时段
+func (p *NotificationPolicy) AllowsLevel(kind string) bool {
+	switch p.Level {
+	case NotificationLevelNone:
+		return false
+	case NotificationLevelMentions:
+		return kind == NotificationKindMention
+	default:
+		return true
+	}
+}
+
+// Allows 判断当前时刻是否应向用户发送该类通知
+func (p *NotificationPolicy) Allows(kind string, now time.Time) bool {
+	if !p.AllowsLevel(kind) {
+		return false
+	}
+	return !p.Snoozed(now) && !p.InQuietHours(now)
+}
+
+// NotificationPolicyResolve 合并全局、世界、频道三级偏好；worldID 可为空（私聊等）
+func NotificationPolicyResolve(tx *gorm.DB, userID, worldID, channelID string) (*NotificationPolicy, error) {
+	policy := &NotificationPolicy{Level: NotificationLevelAll}
+	if strings.TrimSpace(userID) == "" {
+		return policy, nil
+	}
+	query := notificationPreferenceDB(tx).Where("user_id = ?", userID).
+		Where(notificationPreferenceDB(tx).Where("scope_type = ? AND scope_id = ?", NotificationScopeGlobal, "").
+			Or("scope_type = ? AND scope_id = ?", NotificationScopeWorld, worldID).
+			Or("scope_type = ? AND scope_id = ?", NotificationScopeChannel, channelID))
+	var items []*NotificationPreferenceModel
+	if err := query.Find(&items).Error; err != nil {
+		return nil, err
+	}
+	levels := map[string]string{}
+	for _, item := range items {
+		if item.ScopeType == NotificationScopeWorld && strings.TrimSpace(worldID) == "" {
+			continue
+		}
+		if item.ScopeType == NotificationScopeChannel && strings.TrimSpace(channelID) == "" {
+			continue
+		}
+		if item.Level != "" {
+			levels[item.ScopeType] = item.Level
+		}
+		if item.SnoozeUntil != nil && (policy.SnoozeUntil == nil || item.SnoozeUntil.After(*policy.SnoozeUntil)) {
+			until := *item.SnoozeUntil
+			policy.SnoozeUntil = &until
+		}
+		if item.QuietHoursEnabled {
+			policy.quietHours = append(policy.quietHours, item)
+		}
+	}
+	for _, scope := range []string{NotificationScopeChannel, NotificationScopeWorld, NotificationScopeGlobal} {
+		if level, ok := levels[scope]; ok {
+			policy.Level = level
+			break
+		}
+	}
+	return policy, nil
+}
+
+// NotificationPolicyForChannel 根据频道查找所属世界后解析策略
+func NotificationPolicyForChannel(userID, channelID string) (*NotificationPolicy, error) {
+	worldID := ""
+	if len(channelID) < 30 {
+		if ch, err := ChannelGet(channelID); err == nil && ch != nil {
+			worldID = ch.WorldID
+		}
+	}
+	return NotificationPolicyResolve(nil, userID, worldID, channelID)
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"sealchat/utils"
+)
+
+func initNotificationPreferenceTestDB(t *testing.T) {
+	t.Helper()
+	cfg := &utils.AppConfig{
+		DSN: fmt.Sprintf("file:model-notification-pref-%s?mode=memory&cache=shared", utils.NewID()),
+		SQLite: utils.SQLiteConfig{
+			EnableWAL:       false,
+			TxLockImmediate: false,
+			ReadConnections: 1,
+			OptimizeOnInit:  false,
+		},
+	}
+	DBInit(cfg)
+}
+
+func upsertNotificationPreferenceForTest(t *testing.T, item *NotificationPreferenceModel) {
+	t.Helper()
+	item.UserID = "u-pref"
+	if err := NotificationPreferenceUpsert(item); err != nil {
+		t.Fatalf("upsert preference failed: %v", err)
+	}
+}
+
+func TestNotificationPolicyResolveInheritance(t *testing.T) {
+	initNotificationPreferenceTestDB(t)
+	now := time.Now()
+
+	policy, err := NotificationPolicyResolve(nil, "u-pref", "w-1", "ch-1")
+	if err != nil || policy.Level != NotificationLevelAll || !policy.Allows(NotificationKindMessage, now) {
+		t.Fatalf("default policy should allow everything: %+v err=%v", policy, err)
+	}
+
+	upsertNotificationPreferenceForTest(t, &NotificationPreferenceModel{ScopeType: NotificationScopeGlobal, Level: NotificationLevelMentions})
+	upsertNotificationPreferenceForTest(t, &NotificationPreferenceModel{ScopeType: NotificationScopeWorld, ScopeID: "w-1", Level: NotificationLevelNone})
+	upsertNotificationPreferenceForTest(t, &NotificationPreferenceModel{ScopeType: NotificationScopeChannel, ScopeID: "ch-1", Level: NotificationLevelAll})
+
+	cases := []struct {
+		world, channel, want string
+	}{
+		{"w-1", "ch-1", NotificationLevelAll},      // 频道覆盖世界
+		{"w-1", "ch-2", NotificationLevelNone},     // 继承世界
+		{"w-2", "ch-3", NotificationLevelMentions}, // 继承全局
+		{"", "private-ch", NotificationLevelMentions},
+	}
+	for _, tc := range cases {
+		policy, err := NotificationPolicyResolve(nil, "u-pref", tc.world, tc.channel)
+		if err != nil || policy.Level != tc.want {
+			t.Fatalf("world=%s channel=%s: expected %s, got %+v err=%v", tc.world, tc.channel, tc.want, policy, err)
+		}
+	}
+
+	// 频道 Level 为空时沿用上级，但仍可单独设置免打扰
+	until := now.Add(time.Hour)
+	upsertNotificationPreferenceForTest(t, &NotificationPreferenceModel{ScopeType: NotificationScopeChannel, ScopeID: "ch-1", SnoozeUntil: &until})
+	policy, _ = NotificationPolicyResolve(nil, "u-pref", "w-1", "ch-1")
+	if policy.Level != NotificationLevelNone || !policy.Snoozed(now) || policy.Snoozed(now.Add(2*time.Hour)) {
+		t.Fatalf("unexpected snooze policy: %+v", policy)
+	}
+	if policy.AllowsLevel(NotificationKindMention) {
+		t.Fatalf("level none should block mentions")
+	}
+
+	if err := NotificationPreferenceDelete("u-pref", NotificationScopeWorld, "w-1"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	policy, _ = NotificationPolicyResolve(nil, "u-pref", "w-1", "ch-1")
+	if policy.Level != NotificationLevelMentions || policy.Allows(NotificationKindMention, now) || !policy.Allows(NotificationKindMention, now.Add(2*time.Hour)) {
+		t.Fatalf("expected mentions level with snooze: %+v", policy)
+	}
+	if policy.Allows(NotificationKindMessage, now.Add(2*time.Hour)) {
+		t.Fatalf("mentions level should block ordinary messages")
+	}
+}
+
+func TestNotificationPolicyQuietHours(t *testing.T) {
+	initNotificationPreferenceTestDB(t)
+	upsertNotificationPreferenceForTest(t, &NotificationPreferenceModel{
+		ScopeType:         NotificationScopeGlobal,
+		Level:             NotificationLevelAll,
+		QuietHoursEnabled: true,
+		QuietHoursStart:   23 * 60,
+		QuietHoursEnd:     7 * 60,
+		Timezone:          "Asia/Shanghai",
+	})
+	policy, err := NotificationPolicyResolve(nil, "u-pref", "w-1", "ch-1")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	cases := map[string]bool{
+		"22:59": false,
+		"23:00": true,
+		"02:30": true,
+		"06:59": true,
+		"07:00": false,
+		"12:00": false,
+	}
+	for clock, want := range cases {
+		at, _ := time.ParseInLocation("2006-01-02 15:04", "2026-03-01 "+clock, loc)
+		// 以 UTC 传入，验证按偏好时区换算
+		if got := policy.InQuietHours(at.UTC()); got != want {
+			t.Fatalf("%s: expected quiet=%v, got %v", clock, want, got)
+		}
+		if got := policy.Allows(NotificationKindMention, at); got == want {
+			t.Fatalf("%s: quiet hours should block notifications", clock)
+		}
+	}
+}
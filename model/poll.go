@@ -0,0 +1,111 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PollModel 投票消息的元数据，投票选项与实时结果保存在消息的 WidgetData 中
+type PollModel struct {
+	StringPKBaseModel
+	MessageID string     `json:"messageId" gorm:"size:100;uniqueIndex"`
+	ChannelID string     `json:"channelId" gorm:"size:100;index"`
+	UserID    string     `json:"userId" gorm:"size:100"`
+	Multiple  bool       `json:"multiple"`
+	Anonymous bool       `json:"anonymous"`
+	ClosesAt  *time.Time `json:"closesAt" gorm:"index"`
+	ClosedAt  *time.Time `json:"closedAt" gorm:"index"`
+	ClosedBy  string     `json:"closedBy" gorm:"size:100"`
+}
+
+func (*PollModel) TableName() string {
+	return "polls"
+}
+
+// PollVoteModel 单个用户对某一选项的投票，多选时一人多条
+type PollVoteModel struct {
+	StringPKBaseModel
+	MessageID   string `json:"messageId" gorm:"size:100;uniqueIndex:idx_poll_vote,priority:1"`
+	UserID      string `json:"userId" gorm:"size:100;uniqueIndex:idx_poll_vote,priority:2"`
+	OptionIndex int    `json:"optionIndex" gorm:"uniqueIndex:idx_poll_vote,priority:3"`
+}
+
+func (*PollVoteModel) TableName() string {
+	return "poll_votes"
+}
+
+func PollCreate(tx *gorm.DB, item *PollModel) error {
+	if tx == nil {
+		tx = db
+	}
+	if item.ID == "" {
+		item.Init()
+	}
+	return tx.Create(item).Error
+}
+
+func PollGetByMessageID(tx *gorm.DB, messageID string) (*PollModel, error) {
+	if tx == nil {
+		tx = db
+	}
+	var item PollModel
+	if err := tx.Where("message_id = ?", messageID).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// PollListDue 获取已到截止时间但尚未关闭的投票
+func PollListDue(now time.Time, limit int) ([]*PollModel, error) {
+	var items []*PollModel
+	err := db.Where("closed_at IS NULL AND closes_at IS NOT NULL AND closes_at <= ?", now).
+		Order("closes_at ASC").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+// PollVoteReplace 用新的选项集合覆盖用户在该投票中的选择
+func PollVoteReplace(tx *gorm.DB, messageID, userID string, optionIndexes []int) error {
+	if err := tx.Where("message_id = ? AND user_id = ?", messageID, userID).Delete(&PollVoteModel{}).Error; err != nil {
+		return err
+	}
+	for _, idx := range optionIndexes {
+		vote := &PollVoteModel{MessageID: messageID, UserID: userID, OptionIndex: idx}
+		vote.Init()
+		if err := tx.Create(vote).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func PollVoteList(tx *gorm.DB, messageID string) ([]*PollVoteModel, error) {
+	if tx == nil {
+		tx = db
+	}
+	var items []*PollVoteModel
+	err := tx.Where("message_id = ?", messageID).Order("created_at ASC").Find(&items).Error
+	return items, err
+}
+
+// PollVoteMapByUser 查询用户在多条投票消息中的选择
+func PollVoteMapByUser(messageIDs []string, userID string) (map[string][]int, error) {
+	result := map[string][]int{}
+	if len(messageIDs) == 0 || userID == "" {
+		return result, nil
+	}
+	var items []*PollVoteModel
+	if err := db.Where("message_id IN ? AND user_id = ?", messageIDs, userID).
+		Order("option_index ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		result[item.MessageID] = append(result[item.MessageID], item.OptionIndex)
+	}
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	ScheduledMessageStatusActive    = "active"
+	ScheduledMessageStatusCompleted = "completed"
+	ScheduledMessageStatusCanceled  = "canceled"
+	ScheduledMessageStatusFailed    = "failed"
+)
+
+// ScheduledMessageModel 定时消息，CronExpr 为空时为一次性消息
+type ScheduledMessageModel struct {
+	StringPKBaseModel
+	ChannelID         string     `json:"channelId" gorm:"size:100;index"`
+	WorldID           string     `json:"worldId" gorm:"size:100"`
+	UserID            string     `json:"userId" gorm:"size:100;index"`
+	Content           string     `json:"content" gorm:"type:text"`
+	IdentityID        string     `json:"identityId" gorm:"size:100"`
+	IdentityVariantID string     `json:"identityVariantId" gorm:"size:100"`
+	ICMode            string     `json:"icMode" gorm:"size:8"`
+	CronExpr          string     `json:"cronExpr" gorm:"size:64"`
+	Timezone          string     `json:"timezone" gorm:"size:64"`
+	Note              string     `json:"note" gorm:"size:128"`
+	Status            string     `json:"status" gorm:"size:16;index:idx_scheduled_msg_due,priority:1"`
+	NextRunAt         *time.Time `json:"nextRunAt" gorm:"index:idx_scheduled_msg_due,priority:2"`
+	LastRunAt         *time.Time `json:"lastRunAt"`
+	LastMessageID     string     `json:"lastMessageId" gorm:"size:100"`
+	LastError         string     `json:"lastError" gorm:"size:255"`
+	RunCount          int        `json:"runCount"`
+	FailCount         int        `json:"failCount"` // 连续失败次数
+	Revision          int        `json:"revision"`  // 每次修改递增，一次性消息以此去重
+}
+
+func (*ScheduledMessageModel) TableName() string {
+	return "scheduled_messages"
+}
+
+func (m *ScheduledMessageModel) IsRecurring() bool {
+	return m.CronExpr != ""
+}
+
+func ScheduledMessageCreate(item *ScheduledMessageModel) error {
+	if item.ID == "" {
+		item.Init()
+	}
+	return db.Create(item).Error
+}
+
+func ScheduledMessageGet(id string) (*ScheduledMessageModel, error) {
+	var item ScheduledMessageModel
+	if err := db.Where("id = ?", id).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// ScheduledMessageListByChannel 列出频道内的定时消息，userID 为空时返回全部
+func ScheduledMessageListByChannel(channelID, userID string, includeFinished bool) ([]*ScheduledMessageModel, error) {
+	query := db.Where("channel_id = ?", channelID)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if !includeFinished {
+		query = query.Where("status = ?", ScheduledMessageStatusActive)
+	}
+	var items []*ScheduledMessageModel
+	err := query.Order("next_run_at IS NULL, next_run_at ASC").Order("created_at DESC").Limit(200).Find(&items).Error
+	return items, err
+}
+
+func ScheduledMessageCountActive(channelID, userID string) (int64, error) {
+	var count int64
+	err := db.Model(&ScheduledMessageModel{}).
+		Where("channel_id = ? AND user_id = ? AND status = ?", channelID, userID, ScheduledMessageStatusActive).
+		Count(&count).Error
+	return count, err
+}
+
+// ScheduledMessageListDue 获取已到期的定时消息
+func ScheduledMessageListDue(now time.Time, limit int) ([]*ScheduledMessageModel, error) {
+	var items []*ScheduledMessageModel
+	err := db.Where("status = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", ScheduledMessageStatusActive, now).
+		Order("next_run_at ASC").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+func ScheduledMessageUpdate(id string, values map[string]any) error {
+	return db.Model(&ScheduledMessageModel{}).Where("id = ?", id).Updates(values).Error
+}
+
+// ScheduledMessageClaim 以 next_run_at 为乐观锁推进下次执行时间，返回 false 表示已被修改或取消
+func ScheduledMessageClaim(id string, runAt time.Time, values map[string]any) (bool, error) {
+	res := db.Model(&ScheduledMessageModel{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", id, ScheduledMessageStatusActive, runAt).
+		Updates(values)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
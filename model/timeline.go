@@ -43,7 +43,22 @@ func updateTimelineByMention(userId, lastBeMentionedId string) (string, bool) {
 	db.Where("receiver_id = ? and created_at > ?", userId, createdAt).Order("created_at asc").Find(&items)
 
 	newItems := []*TimelineModel{}
+	// 频道通知级别为「不通知」时不写入提及时间线；免打扰与静默时段只影响推送，不影响收件箱
+	allowedByChannel := map[string]bool{}
 	for _, i := range items {
+		if i.LocPostType == "channel" {
+			allowed, ok := allowedByChannel[i.LocPostID]
+			if !ok {
+				allowed = true
+				if policy, err := NotificationPolicyForChannel(userId, i.LocPostID); err == nil {
+					allowed = policy.AllowsLevel(NotificationKindMention)
+				}
+				allowedByChannel[i.LocPostID] = allowed
+			}
+			if !allowed {
+				continue
+			}
+		}
 		newItems = append(newItems, &TimelineModel{
 			StringPKBaseModel: StringPKBaseModel{
 				ID: utils.NewID(),
@@ -59,7 +74,9 @@ func updateTimelineByMention(userId, lastBeMentionedId string) (string, bool) {
 
 	if len(items) > 0 {
 		// TODO: 后面sort一下再统一插入
-		db.CreateInBatches(newItems, 50)
+		if len(newItems) > 0 {
+			db.CreateInBatches(newItems, 50)
+		}
 		return items[len(items)-1].ID, true
 	}
 
@@ -42,7 +42,8 @@ type UserModel struct {
 	Disabled    bool              `json:"disabled"`
 	AccessToken *AccessTokenModel `gorm:"-" json:"-"`
 
-	RoleIds []string `json:"roleIds" gorm:"-"`
+	RoleIds          []string `json:"roleIds" gorm:"-"`
+	TwoFactorEnabled bool     `json:"twoFactorEnabled" gorm:"-"` // 是否已启用两步验证，仅在需要时填充
 	// Token          string `gorm:"index" json:"token"` // 令牌
 	// TokenExpiresAt int64  `json:"expiresAt"`
 	// RecentSentAt int64 `json:"recentSentAt"` // 最近发送消息的时间
@@ -78,8 +79,11 @@ func UserSetDisable(userId string, val bool) error {
 // AccessTokenModel access_token表
 type AccessTokenModel struct {
 	StringPKBaseModel
-	UserID    string    `json:"userID" gorm:"not null"`    // 用户ID，非空
-	ExpiredAt time.Time `json:"expiredAt" gorm:"not null"` // 过期时间，非空
+	UserID     string     `json:"userID" gorm:"not null"`    // 用户ID，非空
+	ExpiredAt  time.Time  `json:"expiredAt" gorm:"not null"` // 过期时间，非空
+	UserAgent  string     `json:"userAgent" gorm:"size:512"` // 登录时的客户端标识
+	IP         string     `json:"ip" gorm:"size:64"`         // 最近一次使用的 IP
+	LastSeenAt *time.Time `json:"lastSeenAt"`                // 最近一次使用时间
 }
 
 func (*AccessTokenModel) TableName() string {
@@ -255,12 +259,21 @@ func AcessTokenDeleteAllByUserID(userID string) error {
 
 // UserGenerateAccessToken 生成 access_token
 func UserGenerateAccessToken(userID string) (string, error) {
-	expiredAt := time.Now().Add(resolveAuthTokenMaxAgeDuration())
+	return UserGenerateAccessTokenWithMeta(userID, AccessTokenMeta{})
+}
+
+// UserGenerateAccessTokenWithMeta 生成 access_token，并记录登录设备信息
+func UserGenerateAccessTokenWithMeta(userID string, meta AccessTokenMeta) (string, error) {
+	now := time.Now()
+	expiredAt := now.Add(resolveAuthTokenMaxAgeDuration())
 
 	token := utils.NewID()
 	accessToken := &AccessTokenModel{
-		UserID:    userID,
-		ExpiredAt: expiredAt,
+		UserID:     userID,
+		ExpiredAt:  expiredAt,
+		UserAgent:  truncateRunes(strings.TrimSpace(meta.UserAgent), 512),
+		IP:         truncateRunes(strings.TrimSpace(meta.IP), 64),
+		LastSeenAt: &now,
 	}
 
 	accessToken.ID = token
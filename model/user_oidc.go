@@ -0,0 +1,208 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"sealchat/utils"
+)
+
+// UserOIDCIdentityModel 外部身份提供方账号与本地用户的关联
+type UserOIDCIdentityModel struct {
+	StringPKBaseModel
+	UserID      string     `gorm:"size:100;not null;index" json:"userId"`
+	Issuer      string     `gorm:"size:255;not null;uniqueIndex:idx_oidc_issuer_subject,priority:1" json:"issuer"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_oidc_issuer_subject,priority:2" json:"subject"`
+	Email       string     `gorm:"size:254" json:"email,omitempty"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+func (*UserOIDCIdentityModel) TableName() string {
+	return "user_oidc_identities"
+}
+
+// OIDCLoginStateModel 授权请求发出后等待回调的状态，保存 PKCE 校验值与 nonce
+type OIDCLoginStateModel struct {
+	StringPKBaseModel
+	StateHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Nonce        string     `gorm:"size:64;not null" json:"-"`
+	CodeVerifier string     `gorm:"size:128;not null" json:"-"`
+	RedirectPath string     `gorm:"size:512" json:"redirectPath"`
+	LinkUserID   string     `gorm:"size:100" json:"linkUserId,omitempty"` // 已登录用户发起的绑定操作
+	ExpiresAt    time.Time  `gorm:"not null" json:"expiresAt"`
+	ConsumedAt   *time.Time `json:"consumedAt,omitempty"`
+}
+
+func (*OIDCLoginStateModel) TableName() string {
+	return "oidc_login_states"
+}
+
+// OIDCLoginTicketModel 回调成功后交给前端换取登录凭证的一次性票据，避免凭证出现在地址栏
+type OIDCLoginTicketModel struct {
+	StringPKBaseModel
+	TicketHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UserID     string     `gorm:"size:100;not null;index" json:"userId"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	ConsumedAt *time.Time `json:"consumedAt,omitempty"`
+}
+
+func (*OIDCLoginTicketModel) TableName() string {
+	return "oidc_login_tickets"
+}
+
+const (
+	oidcLoginStateTTL  = 10 * time.Minute
+	oidcLoginTicketTTL = 2 * time.Minute
+)
+
+var (
+	ErrOIDCStateInvalid  = errors.New("登录请求已失效，请重新发起")
+	ErrOIDCTicketInvalid = errors.New("登录票据已失效，请重新登录")
+)
+
+func randomURLToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashOIDCToken(token string, scene string) string {
+	return hashVerificationCode(token, "oidc-"+scene)
+}
+
+func UserOIDCIdentityGet(issuer string, subject string) (*UserOIDCIdentityModel, error) {
+	var item UserOIDCIdentityModel
+	if err := db.Where("issuer = ? AND subject = ?", issuer, subject).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func UserOIDCIdentityListByUser(userID string) ([]*UserOIDCIdentityModel, error) {
+	var items []*UserOIDCIdentityModel
+	err := db.Where("user_id = ?", userID).Order("created_at asc").Find(&items).Error
+	return items, err
+}
+
+func UserOIDCIdentityCreate(userID string, issuer string, subject string, email string) (*UserOIDCIdentityModel, error) {
+	now := time.Now()
+	item := &UserOIDCIdentityModel{
+		UserID:      userID,
+		Issuer:      issuer,
+		Subject:     subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+	item.ID = utils.NewID()
+	if err := db.Create(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func UserOIDCIdentityTouch(id string, email string) error {
+	now := time.Now()
+	return db.Model(&UserOIDCIdentityModel{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"last_login_at": &now, "email": email}).Error
+}
+
+func UserOIDCIdentityDelete(userID string, id string) (bool, error) {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&UserOIDCIdentityModel{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// OIDCLoginStateCreate 保存授权请求状态，返回放入 state 参数的随机值
+func OIDCLoginStateCreate(nonce string, codeVerifier string, redirectPath string, linkUserID string) (string, error) {
+	state, err := randomURLToken(24)
+	if err != nil {
+		return "", err
+	}
+	_ = db.Where("expires_at < ?", time.Now()).Delete(&OIDCLoginStateModel{}).Error
+	item := &OIDCLoginStateModel{
+		StateHash:    hashOIDCToken(state, "state"),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		RedirectPath: redirectPath,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	}
+	item.ID = utils.NewID()
+	if err := db.Create(item).Error; err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// OIDCLoginStateConsume 取出并作废授权状态，每个 state 只能回调一次
+func OIDCLoginStateConsume(state string) (*OIDCLoginStateModel, error) {
+	var item OIDCLoginStateModel
+	if err := db.Where("state_hash = ? AND consumed_at IS NULL", hashOIDCToken(state, "state")).
+		Limit(1).
+		Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" || time.Now().After(item.ExpiresAt) {
+		return nil, ErrOIDCStateInvalid
+	}
+	now := time.Now()
+	result := db.Model(&OIDCLoginStateModel{}).
+		Where("id = ? AND consumed_at IS NULL", item.ID).
+		Update("consumed_at", &now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrOIDCStateInvalid
+	}
+	return &item, nil
+}
+
+func OIDCLoginTicketCreate(userID string) (string, error) {
+	ticket, err := randomURLToken(24)
+	if err != nil {
+		return "", err
+	}
+	_ = db.Where("expires_at < ?", time.Now()).Delete(&OIDCLoginTicketModel{}).Error
+	item := &OIDCLoginTicketModel{
+		TicketHash: hashOIDCToken(ticket, "ticket"),
+		UserID:     userID,
+		ExpiresAt:  time.Now().Add(oidcLoginTicketTTL),
+	}
+	item.ID = utils.NewID()
+	if err := db.Create(item).Error; err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// OIDCLoginTicketConsume 兑换一次性票据，返回对应用户 ID
+func OIDCLoginTicketConsume(ticket string) (string, error) {
+	var item OIDCLoginTicketModel
+	if err := db.Where("ticket_hash = ? AND consumed_at IS NULL", hashOIDCToken(ticket, "ticket")).
+		Limit(1).
+		Find(&item).Error; err != nil {
+		return "", err
+	}
+	if item.ID == "" || time.Now().After(item.ExpiresAt) {
+		return "", ErrOIDCTicketInvalid
+	}
+	now := time.Now()
+	result := db.Model(&OIDCLoginTicketModel{}).
+		Where("id = ? AND consumed_at IS NULL", item.ID).
+		Update("consumed_at", &now)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrOIDCTicketInvalid
+	}
+	return item.UserID, nil
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// accessTokenTouchInterval 最近使用时间的写入间隔，避免每个请求都更新数据库
+const accessTokenTouchInterval = time.Minute
+
+// AccessTokenMeta 签发 access_token 时记录的设备信息
+type AccessTokenMeta struct {
+	UserAgent string
+	IP        string
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit])
+}
+
+// AccessTokenSessionID 对外展示的会话 ID；token ID 本身是凭证的一部分，不能直接返回给前端
+func AccessTokenSessionID(tokenID string) string {
+	sum := sha256.Sum256([]byte("session:" + tokenID))
+	return hex.EncodeToString(sum[:12])
+}
+
+// AccessTokenListByUser 列出用户尚未过期的登录会话，最近使用的排在前面
+func AccessTokenListByUser(userID string) ([]*AccessTokenModel, error) {
+	var items []*AccessTokenModel
+	err := db.Where("user_id = ? AND expired_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Order("created_at desc").
+		Find(&items).Error
+	return items, err
+}
+
+// AccessTokenTouch 记录会话最近一次使用的时间与 IP
+func AccessTokenTouch(token *AccessTokenModel, ip string) {
+	if token == nil || token.ID == "" {
+		return
+	}
+	now := time.Now()
+	ip = truncateRunes(ip, 64)
+	if token.LastSeenAt != nil && now.Sub(*token.LastSeenAt) < accessTokenTouchInterval && (ip == "" || ip == token.IP) {
+		return
+	}
+	values := map[string]any{"last_seen_at": now}
+	if ip != "" {
+		values["ip"] = ip
+	}
+	if err := db.Model(&AccessTokenModel{}).Where("id = ?", token.ID).Updates(values).Error; err == nil {
+		token.LastSeenAt = &now
+		if ip != "" {
+			token.IP = ip
+		}
+	}
+}
+
+// AccessTokenDeleteBySessionIDs 按会话 ID 注销用户的登录会话，返回被删除的 token ID
+func AccessTokenDeleteBySessionIDs(userID string, sessionIDs []string) ([]string, error) {
+	if len(sessionIDs) == 0 {
+		return nil, nil
+	}
+	wanted := make(map[string]struct{}, len(sessionIDs))
+	for _, id := range sessionIDs {
+		wanted[id] = struct{}{}
+	}
+	var tokenIDs []string
+	if err := db.Model(&AccessTokenModel{}).Where("user_id = ?", userID).Pluck("id", &tokenIDs).Error; err != nil {
+		return nil, err
+	}
+	matched := make([]string, 0, len(sessionIDs))
+	for _, tokenID := range tokenIDs {
+		if _, ok := wanted[AccessTokenSessionID(tokenID)]; ok {
+			matched = append(matched, tokenID)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+	if err := db.Where("user_id = ? AND id IN ?", userID, matched).Delete(&AccessTokenModel{}).Error; err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// AccessTokenDeleteOthers 注销除当前会话以外的全部会话，返回被删除的 token ID
+func AccessTokenDeleteOthers(userID string, keepTokenID string) ([]string, error) {
+	var tokenIDs []string
+	if err := db.Model(&AccessTokenModel{}).
+		Where("user_id = ? AND id <> ?", userID, keepTokenID).
+		Pluck("id", &tokenIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(tokenIDs) == 0 {
+		return nil, nil
+	}
+	if err := db.Where("user_id = ? AND id IN ?", userID, tokenIDs).Delete(&AccessTokenModel{}).Error; err != nil {
+		return nil, err
+	}
+	return tokenIDs, nil
+}
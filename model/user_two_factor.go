@@ -0,0 +1,254 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealchat/utils"
+)
+
+// UserTwoFactorModel 用户的 TOTP 两步验证配置，每个用户至多一条
+type UserTwoFactorModel struct {
+	StringPKBaseModel
+	UserID            string     `gorm:"size:100;not null;uniqueIndex" json:"userId"`
+	Secret            string     `gorm:"size:64;not null" json:"-"` // Base32 编码的共享密钥
+	Enabled           bool       `gorm:"default:false" json:"enabled"`
+	EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+	LastUsedStep      int64      `gorm:"default:0" json:"-"` // 最近一次通过验证的时间步，用于防止重放
+	RecoveryCodesJSON string     `gorm:"type:text" json:"-"` // 恢复码哈希列表
+}
+
+func (*UserTwoFactorModel) TableName() string {
+	return "user_two_factors"
+}
+
+// RecoveryCodeHashes 返回尚未使用的恢复码哈希
+func (m *UserTwoFactorModel) RecoveryCodeHashes() []string {
+	var hashes []string
+	if m == nil || m.RecoveryCodesJSON == "" {
+		return hashes
+	}
+	_ = json.Unmarshal([]byte(m.RecoveryCodesJSON), &hashes)
+	return hashes
+}
+
+// UserTwoFactorChallengeModel 密码校验通过后等待第二步验证的登录挑战
+type UserTwoFactorChallengeModel struct {
+	StringPKBaseModel
+	UserID       string     `gorm:"size:100;not null;index" json:"userId"`
+	TokenHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expiresAt"`
+	ConsumedAt   *time.Time `json:"consumedAt,omitempty"`
+	AttemptCount int        `gorm:"default:0" json:"attemptCount"`
+	SignInIP     string     `gorm:"size:45" json:"-"`
+}
+
+func (*UserTwoFactorChallengeModel) TableName() string {
+	return "user_two_factor_challenges"
+}
+
+const (
+	twoFactorChallengeTTL         = 5 * time.Minute
+	twoFactorChallengeMaxAttempts = 5
+)
+
+var (
+	ErrTwoFactorChallengeInvalid     = errors.New("登录验证已失效，请重新登录")
+	ErrTwoFactorChallengeMaxAttempts = errors.New("验证码尝试次数过多，请重新登录")
+)
+
+func UserTwoFactorGet(userID string) (*UserTwoFactorModel, error) {
+	var item UserTwoFactorModel
+	err := db.Where("user_id = ?", userID).Limit(1).Find(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// UserTwoFactorEnabledMap 批量查询用户是否已启用两步验证
+func UserTwoFactorEnabledMap(userIDs []string) (map[string]bool, error) {
+	result := make(map[string]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var ids []string
+	if err := db.Model(&UserTwoFactorModel{}).
+		Where("user_id IN ? AND enabled = ?", userIDs, true).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+func UserTwoFactorIsEnabled(userID string) bool {
+	var count int64
+	db.Model(&UserTwoFactorModel{}).Where("user_id = ? AND enabled = ?", userID, true).Count(&count)
+	return count > 0
+}
+
+// UserTwoFactorSavePending 写入待确认的新密钥；已启用的配置不会被覆盖
+func UserTwoFactorSavePending(userID string, secret string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var item UserTwoFactorModel
+		if err := tx.Where("user_id = ?", userID).Limit(1).Find(&item).Error; err != nil {
+			return err
+		}
+		if item.ID == "" {
+			item = UserTwoFactorModel{UserID: userID, Secret: secret}
+			item.ID = utils.NewID()
+			return tx.Create(&item).Error
+		}
+		if item.Enabled {
+			return errors.New("两步验证已启用")
+		}
+		return tx.Model(&item).Updates(map[string]any{
+			"secret":              secret,
+			"last_used_step":      0,
+			"recovery_codes_json": "",
+		}).Error
+	})
+}
+
+func UserTwoFactorEnable(userID string, step int64, recoveryHashes []string) error {
+	data, err := json.Marshal(recoveryHashes)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return db.Model(&UserTwoFactorModel{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]any{
+			"enabled":             true,
+			"enabled_at":          &now,
+			"last_used_step":      step,
+			"recovery_codes_json": string(data),
+		}).Error
+}
+
+func UserTwoFactorSetRecoveryCodes(userID string, recoveryHashes []string) error {
+	data, err := json.Marshal(recoveryHashes)
+	if err != nil {
+		return err
+	}
+	return db.Model(&UserTwoFactorModel{}).
+		Where("user_id = ?", userID).
+		Update("recovery_codes_json", string(data)).Error
+}
+
+// UserTwoFactorMarkStepUsed 仅当时间步比上次更新时才记录，返回 false 表示验证码已被使用过
+func UserTwoFactorMarkStepUsed(userID string, step int64) (bool, error) {
+	result := db.Model(&UserTwoFactorModel{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UserTwoFactorConsumeRecoveryCode 以乐观锁方式移除一枚恢复码，避免并发重复使用
+func UserTwoFactorConsumeRecoveryCode(item *UserTwoFactorModel, codeHash string) (bool, error) {
+	hashes := item.RecoveryCodeHashes()
+	remaining := make([]string, 0, len(hashes))
+	found := false
+	for _, h := range hashes {
+		if !found && h == codeHash {
+			found = true
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	if !found {
+		return false, nil
+	}
+	data, err := json.Marshal(remaining)
+	if err != nil {
+		return false, err
+	}
+	result := db.Model(&UserTwoFactorModel{}).
+		Where("user_id = ? AND recovery_codes_json = ?", item.UserID, item.RecoveryCodesJSON).
+		Update("recovery_codes_json", string(data))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	item.RecoveryCodesJSON = string(data)
+	return true, nil
+}
+
+func UserTwoFactorDelete(userID string) error {
+	if err := db.Where("user_id = ?", userID).Delete(&UserTwoFactorChallengeModel{}).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userID).Delete(&UserTwoFactorModel{}).Error
+}
+
+func hashTwoFactorChallengeToken(token string) string {
+	return hashVerificationCode(token, "two-factor-challenge")
+}
+
+// UserTwoFactorChallengeCreate 创建登录挑战，返回交给客户端的一次性令牌
+func UserTwoFactorChallengeCreate(userID string, signInIP string) (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	// 顺带清理该用户已失效的挑战
+	_ = db.Where("user_id = ? AND (expires_at < ? OR consumed_at IS NOT NULL)", userID, time.Now()).
+		Delete(&UserTwoFactorChallengeModel{}).Error
+	item := &UserTwoFactorChallengeModel{
+		UserID:    userID,
+		TokenHash: hashTwoFactorChallengeToken(token),
+		ExpiresAt: time.Now().Add(twoFactorChallengeTTL),
+		SignInIP:  signInIP,
+	}
+	item.ID = utils.NewID()
+	if err := db.Create(item).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UserTwoFactorChallengeGet 取出有效的挑战并累加尝试次数
+func UserTwoFactorChallengeGet(token string) (*UserTwoFactorChallengeModel, error) {
+	var item UserTwoFactorChallengeModel
+	err := db.Where("token_hash = ? AND consumed_at IS NULL", hashTwoFactorChallengeToken(token)).
+		Limit(1).
+		Find(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	if item.ID == "" || time.Now().After(item.ExpiresAt) {
+		return nil, ErrTwoFactorChallengeInvalid
+	}
+	if item.AttemptCount >= twoFactorChallengeMaxAttempts {
+		return nil, ErrTwoFactorChallengeMaxAttempts
+	}
+	if err := db.Model(&item).Update("attempt_count", item.AttemptCount+1).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func UserTwoFactorChallengeConsume(id string) (bool, error) {
+	now := time.Now()
+	result := db.Model(&UserTwoFactorChallengeModel{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", &now)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const WebPushVAPIDKeyID = "main"
+
+// WebPushVAPIDKeyModel 服务端 VAPID 密钥对（单行）
+type WebPushVAPIDKeyModel struct {
+	ID         string    `gorm:"primaryKey;size:32" json:"id"`
+	PublicKey  string    `gorm:"size:128" json:"publicKey"` // base64url 编码的未压缩 P-256 公钥
+	PrivateKey string    `gorm:"type:text" json:"-"`        // base64url 编码的 PKCS#8 私钥
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (*WebPushVAPIDKeyModel) TableName() string {
+	return "web_push_vapid_keys"
+}
+
+// WebPushVAPIDKeyGet 获取已保存的 VAPID 密钥对，不存在时返回 nil
+func WebPushVAPIDKeyGet() (*WebPushVAPIDKeyModel, error) {
+	var item WebPushVAPIDKeyModel
+	if err := db.Where("id = ?", WebPushVAPIDKeyID).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// WebPushVAPIDKeyCreateIfAbsent 保存密钥对；并发初始化时以先写入者为准
+func WebPushVAPIDKeyCreateIfAbsent(publicKey, privateKey string) (*WebPushVAPIDKeyModel, error) {
+	item := &WebPushVAPIDKeyModel{
+		ID:         WebPushVAPIDKeyID,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		CreatedAt:  time.Now(),
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error; err != nil {
+		return nil, err
+	}
+	return WebPushVAPIDKeyGet()
+}
+
+// WebPushSubscriptionModel 用户某个浏览器/设备的推送订阅
+type WebPushSubscriptionModel struct {
+	StringPKBaseModel
+	UserID        string     `json:"userId" gorm:"size:100;index"`
+	EndpointHash  string     `json:"-" gorm:"size:64;uniqueIndex"`
+	Endpoint      string     `json:"-" gorm:"type:text"`
+	P256dh        string     `json:"-" gorm:"size:128"`
+	Auth          string     `json:"-" gorm:"size:64"`
+	UserAgent     string     `json:"userAgent" gorm:"size:255"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	FailCount     int        `json:"failCount"`
+}
+
+func (*WebPushSubscriptionModel) TableName() string {
+	return "web_push_subscriptions"
+}
+
+func WebPushEndpointHash(endpoint string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(endpoint)))
+	return hex.EncodeToString(sum[:])
+}
+
+// WebPushSubscriptionUpsert 按 endpoint 创建或更新订阅，同一设备换号登录时订阅归属新用户
+func WebPushSubscriptionUpsert(userID, endpoint, p256dh, auth, userAgent string) (*WebPushSubscriptionModel, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	hash := WebPushEndpointHash(endpoint)
+	var item WebPushSubscriptionModel
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_hash = ?", hash).Limit(1).Find(&item).Error; err != nil {
+			return err
+		}
+		if item.ID == "" {
+			item = WebPushSubscriptionModel{
+				UserID:       userID,
+				EndpointHash: hash,
+				Endpoint:     endpoint,
+				P256dh:       p256dh,
+				Auth:         auth,
+				UserAgent:    userAgent,
+			}
+			item.Init()
+			return tx.Create(&item).Error
+		}
+		item.UserID = userID
+		item.P256dh = p256dh
+		item.Auth = auth
+		item.UserAgent = userAgent
+		item.FailCount = 0
+		return tx.Model(&WebPushSubscriptionModel{}).Where("id = ?", item.ID).Updates(map[string]any{
+			"user_id":    userID,
+			"p256dh":     p256dh,
+			"auth":       auth,
+			"user_agent": userAgent,
+			"fail_count": 0,
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// WebPushSubscriptionDelete 删除用户的某个订阅
+func WebPushSubscriptionDelete(userID, endpoint string) error {
+	return db.Where("user_id = ? AND endpoint_hash = ?", userID, WebPushEndpointHash(endpoint)).
+		Delete(&WebPushSubscriptionModel{}).Error
+}
+
+func WebPushSubscriptionDeleteByID(id string) error {
+	return db.Where("id = ?", id).Delete(&WebPushSubscriptionModel{}).Error
+}
+
+func WebPushSubscriptionListByUser(userID string) ([]*WebPushSubscriptionModel, error) {
+	var items []*WebPushSubscriptionModel
+	err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&items).Error
+	return items, err
+}
+
+// WebPushSubscriptionListByUsers 批量获取多个用户的订阅
+func WebPushSubscriptionListByUsers(userIDs []string) ([]*WebPushSubscriptionModel, error) {
+	var items []*WebPushSubscriptionModel
+	if len(userIDs) == 0 {
+		return items, nil
+	}
+	err := db.Where("user_id IN ?", userIDs).Order("created_at ASC").Find(&items).Error
+	return items, err
+}
+
+// WebPushSubscriptionMarkResult 记录一次投递结果，成功时清零失败计数
+func WebPushSubscriptionMarkResult(id string, ok bool) error {
+	now := time.Now()
+	if ok {
+		return db.Model(&WebPushSubscriptionModel{}).Where("id = ?", id).Updates(map[string]any{
+			"last_success_at": now,
+			"fail_count":      0,
+		}).Error
+	}
+	return db.Model(&WebPushSubscriptionModel{}).Where("id = ?", id).
+		UpdateColumn("fail_count", gorm.Expr("fail_count + 1")).Error
+}
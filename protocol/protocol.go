@@ -13,8 +13,10 @@ type Channel struct {
 	ParentID                string      `json:"parent_id" gorm:"null"`
 	PermType                string      `json:"permType"`
 	DefaultDiceExpr         string      `json:"defaultDiceExpr,omitempty"`
+	DiceRuleSystem          string      `json:"diceRuleSystem"`
 	BotCommandPrefixes      []string    `json:"botCommandPrefixes,omitempty"`
 	BuiltInDiceEnabled      bool        `json:"builtInDiceEnabled"`
+	VerifiableDiceEnabled   bool        `json:"verifiableDiceEnabled"`
 	BotFeatureEnabled       bool        `json:"botFeatureEnabled"`
 	PrimaryBotID            string      `json:"primaryBotId,omitempty"`
 	EventBotIDs             []string    `json:"eventBotIds,omitempty"`
@@ -158,42 +160,45 @@ const (
 )
 
 type Message struct {
-	ID               string           `json:"id"`
-	MessageID        string           // Deprecated
-	Channel          *Channel         `json:"channel"`
-	Guild            *Guild           `json:"guild"`
-	User             *User            `json:"user"`
-	Identity         *MessageIdentity `json:"identity,omitempty"`
-	SenderRoleID     string           `json:"senderRoleId,omitempty"`
-	Member           *GuildMember     `json:"member"`
-	Content          string           `json:"content"`
-	WidgetData       string           `json:"widgetData,omitempty"`
-	Elements         []*Element       `json:"elements"`
-	Timestamp        int64            `json:"timestamp"`
-	Quote            *Message         `json:"quote"`
-	CreatedAt        int64            `json:"createdAt"`
-	UpdatedAt        int64            `json:"updatedAt"`
-	DisplayOrder     float64          `json:"displayOrder"`
-	IcMode           string           `json:"icMode"`
-	IsWhisper        bool             `json:"isWhisper"`
-	WhisperTo        *User            `json:"whisperTo"`
-	WhisperToIds     []*User          `json:"whisperToIds,omitempty"`
-	IsEdited         bool             `json:"isEdited"`
-	EditCount        int              `json:"editCount"`
-	EditedByUserId   string           `json:"editedByUserId,omitempty"`
-	EditedByUserName string           `json:"editedByUserName,omitempty"`
-	IsArchived       bool             `json:"isArchived"`
-	ArchivedAt       int64            `json:"archivedAt"`
-	ArchivedBy       string           `json:"archivedBy"`
-	ArchiveReason    string           `json:"archiveReason"`
-	IsPinned         bool             `json:"isPinned"`
-	PinnedAt         int64            `json:"pinnedAt"`
-	PinnedBy         string           `json:"pinnedBy"`
-	IsDeleted        bool             `json:"isDeleted"`
-	DeletedAt        int64            `json:"deletedAt"`
-	DeletedBy        string           `json:"deletedBy"`
-	ClientID         string           `json:"clientId,omitempty"`
-	WhisperMeta      *WhisperMeta     `json:"whisperMeta,omitempty"`
+	ID                string           `json:"id"`
+	MessageID         string           // Deprecated
+	Channel           *Channel         `json:"channel"`
+	Guild             *Guild           `json:"guild"`
+	User              *User            `json:"user"`
+	Identity          *MessageIdentity `json:"identity,omitempty"`
+	SenderRoleID      string           `json:"senderRoleId,omitempty"`
+	Member            *GuildMember     `json:"member"`
+	Content           string           `json:"content"`
+	WidgetData        string           `json:"widgetData,omitempty"`
+	Elements          []*Element       `json:"elements"`
+	Timestamp         int64            `json:"timestamp"`
+	Quote             *Message         `json:"quote"`
+	CreatedAt         int64            `json:"createdAt"`
+	UpdatedAt         int64            `json:"updatedAt"`
+	DisplayOrder      float64          `json:"displayOrder"`
+	IcMode            string           `json:"icMode"`
+	IsWhisper         bool             `json:"isWhisper"`
+	WhisperTo         *User            `json:"whisperTo"`
+	WhisperToIds      []*User          `json:"whisperToIds,omitempty"`
+	IsEdited          bool             `json:"isEdited"`
+	EditCount         int              `json:"editCount"`
+	EditedByUserId    string           `json:"editedByUserId,omitempty"`
+	EditedByUserName  string           `json:"editedByUserName,omitempty"`
+	IsArchived        bool             `json:"isArchived"`
+	ArchivedAt        int64            `json:"archivedAt"`
+	ArchivedBy        string           `json:"archivedBy"`
+	ArchiveReason     string           `json:"archiveReason"`
+	IsPinned          bool             `json:"isPinned"`
+	PinnedAt          int64            `json:"pinnedAt"`
+	PinnedBy          string           `json:"pinnedBy"`
+	IsDeleted         bool             `json:"isDeleted"`
+	DeletedAt         int64            `json:"deletedAt"`
+	DeletedBy         string           `json:"deletedBy"`
+	ClientID          string           `json:"clientId,omitempty"`
+	WhisperMeta       *WhisperMeta     `json:"whisperMeta,omitempty"`
+	ThreadID          string           `json:"threadId,omitempty"`
+	ThreadReplyCount  int              `json:"threadReplyCount,omitempty"`
+	ThreadLastReplyAt int64            `json:"threadLastReplyAt,omitempty"`
 }
 
 type MessageIdentity struct {
@@ -422,6 +427,16 @@ const (
 	// Character Remark Events
 	EventCharacterRemarkUpdated  EventName = "character-remark-updated"
 	EventCharacterRemarkSnapshot EventName = "character-remark-snapshot"
+	// 请求被限流，仅发送给触发限流的连接
+	EventRateLimited EventName = "rate-limited"
+	// 话题有新回复或统计变化
+	EventMessageThreadUpdated EventName = "message-thread-updated"
+	// 投票结束，附带最终结果
+	EventPollClosed EventName = "poll-closed"
+	// 消息被阅读（已读回执），仅发送给消息发送者
+	EventMessageRead EventName = "message-read"
+	// 先攻追踪器变化，附带完整的先攻表
+	EventInitiativeUpdated EventName = "initiative-updated"
 )
 
 // MessageContext 提供消息的上下文信息，用于 BOT 继承原消息属性
@@ -433,6 +448,81 @@ type MessageContext struct {
 	SenderUserID    string `json:"senderUserId,omitempty"`    // 原消息发送者ID
 }
 
+// MessageThreadEventPayload 话题活动事件载荷
+type MessageThreadEventPayload struct {
+	RootID          string `json:"rootId"`
+	ChannelID       string `json:"channelId"`
+	ReplyCount      int    `json:"replyCount"`
+	LastReplyAt     int64  `json:"lastReplyAt,omitempty"`
+	LastReplyID     string `json:"lastReplyId,omitempty"`
+	LastReplyUserID string `json:"lastReplyUserId,omitempty"`
+}
+
+// PollOptionResult 投票选项的最终票数
+type PollOptionResult struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// PollEventPayload 投票结束事件载荷
+type PollEventPayload struct {
+	MessageID   string              `json:"messageId"`
+	ChannelID   string              `json:"channelId"`
+	Question    string              `json:"question"`
+	Options     []*PollOptionResult `json:"options"`
+	TotalVoters int                 `json:"totalVoters"`
+	ClosedAt    int64               `json:"closedAt"`
+}
+
+// MessageReadEventPayload 已读回执事件载荷
+type MessageReadEventPayload struct {
+	ChannelID  string   `json:"channelId"`
+	UserID     string   `json:"userId"` // 阅读者
+	MessageIDs []string `json:"messageIds"`
+	ReadAt     int64    `json:"readAt"`
+}
+
+// InitiativeCondition 参战单位身上的状态
+type InitiativeCondition struct {
+	Name   string `json:"name"`
+	Rounds int    `json:"rounds"` // 剩余轮数，0 表示持续到手动移除
+}
+
+// InitiativeCombatant 先攻表中的参战单位
+type InitiativeCombatant struct {
+	ID                string                 `json:"id"`
+	Name              string                 `json:"name"`
+	IdentityID        string                 `json:"identityId,omitempty"`
+	UserID            string                 `json:"userId,omitempty"`
+	IsNPC             bool                   `json:"isNpc"`
+	Color             string                 `json:"color,omitempty"`
+	AvatarAttachment  string                 `json:"avatarAttachment,omitempty"`
+	HasInitiative     bool                   `json:"hasInitiative"`
+	Initiative        int                    `json:"initiative"`
+	InitiativeFormula string                 `json:"initiativeFormula,omitempty"`
+	InitiativeDetail  string                 `json:"initiativeDetail,omitempty"`
+	Tiebreak          int                    `json:"tiebreak"`
+	Conditions        []*InitiativeCondition `json:"conditions"`
+}
+
+// InitiativeTracker 频道先攻表快照，Combatants 已按行动顺序排列
+type InitiativeTracker struct {
+	ChannelID          string                 `json:"channelId"`
+	Active             bool                   `json:"active"`
+	Round              int                    `json:"round"`
+	CurrentCombatantID string                 `json:"currentCombatantId,omitempty"`
+	Version            int                    `json:"version"`
+	UpdatedAt          int64                  `json:"updatedAt,omitempty"`
+	Combatants         []*InitiativeCombatant `json:"combatants"`
+}
+
+// InitiativeEventPayload 先攻追踪器事件载荷
+type InitiativeEventPayload struct {
+	ChannelID string             `json:"channelId"`
+	Action    string             `json:"action"` // 触发变化的操作，如 next、combatant.add
+	Tracker   *InitiativeTracker `json:"tracker"`
+}
+
 type MessageReactionEvent struct {
 	MessageID string `json:"messageId"`
 	Emoji     string `json:"emoji"`
@@ -472,6 +562,11 @@ type Event struct {
 	CharacterRemarkSnapshot    *CharacterRemarkSnapshotPayload    `json:"characterRemarkSnapshot,omitempty"`
 	MessageContext             *MessageContext                    `json:"messageContext,omitempty"`
 	MessageReaction            *MessageReactionEvent              `json:"messageReaction,omitempty"`
+	RateLimit                  *RateLimitEventPayload             `json:"rateLimit,omitempty"`
+	Thread                     *MessageThreadEventPayload         `json:"thread,omitempty"`
+	Poll                       *PollEventPayload                  `json:"poll,omitempty"`
+	MessageRead                *MessageReadEventPayload           `json:"messageRead,omitempty"`
+	Initiative                 *InitiativeEventPayload            `json:"initiative,omitempty"`
 	IsInteractiveUpdate        bool                               `json:"is_interactive_update,omitempty"`
 }
 
@@ -530,6 +625,8 @@ type GatewayBody struct {
 	Identify struct {
 		Token    string
 		Sequence int
+		// Epoch 为上次 Ready 返回的事件序号纪元，与 Sequence 一起用于断线重连补发
+		Epoch string
 	}
 	Ready struct {
 		Logins []Login
@@ -667,3 +764,14 @@ type CharacterRemarkEventPayload struct {
 type CharacterRemarkSnapshotPayload struct {
 	Items []*CharacterRemarkEventPayload `json:"items,omitempty"`
 }
+
+// RateLimitEventPayload 限流拒绝事件载荷
+type RateLimitEventPayload struct {
+	Route        string  `json:"route"`               // 触发限流的接口，如 message_create
+	Scope        string  `json:"scope"`               // 命中的维度：user / ip
+	ChannelID    string  `json:"channelId,omitempty"` // 相关频道
+	ClientID     string  `json:"clientId,omitempty"`  // 被拒绝消息的客户端 ID，便于前端标记发送失败
+	RetryAfterMs int64   `json:"retryAfterMs"`        // 建议等待时长
+	Burst        int     `json:"burst,omitempty"`     // 桶容量
+	PerMinute    float64 `json:"perMinute,omitempty"` // 每分钟补充速率
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"sealchat/model"
+	"sealchat/service/metrics/telemetry"
 	"sealchat/utils"
 )
 
@@ -133,5 +134,8 @@ func RunTaskWithBilling(ctx context.Context, input BilledRunInput) (BilledRunOut
 		return BilledRunOutput{}, err
 	}
 	settled = true
+	telemetry.AIBilledTokens.Add(float64(result.Usage.PromptTokens), result.FeatureKey, "prompt")
+	telemetry.AIBilledTokens.Add(float64(result.Usage.CompletionTokens), result.FeatureKey, "completion")
+	telemetry.AIBilledTokens.Add(float64(result.Usage.CacheTokens), result.FeatureKey, "cache")
 	return BilledRunOutput{Result: result, Billed: true}, nil
 }
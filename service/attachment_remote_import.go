@@ -143,18 +143,61 @@ func ImportAttachmentFromURL(input RemoteAttachmentImportInput) (*model.Attachme
 		filename = "remote-attachment"
 	}
 
-	hashBytes := hasher.Sum(nil)
-	location, err := PersistAttachmentFile(hashBytes, total, tempPath, contentType)
+	return persistImportedAttachment(hasher.Sum(nil), total, tempPath, contentType, filename, input.UserID, input.ChannelID)
+}
+
+// ImportAttachmentFromBytes 将内存中的文件数据保存为附件，用于回导导出文件中内嵌的附件
+func ImportAttachmentFromBytes(data []byte, filename string, contentType string, userID string, channelID string) (*model.AttachmentModel, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, errors.New("缺少用户 ID")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("附件为空")
+	}
+	if GetStorageManager() == nil {
+		return nil, errors.New("存储服务未初始化")
+	}
+
+	tempFile, err := os.CreateTemp("", "sealchat-import-attachment-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer func() {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+	}()
+	if _, err := tempFile.Write(data); err != nil {
+		return nil, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return nil, fmt.Errorf("关闭临时文件失败: %w", err)
+	}
+
+	contentType = strings.TrimSpace(contentType)
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	filename = strings.TrimSpace(filename)
+	if filename == "" {
+		filename = "imported-attachment"
+	}
+	digest := sha256.Sum256(data)
+	return persistImportedAttachment(digest[:], int64(len(data)), tempPath, contentType, filename, userID, channelID)
+}
+
+func persistImportedAttachment(hashBytes []byte, size int64, tempPath string, contentType string, filename string, userID string, channelID string) (*model.AttachmentModel, error) {
+	location, err := PersistAttachmentFile(hashBytes, size, tempPath, contentType)
 	if err != nil {
 		return nil, err
 	}
 	_, item := model.AttachmentCreate(&model.AttachmentModel{
 		Filename:    filename,
-		Size:        total,
+		Size:        size,
 		Hash:        hashBytes,
 		MimeType:    contentType,
-		UserID:      strings.TrimSpace(input.UserID),
-		ChannelID:   strings.TrimSpace(input.ChannelID),
+		UserID:      strings.TrimSpace(userID),
+		ChannelID:   strings.TrimSpace(channelID),
 		StorageType: location.StorageType,
 		ObjectKey:   location.ObjectKey,
 		ExternalURL: location.ExternalURL,
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+// auditSnapshotMaxBytes 单个快照的最大长度，超出时只保留字段差异
+const auditSnapshotMaxBytes = 32 * 1024
+
+// AuditEntry 一条待记录的管理操作，Before/After 会序列化为 JSON 快照并计算逐字段差异
+type AuditEntry struct {
+	ActorID    string
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   string
+	TargetName string
+	WorldID    string
+	ChannelID  string
+	IP         string
+	Before     any
+	After      any
+	// OmitSnapshots 为 true 时不保存完整快照，只保存差异，用于配置等体积较大的对象
+	OmitSnapshots bool
+}
+
+// AuditChange 单个字段的变更，嵌套字段以 . 连接
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// AuditLogItem 审计日志的展示结构
+type AuditLogItem struct {
+	*model.AuditLogModel
+	Before  json.RawMessage `json:"before,omitempty"`
+	After   json.RawMessage `json:"after,omitempty"`
+	Changes []AuditChange   `json:"changes"`
+}
+
+type AuditLogListResult struct {
+	Items    []*AuditLogItem `json:"items"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+	Total    int64           `json:"total"`
+}
+
+// AuditRecord 写入审计日志；失败只记录日志，不影响业务操作
+func AuditRecord(entry AuditEntry) {
+	if err := auditRecord(entry); err != nil {
+		log.Printf("[audit] 记录 %s 失败: %v", entry.Action, err)
+	}
+}
+
+func auditRecord(entry AuditEntry) error {
+	beforeJSON, beforeValue, err := auditNormalize(entry.Before)
+	if err != nil {
+		return err
+	}
+	afterJSON, afterValue, err := auditNormalize(entry.After)
+	if err != nil {
+		return err
+	}
+	changes := AuditDiff(beforeValue, afterValue)
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	if entry.OmitSnapshots || len(beforeJSON) > auditSnapshotMaxBytes {
+		beforeJSON = ""
+	}
+	if entry.OmitSnapshots || len(afterJSON) > auditSnapshotMaxBytes {
+		afterJSON = ""
+	}
+
+	actorName := strings.TrimSpace(entry.ActorName)
+	if actorName == "" && entry.ActorID != "" {
+		if actor := model.UserGet(entry.ActorID); actor != nil {
+			actorName = auditUserDisplayName(actor)
+		}
+	}
+	return model.AuditLogCreate(&model.AuditLogModel{
+		ActorID:     entry.ActorID,
+		ActorName:   truncateAuditText(actorName, 128),
+		Action:      entry.Action,
+		TargetType:  entry.TargetType,
+		TargetID:    entry.TargetID,
+		TargetName:  truncateAuditText(entry.TargetName, 128),
+		WorldID:     entry.WorldID,
+		ChannelID:   entry.ChannelID,
+		IP:          truncateAuditText(entry.IP, 64),
+		BeforeJSON:  beforeJSON,
+		AfterJSON:   afterJSON,
+		ChangesJSON: string(changesJSON),
+	})
+}
+
+// auditNormalize 将任意值转为 JSON 文本及其通用结构，便于比较
+func auditNormalize(value any) (string, any, error) {
+	if value == nil {
+		return "", nil, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return "", nil, err
+	}
+	return string(raw), generic, nil
+}
+
+// AuditDiff 比较两个 JSON 通用结构，对象逐层展开，数组与标量整体比较
+func AuditDiff(before, after any) []AuditChange {
+	flatBefore := map[string]any{}
+	flatAfter := map[string]any{}
+	flattenAuditValue("", before, flatBefore)
+	flattenAuditValue("", after, flatAfter)
+
+	fields := make([]string, 0, len(flatBefore)+len(flatAfter))
+	for key := range flatBefore {
+		fields = append(fields, key)
+	}
+	for key := range flatAfter {
+		if _, ok := flatBefore[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []AuditChange{}
+	for _, field := range fields {
+		b, a := flatBefore[field], flatAfter[field]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes = append(changes, AuditChange{Field: field, Before: b, After: a})
+	}
+	return changes
+}
+
+func flattenAuditValue(prefix string, value any, out map[string]any) {
+	obj, ok := value.(map[string]any)
+	if !ok || (len(obj) == 0 && prefix != "") {
+		if prefix != "" || value != nil {
+			out[prefix] = value
+		}
+		return
+	}
+	for key, item := range obj {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		flattenAuditValue(path, item, out)
+	}
+}
+
+func auditUserDisplayName(user *model.UserModel) string {
+	if user == nil {
+		return ""
+	}
+	if name := strings.TrimSpace(user.Nickname); name != "" {
+		return name
+	}
+	return user.Username
+}
+
+// AuditUserDisplayName 用于填充审计日志中的用户名称
+func AuditUserDisplayName(userID string) string {
+	if strings.TrimSpace(userID) == "" {
+		return ""
+	}
+	return auditUserDisplayName(model.UserGet(userID))
+}
+
+func truncateAuditText(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit])
+}
+
+// AuditLogList 按条件查询审计日志
+func AuditLogList(q model.AuditLogQuery) (*AuditLogListResult, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 || q.PageSize > 200 {
+		q.PageSize = 20
+	}
+	items, total, err := model.AuditLogList(q)
+	if err != nil {
+		return nil, err
+	}
+	result := &AuditLogListResult{
+		Items:    make([]*AuditLogItem, 0, len(items)),
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Total:    total,
+	}
+	for _, item := range items {
+		view := &AuditLogItem{AuditLogModel: item, Changes: []AuditChange{}}
+		if item.BeforeJSON != "" {
+			view.Before = json.RawMessage(item.BeforeJSON)
+		}
+		if item.AfterJSON != "" {
+			view.After = json.RawMessage(item.AfterJSON)
+		}
+		if item.ChangesJSON != "" {
+			_ = json.Unmarshal([]byte(item.ChangesJSON), &view.Changes)
+		}
+		result.Items = append(result.Items, view)
+	}
+	return result, nil
+}
+
+// AuditLogListForWorld 世界拥有者查看本世界的审计日志，平台管理员不受限
+func AuditLogListForWorld(worldID, userID string, q model.AuditLogQuery) (*AuditLogListResult, error) {
+	worldID = strings.TrimSpace(worldID)
+	if worldID == "" {
+		return nil, ErrWorldNotFound
+	}
+	if !IsWorldOwner(worldID, userID) && !pm.CanWithSystemRole(userID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	q.WorldID = worldID
+	return AuditLogList(q)
+}
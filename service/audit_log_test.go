@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+func TestAuditDiffFlattensNestedObjects(t *testing.T) {
+	before := map[string]any{
+		"name":  "old",
+		"perms": []any{"a", "b"},
+		"rateLimit": map[string]any{
+			"enabled": false,
+			"routes":  map[string]any{"message": map[string]any{"burst": float64(10)}},
+		},
+		"removed": "x",
+	}
+	after := map[string]any{
+		"name":  "old",
+		"perms": []any{"a"},
+		"rateLimit": map[string]any{
+			"enabled": true,
+			"routes":  map[string]any{"message": map[string]any{"burst": float64(20)}},
+		},
+		"added": float64(1),
+	}
+
+	got := AuditDiff(before, after)
+	want := []AuditChange{
+		{Field: "added", Before: nil, After: float64(1)},
+		{Field: "perms", Before: []any{"a", "b"}, After: []any{"a"}},
+		{Field: "rateLimit.enabled", Before: false, After: true},
+		{Field: "rateLimit.routes.message.burst", Before: float64(10), After: float64(20)},
+		{Field: "removed", Before: "x", After: nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected diff:\n got=%#v\nwant=%#v", got, want)
+	}
+
+	if changes := AuditDiff("member", nil); len(changes) != 1 || changes[0].Field != "" {
+		t.Fatalf("scalar diff should produce a single root change, got %#v", changes)
+	}
+}
+
+func TestAuditRecordAndListFilters(t *testing.T) {
+	initTestDB(t)
+
+	AuditRecord(AuditEntry{
+		ActorID:    "admin-1",
+		ActorName:  "Admin",
+		Action:     model.AuditActionUserDisable,
+		TargetType: model.AuditTargetUser,
+		TargetID:   "user-1",
+		TargetName: "Alice",
+		Before:     map[string]any{"disabled": false},
+		After:      map[string]any{"disabled": true},
+	})
+	time.Sleep(2 * time.Millisecond)
+	AuditRecord(AuditEntry{
+		ActorID:    "owner-1",
+		ActorName:  "Owner",
+		Action:     model.AuditActionWorldMemberRole,
+		TargetType: model.AuditTargetUser,
+		TargetID:   "user-2",
+		TargetName: "Bob",
+		WorldID:    "world-1",
+		Before:     map[string]any{"role": "member"},
+		After:      map[string]any{"role": "admin"},
+	})
+	time.Sleep(2 * time.Millisecond)
+	AuditRecord(AuditEntry{
+		ActorID:       "admin-1",
+		ActorName:     "Admin",
+		Action:        model.AuditActionConfigUpdate,
+		TargetType:    model.AuditTargetConfig,
+		TargetID:      "app",
+		Before:        map[string]any{"pageTitle": "A"},
+		After:         map[string]any{"pageTitle": "B"},
+		OmitSnapshots: true,
+	})
+
+	all, err := AuditLogList(model.AuditLogQuery{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if all.Total != 3 || len(all.Items) != 3 {
+		t.Fatalf("expected 3 records, got total=%d items=%d", all.Total, len(all.Items))
+	}
+	if all.Items[0].Action != model.AuditActionConfigUpdate {
+		t.Fatalf("records should be newest first, got %s", all.Items[0].Action)
+	}
+	if all.Items[0].Before != nil || all.Items[0].After != nil {
+		t.Fatalf("config snapshots should be omitted")
+	}
+	if len(all.Items[0].Changes) != 1 || all.Items[0].Changes[0].Field != "pageTitle" {
+		t.Fatalf("unexpected config changes: %#v", all.Items[0].Changes)
+	}
+
+	prefixed, err := AuditLogList(model.AuditLogQuery{Action: "user."})
+	if err != nil {
+		t.Fatalf("list by prefix failed: %v", err)
+	}
+	if prefixed.Total != 1 || prefixed.Items[0].TargetName != "Alice" {
+		t.Fatalf("unexpected prefix result: %+v", prefixed.Items)
+	}
+	if string(prefixed.Items[0].After) != `{"disabled":true}` {
+		t.Fatalf("unexpected after snapshot: %s", prefixed.Items[0].After)
+	}
+
+	byWorld, err := AuditLogList(model.AuditLogQuery{WorldID: "world-1"})
+	if err != nil {
+		t.Fatalf("list by world failed: %v", err)
+	}
+	if byWorld.Total != 1 || byWorld.Items[0].TargetID != "user-2" {
+		t.Fatalf("unexpected world result: %+v", byWorld.Items)
+	}
+
+	byKeyword, err := AuditLogList(model.AuditLogQuery{Keyword: "bob"})
+	if err != nil {
+		t.Fatalf("list by keyword failed: %v", err)
+	}
+	if byKeyword.Total != 1 {
+		t.Fatalf("keyword should match target name, got %d", byKeyword.Total)
+	}
+}
+
+func TestAuditLogListForWorldRequiresOwner(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	db := model.GetDB()
+
+	worldID := "audit-world"
+	for _, member := range []*model.WorldMemberModel{
+		{WorldID: worldID, UserID: "owner", Role: model.WorldRoleOwner},
+		{WorldID: worldID, UserID: "admin", Role: model.WorldRoleAdmin},
+	} {
+		member.Init()
+		member.JoinedAt = time.Now()
+		if err := db.Create(member).Error; err != nil {
+			t.Fatalf("create member failed: %v", err)
+		}
+	}
+	AuditRecord(AuditEntry{
+		ActorID:    "admin",
+		Action:     model.AuditActionWorldMemberRemove,
+		TargetType: model.AuditTargetUser,
+		TargetID:   "someone",
+		WorldID:    worldID,
+	})
+	AuditRecord(AuditEntry{
+		ActorID:    "admin",
+		Action:     model.AuditActionWorldMemberRemove,
+		TargetType: model.AuditTargetUser,
+		TargetID:   "elsewhere",
+		WorldID:    "other-world",
+	})
+
+	result, err := AuditLogListForWorld(worldID, "owner", model.AuditLogQuery{WorldID: "other-world"})
+	if err != nil {
+		t.Fatalf("owner should read audit log: %v", err)
+	}
+	if result.Total != 1 || result.Items[0].TargetID != "someone" {
+		t.Fatalf("owner should only see own world records, got %+v", result.Items)
+	}
+
+	if _, err := AuditLogListForWorld(worldID, "admin", model.AuditLogQuery{}); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("world admin should be denied, got %v", err)
+	}
+	if _, err := AuditLogListForWorld("", "owner", model.AuditLogQuery{}); !errors.Is(err, ErrWorldNotFound) {
+		t.Fatalf("empty world should be not found, got %v", err)
+	}
+}
@@ -18,6 +18,7 @@ type BattleReportSummaryInput struct {
 	ContextReportCount int
 	SourceChannelIDs   []string
 	Source             string
+	IncludeDiceStats   bool // 在 AI 战报末尾附加骰运统计
 	AIConfig           utils.AIConfig
 	Runner             aiService.TaskRunner
 }
@@ -26,6 +27,7 @@ type BattleReportSummaryRunOptions struct {
 	User             *model.UserModel
 	Source           string
 	SourceChannelIDs []string
+	IncludeDiceStats bool
 	AIConfig         utils.AIConfig
 	Runner           aiService.TaskRunner
 }
@@ -80,6 +82,7 @@ func StartBattleReportSummary(ctx context.Context, channelID string, userID stri
 			User:             user,
 			Source:           input.Source,
 			SourceChannelIDs: sourceChannelIDs,
+			IncludeDiceStats: input.IncludeDiceStats,
 			AIConfig:         input.AIConfig,
 			Runner:           input.Runner,
 		}); err != nil {
@@ -163,6 +166,13 @@ func runBattleReportSummaryTask(ctx context.Context, reportID string, opts Battl
 	if result == "" {
 		return markBattleReportSummaryFailed(report.ID, fmt.Errorf("AI 返回空战报"))
 	}
+	if opts.IncludeDiceStats {
+		section, err := BattleReportDiceLuckSection(channels, report.PeriodStart, report.PeriodEnd)
+		if err != nil {
+			return markBattleReportSummaryFailed(report.ID, err)
+		}
+		result += "\n\n" + section
+	}
 	updates := map[string]interface{}{
 		"content":         result,
 		"content_preview": model.BuildBattleReportPreview(result, 200),
@@ -88,12 +88,14 @@ func EnsureBattleReportDisplayChannel(sourceChannelID, userID, name string) (*mo
 		return nil, fmt.Errorf("战报展示频道创建失败")
 	}
 	updates := map[string]any{
-		"note":                  "战报总结展示频道",
-		"default_dice_expr":     source.DefaultDiceExpr,
-		"built_in_dice_enabled": source.BuiltInDiceEnabled,
-		"bot_feature_enabled":   source.BotFeatureEnabled,
-		"status":                model.ChannelStatusActive,
-		"sort_order":            source.SortOrder - 1,
+		"note":                    "战报总结展示频道",
+		"default_dice_expr":       source.DefaultDiceExpr,
+		"dice_rule_system":        source.DiceRuleSystem,
+		"built_in_dice_enabled":   source.BuiltInDiceEnabled,
+		"verifiable_dice_enabled": source.VerifiableDiceEnabled,
+		"bot_feature_enabled":     source.BotFeatureEnabled,
+		"status":                  model.ChannelStatusActive,
+		"sort_order":              source.SortOrder - 1,
 	}
 	if strings.TrimSpace(source.DefaultDiceExpr) == "" {
 		updates["default_dice_expr"] = "d20"
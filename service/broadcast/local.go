@@ -0,0 +1,114 @@
+package broadcast
+
+import (
+	"sync"
+)
+
+// localHub 是进程内的信封交换中心。同一进程内以不同节点标识创建的总线会互相投递，
+// 单实例部署时只存在一个节点，Publish 因而不会产生任何额外投递。
+type localHub struct {
+	mu     sync.RWMutex
+	nextID uint64
+	subs   map[uint64]*localSubscription
+}
+
+type localSubscription struct {
+	nodeID  string
+	handler Handler
+}
+
+var defaultLocalHub = newLocalHub()
+
+func newLocalHub() *localHub {
+	return &localHub{subs: map[uint64]*localSubscription{}}
+}
+
+func (h *localHub) subscribe(nodeID string, handler Handler) func() {
+	h.mu.Lock()
+	h.nextID++
+	id := h.nextID
+	h.subs[id] = &localSubscription{nodeID: nodeID, handler: handler}
+	h.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, id)
+			h.mu.Unlock()
+		})
+	}
+}
+
+func (h *localHub) dispatch(env *Envelope) {
+	h.mu.RLock()
+	targets := make([]Handler, 0, len(h.subs))
+	for _, sub := range h.subs {
+		if sub == nil || sub.handler == nil || sub.nodeID == env.NodeID {
+			continue
+		}
+		targets = append(targets, sub.handler)
+	}
+	h.mu.RUnlock()
+	for _, handler := range targets {
+		handler(env)
+	}
+}
+
+type localBus struct {
+	hub    *localHub
+	nodeID string
+
+	mu     sync.Mutex
+	unsubs []func()
+}
+
+// NewLocalBus 创建进程内总线。
+func NewLocalBus(nodeID string) Bus {
+	return newLocalBusWithHub(defaultLocalHub, nodeID)
+}
+
+func newLocalBusWithHub(hub *localHub, nodeID string) *localBus {
+	return &localBus{hub: hub, nodeID: nodeID}
+}
+
+func (b *localBus) Backend() BackendType {
+	return BackendLocal
+}
+
+func (b *localBus) NodeID() string {
+	return b.nodeID
+}
+
+func (b *localBus) Publish(env *Envelope) error {
+	if b == nil || env == nil {
+		return nil
+	}
+	env.NodeID = b.nodeID
+	b.hub.dispatch(env)
+	return nil
+}
+
+func (b *localBus) Subscribe(handler Handler) func() {
+	if b == nil || handler == nil {
+		return func() {}
+	}
+	unsub := b.hub.subscribe(b.nodeID, handler)
+	b.mu.Lock()
+	b.unsubs = append(b.unsubs, unsub)
+	b.mu.Unlock()
+	return unsub
+}
+
+func (b *localBus) Close() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	unsubs := b.unsubs
+	b.unsubs = nil
+	b.mu.Unlock()
+	for _, unsub := range unsubs {
+		unsub()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package broadcast
+
+import (
+	"testing"
+)
+
+func TestLocalBusDeliversOnlyToOtherNodes(t *testing.T) {
+	hub := newLocalHub()
+	nodeA := newLocalBusWithHub(hub, "node-a")
+	nodeB := newLocalBusWithHub(hub, "node-b")
+
+	var gotA, gotB []*Envelope
+	nodeA.Subscribe(func(env *Envelope) { gotA = append(gotA, env) })
+	nodeB.Subscribe(func(env *Envelope) { gotB = append(gotB, env) })
+
+	if err := nodeA.Publish(&Envelope{Kind: KindChannel, ChannelID: "ch-1"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if len(gotA) != 0 {
+		t.Fatalf("expected publisher node not to receive its own envelope, got %d", len(gotA))
+	}
+	if len(gotB) != 1 || gotB[0].NodeID != "node-a" || gotB[0].ChannelID != "ch-1" {
+		t.Fatalf("unexpected delivery to node-b: %+v", gotB)
+	}
+
+	_ = nodeB.Close()
+	_ = nodeA.Publish(&Envelope{Kind: KindEvent})
+	if len(gotB) != 1 {
+		t.Fatalf("expected closed bus to stop receiving, got %d envelopes", len(gotB))
+	}
+}
+
+func TestSingleLocalBusPublishIsNoop(t *testing.T) {
+	hub := newLocalHub()
+	bus := newLocalBusWithHub(hub, "solo")
+	received := 0
+	bus.Subscribe(func(env *Envelope) { received++ })
+	_ = bus.Publish(&Envelope{Kind: KindAll})
+	if received != 0 {
+		t.Fatalf("expected single node publish to be a no-op, got %d deliveries", received)
+	}
+}
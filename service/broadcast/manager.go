@@ -0,0 +1,91 @@
+package broadcast
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"sealchat/utils"
+)
+
+var (
+	defaultBus   Bus
+	defaultBusMu sync.RWMutex
+)
+
+// Init 按配置创建全局广播总线。postgres 后端初始化失败时返回错误，启动流程会据此中止。
+func Init(ctx context.Context, cfg utils.BroadcastConfig, dbDSN string) (Bus, error) {
+	nodeID := strings.TrimSpace(cfg.NodeID)
+	if nodeID == "" {
+		nodeID = utils.NewIDWithLength(10)
+	}
+	var bus Bus
+	switch BackendType(strings.ToLower(strings.TrimSpace(cfg.Backend))) {
+	case BackendPostgres:
+		dsn := strings.TrimSpace(cfg.PostgresDSN)
+		if dsn == "" && isPostgresDSN(dbDSN) {
+			dsn = dbDSN
+		}
+		if dsn == "" {
+			return nil, fmt.Errorf("broadcast backend postgres requires postgresDsn or a postgres dbUrl")
+		}
+		pgBus, err := NewPostgresBus(ctx, dsn, cfg.PostgresChannel, nodeID)
+		if err != nil {
+			return nil, err
+		}
+		bus = pgBus
+	default:
+		bus = NewLocalBus(nodeID)
+	}
+	SetDefault(bus)
+	log.Printf("[broadcast] 广播总线: backend=%s node=%s", bus.Backend(), bus.NodeID())
+	return bus, nil
+}
+
+// SetDefault 替换全局总线，旧总线会被关闭。
+func SetDefault(bus Bus) {
+	defaultBusMu.Lock()
+	prev := defaultBus
+	defaultBus = bus
+	defaultBusMu.Unlock()
+	if prev != nil && prev != bus {
+		_ = prev.Close()
+	}
+}
+
+// Get 返回全局总线，未初始化时为 nil。
+func Get() Bus {
+	defaultBusMu.RLock()
+	defer defaultBusMu.RUnlock()
+	return defaultBus
+}
+
+// Publish 通过全局总线向其他节点发送信封，未初始化时静默忽略。
+func Publish(env *Envelope) {
+	bus := Get()
+	if bus == nil || env == nil {
+		return
+	}
+	if env.SentAt == 0 {
+		env.SentAt = time.Now().UnixMilli()
+	}
+	if err := bus.Publish(env); err != nil {
+		log.Printf("[broadcast] 发布失败: kind=%s err=%v", env.Kind, err)
+	}
+}
+
+// NodeID 返回当前节点标识，未初始化时为空。
+func NodeID() string {
+	if bus := Get(); bus != nil {
+		return bus.NodeID()
+	}
+	return ""
+}
+
+func isPostgresDSN(dsn string) bool {
+	dsn = strings.TrimSpace(dsn)
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
@@ -0,0 +1,238 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// NOTIFY 负载上限为 8000 字节，超出部分改为写入溢出表后仅通知行 ID
+	postgresMaxNotifyPayload = 7900
+	postgresSpillPrefix      = "spill:"
+	postgresSpillTable       = "broadcast_bus_spills"
+	postgresSpillRetention   = 5 * time.Minute
+	postgresReconnectMin     = time.Second
+	postgresReconnectMax     = 30 * time.Second
+)
+
+type postgresBus struct {
+	nodeID  string
+	channel string
+	dsn     string
+	pool    *pgxpool.Pool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.RWMutex
+	nextID   uint64
+	handlers map[uint64]Handler
+}
+
+// NewPostgresBus 基于 Postgres LISTEN/NOTIFY 创建跨节点总线。
+func NewPostgresBus(ctx context.Context, dsn string, channel string, nodeID string) (Bus, error) {
+	dsn = strings.TrimSpace(dsn)
+	channel = strings.TrimSpace(channel)
+	if dsn == "" {
+		return nil, errors.New("postgres broadcast bus requires dsn")
+	}
+	if channel == "" {
+		return nil, errors.New("postgres broadcast bus requires channel")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres broadcast bus: %w", err)
+	}
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+postgresSpillTable+` (
+		id BIGSERIAL PRIMARY KEY,
+		payload TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("prepare broadcast spill table: %w", err)
+	}
+	loopCtx, cancel := context.WithCancel(ctx)
+	bus := &postgresBus{
+		nodeID:   nodeID,
+		channel:  channel,
+		dsn:      dsn,
+		pool:     pool,
+		ctx:      loopCtx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		handlers: map[uint64]Handler{},
+	}
+	go bus.listenLoop()
+	return bus, nil
+}
+
+func (b *postgresBus) Backend() BackendType {
+	return BackendPostgres
+}
+
+func (b *postgresBus) NodeID() string {
+	return b.nodeID
+}
+
+func (b *postgresBus) Publish(env *Envelope) error {
+	if b == nil || env == nil {
+		return nil
+	}
+	env.NodeID = b.nodeID
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	payload := string(data)
+	if len(payload) > postgresMaxNotifyPayload {
+		var spillID int64
+		if err := b.pool.QueryRow(b.ctx, `INSERT INTO `+postgresSpillTable+` (payload) VALUES ($1) RETURNING id`, payload).Scan(&spillID); err != nil {
+			return fmt.Errorf("spill broadcast payload: %w", err)
+		}
+		payload = postgresSpillPrefix + strconv.FormatInt(spillID, 10)
+	}
+	if _, err := b.pool.Exec(b.ctx, `SELECT pg_notify($1, $2)`, b.channel, payload); err != nil {
+		return fmt.Errorf("notify broadcast payload: %w", err)
+	}
+	return nil
+}
+
+func (b *postgresBus) Subscribe(handler Handler) func() {
+	if b == nil || handler == nil {
+		return func() {}
+	}
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	b.handlers[id] = handler
+	b.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.handlers, id)
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *postgresBus) Close() error {
+	if b == nil {
+		return nil
+	}
+	b.cancel()
+	<-b.done
+	b.pool.Close()
+	return nil
+}
+
+func (b *postgresBus) listenLoop() {
+	defer close(b.done)
+	backoff := postgresReconnectMin
+	for {
+		if b.ctx.Err() != nil {
+			return
+		}
+		err := b.listenOnce()
+		if b.ctx.Err() != nil {
+			return
+		}
+		log.Printf("[broadcast] postgres 监听中断，%s 后重连: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-b.ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > postgresReconnectMax {
+			backoff = postgresReconnectMax
+		}
+	}
+}
+
+func (b *postgresBus) listenOnce() error {
+	conn, err := pgx.Connect(b.ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+	if _, err := conn.Exec(b.ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	log.Printf("[broadcast] postgres 总线已就绪: node=%s channel=%s", b.nodeID, b.channel)
+	lastCleanup := time.Now()
+	for {
+		waitCtx, cancel := context.WithTimeout(b.ctx, time.Minute)
+		notification, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		if time.Since(lastCleanup) >= time.Minute {
+			b.cleanupSpills()
+			lastCleanup = time.Now()
+		}
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && b.ctx.Err() == nil {
+				continue
+			}
+			return err
+		}
+		if notification == nil {
+			continue
+		}
+		b.handleNotification(notification.Payload)
+	}
+}
+
+func (b *postgresBus) handleNotification(payload string) {
+	if strings.HasPrefix(payload, postgresSpillPrefix) {
+		spillID, err := strconv.ParseInt(strings.TrimPrefix(payload, postgresSpillPrefix), 10, 64)
+		if err != nil {
+			return
+		}
+		if err := b.pool.QueryRow(b.ctx, `SELECT payload FROM `+postgresSpillTable+` WHERE id = $1`, spillID).Scan(&payload); err != nil {
+			log.Printf("[broadcast] 读取溢出负载失败: id=%d err=%v", spillID, err)
+			return
+		}
+	}
+	env := &Envelope{}
+	if err := json.Unmarshal([]byte(payload), env); err != nil {
+		log.Printf("[broadcast] 解析广播负载失败: %v", err)
+		return
+	}
+	if env.NodeID == b.nodeID {
+		return
+	}
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(env)
+	}
+}
+
+func (b *postgresBus) cleanupSpills() {
+	cutoff := time.Now().Add(-postgresSpillRetention)
+	if _, err := b.pool.Exec(b.ctx, `DELETE FROM `+postgresSpillTable+` WHERE created_at < $1`, cutoff); err != nil {
+		log.Printf("[broadcast] 清理溢出负载失败: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package broadcast
+
+import (
+	"encoding/json"
+)
+
+type BackendType string
+
+const (
+	BackendLocal    BackendType = "local"
+	BackendPostgres BackendType = "postgres"
+)
+
+// Kind 描述信封需要在远端节点上以何种方式投递。
+type Kind string
+
+const (
+	// KindUser 投递给指定用户的全部连接
+	KindUser Kind = "user"
+	// KindAll 投递给全部连接（可排除部分用户）
+	KindAll Kind = "all"
+	// KindEvent 全局事件
+	KindEvent Kind = "event"
+	// KindChannel 频道内事件
+	KindChannel Kind = "channel"
+	// KindChannelExcept 频道内事件（排除部分用户）
+	KindChannelExcept Kind = "channel-except"
+	// KindChannelUsers 频道内指定用户的事件
+	KindChannelUsers Kind = "channel-users"
+	// KindChannelBot 频道内 BOT 事件
+	KindChannelBot Kind = "channel-bot"
+	// KindChannelViewers 当前正在查看该频道的连接（不经频道成员索引）
+	KindChannelViewers Kind = "channel-viewers"
+	// KindWorld 当前处于该世界的连接
+	KindWorld Kind = "world"
+	// KindLobby 全部已登录的非 BOT 连接
+	KindLobby Kind = "lobby"
+	// KindSessionRevoke 断开指定用户使用已注销会话建立的连接，Payload 为会话 ID 列表
+	KindSessionRevoke Kind = "session-revoke"
+	// KindPresence 节点本地在线态快照
+	KindPresence Kind = "presence"
+)
+
+// Envelope 是跨节点传输的广播单元。Payload 保持原始 JSON，由接收方按 Kind 解码。
+type Envelope struct {
+	NodeID         string          `json:"nodeId"`
+	Kind           Kind            `json:"kind"`
+	ChannelID      string          `json:"channelId,omitempty"`
+	WorldID        string          `json:"worldId,omitempty"`
+	UserIDs        []string        `json:"userIds,omitempty"`
+	ExcludeUserIDs []string        `json:"excludeUserIds,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	SentAt         int64           `json:"sentAt"`
+}
+
+// Handler 处理来自其他节点的信封。
+type Handler func(env *Envelope)
+
+// Bus 是跨节点广播的传输层。Publish 只负责把信封送达其他节点，
+// 本节点的连接由调用方直接投递，因此订阅者不会收到自己节点发出的信封。
+type Bus interface {
+	Backend() BackendType
+	NodeID() string
+	Publish(env *Envelope) error
+	Subscribe(handler Handler) (unsubscribe func())
+	Close() error
+}
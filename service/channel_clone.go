@@ -24,6 +24,7 @@ type ChannelCopyOptions struct {
 	CopyGallery     bool `json:"copyGallery"`
 	CopyIForms      bool `json:"copyIForms"`
 	CopyDiceMacros  bool `json:"copyDiceMacros"`
+	CopyInitiative  bool `json:"copyInitiative"`
 	CopyAudioScenes bool `json:"copyAudioScenes"`
 	CopyAudioState  bool `json:"copyAudioState"`
 	CopyWebhooks    bool `json:"copyWebhooks"`
@@ -136,7 +137,9 @@ func ChannelClone(sourceChannelID string, actor *model.UserModel, params Channel
 			"note":                       source.Note,
 			"sort_order":                 source.SortOrder,
 			"default_dice_expr":          source.DefaultDiceExpr,
+			"dice_rule_system":           source.DiceRuleSystem,
 			"built_in_dice_enabled":      source.BuiltInDiceEnabled,
+			"verifiable_dice_enabled":    source.VerifiableDiceEnabled,
 			"bot_feature_enabled":        source.BotFeatureEnabled,
 			"bot_whisper_forward_config": source.BotWhisperForwardConfig,
 			"background_attachment_id":   source.BackgroundAttachmentId,
@@ -242,6 +245,16 @@ func ChannelClone(sourceChannelID string, actor *model.UserModel, params Channel
 		summary.addSkipped("diceMacros")
 	}
 
+	if params.Options.CopyInitiative {
+		if err := copyChannelInitiative(tx, source.ID, newChannel.ID, identityMap, actor.ID, &summary); err != nil {
+			tx.Rollback()
+			cleanupClonedChannel(newChannel.ID)
+			return nil, err
+		}
+	} else {
+		summary.addSkipped("initiative")
+	}
+
 	if params.Options.CopyAudioScenes {
 		sceneMap, err = copyChannelAudioScenes(tx, source.ID, newChannel.ID, &summary)
 		if err != nil {
@@ -900,6 +913,55 @@ func copyChannelDiceMacros(tx *gorm.DB, sourceID, targetID string, allowedUserID
 	return nil
 }
 
+// copyChannelInitiative 复制先攻表与回合进度；未随频道复制的角色转为由操作者控制的 NPC
+func copyChannelInitiative(tx *gorm.DB, sourceID, targetID string, identityMap map[string]string, actorID string, summary *ChannelCopySummary) error {
+	combatants, err := model.InitiativeCombatantList(tx, sourceID)
+	if err != nil {
+		return err
+	}
+	combatantMap := map[string]string{}
+	for _, combatant := range combatants {
+		newID := utils.NewID()
+		combatantMap[combatant.ID] = newID
+		clone := *combatant
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: newID}
+		clone.ChannelID = targetID
+		if clone.IdentityID != "" {
+			if mapped := identityMap[clone.IdentityID]; mapped != "" {
+				clone.IdentityID = mapped
+			} else {
+				clone.IdentityID = ""
+				clone.IsNPC = true
+				clone.UserID = actorID
+			}
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	tracker, err := model.InitiativeTrackerGet(tx, sourceID)
+	if err != nil {
+		return err
+	}
+	if tracker != nil {
+		clone := *tracker
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = targetID
+		clone.CurrentCombatantID = combatantMap[tracker.CurrentCombatantID]
+		clone.Version = 0
+		clone.UpdatedBy = actorID
+		if clone.CurrentCombatantID == "" {
+			clone.Active = false
+			clone.Round = 0
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	summary.addCopied("initiative")
+	return nil
+}
+
 func copyChannelAudioScenes(tx *gorm.DB, sourceID, targetID string, summary *ChannelCopySummary) (map[string]string, error) {
 	var scenes []model.AudioScene
 	if err := tx.Where("channel_scope = ?", sourceID).Find(&scenes).Error; err != nil {
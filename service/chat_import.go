@@ -3,12 +3,15 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"html"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"sealchat/model"
 	"sealchat/pkg/contentstats"
@@ -166,7 +169,7 @@ func executeImportJob(job *model.ChatImportJobModel, content string, config *mod
 	}()
 
 	// 解析日志
-	parser, err := NewChatLogParser(config)
+	parser, err := newChatImportEntryParser(config)
 	if err != nil {
 		job.Status = model.ChatImportStatusFailed
 		job.ErrorMessage = "解析配置错误: " + err.Error()
@@ -190,8 +193,16 @@ func executeImportJob(job *model.ChatImportJobModel, content string, config *mod
 		return
 	}
 
+	// 结构化日志的发言人按名称匹配可复用身份
+	roleMapping := config.RoleMapping
+	if structured, ok := parser.(*structuredChatImportParser); ok {
+		restoreImportAttachments(job.UserID, job.ChannelID, structured.attachments, entries)
+		roleMapping = matchImportRoleMappingByName(job.WorldID, job.ChannelID, job.UserID, ExtractRoleNames(entries), roleMapping)
+		roleMapping = applyImportRoleAppearance(entries, roleMapping)
+	}
+
 	// 创建或获取角色身份映射
-	identityMap, err := resolveImportIdentities(job.ChannelID, job.UserID, entries, config.RoleMapping)
+	identityMap, err := resolveImportIdentities(job.ChannelID, job.UserID, entries, roleMapping)
 	if err != nil {
 		job.Status = model.ChatImportStatusFailed
 		job.ErrorMessage = "角色身份创建失败: " + err.Error()
@@ -338,6 +349,45 @@ func resolveImportIdentities(channelID string, userID string, entries []*model.P
 	return identityMap, nil
 }
 
+// matchImportRoleMappingByName 为未指定复用身份的角色按显示名匹配世界内可复用身份，当前频道的身份优先
+func matchImportRoleMappingByName(worldID, channelID, userID string, roleNames []string, roleMapping map[string]*model.ChatImportRoleMappingConfig) map[string]*model.ChatImportRoleMappingConfig {
+	identities, err := ListReusableIdentities(worldID, channelID, userID, nil, true, false)
+	if err != nil || len(identities) == 0 {
+		return roleMapping
+	}
+	sort.SliceStable(identities, func(i, j int) bool {
+		return identities[i].ChannelID == channelID && identities[j].ChannelID != channelID
+	})
+
+	result := make(map[string]*model.ChatImportRoleMappingConfig, len(roleNames))
+	for name, cfg := range roleMapping {
+		result[name] = cfg
+	}
+	for _, roleName := range roleNames {
+		mappingConfig := result[roleName]
+		if mappingConfig != nil && mappingConfig.ReuseIdentityID != "" {
+			continue
+		}
+		targetName := roleName
+		if mappingConfig != nil && mappingConfig.DisplayName != "" {
+			targetName = mappingConfig.DisplayName
+		}
+		for _, identity := range identities {
+			if !strings.EqualFold(strings.TrimSpace(identity.DisplayName), strings.TrimSpace(targetName)) {
+				continue
+			}
+			matched := &model.ChatImportRoleMappingConfig{}
+			if mappingConfig != nil {
+				*matched = *mappingConfig
+			}
+			matched.ReuseIdentityID = identity.ID
+			result[roleName] = matched
+			break
+		}
+	}
+	return result
+}
+
 // createImportIdentity 创建导入用的角色身份
 func createImportIdentity(channelID, userID, displayName, color, avatarID string) (*model.ChannelIdentityModel, error) {
 	if displayName == "" {
@@ -378,6 +428,8 @@ func batchInsertImportedMessages(importJobID string, channelID string, entries [
 	}
 
 	messages := make([]*model.MessageModel, 0, len(entries))
+	var diceRolls []*model.MessageDiceRollModel
+	var whisperRecipients []*model.MessageWhisperRecipientModel
 
 	for _, entry := range entries {
 		identity := identityMap[entry.RoleName]
@@ -395,20 +447,34 @@ func batchInsertImportedMessages(importJobID string, channelID string, entries [
 			createdAt = time.Now()
 			displayOrder = float64(createdAt.UnixMilli())
 		}
+		if entry.DisplayOrder > 0 {
+			displayOrder = entry.DisplayOrder
+		}
 
 		icMode := "ic"
 		if entry.IsOOC {
 			icMode = "ooc"
 		}
 
+		plainContent := strings.TrimSpace(entry.Content)
+		content := plainContent
+		var msgRolls []*model.MessageDiceRollModel
+		if entry.RichContent != "" {
+			// SealChat 回导：原内容已包含骰子胶囊，只需恢复掷骰记录
+			content = entry.RichContent
+			msgRolls = buildImportedDiceRolls(entry.DiceRolls)
+		} else if len(entry.DiceRolls) > 0 {
+			content, msgRolls = buildImportedDiceContent(plainContent, entry.DiceRolls)
+		}
+
 		msg := &model.MessageModel{
 			StringPKBaseModel: model.StringPKBaseModel{
 				ID:        utils.NewID(),
 				CreatedAt: createdAt,
 				UpdatedAt: createdAt,
 			},
-			Content:             strings.TrimSpace(entry.Content),
-			VisibleCharCount:    contentstats.CountVisibleTextChars(strings.TrimSpace(entry.Content)),
+			Content:             content,
+			VisibleCharCount:    contentstats.CountVisibleTextChars(plainContent),
 			ChannelID:           channelID,
 			UserID:              identity.UserID,
 			DisplayOrder:        displayOrder,
@@ -426,6 +492,43 @@ func batchInsertImportedMessages(importJobID string, channelID string, entries [
 			msg.SenderIdentityAvatarID = identity.AvatarAttachmentID
 		}
 
+		if entry.IsArchived {
+			archivedAt := createdAt
+			msg.IsArchived = true
+			msg.ArchivedAt = &archivedAt
+		}
+
+		if entry.IsWhisper {
+			msg.IsWhisper = true
+			msg.WhisperSenderMemberName = identity.DisplayName
+			for _, targetName := range entry.WhisperTargets {
+				target := identityMap[targetName]
+				if target == nil {
+					continue
+				}
+				if msg.WhisperTo == "" {
+					msg.WhisperTo = target.UserID
+					msg.WhisperTargetMemberID = target.ID
+					msg.WhisperTargetMemberName = target.DisplayName
+				}
+				whisperRecipients = append(whisperRecipients, &model.MessageWhisperRecipientModel{
+					MessageID: msg.ID,
+					UserID:    target.UserID,
+				})
+			}
+			if msg.WhisperTargetMemberName == "" && len(entry.WhisperTargets) > 0 {
+				msg.WhisperTargetMemberName = entry.WhisperTargets[0]
+			}
+		}
+
+		for _, roll := range msgRolls {
+			roll.Init()
+			roll.MessageID = msg.ID
+			roll.CreatedAt = createdAt
+			roll.UpdatedAt = createdAt
+		}
+		diceRolls = append(diceRolls, msgRolls...)
+
 		messages = append(messages, msg)
 	}
 
@@ -434,13 +537,76 @@ func batchInsertImportedMessages(importJobID string, channelID string, entries [
 	}
 
 	// 批量插入
-	if err := model.GetDB().CreateInBatches(messages, 100).Error; err != nil {
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(messages, 100).Error; err != nil {
+			return err
+		}
+		if len(diceRolls) > 0 {
+			if err := tx.CreateInBatches(diceRolls, 100).Error; err != nil {
+				return err
+			}
+		}
+		if len(whisperRecipients) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(whisperRecipients, 100).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return 0, err
 	}
 
 	return len(messages), nil
 }
 
+// buildImportedDiceRolls 将解析出的掷骰结果转换为待写入的记录
+func buildImportedDiceRolls(parsedRolls []*model.ParsedLogDiceRoll) []*model.MessageDiceRollModel {
+	rolls := make([]*model.MessageDiceRollModel, 0, len(parsedRolls))
+	for _, parsed := range parsedRolls {
+		if parsed == nil {
+			continue
+		}
+		rolls = append(rolls, &model.MessageDiceRollModel{
+			RollIndex:       len(rolls),
+			SourceText:      parsed.SourceText,
+			Formula:         parsed.Formula,
+			ResultDetail:    parsed.ResultDetail,
+			ResultValueText: parsed.ResultValueText,
+			ResultText:      parsed.ResultText,
+			IsError:         parsed.IsError,
+			RuleSystem:      parsed.RuleSystem,
+			Outcome:         parsed.Outcome,
+		})
+	}
+	return rolls
+}
+
+// buildImportedDiceContent 将纯文本中的掷骰原文替换为骰子胶囊，找不到原文时追加到末尾
+func buildImportedDiceContent(text string, parsedRolls []*model.ParsedLogDiceRoll) (string, []*model.MessageDiceRollModel) {
+	rolls := buildImportedDiceRolls(parsedRolls)
+	var sb strings.Builder
+	rest := text
+	for _, roll := range rolls {
+		if idx := strings.Index(rest, roll.SourceText); roll.SourceText != "" && idx >= 0 {
+			sb.WriteString(importTextToHTML(rest[:idx]))
+			sb.WriteString(buildDiceChipHTML(roll))
+			rest = rest[idx+len(roll.SourceText):]
+			continue
+		}
+		sb.WriteString(importTextToHTML(rest))
+		rest = ""
+		sb.WriteString(" ")
+		sb.WriteString(buildDiceChipHTML(roll))
+	}
+	sb.WriteString(importTextToHTML(rest))
+	return sb.String(), rolls
+}
+
+func importTextToHTML(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br />")
+}
+
 // GetChatImportJobStatus 获取任务状态
 func GetChatImportJobStatus(jobID string) (*ChatImportJobProgress, error) {
 	// 先从内存获取
@@ -45,6 +45,42 @@ var chatImportTemplates = []*model.ChatImportTemplate{
 		Pattern:     `^([^:：\s]+)\s*[:：]\s*(.+)`,
 		Example:     "木落：你好世界",
 	},
+	{
+		ID:          chatImportTemplateFoundryVTT,
+		Name:        "FoundryVTT 聊天记录",
+		Description: "FoundryVTT 的 messages.db（逐行 JSON）或导出的消息 JSON，保留掷骰结果",
+		Example:     `{"_id":"a1","timestamp":1700000000000,"speaker":{"alias":"木落"},"content":"你好世界"}`,
+		Structured:  true,
+	},
+	{
+		ID:          chatImportTemplateRoll20,
+		Name:        "Roll20 聊天存档",
+		Description: "Roll20 聊天存档页面（Chat Archive）另存的 HTML，保留掷骰结果",
+		Example:     `<div class="message general"><span class="tstamp">March 11, 2020 8:53PM</span><span class="by">木落:</span>你好世界</div>`,
+		Structured:  true,
+	},
+	{
+		ID:          chatImportTemplateSealChat,
+		Name:        "SealChat JSON 导出",
+		Description: "本站 JSON 格式导出（可多个分卷），还原排序、场内外、悄悄话、角色、掷骰与附件",
+		Example:     `{"version":105,"items":[...],"sealchat":{"channel_id":"...","messages":[...]}}`,
+		Structured:  true,
+	},
+}
+
+// chatImportEntryParser 日志解析器的统一接口
+type chatImportEntryParser interface {
+	ParseLogContent(content string) ([]*model.ParsedLogEntry, int, int)
+}
+
+// newChatImportEntryParser 按配置选择逐行正则解析器或结构化解析器
+func newChatImportEntryParser(config *model.ChatImportConfig) (chatImportEntryParser, error) {
+	if config.RegexPattern == "" {
+		if tmpl := GetChatImportTemplateByID(config.TemplateID); tmpl != nil && tmpl.Structured {
+			return newStructuredChatImportParser(tmpl.ID, config), nil
+		}
+	}
+	return NewChatLogParser(config)
 }
 
 // GetChatImportTemplates 获取所有内置模板
@@ -160,7 +196,7 @@ func (p *ChatLogParser) ParseLogContent(content string) ([]*model.ParsedLogEntry
 	}
 
 	// 处理OOC标记
-	p.processOOCMarking(entries)
+	markImportEntriesOOC(entries, p.strictOOC)
 
 	return entries, len(lines), skippedCount
 }
@@ -283,9 +319,9 @@ func (p *ChatLogParser) parseTimestamp(s string, hasDate bool) *time.Time {
 	return nil
 }
 
-// processOOCMarking 处理OOC标记
-func (p *ChatLogParser) processOOCMarking(entries []*model.ParsedLogEntry) {
-	if p.strictOOC {
+// markImportEntriesOOC 处理OOC标记
+func markImportEntriesOOC(entries []*model.ParsedLogEntry, strictOOC bool) {
+	if strictOOC {
 		// 严格模式：只看首字符
 		for _, entry := range entries {
 			content := strings.TrimSpace(entry.Content)
@@ -338,7 +374,7 @@ func ParsePreview(req *model.ChatImportPreviewRequest) (*model.ChatImportPreview
 		MergeUnmatched: req.MergeUnmatched,
 	}
 
-	parser, err := NewChatLogParser(config)
+	parser, err := newChatImportEntryParser(config)
 	if err != nil {
 		return nil, err
 	}
@@ -356,9 +392,18 @@ func ParsePreview(req *model.ChatImportPreviewRequest) (*model.ChatImportPreview
 	}
 
 	// 获取使用的模板名称
+	usedPattern := ""
+	usedTemplateID := ""
+	switch p := parser.(type) {
+	case *ChatLogParser:
+		usedPattern = p.pattern.String()
+		usedTemplateID = p.templateID
+	case *structuredChatImportParser:
+		usedTemplateID = p.format
+	}
 	usedTemplateName := ""
-	if parser.templateID != "" {
-		tmpl := GetChatImportTemplateByID(parser.templateID)
+	if usedTemplateID != "" {
+		tmpl := GetChatImportTemplateByID(usedTemplateID)
 		if tmpl != nil {
 			usedTemplateName = tmpl.Name
 		}
@@ -370,7 +415,7 @@ func ParsePreview(req *model.ChatImportPreviewRequest) (*model.ChatImportPreview
 		ParsedCount:      len(entries),
 		SkippedCount:     skippedCount,
 		DetectedRoles:    ExtractRoleNames(entries),
-		UsedPattern:      parser.pattern.String(),
+		UsedPattern:      usedPattern,
 		UsedTemplateName: usedTemplateName,
 	}, nil
 }
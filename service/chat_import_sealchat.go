@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"sealchat/model"
+)
+
+const chatImportTemplateSealChat = "sealchat"
+
+// parseSealChat 解析 SealChat 的 JSON 导出；多个分卷可直接首尾相接传入
+func (p *structuredChatImportParser) parseSealChat(content string) ([]*model.ParsedLogEntry, int, int) {
+	decoder := json.NewDecoder(strings.NewReader(strings.TrimPrefix(strings.TrimSpace(content), "\ufeff")))
+	var parts []*ExportPayload
+	var legacyItems []diceLogItem
+	skipped := 0
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err != io.EOF {
+				skipped++
+			}
+			break
+		}
+		var doc diceLogPayload
+		if err := json.Unmarshal(raw, &doc); err == nil && doc.SealChat != nil {
+			parts = append(parts, doc.SealChat)
+			continue
+		}
+		var payload ExportPayload
+		if err := json.Unmarshal(raw, &payload); err == nil && len(payload.Messages) > 0 {
+			parts = append(parts, &payload)
+			continue
+		}
+		if len(doc.Items) > 0 {
+			// 旧版导出只有海豹染色器格式，仅能恢复发言人、时间与纯文本
+			legacyItems = append(legacyItems, doc.Items...)
+			continue
+		}
+		skipped++
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].PartIndex < parts[j].PartIndex
+	})
+	seen := map[string]struct{}{}
+	var messages []ExportMessage
+	for _, part := range parts {
+		for token, dataURL := range part.Attachments {
+			if p.attachments == nil {
+				p.attachments = make(map[string]string)
+			}
+			p.attachments[token] = dataURL
+		}
+		for _, msg := range part.Messages {
+			if msg.ID != "" {
+				if _, ok := seen[msg.ID]; ok {
+					continue
+				}
+				seen[msg.ID] = struct{}{}
+			}
+			messages = append(messages, msg)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].DisplayOrder > 0 && messages[j].DisplayOrder > 0 {
+			return messages[i].DisplayOrder < messages[j].DisplayOrder
+		}
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+
+	total := len(messages) + len(legacyItems)
+	var entries []*model.ParsedLogEntry
+	for index, msg := range messages {
+		entry := sealChatMessageToEntry(&msg)
+		if entry == nil {
+			skipped++
+			continue
+		}
+		entry.LineNumber = index + 1
+		entries = append(entries, entry)
+	}
+	for index, item := range legacyItems {
+		text := strings.TrimSpace(item.Message)
+		if text == "" {
+			skipped++
+			continue
+		}
+		roleName := strings.TrimSpace(item.Nickname)
+		if roleName == "" {
+			roleName = "未知角色"
+		}
+		entry := &model.ParsedLogEntry{
+			RawLine:    text,
+			RoleName:   roleName,
+			Content:    text,
+			LineNumber: len(messages) + index + 1,
+		}
+		if item.Time > 0 {
+			t := time.Unix(item.Time, 0)
+			entry.Timestamp = &t
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, skipped
+}
+
+func sealChatMessageToEntry(msg *ExportMessage) *model.ParsedLogEntry {
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+	plain := buildFilteredPlainContent(msg.Content, true)
+	roleName := strings.TrimSpace(msg.SenderName)
+	if roleName == "" {
+		roleName = "未知角色"
+	}
+	entry := &model.ParsedLogEntry{
+		RawLine:        plain,
+		RoleName:       roleName,
+		Content:        plain,
+		IsOOC:          strings.EqualFold(msg.IcMode, "ooc"),
+		IsWhisper:      msg.IsWhisper,
+		WhisperTargets: msg.WhisperTargets,
+		IsArchived:     msg.IsArchived,
+		RichContent:    msg.Content,
+		DisplayOrder:   msg.DisplayOrder,
+		RoleColor:      strings.TrimSpace(msg.SenderColor),
+	}
+	if avatar := strings.TrimSpace(msg.SenderAvatar); strings.HasPrefix(avatar, "id:") {
+		entry.RoleAvatar = avatar
+	}
+	if !msg.CreatedAt.IsZero() {
+		t := msg.CreatedAt
+		entry.Timestamp = &t
+	}
+	for _, roll := range msg.DiceRolls {
+		entry.DiceRolls = append(entry.DiceRolls, &model.ParsedLogDiceRoll{
+			SourceText:      roll.SourceText,
+			Formula:         roll.Formula,
+			ResultDetail:    roll.ResultDetail,
+			ResultValueText: roll.ResultValueText,
+			ResultText:      roll.ResultText,
+			IsError:         roll.IsError,
+			RuleSystem:      roll.RuleSystem,
+			Outcome:         roll.Outcome,
+		})
+	}
+	return entry
+}
+
+// restoreImportAttachments 将导出文件内嵌的附件保存到本实例，并把条目中的附件引用改写为新附件
+func restoreImportAttachments(userID string, channelID string, attachments map[string]string, entries []*model.ParsedLogEntry) {
+	if len(attachments) == 0 {
+		return
+	}
+	replaced := make(map[string]string, len(attachments))
+	for token, dataURL := range attachments {
+		mediaType, data, ok := decodeEPUBDataURL(dataURL)
+		if !ok {
+			continue
+		}
+		filename := token
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			filename += exts[0]
+		}
+		att, err := ImportAttachmentFromBytes(data, filename, mediaType, userID, channelID)
+		if err != nil || att == nil {
+			log.Printf("回导附件 %s 失败: %v", token, err)
+			continue
+		}
+		replaced[normalizeRoundTripAttachmentToken(token)] = att.ID
+	}
+	if len(replaced) == 0 {
+		return
+	}
+	for _, entry := range entries {
+		if entry.RichContent != "" {
+			entry.RichContent = roundTripAttachmentRefPattern.ReplaceAllStringFunc(entry.RichContent, func(match string) string {
+				groups := roundTripAttachmentRefPattern.FindStringSubmatch(match)
+				if newID, ok := replaced[groups[2]]; ok {
+					return groups[1] + "id:" + newID
+				}
+				return match
+			})
+		}
+		if entry.RoleAvatar != "" {
+			if newID, ok := replaced[normalizeRoundTripAttachmentToken(entry.RoleAvatar)]; ok {
+				entry.RoleAvatar = "id:" + newID
+			} else {
+				entry.RoleAvatar = ""
+			}
+		}
+	}
+}
+
+// applyImportRoleAppearance 未手动配置外观的角色沿用导出时的颜色与头像
+func applyImportRoleAppearance(entries []*model.ParsedLogEntry, roleMapping map[string]*model.ChatImportRoleMappingConfig) map[string]*model.ChatImportRoleMappingConfig {
+	result := make(map[string]*model.ChatImportRoleMappingConfig, len(roleMapping))
+	for name, cfg := range roleMapping {
+		result[name] = cfg
+	}
+	for _, entry := range entries {
+		if entry.RoleColor == "" && entry.RoleAvatar == "" {
+			continue
+		}
+		current := result[entry.RoleName]
+		if current != nil && current.ReuseIdentityID != "" {
+			continue
+		}
+		updated := &model.ChatImportRoleMappingConfig{}
+		if current != nil {
+			*updated = *current
+		}
+		if updated.Color == "" {
+			updated.Color = entry.RoleColor
+		}
+		if updated.AvatarAttachmentID == "" {
+			updated.AvatarAttachmentID = entry.RoleAvatar
+		}
+		result[entry.RoleName] = updated
+	}
+	return result
+}
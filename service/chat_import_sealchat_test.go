@@ -0,0 +1,121 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestSealChatJSONExportRoundTrip(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+
+	channelID := "ch-roundtrip-" + utils.NewID()
+	userID := "user-" + utils.NewID()
+	if err := db.Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: channelID},
+		Name:              "Round Trip",
+		WorldID:           "world-" + utils.NewID(),
+		PermType:          "public",
+		Status:            model.ChannelStatusActive,
+	}).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+
+	now := time.Unix(1700006000, 0)
+	chip := buildDiceChipHTML(&model.MessageDiceRollModel{RollIndex: 0, SourceText: ".r d20", Formula: "d20", ResultValueText: "12", ResultText: "d20 = 12"})
+	part := func(index int, msgs ...ExportMessage) *ExportPayload {
+		return &ExportPayload{ChannelID: "origin", PartIndex: index, PartTotal: 2, IncludeImages: true, IncludeRoundTrip: true, Messages: msgs, GeneratedAt: now}
+	}
+	first, err := jsonFormatter{}.Build(part(1,
+		ExportMessage{ID: "m1", SenderName: "KP", SenderColor: "#ff0000", IcMode: "ic", CreatedAt: now, DisplayOrder: 2048, Content: `<p>检定 ` + chip + `</p>`,
+			DiceRolls: []ExportDiceRoll{{RollIndex: 0, SourceText: ".r d20", Formula: "d20", ResultValueText: "12", ResultText: "d20 = 12"}}},
+		ExportMessage{ID: "m2", SenderName: "玩家", IcMode: "ooc", CreatedAt: now.Add(time.Second), DisplayOrder: 4096, Content: "场外聊天", IsArchived: true},
+	))
+	if err != nil {
+		t.Fatalf("build part 1 failed: %v", err)
+	}
+	second, err := jsonFormatter{}.Build(part(2,
+		ExportMessage{ID: "m3", SenderName: "玩家", IcMode: "ic", CreatedAt: now.Add(2 * time.Second), DisplayOrder: 1024.5, Content: "悄悄话", IsWhisper: true, WhisperTargets: []string{"KP"}},
+		ExportMessage{ID: "m2", SenderName: "玩家", IcMode: "ooc", CreatedAt: now.Add(time.Second), DisplayOrder: 4096, Content: "重复"},
+	))
+	if err != nil {
+		t.Fatalf("build part 2 failed: %v", err)
+	}
+	if !strings.Contains(string(first), `"items"`) {
+		t.Fatalf("expected dice log items to remain in json export")
+	}
+
+	parser, err := newChatImportEntryParser(&model.ChatImportConfig{TemplateID: chatImportTemplateSealChat})
+	if err != nil {
+		t.Fatalf("create parser failed: %v", err)
+	}
+	entries, total, skipped := parser.ParseLogContent(string(second) + "\n" + string(first))
+	if total != 3 || skipped != 0 || len(entries) != 3 {
+		t.Fatalf("unexpected counts: total=%d skipped=%d entries=%d", total, skipped, len(entries))
+	}
+	if entries[0].Content != "悄悄话" || !strings.HasPrefix(entries[1].Content, "检定") {
+		t.Fatalf("expected entries sorted by display order, got %q / %q", entries[0].Content, entries[1].Content)
+	}
+
+	roleMapping := applyImportRoleAppearance(entries, nil)
+	identityMap, err := resolveImportIdentities(channelID, userID, entries, roleMapping)
+	if err != nil {
+		t.Fatalf("resolve identities failed: %v", err)
+	}
+	if identityMap["KP"] == nil || identityMap["KP"].Color == "" {
+		t.Fatalf("expected exported sender color to be restored, got %+v", identityMap["KP"])
+	}
+	jobID := "job-" + utils.NewID()
+	if count, err := batchInsertImportedMessages(jobID, channelID, entries, identityMap); err != nil || count != 3 {
+		t.Fatalf("insert failed: count=%d err=%v", count, err)
+	}
+
+	var messages []*model.MessageModel
+	if err := db.Where("import_job_id = ?", jobID).Order("display_order asc").Find(&messages).Error; err != nil || len(messages) != 3 {
+		t.Fatalf("load messages failed: %v (%d)", err, len(messages))
+	}
+	whisper, diceMsg, ooc := messages[0], messages[1], messages[2]
+	if !whisper.IsWhisper || whisper.DisplayOrder != 1024.5 || whisper.WhisperTargetMemberName == "" {
+		t.Fatalf("unexpected whisper message: %+v", whisper)
+	}
+	if recipients := model.GetWhisperRecipientIDs(whisper.ID); len(recipients) != 1 || recipients[0] != userID {
+		t.Fatalf("unexpected whisper recipients: %v", recipients)
+	}
+	if diceMsg.Content != `<p>检定 `+chip+`</p>` || diceMsg.ICMode != "ic" {
+		t.Fatalf("expected rich content preserved, got %q", diceMsg.Content)
+	}
+	rolls, _ := model.MessageDiceRollListByMessageID(diceMsg.ID)
+	if len(rolls) != 1 || rolls[0].Formula != "d20" || rolls[0].ResultValueText != "12" {
+		t.Fatalf("unexpected dice rolls: %+v", rolls)
+	}
+	if ooc.ICMode != "ooc" || !ooc.IsArchived || ooc.Content != "场外聊天" {
+		t.Fatalf("unexpected ooc message: %+v", ooc)
+	}
+}
+
+func TestJSONExportRoundTripBlockIsOptIn(t *testing.T) {
+	payload := &ExportPayload{ChannelID: "origin", Messages: []ExportMessage{{ID: "m1", SenderName: "KP", Content: "你好"}}}
+	data, err := jsonFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if strings.Contains(string(data), `"sealchat"`) {
+		t.Fatalf("round-trip block should be omitted unless requested: %s", data)
+	}
+	payload.IncludeRoundTrip = true
+	if data, _ = (jsonFormatter{}).Build(payload); !strings.Contains(string(data), `"sealchat"`) {
+		t.Fatalf("round-trip block should be present when requested")
+	}
+}
+
+func TestCollectRoundTripAttachmentTokens(t *testing.T) {
+	payload := &ExportPayload{Messages: []ExportMessage{{Content: `<img src="id:old1"> {"src":"id:old2"} id:plain`, SenderAvatar: "id:id:avatar"}}}
+	tokens := collectRoundTripAttachmentTokens(payload)
+	if strings.Join(tokens, ",") != "old1,old2,avatar" {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+}
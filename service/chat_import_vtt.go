@@ -0,0 +1,701 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	htmlnode "golang.org/x/net/html"
+
+	"sealchat/model"
+)
+
+const (
+	chatImportTemplateFoundryVTT = "foundryvtt"
+	chatImportTemplateRoll20     = "roll20"
+)
+
+var (
+	roll20InlineRollTitlePattern = regexp.MustCompile(`(?is)^\s*rolling\s+(.+?)\s*=\s*(.*)$`)
+	roll20TimestampLayouts       = []string{"January 02, 2006 3:04PM", "January 2, 2006 3:04PM", "January 2, 2006 15:04"}
+	roll20TimeOnlyLayouts        = []string{"3:04PM", "15:04"}
+	vttWhitespacePattern         = regexp.MustCompile(`[ \t\r\n\f\x{00a0}]+`)
+)
+
+// structuredChatImportParser 解析 VTT 平台导出的结构化聊天记录
+type structuredChatImportParser struct {
+	format        string
+	baseTime      time.Time
+	timeIncrement time.Duration
+	strictOOC     bool
+	attachments   map[string]string // SealChat 导出内嵌的附件，导入时再落盘
+}
+
+func newStructuredChatImportParser(format string, config *model.ChatImportConfig) *structuredChatImportParser {
+	baseTime := time.Now()
+	if config.BaseTime != nil {
+		baseTime = *config.BaseTime
+	}
+	timeIncrement := config.TimeIncrement
+	if timeIncrement <= 0 {
+		timeIncrement = 1000 // 默认1秒
+	}
+	return &structuredChatImportParser{
+		format:        format,
+		baseTime:      baseTime,
+		timeIncrement: time.Duration(timeIncrement) * time.Millisecond,
+		strictOOC:     config.StrictOOC,
+	}
+}
+
+// ParseLogContent 解析结构化日志，返回条目、记录总数与跳过数
+func (p *structuredChatImportParser) ParseLogContent(content string) ([]*model.ParsedLogEntry, int, int) {
+	var entries []*model.ParsedLogEntry
+	var total, skipped int
+	switch p.format {
+	case chatImportTemplateFoundryVTT:
+		entries, total, skipped = p.parseFoundry(content)
+	case chatImportTemplateRoll20:
+		entries, total, skipped = p.parseRoll20(content)
+	case chatImportTemplateSealChat:
+		// 自身导出已有准确的时间、排序与场内外标记，无需再推断
+		entries, total, skipped = p.parseSealChat(content)
+		return entries, total, skipped
+	}
+	p.normalizeTimestamps(entries)
+	markImportEntriesOOC(entries, p.strictOOC)
+	return entries, total, skipped
+}
+
+// normalizeTimestamps 补齐缺失时间并保证时间严格递增，避免同一时刻的消息乱序
+func (p *structuredChatImportParser) normalizeTimestamps(entries []*model.ParsedLogEntry) {
+	var prev *time.Time
+	for _, entry := range entries {
+		var t time.Time
+		switch {
+		case entry.Timestamp != nil:
+			t = *entry.Timestamp
+		case prev != nil:
+			t = prev.Add(p.timeIncrement)
+		default:
+			t = p.baseTime
+		}
+		if prev != nil && !t.After(*prev) {
+			t = prev.Add(time.Millisecond)
+		}
+		entry.Timestamp = &t
+		prev = &t
+	}
+}
+
+// ---- FoundryVTT ----
+
+type foundryChatSpeaker struct {
+	Alias string `json:"alias"`
+	Actor string `json:"actor"`
+}
+
+type foundryChatMessage struct {
+	ID        string             `json:"_id"`
+	Deleted   bool               `json:"$$deleted"`
+	Type      json.RawMessage    `json:"type"`  // v11 及以前为数字，v12 起为字符串
+	Style     *int               `json:"style"` // v12 起表示 OOC/IC/EMOTE
+	User      json.RawMessage    `json:"user"`
+	Author    string             `json:"author"`
+	Timestamp int64              `json:"timestamp"`
+	Flavor    string             `json:"flavor"`
+	Content   string             `json:"content"`
+	Speaker   foundryChatSpeaker `json:"speaker"`
+	Roll      json.RawMessage    `json:"roll"`  // v9 及以前：单个序列化的 Roll
+	Rolls     []json.RawMessage  `json:"rolls"` // v10 起：Roll 数组
+}
+
+type foundryRollResult struct {
+	Result    float64 `json:"result"`
+	Active    *bool   `json:"active"`
+	Discarded bool    `json:"discarded"`
+}
+
+type foundryRollTerm struct {
+	Class    string              `json:"class"`
+	Number   *float64            `json:"number"`
+	Faces    int                 `json:"faces"`
+	Operator string              `json:"operator"`
+	Results  []foundryRollResult `json:"results"`
+}
+
+type foundryRollData struct {
+	Formula string            `json:"formula"`
+	Total   *float64          `json:"total"`
+	Terms   []foundryRollTerm `json:"terms"`
+}
+
+const foundryChatStyleOOC = 1
+
+func (p *structuredChatImportParser) parseFoundry(content string) ([]*model.ParsedLogEntry, int, int) {
+	records, total, skipped := decodeFoundryRecords(content)
+
+	// messages.db 为追加写入的 NeDB，同一 _id 以最后一条为准
+	order := make([]string, 0, len(records))
+	latest := make(map[string]*foundryChatMessage, len(records))
+	raws := make(map[string]string, len(records))
+	for i, raw := range records {
+		var msg foundryChatMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			skipped++
+			continue
+		}
+		key := msg.ID
+		if key == "" {
+			key = fmt.Sprintf("#%d", i)
+		}
+		if _, exists := latest[key]; !exists {
+			order = append(order, key)
+		}
+		latest[key] = &msg
+		raws[key] = string(raw)
+	}
+
+	var entries []*model.ParsedLogEntry
+	for index, key := range order {
+		msg := latest[key]
+		if msg.Deleted {
+			skipped++
+			continue
+		}
+		entry := foundryMessageToEntry(msg)
+		if entry == nil {
+			skipped++
+			continue
+		}
+		entry.RawLine = raws[key]
+		entry.LineNumber = index + 1
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Timestamp == nil || entries[j].Timestamp == nil {
+			return false
+		}
+		return entries[i].Timestamp.Before(*entries[j].Timestamp)
+	})
+	return entries, total, skipped
+}
+
+// decodeFoundryRecords 兼容 messages.db（逐行 JSON）、JSON 数组与 {"messages": [...]} 三种形式
+func decodeFoundryRecords(content string) ([]json.RawMessage, int, int) {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	if strings.HasPrefix(trimmed, "[") {
+		var records []json.RawMessage
+		if err := json.Unmarshal([]byte(trimmed), &records); err == nil {
+			return records, len(records), 0
+		}
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		var wrapper struct {
+			Messages []json.RawMessage `json:"messages"`
+		}
+		if err := json.Unmarshal([]byte(trimmed), &wrapper); err == nil && wrapper.Messages != nil {
+			return wrapper.Messages, len(wrapper.Messages), 0
+		}
+	}
+
+	var records []json.RawMessage
+	total, skipped := 0, 0
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		total++
+		if !json.Valid([]byte(line)) {
+			skipped++
+			continue
+		}
+		records = append(records, json.RawMessage(line))
+	}
+	return records, total, skipped
+}
+
+func foundryMessageToEntry(msg *foundryChatMessage) *model.ParsedLogEntry {
+	if msg.ID == "" && msg.Content == "" && msg.Timestamp == 0 {
+		// NeDB 的索引声明等非消息记录
+		return nil
+	}
+
+	var rolls []*model.ParsedLogDiceRoll
+	if roll := decodeFoundryRoll(msg.Roll); roll != nil {
+		rolls = append(rolls, roll)
+	}
+	for _, raw := range msg.Rolls {
+		if roll := decodeFoundryRoll(raw); roll != nil {
+			rolls = append(rolls, roll)
+		}
+	}
+
+	var parts []string
+	if flavor := vttHTMLToText(msg.Flavor); flavor != "" {
+		parts = append(parts, flavor)
+	}
+	if text := vttHTMLToText(msg.Content); text != "" && !foundryContentIsRollEcho(msg.Content, text, rolls) {
+		parts = append(parts, text)
+	}
+	for _, roll := range rolls {
+		parts = append(parts, roll.SourceText)
+	}
+	body := strings.Join(parts, "\n")
+	if strings.TrimSpace(body) == "" {
+		return nil
+	}
+
+	entry := &model.ParsedLogEntry{
+		RoleName:  foundrySpeakerName(msg),
+		Content:   body,
+		IsOOC:     foundryMessageIsOOC(msg),
+		DiceRolls: rolls,
+	}
+	if msg.Timestamp > 0 {
+		t := time.UnixMilli(msg.Timestamp)
+		entry.Timestamp = &t
+	}
+	return entry
+}
+
+// foundryContentIsRollEcho 掷骰消息的 content 通常只是总值或渲染后的骰子卡片，已由骰子胶囊替代
+func foundryContentIsRollEcho(rawContent string, text string, rolls []*model.ParsedLogDiceRoll) bool {
+	if len(rolls) == 0 {
+		return false
+	}
+	if strings.Contains(rawContent, "dice-roll") || strings.Contains(rawContent, "dice-result") {
+		return true
+	}
+	for _, roll := range rolls {
+		if text == roll.ResultValueText {
+			return true
+		}
+	}
+	return false
+}
+
+func foundrySpeakerName(msg *foundryChatMessage) string {
+	if alias := strings.TrimSpace(msg.Speaker.Alias); alias != "" {
+		return alias
+	}
+	userID := msg.Author
+	if userID == "" {
+		// user 字段可能是 ID 字符串，也可能是展开后的对象
+		var id string
+		if err := json.Unmarshal(msg.User, &id); err == nil {
+			userID = id
+		} else {
+			var obj struct {
+				ID   string `json:"_id"`
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(msg.User, &obj); err == nil {
+				if obj.Name != "" {
+					return obj.Name
+				}
+				userID = obj.ID
+			}
+		}
+	}
+	if userID == "" {
+		return "未知角色"
+	}
+	if len(userID) > 6 {
+		userID = userID[:6]
+	}
+	return "用户" + userID
+}
+
+func foundryMessageIsOOC(msg *foundryChatMessage) bool {
+	if msg.Style != nil {
+		return *msg.Style == foundryChatStyleOOC
+	}
+	var typ int
+	if err := json.Unmarshal(msg.Type, &typ); err == nil {
+		return typ == foundryChatStyleOOC
+	}
+	return false
+}
+
+// decodeFoundryRoll 解析单个 Roll，兼容序列化为字符串与直接嵌套对象两种存储方式
+func decodeFoundryRoll(raw json.RawMessage) *model.ParsedLogDiceRoll {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var inner string
+		if err := json.Unmarshal([]byte(trimmed), &inner); err != nil {
+			return nil
+		}
+		trimmed = inner
+	}
+	var data foundryRollData
+	if err := json.Unmarshal([]byte(trimmed), &data); err != nil {
+		return nil
+	}
+	formula := strings.TrimSpace(data.Formula)
+	if formula == "" {
+		return nil
+	}
+	roll := &model.ParsedLogDiceRoll{Formula: formula}
+	if data.Total == nil {
+		roll.IsError = true
+		roll.ResultText = formula
+		roll.SourceText = formula
+		return roll
+	}
+	value := strconv.FormatFloat(*data.Total, 'f', -1, 64)
+	roll.ResultValueText = value
+	roll.ResultText = fmt.Sprintf("%s = %s", formula, value)
+	roll.ResultDetail = foundryRollDetail(data.Terms)
+	if roll.ResultDetail == "" {
+		roll.ResultDetail = fmt.Sprintf("[%s=%s]", formula, value)
+	}
+	roll.SourceText = roll.ResultText
+	return roll
+}
+
+// foundryRollDetail 将骰项展开为与站内一致的 [NdF=a+b] 形式，遇到无法展开的骰项时返回空串
+func foundryRollDetail(terms []foundryRollTerm) string {
+	var sb strings.Builder
+	for _, term := range terms {
+		switch {
+		case term.Faces > 0 && len(term.Results) > 0:
+			var values []string
+			for _, result := range term.Results {
+				if result.Discarded || (result.Active != nil && !*result.Active) {
+					continue
+				}
+				values = append(values, strconv.FormatFloat(result.Result, 'f', -1, 64))
+			}
+			count := len(term.Results)
+			if term.Number != nil {
+				count = int(*term.Number)
+			}
+			fmt.Fprintf(&sb, "[%dd%d=%s]", count, term.Faces, strings.Join(values, "+"))
+		case term.Operator != "":
+			sb.WriteString(strings.TrimSpace(term.Operator))
+		case term.Number != nil && term.Faces == 0:
+			sb.WriteString(strconv.FormatFloat(*term.Number, 'f', -1, 64))
+		default:
+			return ""
+		}
+	}
+	return sb.String()
+}
+
+// ---- Roll20 ----
+
+func (p *structuredChatImportParser) parseRoll20(content string) ([]*model.ParsedLogEntry, int, int) {
+	doc, err := htmlnode.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, 0, 0
+	}
+	var messages []*htmlnode.Node
+	var collect func(node *htmlnode.Node)
+	collect = func(node *htmlnode.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == htmlnode.ElementNode && htmlNodeHasClass(htmlNodeAttr(child, "class"), "message") {
+				messages = append(messages, child)
+				continue
+			}
+			collect(child)
+		}
+	}
+	collect(doc)
+
+	var entries []*model.ParsedLogEntry
+	skipped := 0
+	speaker := ""
+	var lastTime *time.Time
+	for index, node := range messages {
+		className := htmlNodeAttr(node, "class")
+		if stamp := strings.TrimSpace(htmlNodeTextByClass(node, "tstamp")); stamp != "" {
+			if t := p.parseRoll20Timestamp(stamp, lastTime); t != nil {
+				lastTime = t
+			}
+		}
+
+		// 连续发言时 Roll20 会省略 .by，沿用上一条的发言人
+		if by := roll20SpeakerName(htmlNodeTextByClass(node, "by")); by != "" {
+			speaker = by
+		}
+		roleName := speaker
+		if htmlNodeHasClass(className, "desc") {
+			roleName = "旁白"
+		}
+
+		builder := &vttTextBuilder{hook: roll20NodeHook}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			builder.walk(child)
+		}
+		body := builder.String()
+		if body == "" || roleName == "" {
+			skipped++
+			continue
+		}
+
+		entry := &model.ParsedLogEntry{
+			RawLine:    body,
+			RoleName:   roleName,
+			Content:    body,
+			LineNumber: index + 1,
+			DiceRolls:  builder.rolls,
+		}
+		if lastTime != nil {
+			t := *lastTime
+			entry.Timestamp = &t
+		}
+		entries = append(entries, entry)
+	}
+	return entries, len(messages), skipped
+}
+
+// parseRoll20Timestamp 解析 Roll20 时间戳；仅有时刻时沿用上一条消息的日期
+func (p *structuredChatImportParser) parseRoll20Timestamp(stamp string, lastTime *time.Time) *time.Time {
+	stamp = vttWhitespacePattern.ReplaceAllString(strings.TrimSpace(stamp), " ")
+	loc := p.baseTime.Location()
+	for _, layout := range roll20TimestampLayouts {
+		if t, err := time.ParseInLocation(layout, stamp, loc); err == nil {
+			return &t
+		}
+	}
+	ref := p.baseTime
+	if lastTime != nil {
+		ref = *lastTime
+	}
+	for _, layout := range roll20TimeOnlyLayouts {
+		if t, err := time.ParseInLocation(layout, stamp, loc); err == nil {
+			full := time.Date(ref.Year(), ref.Month(), ref.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+			if lastTime != nil && full.Before(*lastTime) {
+				// 跨过午夜
+				full = full.AddDate(0, 0, 1)
+			}
+			return &full
+		}
+	}
+	return nil
+}
+
+func roll20SpeakerName(raw string) string {
+	name := strings.TrimSpace(raw)
+	name = strings.TrimSpace(strings.TrimSuffix(name, ":"))
+	if strings.HasPrefix(name, "(") && strings.HasSuffix(name, ")") {
+		// 私聊：(From 某人) 记为某人发言，(To 某人) 为存档者本人发出，沿用上一发言人
+		inner := strings.TrimSpace(name[1 : len(name)-1])
+		if strings.HasPrefix(inner, "From ") {
+			return strings.TrimSpace(strings.TrimPrefix(inner, "From "))
+		}
+		return ""
+	}
+	return name
+}
+
+// roll20NodeHook 处理 Roll20 消息中的元信息节点与掷骰节点，返回 true 表示已处理
+func roll20NodeHook(b *vttTextBuilder, node *htmlnode.Node) bool {
+	if node.Type != htmlnode.ElementNode {
+		return false
+	}
+	className := htmlNodeAttr(node, "class")
+	for _, skip := range []string{"spacer", "avatar", "tstamp", "by", "clear"} {
+		if htmlNodeHasClass(className, skip) {
+			return true
+		}
+	}
+
+	switch {
+	case htmlNodeHasClass(className, "inlinerollresult"):
+		value := strings.TrimSpace(htmlNodeText(node))
+		formula, detail := value, ""
+		if groups := roll20InlineRollTitlePattern.FindStringSubmatch(vttHTMLToText(htmlNodeAttr(node, "title"))); groups != nil {
+			formula = strings.TrimSpace(groups[1])
+			detail = strings.TrimSpace(groups[2])
+		}
+		b.addRoll(formula, detail, value)
+		return true
+	case htmlNodeHasClass(className, "formattedformula"):
+		// 详细骰面由 .rolled 一并输出
+		return true
+	case htmlNodeHasClass(className, "formula"):
+		formula := strings.TrimSpace(htmlNodeText(node))
+		if len(formula) >= len("rolling ") && strings.EqualFold(formula[:len("rolling ")], "rolling ") {
+			formula = strings.TrimSpace(formula[len("rolling "):])
+		}
+		b.pendingFormula = formula
+		return true
+	case htmlNodeHasClass(className, "rolled"):
+		value := strings.TrimSpace(htmlNodeText(node))
+		detail := ""
+		if parent := node.Parent; parent != nil {
+			detail = roll20DiceDetail(htmlNodeFindByClass(parent, "formattedformula"))
+		}
+		formula := b.pendingFormula
+		if formula == "" {
+			formula = value
+		}
+		b.pendingFormula = ""
+		b.addRoll(formula, detail, value)
+		return true
+	}
+	if node.Data == "strong" && strings.TrimSpace(htmlNodeText(node)) == "=" && b.pendingFormula != "" {
+		return true
+	}
+	return false
+}
+
+// roll20DiceDetail 将 formattedformula 中的骰面与修正值还原为可读文本
+func roll20DiceDetail(node *htmlnode.Node) string {
+	if node == nil {
+		return ""
+	}
+	var sb strings.Builder
+	var walk func(n *htmlnode.Node)
+	walk = func(n *htmlnode.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch {
+			case child.Type == htmlnode.TextNode:
+				sb.WriteString(strings.TrimSpace(child.Data))
+			case child.Type != htmlnode.ElementNode:
+			case htmlNodeHasClass(htmlNodeAttr(child, "class"), "backing"):
+			case htmlNodeHasClass(htmlNodeAttr(child, "class"), "diceroll"):
+				sb.WriteString(strings.TrimSpace(htmlNodeTextByClass(child, "didroll")))
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return sb.String()
+}
+
+// ---- 通用 HTML 文本提取 ----
+
+// vttTextBuilder 将 VTT 导出的 HTML 片段转为纯文本，并在原位置记录掷骰结果
+type vttTextBuilder struct {
+	sb             strings.Builder
+	rolls          []*model.ParsedLogDiceRoll
+	hook           func(b *vttTextBuilder, node *htmlnode.Node) bool
+	pendingFormula string
+}
+
+var vttBlockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "ul": true, "ol": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "section": true, "header": true, "footer": true,
+}
+
+func (b *vttTextBuilder) walk(node *htmlnode.Node) {
+	if b.hook != nil && b.hook(b, node) {
+		return
+	}
+	switch node.Type {
+	case htmlnode.TextNode:
+		b.writeText(node.Data)
+		return
+	case htmlnode.ElementNode, htmlnode.DocumentNode:
+	default:
+		return
+	}
+	tag := strings.ToLower(node.Data)
+	switch tag {
+	case "script", "style", "template":
+		return
+	case "br":
+		b.newline()
+		return
+	case "img":
+		if alt := strings.TrimSpace(htmlNodeAttr(node, "alt")); alt != "" {
+			b.writeText("[" + alt + "]")
+		}
+		return
+	case "td", "th":
+		b.writeText(" ")
+	}
+	block := vttBlockElements[tag]
+	if block {
+		b.newline()
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		b.walk(child)
+	}
+	if block {
+		b.newline()
+	}
+}
+
+func (b *vttTextBuilder) writeText(text string) {
+	text = vttWhitespacePattern.ReplaceAllString(text, " ")
+	if text == "" {
+		return
+	}
+	current := b.sb.String()
+	if current == "" || strings.HasSuffix(current, " ") || strings.HasSuffix(current, "\n") {
+		text = strings.TrimLeft(text, " ")
+	}
+	b.sb.WriteString(text)
+}
+
+func (b *vttTextBuilder) newline() {
+	current := b.sb.String()
+	if current != "" && !strings.HasSuffix(current, "\n") {
+		b.sb.WriteString("\n")
+	}
+}
+
+func (b *vttTextBuilder) addRoll(formula string, detail string, value string) {
+	if formula == "" && value == "" {
+		return
+	}
+	roll := &model.ParsedLogDiceRoll{
+		Formula:         formula,
+		ResultValueText: value,
+		ResultText:      fmt.Sprintf("%s = %s", formula, value),
+	}
+	if detail != "" {
+		roll.ResultDetail = fmt.Sprintf("[%s=%s]", formula, detail)
+	}
+	roll.SourceText = roll.ResultText
+	b.rolls = append(b.rolls, roll)
+	b.writeText(" " + roll.SourceText + " ")
+}
+
+// String 返回整理后的文本：去除行首尾空白并合并连续空行
+func (b *vttTextBuilder) String() string {
+	lines := strings.Split(b.sb.String(), "\n")
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// vttHTMLToText 将 HTML 片段转为纯文本
+func vttHTMLToText(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "<") && !strings.Contains(raw, "&") {
+		return strings.TrimSpace(raw)
+	}
+	nodes, err := htmlnode.ParseFragment(strings.NewReader(raw), nil)
+	if err != nil {
+		return strings.TrimSpace(raw)
+	}
+	builder := &vttTextBuilder{}
+	for _, node := range nodes {
+		builder.walk(node)
+	}
+	return builder.String()
+}
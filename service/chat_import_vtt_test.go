@@ -0,0 +1,154 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestFoundryChatImportParsesMessagesDB(t *testing.T) {
+	content := strings.Join([]string{
+		`{"_id":"m1","type":2,"user":"abcdef123","timestamp":1700000001000,"speaker":{"alias":"木落"},"content":"<p>你好&nbsp;世界</p>"}`,
+		`{"_id":"m2","type":5,"user":"abcdef123","timestamp":1700000002000,"speaker":{"alias":"木落"},"flavor":"侦查","content":"17","roll":"{\"class\":\"Roll\",\"formula\":\"1d20 + 5\",\"total\":17,\"terms\":[{\"class\":\"Die\",\"number\":1,\"faces\":20,\"results\":[{\"result\":12,\"active\":true}]},{\"class\":\"OperatorTerm\",\"operator\":\"+\"},{\"class\":\"NumericTerm\",\"number\":5}]}"}`,
+		`{"_id":"m3","type":1,"user":"zzz999999","timestamp":1700000003000,"speaker":{},"content":"（去倒杯水）"}`,
+		`{"_id":"m4","timestamp":1700000004000,"speaker":{"alias":"木落"},"content":"将被删除"}`,
+		`{"_id":"m4","$$deleted":true}`,
+		`{"_id":"m5","style":0,"timestamp":1700000000000,"speaker":{"alias":"KP"},"content":"开场","rolls":[{"formula":"2d6","total":7,"terms":[{"class":"Die","number":2,"faces":6,"results":[{"result":3,"active":true},{"result":4,"active":true}]}]}]}`,
+		`not json`,
+	}, "\n")
+
+	parser, err := newChatImportEntryParser(&model.ChatImportConfig{TemplateID: chatImportTemplateFoundryVTT})
+	if err != nil {
+		t.Fatalf("create parser failed: %v", err)
+	}
+	entries, total, skipped := parser.ParseLogContent(content)
+	if total != 7 || skipped != 2 || len(entries) != 4 {
+		t.Fatalf("unexpected counts: total=%d skipped=%d entries=%d", total, skipped, len(entries))
+	}
+	if entries[0].RoleName != "KP" || entries[0].Content != "开场\n2d6 = 7" || entries[0].DiceRolls[0].ResultDetail != "[2d6=3+4]" {
+		t.Fatalf("expected entries sorted by timestamp, got %+v", entries[0])
+	}
+	if entries[1].Content != "你好 世界" {
+		t.Fatalf("unexpected content: %q", entries[1].Content)
+	}
+	rollEntry := entries[2]
+	if rollEntry.Content != "侦查\n1d20 + 5 = 17" || len(rollEntry.DiceRolls) != 1 {
+		t.Fatalf("unexpected roll entry: %q", rollEntry.Content)
+	}
+	if roll := rollEntry.DiceRolls[0]; roll.ResultValueText != "17" || roll.ResultDetail != "[1d20=12]+5" {
+		t.Fatalf("unexpected roll: %+v", roll)
+	}
+	if entries[3].RoleName != "用户zzz999" || !entries[3].IsOOC {
+		t.Fatalf("expected ooc fallback speaker, got %+v", entries[3])
+	}
+}
+
+func TestRoll20ChatImportParsesArchiveHTML(t *testing.T) {
+	content := `<html><body><div id="textchat"><div class="content">
+<div class="message general" data-messageid="1"><div class="spacer"></div><div class="avatar"><img src="a.png"></div><span class="tstamp">March 11, 2020 8:53PM</span><span class="by">木落:</span>大家好</div>
+<div class="message general" data-messageid="2">攻击 <span class="inlinerollresult showtip" title="Rolling 1d20+5 = (&lt;span class=&quot;basicdiceroll&quot;&gt;12&lt;/span&gt;)+5">17</span> 点</div>
+<div class="message rollresult" data-messageid="3"><span class="tstamp">8:53PM</span><span class="by">KP:</span><div class="formula" style="margin-bottom: 3px;">rolling 2d6+1 </div><div class="clear"></div><div class="formula formattedformula"><div class="dicegrouping">(<div class="diceroll d6"><div class="dicon"><div class="didroll">3</div><div class="backing"></div></div></div>+<div class="diceroll d6"><div class="dicon"><div class="didroll">5</div><div class="backing"></div></div></div>)</div>+1<div class="clear"></div></div><div class="clear"></div><strong>=</strong><div class="rolled">9</div></div>
+<div class="message desc" data-messageid="4">夜幕降临</div>
+<div class="message private" data-messageid="5"><span class="by">(From 玩家):</span>(悄悄话)</div>
+</div></div></body></html>`
+
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	parser, err := newChatImportEntryParser(&model.ChatImportConfig{TemplateID: chatImportTemplateRoll20, BaseTime: &base})
+	if err != nil {
+		t.Fatalf("create parser failed: %v", err)
+	}
+	entries, total, skipped := parser.ParseLogContent(content)
+	if total != 5 || skipped != 0 || len(entries) != 5 {
+		t.Fatalf("unexpected counts: total=%d skipped=%d entries=%d", total, skipped, len(entries))
+	}
+	expectRoles := []string{"木落", "木落", "KP", "旁白", "玩家"}
+	for i, role := range expectRoles {
+		if entries[i].RoleName != role {
+			t.Fatalf("entry %d role = %q, want %q", i, entries[i].RoleName, role)
+		}
+		if i > 0 && !entries[i].Timestamp.After(*entries[i-1].Timestamp) {
+			t.Fatalf("expected strictly increasing timestamps at %d", i)
+		}
+	}
+	if got := entries[0].Timestamp.Format("2006-01-02 15:04"); got != "2020-03-11 20:53" {
+		t.Fatalf("unexpected timestamp: %s", got)
+	}
+	if entries[1].Content != "攻击 1d20+5 = 17 点" || entries[1].DiceRolls[0].ResultDetail != "[1d20+5=(12)+5]" {
+		t.Fatalf("unexpected inline roll: %q %+v", entries[1].Content, entries[1].DiceRolls)
+	}
+	if entries[2].Content != "2d6+1 = 9" || entries[2].DiceRolls[0].ResultDetail != "[2d6+1=(3+5)+1]" {
+		t.Fatalf("unexpected roll result: %q %+v", entries[2].Content, *entries[2].DiceRolls[0])
+	}
+	if !entries[4].IsOOC {
+		t.Fatalf("expected parenthesized private message to be ooc")
+	}
+}
+
+func TestStructuredChatImportInsertsDiceRollsAndReusesIdentity(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+
+	worldID := "world-" + utils.NewID()
+	channelID := "ch-import-" + utils.NewID()
+	otherChannelID := "ch-other-" + utils.NewID()
+	userID := "user-" + utils.NewID()
+	for _, id := range []string{channelID, otherChannelID} {
+		if err := db.Create(&model.ChannelModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id},
+			Name:              "Import Channel",
+			WorldID:           worldID,
+			PermType:          "public",
+			Status:            model.ChannelStatusActive,
+		}).Error; err != nil {
+			t.Fatalf("create channel failed: %v", err)
+		}
+	}
+	if err := db.Create(&model.ChannelIdentityModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "identity-" + utils.NewID()},
+		ChannelID:         otherChannelID,
+		UserID:            userID,
+		DisplayName:       "木落",
+		Color:             "#336699",
+	}).Error; err != nil {
+		t.Fatalf("create identity failed: %v", err)
+	}
+
+	parser, _ := newChatImportEntryParser(&model.ChatImportConfig{TemplateID: chatImportTemplateFoundryVTT})
+	entries, _, _ := parser.ParseLogContent(`{"_id":"r1","timestamp":1700000000000,"speaker":{"alias":"木落"},"flavor":"<b>侦查</b>","rolls":["{\"formula\":\"1d100\",\"total\":42,\"terms\":[{\"class\":\"Die\",\"number\":1,\"faces\":100,\"results\":[{\"result\":42}]}]}"]}`)
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(entries))
+	}
+
+	roleMapping := matchImportRoleMappingByName(worldID, channelID, userID, ExtractRoleNames(entries), nil)
+	identityMap, err := resolveImportIdentities(channelID, userID, entries, roleMapping)
+	if err != nil {
+		t.Fatalf("resolve identities failed: %v", err)
+	}
+	identity := identityMap["木落"]
+	if identity == nil || identity.ChannelID != channelID || identity.Color != "#336699" {
+		t.Fatalf("expected identity cloned from reusable template, got %+v", identity)
+	}
+
+	jobID := "job-" + utils.NewID()
+	count, err := batchInsertImportedMessages(jobID, channelID, entries, identityMap)
+	if err != nil || count != 1 {
+		t.Fatalf("insert failed: count=%d err=%v", count, err)
+	}
+	var msg model.MessageModel
+	if err := db.Where("channel_id = ? AND import_job_id = ?", channelID, jobID).First(&msg).Error; err != nil {
+		t.Fatalf("load message failed: %v", err)
+	}
+	if !msg.IsImported || !strings.HasPrefix(msg.Content, "侦查<br />") || !strings.Contains(msg.Content, `data-dice-roll-index="0"`) {
+		t.Fatalf("unexpected imported message: %+v", msg)
+	}
+	rolls, err := model.MessageDiceRollListByMessageID(msg.ID)
+	if err != nil || len(rolls) != 1 {
+		t.Fatalf("expected one dice roll row, got %d (%v)", len(rolls), err)
+	}
+	if rolls[0].Formula != "1d100" || rolls[0].ResultValueText != "42" || rolls[0].ResultDetail != "[1d100=42]" {
+		t.Fatalf("unexpected dice roll row: %+v", rolls[0])
+	}
+}
@@ -77,6 +77,12 @@ func DefaultDatabaseCleanupTools() []DatabaseCleanupTool {
 				return total, nil
 			},
 		},
+		{
+			Name: "message_draft_expired_cleanup",
+			Run: func(now time.Time) (int64, error) {
+				return model.MessageDraftCleanupExpired(now)
+			},
+		},
 		{
 			Name: "channel_latest_read_orphan_cleanup",
 			Run: func(now time.Time) (int64, error) {
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+)
+
+// DiceCardLoader 按需加载发送者当前生效的角色卡，仅在表达式引用了变量时才会调用；
+// 没有可用角色卡时返回 nil
+type DiceCardLoader func() (*model.CharacterCardModel, error)
+
+// dnd 系卡片的属性调整值可由属性本身推导，如 力量调整 = floor((力量-10)/2)
+const diceCardModifierSuffix = "调整"
+
+// diceInternalVarNames 骰点引擎内部引用的变量，角色卡未定义时不视为缺失
+var diceInternalVarNames = map[string]struct{}{
+	"面数": {},
+}
+
+// NewDiceCardLoader 解析发送者所用身份绑定的角色卡，身份未绑定时回退到频道内的默认角色卡
+func NewDiceCardLoader(userID string, channelID string, identityID string) DiceCardLoader {
+	return func() (*model.CharacterCardModel, error) {
+		identityID = strings.TrimSpace(identityID)
+		if identityID != "" {
+			identity, err := model.ChannelIdentityGetByID(identityID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			if identity != nil && identity.UserID == userID && identity.CharacterCardID != "" {
+				card, err := model.CharacterCardGetByID(identity.CharacterCardID)
+				if err == nil && card.UserID == userID && card.ChannelID == channelID {
+					ensureCharacterCardAttrs(card)
+					return card, nil
+				}
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, err
+				}
+			}
+		}
+		card, err := CharacterCardResolveForChannel(userID, channelID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return card, nil
+	}
+}
+
+// diceCardAttrs 记录一次渲染中加载的角色卡，避免同一条消息多次查询
+type diceCardAttrs struct {
+	load   DiceCardLoader
+	loaded bool
+	card   *model.CharacterCardModel
+	err    error
+}
+
+func (c *diceCardAttrs) resolve() (*model.CharacterCardModel, error) {
+	if c == nil || c.load == nil {
+		return nil, nil
+	}
+	if !c.loaded {
+		c.loaded = true
+		c.card, c.err = c.load()
+	}
+	return c.card, c.err
+}
+
+// lookup 按属性名读取角色卡数值，名称不区分大小写
+func (c *diceCardAttrs) lookup(name string) (*ds.VMValue, bool) {
+	card, err := c.resolve()
+	if err != nil || card == nil || len(card.Attrs) == 0 {
+		return nil, false
+	}
+	if value, ok := diceCardAttrValue(card.Attrs, name); ok {
+		return value, true
+	}
+	base := strings.TrimSuffix(name, diceCardModifierSuffix)
+	if base == name || !strings.HasPrefix(strings.ToLower(card.SheetType), "dnd") {
+		return nil, false
+	}
+	value, ok := diceCardAttrValue(card.Attrs, base)
+	if !ok || value.TypeId != ds.VMTypeInt {
+		return nil, false
+	}
+	score := float64(value.MustReadInt())
+	return ds.NewIntVal(ds.IntType(math.Floor((score - 10) / 2))), true
+}
+
+func (c *diceCardAttrs) has(name string) bool {
+	_, ok := c.lookup(name)
+	return ok
+}
+
+// missingError 生成缺失属性时的错误提示
+func (c *diceCardAttrs) missingError(name string) error {
+	card, err := c.resolve()
+	if err != nil {
+		return fmt.Errorf("读取角色卡失败，无法解析属性「%s」", name)
+	}
+	if card == nil {
+		return fmt.Errorf("未找到可用的角色卡，无法解析属性「%s」", name)
+	}
+	return fmt.Errorf("角色卡「%s」中没有属性「%s」", card.Name, name)
+}
+
+func diceCardAttrValue(attrs model.JSONMap, name string) (*ds.VMValue, bool) {
+	raw, ok := attrs[name]
+	if !ok {
+		for key, value := range attrs {
+			if strings.EqualFold(key, name) {
+				raw, ok = value, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	switch v := raw.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return ds.NewIntVal(ds.IntType(v)), true
+		}
+		return ds.NewFloatVal(v), true
+	case int:
+		return ds.NewIntVal(ds.IntType(v)), true
+	case int64:
+		return ds.NewIntVal(ds.IntType(v)), true
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if n, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return ds.NewIntVal(ds.IntType(n)), true
+		}
+		if f, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return ds.NewFloatVal(f), true
+		}
+		return ds.NewStrVal(v), true
+	}
+	return nil, false
+}
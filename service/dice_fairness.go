@@ -0,0 +1,283 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ds "github.com/sealdice/dicescript"
+	exprand "golang.org/x/exp/rand"
+
+	"sealchat/model"
+)
+
+// DiceFairnessWindowDuration 种子窗口长度，窗口按整点对齐，结束后公开种子
+var DiceFairnessWindowDuration = time.Hour
+
+var diceFairnessNow = time.Now
+
+const (
+	diceFairnessSeedBytes       = 32
+	diceFairnessDefaultListSize = 24
+	diceFairnessMaxListSize     = 200
+)
+
+// 掷骰验证状态
+const (
+	DiceFairnessVerified     = "verified"     // 复算结果与记录一致
+	DiceFairnessMismatch     = "mismatch"     // 复算结果与记录不一致
+	DiceFairnessPending      = "pending"      // 窗口尚未结束，种子未公开
+	DiceFairnessUnverifiable = "unverifiable" // 非可验证模式下的掷骰
+)
+
+// DiceFairnessContext 可验证骰点模式下渲染掷骰所需的上下文，MessageID 必须在渲染前确定
+type DiceFairnessContext struct {
+	MessageID string
+	Window    *model.DiceFairnessWindowModel
+}
+
+// DiceFairnessInputs 复算掷骰所需的公开输入，随掷骰记录保存
+type DiceFairnessInputs struct {
+	DefaultDiceExpr string         `json:"defaultDiceExpr"`
+	Attrs           map[string]any `json:"attrs,omitempty"` // 掷骰时实际读取的角色卡属性值
+}
+
+// DiceFairnessWindowView 对外公开的窗口信息，种子仅在窗口结束后给出
+type DiceFairnessWindowView struct {
+	ID          string    `json:"id"`
+	ChannelID   string    `json:"channelId"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+	SeedHash    string    `json:"seedHash"`
+	Seed        string    `json:"seed,omitempty"`
+	Revealed    bool      `json:"revealed"`
+}
+
+// DiceFairnessVerifyResult 单个掷骰的验证结果
+type DiceFairnessVerifyResult struct {
+	RollIndex       int                     `json:"rollIndex"`
+	Formula         string                  `json:"formula"`
+	Status          string                  `json:"status"`
+	ResultDetail    string                  `json:"resultDetail"`
+	ResultValueText string                  `json:"resultValueText"`
+	ExpectedDetail  string                  `json:"expectedDetail,omitempty"`
+	ExpectedValue   string                  `json:"expectedValue,omitempty"`
+	Window          *DiceFairnessWindowView `json:"window,omitempty"`
+}
+
+// DiceFairnessWindowFor 返回频道当前的种子窗口，不存在时生成新种子
+func DiceFairnessWindowFor(channelID string) (*model.DiceFairnessWindowModel, error) {
+	if strings.TrimSpace(channelID) == "" {
+		return nil, errors.New("频道ID不能为空")
+	}
+	start := diceFairnessNow().UTC().Truncate(DiceFairnessWindowDuration)
+	seed := make([]byte, diceFairnessSeedBytes)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(seed)
+	return model.DiceFairnessWindowGetOrCreate(&model.DiceFairnessWindowModel{
+		ChannelID:   channelID,
+		WindowStart: start,
+		WindowEnd:   start.Add(DiceFairnessWindowDuration),
+		SeedHash:    hex.EncodeToString(hash[:]),
+		Seed:        hex.EncodeToString(seed),
+	})
+}
+
+// NewDiceFairnessContext 为即将发送的消息准备可验证骰点上下文
+func NewDiceFairnessContext(channelID string, messageID string) (*DiceFairnessContext, error) {
+	window, err := DiceFairnessWindowFor(channelID)
+	if err != nil {
+		return nil, err
+	}
+	return &DiceFairnessContext{MessageID: messageID, Window: window}, nil
+}
+
+// DiceFairnessRevealed 窗口结束后种子即可公开
+func DiceFairnessRevealed(window *model.DiceFairnessWindowModel) bool {
+	return window != nil && !diceFairnessNow().Before(window.WindowEnd)
+}
+
+// DiceFairnessWindowToView 转换为对外结构，未结束的窗口不包含种子
+func DiceFairnessWindowToView(window *model.DiceFairnessWindowModel) *DiceFairnessWindowView {
+	if window == nil {
+		return nil
+	}
+	view := &DiceFairnessWindowView{
+		ID:          window.ID,
+		ChannelID:   window.ChannelID,
+		WindowStart: window.WindowStart,
+		WindowEnd:   window.WindowEnd,
+		SeedHash:    window.SeedHash,
+	}
+	if DiceFairnessRevealed(window) {
+		view.Seed = window.Seed
+		view.Revealed = true
+	}
+	return view
+}
+
+// DiceFairnessWindowList 列出频道最近的种子窗口
+func DiceFairnessWindowList(channelID string, limit int) ([]*DiceFairnessWindowView, error) {
+	if limit <= 0 {
+		limit = diceFairnessDefaultListSize
+	}
+	if limit > diceFairnessMaxListSize {
+		limit = diceFairnessMaxListSize
+	}
+	items, err := model.DiceFairnessWindowList(channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]*DiceFairnessWindowView, 0, len(items))
+	for _, item := range items {
+		views = append(views, DiceFairnessWindowToView(item))
+	}
+	return views, nil
+}
+
+// diceFairnessSource 由种子、消息ID与掷骰序号派生随机源：
+// HMAC-SHA256(seed, "<messageID>:<rollIndex>") 的前 8 字节（大端）作为 PCG 种子
+func diceFairnessSource(seedHex string, messageID string, rollIndex int) (*exprand.PCGSource, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil || len(seed) == 0 {
+		return nil, errors.New("种子格式无效")
+	}
+	mac := hmac.New(sha256.New, seed)
+	fmt.Fprintf(mac, "%s:%d", messageID, rollIndex)
+	source := &exprand.PCGSource{}
+	source.Seed(binary.BigEndian.Uint64(mac.Sum(nil)[:8]))
+	return source, nil
+}
+
+// DiceFairnessVerifyMessage 用公开数据复算消息中的全部掷骰
+func DiceFairnessVerifyMessage(messageID string) ([]*DiceFairnessVerifyResult, error) {
+	rolls, err := model.MessageDiceRollListByMessageID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	windowIDs := []string{}
+	for _, roll := range rolls {
+		if roll.FairWindowID != "" {
+			windowIDs = append(windowIDs, roll.FairWindowID)
+		}
+	}
+	windows, err := model.DiceFairnessWindowGetByIDs(windowIDs)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*DiceFairnessVerifyResult, 0, len(rolls))
+	for _, roll := range rolls {
+		results = append(results, DiceFairnessVerifyRoll(roll, windows[roll.FairWindowID]))
+	}
+	return results, nil
+}
+
+// DiceFairnessVerifyRoll 复算单个掷骰；窗口未结束时只返回承诺信息
+func DiceFairnessVerifyRoll(roll *model.MessageDiceRollModel, window *model.DiceFairnessWindowModel) *DiceFairnessVerifyResult {
+	result := &DiceFairnessVerifyResult{
+		RollIndex:       roll.RollIndex,
+		Formula:         roll.Formula,
+		ResultDetail:    roll.ResultDetail,
+		ResultValueText: roll.ResultValueText,
+		Window:          DiceFairnessWindowToView(window),
+	}
+	switch {
+	case roll.FairWindowID == "" || window == nil:
+		result.Status = DiceFairnessUnverifiable
+		return result
+	case !DiceFairnessRevealed(window):
+		result.Status = DiceFairnessPending
+		return result
+	}
+	computed, err := diceFairnessRecompute(roll, window.Seed)
+	if err != nil {
+		result.Status = DiceFairnessMismatch
+		return result
+	}
+	result.ExpectedDetail = computed.ResultDetail
+	result.ExpectedValue = computed.ResultValueText
+	result.Status = DiceFairnessMismatch
+	if diceFairnessSameResult(roll, computed) {
+		result.Status = DiceFairnessVerified
+	}
+	return result
+}
+
+// diceFairnessRecompute 以记录中的公式、规则系统与公开输入重新掷骰
+func diceFairnessRecompute(roll *model.MessageDiceRollModel, seedHex string) (*model.MessageDiceRollModel, error) {
+	var inputs DiceFairnessInputs
+	if roll.FairInputs != "" {
+		if err := json.Unmarshal([]byte(roll.FairInputs), &inputs); err != nil {
+			return nil, err
+		}
+	}
+	renderer := newDiceRenderer(inputs.DefaultDiceExpr, nil)
+	renderer.ruleSystem = roll.RuleSystem
+	renderer.cardAttrs = &diceCardAttrs{load: func() (*model.CharacterCardModel, error) {
+		if len(inputs.Attrs) == 0 {
+			return nil, nil
+		}
+		return &model.CharacterCardModel{Attrs: model.JSONMap(inputs.Attrs)}, nil
+	}}
+	source, err := diceFairnessSource(seedHex, roll.MessageID, roll.RollIndex)
+	if err != nil {
+		return nil, err
+	}
+	renderer.randSrc = source
+	return renderer.computeRoll(roll.Formula), nil
+}
+
+func diceFairnessSameResult(stored *model.MessageDiceRollModel, computed *model.MessageDiceRollModel) bool {
+	if stored.IsError || computed.IsError {
+		// 错误提示文本依赖角色卡名称等非公开信息，只比较是否同为错误
+		return stored.IsError == computed.IsError
+	}
+	return stored.ResultValueText == computed.ResultValueText &&
+		stored.ResultDetail == computed.ResultDetail &&
+		stored.Outcome == computed.Outcome
+}
+
+// beginFairRoll 为指定序号的掷骰准备派生随机源并开始记录读取的属性
+func (r *diceRenderer) beginFairRoll(index int) error {
+	source, err := diceFairnessSource(r.fairness.Window.Seed, r.fairness.MessageID, index)
+	if err != nil {
+		return err
+	}
+	r.randSrc = source
+	r.fairAttrs = map[string]any{}
+	return nil
+}
+
+// endFairRoll 将窗口与公开输入写入掷骰记录
+func (r *diceRenderer) endFairRoll(roll *model.MessageDiceRollModel) {
+	inputs := DiceFairnessInputs{DefaultDiceExpr: r.defaultDiceExpr}
+	if len(r.fairAttrs) > 0 {
+		inputs.Attrs = r.fairAttrs
+	}
+	if data, err := json.Marshal(inputs); err == nil {
+		roll.FairInputs = string(data)
+	}
+	roll.FairWindowID = r.fairness.Window.ID
+	r.randSrc = nil
+	r.fairAttrs = nil
+}
+
+// diceFairnessAttrValue 将属性值转为可 JSON 序列化的形式，复算时经 diceCardAttrValue 还原
+func diceFairnessAttrValue(value *ds.VMValue) any {
+	switch value.TypeId {
+	case ds.VMTypeInt:
+		return int64(value.MustReadInt())
+	case ds.VMTypeFloat:
+		return value.MustReadFloat()
+	}
+	return value.ToString()
+}
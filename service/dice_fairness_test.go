@@ -0,0 +1,110 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"sealchat/model"
+)
+
+func setupDiceFairnessTest(t *testing.T) *time.Time {
+	t.Helper()
+	initTestDB(t)
+	now := time.Date(2026, 6, 1, 20, 15, 0, 0, time.UTC)
+	prev := diceFairnessNow
+	diceFairnessNow = func() time.Time { return now }
+	t.Cleanup(func() { diceFairnessNow = prev })
+	return &now
+}
+
+func TestDiceFairnessDeterministicRolls(t *testing.T) {
+	setupDiceFairnessTest(t)
+	first, err := NewDiceFairnessContext("ch-fair", "msg-1")
+	if err != nil {
+		t.Fatalf("create window failed: %v", err)
+	}
+	again, err := NewDiceFairnessContext("ch-fair", "msg-1")
+	if err != nil || again.Window.ID != first.Window.ID {
+		t.Fatalf("same hour should reuse the window: %+v err=%v", again.Window, err)
+	}
+	if !first.Window.WindowStart.Equal(time.Date(2026, 6, 1, 20, 0, 0, 0, time.UTC)) {
+		t.Fatalf("window should align to the hour: %v", first.Window.WindowStart)
+	}
+
+	render := func(fairness *DiceFairnessContext) *DiceRenderResult {
+		result, err := RenderDiceContentWithOptions("{10d100} {3#d20}", "d20", nil, DiceRenderOptions{Fairness: fairness})
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		return result
+	}
+	a, b := render(first), render(again)
+	for i := range a.Rolls {
+		if a.Rolls[i].ResultDetail != b.Rolls[i].ResultDetail || a.Rolls[i].FairWindowID != first.Window.ID {
+			t.Fatalf("roll %d should be derived from the seed: %+v vs %+v", i, a.Rolls[i], b.Rolls[i])
+		}
+	}
+	other := render(&DiceFairnessContext{MessageID: "msg-2", Window: first.Window})
+	if other.Rolls[0].ResultDetail == a.Rolls[0].ResultDetail {
+		t.Fatalf("different messages should not share rolls: %s", a.Rolls[0].ResultDetail)
+	}
+}
+
+func TestDiceFairnessVerifyAfterReveal(t *testing.T) {
+	now := setupDiceFairnessTest(t)
+	fairness, err := NewDiceFairnessContext("ch-fair", "msg-verify")
+	if err != nil {
+		t.Fatalf("create window failed: %v", err)
+	}
+	loadCard := func() (*model.CharacterCardModel, error) {
+		return &model.CharacterCardModel{
+			Name:      "约翰",
+			SheetType: "dnd5e",
+			Attrs:     model.JSONMap{"力量": "16", "感知": float64(12)},
+		}, nil
+	}
+	result, err := RenderDiceContentWithOptions("{ra力量调整dc12} {d20+感知} {2d6}", "d20", nil, DiceRenderOptions{RuleSystem: DiceRuleSystemDnD5e, LoadCard: loadCard, Fairness: fairness})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if err := model.MessageDiceRollReplace("msg-verify", result.Rolls); err != nil {
+		t.Fatalf("save rolls failed: %v", err)
+	}
+
+	pending, err := DiceFairnessVerifyMessage("msg-verify")
+	if err != nil || len(pending) != 3 {
+		t.Fatalf("verify failed: %v %+v", err, pending)
+	}
+	if pending[0].Status != DiceFairnessPending || pending[0].Window.Seed != "" {
+		t.Fatalf("seed must stay hidden before the window ends: %+v", pending[0].Window)
+	}
+
+	*now = now.Add(DiceFairnessWindowDuration)
+	verified, err := DiceFairnessVerifyMessage("msg-verify")
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	for _, item := range verified {
+		if item.Status != DiceFairnessVerified {
+			t.Fatalf("roll %d should verify: %+v", item.RollIndex, item)
+		}
+	}
+	seed, _ := hex.DecodeString(verified[0].Window.Seed)
+	if hash := sha256.Sum256(seed); hex.EncodeToString(hash[:]) != verified[0].Window.SeedHash {
+		t.Fatalf("revealed seed should match the commitment")
+	}
+
+	tampered := result.Rolls[2]
+	model.GetDB().Model(tampered).Update("result_value_text", "99")
+	if items, _ := DiceFairnessVerifyMessage("msg-verify"); items[2].Status != DiceFairnessMismatch {
+		t.Fatalf("tampered roll should not verify: %+v", items[2])
+	}
+
+	plain, _ := RenderDiceContent("{d20}", "d20", nil)
+	_ = model.MessageDiceRollReplace("msg-plain", plain.Rolls)
+	if items, _ := DiceFairnessVerifyMessage("msg-plain"); items[0].Status != DiceFairnessUnverifiable {
+		t.Fatalf("ordinary roll should be unverifiable: %+v", items[0])
+	}
+}
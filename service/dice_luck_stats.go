@@ -0,0 +1,493 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sealchat/model"
+)
+
+// 骰运统计的分组方式
+const (
+	DiceLuckGroupByUser     = "user"
+	DiceLuckGroupByIdentity = "identity"
+	DiceLuckGroupByChannel  = "channel"
+)
+
+const (
+	// 面数不超过该值的骰子才统计点数分布与卡方检验
+	diceLuckMaxHistogramSides = 100
+	// 卡方检验要求每个点数的期望次数不少于 5
+	diceLuckMinExpectedPerFace = 5
+)
+
+var (
+	diceLuckListPattern   = regexp.MustCompile(`\[(\d*)d(\d+)=(\d+(?:\+\d+)*)\]`)
+	diceLuckKeepPattern   = regexp.MustCompile(`\[(\d*)d(\d+)[a-z]+\d*=\{([\d\s|]+)\}\]`)
+	diceLuckSinglePattern = regexp.MustCompile(`(\d+)\[1?d(\d+)\]`)
+	diceLuckPlainPattern  = regexp.MustCompile(`^1?d(\d+)\s*(?:([+-])\s*(\d+))?$`)
+)
+
+// DiceLuckQuery 骰运统计请求
+type DiceLuckQuery struct {
+	model.DiceLuckFilter
+	GroupBy string
+}
+
+// DiceLuckReport 骰运统计结果，Groups 按 LuckZ 升序排列，排在最前的即“今晚最非”的
+type DiceLuckReport struct {
+	GroupBy   string           `json:"groupBy"`
+	StartTime *time.Time       `json:"startTime,omitempty"`
+	EndTime   *time.Time       `json:"endTime,omitempty"`
+	Overall   *DiceLuckStats   `json:"overall"`
+	Groups    []*DiceLuckStats `json:"groups"`
+}
+
+// DiceLuckStats 一组掷骰的统计
+type DiceLuckStats struct {
+	Key       string                    `json:"key"`
+	Name      string                    `json:"name"`
+	RollCount int                       `json:"rollCount"`
+	DiceCount int                       `json:"diceCount"`
+	LuckScore float64                   `json:"luckScore"` // 骰面在各自范围内的平均位置，0.5 为期望，越高越欧；CoC 检定按出目越低越好换算
+	LuckZ     float64                   `json:"luckZ"`     // 标准化后的总偏离，|z| > 2 可视为明显偏离期望
+	Sides     []*DiceLuckSidesStats     `json:"sides"`
+	Outcomes  map[string]map[string]int `json:"outcomes,omitempty"` // 规则系统 -> 检定结果 -> 次数
+
+	stdSum   float64
+	scoreSum float64
+	sidesMap map[int]*DiceLuckSidesStats
+	latestAt time.Time
+}
+
+// DiceLuckSidesStats 同一面数骰子的分布
+type DiceLuckSidesStats struct {
+	Sides            int     `json:"sides"`
+	Count            int     `json:"count"`
+	Mean             float64 `json:"mean"`
+	Expected         float64 `json:"expected"`
+	Histogram        []int   `json:"histogram,omitempty"` // 下标为点数减一
+	ChiSquare        float64 `json:"chiSquare"`
+	DegreesOfFreedom int     `json:"degreesOfFreedom"`
+	PValue           float64 `json:"pValue"`
+	Reliable         bool    `json:"reliable"` // 样本量足够时卡方结果才有参考意义
+
+	sum int
+}
+
+type diceLuckFace struct {
+	sides int
+	value int
+}
+
+func newDiceLuckStats(key, name string) *DiceLuckStats {
+	return &DiceLuckStats{Key: key, Name: name, sidesMap: map[int]*DiceLuckSidesStats{}}
+}
+
+// NormalizeDiceLuckGroupBy 规范化分组方式，默认按用户
+func NormalizeDiceLuckGroupBy(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", DiceLuckGroupByUser:
+		return DiceLuckGroupByUser, nil
+	case DiceLuckGroupByIdentity:
+		return DiceLuckGroupByIdentity, nil
+	case DiceLuckGroupByChannel:
+		return DiceLuckGroupByChannel, nil
+	}
+	return "", errors.New("不支持的分组方式")
+}
+
+// DiceLuckCompute 统计频道内的掷骰分布
+func DiceLuckCompute(query DiceLuckQuery) (*DiceLuckReport, error) {
+	groupBy, err := NormalizeDiceLuckGroupBy(query.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	report := &DiceLuckReport{
+		GroupBy:   groupBy,
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		Overall:   newDiceLuckStats("", "全部"),
+	}
+	groups := map[string]*DiceLuckStats{}
+	err = model.DiceLuckScanRolls(query.DiceLuckFilter, func(rows []model.DiceLuckRollRow) error {
+		for i := range rows {
+			row := &rows[i]
+			key, name := diceLuckGroupKey(groupBy, row)
+			group, ok := groups[key]
+			if !ok {
+				group = newDiceLuckStats(key, name)
+				groups[key] = group
+			}
+			faces := extractDiceLuckFaces(row.Formula, row.ResultDetail, row.ResultValueText)
+			report.Overall.add(row, faces)
+			group.add(row, faces)
+			if row.CreatedAt.After(group.latestAt) && name != "" {
+				group.latestAt = row.CreatedAt
+				group.Name = name
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if groupBy == DiceLuckGroupByChannel {
+		for key, group := range groups {
+			if channel, err := model.ChannelGet(key); err == nil && channel.ID != "" {
+				group.Name = channel.Name
+			}
+		}
+	}
+
+	report.Overall.finish()
+	report.Groups = make([]*DiceLuckStats, 0, len(groups))
+	for _, group := range groups {
+		group.finish()
+		report.Groups = append(report.Groups, group)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if (a.DiceCount == 0) != (b.DiceCount == 0) {
+			return a.DiceCount > 0
+		}
+		if a.LuckZ != b.LuckZ {
+			return a.LuckZ < b.LuckZ
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+func diceLuckGroupKey(groupBy string, row *model.DiceLuckRollRow) (string, string) {
+	switch groupBy {
+	case DiceLuckGroupByChannel:
+		return row.ChannelID, ""
+	case DiceLuckGroupByIdentity:
+		if row.IdentityID != "" {
+			name := strings.TrimSpace(row.IdentityName)
+			if name == "" {
+				name = strings.TrimSpace(row.MemberName)
+			}
+			return row.IdentityID, name
+		}
+		// 未使用角色发言的掷骰归到用户本人
+		return "user:" + row.UserID, strings.TrimSpace(row.MemberName)
+	}
+	return row.UserID, strings.TrimSpace(row.MemberName)
+}
+
+func (s *DiceLuckStats) add(row *model.DiceLuckRollRow, faces []diceLuckFace) {
+	s.RollCount++
+	if row.RuleSystem != "" && row.Outcome != "" {
+		if s.Outcomes == nil {
+			s.Outcomes = map[string]map[string]int{}
+		}
+		if s.Outcomes[row.RuleSystem] == nil {
+			s.Outcomes[row.RuleSystem] = map[string]int{}
+		}
+		s.Outcomes[row.RuleSystem][row.Outcome]++
+	}
+	// CoC 检定为小于等于判定，出目越低越好
+	lowerIsBetter := row.RuleSystem == DiceRuleSystemCoC7 && row.Outcome != ""
+	for _, face := range faces {
+		sides := s.sidesMap[face.sides]
+		if sides == nil {
+			sides = &DiceLuckSidesStats{Sides: face.sides}
+			if face.sides <= diceLuckMaxHistogramSides {
+				sides.Histogram = make([]int, face.sides)
+			}
+			s.sidesMap[face.sides] = sides
+		}
+		sides.Count++
+		sides.sum += face.value
+		if sides.Histogram != nil {
+			sides.Histogram[face.value-1]++
+		}
+		s.DiceCount++
+		luckValue := face.value
+		if lowerIsBetter {
+			luckValue = face.sides + 1 - face.value
+		}
+		mean := float64(face.sides+1) / 2
+		sd := math.Sqrt(float64(face.sides*face.sides-1) / 12)
+		s.stdSum += (float64(luckValue) - mean) / sd
+		s.scoreSum += float64(luckValue-1) / float64(face.sides-1)
+	}
+}
+
+func (s *DiceLuckStats) finish() {
+	if s.DiceCount > 0 {
+		s.LuckScore = roundDiceLuck(s.scoreSum / float64(s.DiceCount))
+		s.LuckZ = roundDiceLuck(s.stdSum / math.Sqrt(float64(s.DiceCount)))
+	}
+	s.Sides = make([]*DiceLuckSidesStats, 0, len(s.sidesMap))
+	for _, sides := range s.sidesMap {
+		sides.finish()
+		s.Sides = append(s.Sides, sides)
+	}
+	sort.Slice(s.Sides, func(i, j int) bool { return s.Sides[i].Sides < s.Sides[j].Sides })
+}
+
+func (s *DiceLuckSidesStats) finish() {
+	s.Expected = float64(s.Sides+1) / 2
+	if s.Count > 0 {
+		s.Mean = roundDiceLuck(float64(s.sum) / float64(s.Count))
+	}
+	if s.Histogram == nil || s.Count == 0 {
+		return
+	}
+	expected := float64(s.Count) / float64(s.Sides)
+	chi := 0.0
+	for _, observed := range s.Histogram {
+		diff := float64(observed) - expected
+		chi += diff * diff / expected
+	}
+	s.ChiSquare = roundDiceLuck(chi)
+	s.DegreesOfFreedom = s.Sides - 1
+	s.PValue = roundDiceLuck(chiSquarePValue(chi, s.DegreesOfFreedom))
+	s.Reliable = expected >= diceLuckMinExpectedPerFace
+}
+
+// extractDiceLuckFaces 从掷骰明细中提取每颗骰子的点数，如 14[3d6=3+6+5]、7[2d20k1={7 | 5}]、37[d100]；
+// 取高/取低的骰子会计入全部骰面，奖励骰等非均匀分布的骰子不计入
+func extractDiceLuckFaces(formula, detail, valueText string) []diceLuckFace {
+	var faces []diceLuckFace
+	appendFace := func(sides, value int) {
+		if sides >= 2 && value >= 1 && value <= sides {
+			faces = append(faces, diceLuckFace{sides: sides, value: value})
+		}
+	}
+	for _, groups := range diceLuckListPattern.FindAllStringSubmatch(detail, -1) {
+		sides, _ := strconv.Atoi(groups[2])
+		for _, part := range strings.Split(groups[3], "+") {
+			value, _ := strconv.Atoi(part)
+			appendFace(sides, value)
+		}
+	}
+	for _, groups := range diceLuckKeepPattern.FindAllStringSubmatch(detail, -1) {
+		sides, _ := strconv.Atoi(groups[2])
+		for _, part := range strings.Fields(strings.ReplaceAll(groups[3], "|", " ")) {
+			value, _ := strconv.Atoi(part)
+			appendFace(sides, value)
+		}
+	}
+	for _, groups := range diceLuckSinglePattern.FindAllStringSubmatch(detail, -1) {
+		value, _ := strconv.Atoi(groups[1])
+		sides, _ := strconv.Atoi(groups[2])
+		appendFace(sides, value)
+	}
+	if len(faces) > 0 || strings.Contains(detail, "[") {
+		return faces
+	}
+	// 单颗骰加减常数时明细没有方括号，如 d20+5 的明细为 14+5
+	groups := diceLuckPlainPattern.FindStringSubmatch(strings.TrimSpace(formula))
+	if groups == nil {
+		return nil
+	}
+	sides, _ := strconv.Atoi(groups[1])
+	total, err := strconv.Atoi(strings.TrimSpace(valueText))
+	if err != nil {
+		return nil
+	}
+	modifier, _ := strconv.Atoi(groups[3])
+	if groups[2] == "-" {
+		modifier = -modifier
+	}
+	appendFace(sides, total-modifier)
+	return faces
+}
+
+// chiSquarePValue 卡方分布的上尾概率 Q(df/2, x/2)
+func chiSquarePValue(x float64, df int) float64 {
+	if df <= 0 || x <= 0 {
+		return 1
+	}
+	return regularizedGammaQ(float64(df)/2, x/2)
+}
+
+// regularizedGammaQ 正则化上不完全伽马函数，x < a+1 时用级数，否则用连分式
+func regularizedGammaQ(a, x float64) float64 {
+	const (
+		maxIterations = 500
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+	lgammaA, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgammaA)
+	if x < a+1 {
+		term := 1 / a
+		sum := term
+		for n := 1; n < maxIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*prefix)
+	}
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < maxIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return math.Min(1, prefix*h)
+}
+
+func roundDiceLuck(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
+
+// DiceLuckReportMarkdown 渲染为 Markdown，可直接嵌入战报
+func DiceLuckReportMarkdown(report *DiceLuckReport) string {
+	var builder strings.Builder
+	builder.WriteString("## 骰运统计\n\n")
+	if report.StartTime != nil || report.EndTime != nil {
+		start, end := time.Time{}, time.Time{}
+		if report.StartTime != nil {
+			start = *report.StartTime
+		}
+		if report.EndTime != nil {
+			end = *report.EndTime
+		}
+		fmt.Fprintf(&builder, "时间：%s - %s\n\n", formatBattleReportTime(start), formatBattleReportTime(end))
+	}
+	if report.Overall == nil || report.Overall.RollCount == 0 {
+		builder.WriteString("这段时间没有掷骰记录。\n")
+		return strings.TrimSpace(builder.String())
+	}
+	builder.WriteString("| 名称 | 掷骰 | 骰子 | 运势 | 偏离(z) | 大成功 | 大失败 |\n")
+	builder.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	rows := append([]*DiceLuckStats{}, report.Groups...)
+	rows = append(rows, report.Overall)
+	for _, item := range rows {
+		name := item.Name
+		if name == "" {
+			name = item.Key
+		}
+		critical, fumble := item.outcomeTotals()
+		fmt.Fprintf(&builder, "| %s | %d | %d | %.1f%% | %+.2f | %d | %d |\n",
+			escapeDiceLuckMarkdown(name), item.RollCount, item.DiceCount, item.LuckScore*100, item.LuckZ, critical, fumble)
+	}
+	if len(report.Groups) > 1 && report.Groups[0].DiceCount > 0 {
+		cursed := report.Groups[0]
+		lucky := report.Groups[0]
+		for _, item := range report.Groups {
+			if item.DiceCount > 0 && item.LuckZ > lucky.LuckZ {
+				lucky = item
+			}
+		}
+		fmt.Fprintf(&builder, "\n今晚最非：**%s**；最欧：**%s**。\n", escapeDiceLuckMarkdown(cursed.Name), escapeDiceLuckMarkdown(lucky.Name))
+	}
+	for _, sides := range report.Overall.Sides {
+		if !sides.Reliable {
+			continue
+		}
+		verdict := "分布正常"
+		if sides.PValue < 0.01 {
+			verdict = "分布明显偏离均匀"
+		}
+		fmt.Fprintf(&builder, "\nd%d 共 %d 次，均值 %.2f（期望 %.1f），卡方 %.2f，p=%.3f，%s。", sides.Sides, sides.Count, sides.Mean, sides.Expected, sides.ChiSquare, sides.PValue, verdict)
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// BattleReportDiceLuckSection 统计战报来源频道在战报时间段内的骰运，返回可追加到战报末尾的 Markdown
+func BattleReportDiceLuckSection(channels []*model.ChannelModel, start, end time.Time) (string, error) {
+	query := DiceLuckQuery{GroupBy: DiceLuckGroupByIdentity}
+	for _, channel := range channels {
+		query.ChannelIDs = append(query.ChannelIDs, channel.ID)
+	}
+	if !start.IsZero() {
+		query.StartTime = &start
+	}
+	if !end.IsZero() {
+		query.EndTime = &end
+	}
+	report, err := DiceLuckCompute(query)
+	if err != nil {
+		return "", err
+	}
+	return DiceLuckReportMarkdown(report), nil
+}
+
+// DiceLuckReportCSV 导出为 CSV，每行一个分组与面数的组合
+func DiceLuckReportCSV(report *DiceLuckReport) ([]byte, error) {
+	var buf bytes.Buffer
+	// 带 BOM 以便 Excel 正确识别 UTF-8
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"key", "name", "rolls", "dice", "luck_score", "luck_z", "critical", "fumble", "sides", "count", "mean", "expected", "chi_square", "df", "p_value", "reliable"})
+	rows := append([]*DiceLuckStats{}, report.Groups...)
+	rows = append(rows, report.Overall)
+	for _, item := range rows {
+		critical, fumble := item.outcomeTotals()
+		base := []string{
+			item.Key,
+			item.Name,
+			strconv.Itoa(item.RollCount),
+			strconv.Itoa(item.DiceCount),
+			strconv.FormatFloat(item.LuckScore, 'f', 4, 64),
+			strconv.FormatFloat(item.LuckZ, 'f', 4, 64),
+			strconv.Itoa(critical),
+			strconv.Itoa(fumble),
+		}
+		if len(item.Sides) == 0 {
+			_ = writer.Write(append(base, "", "", "", "", "", "", "", ""))
+			continue
+		}
+		for _, sides := range item.Sides {
+			_ = writer.Write(append(append([]string{}, base...),
+				strconv.Itoa(sides.Sides),
+				strconv.Itoa(sides.Count),
+				strconv.FormatFloat(sides.Mean, 'f', 4, 64),
+				strconv.FormatFloat(sides.Expected, 'f', 1, 64),
+				strconv.FormatFloat(sides.ChiSquare, 'f', 4, 64),
+				strconv.Itoa(sides.DegreesOfFreedom),
+				strconv.FormatFloat(sides.PValue, 'f', 4, 64),
+				strconv.FormatBool(sides.Reliable),
+			))
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func (s *DiceLuckStats) outcomeTotals() (int, int) {
+	critical, fumble := 0, 0
+	for _, outcomes := range s.Outcomes {
+		critical += outcomes[DiceOutcomeCriticalSuccess]
+		fumble += outcomes[DiceOutcomeFumble]
+	}
+	return critical, fumble
+}
+
+func escapeDiceLuckMarkdown(value string) string {
+	return strings.ReplaceAll(strings.TrimSpace(value), "|", "\\|")
+}
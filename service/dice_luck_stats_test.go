@@ -0,0 +1,117 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestExtractDiceLuckFaces(t *testing.T) {
+	cases := []struct {
+		formula string
+		detail  string
+		value   string
+		want    []diceLuckFace
+	}{
+		{"3d6", "14[3d6=3+6+5]", "14", []diceLuckFace{{6, 3}, {6, 6}, {6, 5}}},
+		{"d20+5", "14+5", "19", []diceLuckFace{{20, 14}}},
+		{"d100", "[d100=73]", "73", []diceLuckFace{{100, 73}}},
+		{"2d20k1", "20[2d20k1={20 | 9}]", "20", []diceLuckFace{{20, 20}, {20, 9}}},
+		{"1d20+1d4", "19[1d20]+4[1d4]", "23", []diceLuckFace{{20, 19}, {4, 4}}},
+		{"d100<=60", "4<=60", "1", nil},
+	}
+	for _, tc := range cases {
+		got := extractDiceLuckFaces(tc.formula, tc.detail, tc.value)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.formula, tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: expected %v, got %v", tc.formula, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestChiSquarePValue(t *testing.T) {
+	// 查表值：df=5 时 x=11.07 对应 p≈0.05，df=19 时 x=30.144 对应 p≈0.05
+	if p := chiSquarePValue(11.0705, 5); math.Abs(p-0.05) > 1e-3 {
+		t.Fatalf("unexpected p-value for df=5: %v", p)
+	}
+	if p := chiSquarePValue(30.1435, 19); math.Abs(p-0.05) > 1e-3 {
+		t.Fatalf("unexpected p-value for df=19: %v", p)
+	}
+	if p := chiSquarePValue(0, 19); p != 1 {
+		t.Fatalf("zero statistic should give p=1, got %v", p)
+	}
+}
+
+func TestDiceLuckCompute(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+	base := time.Date(2026, 6, 1, 20, 0, 0, 0, time.UTC)
+	addRoll := func(userID, identityID, name, formula, detail, value, outcome string, whisper bool, offset time.Duration) {
+		msg := &model.MessageModel{
+			StringPKBaseModel:  model.StringPKBaseModel{ID: utils.NewID(), CreatedAt: base.Add(offset)},
+			ChannelID:          "ch-luck",
+			UserID:             userID,
+			IsWhisper:          whisper,
+			SenderMemberName:   name,
+			SenderIdentityID:   identityID,
+			SenderIdentityName: name,
+		}
+		if err := db.Create(msg).Error; err != nil {
+			t.Fatalf("create message failed: %v", err)
+		}
+		roll := &model.MessageDiceRollModel{
+			MessageID:       msg.ID,
+			Formula:         formula,
+			ResultDetail:    detail,
+			ResultValueText: value,
+			RuleSystem:      DiceRuleSystemCoC7,
+			Outcome:         outcome,
+		}
+		if err := model.MessageDiceRollReplace(msg.ID, []*model.MessageDiceRollModel{roll}); err != nil {
+			t.Fatalf("save roll failed: %v", err)
+		}
+	}
+	addRoll("u-lucky", "id-lucky", "阿欧", "d100", "[d100=1]", "1", DiceOutcomeCriticalSuccess, false, 0)
+	addRoll("u-lucky", "id-lucky", "阿欧", "3d6", "18[3d6=6+6+6]", "18", "", false, time.Minute)
+	addRoll("u-cursed", "id-cursed", "阿非", "d100", "[d100=100]", "100", DiceOutcomeFumble, false, 2*time.Minute)
+	addRoll("u-cursed", "id-cursed", "阿非", "d20", "1", "1", "", false, 3*time.Minute)
+	addRoll("u-cursed", "id-cursed", "阿非", "d20", "20", "20", "", true, 4*time.Minute)
+	addRoll("u-cursed", "id-cursed", "阿非", "d20", "20", "20", "", false, 2*time.Hour)
+
+	end := base.Add(time.Hour)
+	report, err := DiceLuckCompute(DiceLuckQuery{
+		DiceLuckFilter: model.DiceLuckFilter{ChannelIDs: []string{"ch-luck"}, StartTime: &base, EndTime: &end},
+		GroupBy:        DiceLuckGroupByIdentity,
+	})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	if report.Overall.RollCount != 4 || report.Overall.DiceCount != 6 {
+		t.Fatalf("whispers and rolls outside the range should be skipped: %+v", report.Overall)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Key != "id-cursed" || report.Groups[1].Key != "id-lucky" {
+		t.Fatalf("groups should be ordered from cursed to lucky: %+v", report.Groups)
+	}
+	// CoC 检定的 100 计为最差出目
+	cursed := report.Groups[0]
+	if cursed.Name != "阿非" || cursed.LuckZ >= 0 || cursed.Outcomes[DiceRuleSystemCoC7][DiceOutcomeFumble] != 1 {
+		t.Fatalf("unexpected cursed stats: %+v", cursed)
+	}
+
+	markdown := DiceLuckReportMarkdown(report)
+	if !strings.Contains(markdown, "## 骰运统计") || !strings.Contains(markdown, "| 阿欧 |") {
+		t.Fatalf("unexpected markdown: %s", markdown)
+	}
+	data, err := DiceLuckReportCSV(report)
+	if err != nil || !strings.Contains(string(data), "id-lucky,阿欧,2,4") {
+		t.Fatalf("unexpected csv: %s err=%v", data, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	ds "github.com/sealdice/dicescript"
+	exprand "golang.org/x/exp/rand"
 	htmlparser "golang.org/x/net/html"
 	htmlatom "golang.org/x/net/html/atom"
 
@@ -72,8 +73,23 @@ func NormalizeDefaultDiceExpr(raw string) (string, error) {
 	return fmt.Sprintf("d%d", value), nil
 }
 
+// DiceRenderOptions 掷骰渲染的附加选项，零值即不带规则、角色卡与公平性承诺的普通渲染
+type DiceRenderOptions struct {
+	// RuleSystem 检定指令判定成功等级所用的规则
+	RuleSystem string
+	// LoadCard 提供表达式中变量对应的角色卡属性
+	LoadCard DiceCardLoader
+	// Fairness 非空时新掷骰由承诺种子派生
+	Fairness *DiceFairnessContext
+}
+
 // RenderDiceContent 在HTML字符串中识别骰子表达式并渲染为dice-chip
 func RenderDiceContent(content string, defaultDiceExpr string, existing []*model.MessageDiceRollModel) (*DiceRenderResult, error) {
+	return RenderDiceContentWithOptions(content, defaultDiceExpr, existing, DiceRenderOptions{})
+}
+
+// RenderDiceContentWithOptions 同 RenderDiceContent，按 opts 指定的规则、角色卡与公平性承诺渲染掷骰
+func RenderDiceContentWithOptions(content string, defaultDiceExpr string, existing []*model.MessageDiceRollModel, opts DiceRenderOptions) (*DiceRenderResult, error) {
 	if LooksLikeTipTapJSON(content) {
 		return &DiceRenderResult{Content: content, Rolls: nil, IsHidden: false}, nil
 	}
@@ -86,6 +102,7 @@ func RenderDiceContent(content string, defaultDiceExpr string, existing []*model
 		wrapper.AppendChild(node)
 	}
 	renderer := newDiceRenderer(defaultDiceExpr, existing)
+	renderer.applyOptions(opts)
 	renderer.walk(wrapper)
 	isHidden := containsHiddenDiceCommand(content)
 
@@ -103,7 +120,7 @@ func RenderDiceContent(content string, defaultDiceExpr string, existing []*model
 }
 
 func RenderDiceContentWithPreviousMessage(content string, defaultDiceExpr string, previousContent string, cacheKey string, rollMore func(string) []int) (*DiceRenderResult, error) {
-	return RenderDiceContentWithExisting(content, defaultDiceExpr, nil, previousContent, cacheKey, rollMore)
+	return RenderDiceContentWithExisting(content, defaultDiceExpr, nil, previousContent, cacheKey, rollMore, DiceRenderOptions{})
 }
 
 func RenderDiceContentWithExisting(
@@ -113,6 +130,7 @@ func RenderDiceContentWithExisting(
 	previousContent string,
 	cacheKey string,
 	rollMore func(string) []int,
+	opts DiceRenderOptions,
 ) (*DiceRenderResult, error) {
 	snapshot, err := loadDiceReplaySnapshot(previousContent, cacheKey)
 	if err != nil {
@@ -130,6 +148,7 @@ func RenderDiceContentWithExisting(
 		wrapper.AppendChild(node)
 	}
 	renderer := newDiceReplayRenderer(defaultDiceExpr, existing, snapshot, rollMore)
+	renderer.applyOptions(opts)
 	renderer.walk(wrapper)
 	isHidden := containsHiddenDiceCommand(content)
 
@@ -172,6 +191,12 @@ func newDiceRenderer(defaultDiceExpr string, existing []*model.MessageDiceRollMo
 	}
 }
 
+func (r *diceRenderer) applyOptions(opts DiceRenderOptions) {
+	r.ruleSystem = opts.RuleSystem
+	r.cardAttrs = &diceCardAttrs{load: opts.LoadCard}
+	r.fairness = opts.Fairness
+}
+
 func newDiceReplayRenderer(defaultDiceExpr string, existing []*model.MessageDiceRollModel, snapshot *DiceReplaySnapshot, rollMore func(string) []int) *diceRenderer {
 	renderer := newDiceRenderer(defaultDiceExpr, existing)
 	if snapshot != nil {
@@ -189,6 +214,11 @@ type diceRenderer struct {
 	existing         map[string]*model.MessageDiceRollModel
 	replayEntries    map[int]DiceReplayEntry
 	rollMore         func(string) []int
+	ruleSystem       string
+	cardAttrs        *diceCardAttrs
+	fairness         *DiceFairnessContext
+	randSrc          *exprand.PCGSource // 非空时替代引擎自带的随机源
+	fairAttrs        map[string]any     // 可验证模式下记录当前掷骰读取的属性值
 	rolls            []*model.MessageDiceRollModel
 	modified         bool
 }
@@ -344,6 +374,10 @@ func (r *diceRenderer) buildRolls(match diceTextMatch) []*model.MessageDiceRollM
 		r.rolls = append(r.rolls, roll)
 		return []*model.MessageDiceRollModel{roll}
 	}
+	// 指令形式的 .ra 在规范化时已去掉 r，这里补回以便与 {ra ...} 统一
+	if match.kind == matchKindCommand && strings.HasPrefix(formula, "a") {
+		formula = "r" + formula
+	}
 	if repeatCount <= 1 {
 		roll := r.buildSingleRoll(strings.TrimSpace(match.raw), formula)
 		r.rolls = append(r.rolls, roll)
@@ -388,19 +422,39 @@ func (r *diceRenderer) buildSingleRoll(sourceText string, formula string) *model
 		roll.ResultValueText = prev.ResultValueText
 		roll.ResultText = prev.ResultText
 		roll.IsError = prev.IsError
+		roll.RuleSystem = prev.RuleSystem
+		roll.Outcome = prev.Outcome
+		roll.FairWindowID = prev.FairWindowID
+		roll.FairInputs = prev.FairInputs
 		return roll
 	}
-	if replayed, ok := r.tryReplayRoll(index, sourceText, formula); ok {
+	if r.fairness != nil {
+		// 可验证模式下结果只能由种子派生，不沿用编辑前的骰面
+		if err := r.beginFairRoll(index); err != nil {
+			return r.buildErrorRoll(sourceText, formula, err)
+		}
+		defer r.endFairRoll(roll)
+	} else if replayed, ok := r.tryReplayRoll(index, sourceText, formula); ok {
 		return replayed
 	}
-	computed := r.evaluateFormula(formula)
+	computed := r.computeRoll(formula)
 	roll.ResultDetail = computed.ResultDetail
 	roll.ResultValueText = computed.ResultValueText
 	roll.ResultText = computed.ResultText
 	roll.IsError = computed.IsError
+	roll.RuleSystem = computed.RuleSystem
+	roll.Outcome = computed.Outcome
 	return roll
 }
 
+// computeRoll 计算规范化后的公式，ra 开头的按检定处理
+func (r *diceRenderer) computeRoll(formula string) *model.MessageDiceRollModel {
+	if body, ok := strings.CutPrefix(formula, diceCheckPrefix); ok {
+		return r.evaluateCheck(formula, body)
+	}
+	return r.evaluateFormula(formula)
+}
+
 func (r *diceRenderer) parseMultiRoll(normalized string) (int, string, error) {
 	trimmed := strings.TrimSpace(normalized)
 	groups := multiDicePattern.FindStringSubmatch(trimmed)
@@ -688,17 +742,8 @@ func (r *diceRenderer) rollAdditionalDice(count int, sides int) ([]int, bool) {
 
 func (r *diceRenderer) evaluateFormula(expr string) *model.MessageDiceRollModel {
 	roll := &model.MessageDiceRollModel{Formula: expr}
-	vm := ds.NewVM()
-	vm.Config.EnableDiceWoD = true
-	vm.Config.EnableDiceCoC = true
-	vm.Config.EnableDiceFate = true
-	vm.Config.EnableDiceDoubleCross = true
-	vm.Config.DisableStmts = true
-	vm.Config.OpCountLimit = 30000
-	if r.defaultDiceSides != "" {
-		vm.Config.DefaultDiceSideExpr = fmt.Sprintf("面数 ?? %s", r.defaultDiceSides)
-	}
-	if err := vm.Run(expr); err != nil {
+	vm, err := r.runExpr(expr)
+	if err != nil {
 		roll.IsError = true
 		roll.ResultText = err.Error()
 		return roll
@@ -719,11 +764,61 @@ func (r *diceRenderer) evaluateFormula(expr string) *model.MessageDiceRollModel
 	return roll
 }
 
+// runExpr 执行表达式，变量从发送者的角色卡读取，明细中会显示为 60[侦查] 的形式；
+// 引用了角色卡中不存在的属性时返回说明缺失属性的错误
+func (r *diceRenderer) runExpr(expr string) (*ds.Context, error) {
+	vm := ds.NewVM()
+	vm.Config.EnableDiceWoD = true
+	vm.Config.EnableDiceCoC = true
+	vm.Config.EnableDiceFate = true
+	vm.Config.EnableDiceDoubleCross = true
+	vm.Config.DisableStmts = true
+	vm.Config.OpCountLimit = 30000
+	if r.defaultDiceSides != "" {
+		vm.Config.DefaultDiceSideExpr = fmt.Sprintf("面数 ?? %s", r.defaultDiceSides)
+	}
+	if r.randSrc != nil {
+		vm.RandSrc = r.randSrc
+	}
+	missingAttr := ""
+	vm.GlobalValueLoadFunc = func(name string) *ds.VMValue {
+		if value, ok := r.cardAttrs.lookup(name); ok {
+			if r.fairAttrs != nil {
+				r.fairAttrs[name] = diceFairnessAttrValue(value)
+			}
+			return value
+		}
+		return nil
+	}
+	vm.GlobalValueLoadOverwriteFunc = func(name string, curVal *ds.VMValue) *ds.VMValue {
+		if _, internal := diceInternalVarNames[name]; curVal == nil && !internal && missingAttr == "" {
+			missingAttr = name
+		}
+		return curVal
+	}
+	err := vm.Run(expr)
+	if missingAttr != "" {
+		return nil, r.cardAttrs.missingError(missingAttr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
 func buildDiceChipHTML(roll *model.MessageDiceRollModel) string {
 	classes := []string{"dice-chip"}
 	if roll.IsError {
 		classes = append(classes, "dice-chip--error")
 	}
+	outcomeLabel := ""
+	if !roll.IsError && roll.Outcome != "" {
+		outcomeLabel = DiceOutcomeLabel(roll.Outcome)
+		classes = append(classes, "dice-chip--outcome-"+strings.ReplaceAll(roll.Outcome, "_", "-"))
+	}
+	if roll.FairWindowID != "" {
+		classes = append(classes, "dice-chip--verifiable")
+	}
 	builder := &strings.Builder{}
 	fmt.Fprintf(builder, `<span class="%s" data-dice-roll-index="%d" data-dice-source="%s" data-dice-formula="%s"`,
 		strings.Join(classes, " "), roll.RollIndex, html.EscapeString(roll.SourceText), html.EscapeString(roll.Formula))
@@ -745,6 +840,12 @@ func buildDiceChipHTML(roll *model.MessageDiceRollModel) string {
 	if roll.IsError {
 		builder.WriteString(` data-dice-error="true"`)
 	}
+	if roll.FairWindowID != "" {
+		fmt.Fprintf(builder, ` data-dice-fair-window="%s"`, html.EscapeString(roll.FairWindowID))
+	}
+	if outcomeLabel != "" {
+		fmt.Fprintf(builder, ` data-dice-rule="%s" data-dice-outcome="%s"`, html.EscapeString(roll.RuleSystem), html.EscapeString(roll.Outcome))
+	}
 	builder.WriteString(">")
 	formulaText := roll.Formula
 	if roll.ResultDetail != "" {
@@ -769,7 +870,13 @@ func buildDiceChipHTML(roll *model.MessageDiceRollModel) string {
 	} else {
 		builder.WriteString("?")
 	}
-	builder.WriteString(`</span></span>`)
+	builder.WriteString(`</span>`)
+	if outcomeLabel != "" {
+		builder.WriteString(`<span class="dice-chip__outcome">`)
+		builder.WriteString(html.EscapeString(outcomeLabel))
+		builder.WriteString(`</span>`)
+	}
+	builder.WriteString(`</span>`)
 	return builder.String()
 }
 
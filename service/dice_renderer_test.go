@@ -192,3 +192,42 @@ func TestRenderDiceContentMultiRollReuse(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderDiceContentCardAttributes(t *testing.T) {
+	loads := 0
+	loadCard := func() (*model.CharacterCardModel, error) {
+		loads++
+		return &model.CharacterCardModel{
+			Name:      "约翰",
+			SheetType: "dnd5e",
+			Attrs:     model.JSONMap{"侦查": float64(60), "力量": "16"},
+		}, nil
+	}
+	result, err := RenderDiceContentWithOptions("{d100<=侦查} {1d20+力量调整} {d100<=图书馆}", "d100", nil, DiceRenderOptions{LoadCard: loadCard})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(result.Rolls) != 3 {
+		t.Fatalf("expected 3 rolls, got %d", len(result.Rolls))
+	}
+	if loads != 1 {
+		t.Fatalf("card should be loaded once per render, got %d", loads)
+	}
+	if roll := result.Rolls[0]; roll.IsError || !strings.Contains(roll.ResultDetail, "60[侦查]") {
+		t.Fatalf("attribute value should show in detail: %+v", roll)
+	}
+	if roll := result.Rolls[1]; roll.IsError || !strings.Contains(roll.ResultDetail, "3[力量调整]") {
+		t.Fatalf("dnd modifier should be derived from score: %+v", roll)
+	}
+	if roll := result.Rolls[2]; !roll.IsError || !strings.Contains(roll.ResultText, "图书馆") {
+		t.Fatalf("missing attribute should produce an error roll: %+v", roll)
+	}
+
+	noCard, err := RenderDiceContentWithOptions("{d100<=侦查}", "d100", nil, DiceRenderOptions{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if roll := noCard.Rolls[0]; !roll.IsError || !strings.Contains(roll.ResultText, "未找到可用的角色卡") {
+		t.Fatalf("expected no-card error roll, got %+v", roll)
+	}
+}
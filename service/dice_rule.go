@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sealchat/model"
+)
+
+// 频道可选的规则系统，决定检定指令（.ra / {ra ...}）的解释方式
+const (
+	DiceRuleSystemNone  = ""
+	DiceRuleSystemCoC7  = "coc7"
+	DiceRuleSystemDnD5e = "dnd5e"
+)
+
+// 检定结果等级，写入 MessageDiceRollModel.Outcome
+const (
+	DiceOutcomeCriticalSuccess = "critical_success"
+	DiceOutcomeExtremeSuccess  = "extreme_success"
+	DiceOutcomeHardSuccess     = "hard_success"
+	DiceOutcomeSuccess         = "success"
+	DiceOutcomeFailure         = "failure"
+	DiceOutcomeFumble          = "fumble"
+)
+
+// diceCheckPrefix 检定指令在规范化公式中的前缀，.ra侦查 与 {ra侦查} 都会规范化为 ra侦查
+const diceCheckPrefix = "ra"
+
+const maxCoC7ExtraDice = 3
+
+var diceOutcomeLabels = map[string]string{
+	DiceOutcomeCriticalSuccess: "大成功",
+	DiceOutcomeExtremeSuccess:  "极难成功",
+	DiceOutcomeHardSuccess:     "困难成功",
+	DiceOutcomeSuccess:         "成功",
+	DiceOutcomeFailure:         "失败",
+	DiceOutcomeFumble:          "大失败",
+}
+
+var (
+	coc7CheckPattern   = regexp.MustCompile(`^([bp])(\d*)(.*)$`)
+	dnd5eCheckPattern  = regexp.MustCompile(`^(adv|dis|优势|劣势)?(.*?)(?:dc(\d+))?$`)
+	plainNumberPattern = regexp.MustCompile(`^[+-]?\d+$`)
+)
+
+// DiceOutcomeLabel 返回检定结果的中文描述
+func DiceOutcomeLabel(outcome string) string {
+	return diceOutcomeLabels[outcome]
+}
+
+// NormalizeDiceRuleSystem 规范化频道规则系统配置，空值表示不启用检定
+func NormalizeDiceRuleSystem(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "none":
+		return DiceRuleSystemNone, nil
+	case "coc", "coc7", "coc7th":
+		return DiceRuleSystemCoC7, nil
+	case "dnd", "dnd5e", "5e":
+		return DiceRuleSystemDnD5e, nil
+	}
+	return "", errors.New("不支持的规则系统")
+}
+
+// GradeCoC7 按 CoC 第七版规则判定成功等级：1 为大成功；
+// 目标值小于 50 时 96-100 为大失败，否则仅 100 为大失败
+func GradeCoC7(value int, target int) string {
+	switch {
+	case value == 1:
+		return DiceOutcomeCriticalSuccess
+	case value >= 100 || (target < 50 && value >= 96):
+		return DiceOutcomeFumble
+	case value <= target/5:
+		return DiceOutcomeExtremeSuccess
+	case value <= target/2:
+		return DiceOutcomeHardSuccess
+	case value <= target:
+		return DiceOutcomeSuccess
+	}
+	return DiceOutcomeFailure
+}
+
+// GradeDnD5e 按 D&D 5e 规则判定：自然 20 为大成功，自然 1 为大失败；
+// 未给出 DC 时其余结果不做判定
+func GradeDnD5e(natural int, total int, dc int, hasDC bool) string {
+	switch {
+	case natural == 20:
+		return DiceOutcomeCriticalSuccess
+	case natural == 1:
+		return DiceOutcomeFumble
+	case !hasDC:
+		return ""
+	case total >= dc:
+		return DiceOutcomeSuccess
+	}
+	return DiceOutcomeFailure
+}
+
+// evaluateCheck 按频道规则系统执行检定，body 为去掉 ra 前缀后的部分
+func (r *diceRenderer) evaluateCheck(formula string, body string) *model.MessageDiceRollModel {
+	roll := &model.MessageDiceRollModel{Formula: formula, RuleSystem: r.ruleSystem}
+	var err error
+	switch r.ruleSystem {
+	case DiceRuleSystemCoC7:
+		err = r.evaluateCoC7Check(roll, strings.TrimSpace(body))
+	case DiceRuleSystemDnD5e:
+		err = r.evaluateDnD5eCheck(roll, strings.TrimSpace(body))
+	default:
+		err = errors.New("当前频道未设置规则系统，无法进行检定")
+	}
+	if err != nil {
+		roll.IsError = true
+		roll.ResultText = err.Error()
+		roll.ResultDetail = ""
+		roll.ResultValueText = ""
+		roll.Outcome = ""
+	}
+	return roll
+}
+
+// evaluateCoC7Check 支持 ra侦查、ra60、rab侦查（奖励骰）、rap2侦查（惩罚骰）
+func (r *diceRenderer) evaluateCoC7Check(roll *model.MessageDiceRollModel, body string) error {
+	diceExpr := "d100"
+	// 以 b/p 开头的属性名（如 pow）优先按属性处理
+	if groups := coc7CheckPattern.FindStringSubmatch(body); groups != nil && !r.cardAttrs.has(body) {
+		extra := 1
+		if groups[2] != "" {
+			extra, _ = strconv.Atoi(groups[2])
+		}
+		if extra < 1 || extra > maxCoC7ExtraDice {
+			return fmt.Errorf("奖励骰/惩罚骰数量需为 1-%d", maxCoC7ExtraDice)
+		}
+		diceExpr = fmt.Sprintf("%s%d", groups[1], extra)
+		body = strings.TrimSpace(groups[3])
+	}
+	if body == "" {
+		return errors.New("缺少检定目标值，例如 .ra侦查 或 .ra60")
+	}
+	target, targetDetail, err := r.evaluateCheckInt(body)
+	if err != nil {
+		return err
+	}
+	value, valueDetail, err := r.evaluateCheckInt(diceExpr)
+	if err != nil {
+		return err
+	}
+	roll.Outcome = GradeCoC7(value, target)
+	roll.ResultValueText = strconv.Itoa(value)
+	roll.ResultDetail = fmt.Sprintf("%s/%s", valueDetail, targetDetail)
+	roll.ResultText = fmt.Sprintf("%s = %d/%d %s", roll.Formula, value, target, DiceOutcomeLabel(roll.Outcome))
+	return nil
+}
+
+// evaluateDnD5eCheck 支持 ra+5、ra力量调整dc15、raadv+3、ra劣势dc12
+func (r *diceRenderer) evaluateDnD5eCheck(roll *model.MessageDiceRollModel, body string) error {
+	groups := dnd5eCheckPattern.FindStringSubmatch(body)
+	if groups == nil {
+		return errors.New("检定格式不正确，例如 .ra+5dc15")
+	}
+	diceExpr := "d20"
+	switch groups[1] {
+	case "adv", "优势":
+		diceExpr = "2d20k1"
+	case "dis", "劣势":
+		diceExpr = "2d20q1"
+	}
+	natural, naturalDetail, err := r.evaluateCheckInt(diceExpr)
+	if err != nil {
+		return err
+	}
+	total := natural
+	detail := naturalDetail
+	if modifierExpr := strings.TrimSpace(groups[2]); modifierExpr != "" {
+		modifier, _, err := r.evaluateCheckInt(modifierExpr)
+		if err != nil {
+			return err
+		}
+		total += modifier
+		if plainNumberPattern.MatchString(modifierExpr) {
+			detail += fmt.Sprintf("%+d", modifier)
+		} else {
+			detail += fmt.Sprintf("%+d[%s]", modifier, strings.TrimLeft(modifierExpr, "+"))
+		}
+	}
+	dc, hasDC := 0, groups[3] != ""
+	if hasDC {
+		dc, _ = strconv.Atoi(groups[3])
+		detail += fmt.Sprintf(" vs DC%d", dc)
+	}
+	roll.Outcome = GradeDnD5e(natural, total, dc, hasDC)
+	roll.ResultValueText = strconv.Itoa(total)
+	roll.ResultDetail = detail
+	roll.ResultText = strings.TrimSpace(fmt.Sprintf("%s = %d %s", roll.Formula, total, DiceOutcomeLabel(roll.Outcome)))
+	return nil
+}
+
+// evaluateCheckInt 计算检定中的子表达式，返回整数结果与明细，明细形如 60[侦查]
+func (r *diceRenderer) evaluateCheckInt(expr string) (int, string, error) {
+	vm, err := r.runExpr(expr)
+	if err != nil {
+		return 0, "", err
+	}
+	if vm.Ret == nil {
+		return 0, "", fmt.Errorf("表达式「%s」没有结果", expr)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(vm.Ret.ToString()))
+	if err != nil {
+		return 0, "", fmt.Errorf("表达式「%s」的结果不是整数", expr)
+	}
+	detail := strings.TrimSpace(vm.GetDetailText())
+	switch {
+	case plainNumberPattern.MatchString(expr):
+		detail = strconv.Itoa(value)
+	case detail == "" || !strings.Contains(detail, "["):
+		detail = fmt.Sprintf("%d[%s]", value, expr)
+	}
+	return value, detail, nil
+}
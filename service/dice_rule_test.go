@@ -0,0 +1,94 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"sealchat/model"
+)
+
+func TestGradeCoC7(t *testing.T) {
+	cases := []struct {
+		value, target int
+		want          string
+	}{
+		{1, 50, DiceOutcomeCriticalSuccess},
+		{10, 50, DiceOutcomeExtremeSuccess},
+		{25, 50, DiceOutcomeHardSuccess},
+		{50, 50, DiceOutcomeSuccess},
+		{51, 50, DiceOutcomeFailure},
+		{96, 40, DiceOutcomeFumble},
+		{96, 60, DiceOutcomeFailure},
+		{100, 99, DiceOutcomeFumble},
+	}
+	for _, c := range cases {
+		if got := GradeCoC7(c.value, c.target); got != c.want {
+			t.Fatalf("GradeCoC7(%d, %d) = %s, want %s", c.value, c.target, got, c.want)
+		}
+	}
+}
+
+func TestGradeDnD5e(t *testing.T) {
+	if got := GradeDnD5e(20, 15, 30, true); got != DiceOutcomeCriticalSuccess {
+		t.Fatalf("natural 20 should crit, got %s", got)
+	}
+	if got := GradeDnD5e(1, 25, 10, true); got != DiceOutcomeFumble {
+		t.Fatalf("natural 1 should fumble, got %s", got)
+	}
+	if got := GradeDnD5e(10, 15, 15, true); got != DiceOutcomeSuccess {
+		t.Fatalf("meeting DC should succeed, got %s", got)
+	}
+	if got := GradeDnD5e(10, 14, 15, true); got != DiceOutcomeFailure {
+		t.Fatalf("below DC should fail, got %s", got)
+	}
+	if got := GradeDnD5e(10, 14, 0, false); got != "" {
+		t.Fatalf("no DC should not grade, got %s", got)
+	}
+}
+
+func TestRenderDiceContentRuleChecks(t *testing.T) {
+	loadCard := func() (*model.CharacterCardModel, error) {
+		return &model.CharacterCardModel{Name: "约翰", Attrs: model.JSONMap{"侦查": float64(60), "pow": float64(45)}}, nil
+	}
+	result, err := RenderDiceContentWithOptions("调查 .ra侦查 {rab2侦查} {rapow}", "d100", nil, DiceRenderOptions{RuleSystem: DiceRuleSystemCoC7, LoadCard: loadCard})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(result.Rolls) != 3 {
+		t.Fatalf("expected 3 rolls, got %d", len(result.Rolls))
+	}
+	for _, roll := range result.Rolls {
+		if roll.IsError || roll.RuleSystem != DiceRuleSystemCoC7 || DiceOutcomeLabel(roll.Outcome) == "" {
+			t.Fatalf("check should be graded: %+v", roll)
+		}
+	}
+	if !strings.HasSuffix(result.Rolls[0].ResultDetail, "/60[侦查]") || result.Rolls[0].Formula != "ra侦查" {
+		t.Fatalf("unexpected coc check roll: %+v", result.Rolls[0])
+	}
+	if !strings.Contains(result.Rolls[1].ResultDetail, "b2") {
+		t.Fatalf("bonus dice should be rolled: %+v", result.Rolls[1])
+	}
+	if !strings.HasSuffix(result.Rolls[2].ResultDetail, "/45[pow]") {
+		t.Fatalf("attribute starting with p should not be read as penalty dice: %+v", result.Rolls[2])
+	}
+	if !strings.Contains(result.Content, "dice-chip__outcome") || !strings.Contains(result.Content, `data-dice-outcome="`) {
+		t.Fatalf("outcome markup missing: %s", result.Content)
+	}
+
+	dnd, err := RenderDiceContentWithOptions("{ra adv +5 dc15}", "d20", nil, DiceRenderOptions{RuleSystem: DiceRuleSystemDnD5e})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	roll := dnd.Rolls[0]
+	if roll.IsError || !strings.Contains(roll.ResultDetail, "2d20k1") || !strings.HasSuffix(roll.ResultDetail, "+5 vs DC15") {
+		t.Fatalf("unexpected dnd check roll: %+v", roll)
+	}
+
+	none, err := RenderDiceContent(".ra侦查", "d100", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !none.Rolls[0].IsError || !strings.Contains(none.Rolls[0].ResultText, "规则系统") {
+		t.Fatalf("check without rule system should be an error roll: %+v", none.Rolls[0])
+	}
+}
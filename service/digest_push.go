@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"sealchat/model"
+	"sealchat/service/metrics/telemetry"
 )
 
 const (
@@ -1003,6 +1004,7 @@ func deliverDigestRecord(rule *model.DigestPushRuleModel, record *model.DigestRe
 		lastResult = result
 		lastErr = err
 		if err == nil {
+			telemetry.DigestDeliveries.Inc(telemetry.OutcomeSuccess)
 			return result, nil
 		}
 		if retry >= DigestActivePushRetryCount {
@@ -1013,6 +1015,7 @@ func deliverDigestRecord(rule *model.DigestPushRuleModel, record *model.DigestRe
 	if lastResult == nil && lastErr == nil {
 		lastErr = errors.New("主动推送失败")
 	}
+	telemetry.DigestDeliveries.Inc(telemetry.OutcomeFailure)
 	return lastResult, lastErr
 }
 
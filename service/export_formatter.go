@@ -16,6 +16,7 @@ import (
 	"unicode/utf8"
 
 	"sealchat/model"
+	"sealchat/protocol"
 	"sealchat/utils"
 
 	htmlnode "golang.org/x/net/html"
@@ -37,21 +38,36 @@ type payloadContext struct {
 }
 
 type ExportMessage struct {
-	ID               string    `json:"id"`
-	SenderID         string    `json:"sender_id"`
-	SenderIdentityID string    `json:"sender_identity_id,omitempty"`
-	SenderName       string    `json:"sender_name"`
-	SenderColor      string    `json:"sender_color"`
-	SenderAvatar     string    `json:"sender_avatar,omitempty"`
-	IsMerged         bool      `json:"is_merged,omitempty"`
-	IcMode           string    `json:"ic_mode"`
-	IsWhisper        bool      `json:"is_whisper"`
-	IsArchived       bool      `json:"is_archived"`
-	IsBot            bool      `json:"is_bot"`
-	CreatedAt        time.Time `json:"created_at"`
-	Content          string    `json:"content"`
-	ContentHTML      string    `json:"content_html,omitempty"` // HTML 渲染结果，用于 HTML 导出
-	WhisperTargets   []string  `json:"whisper_targets"`
+	ID               string           `json:"id"`
+	SenderID         string           `json:"sender_id"`
+	SenderIdentityID string           `json:"sender_identity_id,omitempty"`
+	SenderName       string           `json:"sender_name"`
+	SenderColor      string           `json:"sender_color"`
+	SenderAvatar     string           `json:"sender_avatar,omitempty"`
+	IsMerged         bool             `json:"is_merged,omitempty"`
+	IcMode           string           `json:"ic_mode"`
+	IsWhisper        bool             `json:"is_whisper"`
+	IsArchived       bool             `json:"is_archived"`
+	IsBot            bool             `json:"is_bot"`
+	CreatedAt        time.Time        `json:"created_at"`
+	Content          string           `json:"content"`
+	ContentHTML      string           `json:"content_html,omitempty"` // HTML 渲染结果，用于 HTML 导出
+	WhisperTargets   []string         `json:"whisper_targets"`
+	DisplayOrder     float64          `json:"display_order,omitempty"`
+	DiceRolls        []ExportDiceRoll `json:"dice_rolls,omitempty"` // 仅 JSON 导出填充，用于回导
+}
+
+// ExportDiceRoll 消息中的掷骰结果，与 MessageDiceRollModel 对应
+type ExportDiceRoll struct {
+	RollIndex       int    `json:"roll_index"`
+	SourceText      string `json:"source_text"`
+	Formula         string `json:"formula"`
+	ResultDetail    string `json:"result_detail,omitempty"`
+	ResultValueText string `json:"result_value_text,omitempty"`
+	ResultText      string `json:"result_text,omitempty"`
+	IsError         bool   `json:"is_error,omitempty"`
+	RuleSystem      string `json:"rule_system,omitempty"`
+	Outcome         string `json:"outcome,omitempty"`
 }
 
 type ExportPayload struct {
@@ -66,6 +82,8 @@ type ExportPayload struct {
 	PartTotal        int                    `json:"part_total,omitempty"`
 	DisplayOptions   map[string]any         `json:"display_options,omitempty"`
 	InlineAssets     map[string]string      `json:"inline_assets,omitempty"`
+	Attachments      map[string]string      `json:"attachments,omitempty"`     // 附件 token -> data URL，仅 JSON 导出填充，用于回导
+	AttachmentRefs   []string               `json:"attachment_refs,omitempty"` // 超出内嵌预算、仅以 id: token 引用的附件
 	Messages         []ExportMessage        `json:"messages"`
 	Meta             map[string]bool        `json:"meta"`
 	Count            int                    `json:"count"`
@@ -73,6 +91,10 @@ type ExportPayload struct {
 	IncludeImages    bool                   `json:"include_images"`
 	IncludeDiceCmds  bool                   `json:"include_dice_commands"`
 	ExtraMeta        map[string]interface{} `json:"extra_meta,omitempty"`
+	// Initiative 导出时频道的先攻表快照
+	Initiative *protocol.InitiativeTracker `json:"initiative,omitempty"`
+	// IncludeRoundTrip JSON 导出是否附带可回导的 sealchat 数据块
+	IncludeRoundTrip bool `json:"-"`
 }
 
 type quickFormatRenderOptions struct {
@@ -88,11 +110,16 @@ var formatterRegistry = map[string]exportFormatter{
 	"json": jsonFormatter{},
 	"txt":  textFormatter{},
 	"html": htmlFormatter{},
+	"md":   markdownFormatter{},
+	"epub": epubFormatter{},
+	"pdf":  pdfFormatter{},
 }
 
 type diceLogPayload struct {
 	Version int           `json:"version"`
 	Items   []diceLogItem `json:"items"`
+	// SealChat 保留完整导出数据，供其他 SealChat 实例回导；海豹染色器会忽略该字段
+	SealChat *ExportPayload `json:"sealchat,omitempty"`
 }
 
 type diceLogItem struct {
@@ -139,6 +166,10 @@ func buildExportPayload(job *model.MessageExportJobModel, channelName string, me
 			exportContent = expanded.Plain
 			htmlContent = expanded.HTML
 		}
+		if poll := ParsePollWidget(msg.WidgetData); poll != nil {
+			exportContent = renderPollExportPlain(poll)
+			htmlContent = renderPollExportHTML(poll)
+		}
 		plainContent := buildFilteredPlainContent(exportContent, includeImages)
 		if plainContent == "" {
 			continue
@@ -178,6 +209,7 @@ func buildExportPayload(job *model.MessageExportJobModel, channelName string, me
 			Content:          exportContent,
 			ContentHTML:      htmlContent,
 			WhisperTargets:   extractWhisperTargets(msg, job.ChannelID, identityResolver),
+			DisplayOrder:     msg.DisplayOrder,
 		})
 	}
 
@@ -197,6 +229,7 @@ func buildExportPayload(job *model.MessageExportJobModel, channelName string, me
 		WithoutTimestamp: job.WithoutTimestamp,
 		IncludeImages:    includeImages,
 		IncludeDiceCmds:  includeDiceCommand,
+		Initiative:       loadExportInitiative(job.ChannelID),
 		Meta: map[string]bool{
 			"include_ooc":           job.IncludeOOC,
 			"include_archived":      job.IncludeArchived,
@@ -2843,6 +2876,9 @@ func (jsonFormatter) Build(payload *ExportPayload) ([]byte, error) {
 		return nil, fmt.Errorf("payload 为空")
 	}
 	dicePayload := buildDiceLogPayload(payload)
+	if payload.IncludeRoundTrip {
+		dicePayload.SealChat = buildRoundTripPayload(payload)
+	}
 	return json.MarshalIndent(dicePayload, "", "  ")
 }
 
@@ -2902,6 +2938,7 @@ func (textFormatter) Build(payload *ExportPayload) ([]byte, error) {
 		}
 		sb.WriteString(line + "\n")
 	}
+	sb.WriteString(buildInitiativeTextSection(payload.Initiative))
 	return []byte(sb.String()), nil
 }
 
@@ -3330,6 +3367,9 @@ var exportHTMLTemplate = htmltemplate.Must(htmltemplate.New("export_html").Funcs
 	"safeCSS": func(s string) htmltemplate.CSS {
 		return htmltemplate.CSS(s)
 	},
+	"initiativeTitle":      formatInitiativeTitle,
+	"initiativeValue":      formatInitiativeValue,
+	"initiativeConditions": formatInitiativeConditions,
 }).Parse(`<!DOCTYPE html>
 <html lang="zh">
 <head>
@@ -3397,6 +3437,25 @@ var exportHTMLTemplate = htmltemplate.Must(htmltemplate.New("export_html").Funcs
     .export-sticky-note-list__item { display: flex; align-items: center; gap: 0.45em; padding: 0.18em 0; }
     .export-sticky-note-list__checkbox { width: 1em; height: 1em; flex: none; display: inline-grid; place-items: center; border-radius: 3px; border: 1px solid rgba(15,23,42,0.28); font-size: 0.78em; line-height: 1; }
     .export-sticky-note-list__item--checked .export-sticky-note-list__text { color: #64748b; text-decoration: line-through; }
+    .export-poll { margin: 0.4em 0; padding: 0.7em 0.85em; border: 1px solid rgba(15,23,42,0.12); border-radius: 6px; background: rgba(248,250,252,0.9); white-space: normal; }
+    .export-poll__header { display: flex; align-items: baseline; justify-content: space-between; gap: 0.75em; margin-bottom: 0.5em; }
+    .export-poll__question { font-weight: 700; color: #111827; }
+    .export-poll__meta, .export-poll__footer { font-size: 0.8em; color: #64748b; }
+    .export-poll__options { list-style: none; margin: 0; padding: 0; display: grid; gap: 0.45em; }
+    .export-poll__option-head { display: flex; justify-content: space-between; gap: 0.75em; }
+    .export-poll__option-count { font-size: 0.85em; color: #475569; white-space: nowrap; }
+    .export-poll__bar { height: 0.45em; margin-top: 0.2em; overflow: hidden; border-radius: 999px; background: rgba(15,23,42,0.1); }
+    .export-poll__bar-fill { height: 100%; border-radius: inherit; background: #3b82f6; }
+    .export-poll__voters { margin-top: 0.2em; font-size: 0.8em; color: #64748b; }
+    .export-poll__footer { margin-top: 0.5em; }
+    .export-initiative { margin-top: 1.5rem; padding: 12px 16px; background: #fff; border-radius: 6px; box-shadow: 0 1px 2px rgba(0,0,0,0.05); }
+    .export-initiative h2 { margin: 0 0 0.5em; font-size: 1.1rem; }
+    .export-initiative ol { margin: 0; padding-left: 1.5em; }
+    .export-initiative li { padding: 0.2em 0; }
+    .export-initiative__value { margin-left: 0.5em; color: #475569; font-variant-numeric: tabular-nums; }
+    .export-initiative__conditions { margin-left: 0.5em; font-size: 0.85em; color: #b45309; }
+    .export-initiative__item--current { font-weight: 700; }
+    .export-initiative__item--current::marker { color: #3b82f6; }
   </style>
 </head>
 <body>
@@ -3411,6 +3470,16 @@ var exportHTMLTemplate = htmltemplate.Must(htmltemplate.New("export_html").Funcs
       <div class="content"><span class="sender">&lt;{{.SenderName}}&gt;</span>{{if and .IsWhisper .WhisperTargets}}<span class="whisper-meta">{{formatWhisperMeta .WhisperTargets}}</span>{{end}}{{if .ContentHTML}}{{safeHTML .ContentHTML}}{{else}}{{.Content}}{{end}}</div>
     </article>
   {{end}}
+  {{with .Initiative}}
+  <section class="export-initiative">
+    <h2>{{initiativeTitle .}}</h2>
+    <ol>
+      {{range .Combatants}}
+      <li class="{{if and $.Initiative.Active (eq .ID $.Initiative.CurrentCombatantID)}}export-initiative__item--current{{end}}"{{if .Color}} style="color: {{.Color}}"{{end}}>{{.Name}}<span class="export-initiative__value">{{initiativeValue .}}</span>{{with initiativeConditions .Conditions}}<span class="export-initiative__conditions">{{.}}</span>{{end}}</li>
+      {{end}}
+    </ol>
+  </section>
+  {{end}}
 </body>
 </html>`))
 
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"sealchat/protocol"
+
+	htmlnode "golang.org/x/net/html"
+)
+
+// 每个 EPUB 章节包含的消息条数，避免单个 XHTML 过大导致阅读器卡顿
+const epubChapterMessageLimit = 500
+
+type epubFormatter struct{}
+
+func (epubFormatter) Ext() string {
+	return "epub"
+}
+
+func (epubFormatter) ContentType() string {
+	return "application/epub+zip"
+}
+
+type epubImageAsset struct {
+	href      string
+	mediaType string
+	data      []byte
+}
+
+type epubChapter struct {
+	file  string
+	title string
+	body  []byte
+}
+
+// epubAssetBook 收集章节引用的图片：导出前已由 inlineImageEmbedder 内联为 data URL 并去重为 scasset 引用。
+type epubAssetBook struct {
+	inline  map[string]string
+	byKey   map[string]*epubImageAsset
+	ordered []*epubImageAsset
+}
+
+func newEPUBAssetBook(inline map[string]string) *epubAssetBook {
+	return &epubAssetBook{
+		inline: inline,
+		byKey:  make(map[string]*epubImageAsset),
+	}
+}
+
+// resolve 返回图片在 EPUB 内的相对路径；无法内嵌时返回空字符串。
+func (b *epubAssetBook) resolve(src string) string {
+	src = strings.TrimSpace(src)
+	key := src
+	dataURL := src
+	if strings.HasPrefix(src, inlineAssetRefPrefix) {
+		dataURL = b.inline[strings.TrimPrefix(src, inlineAssetRefPrefix)]
+	}
+	if !strings.HasPrefix(strings.ToLower(dataURL), "data:") {
+		return ""
+	}
+	if asset, ok := b.byKey[key]; ok {
+		return asset.href
+	}
+	mediaType, data, ok := decodeEPUBDataURL(dataURL)
+	if !ok {
+		return ""
+	}
+	asset := &epubImageAsset{
+		href:      fmt.Sprintf("images/img-%04d.%s", len(b.ordered)+1, epubImageExt(mediaType)),
+		mediaType: mediaType,
+		data:      data,
+	}
+	b.byKey[key] = asset
+	b.ordered = append(b.ordered, asset)
+	return asset.href
+}
+
+func decodeEPUBDataURL(dataURL string) (string, []byte, bool) {
+	trimmed := strings.TrimSpace(dataURL)
+	if len(trimmed) < 5 || !strings.EqualFold(trimmed[:5], "data:") {
+		return "", nil, false
+	}
+	meta, encoded, found := strings.Cut(trimmed[5:], ",")
+	if !found {
+		return "", nil, false
+	}
+	mediaType := "application/octet-stream"
+	isBase64 := false
+	for i, part := range strings.Split(meta, ";") {
+		part = strings.TrimSpace(part)
+		if i == 0 && part != "" {
+			mediaType = strings.ToLower(part)
+		} else if strings.EqualFold(part, "base64") {
+			isBase64 = true
+		}
+	}
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", nil, false
+	}
+	var data []byte
+	var err error
+	if isBase64 {
+		data, err = base64.StdEncoding.DecodeString(encoded)
+	} else {
+		var decoded string
+		decoded, err = url.PathUnescape(encoded)
+		data = []byte(decoded)
+	}
+	if err != nil || len(data) == 0 {
+		return "", nil, false
+	}
+	return mediaType, data, true
+}
+
+func epubImageExt(mediaType string) string {
+	switch mediaType {
+	case "image/png":
+		return "png"
+	case "image/jpeg", "image/jpg":
+		return "jpg"
+	case "image/gif":
+		return "gif"
+	case "image/webp":
+		return "webp"
+	case "image/svg+xml":
+		return "svg"
+	case "image/avif":
+		return "avif"
+	default:
+		return "bin"
+	}
+}
+
+func (epubFormatter) Build(payload *ExportPayload) ([]byte, error) {
+	if payload == nil {
+		return nil, fmt.Errorf("payload 为空")
+	}
+	assets := newEPUBAssetBook(payload.InlineAssets)
+	chapters := buildEPUBChapters(payload, assets)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	// mimetype 必须是第一个且不压缩的条目
+	mimeWriter, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mimeWriter.Write([]byte("application/epub+zip")); err != nil {
+		return nil, err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"META-INF/container.xml", []byte(epubContainerXML)},
+		{"OEBPS/style.css", []byte(epubStyleCSS)},
+		{"OEBPS/nav.xhtml", buildEPUBNav(payload, chapters)},
+		{"OEBPS/content.opf", buildEPUBPackage(payload, chapters, assets)},
+	}
+	for _, chapter := range chapters {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{"OEBPS/" + chapter.file, chapter.body})
+	}
+	for _, asset := range assets.ordered {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{"OEBPS/" + asset.href, asset.data})
+	}
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func buildEPUBChapters(payload *ExportPayload, assets *epubAssetBook) []epubChapter {
+	total := (len(payload.Messages) + epubChapterMessageLimit - 1) / epubChapterMessageLimit
+	if total == 0 {
+		total = 1
+	}
+	chapters := make([]epubChapter, 0, total)
+	for index := 0; index < total; index++ {
+		start := index * epubChapterMessageLimit
+		end := start + epubChapterMessageLimit
+		if end > len(payload.Messages) {
+			end = len(payload.Messages)
+		}
+		messages := payload.Messages[start:end]
+		title := fmt.Sprintf("第 %d 部分", index+1)
+		if len(messages) > 0 && !payload.WithoutTimestamp {
+			title += fmt.Sprintf("（%s ~ %s）",
+				messages[0].CreatedAt.Format("2006-01-02 15:04"),
+				messages[len(messages)-1].CreatedAt.Format("2006-01-02 15:04"))
+		}
+		var body strings.Builder
+		body.WriteString(epubXHTMLHeader(title))
+		if index == 0 {
+			body.WriteString(`<section class="meta">`)
+			body.WriteString(`<h1>` + htmlEscape(payload.ChannelName) + `</h1>`)
+			body.WriteString(`<div><strong>频道：</strong>` + htmlEscape(payload.ChannelName) + ` (` + htmlEscape(payload.ChannelID) + `)</div>`)
+			body.WriteString(`<div><strong>导出时间：</strong>` + payload.GeneratedAt.Format("2006-01-02 15:04:05") + `</div>`)
+			body.WriteString(fmt.Sprintf(`<div><strong>消息数量：</strong>%d</div>`, len(payload.Messages)))
+			body.WriteString(`</section>`)
+		}
+		body.WriteString(`<h2 class="chapter-title">` + htmlEscape(title) + `</h2>`)
+		for i := range messages {
+			body.WriteString(buildEPUBMessage(payload, &messages[i], assets))
+		}
+		if index == total-1 {
+			body.WriteString(buildEPUBInitiativeSection(payload.Initiative))
+		}
+		body.WriteString("</body>\n</html>\n")
+		chapters = append(chapters, epubChapter{
+			file:  fmt.Sprintf("chapter-%03d.xhtml", index+1),
+			title: title,
+			body:  []byte(body.String()),
+		})
+	}
+	return chapters
+}
+
+// buildEPUBMessage 与 HTML 导出模板的消息结构保持一致，样式类名共用。
+func buildEPUBMessage(payload *ExportPayload, msg *ExportMessage, assets *epubAssetBook) string {
+	classes := []string{"message"}
+	if strings.EqualFold(msg.IcMode, "ooc") {
+		classes = append(classes, "ooc")
+	}
+	if msg.IsWhisper {
+		classes = append(classes, "whisper")
+	}
+	if msg.IsArchived {
+		classes = append(classes, "archived")
+	}
+	var sb strings.Builder
+	sb.WriteString(`<div class="` + strings.Join(classes, " ") + `">`)
+	if !payload.WithoutTimestamp {
+		sb.WriteString(`<div class="timestamp">` + msg.CreatedAt.Format("2006-01-02 15:04:05") + `</div>`)
+	}
+	sb.WriteString(`<div class="content"><span class="sender">&lt;` + htmlEscape(msg.SenderName) + `&gt;</span>`)
+	if msg.IsWhisper && len(msg.WhisperTargets) > 0 {
+		sb.WriteString(`<span class="whisper-meta">` + htmlEscape(formatWhisperMetaText(msg.WhisperTargets)) + `</span>`)
+	}
+	content := ""
+	if strings.TrimSpace(msg.ContentHTML) != "" {
+		content = convertHTMLToEPUBXHTML(msg.ContentHTML, assets)
+	}
+	if content == "" {
+		plain := buildFilteredPlainContent(msg.Content, payload.IncludeImages)
+		content = strings.ReplaceAll(htmlEscape(plain), "\n", "<br/>")
+	}
+	sb.WriteString(content)
+	sb.WriteString("</div></div>\n")
+	return sb.String()
+}
+
+// buildEPUBInitiativeSection 在最后一章末尾附上先攻表，结构与 HTML 导出一致。
+func buildEPUBInitiativeSection(tracker *protocol.InitiativeTracker) string {
+	if tracker == nil || len(tracker.Combatants) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(`<section class="export-initiative"><h2>` + htmlEscape(formatInitiativeTitle(tracker)) + `</h2><ol>`)
+	for _, item := range tracker.Combatants {
+		sb.WriteString(`<li`)
+		if tracker.Active && item.ID == tracker.CurrentCombatantID {
+			sb.WriteString(` class="export-initiative__item--current"`)
+		}
+		// 仅输出规整后的十六进制颜色，避免把任意内容写进 style
+		if color := sanitizeBBCodeColor(item.Color, ""); color != "" {
+			sb.WriteString(` style="color: ` + color + `"`)
+		}
+		sb.WriteString(`>` + htmlEscape(item.Name))
+		sb.WriteString(`<span class="export-initiative__value">` + htmlEscape(formatInitiativeValue(item)) + `</span>`)
+		if conditions := formatInitiativeConditions(item.Conditions); conditions != "" {
+			sb.WriteString(`<span class="export-initiative__conditions">` + htmlEscape(conditions) + `</span>`)
+		}
+		sb.WriteString(`</li>`)
+	}
+	sb.WriteString("</ol></section>\n")
+	return sb.String()
+}
+
+// convertHTMLToEPUBXHTML 将消息 HTML 规整为合法的 XHTML 片段，并把图片替换为书内资源。
+func convertHTMLToEPUBXHTML(content string, assets *epubAssetBook) string {
+	nodes, err := htmlnode.ParseFragment(strings.NewReader(content), nil)
+	if err != nil {
+		return ""
+	}
+	container := &htmlnode.Node{Type: htmlnode.ElementNode, Data: "div"}
+	for _, node := range nodes {
+		container.AppendChild(node)
+	}
+	sanitizeEPUBNode(container, assets)
+	var buf bytes.Buffer
+	for child := container.FirstChild; child != nil; child = child.NextSibling {
+		if err := htmlnode.Render(&buf, child); err != nil {
+			return ""
+		}
+	}
+	// html.Render 可能输出 XML 未定义的命名实体
+	return strings.ReplaceAll(buf.String(), "&nbsp;", "&#160;")
+}
+
+func isEPUBDroppedTag(tag string) bool {
+	switch strings.ToLower(tag) {
+	case "script", "style", "iframe", "object", "embed", "form", "input", "button":
+		return true
+	}
+	return false
+}
+
+func sanitizeEPUBNode(node *htmlnode.Node, assets *epubAssetBook) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch {
+		case child.Type == htmlnode.CommentNode:
+			node.RemoveChild(child)
+		case child.Type == htmlnode.ElementNode && isEPUBDroppedTag(child.Data):
+			node.RemoveChild(child)
+		case child.Type == htmlnode.ElementNode:
+			sanitizeEPUBNode(child, assets)
+			if strings.EqualFold(child.Data, "img") {
+				if replacement := rewriteEPUBImage(child, assets); replacement != nil {
+					node.InsertBefore(replacement, child)
+					node.RemoveChild(child)
+				}
+			}
+		}
+		child = next
+	}
+	if node.Type != htmlnode.ElementNode {
+		return
+	}
+	attrs := node.Attr[:0]
+	for _, attr := range node.Attr {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || strings.HasPrefix(key, "on") || !isEPUBAttrName(key) {
+			continue
+		}
+		attr.Key = key
+		attrs = append(attrs, attr)
+	}
+	node.Attr = attrs
+}
+
+// rewriteEPUBImage 将可内嵌的图片指向书内资源；无法内嵌时返回替换用的文字链接节点。
+func rewriteEPUBImage(node *htmlnode.Node, assets *epubAssetBook) *htmlnode.Node {
+	src := firstNonEmpty(htmlNodeAttr(node, "src"), htmlNodeAttr(node, "data-src"), htmlNodeAttr(node, "data-original"))
+	if href := assets.resolve(src); href != "" {
+		attrs := make([]htmlnode.Attribute, 0, len(node.Attr)+1)
+		hasAlt := false
+		for _, attr := range node.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "src":
+				continue
+			case "data-src", "data-original":
+				continue
+			case "alt":
+				hasAlt = true
+			}
+			attrs = append(attrs, attr)
+		}
+		attrs = append(attrs, htmlnode.Attribute{Key: "src", Val: href})
+		if !hasAlt {
+			attrs = append(attrs, htmlnode.Attribute{Key: "alt", Val: ""})
+		}
+		node.Attr = attrs
+		return nil
+	}
+	label := strings.TrimSpace(htmlNodeAttr(node, "alt"))
+	if label == "" {
+		label = "图片"
+	}
+	link := &htmlnode.Node{Type: htmlnode.ElementNode, Data: "span", Attr: []htmlnode.Attribute{{Key: "class", Val: "image-missing"}}}
+	if resolved := resolveImageURL(src); strings.HasPrefix(resolved, "http://") || strings.HasPrefix(resolved, "https://") {
+		link = &htmlnode.Node{Type: htmlnode.ElementNode, Data: "a", Attr: []htmlnode.Attribute{{Key: "href", Val: resolved}}}
+	}
+	link.AppendChild(&htmlnode.Node{Type: htmlnode.TextNode, Data: "[" + label + "]"})
+	return link
+}
+
+func isEPUBAttrName(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case r == '_' || r == ':' && i > 0:
+		case (r >= '0' && r <= '9' || r == '-' || r == '.') && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func epubXHTMLHeader(title string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="zh" xml:lang="zh">
+<head>
+<meta charset="UTF-8"/>
+<title>` + htmlEscape(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+`
+}
+
+func buildEPUBNav(payload *ExportPayload, chapters []epubChapter) []byte {
+	var sb strings.Builder
+	sb.WriteString(epubXHTMLHeader("目录"))
+	sb.WriteString(`<nav epub:type="toc" id="toc"><h1>` + htmlEscape(payload.ChannelName) + `</h1><ol>`)
+	for _, chapter := range chapters {
+		sb.WriteString(`<li><a href="` + chapter.file + `">` + htmlEscape(chapter.title) + `</a></li>`)
+	}
+	sb.WriteString("</ol></nav>\n</body>\n</html>\n")
+	return []byte(sb.String())
+}
+
+func buildEPUBPackage(payload *ExportPayload, chapters []epubChapter, assets *epubAssetBook) []byte {
+	generatedAt := payload.GeneratedAt
+	if generatedAt.IsZero() {
+		generatedAt = time.Now()
+	}
+	identifier := fmt.Sprintf("urn:sealchat:export:%s:%d", payload.ChannelID, generatedAt.UnixNano())
+	title := strings.TrimSpace(payload.ChannelName)
+	if title == "" {
+		title = defaultExportFileBaseName
+	}
+	if payload.PartTotal > 1 {
+		title = fmt.Sprintf("%s（%d/%d）", title, payload.PartIndex, payload.PartTotal)
+	}
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="zh">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	sb.WriteString(`<dc:identifier id="book-id">` + htmlEscape(identifier) + "</dc:identifier>\n")
+	sb.WriteString(`<dc:title>` + htmlEscape(title) + "</dc:title>\n")
+	sb.WriteString("<dc:language>zh</dc:language>\n")
+	sb.WriteString("<dc:creator>SealChat</dc:creator>\n")
+	sb.WriteString(`<meta property="dcterms:modified">` + generatedAt.UTC().Format("2006-01-02T15:04:05Z") + "</meta>\n")
+	sb.WriteString("</metadata>\n<manifest>\n")
+	sb.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	sb.WriteString(`<item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for i, chapter := range chapters {
+		sb.WriteString(fmt.Sprintf(`<item id="chapter-%d" href="%s" media-type="application/xhtml+xml"/>`+"\n", i+1, chapter.file))
+	}
+	for i, asset := range assets.ordered {
+		sb.WriteString(fmt.Sprintf(`<item id="image-%d" href="%s" media-type="%s"/>`+"\n", i+1, asset.href, htmlEscape(asset.mediaType)))
+	}
+	sb.WriteString("</manifest>\n<spine>\n")
+	for i := range chapters {
+		sb.WriteString(fmt.Sprintf(`<itemref idref="chapter-%d"/>`+"\n", i+1))
+	}
+	sb.WriteString("</spine>\n</package>\n")
+	return []byte(sb.String())
+}
+
+const epubContainerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubStyleCSS = `body { font-family: serif; line-height: 1.6; margin: 0 0.5em; }
+.meta { margin-bottom: 1.5em; color: #555; }
+.meta h1 { font-size: 1.4em; margin: 0 0 0.5em; }
+.chapter-title { font-size: 1.1em; color: #666; margin: 1.2em 0 0.8em; }
+.message { padding: 0.4em 0.6em; margin-bottom: 0.5em; border-left: 3px solid transparent; page-break-inside: avoid; }
+.message.ooc { border-left-color: #eab308; color: #555; }
+.message.whisper { border-left-color: #6366f1; }
+.message.archived { opacity: 0.7; }
+.timestamp { color: #888; font-size: 0.8em; }
+.sender { font-weight: bold; margin-right: 0.3em; }
+.whisper-meta { margin: 0 0.3em; color: #666; font-size: 0.82em; }
+.content p { margin: 0.3em 0; }
+.content blockquote { margin: 0.4em 0; padding-left: 0.8em; border-left: 3px solid #ddd; color: #666; }
+.content pre, .content code { font-family: monospace; background: #f4f4f4; }
+.content img { max-width: 100%; height: auto; }
+.mention-capsule { color: #3b82f6; }
+.dice-chip { display: inline-block; padding: 0 0.35em; border: 1px solid #cbd5e1; border-radius: 0.4em; background: #f1f5f9; font-family: monospace; }
+.dice-chip__equals { margin: 0 0.15em; }
+.dice-chip__result { font-weight: bold; }
+.dice-chip--error { border-color: #fca5a5; background: #fef2f2; }
+.dice-chip__outcome { margin-left: 0.3em; font-weight: bold; }
+.tiptap-spoiler { background: #cbd5e1; color: #cbd5e1; }
+.export-sticky-note { margin: 0.5em 0; padding: 0.5em 0.7em; border: 1px solid #ddd; border-left: 4px solid #64748b; }
+.export-sticky-note__header { margin-bottom: 0.3em; }
+.export-sticky-note__title { font-weight: bold; }
+.export-sticky-note__type { margin-left: 0.5em; font-size: 0.8em; color: #64748b; }
+.export-sticky-note-list { list-style: none; padding: 0; }
+.export-poll { margin: 0.5em 0; padding: 0.5em 0.7em; border: 1px solid #ddd; }
+.export-poll__question { font-weight: bold; }
+.export-poll__meta { margin-left: 0.5em; font-size: 0.8em; color: #64748b; }
+.export-poll__options { list-style: none; padding: 0; }
+.export-poll__option-count { margin-left: 0.5em; color: #475569; }
+.export-poll__bar { height: 0.4em; background: #e2e8f0; }
+.export-poll__bar-fill { height: 100%; background: #3b82f6; }
+.export-poll__voters, .export-poll__footer { font-size: 0.8em; color: #64748b; }
+.export-initiative { margin-top: 1.5em; padding-top: 0.8em; border-top: 1px solid #ddd; }
+.export-initiative h2 { font-size: 1.1em; margin: 0 0 0.5em; }
+.export-initiative__value { margin-left: 0.5em; color: #475569; }
+.export-initiative__conditions { margin-left: 0.5em; font-size: 0.85em; color: #b45309; }
+.export-initiative__item--current { font-weight: bold; }
+.image-missing { color: #888; }
+`
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEPUBFormatterBuildsSelfContainedBook(t *testing.T) {
+	const pngDataURL = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mP8/x8AAwMCAO7Z0ioAAAAASUVORK5CYII="
+	now := time.Unix(1700004000, 0)
+	payload := &ExportPayload{
+		ChannelID:    "ch-epub",
+		ChannelName:  "跑团 & 记录",
+		GeneratedAt:  now,
+		InlineAssets: map[string]string{"asset1": pngDataURL},
+		Messages: []ExportMessage{
+			{
+				SenderName:  "KP",
+				IcMode:      "ooc",
+				CreatedAt:   now,
+				ContentHTML: `<p>图&nbsp;片<br><img src="scasset:asset1" onclick="x()"></p><script>alert(1)</script>`,
+			},
+			{
+				SenderName:     "玩家",
+				IcMode:         "ic",
+				IsWhisper:      true,
+				WhisperTargets: []string{"KP"},
+				CreatedAt:      now,
+				ContentHTML:    `<p><img src="https://example.com/remote.png" alt="远程"></p>`,
+			},
+		},
+	}
+
+	data, err := epubFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build epub failed: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open epub zip failed: %v", err)
+	}
+	if len(reader.File) == 0 || reader.File[0].Name != "mimetype" || reader.File[0].Method != zip.Store {
+		t.Fatalf("expected stored mimetype as first entry")
+	}
+	files := map[string]string{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s failed: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[file.Name] = string(content)
+	}
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/chapter-001.xhtml", "OEBPS/images/img-0001.png"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("expected %s in epub", name)
+		}
+	}
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".xhtml") && !strings.HasSuffix(name, ".opf") {
+			continue
+		}
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed xml: %v\n%s", name, err, content)
+			}
+		}
+	}
+	chapter := files["OEBPS/chapter-001.xhtml"]
+	expects := []string{
+		`class="message ooc"`,
+		`class="message whisper"`,
+		`<img src="images/img-0001.png" alt=""/>`,
+		`<a href="https://example.com/remote.png">[远程]</a>`,
+		"发送给 KP",
+	}
+	for _, expected := range expects {
+		if !strings.Contains(chapter, expected) {
+			t.Fatalf("expect chapter contains %q, got:\n%s", expected, chapter)
+		}
+	}
+	if strings.Contains(chapter, "<script") || strings.Contains(chapter, "onclick") {
+		t.Fatalf("expected scripts and handlers to be stripped, got:\n%s", chapter)
+	}
+	if !strings.Contains(files["OEBPS/content.opf"], `href="images/img-0001.png" media-type="image/png"`) {
+		t.Fatalf("expected image in manifest, got:\n%s", files["OEBPS/content.opf"])
+	}
+}
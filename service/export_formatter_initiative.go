@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"sealchat/model"
+	"sealchat/protocol"
+)
+
+// loadExportInitiative 导出时附带频道当前的先攻表，没有参战单位时不输出
+func loadExportInitiative(channelID string) *protocol.InitiativeTracker {
+	channelID = strings.TrimSpace(channelID)
+	if channelID == "" || model.GetDB() == nil {
+		return nil
+	}
+	tracker, err := InitiativeGet(channelID)
+	if err != nil || tracker == nil || len(tracker.Combatants) == 0 {
+		return nil
+	}
+	return tracker
+}
+
+func formatInitiativeTitle(tracker *protocol.InitiativeTracker) string {
+	if tracker.Active && tracker.Round > 0 {
+		return fmt.Sprintf("先攻表（第 %d 轮）", tracker.Round)
+	}
+	return "先攻表"
+}
+
+func formatInitiativeValue(item *protocol.InitiativeCombatant) string {
+	if !item.HasInitiative {
+		return "-"
+	}
+	return fmt.Sprintf("%d", item.Initiative)
+}
+
+// formatInitiativeConditions 输出如“中毒(2轮)、倒地”，无限期状态不标轮数
+func formatInitiativeConditions(conditions []*protocol.InitiativeCondition) string {
+	parts := make([]string, 0, len(conditions))
+	for _, cond := range conditions {
+		if cond == nil || cond.Name == "" {
+			continue
+		}
+		if cond.Rounds > 0 {
+			parts = append(parts, fmt.Sprintf("%s(%d轮)", cond.Name, cond.Rounds))
+		} else {
+			parts = append(parts, cond.Name)
+		}
+	}
+	return strings.Join(parts, "、")
+}
+
+func buildInitiativeTextSection(tracker *protocol.InitiativeTracker) string {
+	if tracker == nil || len(tracker.Combatants) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("---\n" + formatInitiativeTitle(tracker) + "\n")
+	for i, item := range tracker.Combatants {
+		marker := "  "
+		if tracker.Active && item.ID == tracker.CurrentCombatantID {
+			marker = "> "
+		}
+		line := fmt.Sprintf("%s%d. %s  先攻 %s", marker, i+1, item.Name, formatInitiativeValue(item))
+		if conditions := formatInitiativeConditions(item.Conditions); conditions != "" {
+			line += "  [" + conditions + "]"
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+func buildInitiativeMarkdownSection(tracker *protocol.InitiativeTracker) string {
+	if tracker == nil || len(tracker.Combatants) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## " + formatInitiativeTitle(tracker) + "\n\n")
+	sb.WriteString("| 顺序 | 名称 | 先攻 | 状态 |\n| --- | --- | --- | --- |\n")
+	for i, item := range tracker.Combatants {
+		name := escapeMarkdownText(item.Name)
+		if tracker.Active && item.ID == tracker.CurrentCombatantID {
+			name = "**" + name + "** ◀"
+		}
+		conditions := escapeMarkdownText(formatInitiativeConditions(item.Conditions))
+		sb.WriteString(fmt.Sprintf("| %d | %s | %s | %s |\n", i+1, name, formatInitiativeValue(item), conditions))
+	}
+	return sb.String()
+}
@@ -0,0 +1,434 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	htmlnode "golang.org/x/net/html"
+)
+
+type markdownFormatter struct{}
+
+func (markdownFormatter) Ext() string {
+	return "md"
+}
+
+func (markdownFormatter) ContentType() string {
+	return "text/markdown; charset=utf-8"
+}
+
+func (markdownFormatter) Build(payload *ExportPayload) ([]byte, error) {
+	if payload == nil {
+		return nil, fmt.Errorf("payload 为空")
+	}
+	var sb strings.Builder
+	sb.WriteString("# 频道导出 - " + escapeMarkdownText(payload.ChannelName) + "\n\n")
+	sb.WriteString(fmt.Sprintf("- 频道：%s (%s)\n", escapeMarkdownText(payload.ChannelName), escapeMarkdownText(payload.ChannelID)))
+	sb.WriteString(fmt.Sprintf("- 导出时间：%s\n", payload.GeneratedAt.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("- 消息数量：%d\n\n---\n\n", len(payload.Messages)))
+	for i := range payload.Messages {
+		block := buildMarkdownMessageBlock(payload, &payload.Messages[i])
+		if block == "" {
+			continue
+		}
+		sb.WriteString(block)
+		sb.WriteString("\n\n")
+	}
+	if section := buildInitiativeMarkdownSection(payload.Initiative); section != "" {
+		sb.WriteString("---\n\n" + section)
+	}
+	return []byte(strings.TrimRight(sb.String(), "\n") + "\n"), nil
+}
+
+// buildMarkdownMessageBlock 输出单条消息：单行内容与发言人同一行，多行内容另起段落。
+func buildMarkdownMessageBlock(payload *ExportPayload, msg *ExportMessage) string {
+	if payload == nil || msg == nil {
+		return ""
+	}
+	var headerParts []string
+	if !payload.WithoutTimestamp {
+		headerParts = append(headerParts, fmt.Sprintf("`%s`", msg.CreatedAt.Format("2006-01-02 15:04:05")))
+	}
+	headerParts = append(headerParts, fmt.Sprintf("**%s**", escapeMarkdownText("<"+msg.SenderName+">")))
+	if msg.IsArchived {
+		headerParts = append(headerParts, "\\[已归档\\]")
+	}
+	if msg.IsWhisper {
+		if label := formatWhisperTargets(msg.WhisperTargets); label != "" {
+			headerParts = append(headerParts, "*"+escapeMarkdownText(label)+"*")
+		}
+	}
+	header := strings.Join(headerParts, " ")
+
+	body := ""
+	if strings.TrimSpace(msg.ContentHTML) != "" {
+		body = convertHTMLToMarkdown(msg.ContentHTML)
+	}
+	if body == "" {
+		body = escapeMarkdownText(buildFilteredPlainContent(msg.Content, payload.IncludeImages))
+		body = strings.ReplaceAll(body, "\n", "  \n")
+	}
+	body = wrapOOCContent(msg.IcMode, body)
+	if body == "" {
+		return header
+	}
+	if strings.Contains(body, "\n") || markdownStartsWithBlock(body) {
+		return header + "\n\n" + body
+	}
+	return header + " " + body
+}
+
+var (
+	markdownEscapePattern     = regexp.MustCompile("([\\\\`*_\\[\\]<>|~])")
+	markdownBlankLinesPattern = regexp.MustCompile(`\n{3,}`)
+	markdownBlockStartPattern = regexp.MustCompile(`^(#{1,6} |> |- |\d+\. |` + "```" + `|---)`)
+)
+
+func escapeMarkdownText(input string) string {
+	if input == "" {
+		return ""
+	}
+	return markdownEscapePattern.ReplaceAllString(input, `\$1`)
+}
+
+func markdownStartsWithBlock(body string) bool {
+	return markdownBlockStartPattern.MatchString(body)
+}
+
+// convertHTMLToMarkdown 将导出用的 HTML 内容（TipTap 渲染结果、快捷格式、骰子、便签）转换为 Markdown。
+func convertHTMLToMarkdown(content string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	nodes, err := htmlnode.ParseFragment(strings.NewReader(content), nil)
+	if err != nil {
+		return escapeMarkdownText(stripRichText(content))
+	}
+	w := &markdownWriter{}
+	for _, node := range nodes {
+		w.writeNode(node)
+	}
+	return w.String()
+}
+
+type markdownWriter struct {
+	sb strings.Builder
+}
+
+func (w *markdownWriter) String() string {
+	result := markdownBlankLinesPattern.ReplaceAllString(w.sb.String(), "\n\n")
+	return strings.Trim(result, "\n ")
+}
+
+func (w *markdownWriter) write(text string) {
+	w.sb.WriteString(text)
+}
+
+// blockBreak 确保块级元素前后有空行。
+func (w *markdownWriter) blockBreak() {
+	current := w.sb.String()
+	if current == "" || strings.HasSuffix(current, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(current, "\n") {
+		w.sb.WriteString("\n")
+		return
+	}
+	w.sb.WriteString("\n\n")
+}
+
+func (w *markdownWriter) writeChildren(node *htmlnode.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		w.writeNode(child)
+	}
+}
+
+func renderMarkdownChildren(node *htmlnode.Node) string {
+	sub := &markdownWriter{}
+	sub.writeChildren(node)
+	return sub.String()
+}
+
+func (w *markdownWriter) writeNode(node *htmlnode.Node) {
+	if node == nil {
+		return
+	}
+	switch node.Type {
+	case htmlnode.TextNode:
+		text := strings.ReplaceAll(node.Data, "\u00a0", " ")
+		text = escapeMarkdownText(text)
+		w.write(strings.ReplaceAll(text, "\n", "  \n"))
+		return
+	case htmlnode.ElementNode:
+	default:
+		w.writeChildren(node)
+		return
+	}
+
+	tag := strings.ToLower(node.Data)
+	className := htmlNodeAttr(node, "class")
+	switch {
+	case tag == "script" || tag == "style":
+		return
+	case htmlNodeHasClass(className, "dice-chip"):
+		w.write(renderMarkdownDiceChip(node, className))
+		return
+	case htmlNodeHasClass(className, "export-sticky-note"):
+		w.blockBreak()
+		w.write(renderMarkdownStickyNote(node))
+		w.blockBreak()
+		return
+	}
+
+	switch tag {
+	case "p", "div", "section", "header", "article":
+		w.blockBreak()
+		w.writeChildren(node)
+		w.blockBreak()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.blockBreak()
+		w.write(strings.Repeat("#", int(tag[1]-'0')) + " " + strings.ReplaceAll(renderMarkdownChildren(node), "\n", " "))
+		w.blockBreak()
+	case "br":
+		w.write("  \n")
+	case "hr":
+		w.blockBreak()
+		w.write("---")
+		w.blockBreak()
+	case "strong", "b":
+		w.writeWrapped(node, "**")
+	case "em", "i":
+		w.writeWrapped(node, "*")
+	case "s", "del", "strike":
+		w.writeWrapped(node, "~~")
+	case "code":
+		w.write(markdownInlineCode(htmlNodeText(node)))
+	case "pre":
+		w.blockBreak()
+		w.write("```\n" + strings.TrimRight(htmlNodeText(node), "\n") + "\n```")
+		w.blockBreak()
+	case "a":
+		label := renderMarkdownChildren(node)
+		href := strings.TrimSpace(htmlNodeAttr(node, "href"))
+		if label == "" {
+			label = escapeMarkdownText(href)
+		}
+		if isSafeQuickLink(href) {
+			w.write("[" + label + "](" + href + ")")
+		} else {
+			w.write(label)
+		}
+	case "img":
+		src := resolveImageURL(firstNonEmpty(htmlNodeAttr(node, "src"), htmlNodeAttr(node, "data-src"), htmlNodeAttr(node, "data-original")))
+		if src == "" || strings.HasPrefix(src, inlineAssetRefPrefix) {
+			return
+		}
+		w.write("![" + escapeMarkdownText(htmlNodeAttr(node, "alt")) + "](" + strings.ReplaceAll(src, " ", "%20") + ")")
+	case "ul", "ol":
+		w.blockBreak()
+		w.write(renderMarkdownList(node, tag == "ol"))
+		w.blockBreak()
+	case "blockquote":
+		w.blockBreak()
+		w.write(prefixMarkdownLines(renderMarkdownChildren(node), "> "))
+		w.blockBreak()
+	case "ruby":
+		w.write(renderMarkdownRuby(node))
+	default:
+		w.writeChildren(node)
+	}
+}
+
+func (w *markdownWriter) writeWrapped(node *htmlnode.Node, marker string) {
+	inner := renderMarkdownChildren(node)
+	if strings.TrimSpace(inner) == "" {
+		w.write(inner)
+		return
+	}
+	w.write(marker + inner + marker)
+}
+
+func renderMarkdownList(node *htmlnode.Node, ordered bool) string {
+	var lines []string
+	index := 0
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != htmlnode.ElementNode || !strings.EqualFold(child.Data, "li") {
+			continue
+		}
+		index++
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", index)
+		}
+		item := renderMarkdownChildren(child)
+		item = markdownBlankLinesPattern.ReplaceAllString(strings.ReplaceAll(item, "\n\n", "\n"), "\n")
+		itemLines := strings.Split(item, "\n")
+		indent := strings.Repeat(" ", len(marker))
+		for i, line := range itemLines {
+			if i == 0 {
+				itemLines[i] = marker + line
+			} else if line != "" {
+				itemLines[i] = indent + line
+			}
+		}
+		lines = append(lines, strings.Join(itemLines, "\n"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prefixMarkdownLines(text string, prefix string) string {
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func markdownInlineCode(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if text == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// renderMarkdownDiceChip 与 HTML 导出的骰子胶囊保持一致：🎲 公式=结果。
+func renderMarkdownDiceChip(node *htmlnode.Node, className string) string {
+	formula := strings.TrimSpace(htmlNodeTextByClass(node, "dice-chip__formula"))
+	result := strings.TrimSpace(htmlNodeTextByClass(node, "dice-chip__result"))
+	if formula == "" && result == "" {
+		formula = strings.TrimSpace(htmlNodeAttr(node, "data-dice-formula"))
+		result = strings.TrimSpace(htmlNodeAttr(node, "data-dice-result-value"))
+	}
+	if formula == "" && result == "" {
+		return ""
+	}
+	text := "🎲 " + formula
+	if htmlNodeHasClass(className, "dice-chip--error") {
+		text += " " + result
+	} else if result != "" {
+		text += "=" + result
+	}
+	if outcome := strings.TrimSpace(htmlNodeTextByClass(node, "dice-chip__outcome")); outcome != "" {
+		text += " " + outcome
+	}
+	return markdownInlineCode(strings.TrimSpace(text))
+}
+
+// renderMarkdownStickyNote 将便签渲染为引用块，标题行与纯文本导出的 [便签: 标题] 对应。
+func renderMarkdownStickyNote(node *htmlnode.Node) string {
+	title := strings.TrimSpace(htmlNodeTextByClass(node, "export-sticky-note__title"))
+	if title == "" {
+		title = "未命名便签"
+	}
+	label := strings.TrimSpace(htmlNodeTextByClass(node, "export-sticky-note__type"))
+	header := "**\\[便签: " + escapeMarkdownText(title) + "\\]**"
+	if label != "" {
+		header += " " + escapeMarkdownText(label)
+	}
+	body := ""
+	if bodyNode := htmlNodeFindByClass(node, "export-sticky-note__body"); bodyNode != nil {
+		body = renderMarkdownChildren(bodyNode)
+	}
+	if body == "" {
+		body = "（空便签）"
+	}
+	return prefixMarkdownLines(header+"\n\n"+body, "> ")
+}
+
+func renderMarkdownRuby(node *htmlnode.Node) string {
+	var base strings.Builder
+	var annotation strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == htmlnode.ElementNode {
+			switch strings.ToLower(child.Data) {
+			case "rt":
+				annotation.WriteString(htmlNodeText(child))
+				continue
+			case "rp":
+				continue
+			}
+		}
+		sub := &markdownWriter{}
+		sub.writeNode(child)
+		base.WriteString(sub.sb.String())
+	}
+	if strings.TrimSpace(annotation.String()) == "" {
+		return base.String()
+	}
+	return base.String() + "（" + escapeMarkdownText(strings.TrimSpace(annotation.String())) + "）"
+}
+
+func htmlNodeAttr(node *htmlnode.Node, key string) string {
+	if node == nil {
+		return ""
+	}
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func htmlNodeHasClass(className string, target string) bool {
+	for _, item := range strings.Fields(className) {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+func htmlNodeText(node *htmlnode.Node) string {
+	if node == nil {
+		return ""
+	}
+	if node.Type == htmlnode.TextNode {
+		return node.Data
+	}
+	var sb strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == htmlnode.ElementNode && strings.EqualFold(child.Data, "br") {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(htmlNodeText(child))
+	}
+	return sb.String()
+}
+
+func htmlNodeFindByClass(node *htmlnode.Node, className string) *htmlnode.Node {
+	if node == nil {
+		return nil
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == htmlnode.ElementNode && htmlNodeHasClass(htmlNodeAttr(child, "class"), className) {
+			return child
+		}
+		if found := htmlNodeFindByClass(child, className); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func htmlNodeTextByClass(node *htmlnode.Node, className string) string {
+	return htmlNodeText(htmlNodeFindByClass(node, className))
+}
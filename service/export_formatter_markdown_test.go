@@ -0,0 +1,172 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/protocol"
+)
+
+func TestMarkdownFormatterRendersMessageRules(t *testing.T) {
+	now := time.Unix(1700003000, 0)
+	payload := &ExportPayload{
+		ChannelID:   "ch-md",
+		ChannelName: "跑团记录",
+		GeneratedAt: now,
+		Messages: []ExportMessage{
+			{
+				SenderName:  "KP",
+				IcMode:      "ic",
+				CreatedAt:   now,
+				Content:     "**粗体** 与骰子",
+				ContentHTML: `<p><strong>粗体</strong> 与 <span class="dice-chip" data-dice-roll-index="0"><span class="dice-chip__icon">🎲</span><span class="dice-chip__formula">d100</span><span class="dice-chip__equals">=</span><span class="dice-chip__result">42</span></span></p>`,
+			},
+			{
+				SenderName:  "玩家",
+				IcMode:      "ooc",
+				CreatedAt:   now,
+				Content:     "场外说明",
+				ContentHTML: "场外说明",
+			},
+			{
+				SenderName:     "玩家",
+				IcMode:         "ic",
+				IsWhisper:      true,
+				WhisperTargets: []string{"KP"},
+				CreatedAt:      now,
+				Content:        "悄悄话",
+				ContentHTML:    "<p>悄悄话</p>",
+			},
+			{
+				SenderName:  "KP",
+				IcMode:      "ic",
+				CreatedAt:   now,
+				Content:     "[便签: 线索]\n- 钥匙",
+				ContentHTML: `<section class="export-sticky-note"><header class="export-sticky-note__header"><span class="export-sticky-note__title">线索</span><span class="export-sticky-note__type">清单便签</span></header><div class="export-sticky-note__body"><ul><li>钥匙</li></ul></div></section>`,
+			},
+		},
+	}
+
+	data, err := markdownFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build markdown failed: %v", err)
+	}
+	got := string(data)
+	expects := []string{
+		"# 频道导出 - 跑团记录",
+		"**\\<KP\\>** **粗体** 与 `🎲 d100=42`",
+		"**\\<玩家\\>** （场外说明）",
+		"**\\<玩家\\>** *\\[对KP\\]* 悄悄话",
+		"> **\\[便签: 线索\\]** 清单便签\n>\n> - 钥匙",
+		"`" + now.Format("2006-01-02 15:04:05") + "`",
+	}
+	for _, expected := range expects {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("expect markdown contains %q, got:\n%s", expected, got)
+		}
+	}
+}
+
+func TestConvertHTMLToMarkdownBlocks(t *testing.T) {
+	input := `<h2>标题</h2><blockquote><p>引用</p></blockquote><ol><li>一</li><li>二</li></ol><pre><code>a*b</code></pre><p><a href="https://example.com">链接</a><img src="https://example.com/a.png" alt="图" /></p>`
+	got := convertHTMLToMarkdown(input)
+	expects := []string{
+		"## 标题",
+		"> 引用",
+		"1. 一\n2. 二",
+		"```\na*b\n```",
+		"[链接](https://example.com)![图](https://example.com/a.png)",
+	}
+	for _, expected := range expects {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("expect markdown contains %q, got:\n%s", expected, got)
+		}
+	}
+}
+
+func TestExportFormattersRenderInitiative(t *testing.T) {
+	now := time.Unix(1700003000, 0)
+	payload := &ExportPayload{
+		ChannelID:   "ch-init",
+		ChannelName: "遭遇战",
+		GeneratedAt: now,
+		Messages:    []ExportMessage{{SenderName: "KP", IcMode: "ic", CreatedAt: now, Content: "开打"}},
+		Initiative: &protocol.InitiativeTracker{
+			ChannelID:          "ch-init",
+			Active:             true,
+			Round:              3,
+			CurrentCombatantID: "c-2",
+			Combatants: []*protocol.InitiativeCombatant{
+				{ID: "c-1", Name: "哥布林", IsNPC: true, HasInitiative: true, Initiative: 18},
+				{ID: "c-2", Name: "艾琳", HasInitiative: true, Initiative: 12, Conditions: []*protocol.InitiativeCondition{{Name: "中毒", Rounds: 2}, {Name: "倒地"}}},
+				{ID: "c-3", Name: "巨魔", IsNPC: true},
+			},
+		},
+	}
+
+	md, err := markdownFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build markdown failed: %v", err)
+	}
+	for _, want := range []string{"## 先攻表（第 3 轮）", "| 2 | **艾琳** ◀ | 12 | 中毒(2轮)、倒地 |", "| 3 | 巨魔 | - |  |"} {
+		if !strings.Contains(string(md), want) {
+			t.Fatalf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	txt, err := textFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build text failed: %v", err)
+	}
+	if !strings.Contains(string(txt), "> 2. 艾琳  先攻 12  [中毒(2轮)、倒地]") {
+		t.Fatalf("text missing current combatant:\n%s", txt)
+	}
+	html, err := htmlFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build html failed: %v", err)
+	}
+	if !strings.Contains(string(html), `<li class="export-initiative__item--current">艾琳`) {
+		t.Fatalf("html missing current combatant:\n%s", html)
+	}
+
+	epub, err := epubFormatter{}.Build(payload)
+	if err != nil {
+		t.Fatalf("build epub failed: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(epub), int64(len(epub)))
+	if err != nil {
+		t.Fatalf("open epub zip failed: %v", err)
+	}
+	var chapter string
+	for _, file := range reader.File {
+		if file.Name == "OEBPS/chapter-001.xhtml" {
+			rc, _ := file.Open()
+			content, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			chapter = string(content)
+		}
+	}
+	for _, want := range []string{"<h2>先攻表（第 3 轮）</h2>", `<li class="export-initiative__item--current">艾琳<span class="export-initiative__value">12</span><span class="export-initiative__conditions">中毒(2轮)、倒地</span></li>`} {
+		if !strings.Contains(chapter, want) {
+			t.Fatalf("epub missing %q:\n%s", want, chapter)
+		}
+	}
+	pdf, err := renderExportPDF(payload, nil)
+	if err != nil {
+		t.Fatalf("build pdf failed: %v", err)
+	}
+	content := inflatePDFStreams(t, pdf)
+	for _, want := range []string{"先攻表", "艾琳", "中毒(2轮)、倒地"} {
+		var hex strings.Builder
+		for _, r := range want {
+			hex.WriteString(fmt.Sprintf("%04X", r))
+		}
+		if !strings.Contains(content, hex.String()) {
+			t.Fatalf("pdf missing %q", want)
+		}
+	}
+}
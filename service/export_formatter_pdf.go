@@ -0,0 +1,957 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"sealchat/protocol"
+
+	htmlnode "golang.org/x/net/html"
+
+	"sealchat/model"
+)
+
+const (
+	pdfMargin        = 48.0
+	pdfFooterHeight  = 18.0
+	pdfBodyFontSize  = 10.5
+	pdfLineSpacing   = 1.45
+	pdfBlockGap      = 3.0
+	pdfMessageGap    = 8.0
+	pdfIndentStep    = 14.0
+	pdfQuoteIndent   = 10.0
+	pdfDicePadding   = 2.0
+	pdfImagePixelPt  = 0.75
+	pdfImageMaxRatio = 0.45
+)
+
+var (
+	pdfColorText    = [3]float64{0.13, 0.13, 0.16}
+	pdfColorMuted   = [3]float64{0.45, 0.45, 0.5}
+	pdfColorLink    = [3]float64{0.1, 0.35, 0.75}
+	pdfColorWhisper = [3]float64{0.45, 0.3, 0.65}
+	pdfColorDiceBg  = [3]float64{1, 0.95, 0.82}
+	pdfColorDice    = [3]float64{0.45, 0.28, 0.05}
+	pdfColorRule    = [3]float64{0.82, 0.82, 0.86}
+	pdfColorQuote   = [3]float64{0.78, 0.72, 0.55}
+)
+
+type pdfFormatter struct{}
+
+func (pdfFormatter) Ext() string {
+	return "pdf"
+}
+
+func (pdfFormatter) ContentType() string {
+	return "application/pdf"
+}
+
+func (pdfFormatter) Build(payload *ExportPayload) ([]byte, error) {
+	if payload == nil {
+		return nil, fmt.Errorf("payload 为空")
+	}
+	return renderExportPDF(payload, loadPDFPlatformFonts(payload))
+}
+
+// pdfFontSource 为需要嵌入 PDF 的平台字体原始文件。
+type pdfFontSource struct {
+	ID     string
+	Family string
+	Data   []byte
+}
+
+// loadPDFPlatformFonts 读取消息中引用的平台字体原始文件；PDF 只能嵌入 TrueType/OpenType，WOFF 系列会被跳过。
+func loadPDFPlatformFonts(payload *ExportPayload) []pdfFontSource {
+	refs := collectPayloadPlatformFontRefs(payload)
+	if len(refs) == 0 {
+		return nil
+	}
+	sources := make([]pdfFontSource, 0, len(refs))
+	for _, ref := range refs {
+		item, err := PlatformFontGet(ref.ID)
+		if err != nil || item == nil || item.Status != model.PlatformFontStatusReady {
+			continue
+		}
+		if format := detectFontFormat(item.SourceMimeType, item.SourceFileName); format == "woff" || format == "woff2" {
+			continue
+		}
+		data, _, err := readPlatformFontObject(item.OriginalStorageType, item.OriginalObjectKey, item.SourceMimeType)
+		if err != nil {
+			log.Printf("export: 读取平台字体 %s 失败: %v", ref.ID, err)
+			continue
+		}
+		family := ref.Family
+		if strings.TrimSpace(family) == "" {
+			family = item.Family
+		}
+		sources = append(sources, pdfFontSource{ID: ref.ID, Family: family, Data: data})
+	}
+	return sources
+}
+
+type pdfSpanStyle struct {
+	color  [3]float64
+	bold   bool
+	dice   bool
+	fontID string
+	scale  float64
+}
+
+type pdfSpan struct {
+	text  string
+	style pdfSpanStyle
+}
+
+type pdfBlock struct {
+	spans  []pdfSpan
+	image  string
+	alt    string
+	indent float64
+	quote  int
+}
+
+type pdfRun struct {
+	font    *pdfFont
+	text    string
+	size    float64
+	width   float64
+	padLeft float64
+	style   pdfSpanStyle
+}
+
+type pdfLine struct {
+	runs   []pdfRun
+	width  float64
+	height float64
+}
+
+type pdfRenderer struct {
+	payload  *ExportPayload
+	doc      *pdfDocument
+	base     *pdfFont
+	platform map[string]*pdfFont
+	fallback []*pdfFont
+	page     *pdfContent
+	pageNo   int
+	y        float64
+}
+
+func renderExportPDF(payload *ExportPayload, fonts []pdfFontSource) ([]byte, error) {
+	title := "频道导出 - " + payload.ChannelName
+	if payload.PartTotal > 1 {
+		title = fmt.Sprintf("%s（%d/%d）", title, payload.PartIndex, payload.PartTotal)
+	}
+	r := &pdfRenderer{
+		payload:  payload,
+		doc:      newPDFDocument(title, payload.GeneratedAt),
+		platform: make(map[string]*pdfFont),
+	}
+	r.base = r.doc.addBuiltinCJKFont()
+	for _, source := range fonts {
+		f, err := r.doc.addEmbeddedFont(source.Family, source.Data)
+		if err != nil {
+			log.Printf("export: 平台字体 %s 无法嵌入 PDF: %v", source.ID, err)
+			continue
+		}
+		r.platform[source.ID] = f
+		r.fallback = append(r.fallback, f)
+	}
+
+	r.newPage()
+	r.writeTitle(title)
+	for i := range payload.Messages {
+		r.writeMessage(&payload.Messages[i])
+	}
+	r.writeInitiative(payload.Initiative)
+	r.finishPage()
+	return r.doc.Bytes()
+}
+
+func (r *pdfRenderer) contentWidth() float64 {
+	return pdfPageWidth - pdfMargin*2
+}
+
+func (r *pdfRenderer) newPage() {
+	r.finishPage()
+	r.page = &pdfContent{}
+	r.pageNo++
+	r.y = pdfPageHeight - pdfMargin
+}
+
+func (r *pdfRenderer) finishPage() {
+	if r.page == nil {
+		return
+	}
+	footer := fmt.Sprintf("%s · 第 %d 页", r.payload.ChannelName, r.pageNo)
+	if r.payload.PartTotal > 1 {
+		footer = fmt.Sprintf("%s · 分卷 %d/%d · 第 %d 页", r.payload.ChannelName, r.payload.PartIndex, r.payload.PartTotal, r.pageNo)
+	}
+	lines := r.layoutSpans([]pdfSpan{{text: footer, style: pdfSpanStyle{color: pdfColorMuted, scale: 0.8}}}, r.contentWidth())
+	if len(lines) > 0 {
+		x := pdfMargin + (r.contentWidth()-lines[0].width)/2
+		r.drawLine(lines[0], x, pdfMargin-pdfFooterHeight/2)
+	}
+	r.doc.addPage(r.page.Bytes())
+	r.page = nil
+}
+
+// ensureSpace 剩余高度不足时换页。
+func (r *pdfRenderer) ensureSpace(height float64) {
+	if r.y-height < pdfMargin+pdfFooterHeight/2 && r.y < pdfPageHeight-pdfMargin {
+		r.newPage()
+	}
+}
+
+func (r *pdfRenderer) writeTitle(title string) {
+	r.writeBlock(pdfBlock{spans: []pdfSpan{{text: title, style: pdfSpanStyle{color: pdfColorText, bold: true, scale: 1.5}}}})
+	meta := []string{
+		fmt.Sprintf("频道：%s (%s)", r.payload.ChannelName, r.payload.ChannelID),
+		fmt.Sprintf("导出时间：%s", r.payload.GeneratedAt.Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("消息数量：%d", len(r.payload.Messages)),
+	}
+	if r.payload.PartTotal > 1 {
+		meta = append(meta, fmt.Sprintf("分卷：%d/%d", r.payload.PartIndex, r.payload.PartTotal))
+	}
+	if r.payload.SliceStart != nil && r.payload.SliceEnd != nil {
+		meta = append(meta, fmt.Sprintf("时间范围：%s ~ %s", r.payload.SliceStart.Format("2006-01-02 15:04:05"), r.payload.SliceEnd.Format("2006-01-02 15:04:05")))
+	}
+	for _, line := range meta {
+		r.writeBlock(pdfBlock{spans: []pdfSpan{{text: line, style: pdfSpanStyle{color: pdfColorMuted, scale: 0.86}}}})
+	}
+	r.y -= pdfBlockGap * 2
+	r.page.line(pdfMargin, r.y, pdfPageWidth-pdfMargin, r.y, 0.6, pdfColorRule)
+	r.y -= pdfMessageGap
+}
+
+// writeInitiative 在正文末尾输出先攻表，当前行动者加粗并以箭头标记，与其他导出格式的内容一致。
+func (r *pdfRenderer) writeInitiative(tracker *protocol.InitiativeTracker) {
+	if tracker == nil || len(tracker.Combatants) == 0 {
+		return
+	}
+	r.ensureSpace(pdfMessageGap * 3)
+	r.page.line(pdfMargin, r.y, pdfPageWidth-pdfMargin, r.y, 0.6, pdfColorRule)
+	r.y -= pdfMessageGap
+	r.writeBlock(pdfBlock{spans: []pdfSpan{{text: formatInitiativeTitle(tracker), style: pdfSpanStyle{color: pdfColorText, bold: true, scale: 1.2}}}})
+	for i, item := range tracker.Combatants {
+		current := tracker.Active && item.ID == tracker.CurrentCombatantID
+		marker := fmt.Sprintf("%d. ", i+1)
+		if current {
+			marker = "→ " + marker
+		}
+		nameColor := pdfColorText
+		if c, ok := parsePDFColor(item.Color); ok {
+			nameColor = c
+		}
+		spans := []pdfSpan{
+			{text: marker, style: pdfSpanStyle{color: pdfColorMuted}},
+			{text: item.Name, style: pdfSpanStyle{color: nameColor, bold: current}},
+			{text: "  先攻 " + formatInitiativeValue(item), style: pdfSpanStyle{color: pdfColorMuted, scale: 0.9}},
+		}
+		if conditions := formatInitiativeConditions(item.Conditions); conditions != "" {
+			spans = append(spans, pdfSpan{text: "  [" + conditions + "]", style: pdfSpanStyle{color: pdfColorDice, scale: 0.9}})
+		}
+		r.writeBlock(pdfBlock{spans: spans, indent: pdfIndentStep})
+	}
+}
+
+// writeMessage 输出单条消息：时间、发言人（按导出配色）、悄悄话/归档标记，正文另起段落。
+func (r *pdfRenderer) writeMessage(msg *ExportMessage) {
+	blocks := buildPDFContentBlocks(msg.ContentHTML)
+	if len(blocks) == 0 {
+		plain := buildFilteredPlainContent(msg.Content, r.payload.IncludeImages)
+		if strings.TrimSpace(plain) != "" {
+			blocks = []pdfBlock{{spans: []pdfSpan{{text: plain, style: pdfSpanStyle{color: pdfColorText}}}}}
+		}
+	}
+	isOOC := strings.EqualFold(strings.TrimSpace(msg.IcMode), "ooc")
+	if isOOC {
+		wrapPDFOOCBlocks(blocks)
+	}
+
+	var header []pdfSpan
+	if !r.payload.WithoutTimestamp {
+		header = append(header, pdfSpan{text: msg.CreatedAt.Format("2006-01-02 15:04:05") + "  ", style: pdfSpanStyle{color: pdfColorMuted, scale: 0.86}})
+	}
+	name := msg.SenderName
+	if override := lookupBBCodeNameOverride(r.payload, msg); override != "" {
+		name = override
+	}
+	header = append(header, pdfSpan{text: name, style: pdfSpanStyle{color: r.resolveSenderColor(msg), bold: true}})
+	if msg.IsWhisper {
+		if label := formatWhisperTargets(msg.WhisperTargets); label != "" {
+			header = append(header, pdfSpan{text: " " + label, style: pdfSpanStyle{color: pdfColorWhisper, scale: 0.9}})
+		}
+	}
+	if msg.IsArchived {
+		header = append(header, pdfSpan{text: " [已归档]", style: pdfSpanStyle{color: pdfColorMuted, scale: 0.9}})
+	}
+	if isOOC {
+		header = append(header, pdfSpan{text: " 场外", style: pdfSpanStyle{color: pdfColorMuted, scale: 0.9}})
+	}
+	r.writeBlock(pdfBlock{spans: header})
+	for _, block := range blocks {
+		if isOOC {
+			for i := range block.spans {
+				if block.spans[i].style.color == pdfColorText {
+					block.spans[i].style.color = pdfColorMuted
+				}
+			}
+		}
+		block.indent += pdfIndentStep
+		r.writeBlock(block)
+	}
+	r.y -= pdfMessageGap
+}
+
+// resolveSenderColor 优先使用频道导出配色，其次为身份颜色。
+func (r *pdfRenderer) resolveSenderColor(msg *ExportMessage) [3]float64 {
+	candidates := []string{lookupBBCodeColorOverride(r.payload, msg), msg.SenderColor}
+	for _, candidate := range candidates {
+		if color, ok := parsePDFColor(candidate); ok {
+			return color
+		}
+	}
+	return pdfColorText
+}
+
+func wrapPDFOOCBlocks(blocks []pdfBlock) {
+	first, last := -1, -1
+	for i := range blocks {
+		if len(blocks[i].spans) == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first < 0 {
+		return
+	}
+	head := strings.TrimSpace(blocks[first].spans[0].text)
+	if strings.HasPrefix(head, "（") || strings.HasPrefix(head, "(") {
+		return
+	}
+	blocks[first].spans = append([]pdfSpan{{text: "（", style: pdfSpanStyle{color: pdfColorText}}}, blocks[first].spans...)
+	blocks[last].spans = append(blocks[last].spans, pdfSpan{text: "）", style: pdfSpanStyle{color: pdfColorText}})
+}
+
+func (r *pdfRenderer) writeBlock(block pdfBlock) {
+	x := pdfMargin + block.indent + float64(block.quote)*pdfQuoteIndent
+	maxWidth := pdfPageWidth - pdfMargin - x
+	if block.image != "" {
+		if r.writeImage(block, x, maxWidth) {
+			return
+		}
+		label := "[图片]"
+		if strings.HasPrefix(strings.ToLower(block.image), "http") {
+			label = "[图片] " + block.image
+		} else if block.alt != "" {
+			label = "[图片: " + block.alt + "]"
+		}
+		block.spans = []pdfSpan{{text: label, style: pdfSpanStyle{color: pdfColorLink}}}
+	}
+	for _, line := range r.layoutSpans(block.spans, maxWidth) {
+		r.ensureSpace(line.height)
+		r.y -= line.height
+		r.drawQuoteBars(block, r.y, line.height)
+		r.drawLine(line, x, r.y+line.height*0.28)
+	}
+	r.y -= pdfBlockGap
+}
+
+func (r *pdfRenderer) drawQuoteBars(block pdfBlock, bottom, height float64) {
+	for level := 0; level < block.quote; level++ {
+		barX := pdfMargin + block.indent + float64(level)*pdfQuoteIndent + 2
+		r.page.line(barX, bottom, barX, bottom+height, 1.5, pdfColorQuote)
+	}
+}
+
+func (r *pdfRenderer) drawLine(line pdfLine, x, baseline float64) {
+	for _, run := range line.runs {
+		if run.style.dice {
+			r.page.fillRect(x, baseline-run.size*0.28, run.width, run.size*1.25, pdfColorDiceBg)
+		}
+		r.page.text(run.font, run.size, x+run.padLeft, baseline, run.text, run.style.color, run.style.bold)
+		x += run.width
+	}
+}
+
+// writeImage 内嵌 data URL / scasset 图片，按原始像素尺寸缩放到可用宽度与半页高度内。
+func (r *pdfRenderer) writeImage(block pdfBlock, x, maxWidth float64) bool {
+	src := strings.TrimSpace(block.image)
+	dataURL := src
+	if strings.HasPrefix(src, inlineAssetRefPrefix) {
+		dataURL = r.payload.InlineAssets[strings.TrimPrefix(src, inlineAssetRefPrefix)]
+	}
+	_, data, ok := decodeEPUBDataURL(dataURL)
+	if !ok {
+		return false
+	}
+	img, err := r.doc.addImage(src, data)
+	if err != nil {
+		log.Printf("export: PDF 图片内嵌失败: %v", err)
+		return false
+	}
+	width := float64(img.width) * pdfImagePixelPt
+	height := float64(img.height) * pdfImagePixelPt
+	maxHeight := (pdfPageHeight - pdfMargin*2) * pdfImageMaxRatio
+	scale := 1.0
+	if width > maxWidth {
+		scale = maxWidth / width
+	}
+	if height*scale > maxHeight {
+		scale = maxHeight / height
+	}
+	width *= scale
+	height *= scale
+	r.ensureSpace(height)
+	r.y -= height
+	r.drawQuoteBars(block, r.y, height)
+	r.page.image(img, x, r.y, width, height)
+	r.y -= pdfBlockGap
+	return true
+}
+
+// resolveFont 为字符选择字体：优先使用指定的平台字体，缺字时回退到内置 CJK 字体或其他已嵌入字体。
+func (r *pdfRenderer) resolveFont(fontID string, ch rune) (*pdfFont, rune) {
+	if f, ok := r.platform[fontID]; ok && f.hasGlyph(ch) {
+		return f, ch
+	}
+	if r.base.hasGlyph(ch) {
+		return r.base, ch
+	}
+	for _, f := range r.fallback {
+		if f.hasGlyph(ch) {
+			return f, ch
+		}
+	}
+	return r.base, '□'
+}
+
+type pdfToken struct {
+	text    string
+	style   pdfSpanStyle
+	newline bool
+	space   bool
+}
+
+// tokenizePDFSpans 将文本拆分为可换行单元：连续 ASCII 字符视作单词，其余字符（CJK 等）逐字可断；骰子胶囊整体不拆分。
+func tokenizePDFSpans(spans []pdfSpan) []pdfToken {
+	var tokens []pdfToken
+	for _, span := range spans {
+		if span.style.dice {
+			tokens = append(tokens, pdfToken{text: span.text, style: span.style})
+			continue
+		}
+		var word strings.Builder
+		flushWord := func() {
+			if word.Len() > 0 {
+				tokens = append(tokens, pdfToken{text: word.String(), style: span.style})
+				word.Reset()
+			}
+		}
+		for _, ch := range span.text {
+			switch {
+			case ch == '\n':
+				flushWord()
+				tokens = append(tokens, pdfToken{newline: true, style: span.style})
+			case unicode.IsSpace(ch):
+				flushWord()
+				if n := len(tokens); n > 0 && tokens[n-1].space {
+					continue
+				}
+				tokens = append(tokens, pdfToken{text: " ", space: true, style: span.style})
+			case ch < 0x80:
+				word.WriteRune(ch)
+			default:
+				flushWord()
+				tokens = append(tokens, pdfToken{text: string(ch), style: span.style})
+			}
+		}
+		flushWord()
+	}
+	return tokens
+}
+
+func (r *pdfRenderer) spanSize(style pdfSpanStyle) float64 {
+	if style.scale <= 0 {
+		return pdfBodyFontSize
+	}
+	return pdfBodyFontSize * style.scale
+}
+
+func (r *pdfRenderer) measureToken(tok pdfToken) float64 {
+	size := r.spanSize(tok.style)
+	width := 0.0
+	for _, ch := range tok.text {
+		f, mapped := r.resolveFont(tok.style.fontID, ch)
+		width += f.runeWidth(mapped) * size / 1000
+	}
+	if tok.style.dice {
+		width += pdfDicePadding * 2
+	}
+	return width
+}
+
+// layoutSpans 按可用宽度贪心断行，硬换行保留为空行。
+func (r *pdfRenderer) layoutSpans(spans []pdfSpan, maxWidth float64) []pdfLine {
+	var lines []pdfLine
+	current := pdfLine{}
+	push := func() {
+		if current.height == 0 {
+			current.height = pdfBodyFontSize * pdfLineSpacing
+		}
+		if n := len(current.runs); n > 0 && !current.runs[n-1].style.dice {
+			last := &current.runs[n-1]
+			trimmed := strings.TrimRight(last.text, " ")
+			if trimmed != last.text {
+				f := last.font
+				last.width -= f.textWidth(last.text[len(trimmed):], last.size)
+				current.width -= f.textWidth(last.text[len(trimmed):], last.size)
+				last.text = trimmed
+			}
+		}
+		lines = append(lines, current)
+		current = pdfLine{}
+	}
+	for _, tok := range tokenizePDFSpans(spans) {
+		if tok.newline {
+			push()
+			continue
+		}
+		width := r.measureToken(tok)
+		if tok.space {
+			if len(current.runs) == 0 {
+				continue
+			}
+			if current.width+width > maxWidth {
+				push()
+				continue
+			}
+		} else if current.width+width > maxWidth && len(current.runs) > 0 {
+			push()
+		}
+		if width > maxWidth && !tok.style.dice {
+			for _, ch := range tok.text {
+				part := pdfToken{text: string(ch), style: tok.style}
+				partWidth := r.measureToken(part)
+				if current.width+partWidth > maxWidth && len(current.runs) > 0 {
+					push()
+				}
+				r.appendToken(&current, part)
+			}
+			continue
+		}
+		r.appendToken(&current, tok)
+	}
+	if len(current.runs) > 0 {
+		push()
+	}
+	return lines
+}
+
+func (r *pdfRenderer) appendToken(line *pdfLine, tok pdfToken) {
+	size := r.spanSize(tok.style)
+	if height := size * pdfLineSpacing; height > line.height {
+		line.height = height
+	}
+	first := len(line.runs)
+	for _, ch := range tok.text {
+		f, mapped := r.resolveFont(tok.style.fontID, ch)
+		width := f.runeWidth(mapped) * size / 1000
+		line.width += width
+		if n := len(line.runs); n > first || (n > 0 && !tok.style.dice) {
+			last := &line.runs[n-1]
+			if last.font == f && last.size == size && last.style == tok.style {
+				last.text += string(mapped)
+				last.width += width
+				continue
+			}
+		}
+		line.runs = append(line.runs, pdfRun{font: f, text: string(mapped), size: size, width: width, style: tok.style})
+	}
+	// 骰子胶囊两侧留白，内部因字体切换拆分的片段共用连续背景
+	if tok.style.dice && len(line.runs) > first {
+		line.runs[first].padLeft = pdfDicePadding
+		line.runs[first].width += pdfDicePadding
+		line.runs[len(line.runs)-1].width += pdfDicePadding
+		line.width += pdfDicePadding * 2
+	}
+}
+
+// pdfBlockBuilder 将导出 HTML（TipTap 渲染结果、骰子胶囊、便签）展开为段落块。
+type pdfBlockBuilder struct {
+	blocks  []pdfBlock
+	current []pdfSpan
+	indent  float64
+	quote   int
+}
+
+func buildPDFContentBlocks(content string) []pdfBlock {
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+	nodes, err := htmlnode.ParseFragment(strings.NewReader(content), nil)
+	if err != nil {
+		return nil
+	}
+	b := &pdfBlockBuilder{}
+	style := pdfSpanStyle{color: pdfColorText}
+	for _, node := range nodes {
+		b.walk(node, style, false)
+	}
+	b.flush()
+	return b.blocks
+}
+
+func (b *pdfBlockBuilder) flush() {
+	spans := b.current
+	b.current = nil
+	for len(spans) > 0 && !spans[0].style.dice && strings.TrimSpace(spans[0].text) == "" && !strings.Contains(spans[0].text, "\n") {
+		spans = spans[1:]
+	}
+	for len(spans) > 0 {
+		last := &spans[len(spans)-1]
+		trimmed := strings.TrimRight(last.text, " \n")
+		if trimmed == last.text || last.style.dice {
+			break
+		}
+		if trimmed == "" {
+			spans = spans[:len(spans)-1]
+			continue
+		}
+		last.text = trimmed
+		break
+	}
+	if len(spans) == 0 {
+		return
+	}
+	spans[0].text = strings.TrimLeft(spans[0].text, " ")
+	b.blocks = append(b.blocks, pdfBlock{spans: spans, indent: b.indent, quote: b.quote})
+}
+
+func (b *pdfBlockBuilder) write(text string, style pdfSpanStyle) {
+	if text == "" {
+		return
+	}
+	b.current = append(b.current, pdfSpan{text: text, style: style})
+}
+
+func (b *pdfBlockBuilder) walkChildren(node *htmlnode.Node, style pdfSpanStyle, pre bool) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		b.walk(child, style, pre)
+	}
+}
+
+func (b *pdfBlockBuilder) walkBlock(node *htmlnode.Node, style pdfSpanStyle, pre bool) {
+	b.flush()
+	b.walkChildren(node, style, pre)
+	b.flush()
+}
+
+func (b *pdfBlockBuilder) walk(node *htmlnode.Node, style pdfSpanStyle, pre bool) {
+	switch node.Type {
+	case htmlnode.TextNode:
+		text := strings.ReplaceAll(node.Data, "\u00a0", " ")
+		if !pre {
+			text = strings.Join(strings.Fields(text), " ")
+			if strings.TrimSpace(node.Data) != "" {
+				if unicode.IsSpace(rune(node.Data[0])) {
+					text = " " + text
+				}
+				if unicode.IsSpace(rune(node.Data[len(node.Data)-1])) {
+					text += " "
+				}
+			} else if node.Data != "" {
+				text = " "
+			}
+		}
+		b.write(text, style)
+		return
+	case htmlnode.ElementNode:
+	default:
+		b.walkChildren(node, style, pre)
+		return
+	}
+
+	className := htmlNodeAttr(node, "class")
+	if color, ok := parsePDFStyleColor(htmlNodeAttr(node, "style")); ok {
+		style.color = color
+	}
+	if fontID := strings.TrimSpace(htmlNodeAttr(node, "data-platform-font-id")); fontID != "" {
+		style.fontID = fontID
+	}
+	tag := strings.ToLower(node.Data)
+	switch tag {
+	case "script", "style", "rp", "template":
+		return
+	case "br":
+		b.write("\n", style)
+	case "img":
+		b.flush()
+		b.blocks = append(b.blocks, pdfBlock{
+			image:  htmlNodeAttr(node, "src"),
+			alt:    strings.TrimSpace(htmlNodeAttr(node, "alt")),
+			indent: b.indent,
+			quote:  b.quote,
+		})
+	case "strong", "b", "th":
+		style.bold = true
+		b.walkChildren(node, style, pre)
+	case "a":
+		if style.color == pdfColorText {
+			style.color = pdfColorLink
+		}
+		b.walkChildren(node, style, pre)
+	case "rt":
+		if text := strings.TrimSpace(htmlNodeText(node)); text != "" {
+			b.write("（"+text+"）", style)
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		style.bold = true
+		style.scale = 1.3 - float64(tag[1]-'1')*0.05
+		b.walkBlock(node, style, pre)
+	case "pre":
+		b.walkBlock(node, style, true)
+	case "blockquote":
+		b.flush()
+		b.quote++
+		b.walkChildren(node, style, pre)
+		b.flush()
+		b.quote--
+	case "ul", "ol":
+		b.writeList(node, style, pre, tag == "ol")
+	case "hr":
+		b.flush()
+		b.write("──────", pdfSpanStyle{color: pdfColorRule})
+		b.flush()
+	case "p", "div", "header", "footer", "article", "li", "tr", "table", "h", "figure", "figcaption":
+		b.walkBlock(node, style, pre)
+	case "section":
+		if htmlNodeHasClass(className, "export-sticky-note") {
+			b.writeStickyNote(node, style)
+			return
+		}
+		b.walkBlock(node, style, pre)
+	case "td":
+		b.walkChildren(node, style, pre)
+		b.write(" ", style)
+	default:
+		if htmlNodeHasClass(className, "dice-chip") {
+			b.writeDiceChip(node, className, style)
+			return
+		}
+		b.walkChildren(node, style, pre)
+	}
+}
+
+func (b *pdfBlockBuilder) writeList(node *htmlnode.Node, style pdfSpanStyle, pre bool, ordered bool) {
+	b.flush()
+	b.indent += pdfIndentStep
+	index := 0
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != htmlnode.ElementNode || !strings.EqualFold(child.Data, "li") {
+			continue
+		}
+		index++
+		marker := "• "
+		if ordered {
+			marker = strconv.Itoa(index) + ". "
+		}
+		b.flush()
+		b.write(marker, style)
+		b.walkChildren(child, style, pre)
+		b.flush()
+	}
+	b.indent -= pdfIndentStep
+}
+
+// writeDiceChip 与 HTML 导出的骰子胶囊一致，渲染为带底色的「公式=结果」。
+func (b *pdfBlockBuilder) writeDiceChip(node *htmlnode.Node, className string, style pdfSpanStyle) {
+	formula := strings.TrimSpace(htmlNodeTextByClass(node, "dice-chip__formula"))
+	result := strings.TrimSpace(htmlNodeTextByClass(node, "dice-chip__result"))
+	if formula == "" && result == "" {
+		formula = strings.TrimSpace(htmlNodeAttr(node, "data-dice-formula"))
+		result = strings.TrimSpace(htmlNodeAttr(node, "data-dice-result-value"))
+	}
+	if formula == "" && result == "" {
+		return
+	}
+	text := formula
+	if htmlNodeHasClass(className, "dice-chip--error") {
+		text += " " + result
+	} else if result != "" {
+		text += "=" + result
+	}
+	if outcome := strings.TrimSpace(htmlNodeTextByClass(node, "dice-chip__outcome")); outcome != "" {
+		text += " " + outcome
+	}
+	style.dice = true
+	style.bold = true
+	style.color = pdfColorDice
+	b.write(strings.TrimSpace(text), style)
+}
+
+// writeStickyNote 便签以引用块呈现，标题行与纯文本导出的 [便签: 标题] 对应。
+func (b *pdfBlockBuilder) writeStickyNote(node *htmlnode.Node, style pdfSpanStyle) {
+	title := strings.TrimSpace(htmlNodeTextByClass(node, "export-sticky-note__title"))
+	if title == "" {
+		title = "未命名便签"
+	}
+	b.flush()
+	b.quote++
+	headerStyle := style
+	headerStyle.bold = true
+	b.write("[便签: "+title+"]", headerStyle)
+	if label := strings.TrimSpace(htmlNodeTextByClass(node, "export-sticky-note__type")); label != "" {
+		b.write(" "+label, pdfSpanStyle{color: pdfColorMuted, scale: 0.9})
+	}
+	b.flush()
+	before := len(b.blocks)
+	if body := htmlNodeFindByClass(node, "export-sticky-note__body"); body != nil {
+		b.walkChildren(body, style, false)
+		b.flush()
+	}
+	if len(b.blocks) == before {
+		b.write("（空便签）", pdfSpanStyle{color: pdfColorMuted})
+		b.flush()
+	}
+	b.quote--
+}
+
+func parsePDFStyleColor(styleAttr string) ([3]float64, bool) {
+	for _, decl := range strings.Split(styleAttr, ";") {
+		key, value, found := strings.Cut(decl, ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "color") {
+			continue
+		}
+		return parsePDFColor(value)
+	}
+	return [3]float64{}, false
+}
+
+// parsePDFColor 支持 #rgb / #rrggbb 与 rgb()/rgba() 写法。
+func parsePDFColor(input string) ([3]float64, bool) {
+	value := strings.ToLower(strings.TrimSpace(input))
+	if strings.HasPrefix(value, "#") {
+		normalized := sanitizeBBCodeColor(value, "")
+		if normalized == "" {
+			return [3]float64{}, false
+		}
+		var rgb [3]float64
+		for i := 0; i < 3; i++ {
+			channel, err := strconv.ParseUint(normalized[1+i*2:3+i*2], 16, 8)
+			if err != nil {
+				return [3]float64{}, false
+			}
+			rgb[i] = float64(channel) / 255
+		}
+		return rgb, true
+	}
+	if strings.HasPrefix(value, "rgb(") || strings.HasPrefix(value, "rgba(") {
+		inner := value[strings.Index(value, "(")+1:]
+		inner = strings.TrimSuffix(strings.TrimSpace(inner), ")")
+		parts := strings.Split(inner, ",")
+		if len(parts) < 3 {
+			return [3]float64{}, false
+		}
+		var rgb [3]float64
+		for i := 0; i < 3; i++ {
+			channel, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+			if err != nil || channel < 0 || channel > 255 {
+				return [3]float64{}, false
+			}
+			rgb[i] = channel / 255
+		}
+		return rgb, true
+	}
+	return [3]float64{}, false
+}
+
+// processPDFExportJob 按切片上限拆分 PDF：单卷直接输出 .pdf，多卷打包为 ZIP，分卷序号与 HTML 导出一致。
+func processPDFExportJob(
+	job *model.MessageExportJobModel,
+	channelName string,
+	messages []*model.MessageModel,
+	cfg MessageExportWorkerConfig,
+	extra *exportExtraOptions,
+) error {
+	if extra == nil {
+		extra = parseExportExtraOptions("")
+	}
+	chunks := splitMessagesForViewer(messages, extra.SliceLimit)
+	defer releaseViewerChunks(chunks)
+	generatedAt := time.Now()
+	embedder := newInlineImageEmbedder()
+	formatter := pdfFormatter{}
+	results := make([]partRenderResult, len(chunks))
+	defer releasePartResults(results)
+
+	for index, chunk := range chunks {
+		ctx := &payloadContext{
+			DisplayOptions: extra.DisplaySettings,
+			GeneratedAt:    &generatedAt,
+		}
+		if len(chunks) > 1 {
+			ctx.PartIndex = index + 1
+			ctx.PartTotal = len(chunks)
+			ctx.SliceStart, ctx.SliceEnd = sliceBounds(chunk)
+		}
+		payload := buildExportPayload(job, channelName, chunk, ctx, extra)
+		applyExportColorizeMeta(payload, extra)
+		if payload.IncludeImages {
+			embedder.inlinePayload(payload)
+		}
+		data, err := formatter.Build(payload)
+		if err != nil {
+			return fmt.Errorf("生成 PDF 失败: %w", err)
+		}
+		results[index] = partRenderResult{
+			fileName: fmt.Sprintf("part-%03d.pdf", index+1),
+			content:  data,
+			meta: viewerManifestPart{
+				PartIndex: index + 1,
+				PartTotal: len(chunks),
+				Messages:  len(payload.Messages),
+			},
+		}
+	}
+
+	if err := os.MkdirAll(cfg.StorageDir, 0755); err != nil {
+		return fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	if len(results) == 1 {
+		fileName := BuildExportResultFileName(job.DisplayName, job.ID, formatter.Ext(), generatedAt)
+		filePath := filepath.Join(cfg.StorageDir, fmt.Sprintf("%s.%s", job.ID, formatter.Ext()))
+		if err := os.WriteFile(filePath, results[0].content, 0644); err != nil {
+			return err
+		}
+		return markJobDone(job, filePath, fileName)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, result := range results {
+		if err := writeZipEntry(zw, result.fileName, result.content); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("写入 ZIP 失败: %w", err)
+	}
+	fileName := BuildExportResultFileName(job.DisplayName, job.ID, "zip", generatedAt)
+	filePath := filepath.Join(cfg.StorageDir, fmt.Sprintf("%s.zip", job.ID))
+	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return markJobDone(job, filePath, fileName)
+}
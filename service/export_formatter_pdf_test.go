@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+var pdfStreamPattern = regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`)
+
+func inflatePDFStreams(t *testing.T, data []byte) string {
+	t.Helper()
+	var sb strings.Builder
+	for _, match := range pdfStreamPattern.FindAllSubmatch(data, -1) {
+		reader, err := zlib.NewReader(bytes.NewReader(match[1]))
+		if err != nil {
+			continue
+		}
+		content, _ := io.ReadAll(reader)
+		sb.Write(content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func TestPDFFormatterRendersMessagesImagesAndFonts(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.NRGBA{R: 200, A: 128})
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("encode png failed: %v", err)
+	}
+	pngDataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngBuf.Bytes())
+	now := time.Unix(1700005000, 0)
+	payload := &ExportPayload{
+		ChannelID:    "ch-pdf",
+		ChannelName:  "跑团记录",
+		GeneratedAt:  now,
+		PartIndex:    2,
+		PartTotal:    3,
+		InlineAssets: map[string]string{"asset1": pngDataURL},
+		ExtraMeta: map[string]interface{}{
+			"text_colorize_bbcode_map":      map[string]string{"identity:kp": "#ff0000"},
+			"text_colorize_bbcode_name_map": map[string]string{"identity:kp": "守秘人"},
+		},
+		Messages: []ExportMessage{
+			{
+				SenderName:       "KP",
+				SenderIdentityID: "kp",
+				SenderColor:      "#00ff00",
+				IcMode:           "ic",
+				CreatedAt:        now,
+				ContentHTML:      `<p>检定 <span class="dice-chip"><span class="dice-chip__formula">d100</span><span class="dice-chip__result">42</span></span></p><p><img src="scasset:asset1"></p>`,
+			},
+			{
+				SenderName:  "玩家",
+				IcMode:      "ooc",
+				CreatedAt:   now,
+				ContentHTML: `<p><span data-platform-font-id="go" style="color: rgb(0, 0, 255)">Go font text</span></p>`,
+			},
+			{
+				SenderName:       "KP",
+				SenderIdentityID: "kp",
+				IcMode:           "ic",
+				CreatedAt:        now,
+				ContentHTML:      `<section class="export-sticky-note"><header><span class="export-sticky-note__title">线索</span></header><div class="export-sticky-note__body"><ul><li>钥匙</li></ul></div></section>`,
+			},
+		},
+	}
+	for i := 0; i < 120; i++ {
+		payload.Messages = append(payload.Messages, ExportMessage{
+			SenderName: "玩家",
+			IcMode:     "ic",
+			CreatedAt:  now,
+			Content:    strings.Repeat("很长的一段发言 ", 12),
+		})
+	}
+
+	data, err := renderExportPDF(payload, []pdfFontSource{{ID: "go", Family: "Go Regular", Data: goregular.TTF}})
+	if err != nil {
+		t.Fatalf("render pdf failed: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.7")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("unexpected pdf envelope")
+	}
+
+	// 校验 xref 中每个偏移都指向对应对象
+	startIdx := bytes.LastIndex(data, []byte("startxref\n"))
+	xrefOffset, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(string(data[startIdx+len("startxref\n"):]), "\n", 2)[0]))
+	if err != nil || !bytes.HasPrefix(data[xrefOffset:], []byte("xref\n")) {
+		t.Fatalf("invalid startxref")
+	}
+	xrefLines := strings.Split(string(data[xrefOffset:]), "\n")
+	var count int
+	fmt.Sscanf(xrefLines[1], "0 %d", &count)
+	for i := 1; i < count; i++ {
+		offset, _ := strconv.Atoi(xrefLines[2+i][:10])
+		if !bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i))) {
+			t.Fatalf("xref entry %d points to wrong offset", i)
+		}
+	}
+
+	raw := string(data)
+	for _, expected := range []string{"/Subtype /Image", "/SMask", "/FontFile2", "/BaseFont /GoRegular", "/Encoding /UniGB-UCS2-H", "/ToUnicode"} {
+		if !strings.Contains(raw, expected) {
+			t.Fatalf("expected %q in pdf", expected)
+		}
+	}
+	if pages := regexp.MustCompile(`/Count (\d+)`).FindStringSubmatch(raw); pages == nil || pages[1] == "1" {
+		t.Fatalf("expected long export to span multiple pages")
+	}
+
+	content := inflatePDFStreams(t, data)
+	expects := []string{
+		"1.000 0.000 0.000 rg",           // 导出配色覆盖身份颜色
+		"0.000 0.000 1.000 rg",           // 行内颜色
+		pdfColor(pdfColorDiceBg) + " rg", // 骰子胶囊底色
+		"/Im1 Do",
+		"<" + fmt.Sprintf("%04X%04X%04X", '守', '秘', '人') + ">",
+		fmt.Sprintf("%04X%04X", 'd', '1'),
+	}
+	for _, expected := range expects {
+		if !strings.Contains(content, expected) {
+			t.Fatalf("expected %q in content streams", expected)
+		}
+	}
+	if strings.Contains(content, fmt.Sprintf("%04X%04X", 'K', 'P')) {
+		t.Fatalf("expected sender name override to replace original name")
+	}
+}
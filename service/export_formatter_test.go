@@ -650,3 +650,40 @@ func TestBuildExportPayloadMarksMergedMessages(t *testing.T) {
 		t.Fatalf("expected merged export message flag, got %+v", payload.Messages[0])
 	}
 }
+
+func TestBuildExportPayloadRendersPoll(t *testing.T) {
+	job := &model.MessageExportJobModel{
+		ChannelID: "channel-export-poll",
+	}
+	widgetData := marshalStateWidgetEntries([]StateWidgetEntry{newPollWidgetEntry(&PollWidgetState{
+		Question: "下次跑团时间",
+		Options: []PollOptionState{
+			{Text: "周五", Count: 3, Voters: []PollVoter{{UserID: "u1", Name: "Alice"}}},
+			{Text: "周六", Count: 1},
+		},
+		Closed:      true,
+		TotalVoters: 4,
+	})})
+	msg := &model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "msg-poll", CreatedAt: time.Unix(1700000400, 0)},
+		UserID:            "user-poll",
+		Content:           "下次跑团时间",
+		ICMode:            "ooc",
+		WidgetData:        widgetData,
+	}
+
+	payload := buildExportPayload(job, "测试频道", []*model.MessageModel{msg}, nil, nil)
+	if payload == nil || len(payload.Messages) != 1 {
+		t.Fatalf("expected 1 export message, got %+v", payload)
+	}
+	exported := payload.Messages[0]
+	if !strings.Contains(exported.Content, "[投票] 下次跑团时间（单选 · 实名 · 已结束）") ||
+		!strings.Contains(exported.Content, "1. 周五 — 3 票 (75%)：Alice") ||
+		!strings.Contains(exported.Content, "共 4 人参与") {
+		t.Fatalf("unexpected plain poll export: %q", exported.Content)
+	}
+	if !strings.Contains(exported.ContentHTML, `class="export-poll"`) ||
+		!strings.Contains(exported.ContentHTML, `style="width: 25%"`) {
+		t.Fatalf("unexpected html poll export: %q", exported.ContentHTML)
+	}
+}
@@ -34,6 +34,9 @@ var supportedExportFormats = map[string]struct{}{
 	"json": {},
 	"txt":  {},
 	"html": {},
+	"md":   {},
+	"epub": {},
+	"pdf":  {},
 }
 
 // ExportJobOptions 聚合创建导出任务所需的信息。
@@ -46,6 +49,8 @@ type ExportJobOptions struct {
 	IncludeArchived           bool
 	IncludeImages             bool
 	IncludeDiceCommand        bool
+	IncludeThreadReplies      bool
+	IncludeRoundTrip          bool
 	WithoutTimestamp          bool
 	MergeMessages             bool
 	StartTime                 *time.Time
@@ -67,6 +72,8 @@ type exportExtraOptions struct {
 	TextColorizeBBCodeNameMap map[string]string `json:"text_colorize_bbcode_name_map,omitempty"`
 	IncludeImages             bool              `json:"include_images"`
 	IncludeDiceCommand        bool              `json:"include_dice_commands"`
+	IncludeThreadReplies      bool              `json:"include_thread_replies,omitempty"`
+	IncludeRoundTrip          bool              `json:"include_round_trip,omitempty"`
 }
 
 func normalizeExportFormat(format string) (string, bool) {
@@ -152,6 +159,10 @@ func loadMessagesForExport(job *model.MessageExportJobModel) ([]*model.MessageMo
 	if !job.IncludeOOC && !job.MergeMessages {
 		query = query.Where("COALESCE(ic_mode, 'ic') != ?", "ooc")
 	}
+	extra := parseExportExtraOptions(job.ExtraOptions)
+	if !extra.IncludeThreadReplies {
+		query = query.Where("thread_id = ?", "")
+	}
 
 	query = query.Order("display_order asc").Order("created_at asc").Limit(messageExportLimit)
 
@@ -162,7 +173,6 @@ func loadMessagesForExport(job *model.MessageExportJobModel) ([]*model.MessageMo
 	if err := hydrateWhisperTargetsForExport(messages); err != nil {
 		return nil, err
 	}
-	extra := parseExportExtraOptions(job.ExtraOptions)
 	if job.MergeMessages {
 		return mergeSequentialMessagesForExport(messages, extra, job.IncludeOOC), nil
 	}
@@ -554,6 +564,8 @@ func buildExportExtraOptions(opts *ExportJobOptions) (string, error) {
 		TextColorizeBBCodeNameMap: cloneStringMap(opts.TextColorizeBBCodeNameMap),
 		IncludeImages:             opts.IncludeImages,
 		IncludeDiceCommand:        opts.IncludeDiceCommand,
+		IncludeThreadReplies:      opts.IncludeThreadReplies,
+		IncludeRoundTrip:          opts.IncludeRoundTrip,
 	}
 	if len(opts.DisplaySettings) > 0 {
 		extra.DisplaySettings = opts.DisplaySettings
@@ -565,7 +577,9 @@ func buildExportExtraOptions(opts *ExportJobOptions) (string, error) {
 		len(extra.TextColorizeBBCodeMap) == 0 &&
 		len(extra.TextColorizeBBCodeNameMap) == 0 &&
 		extra.IncludeImages &&
-		extra.IncludeDiceCommand {
+		extra.IncludeDiceCommand &&
+		!extra.IncludeThreadReplies &&
+		!extra.IncludeRoundTrip {
 		return "", nil
 	}
 	data, err := json.Marshal(extra)
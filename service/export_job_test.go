@@ -364,3 +364,52 @@ func createExportTestUser(t *testing.T, id, username, nickname string) *model.Us
 	}
 	return user
 }
+
+func TestLoadMessagesForExportExcludesThreadRepliesByDefault(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	channelID := "ch-export-thread-" + suffix
+	sender := createExportTestUser(t, "u-export-thread-"+suffix, "export_thread_"+suffix, "话题发送者")
+	now := time.Now()
+	rootID := "msg-export-thread-root-" + suffix
+	replyID := "msg-export-thread-reply-" + suffix
+	for i, msg := range []*model.MessageModel{
+		{StringPKBaseModel: model.StringPKBaseModel{ID: rootID}, Content: "主时间线消息"},
+		{StringPKBaseModel: model.StringPKBaseModel{ID: replyID}, Content: "话题回复", ThreadID: rootID},
+	} {
+		at := now.Add(time.Duration(i) * time.Second)
+		msg.CreatedAt = at
+		msg.UpdatedAt = at
+		msg.ChannelID = channelID
+		msg.UserID = sender.ID
+		msg.DisplayOrder = float64(at.UnixMilli())
+		msg.ICMode = "ic"
+		if err := db.Create(msg).Error; err != nil {
+			t.Fatalf("create message failed: %v", err)
+		}
+	}
+
+	job := &model.MessageExportJobModel{ChannelID: channelID, IncludeOOC: true}
+	messages, err := loadMessagesForExport(job)
+	if err != nil {
+		t.Fatalf("loadMessagesForExport failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != rootID {
+		t.Fatalf("thread replies should be excluded by default, got %d messages", len(messages))
+	}
+
+	extra, err := buildExportExtraOptions(&ExportJobOptions{IncludeImages: true, IncludeDiceCommand: true, IncludeThreadReplies: true})
+	if err != nil {
+		t.Fatalf("build extra options failed: %v", err)
+	}
+	job.ExtraOptions = extra
+	messages, err = loadMessagesForExport(job)
+	if err != nil {
+		t.Fatalf("loadMessagesForExport failed: %v", err)
+	}
+	if len(messages) != 2 || messages[1].ID != replyID {
+		t.Fatalf("thread replies should be included on request, got %d messages", len(messages))
+	}
+}
@@ -0,0 +1,504 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// 纯 Go 的最小 PDF 写入器，仅覆盖聊天记录导出需要的能力：
+// 多页文本、CJK 字体（内置 STSong-Light 或嵌入平台字体）、矩形/线条与 JPEG/PNG/GIF/WebP 图片。
+
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+)
+
+type pdfDocument struct {
+	objects     [][]byte
+	pagesID     int
+	resourcesID int
+	pageIDs     []int
+	fonts       []*pdfFont
+	images      map[string]*pdfImage
+	imageOrder  []*pdfImage
+	title       string
+	createdAt   time.Time
+}
+
+func newPDFDocument(title string, createdAt time.Time) *pdfDocument {
+	doc := &pdfDocument{
+		images:    make(map[string]*pdfImage),
+		title:     title,
+		createdAt: createdAt,
+	}
+	doc.pagesID = doc.reserveObject()
+	doc.resourcesID = doc.reserveObject()
+	return doc
+}
+
+func (d *pdfDocument) reserveObject() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+func (d *pdfDocument) setObject(id int, body string) {
+	d.objects[id-1] = []byte(body)
+}
+
+func (d *pdfDocument) addObject(body string) int {
+	id := d.reserveObject()
+	d.setObject(id, body)
+	return id
+}
+
+// addStream 写入流对象，compress 为 true 时使用 FlateDecode 压缩。
+func (d *pdfDocument) addStream(dict string, data []byte, compress bool) int {
+	if compress {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		data = buf.Bytes()
+		dict += " /Filter /FlateDecode"
+	}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<< %s /Length %d >>\nstream\n", strings.TrimSpace(dict), len(data))
+	body.Write(data)
+	body.WriteString("\nendstream")
+	id := d.reserveObject()
+	d.objects[id-1] = body.Bytes()
+	return id
+}
+
+func (d *pdfDocument) addPage(content []byte) {
+	contentID := d.addStream("", content, true)
+	pageID := d.addObject(fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+		d.pagesID, pdfNum(pdfPageWidth), pdfNum(pdfPageHeight), d.resourcesID, contentID,
+	))
+	d.pageIDs = append(d.pageIDs, pageID)
+}
+
+// Bytes 写出字体、图片等延迟对象并生成完整文件。
+func (d *pdfDocument) Bytes() ([]byte, error) {
+	if len(d.pageIDs) == 0 {
+		d.addPage(nil)
+	}
+	var fontRefs strings.Builder
+	for _, f := range d.fonts {
+		if err := f.write(d); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&fontRefs, " /%s %d 0 R", f.name, f.objectID)
+	}
+	var imageRefs strings.Builder
+	for _, img := range d.imageOrder {
+		fmt.Fprintf(&imageRefs, " /%s %d 0 R", img.name, img.objectID)
+	}
+	d.setObject(d.resourcesID, fmt.Sprintf("<< /ProcSet [/PDF /Text /ImageB /ImageC] /Font <<%s >> /XObject <<%s >> >>", fontRefs.String(), imageRefs.String()))
+
+	kids := make([]string, len(d.pageIDs))
+	for i, id := range d.pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	d.setObject(d.pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pageIDs)))
+	catalogID := d.addObject(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", d.pagesID))
+	infoID := d.addObject(fmt.Sprintf("<< /Title %s /Producer (SealChat) /CreationDate (D:%s) >>",
+		pdfTextString(d.title), d.createdAt.Format("20060102150405")))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		if body == nil {
+			return nil, fmt.Errorf("PDF 对象 %d 未写入", i+1)
+		}
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+	xrefOffset := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objects)+1, catalogID, infoID, xrefOffset)
+	return out.Bytes(), nil
+}
+
+// pdfFont 为 Type0 复合字体：builtin 使用阅读器内置的 STSong-Light（UCS-2 编码），
+// 否则嵌入 TrueType/OpenType 字体文件并以字形 ID（Identity-H）编码。
+type pdfFont struct {
+	name     string
+	objectID int
+	builtin  bool
+
+	baseName string
+	data     []byte
+	cff      bool
+	parsed   *sfnt.Font
+	buf      sfnt.Buffer
+	glyphs   map[rune]uint16
+	widths   map[uint16]int
+	used     map[uint16]rune
+}
+
+func (d *pdfDocument) addBuiltinCJKFont() *pdfFont {
+	f := &pdfFont{
+		name:     fmt.Sprintf("F%d", len(d.fonts)+1),
+		objectID: d.reserveObject(),
+		builtin:  true,
+		baseName: "STSong-Light",
+	}
+	d.fonts = append(d.fonts, f)
+	return f
+}
+
+// addEmbeddedFont 解析并登记平台字体；WOFF/WOFF2 等无法直接嵌入的格式返回错误。
+func (d *pdfDocument) addEmbeddedFont(family string, data []byte) (*pdfFont, error) {
+	parsed, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析字体失败: %w", err)
+	}
+	f := &pdfFont{
+		name:     fmt.Sprintf("F%d", len(d.fonts)+1),
+		objectID: d.reserveObject(),
+		baseName: pdfFontBaseName(family, len(d.fonts)+1),
+		data:     data,
+		cff:      bytes.HasPrefix(data, []byte("OTTO")),
+		parsed:   parsed,
+		glyphs:   make(map[rune]uint16),
+		widths:   make(map[uint16]int),
+		used:     make(map[uint16]rune),
+	}
+	d.fonts = append(d.fonts, f)
+	return f, nil
+}
+
+func pdfFontBaseName(family string, index int) string {
+	var sb strings.Builder
+	for _, r := range family {
+		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return fmt.Sprintf("SealChatFont%d", index)
+	}
+	return sb.String()
+}
+
+// hasGlyph 判断字体能否绘制该字符。
+func (f *pdfFont) hasGlyph(r rune) bool {
+	if f.builtin {
+		return r >= 0x20 && r <= 0xFFFF && (r < 0xD800 || r > 0xDFFF)
+	}
+	_, ok := f.glyphIndex(r)
+	return ok
+}
+
+func (f *pdfFont) glyphIndex(r rune) (uint16, bool) {
+	if gid, ok := f.glyphs[r]; ok {
+		return gid, gid != 0
+	}
+	idx, err := f.parsed.GlyphIndex(&f.buf, r)
+	gid := uint16(0)
+	if err == nil {
+		gid = uint16(idx)
+	}
+	f.glyphs[r] = gid
+	return gid, gid != 0
+}
+
+// runeWidth 返回千分之一 em 单位的字宽。
+func (f *pdfFont) runeWidth(r rune) float64 {
+	if f.builtin {
+		if r >= 0x20 && r <= 0x7E {
+			return 500
+		}
+		return 1000
+	}
+	gid, ok := f.glyphIndex(r)
+	if !ok {
+		return 1000
+	}
+	if w, ok := f.widths[gid]; ok {
+		return float64(w)
+	}
+	adv, err := f.parsed.GlyphAdvance(&f.buf, sfnt.GlyphIndex(gid), fixed.I(1000), font.HintingNone)
+	w := 1000
+	if err == nil {
+		w = adv.Round()
+	}
+	f.widths[gid] = w
+	return float64(w)
+}
+
+func (f *pdfFont) textWidth(text string, size float64) float64 {
+	total := 0.0
+	for _, r := range text {
+		total += f.runeWidth(r)
+	}
+	return total * size / 1000
+}
+
+// encode 将文本编码为内容流中的十六进制字符串。
+func (f *pdfFont) encode(text string) string {
+	var sb strings.Builder
+	sb.WriteByte('<')
+	for _, r := range text {
+		if f.builtin {
+			fmt.Fprintf(&sb, "%04X", r)
+			continue
+		}
+		gid, _ := f.glyphIndex(r)
+		if _, ok := f.used[gid]; !ok {
+			f.used[gid] = r
+		}
+		f.runeWidth(r)
+		fmt.Fprintf(&sb, "%04X", gid)
+	}
+	sb.WriteByte('>')
+	return sb.String()
+}
+
+func (f *pdfFont) write(d *pdfDocument) error {
+	if f.builtin {
+		descriptorID := d.addObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+		d.setObject(f.objectID, fmt.Sprintf(
+			"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 500] >>] >>",
+			descriptorID,
+		))
+		return nil
+	}
+
+	var fileID int
+	fileKey := "FontFile2"
+	if f.cff {
+		fileKey = "FontFile3"
+		fileID = d.addStream("/Subtype /OpenType", f.data, true)
+	} else {
+		fileID = d.addStream(fmt.Sprintf("/Length1 %d", len(f.data)), f.data, true)
+	}
+	ppem := fixed.I(1000)
+	metrics, err := f.parsed.Metrics(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return fmt.Errorf("读取字体度量失败: %w", err)
+	}
+	bounds, err := f.parsed.Bounds(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return fmt.Errorf("读取字体边界失败: %w", err)
+	}
+	capHeight := metrics.CapHeight.Round()
+	if capHeight <= 0 {
+		capHeight = metrics.Ascent.Round()
+	}
+	// sfnt 的 Y 轴向下，需翻转为 PDF 坐标
+	descriptorID := d.addObject(fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /%s %d 0 R >>",
+		f.baseName, bounds.Min.X.Round(), -bounds.Max.Y.Round(), bounds.Max.X.Round(), -bounds.Min.Y.Round(),
+		metrics.Ascent.Round(), -metrics.Descent.Round(), capHeight, fileKey, fileID,
+	))
+
+	gids := make([]int, 0, len(f.used))
+	for gid := range f.used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, " %d [%d]", gid, f.widths[uint16(gid)])
+	}
+	subtype := "CIDFontType2"
+	gidMap := " /CIDToGIDMap /Identity"
+	if f.cff {
+		subtype = "CIDFontType0"
+		gidMap = ""
+	}
+	cidFontID := d.addObject(fmt.Sprintf(
+		"<< /Type /Font /Subtype /%s /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s ]%s >>",
+		subtype, f.baseName, descriptorID, widths.String(), gidMap,
+	))
+	toUnicodeID := d.addStream("", buildPDFToUnicodeCMap(f.used, gids), true)
+	d.setObject(f.objectID, fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.baseName, cidFontID, toUnicodeID,
+	))
+	return nil
+}
+
+// buildPDFToUnicodeCMap 让嵌入字体中的文字可被复制与检索。
+func buildPDFToUnicodeCMap(used map[uint16]rune, gids []int) []byte {
+	var sb strings.Builder
+	sb.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	sb.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	sb.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	sb.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&sb, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			units := utf16.Encode([]rune{used[uint16(gid)]})
+			var target strings.Builder
+			for _, unit := range units {
+				fmt.Fprintf(&target, "%04X", unit)
+			}
+			fmt.Fprintf(&sb, "<%04X> <%s>\n", gid, target.String())
+		}
+		sb.WriteString("endbfchar\n")
+	}
+	sb.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return []byte(sb.String())
+}
+
+type pdfImage struct {
+	name     string
+	objectID int
+	width    int
+	height   int
+}
+
+// addImage 登记图片 XObject，相同 key 只写入一次。JPEG 直接透传，其余格式解码后以 Flate 压缩，透明通道写入 SMask。
+func (d *pdfDocument) addImage(key string, data []byte) (*pdfImage, error) {
+	if img, ok := d.images[key]; ok {
+		return img, nil
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("无法识别图片: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("图片尺寸无效")
+	}
+	img := &pdfImage{
+		name:   fmt.Sprintf("Im%d", len(d.imageOrder)+1),
+		width:  cfg.Width,
+		height: cfg.Height,
+	}
+	colorSpace := ""
+	if format == "jpeg" {
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.YCbCrModel, color.RGBAModel:
+			colorSpace = "/DeviceRGB"
+		}
+	}
+	if colorSpace != "" {
+		img.objectID = d.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+			cfg.Width, cfg.Height, colorSpace), data, false)
+	} else {
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解码图片失败: %w", err)
+		}
+		rgb, alpha, hasAlpha := splitPDFImageChannels(decoded)
+		smask := ""
+		if hasAlpha {
+			maskID := d.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8",
+				cfg.Width, cfg.Height), alpha, true)
+			smask = fmt.Sprintf(" /SMask %d 0 R", maskID)
+		}
+		img.objectID = d.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8%s",
+			cfg.Width, cfg.Height, smask), rgb, true)
+	}
+	d.images[key] = img
+	d.imageOrder = append(d.imageOrder, img)
+	return img, nil
+}
+
+func splitPDFImageChannels(img image.Image) ([]byte, []byte, bool) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rgb := make([]byte, 0, w*h*3)
+	alpha := make([]byte, 0, w*h)
+	hasAlpha := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A != 0xff {
+				hasAlpha = true
+			}
+		}
+	}
+	return rgb, alpha, hasAlpha
+}
+
+// pdfContent 累积单页内容流指令。
+type pdfContent struct {
+	buf bytes.Buffer
+}
+
+func (c *pdfContent) fillRect(x, y, w, h float64, rgb [3]float64) {
+	fmt.Fprintf(&c.buf, "q %s rg %s %s %s %s re f Q\n", pdfColor(rgb), pdfNum(x), pdfNum(y), pdfNum(w), pdfNum(h))
+}
+
+func (c *pdfContent) line(x1, y1, x2, y2, width float64, rgb [3]float64) {
+	fmt.Fprintf(&c.buf, "q %s RG %s w %s %s m %s %s l S Q\n", pdfColor(rgb), pdfNum(width), pdfNum(x1), pdfNum(y1), pdfNum(x2), pdfNum(y2))
+}
+
+// text 在基线 (x, y) 处绘制单一字体的文本；bold 通过描边模拟。
+func (c *pdfContent) text(f *pdfFont, size, x, y float64, text string, rgb [3]float64, bold bool) {
+	if text == "" {
+		return
+	}
+	mode := "0 Tr"
+	if bold {
+		mode = fmt.Sprintf("2 Tr %s w %s RG", pdfNum(size*0.03), pdfColor(rgb))
+	}
+	fmt.Fprintf(&c.buf, "BT /%s %s Tf %s rg %s %s %s Td %s Tj ET\n",
+		f.name, pdfNum(size), pdfColor(rgb), mode, pdfNum(x), pdfNum(y), f.encode(text))
+}
+
+func (c *pdfContent) image(img *pdfImage, x, y, w, h float64) {
+	fmt.Fprintf(&c.buf, "q %s 0 0 %s %s %s cm /%s Do Q\n", pdfNum(w), pdfNum(h), pdfNum(x), pdfNum(y), img.name)
+}
+
+func (c *pdfContent) Bytes() []byte {
+	return c.buf.Bytes()
+}
+
+func pdfNum(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+func pdfColor(rgb [3]float64) string {
+	return fmt.Sprintf("%.3f %.3f %.3f", rgb[0], rgb[1], rgb[2])
+}
+
+// pdfTextString 以带 BOM 的 UTF-16BE 十六进制字符串表示文档信息中的文本。
+func pdfTextString(text string) string {
+	units := utf16.Encode([]rune(text))
+	raw := make([]byte, 0, 2+len(units)*2)
+	raw = append(raw, 0xfe, 0xff)
+	for _, unit := range units {
+		raw = append(raw, byte(unit>>8), byte(unit))
+	}
+	return "<" + strings.ToUpper(hex.EncodeToString(raw)) + ">"
+}
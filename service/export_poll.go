@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pollExportStatus 汇总投票类型与状态，例如「多选 · 匿名 · 已结束」
+func pollExportStatus(poll *PollWidgetState) string {
+	parts := make([]string, 0, 3)
+	if poll.Multiple {
+		parts = append(parts, "多选")
+	} else {
+		parts = append(parts, "单选")
+	}
+	if poll.Anonymous {
+		parts = append(parts, "匿名")
+	} else {
+		parts = append(parts, "实名")
+	}
+	switch {
+	case poll.Closed:
+		parts = append(parts, "已结束")
+	case poll.ClosesAt > 0:
+		parts = append(parts, "截止于 "+time.UnixMilli(poll.ClosesAt).Format("2006-01-02 15:04"))
+	default:
+		parts = append(parts, "进行中")
+	}
+	return strings.Join(parts, " · ")
+}
+
+func pollExportPercent(count, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return (count*100 + total/2) / total
+}
+
+func pollExportVoterNames(option PollOptionState) []string {
+	names := make([]string, 0, len(option.Voters))
+	for _, voter := range option.Voters {
+		name := strings.TrimSpace(voter.Name)
+		if name == "" {
+			name = voter.UserID
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func renderPollExportPlain(poll *PollWidgetState) string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("[投票] %s（%s）", poll.Question, pollExportStatus(poll)))
+	for i, option := range poll.Options {
+		buf.WriteString(fmt.Sprintf("\n%d. %s — %d 票 (%d%%)", i+1, option.Text, option.Count, pollExportPercent(option.Count, poll.TotalVoters)))
+		if names := pollExportVoterNames(option); len(names) > 0 {
+			buf.WriteString("：" + strings.Join(names, "、"))
+		}
+	}
+	buf.WriteString(fmt.Sprintf("\n共 %d 人参与", poll.TotalVoters))
+	return buf.String()
+}
+
+func renderPollExportHTML(poll *PollWidgetState) string {
+	var buf strings.Builder
+	buf.WriteString(`<div class="export-poll">`)
+	buf.WriteString(`<div class="export-poll__header">`)
+	buf.WriteString(`<span class="export-poll__question">` + htmlEscape(poll.Question) + `</span>`)
+	buf.WriteString(`<span class="export-poll__meta">` + htmlEscape(pollExportStatus(poll)) + `</span>`)
+	buf.WriteString(`</div>`)
+	buf.WriteString(`<ul class="export-poll__options">`)
+	for _, option := range poll.Options {
+		percent := pollExportPercent(option.Count, poll.TotalVoters)
+		buf.WriteString(`<li class="export-poll__option">`)
+		buf.WriteString(`<div class="export-poll__option-head">`)
+		buf.WriteString(`<span class="export-poll__option-text">` + htmlEscape(option.Text) + `</span>`)
+		buf.WriteString(fmt.Sprintf(`<span class="export-poll__option-count">%d 票 · %d%%</span>`, option.Count, percent))
+		buf.WriteString(`</div>`)
+		buf.WriteString(fmt.Sprintf(`<div class="export-poll__bar"><div class="export-poll__bar-fill" style="width: %d%%"></div></div>`, percent))
+		if names := pollExportVoterNames(option); len(names) > 0 {
+			buf.WriteString(`<div class="export-poll__voters">` + htmlEscape(strings.Join(names, "、")) + `</div>`)
+		}
+		buf.WriteString(`</li>`)
+	}
+	buf.WriteString(`</ul>`)
+	buf.WriteString(fmt.Sprintf(`<div class="export-poll__footer">共 %d 人参与</div>`, poll.TotalVoters))
+	buf.WriteString(`</div>`)
+	return buf.String()
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"sealchat/model"
+)
+
+// 消息内容中引用的站内附件：HTML 的 src="id:xxx" 与富文本 JSON 的 "src":"id:xxx"
+var roundTripAttachmentRefPattern = regexp.MustCompile(`(src="|src='|"src":\s*")id:([A-Za-z0-9_\-]+)`)
+
+const (
+	maxRoundTripAttachmentSize = 20 * 1024 * 1024
+	// 单个导出文件内嵌附件的总字节预算，超出后的附件只保留 token 引用
+	maxRoundTripAttachmentTotalSize = 64 * 1024 * 1024
+)
+
+// attachExportRoundTripData 为 JSON 导出补充掷骰结果与附件数据，使导出文件可以在其他实例完整回导
+func attachExportRoundTripData(payload *ExportPayload) {
+	if payload == nil || len(payload.Messages) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(payload.Messages))
+	for _, msg := range payload.Messages {
+		if msg.ID != "" {
+			ids = append(ids, msg.ID)
+		}
+	}
+	rollMap, err := model.MessageDiceRollListByMessageIDs(ids)
+	if err != nil {
+		log.Printf("导出掷骰结果加载失败: %v", err)
+	}
+	for i := range payload.Messages {
+		rolls := rollMap[payload.Messages[i].ID]
+		if len(rolls) == 0 {
+			continue
+		}
+		items := make([]ExportDiceRoll, 0, len(rolls))
+		for _, roll := range rolls {
+			items = append(items, ExportDiceRoll{
+				RollIndex:       roll.RollIndex,
+				SourceText:      roll.SourceText,
+				Formula:         roll.Formula,
+				ResultDetail:    roll.ResultDetail,
+				ResultValueText: roll.ResultValueText,
+				ResultText:      roll.ResultText,
+				IsError:         roll.IsError,
+				RuleSystem:      roll.RuleSystem,
+				Outcome:         roll.Outcome,
+			})
+		}
+		payload.Messages[i].DiceRolls = items
+	}
+
+	if !payload.IncludeImages {
+		return
+	}
+	tokens := collectRoundTripAttachmentTokens(payload)
+	if len(tokens) == 0 {
+		return
+	}
+	assets := make(map[string]string, len(tokens))
+	var omitted []string
+	var total int64
+	for _, token := range tokens {
+		att, _ := ResolveAttachment(token)
+		// 已知大小时先按预算筛掉，避免读取注定放不下的文件
+		if att != nil && att.Size > 0 && (att.Size > maxRoundTripAttachmentSize || total+att.Size > maxRoundTripAttachmentTotalSize) {
+			omitted = append(omitted, token)
+			continue
+		}
+		data, mimeType, _, err := loadAttachmentBytes(token, att)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		size := int64(len(data))
+		if size > maxRoundTripAttachmentSize || total+size > maxRoundTripAttachmentTotalSize {
+			omitted = append(omitted, token)
+			continue
+		}
+		total += size
+		if mimeType == "" {
+			mimeType = http.DetectContentType(data)
+		}
+		assets[token] = fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+	}
+	if len(assets) > 0 {
+		payload.Attachments = assets
+	}
+	if len(omitted) > 0 {
+		log.Printf("导出附件超出内嵌预算，%d 个附件仅保留引用: channel=%s", len(omitted), payload.ChannelID)
+		payload.AttachmentRefs = omitted
+	}
+}
+
+// collectRoundTripAttachmentTokens 收集消息内容与发言人头像中引用的附件 token
+func collectRoundTripAttachmentTokens(payload *ExportPayload) []string {
+	seen := map[string]struct{}{}
+	var tokens []string
+	add := func(token string) {
+		token = normalizeRoundTripAttachmentToken(token)
+		if token == "" {
+			return
+		}
+		if _, ok := seen[token]; ok {
+			return
+		}
+		seen[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+	for _, msg := range payload.Messages {
+		for _, match := range roundTripAttachmentRefPattern.FindAllStringSubmatch(msg.Content, -1) {
+			add(match[2])
+		}
+		if avatar := strings.TrimSpace(msg.SenderAvatar); strings.HasPrefix(avatar, "id:") {
+			add(avatar)
+		}
+	}
+	return tokens
+}
+
+func normalizeRoundTripAttachmentToken(token string) string {
+	token = strings.TrimSpace(token)
+	for strings.HasPrefix(token, "id:") {
+		token = strings.TrimSpace(token[3:])
+	}
+	return token
+}
+
+// buildRoundTripPayload 复制导出数据并去除仅用于展示的 HTML 渲染结果
+func buildRoundTripPayload(payload *ExportPayload) *ExportPayload {
+	if payload == nil {
+		return nil
+	}
+	clone := *payload
+	clone.InlineAssets = nil
+	clone.Messages = make([]ExportMessage, len(payload.Messages))
+	for i, msg := range payload.Messages {
+		msg.ContentHTML = ""
+		clone.Messages[i] = msg
+	}
+	return &clone
+}
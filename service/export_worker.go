@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"sealchat/model"
+	"sealchat/service/metrics/telemetry"
 )
 
 type MessageExportWorkerConfig struct {
@@ -65,9 +66,14 @@ func runMessageExportWorker(cfg MessageExportWorkerConfig) {
 			<-ticker.C
 			continue
 		}
-		if err := processExportJob(job, cfg); err != nil {
+		startedAt := time.Now()
+		err = processExportJob(job, cfg)
+		status := telemetry.OutcomeSuccess
+		if err != nil {
+			status = telemetry.OutcomeFailure
 			log.Printf("export: 执行任务 %s 失败: %v", job.ID, err)
 		}
+		telemetry.ExportJobDuration.Observe(time.Since(startedAt).Seconds(), strings.ToLower(job.Format), status)
 	}
 }
 
@@ -116,22 +122,29 @@ func processExportJob(job *model.MessageExportJobModel, cfg MessageExportWorkerC
 		return nil
 	}
 
+	if strings.EqualFold(job.Format, "pdf") {
+		if err := processPDFExportJob(job, channelName, messages, cfg, extraOptions); err != nil {
+			_ = markJobFailed(job, err)
+			return err
+		}
+		return nil
+	}
+
 	var ctx *payloadContext
 	if extraOptions != nil && len(extraOptions.DisplaySettings) > 0 {
 		ctx = &payloadContext{DisplayOptions: extraOptions.DisplaySettings}
 	}
 	payload := buildExportPayload(job, channelName, messages, ctx, extraOptions)
-	if extraOptions != nil && extraOptions.TextColorizeBBCode {
-		if payload.ExtraMeta == nil {
-			payload.ExtraMeta = make(map[string]interface{})
-		}
-		payload.ExtraMeta["text_colorize_bbcode"] = true
-		if len(extraOptions.TextColorizeBBCodeMap) > 0 {
-			payload.ExtraMeta["text_colorize_bbcode_map"] = cloneStringMap(extraOptions.TextColorizeBBCodeMap)
-		}
-		if len(extraOptions.TextColorizeBBCodeNameMap) > 0 {
-			payload.ExtraMeta["text_colorize_bbcode_name_map"] = cloneStringMap(extraOptions.TextColorizeBBCodeNameMap)
-		}
+	applyExportColorizeMeta(payload, extraOptions)
+
+	if strings.EqualFold(job.Format, "epub") && payload.IncludeImages {
+		// EPUB 需自包含图片，与 HTML 导出共用内联逻辑，由格式化器拆分为书内资源
+		newInlineImageEmbedder().inlinePayload(payload)
+	}
+	if strings.EqualFold(job.Format, "json") && extraOptions != nil && extraOptions.IncludeRoundTrip {
+		// 按需附带掷骰结果与附件，便于回导到其他实例
+		payload.IncludeRoundTrip = true
+		attachExportRoundTripData(payload)
 	}
 
 	formatter, ok := getFormatter(job.Format)
@@ -161,6 +174,28 @@ func processExportJob(job *model.MessageExportJobModel, cfg MessageExportWorkerC
 	return markJobDone(job, filePath, fileName)
 }
 
+// applyExportColorizeMeta 将导出配色与自定义名字写入 ExtraMeta，供 TXT 的 BBCode 着色与 PDF 发言人配色使用。
+func applyExportColorizeMeta(payload *ExportPayload, extraOptions *exportExtraOptions) {
+	if payload == nil || extraOptions == nil {
+		return
+	}
+	if !extraOptions.TextColorizeBBCode && len(extraOptions.TextColorizeBBCodeMap) == 0 && len(extraOptions.TextColorizeBBCodeNameMap) == 0 {
+		return
+	}
+	if payload.ExtraMeta == nil {
+		payload.ExtraMeta = make(map[string]interface{})
+	}
+	if extraOptions.TextColorizeBBCode {
+		payload.ExtraMeta["text_colorize_bbcode"] = true
+	}
+	if len(extraOptions.TextColorizeBBCodeMap) > 0 {
+		payload.ExtraMeta["text_colorize_bbcode_map"] = cloneStringMap(extraOptions.TextColorizeBBCodeMap)
+	}
+	if len(extraOptions.TextColorizeBBCodeNameMap) > 0 {
+		payload.ExtraMeta["text_colorize_bbcode_name_map"] = cloneStringMap(extraOptions.TextColorizeBBCodeNameMap)
+	}
+}
+
 func markJobFailed(job *model.MessageExportJobModel, cause error) error {
 	message := ""
 	if cause != nil {
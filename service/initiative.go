@@ -0,0 +1,704 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/protocol"
+)
+
+const (
+	initiativeMaxCombatants       = 100
+	initiativeNameMaxLen          = 50
+	initiativeFormulaMaxLen       = 100
+	initiativeMaxConditions       = 20
+	initiativeConditionNameMaxLen = 30
+	initiativeMaxConditionRounds  = 1000
+	initiativeDefaultFormula      = "d20"
+	// 并发修改时的重试次数
+	initiativeMaxRetries = 3
+)
+
+// 回合操作
+const (
+	InitiativeActionStart = "start" // 开始战斗，从先攻最高者的第 1 轮开始
+	InitiativeActionNext  = "next"  // 结束当前回合，轮到下一位
+	InitiativeActionPrev  = "prev"  // 回到上一位，不恢复已过期的状态
+	InitiativeActionEnd   = "end"   // 结束战斗，保留参战单位
+	InitiativeActionClear = "clear" // 结束战斗并清空先攻表
+)
+
+var (
+	ErrInitiativeCombatantNotFound = errors.New("参战单位不存在")
+	ErrInitiativePermission        = errors.New("没有操作该先攻表的权限")
+	ErrInitiativeEmpty             = errors.New("先攻表中还没有参战单位")
+	ErrInitiativeConflict          = errors.New("先攻表已被他人修改，请重试")
+
+	errInitiativeVersionMismatch = errors.New("initiative version mismatch")
+)
+
+// InitiativeCombatantInput 添加或修改参战单位的参数；Initiative 与 Roll 同时给出时以 Initiative 为准
+type InitiativeCombatantInput struct {
+	Name       *string `json:"name"`
+	IdentityID string  `json:"identity_id"` // 仅添加时有效，留空为 NPC
+	Initiative *int    `json:"initiative"`
+	Formula    *string `json:"formula"` // 先攻公式，默认 d20，可引用角色卡属性如 d20+敏捷调整
+	Tiebreak   *int    `json:"tiebreak"`
+	Roll       bool    `json:"roll"` // 立即按公式掷先攻
+}
+
+// initiativeState 一次修改中加载的先攻表，修改过的单位在提交时写回
+type initiativeState struct {
+	tracker    *model.InitiativeTrackerModel
+	combatants []*model.InitiativeCombatantModel
+	dirty      map[string]*model.InitiativeCombatantModel
+}
+
+// InitiativeCanManage 世界管理员或拥有频道基础设置权限的成员可以管理先攻表
+func InitiativeCanManage(channelID, userID string) bool {
+	if pm.CanWithChannelRole(userID, channelID, pm.PermFuncChannelManageInfo) {
+		return true
+	}
+	channel, _ := model.ChannelGet(channelID)
+	return channel != nil && channel.WorldID != "" && IsWorldAdmin(channel.WorldID, userID)
+}
+
+func initiativeCanParticipate(channelID, userID string) bool {
+	return pm.CanWithChannelRole(userID, channelID, pm.PermFuncChannelTextSend, pm.PermFuncChannelTextSendAll)
+}
+
+// InitiativeGet 返回频道的先攻表，未创建时返回空表
+func InitiativeGet(channelID string) (*protocol.InitiativeTracker, error) {
+	tracker, err := model.InitiativeTrackerGet(nil, channelID)
+	if err != nil {
+		return nil, err
+	}
+	combatants, err := model.InitiativeCombatantList(nil, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return InitiativeTrackerToProtocol(channelID, tracker, combatants), nil
+}
+
+// InitiativeTrackerToProtocol 转换为按行动顺序排列的快照，关联角色的颜色与头像取当前值
+func InitiativeTrackerToProtocol(channelID string, tracker *model.InitiativeTrackerModel, combatants []*model.InitiativeCombatantModel) *protocol.InitiativeTracker {
+	view := &protocol.InitiativeTracker{
+		ChannelID:  channelID,
+		Combatants: make([]*protocol.InitiativeCombatant, 0, len(combatants)),
+	}
+	if tracker != nil {
+		view.Active = tracker.Active
+		view.Round = tracker.Round
+		view.CurrentCombatantID = tracker.CurrentCombatantID
+		view.Version = tracker.Version
+		view.UpdatedAt = tracker.UpdatedAt.UnixMilli()
+	}
+	identityIDs := make([]string, 0, len(combatants))
+	for _, item := range combatants {
+		if item.IdentityID != "" {
+			identityIDs = append(identityIDs, item.IdentityID)
+		}
+	}
+	identities := map[string]*model.ChannelIdentityModel{}
+	if len(identityIDs) > 0 {
+		var items []*model.ChannelIdentityModel
+		model.GetDB().Where("id IN ?", identityIDs).Find(&items)
+		for _, item := range items {
+			identities[item.ID] = item
+		}
+	}
+	for _, item := range sortInitiativeCombatants(combatants) {
+		entry := &protocol.InitiativeCombatant{
+			ID:                item.ID,
+			Name:              item.Name,
+			IdentityID:        item.IdentityID,
+			UserID:            item.UserID,
+			IsNPC:             item.IsNPC,
+			HasInitiative:     item.HasInitiative,
+			Initiative:        item.Initiative,
+			InitiativeFormula: item.InitiativeFormula,
+			InitiativeDetail:  item.InitiativeDetail,
+			Tiebreak:          item.Tiebreak,
+			Conditions:        make([]*protocol.InitiativeCondition, 0, len(item.Conditions)),
+		}
+		if identity := identities[item.IdentityID]; identity != nil {
+			entry.Color = identity.Color
+			entry.AvatarAttachment = identity.AvatarAttachmentID
+		}
+		for _, cond := range item.Conditions {
+			entry.Conditions = append(entry.Conditions, &protocol.InitiativeCondition{Name: cond.Name, Rounds: cond.Rounds})
+		}
+		view.Combatants = append(view.Combatants, entry)
+	}
+	return view
+}
+
+// sortInitiativeCombatants 行动顺序：已掷先攻者在前，先攻降序，再按 Tiebreak 降序与加入顺序
+func sortInitiativeCombatants(items []*model.InitiativeCombatantModel) []*model.InitiativeCombatantModel {
+	sorted := append([]*model.InitiativeCombatantModel{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.HasInitiative != b.HasInitiative {
+			return a.HasInitiative
+		}
+		if a.Initiative != b.Initiative {
+			return a.Initiative > b.Initiative
+		}
+		if a.Tiebreak != b.Tiebreak {
+			return a.Tiebreak > b.Tiebreak
+		}
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return sorted
+}
+
+// InitiativeCombatantAdd 添加参战单位。关联角色时成员只能添加自己的角色，NPC 需要管理权限
+func InitiativeCombatantAdd(channelID, userID string, input InitiativeCombatantInput) (*protocol.InitiativeTracker, error) {
+	canManage := InitiativeCanManage(channelID, userID)
+	if !canManage && !initiativeCanParticipate(channelID, userID) {
+		return nil, ErrInitiativePermission
+	}
+	item := &model.InitiativeCombatantModel{ChannelID: channelID, UserID: userID, IsNPC: true}
+	if identityID := strings.TrimSpace(input.IdentityID); identityID != "" {
+		identity, err := model.ChannelIdentityGetByID(identityID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if identity == nil || identity.ID == "" || identity.ChannelID != channelID {
+			return nil, errors.New("角色不存在或不属于该频道")
+		}
+		if identity.UserID != userID && !canManage {
+			return nil, ErrInitiativePermission
+		}
+		item.IdentityID = identity.ID
+		item.UserID = identity.UserID
+		item.IsNPC = false
+		item.Name = identity.DisplayName
+	} else if !canManage {
+		return nil, ErrInitiativePermission
+	}
+	if err := applyInitiativeCombatantInput(item, input); err != nil {
+		return nil, err
+	}
+	if item.Name == "" {
+		return nil, errors.New("名称不能为空")
+	}
+	if input.Initiative == nil && input.Roll {
+		if err := rollInitiativeCombatant(channelID, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return initiativeMutate(channelID, userID, func(tx *gorm.DB, state *initiativeState) error {
+		if len(state.combatants) >= initiativeMaxCombatants {
+			return errors.New("先攻表人数已达上限")
+		}
+		maxOrder := 0
+		for _, existing := range state.combatants {
+			if item.IdentityID != "" && existing.IdentityID == item.IdentityID {
+				return errors.New("该角色已在先攻表中")
+			}
+			if existing.SortOrder > maxOrder {
+				maxOrder = existing.SortOrder
+			}
+		}
+		item.Init()
+		item.SortOrder = maxOrder + 1
+		if err := tx.Create(item).Error; err != nil {
+			return err
+		}
+		state.combatants = append(state.combatants, item)
+		return nil
+	})
+}
+
+// InitiativeCombatantUpdate 修改参战单位的名称、先攻值或公式，Roll 为真时重新掷先攻
+func InitiativeCombatantUpdate(channelID, userID, combatantID string, input InitiativeCombatantInput) (*protocol.InitiativeTracker, error) {
+	current, err := loadInitiativeCombatantForUser(channelID, userID, combatantID)
+	if err != nil {
+		return nil, err
+	}
+	next := *current
+	if err := applyInitiativeCombatantInput(&next, input); err != nil {
+		return nil, err
+	}
+	if next.Name == "" {
+		return nil, errors.New("名称不能为空")
+	}
+	if input.Initiative == nil && input.Roll {
+		if err := rollInitiativeCombatant(channelID, &next); err != nil {
+			return nil, err
+		}
+	}
+	return initiativeMutate(channelID, userID, func(tx *gorm.DB, state *initiativeState) error {
+		item := state.find(combatantID)
+		if item == nil {
+			return ErrInitiativeCombatantNotFound
+		}
+		item.Name = next.Name
+		item.HasInitiative = next.HasInitiative
+		item.Initiative = next.Initiative
+		item.InitiativeFormula = next.InitiativeFormula
+		item.InitiativeDetail = next.InitiativeDetail
+		item.Tiebreak = next.Tiebreak
+		state.markDirty(item)
+		return nil
+	})
+}
+
+// InitiativeCombatantRemove 移除参战单位；移除当前行动者时轮到下一位
+func InitiativeCombatantRemove(channelID, userID, combatantID string) (*protocol.InitiativeTracker, error) {
+	if _, err := loadInitiativeCombatantForUser(channelID, userID, combatantID); err != nil {
+		return nil, err
+	}
+	return initiativeMutate(channelID, userID, func(tx *gorm.DB, state *initiativeState) error {
+		if state.find(combatantID) == nil {
+			return ErrInitiativeCombatantNotFound
+		}
+		if state.tracker.Active && state.tracker.CurrentCombatantID == combatantID {
+			state.moveNext(false)
+		}
+		if err := tx.Where("id = ? AND channel_id = ?", combatantID, channelID).Delete(&model.InitiativeCombatantModel{}).Error; err != nil {
+			return err
+		}
+		state.remove(combatantID)
+		if len(state.combatants) == 0 {
+			state.end()
+		}
+		return nil
+	})
+}
+
+// InitiativeRoll 按各自的公式（或统一给出的 formula）为参战单位掷先攻。
+// 未指定单位时，管理者为全部 NPC 掷骰，成员为自己的角色掷骰
+func InitiativeRoll(channelID, userID string, combatantIDs []string, formula string) (*protocol.InitiativeTracker, error) {
+	canManage := InitiativeCanManage(channelID, userID)
+	combatants, err := model.InitiativeCombatantList(nil, channelID)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]struct{}{}
+	for _, id := range combatantIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			wanted[id] = struct{}{}
+		}
+	}
+	formula = strings.TrimSpace(formula)
+	if utf8.RuneCountInString(formula) > initiativeFormulaMaxLen {
+		return nil, errors.New("先攻公式过长")
+	}
+	rolled := map[string]*model.InitiativeCombatantModel{}
+	for _, item := range combatants {
+		if len(wanted) > 0 {
+			if _, ok := wanted[item.ID]; !ok {
+				continue
+			}
+			if !canManage && item.UserID != userID {
+				return nil, ErrInitiativePermission
+			}
+		} else if (canManage && !item.IsNPC) || (!canManage && item.UserID != userID) {
+			continue
+		}
+		next := *item
+		if formula != "" {
+			next.InitiativeFormula = formula
+		}
+		if err := rollInitiativeCombatant(channelID, &next); err != nil {
+			return nil, err
+		}
+		rolled[item.ID] = &next
+	}
+	if len(wanted) > 0 && len(rolled) != len(wanted) {
+		return nil, ErrInitiativeCombatantNotFound
+	}
+	if len(rolled) == 0 {
+		return nil, errors.New("没有需要掷先攻的参战单位")
+	}
+	return initiativeMutate(channelID, userID, func(tx *gorm.DB, state *initiativeState) error {
+		for id, next := range rolled {
+			item := state.find(id)
+			if item == nil {
+				continue
+			}
+			item.HasInitiative = true
+			item.Initiative = next.Initiative
+			item.InitiativeFormula = next.InitiativeFormula
+			item.InitiativeDetail = next.InitiativeDetail
+			state.markDirty(item)
+		}
+		return nil
+	})
+}
+
+// InitiativeTurn 推进或调整回合。当前行动者的控制者也可以结束自己的回合
+func InitiativeTurn(channelID, userID, action string) (*protocol.InitiativeTracker, error) {
+	action = strings.TrimSpace(action)
+	canManage := InitiativeCanManage(channelID, userID)
+	if !canManage && action != InitiativeActionNext {
+		return nil, ErrInitiativePermission
+	}
+	return initiativeMutate(channelID, userID, func(tx *gorm.DB, state *initiativeState) error {
+		switch action {
+		case InitiativeActionStart:
+			return state.start()
+		case InitiativeActionNext:
+			if !canManage {
+				current := state.find(state.tracker.CurrentCombatantID)
+				if !state.tracker.Active || current == nil || current.UserID != userID {
+					return ErrInitiativePermission
+				}
+			}
+			if !state.tracker.Active {
+				return state.start()
+			}
+			state.moveNext(true)
+		case InitiativeActionPrev:
+			if state.tracker.Active {
+				state.movePrev()
+			}
+		case InitiativeActionEnd:
+			state.end()
+		case InitiativeActionClear:
+			if err := tx.Where("channel_id = ?", channelID).Delete(&model.InitiativeCombatantModel{}).Error; err != nil {
+				return err
+			}
+			state.combatants = nil
+			state.dirty = map[string]*model.InitiativeCombatantModel{}
+			state.end()
+		default:
+			return errors.New("不支持的回合操作")
+		}
+		return nil
+	})
+}
+
+// InitiativeConditionSet 为参战单位添加、更新或移除状态，rounds 为 0 表示持续到手动移除
+func InitiativeConditionSet(channelID, userID, combatantID, name string, rounds int, remove bool) (*protocol.InitiativeTracker, error) {
+	if _, err := loadInitiativeCombatantForUser(channelID, userID, combatantID); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("状态名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > initiativeConditionNameMaxLen {
+		return nil, errors.New("状态名称过长")
+	}
+	if rounds < 0 || rounds > initiativeMaxConditionRounds {
+		return nil, errors.New("状态持续轮数无效")
+	}
+	return initiativeMutate(channelID, userID, func(tx *gorm.DB, state *initiativeState) error {
+		item := state.find(combatantID)
+		if item == nil {
+			return ErrInitiativeCombatantNotFound
+		}
+		conditions := model.JSONList[model.InitiativeCondition]{}
+		found := false
+		for _, cond := range item.Conditions {
+			if cond.Name == name {
+				found = true
+				if remove {
+					continue
+				}
+				cond.Rounds = rounds
+			}
+			conditions = append(conditions, cond)
+		}
+		if !found && !remove {
+			if len(conditions) >= initiativeMaxConditions {
+				return errors.New("状态数量已达上限")
+			}
+			conditions = append(conditions, model.InitiativeCondition{Name: name, Rounds: rounds})
+		}
+		item.Conditions = conditions
+		state.markDirty(item)
+		return nil
+	})
+}
+
+func applyInitiativeCombatantInput(item *model.InitiativeCombatantModel, input InitiativeCombatantInput) error {
+	if input.Name != nil {
+		item.Name = strings.TrimSpace(*input.Name)
+	}
+	if utf8.RuneCountInString(item.Name) > initiativeNameMaxLen {
+		return errors.New("名称过长")
+	}
+	if input.Formula != nil {
+		item.InitiativeFormula = strings.TrimSpace(*input.Formula)
+	}
+	if utf8.RuneCountInString(item.InitiativeFormula) > initiativeFormulaMaxLen {
+		return errors.New("先攻公式过长")
+	}
+	if input.Tiebreak != nil {
+		item.Tiebreak = *input.Tiebreak
+	}
+	if input.Initiative != nil {
+		item.HasInitiative = true
+		item.Initiative = *input.Initiative
+		item.InitiativeDetail = ""
+	}
+	return nil
+}
+
+// rollInitiativeCombatant 通过骰点渲染器掷先攻，关联角色时可引用其角色卡属性
+func rollInitiativeCombatant(channelID string, item *model.InitiativeCombatantModel) error {
+	channel, err := model.ChannelGet(channelID)
+	if err != nil {
+		return err
+	}
+	formula := item.InitiativeFormula
+	if formula == "" {
+		formula = initiativeDefaultFormula
+	}
+	renderer := newDiceRenderer(channel.DefaultDiceExpr, nil)
+	if item.IdentityID != "" {
+		renderer.cardAttrs = &diceCardAttrs{load: NewDiceCardLoader(item.UserID, channelID, item.IdentityID)}
+	}
+	roll := renderer.computeRoll(formula)
+	if roll.IsError {
+		if roll.ResultText != "" {
+			return errors.New(roll.ResultText)
+		}
+		return errors.New("先攻公式无效")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(roll.ResultValueText), 64)
+	if err != nil {
+		return errors.New("先攻公式的结果不是数字")
+	}
+	item.HasInitiative = true
+	item.Initiative = int(math.Round(value))
+	item.InitiativeFormula = formula
+	item.InitiativeDetail = roll.ResultDetail
+	return nil
+}
+
+// loadInitiativeCombatantForUser 管理者可以操作任意单位，成员只能操作自己控制的单位
+func loadInitiativeCombatantForUser(channelID, userID, combatantID string) (*model.InitiativeCombatantModel, error) {
+	var item model.InitiativeCombatantModel
+	if err := model.GetDB().Where("id = ? AND channel_id = ?", strings.TrimSpace(combatantID), channelID).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, ErrInitiativeCombatantNotFound
+	}
+	if item.UserID != userID && !InitiativeCanManage(channelID, userID) {
+		return nil, ErrInitiativePermission
+	}
+	if item.UserID == userID && !initiativeCanParticipate(channelID, userID) && !InitiativeCanManage(channelID, userID) {
+		return nil, ErrInitiativePermission
+	}
+	return &item, nil
+}
+
+// initiativeMutate 在事务内加载先攻表并执行修改，通过版本号检测并发写入
+func initiativeMutate(channelID, userID string, fn func(tx *gorm.DB, state *initiativeState) error) (*protocol.InitiativeTracker, error) {
+	var tracker *model.InitiativeTrackerModel
+	var combatants []*model.InitiativeCombatantModel
+	for attempt := 0; ; attempt++ {
+		err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+			current, err := loadOrCreateInitiativeTracker(tx, channelID)
+			if err != nil {
+				return err
+			}
+			list, err := model.InitiativeCombatantList(tx, channelID)
+			if err != nil {
+				return err
+			}
+			state := &initiativeState{tracker: current, combatants: list, dirty: map[string]*model.InitiativeCombatantModel{}}
+			version := current.Version
+			if err := fn(tx, state); err != nil {
+				return err
+			}
+			for _, item := range state.dirty {
+				if err := tx.Model(item).Select("name", "has_initiative", "initiative", "initiative_formula", "initiative_detail", "tiebreak", "conditions", "updated_at").
+					Updates(item).Error; err != nil {
+					return err
+				}
+			}
+			current.Version = version + 1
+			current.UpdatedBy = userID
+			current.UpdatedAt = time.Now()
+			result := tx.Model(&model.InitiativeTrackerModel{}).
+				Where("id = ? AND version = ?", current.ID, version).
+				Updates(map[string]any{
+					"active":               current.Active,
+					"round":                current.Round,
+					"current_combatant_id": current.CurrentCombatantID,
+					"version":              current.Version,
+					"updated_by":           current.UpdatedBy,
+					"updated_at":           current.UpdatedAt,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errInitiativeVersionMismatch
+			}
+			tracker = current
+			combatants = state.combatants
+			return nil
+		})
+		if errors.Is(err, errInitiativeVersionMismatch) {
+			if attempt+1 < initiativeMaxRetries {
+				continue
+			}
+			return nil, ErrInitiativeConflict
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	return InitiativeTrackerToProtocol(channelID, tracker, combatants), nil
+}
+
+func loadOrCreateInitiativeTracker(tx *gorm.DB, channelID string) (*model.InitiativeTrackerModel, error) {
+	tracker, err := model.InitiativeTrackerGet(tx, channelID)
+	if err != nil || tracker != nil {
+		return tracker, err
+	}
+	item := &model.InitiativeTrackerModel{ChannelID: channelID}
+	item.Init()
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error; err != nil {
+		return nil, err
+	}
+	tracker, err = model.InitiativeTrackerGet(tx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if tracker == nil {
+		return nil, errors.New("先攻表创建失败")
+	}
+	return tracker, nil
+}
+
+func (s *initiativeState) find(id string) *model.InitiativeCombatantModel {
+	if id == "" {
+		return nil
+	}
+	for _, item := range s.combatants {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *initiativeState) markDirty(item *model.InitiativeCombatantModel) {
+	s.dirty[item.ID] = item
+}
+
+func (s *initiativeState) remove(id string) {
+	list := s.combatants[:0]
+	for _, item := range s.combatants {
+		if item.ID != id {
+			list = append(list, item)
+		}
+	}
+	s.combatants = list
+	delete(s.dirty, id)
+}
+
+func (s *initiativeState) start() error {
+	order := sortInitiativeCombatants(s.combatants)
+	if len(order) == 0 {
+		return ErrInitiativeEmpty
+	}
+	s.tracker.Active = true
+	s.tracker.Round = 1
+	s.tracker.CurrentCombatantID = order[0].ID
+	return nil
+}
+
+func (s *initiativeState) end() {
+	s.tracker.Active = false
+	s.tracker.Round = 0
+	s.tracker.CurrentCombatantID = ""
+}
+
+// moveNext 轮到下一位，越过末尾时进入下一轮。tick 为真时当前行动者身上的限时状态减少一轮
+func (s *initiativeState) moveNext(tick bool) {
+	order := sortInitiativeCombatants(s.combatants)
+	if len(order) == 0 {
+		s.end()
+		return
+	}
+	index := initiativeIndexOf(order, s.tracker.CurrentCombatantID)
+	if index < 0 {
+		s.tracker.CurrentCombatantID = order[0].ID
+		return
+	}
+	if tick {
+		s.tickConditions(order[index])
+	}
+	index++
+	if index >= len(order) {
+		index = 0
+		s.tracker.Round++
+	}
+	s.tracker.CurrentCombatantID = order[index].ID
+}
+
+func (s *initiativeState) movePrev() {
+	order := sortInitiativeCombatants(s.combatants)
+	index := initiativeIndexOf(order, s.tracker.CurrentCombatantID)
+	if index < 0 {
+		if len(order) > 0 {
+			s.tracker.CurrentCombatantID = order[0].ID
+		}
+		return
+	}
+	if index == 0 {
+		if s.tracker.Round <= 1 {
+			return
+		}
+		s.tracker.Round--
+		index = len(order)
+	}
+	s.tracker.CurrentCombatantID = order[index-1].ID
+}
+
+// tickConditions 在单位的回合结束时递减其限时状态，归零的状态被移除
+func (s *initiativeState) tickConditions(item *model.InitiativeCombatantModel) {
+	if len(item.Conditions) == 0 {
+		return
+	}
+	conditions := model.JSONList[model.InitiativeCondition]{}
+	changed := false
+	for _, cond := range item.Conditions {
+		if cond.Rounds > 0 {
+			cond.Rounds--
+			changed = true
+			if cond.Rounds == 0 {
+				continue
+			}
+		}
+		conditions = append(conditions, cond)
+	}
+	if changed {
+		item.Conditions = conditions
+		s.markDirty(item)
+	}
+}
+
+func initiativeIndexOf(order []*model.InitiativeCombatantModel, id string) int {
+	for i, item := range order {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+// setupInitiativeChannelForTest 创建带主持人与普通成员的频道，成员拥有一个频道角色
+func setupInitiativeChannelForTest(t *testing.T) (channelID, gmID, playerID, identityID string) {
+	t.Helper()
+	initTestDB(t)
+	pm.Init()
+	db := model.GetDB()
+
+	worldID := "world-initiative-" + utils.NewID()
+	channelID = "ch-initiative-" + utils.NewID()
+	gmID = "gm-" + utils.NewID()
+	playerID = "player-" + utils.NewID()
+	for _, id := range []string{gmID, playerID} {
+		if err := db.Create(&model.UserModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id},
+			Username:          "u_" + id,
+			Password:          "pw",
+			Salt:              "salt",
+		}).Error; err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+	}
+	if err := db.Create(&model.WorldModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: worldID},
+		Name:              "Initiative World",
+		Visibility:        model.WorldVisibilityPublic,
+		Status:            "active",
+		OwnerID:           gmID,
+	}).Error; err != nil {
+		t.Fatalf("create world failed: %v", err)
+	}
+	for userID, role := range map[string]string{gmID: model.WorldRoleOwner, playerID: model.WorldRoleMember} {
+		if err := db.Create(&model.WorldMemberModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			WorldID:           worldID,
+			UserID:            userID,
+			Role:              role,
+			JoinedAt:          time.Now(),
+		}).Error; err != nil {
+			t.Fatalf("create world member failed: %v", err)
+		}
+	}
+	if channel := ChannelNew(channelID, "public", "Initiative Channel", worldID, gmID, ""); channel == nil {
+		t.Fatal("channel create returned nil")
+	}
+	for userID, role := range map[string]string{gmID: "owner", playerID: "member"} {
+		if _, err := model.UserRoleLink([]string{buildChannelRoleID(channelID, role)}, []string{userID}); err != nil {
+			t.Fatalf("link role failed: %v", err)
+		}
+	}
+	identity := &model.ChannelIdentityModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "identity-" + utils.NewID()},
+		ChannelID:         channelID,
+		UserID:            playerID,
+		DisplayName:       "艾琳",
+		Color:             "#3b82f6",
+	}
+	if err := db.Create(identity).Error; err != nil {
+		t.Fatalf("create identity failed: %v", err)
+	}
+	return channelID, gmID, playerID, identity.ID
+}
+
+func initiativeIntPtr(v int) *int {
+	return &v
+}
+
+func initiativeStringPtr(v string) *string {
+	return &v
+}
+
+func TestInitiativeTurnOrderAndConditions(t *testing.T) {
+	channelID, gmID, playerID, identityID := setupInitiativeChannelForTest(t)
+
+	if _, err := InitiativeCombatantAdd(channelID, playerID, InitiativeCombatantInput{Name: initiativeStringPtr("哥布林")}); !errors.Is(err, ErrInitiativePermission) {
+		t.Fatalf("player should not add NPCs, got %v", err)
+	}
+	tracker, err := InitiativeCombatantAdd(channelID, playerID, InitiativeCombatantInput{IdentityID: identityID, Formula: initiativeStringPtr("d20+3"), Roll: true})
+	if err != nil {
+		t.Fatalf("player add own identity failed: %v", err)
+	}
+	hero := tracker.Combatants[0]
+	if hero.Name != "艾琳" || hero.Color != "#3b82f6" || !hero.HasInitiative || hero.Initiative < 4 || hero.Initiative > 23 || hero.InitiativeDetail == "" {
+		t.Fatalf("unexpected rolled combatant: %+v", hero)
+	}
+	if _, err := InitiativeCombatantAdd(channelID, gmID, InitiativeCombatantInput{IdentityID: identityID}); err == nil {
+		t.Fatal("duplicate identity should be rejected")
+	}
+	if _, err := InitiativeCombatantAdd(channelID, gmID, InitiativeCombatantInput{Name: initiativeStringPtr("哥布林"), Initiative: initiativeIntPtr(30)}); err != nil {
+		t.Fatalf("gm add npc failed: %v", err)
+	}
+	tracker, err = InitiativeCombatantAdd(channelID, gmID, InitiativeCombatantInput{Name: initiativeStringPtr("巨魔"), Initiative: initiativeIntPtr(1)})
+	if err != nil {
+		t.Fatalf("gm add npc failed: %v", err)
+	}
+	if len(tracker.Combatants) != 3 || tracker.Combatants[0].Name != "哥布林" || tracker.Combatants[2].Name != "巨魔" {
+		t.Fatalf("combatants should be sorted by initiative: %+v", tracker.Combatants)
+	}
+	goblinID := tracker.Combatants[0].ID
+
+	if _, err := InitiativeTurn(channelID, playerID, InitiativeActionStart); !errors.Is(err, ErrInitiativePermission) {
+		t.Fatalf("player should not start combat, got %v", err)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionStart)
+	if err != nil || !tracker.Active || tracker.Round != 1 || tracker.CurrentCombatantID != goblinID {
+		t.Fatalf("unexpected start state: %+v err=%v", tracker, err)
+	}
+	if _, err := InitiativeConditionSet(channelID, gmID, goblinID, "中毒", 2, false); err != nil {
+		t.Fatalf("set condition failed: %v", err)
+	}
+	if _, err := InitiativeConditionSet(channelID, gmID, goblinID, "倒地", 0, false); err != nil {
+		t.Fatalf("set condition failed: %v", err)
+	}
+
+	if _, err := InitiativeTurn(channelID, playerID, InitiativeActionNext); !errors.Is(err, ErrInitiativePermission) {
+		t.Fatalf("player should not end the goblin's turn, got %v", err)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionNext)
+	if err != nil || tracker.CurrentCombatantID != hero.ID {
+		t.Fatalf("turn should pass to the hero: %+v err=%v", tracker, err)
+	}
+	if conds := tracker.Combatants[0].Conditions; len(conds) != 2 || conds[0].Rounds != 1 || conds[1].Rounds != 0 {
+		t.Fatalf("conditions should tick down at the end of the turn: %+v", conds)
+	}
+	// 当前行动者的控制者可以结束自己的回合
+	if _, err := InitiativeTurn(channelID, playerID, InitiativeActionNext); err != nil {
+		t.Fatalf("player should end own turn: %v", err)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionNext)
+	if err != nil || tracker.Round != 2 || tracker.CurrentCombatantID != goblinID {
+		t.Fatalf("round should wrap to the goblin: %+v err=%v", tracker, err)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionNext)
+	if err != nil {
+		t.Fatalf("next failed: %v", err)
+	}
+	if conds := tracker.Combatants[0].Conditions; len(conds) != 1 || conds[0].Name != "倒地" {
+		t.Fatalf("expired condition should be removed: %+v", conds)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionPrev)
+	if err != nil || tracker.Round != 2 || tracker.CurrentCombatantID != goblinID {
+		t.Fatalf("prev should step back to the goblin: %+v err=%v", tracker, err)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionPrev)
+	if err != nil || tracker.Round != 1 || tracker.Combatants[2].ID != tracker.CurrentCombatantID {
+		t.Fatalf("prev should wrap back to the previous round: %+v err=%v", tracker, err)
+	}
+
+	tracker, err = InitiativeCombatantRemove(channelID, gmID, tracker.CurrentCombatantID)
+	if err != nil || tracker.Round != 2 || tracker.CurrentCombatantID != goblinID || len(tracker.Combatants) != 2 {
+		t.Fatalf("removing the current combatant should advance the turn: %+v err=%v", tracker, err)
+	}
+	tracker, err = InitiativeTurn(channelID, gmID, InitiativeActionClear)
+	if err != nil || tracker.Active || len(tracker.Combatants) != 0 {
+		t.Fatalf("clear should empty the tracker: %+v err=%v", tracker, err)
+	}
+}
+
+func TestInitiativeRollDefaults(t *testing.T) {
+	channelID, gmID, playerID, identityID := setupInitiativeChannelForTest(t)
+	if _, err := InitiativeCombatantAdd(channelID, playerID, InitiativeCombatantInput{IdentityID: identityID}); err != nil {
+		t.Fatalf("add identity failed: %v", err)
+	}
+	if _, err := InitiativeCombatantAdd(channelID, gmID, InitiativeCombatantInput{Name: initiativeStringPtr("狼"), Formula: initiativeStringPtr("1d1+4")}); err != nil {
+		t.Fatalf("add npc failed: %v", err)
+	}
+	// 主持人未指定对象时只为 NPC 掷骰
+	tracker, err := InitiativeRoll(channelID, gmID, nil, "")
+	if err != nil {
+		t.Fatalf("roll failed: %v", err)
+	}
+	if tracker.Combatants[0].Name != "狼" || tracker.Combatants[0].Initiative != 5 || tracker.Combatants[1].HasInitiative {
+		t.Fatalf("unexpected roll result: %+v", tracker.Combatants)
+	}
+	if _, err := InitiativeRoll(channelID, playerID, []string{tracker.Combatants[0].ID}, ""); !errors.Is(err, ErrInitiativePermission) {
+		t.Fatalf("player should not roll for NPCs, got %v", err)
+	}
+	tracker, err = InitiativeRoll(channelID, playerID, nil, "1d1+9")
+	if err != nil || tracker.Combatants[0].Name != "艾琳" || tracker.Combatants[0].Initiative != 10 {
+		t.Fatalf("player roll should use the override formula: %+v err=%v", tracker, err)
+	}
+	// 未绑定角色卡时引用属性会失败，错误原样返回
+	if _, err := InitiativeRoll(channelID, playerID, nil, "d20+敏捷"); err == nil {
+		t.Fatal("formula referencing a missing card should be rejected")
+	}
+}
+
+func TestChannelCloneCopiesInitiative(t *testing.T) {
+	channelID, gmID, playerID, identityID := setupInitiativeChannelForTest(t)
+	if _, err := InitiativeCombatantAdd(channelID, playerID, InitiativeCombatantInput{IdentityID: identityID, Initiative: initiativeIntPtr(12)}); err != nil {
+		t.Fatalf("add identity failed: %v", err)
+	}
+	if _, err := InitiativeCombatantAdd(channelID, gmID, InitiativeCombatantInput{Name: initiativeStringPtr("龙"), Initiative: initiativeIntPtr(20)}); err != nil {
+		t.Fatalf("add npc failed: %v", err)
+	}
+	if _, err := InitiativeTurn(channelID, gmID, InitiativeActionStart); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if _, err := InitiativeTurn(channelID, gmID, InitiativeActionNext); err != nil {
+		t.Fatalf("next failed: %v", err)
+	}
+	gm := model.UserGet(gmID)
+	result, err := ChannelClone(channelID, gm, ChannelCopyParams{
+		Name:    "Initiative Copy",
+		Options: ChannelCopyOptions{CopyInitiative: true},
+	})
+	if err != nil {
+		t.Fatalf("clone failed: %v", err)
+	}
+	tracker, err := InitiativeGet(result.ChannelID)
+	if err != nil {
+		t.Fatalf("get cloned tracker failed: %v", err)
+	}
+	if !tracker.Active || tracker.Round != 1 || len(tracker.Combatants) != 2 {
+		t.Fatalf("unexpected cloned tracker: %+v", tracker)
+	}
+	// 未复制角色时，玩家角色转为 NPC，回合指针指向新单位
+	hero := tracker.Combatants[1]
+	if hero.Name != "艾琳" || !hero.IsNPC || hero.IdentityID != "" || tracker.CurrentCombatantID != hero.ID {
+		t.Fatalf("identity-linked combatant should become an NPC: %+v current=%s", hero, tracker.CurrentCombatantID)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"sealchat/model"
+)
+
+const (
+	MessageDraftTTL             = 7 * 24 * time.Hour
+	messageDraftMaxContentBytes = 256 * 1024
+	messageDraftMaxExtraBytes   = 8 * 1024
+	// 客户端时间戳最多允许超前服务器的时长，避免时钟偏快的设备长期压制其他设备的保存
+	messageDraftMaxClockSkew = time.Minute
+)
+
+var (
+	ErrMessageDraftTooLarge    = errors.New("草稿内容过长")
+	ErrMessageDraftInvalidMode = errors.New("草稿输入模式无效")
+)
+
+var messageDraftNow = time.Now
+
+// MessageDraftInput 客户端提交的草稿
+type MessageDraftInput struct {
+	ChannelID string `json:"channel_id"`
+	Mode      string `json:"mode"`
+	Content   string `json:"content"`
+	Extra     string `json:"extra"`
+	UpdatedAt int64  `json:"updated_at"` // 客户端最后编辑时间（毫秒）
+}
+
+func messageDraftClientTime(at int64, now time.Time) int64 {
+	limit := now.Add(messageDraftMaxClockSkew).UnixMilli()
+	if at <= 0 || at > limit {
+		return now.UnixMilli()
+	}
+	return at
+}
+
+// MessageDraftSave 按客户端时间戳保存草稿，较旧的写入会被拒绝并返回当前生效的版本。
+// 内容为空表示清空草稿
+func MessageDraftSave(userID string, input *MessageDraftInput) (*model.MessageDraftModel, bool, error) {
+	mode := strings.TrimSpace(input.Mode)
+	switch mode {
+	case "":
+		mode = "plain"
+	case "plain", "rich":
+	default:
+		return nil, false, ErrMessageDraftInvalidMode
+	}
+	if len(input.Content) > messageDraftMaxContentBytes || len(input.Extra) > messageDraftMaxExtraBytes {
+		return nil, false, ErrMessageDraftTooLarge
+	}
+	now := messageDraftNow()
+	item := &model.MessageDraftModel{
+		UserID:          userID,
+		ChannelID:       strings.TrimSpace(input.ChannelID),
+		Mode:            mode,
+		Content:         input.Content,
+		Extra:           input.Extra,
+		ClientUpdatedAt: messageDraftClientTime(input.UpdatedAt, now),
+		ExpiresAt:       now.Add(MessageDraftTTL),
+	}
+	if strings.TrimSpace(item.Content) == "" {
+		item.Content = ""
+		item.Extra = ""
+	}
+	return model.MessageDraftSave(item)
+}
+
+// MessageDraftLoad 获取仍有效的草稿，已清空或已过期时返回 nil
+func MessageDraftLoad(userID, channelID string) (*model.MessageDraftModel, error) {
+	item, err := model.MessageDraftGet(userID, channelID)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	if item.Content == "" || !item.ExpiresAt.After(messageDraftNow()) {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// MessageDraftClearOnSend 消息真正发出后清空该频道草稿
+func MessageDraftClearOnSend(userID, channelID string) error {
+	now := messageDraftNow()
+	return model.MessageDraftClear(userID, channelID, now.UnixMilli(), now.Add(MessageDraftTTL))
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"sealchat/model"
+)
+
+func setupMessageDraftTest(t *testing.T) *time.Time {
+	t.Helper()
+	initTestDB(t)
+	now := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	prev := messageDraftNow
+	messageDraftNow = func() time.Time { return now }
+	t.Cleanup(func() { messageDraftNow = prev })
+	return &now
+}
+
+func TestMessageDraftConflictByTimestamp(t *testing.T) {
+	now := setupMessageDraftTest(t)
+	base := now.UnixMilli()
+
+	desktop := &MessageDraftInput{ChannelID: "ch-draft", Content: "半截的 IC 描写", UpdatedAt: base - 1000}
+	item, applied, err := MessageDraftSave("u-1", desktop)
+	if err != nil || !applied || item.Content != desktop.Content {
+		t.Fatalf("first save should apply: %+v applied=%v err=%v", item, applied, err)
+	}
+
+	stale := &MessageDraftInput{ChannelID: "ch-draft", Content: "手机上的旧版本", UpdatedAt: base - 5000}
+	item, applied, err = MessageDraftSave("u-1", stale)
+	if err != nil || applied || item.Content != desktop.Content {
+		t.Fatalf("stale save should be rejected with current draft: %+v applied=%v err=%v", item, applied, err)
+	}
+
+	newer := &MessageDraftInput{ChannelID: "ch-draft", Mode: "rich", Content: "{\"type\":\"doc\"}", UpdatedAt: base}
+	if item, applied, _ = MessageDraftSave("u-1", newer); !applied || item.Mode != "rich" {
+		t.Fatalf("newer save should apply: %+v applied=%v", item, applied)
+	}
+
+	loaded, err := MessageDraftLoad("u-1", "ch-draft")
+	if err != nil || loaded == nil || loaded.Content != newer.Content {
+		t.Fatalf("unexpected loaded draft: %+v err=%v", loaded, err)
+	}
+	if other, _ := MessageDraftLoad("u-2", "ch-draft"); other != nil {
+		t.Fatalf("drafts should be per user")
+	}
+
+	if _, _, err := MessageDraftSave("u-1", &MessageDraftInput{ChannelID: "ch-draft", Mode: "html"}); err != ErrMessageDraftInvalidMode {
+		t.Fatalf("expected invalid mode error, got %v", err)
+	}
+}
+
+func TestMessageDraftClearedOnSendAndExpires(t *testing.T) {
+	now := setupMessageDraftTest(t)
+	if _, _, err := MessageDraftSave("u-1", &MessageDraftInput{ChannelID: "ch-draft", Content: "待发送", UpdatedAt: now.UnixMilli() - 100}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := MessageDraftClearOnSend("u-1", "ch-draft"); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if loaded, _ := MessageDraftLoad("u-1", "ch-draft"); loaded != nil {
+		t.Fatalf("draft should be cleared after send: %+v", loaded)
+	}
+	// 发送前排队的防抖保存到达时应被拒绝，不会复活已发出的内容
+	if _, applied, _ := MessageDraftSave("u-1", &MessageDraftInput{ChannelID: "ch-draft", Content: "待发送", UpdatedAt: now.UnixMilli() - 50}); applied {
+		t.Fatalf("save older than the send should be rejected")
+	}
+
+	// 客户端时钟超前过多时按服务器时间处理
+	if item, applied, _ := MessageDraftSave("u-1", &MessageDraftInput{ChannelID: "ch-future", Content: "x", UpdatedAt: now.Add(time.Hour).UnixMilli()}); !applied || item.ClientUpdatedAt != now.UnixMilli() {
+		t.Fatalf("future timestamp should be clamped: %+v", item)
+	}
+
+	*now = now.Add(MessageDraftTTL + time.Minute)
+	if loaded, _ := MessageDraftLoad("u-1", "ch-future"); loaded != nil {
+		t.Fatalf("expired draft should not load")
+	}
+	removed, err := model.MessageDraftCleanupExpired(*now)
+	if err != nil || removed != 2 {
+		t.Fatalf("expected 2 expired drafts removed, got %d err=%v", removed, err)
+	}
+}
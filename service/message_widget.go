@@ -14,6 +14,8 @@ type StateWidgetEntry struct {
 	Type    string   `json:"type"`
 	Options []string `json:"options"`
 	Index   int      `json:"index"`
+	// Poll 仅在 Type 为 poll 时存在
+	Poll *PollWidgetState `json:"poll,omitempty"`
 }
 
 const (
@@ -38,17 +40,20 @@ func BuildStateWidgetDataFromContent(content string) string {
 
 // BuildStateWidgetDataFromContentWithPrevious 在重建 widgetData 时尽可能保留历史索引。
 // 当新旧 widget 的 options 序列一致时，继承历史 index；否则回退到默认 index=0。
+// 投票不由正文生成，编辑时原样保留在最前面。
 func BuildStateWidgetDataFromContentWithPrevious(content string, previousWidgetData string) string {
 	entries := buildStateWidgetEntries(content)
-	if len(entries) == 0 {
-		return ""
-	}
 
 	var previous []StateWidgetEntry
+	var polls []StateWidgetEntry
 	if strings.TrimSpace(previousWidgetData) != "" {
 		if err := json.Unmarshal([]byte(previousWidgetData), &previous); err == nil {
 			signatureIndexes := map[string][]int{}
 			for _, entry := range previous {
+				if entry.Type == WidgetTypePoll {
+					polls = append(polls, entry)
+					continue
+				}
 				sig := buildWidgetOptionsSignature(entry.Options)
 				if sig == "" {
 					continue
@@ -84,6 +89,9 @@ func BuildStateWidgetDataFromContentWithPrevious(content string, previousWidgetD
 		}
 	}
 
+	if len(polls) > 0 {
+		entries = append(polls, entries...)
+	}
 	return marshalStateWidgetEntries(entries)
 }
 
@@ -203,6 +211,9 @@ func RotateWidgetIndex(widgetDataJSON string, widgetIndex int) (string, error) {
 	}
 
 	entry := &entries[widgetIndex]
+	if entry.Type == WidgetTypePoll {
+		return "", errors.New("poll widget cannot be rotated")
+	}
 	if len(entry.Options) == 0 {
 		return "", errors.New("widget has no options")
 	}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"sealchat/model"
+	"sealchat/service/metrics/telemetry"
 )
 
 // Config 定义采样周期、保留时间等行为。
@@ -258,6 +259,7 @@ func (c *Collector) RecordMessage() {
 		return
 	}
 	c.messageWindow.Add(1)
+	telemetry.MessagesCreated.Inc()
 }
 
 // LatestSample 返回最近一次采样结果。
@@ -312,6 +314,14 @@ func (c *Collector) CurrentConnectionCount() int64 {
 	return c.connCount.Load()
 }
 
+// CurrentOnlineUsers 返回当前在线判定窗口内活跃的用户数。
+func (c *Collector) CurrentOnlineUsers() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.countOnlineUsers(time.Now().UnixMilli())
+}
+
 // OnlineTTL 返回在线判定窗口。
 func (c *Collector) OnlineTTL() time.Duration {
 	if c == nil || c.cfg.OnlineTTL <= 0 {
@@ -0,0 +1,32 @@
+package telemetry
+
+// 各业务模块的埋点指标，名称统一以 sealchat_ 开头
+var (
+	// MessagesCreated 成功写入的消息数，配合 rate() 计算吞吐
+	MessagesCreated = NewCounterVec("sealchat_messages_created", "Messages successfully created.")
+
+	// WSEvents 按事件名统计下发的 WebSocket 事件
+	WSEvents = NewCounterVec("sealchat_ws_events", "WebSocket events dispatched, by event name.", "event")
+
+	// ExportJobDuration 消息导出任务耗时
+	ExportJobDuration = NewHistogramVec(
+		"sealchat_export_job_duration_seconds",
+		"Duration of message export jobs, by format and result.",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+		"format", "status",
+	)
+
+	// DigestDeliveries 摘要主动推送结果（含重试后的最终结果）
+	DigestDeliveries = NewCounterVec("sealchat_digest_deliveries", "Digest webhook deliveries, by final outcome.", "outcome")
+
+	// AIBilledTokens 平台计费的 AI token 数
+	AIBilledTokens = NewCounterVec("sealchat_ai_billed_tokens", "AI tokens billed on the platform quota, by feature and token kind.", "feature", "kind")
+
+	// StorageBackendErrors 存储后端操作失败次数
+	StorageBackendErrors = NewCounterVec("sealchat_storage_backend_errors", "Storage backend operation failures, by backend and operation.", "backend", "op")
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
@@ -0,0 +1,270 @@
+// Package telemetry 维护进程内的累计计数器与直方图，并按 OpenMetrics 文本格式输出。
+// 该包不依赖业务包，service、storage、ai 等底层模块均可直接埋点。
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type family interface {
+	familyName() string
+	write(w *bufio.Writer)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []family
+)
+
+func register(f family) {
+	registryMu.Lock()
+	registry = append(registry, f)
+	registryMu.Unlock()
+}
+
+// CounterVec 带标签的单调递增计数器，name 不含 _total 后缀
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec 创建并注册计数器
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, series: map[string]*counterSeries{}}
+	register(c)
+	return c
+}
+
+// Inc 计数加一
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 计数增加 delta，负数与非法值会被忽略
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if c == nil || delta <= 0 || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return
+	}
+	values := normalizeLabelValues(c.labels, labelValues)
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	item, ok := c.series[key]
+	if !ok {
+		item = &counterSeries{labelValues: values}
+		c.series[key] = item
+	}
+	item.value += delta
+	c.mu.Unlock()
+}
+
+// Value 返回指定标签组合的当前值
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	if c == nil {
+		return 0
+	}
+	key := strings.Join(normalizeLabelValues(c.labels, labelValues), "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, ok := c.series[key]; ok {
+		return item.value
+	}
+	return 0
+}
+
+func (c *CounterVec) familyName() string { return c.name }
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, "counter", c.help)
+	c.mu.Lock()
+	snapshot := make([]counterSeries, 0, len(c.series))
+	for _, item := range c.series {
+		snapshot = append(snapshot, *item)
+	}
+	c.mu.Unlock()
+	sortSeries(snapshot, func(s counterSeries) []string { return s.labelValues })
+	for _, item := range snapshot {
+		writeSample(w, c.name+"_total", c.labels, item.labelValues, "", "", item.value)
+	}
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec 创建并注册直方图，buckets 为升序的上界，+Inf 自动补充
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: sorted, series: map[string]*histogramSeries{}}
+	register(h)
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if h == nil || math.IsNaN(value) {
+		return
+	}
+	values := normalizeLabelValues(h.labels, labelValues)
+	key := strings.Join(values, "\xff")
+	h.mu.Lock()
+	item, ok := h.series[key]
+	if !ok {
+		item = &histogramSeries{labelValues: values, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = item
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			item.counts[i]++
+		}
+	}
+	item.count++
+	item.sum += value
+	h.mu.Unlock()
+}
+
+func (h *HistogramVec) familyName() string { return h.name }
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, "histogram", h.help)
+	h.mu.Lock()
+	snapshot := make([]histogramSeries, 0, len(h.series))
+	for _, item := range h.series {
+		copied := *item
+		copied.counts = append([]uint64(nil), item.counts...)
+		snapshot = append(snapshot, copied)
+	}
+	h.mu.Unlock()
+	sortSeries(snapshot, func(s histogramSeries) []string { return s.labelValues })
+	for _, item := range snapshot {
+		for i, bound := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labels, item.labelValues, "le", formatFloat(bound), float64(item.counts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, item.labelValues, "le", "+Inf", float64(item.count))
+		writeSample(w, h.name+"_count", h.labels, item.labelValues, "", "", float64(item.count))
+		writeSample(w, h.name+"_sum", h.labels, item.labelValues, "", "", item.sum)
+	}
+}
+
+// Gauge 采集时即时计算的瞬时值
+type Gauge struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+// WriteOpenMetrics 输出 gauges 与所有已注册的指标族，以 # EOF 结尾
+func WriteOpenMetrics(out io.Writer, gauges []Gauge) error {
+	w := bufio.NewWriter(out)
+	for _, gauge := range gauges {
+		writeHeader(w, gauge.Name, "gauge", gauge.Help)
+		writeSample(w, gauge.Name, nil, nil, "", "", gauge.Value)
+	}
+	registryMu.RLock()
+	families := append([]family(nil), registry...)
+	registryMu.RUnlock()
+	sort.SliceStable(families, func(i, j int) bool { return families[i].familyName() < families[j].familyName() })
+	for _, f := range families {
+		f.write(w)
+	}
+	_, _ = w.WriteString("# EOF\n")
+	return w.Flush()
+}
+
+func normalizeLabelValues(labels []string, values []string) []string {
+	out := make([]string, len(labels))
+	copy(out, values)
+	return out
+}
+
+func sortSeries[T any](items []T, labelValues func(T) []string) {
+	sort.Slice(items, func(i, j int) bool {
+		return strings.Join(labelValues(items[i]), "\xff") < strings.Join(labelValues(items[j]), "\xff")
+	})
+}
+
+func writeHeader(w *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	_, _ = w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		_ = w.WriteByte('{')
+		first := true
+		for i, label := range labels {
+			if !first {
+				_ = w.WriteByte(',')
+			}
+			first = false
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabelValue(values[i]))
+		}
+		if extraLabel != "" {
+			if !first {
+				_ = w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		_ = w.WriteByte('}')
+	}
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(value))
+	_ = w.WriteByte('\n')
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
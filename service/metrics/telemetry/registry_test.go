@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterVecWrite(t *testing.T) {
+	counter := &CounterVec{name: "test_events", help: "Test events.", labels: []string{"event"}, series: map[string]*counterSeries{}}
+	counter.Inc("message-created")
+	counter.Inc("message-created")
+	counter.Add(3, `quote"back\slash`)
+	counter.Add(-1, "message-created")
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	counter.write(w)
+	_ = w.Flush()
+
+	want := strings.Join([]string{
+		"# TYPE test_events counter",
+		"# HELP test_events Test events.",
+		`test_events_total{event="message-created"} 2`,
+		`test_events_total{event="quote\"back\\slash"} 3`,
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if counter.Value("message-created") != 2 {
+		t.Fatalf("unexpected counter value: %v", counter.Value("message-created"))
+	}
+}
+
+func TestHistogramVecWrite(t *testing.T) {
+	histogram := &HistogramVec{name: "test_duration_seconds", labels: []string{"format"}, buckets: []float64{1, 10}, series: map[string]*histogramSeries{}}
+	histogram.Observe(0.5, "json")
+	histogram.Observe(5, "json")
+	histogram.Observe(20, "json")
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	histogram.write(w)
+	_ = w.Flush()
+
+	want := strings.Join([]string{
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{format="json",le="1"} 1`,
+		`test_duration_seconds_bucket{format="json",le="10"} 2`,
+		`test_duration_seconds_bucket{format="json",le="+Inf"} 3`,
+		`test_duration_seconds_count{format="json"} 3`,
+		`test_duration_seconds_sum{format="json"} 25.5`,
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteOpenMetricsEndsWithEOF(t *testing.T) {
+	WSEvents.Inc("typing-preview")
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, []Gauge{{Name: "test_online_users", Help: "Online.", Value: 7}}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "# TYPE test_online_users gauge\n# HELP test_online_users Online.\ntest_online_users 7\n") {
+		t.Fatalf("gauge missing:\n%s", out)
+	}
+	if !strings.Contains(out, `sealchat_ws_events_total{event="typing-preview"} 1`) {
+		t.Fatalf("registered counter missing:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Fatalf("output must end with # EOF:\n%s", out)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"sealchat/model"
+)
+
+const notificationSnoozeMaxDuration = 365 * 24 * time.Hour
+
+var (
+	ErrNotificationPreferenceInvalidScope = errors.New("通知设置范围无效")
+	ErrNotificationPreferenceInvalidLevel = errors.New("通知级别无效")
+	ErrNotificationPreferenceInvalidTime  = errors.New("免打扰或静默时段设置无效")
+)
+
+var notificationNow = time.Now
+
+// NotificationPreferenceInput 客户端提交的通知偏好
+type NotificationPreferenceInput struct {
+	ScopeType   string `json:"scopeType"`
+	ScopeID     string `json:"scopeId"`
+	Level       string `json:"level"`       // 空字符串表示继承上级
+	SnoozeUntil int64  `json:"snoozeUntil"` // 毫秒时间戳，0 表示不免打扰
+	QuietHours  struct {
+		Enabled bool `json:"enabled"`
+		Start   int  `json:"start"` // 当天第几分钟
+		End     int  `json:"end"`
+	} `json:"quietHours"`
+	Timezone string `json:"timezone"`
+}
+
+// NormalizeNotificationScope 校验范围类型，全局范围忽略 scopeID
+func NormalizeNotificationScope(scopeType, scopeID string) (string, string, error) {
+	scopeType = strings.TrimSpace(scopeType)
+	scopeID = strings.TrimSpace(scopeID)
+	switch scopeType {
+	case model.NotificationScopeGlobal:
+		return scopeType, "", nil
+	case model.NotificationScopeWorld, model.NotificationScopeChannel:
+		if scopeID == "" {
+			return "", "", ErrNotificationPreferenceInvalidScope
+		}
+		return scopeType, scopeID, nil
+	default:
+		return "", "", ErrNotificationPreferenceInvalidScope
+	}
+}
+
+// BuildNotificationPreference 校验输入并转换为数据模型
+func BuildNotificationPreference(userID string, input *NotificationPreferenceInput) (*model.NotificationPreferenceModel, error) {
+	scopeType, scopeID, err := NormalizeNotificationScope(input.ScopeType, input.ScopeID)
+	if err != nil {
+		return nil, err
+	}
+	level := strings.TrimSpace(input.Level)
+	switch level {
+	case "", model.NotificationLevelAll, model.NotificationLevelMentions, model.NotificationLevelNone:
+	default:
+		return nil, ErrNotificationPreferenceInvalidLevel
+	}
+	if scopeType == model.NotificationScopeGlobal && level == "" {
+		level = model.NotificationLevelAll
+	}
+	item := &model.NotificationPreferenceModel{
+		UserID:    userID,
+		ScopeType: scopeType,
+		ScopeID:   scopeID,
+		Level:     level,
+	}
+	if input.SnoozeUntil > 0 {
+		until := time.UnixMilli(input.SnoozeUntil)
+		now := notificationNow()
+		if !until.After(now) || until.Sub(now) > notificationSnoozeMaxDuration {
+			return nil, ErrNotificationPreferenceInvalidTime
+		}
+		item.SnoozeUntil = &until
+	}
+	if input.QuietHours.Enabled {
+		start, end := input.QuietHours.Start, input.QuietHours.End
+		if start < 0 || start >= 24*60 || end < 0 || end >= 24*60 || start == end {
+			return nil, ErrNotificationPreferenceInvalidTime
+		}
+		item.QuietHoursEnabled = true
+		item.QuietHoursStart = start
+		item.QuietHoursEnd = end
+	}
+	if tz := strings.TrimSpace(input.Timezone); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return nil, ErrNotificationPreferenceInvalidTime
+		}
+		item.Timezone = tz
+	}
+	return item, nil
+}
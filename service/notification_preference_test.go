@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestBuildNotificationPreference(t *testing.T) {
+	input := &NotificationPreferenceInput{ScopeType: "global", ScopeID: "ignored"}
+	item, err := BuildNotificationPreference("u1", input)
+	if err != nil || item.ScopeID != "" || item.Level != model.NotificationLevelAll {
+		t.Fatalf("global scope should default to all: %+v err=%v", item, err)
+	}
+
+	input = &NotificationPreferenceInput{
+		ScopeType:   "channel",
+		ScopeID:     "ch-1",
+		Level:       "mentions",
+		SnoozeUntil: time.Now().Add(time.Hour).UnixMilli(),
+		Timezone:    "Asia/Shanghai",
+	}
+	input.QuietHours.Enabled = true
+	input.QuietHours.Start = 22 * 60
+	input.QuietHours.End = 8 * 60
+	item, err = BuildNotificationPreference("u1", input)
+	if err != nil || item.SnoozeUntil == nil || !item.QuietHoursEnabled || item.QuietHoursEnd != 480 {
+		t.Fatalf("unexpected preference: %+v err=%v", item, err)
+	}
+
+	invalid := []*NotificationPreferenceInput{
+		{ScopeType: "guild", ScopeID: "x"},
+		{ScopeType: "world"},
+		{ScopeType: "channel", ScopeID: "ch", Level: "loud"},
+		{ScopeType: "channel", ScopeID: "ch", SnoozeUntil: time.Now().Add(-time.Minute).UnixMilli()},
+		{ScopeType: "channel", ScopeID: "ch", Timezone: "Mars/Olympus"},
+	}
+	quiet := &NotificationPreferenceInput{ScopeType: "global"}
+	quiet.QuietHours.Enabled = true
+	quiet.QuietHours.Start = 60
+	quiet.QuietHours.End = 60
+	invalid = append(invalid, quiet)
+	for i, item := range invalid {
+		if _, err := BuildNotificationPreference("u1", item); err == nil {
+			t.Fatalf("case %d should be rejected", i)
+		}
+	}
+}
+
+func TestUnreadEmailMentionsOnlyFilter(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+	base := time.Now().Add(-time.Hour)
+	create := func(id, userID, whisperTo, quoteID string, offset time.Duration) {
+		at := base.Add(offset)
+		msg := &model.MessageModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id, CreatedAt: at, UpdatedAt: at},
+			ChannelID:         "ch-mail",
+			UserID:            userID,
+			Content:           id,
+			WhisperTo:         whisperTo,
+			QuoteID:           quoteID,
+		}
+		if err := db.Create(msg).Error; err != nil {
+			t.Fatalf("create message failed: %v", err)
+		}
+	}
+	create("m-own", "u-me", "", "", 0)
+	create("m-plain", "u-other", "", "", time.Minute)
+	create("m-mention", "u-other", "", "", 2*time.Minute)
+	create("m-whisper", "u-other", "u-me", "", 3*time.Minute)
+	create("m-reply", "u-other", "", "m-own", 4*time.Minute)
+	if err := db.Create(&model.MentionModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		ReceiverId:        "u-me",
+		SenderId:          "u-other",
+		LocPostType:       "channel",
+		LocPostID:         "ch-mail",
+		RelatedType:       "message",
+		RelatedID:         "m-mention",
+	}).Error; err != nil {
+		t.Fatalf("create mention failed: %v", err)
+	}
+
+	cutoff := time.Now().UnixMilli()
+	all, err := getUnreadMessagesForNotification("ch-mail", "u-me", 0, 0, cutoff, false)
+	if err != nil || len(all) != 4 {
+		t.Fatalf("expected 4 unread messages, got %d err=%v", len(all), err)
+	}
+	direct, err := getUnreadMessagesForNotification("ch-mail", "u-me", 0, 0, cutoff, true)
+	if err != nil || len(direct) != 3 {
+		t.Fatalf("expected 3 direct messages, got %d err=%v", len(direct), err)
+	}
+	for _, item := range direct {
+		if item.Content == "m-plain" {
+			t.Fatalf("plain message should be filtered in mentions-only mode")
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+const (
+	oidcMetadataTTL      = time.Hour
+	oidcKeysRefreshAfter = time.Minute // 遇到未知 kid 时最短的 JWKS 刷新间隔
+	oidcClockLeeway      = time.Minute
+	oidcMaxResponseSize  = 1 << 20
+	oidcUsernameMaxLen   = 32
+)
+
+var (
+	ErrOIDCDisabled        = errors.New("未启用单点登录")
+	ErrOIDCNotConfigured   = errors.New("单点登录配置不完整")
+	ErrOIDCSignupDisabled  = errors.New("该身份尚未关联本站账号，请先登录后在个人设置中绑定")
+	ErrOIDCIdentityInUse   = errors.New("该身份已关联其他账号")
+	ErrOIDCTokenInvalid    = errors.New("身份提供方返回的凭证无效")
+	ErrOIDCUserUnavailable = errors.New("关联的账号不可用")
+)
+
+var oidcSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+var oidcUsernameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// oidcHTTPClient 访问身份提供方使用的客户端，测试中可替换
+var oidcHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// oidcAppConfig 读取当前配置，测试中可替换
+var oidcAppConfig = utils.GetConfig
+
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProviderCache struct {
+	mu        sync.Mutex
+	issuer    string
+	metadata  *oidcProviderMetadata
+	fetchedAt time.Time
+	keys      *jose.JSONWebKeySet
+	keysAt    time.Time
+}
+
+var oidcProvider = &oidcProviderCache{}
+
+// OIDCLoginResult 回调处理结果
+type OIDCLoginResult struct {
+	User         *model.UserModel
+	RedirectPath string
+	Linked       bool // 本次为已登录用户的绑定操作
+	Created      bool // 本次自动创建了新账号
+}
+
+type oidcClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Nickname      string
+	Picture       string
+}
+
+func oidcConfig() (utils.OIDCConfig, error) {
+	cfg := oidcAppConfig()
+	if cfg == nil || !cfg.OIDC.Enabled {
+		return utils.OIDCConfig{}, ErrOIDCDisabled
+	}
+	oc := cfg.OIDC
+	oc.Issuer = strings.TrimRight(strings.TrimSpace(oc.Issuer), "/")
+	oc.ClientID = strings.TrimSpace(oc.ClientID)
+	if oc.Issuer == "" || oc.ClientID == "" {
+		return oc, ErrOIDCNotConfigured
+	}
+	return oc, nil
+}
+
+// OIDCEnabled 单点登录是否可用
+func OIDCEnabled() bool {
+	_, err := oidcConfig()
+	return err == nil
+}
+
+// OIDCResolveRedirectURL 优先使用配置中的回调地址，否则使用调用方推导的地址
+func OIDCResolveRedirectURL(fallback string) string {
+	if cfg := oidcAppConfig(); cfg != nil && strings.TrimSpace(cfg.OIDC.RedirectURL) != "" {
+		return strings.TrimSpace(cfg.OIDC.RedirectURL)
+	}
+	return fallback
+}
+
+// OIDCBuildAuthURL 生成授权地址；linkUserID 非空时回调会把身份绑定到该用户
+func OIDCBuildAuthURL(redirectURI string, redirectPath string, linkUserID string) (string, error) {
+	cfg, err := oidcConfig()
+	if err != nil {
+		return "", err
+	}
+	meta, err := oidcProvider.discover(cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidcRandomString(48)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidcRandomString(24)
+	if err != nil {
+		return "", err
+	}
+	state, err := model.OIDCLoginStateCreate(nonce, verifier, sanitizeOIDCRedirectPath(redirectPath), linkUserID)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("授权地址无效: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(oidcScopes(cfg), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// OIDCHandleCallback 校验回调参数、兑换令牌并解析为本地用户
+func OIDCHandleCallback(ctx context.Context, code string, state string, redirectURI string) (*OIDCLoginResult, error) {
+	cfg, err := oidcConfig()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(state) == "" || strings.TrimSpace(code) == "" {
+		return nil, model.ErrOIDCStateInvalid
+	}
+	loginState, err := model.OIDCLoginStateConsume(state)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := oidcProvider.discover(cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	idToken, accessToken, err := oidcExchangeCode(ctx, cfg, meta, code, loginState.CodeVerifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := oidcProvider.verifyIDToken(cfg, meta, idToken, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if accessToken != "" && meta.UserinfoEndpoint != "" {
+		if err := oidcMergeUserinfo(ctx, meta.UserinfoEndpoint, accessToken, claims); err != nil {
+			log.Printf("[oidc] 获取 userinfo 失败: %v", err)
+		}
+	}
+
+	result := &OIDCLoginResult{RedirectPath: loginState.RedirectPath}
+	identity, err := model.UserOIDCIdentityGet(meta.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if loginState.LinkUserID != "" {
+		if identity != nil && identity.UserID != loginState.LinkUserID {
+			return nil, ErrOIDCIdentityInUse
+		}
+		if identity == nil {
+			if _, err := model.UserOIDCIdentityCreate(loginState.LinkUserID, meta.Issuer, claims.Subject, claims.Email); err != nil {
+				return nil, err
+			}
+		}
+		result.User = model.UserGet(loginState.LinkUserID)
+		result.Linked = true
+		if result.User == nil {
+			return nil, ErrOIDCUserUnavailable
+		}
+		return result, nil
+	}
+
+	if identity != nil {
+		user := model.UserGet(identity.UserID)
+		if user == nil || user.DeletedAt != nil || user.Disabled {
+			return nil, ErrOIDCUserUnavailable
+		}
+		_ = model.UserOIDCIdentityTouch(identity.ID, claims.Email)
+		result.User = user
+		return result, nil
+	}
+
+	if cfg.LinkByEmail && claims.EmailVerified && claims.Email != "" {
+		user, err := model.UserGetByEmail(strings.ToLower(claims.Email))
+		if err != nil {
+			return nil, err
+		}
+		// 仅在本地邮箱同样已验证时才自动关联，防止借他人未验证的邮箱接管账号
+		if user != nil && user.EmailVerified && user.DeletedAt == nil && !user.Disabled && !user.IsBot {
+			if _, err := model.UserOIDCIdentityCreate(user.ID, meta.Issuer, claims.Subject, claims.Email); err != nil {
+				return nil, err
+			}
+			result.User = user
+			return result, nil
+		}
+	}
+
+	if !cfg.AllowSignup {
+		return nil, ErrOIDCSignupDisabled
+	}
+	user, err := oidcProvisionUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := model.UserOIDCIdentityCreate(user.ID, meta.Issuer, claims.Subject, claims.Email); err != nil {
+		return nil, err
+	}
+	result.User = user
+	result.Created = true
+	return result, nil
+}
+
+// OIDCUnlink 解除当前用户的某个外部身份关联
+func OIDCUnlink(userID string, identityID string) error {
+	removed, err := model.UserOIDCIdentityDelete(userID, identityID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return errors.New("关联不存在")
+	}
+	return nil
+}
+
+func oidcScopes(cfg utils.OIDCConfig) []string {
+	scopes := []string{"openid"}
+	extra := cfg.Scopes
+	if len(extra) == 0 {
+		extra = []string{"profile", "email"}
+	}
+	for _, scope := range extra {
+		scope = strings.TrimSpace(scope)
+		if scope != "" && scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// sanitizeOIDCRedirectPath 只允许站内前端路由，防止开放重定向
+func sanitizeOIDCRedirectPath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" || !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.Contains(p, "\\") {
+		return ""
+	}
+	if len(p) > 512 {
+		return ""
+	}
+	return p
+}
+
+func oidcRandomString(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func oidcGetJSON(ctx context.Context, endpoint string, bearer string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 失败: HTTP %d", endpoint, resp.StatusCode)
+	}
+	return json.Unmarshal(body, dest)
+}
+
+func (p *oidcProviderCache) discover(issuer string) (*oidcProviderMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.issuer == issuer && p.metadata != nil && time.Since(p.fetchedAt) < oidcMetadataTTL {
+		return p.metadata, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var meta oidcProviderMetadata
+	if err := oidcGetJSON(ctx, issuer+"/.well-known/openid-configuration", "", &meta); err != nil {
+		return nil, fmt.Errorf("获取身份提供方配置失败: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("身份提供方 issuer 不匹配: %s", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, ErrOIDCNotConfigured
+	}
+	if p.issuer != issuer {
+		p.keys = nil
+	}
+	p.issuer = issuer
+	p.metadata = &meta
+	p.fetchedAt = time.Now()
+	return p.metadata, nil
+}
+
+func (p *oidcProviderCache) lookupKeys(meta *oidcProviderMetadata, kid string) ([]jose.JSONWebKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	find := func() []jose.JSONWebKey {
+		if p.keys == nil {
+			return nil
+		}
+		if kid != "" {
+			return p.keys.Key(kid)
+		}
+		return p.keys.Keys
+	}
+	if keys := find(); len(keys) > 0 {
+		return keys, nil
+	}
+	if p.keys != nil && time.Since(p.keysAt) < oidcKeysRefreshAfter {
+		return nil, ErrOIDCTokenInvalid
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var set jose.JSONWebKeySet
+	if err := oidcGetJSON(ctx, meta.JWKSURI, "", &set); err != nil {
+		return nil, fmt.Errorf("获取签名公钥失败: %w", err)
+	}
+	p.keys = &set
+	p.keysAt = time.Now()
+	return find(), nil
+}
+
+func oidcExchangeCode(ctx context.Context, cfg utils.OIDCConfig, meta *oidcProviderMetadata, code string, verifier string, redirectURI string) (string, string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	if secret := strings.TrimSpace(cfg.ClientSecret); secret != "" {
+		form.Set("client_secret", secret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("兑换令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return "", "", err
+	}
+	var payload struct {
+		IDToken          string `json:"id_token"`
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	if resp.StatusCode != http.StatusOK || payload.Error != "" {
+		return "", "", fmt.Errorf("兑换令牌失败: HTTP %d %s %s", resp.StatusCode, payload.Error, payload.ErrorDescription)
+	}
+	if payload.IDToken == "" {
+		return "", "", ErrOIDCTokenInvalid
+	}
+	return payload.IDToken, payload.AccessToken, nil
+}
+
+func (p *oidcProviderCache) verifyIDToken(cfg utils.OIDCConfig, meta *oidcProviderMetadata, raw string, nonce string) (*oidcClaims, error) {
+	tok, err := jwt.ParseSigned(raw, oidcSignatureAlgorithms)
+	if err != nil || len(tok.Headers) == 0 {
+		return nil, ErrOIDCTokenInvalid
+	}
+	keys, err := p.lookupKeys(meta, tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+	var std jwt.Claims
+	var extra map[string]any
+	verified := false
+	for _, key := range keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if err := tok.Claims(key.Key, &std, &extra); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrOIDCTokenInvalid
+	}
+	if std.Expiry == nil || std.Subject == "" {
+		return nil, ErrOIDCTokenInvalid
+	}
+	if err := std.ValidateWithLeeway(jwt.Expected{
+		Issuer:      meta.Issuer,
+		AnyAudience: jwt.Audience{cfg.ClientID},
+		Time:        time.Now(),
+	}, oidcClockLeeway); err != nil {
+		return nil, ErrOIDCTokenInvalid
+	}
+	if len(std.Audience) > 1 {
+		if azp, _ := extra["azp"].(string); azp != cfg.ClientID {
+			return nil, ErrOIDCTokenInvalid
+		}
+	}
+	if got, _ := extra["nonce"].(string); got != nonce {
+		return nil, ErrOIDCTokenInvalid
+	}
+	claims := &oidcClaims{Subject: std.Subject}
+	applyOIDCClaims(cfg, claims, extra)
+	return claims, nil
+}
+
+func oidcMergeUserinfo(ctx context.Context, endpoint string, accessToken string, claims *oidcClaims) error {
+	var info map[string]any
+	if err := oidcGetJSON(ctx, endpoint, accessToken, &info); err != nil {
+		return err
+	}
+	// userinfo 的 sub 必须与 ID Token 一致，否则丢弃
+	if sub, _ := info["sub"].(string); sub != claims.Subject {
+		return errors.New("userinfo sub 与 ID Token 不一致")
+	}
+	cfg, _ := oidcConfig()
+	applyOIDCClaims(cfg, claims, info)
+	return nil
+}
+
+func applyOIDCClaims(cfg utils.OIDCConfig, claims *oidcClaims, raw map[string]any) {
+	str := func(key string) string {
+		v, _ := raw[key].(string)
+		return strings.TrimSpace(v)
+	}
+	claimName := func(configured string, fallback string) string {
+		if strings.TrimSpace(configured) != "" {
+			return strings.TrimSpace(configured)
+		}
+		return fallback
+	}
+	if v := str("email"); v != "" {
+		claims.Email = v
+		switch verified := raw["email_verified"].(type) {
+		case bool:
+			claims.EmailVerified = verified
+		case string:
+			claims.EmailVerified = verified == "true"
+		}
+	}
+	if v := str(claimName(cfg.UsernameClaim, "preferred_username")); v != "" {
+		claims.Username = v
+	}
+	if v := str(claimName(cfg.NicknameClaim, "name")); v != "" {
+		claims.Nickname = v
+	} else if claims.Nickname == "" {
+		if v := str("nickname"); v != "" {
+			claims.Nickname = v
+		}
+	}
+	if v := str(claimName(cfg.AvatarClaim, "picture")); v != "" {
+		claims.Picture = v
+	}
+}
+
+// oidcProvisionUser 按声明创建新账号，与普通注册一致地分配系统角色并加入默认世界
+func oidcProvisionUser(claims *oidcClaims) (*model.UserModel, error) {
+	username, err := oidcAvailableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	nickname := claims.Nickname
+	if nickname == "" {
+		nickname = username
+	}
+	if len([]rune(nickname)) > 64 {
+		nickname = string([]rune(nickname)[:64])
+	}
+	password, err := oidcRandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	count := model.UserCount()
+	user, err := model.UserCreate(username, password, nickname)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		_, _ = UserRoleLink([]string{"sys-admin"}, []string{user.ID})
+		if _, err := BootstrapDefaultWorldForOwner(user.ID); err != nil {
+			log.Printf("初始化默认世界失败: %v", err)
+		}
+	} else {
+		_, _ = UserRoleLink([]string{"sys-user"}, []string{user.ID})
+		if world, err := GetOrCreateDefaultWorld(); err == nil {
+			_, _ = WorldJoin(world.ID, user.ID, model.WorldRoleMember)
+		}
+	}
+
+	if claims.Picture != "" && GetStorageManager() != nil {
+		att, err := ImportAttachmentFromURL(RemoteAttachmentImportInput{
+			URL:          claims.Picture,
+			UserID:       user.ID,
+			ChannelID:    "user-avatar",
+			MaxSizeBytes: 5 * 1024 * 1024,
+		})
+		if err != nil {
+			log.Printf("[oidc] 导入头像失败: %v", err)
+		} else if att != nil {
+			user.Avatar = "id:" + att.ID
+			user.SaveAvatar()
+		}
+	}
+	return user, nil
+}
+
+func oidcAvailableUsername(claims *oidcClaims) (string, error) {
+	base := claims.Username
+	if base == "" && claims.Email != "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = strings.Trim(oidcUsernameSanitizer.ReplaceAllString(base, "_"), "_.-")
+	if len(base) > oidcUsernameMaxLen-4 {
+		base = base[:oidcUsernameMaxLen-4]
+	}
+	if len(base) < 2 {
+		sum := sha256.Sum256([]byte(claims.Subject))
+		base = "sso_" + hex.EncodeToString(sum[:4])
+	}
+	for i := 0; i < 100; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s_%d", base, i+1)
+		}
+		existing, err := model.UserGetByUsername(candidate)
+		if err != nil && !strings.Contains(err.Error(), "不存在") {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("无法生成可用的用户名")
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+type mockOIDCProvider struct {
+	t        *testing.T
+	server   *httptest.Server
+	signer   jose.Signer
+	jwks     jose.JSONWebKeySet
+	mu       sync.Mutex
+	pending  map[string]mockOIDCAuthRequest
+	subject  string
+	email    string
+	verified bool
+	username string
+	audience string
+}
+
+type mockOIDCAuthRequest struct {
+	nonce     string
+	challenge string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test-key"))
+	if err != nil {
+		t.Fatalf("create signer failed: %v", err)
+	}
+	p := &mockOIDCProvider{
+		t:       t,
+		signer:  signer,
+		jwks:    jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"}}},
+		pending: map[string]mockOIDCAuthRequest{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"userinfo_endpoint":      p.server.URL + "/userinfo",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(p.jwks)
+	})
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"sub": p.subject, "name": "Userinfo Name"})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize 模拟用户在提供方处同意授权，返回回调所需的 code 与 state
+func (p *mockOIDCProvider) authorize(authURL string) (string, string) {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("parse auth url failed: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" {
+		p.t.Fatalf("auth url missing pkce or nonce: %s", authURL)
+	}
+	code := utils.NewID()
+	p.mu.Lock()
+	p.pending[code] = mockOIDCAuthRequest{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	p.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (p *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	p.mu.Lock()
+	req, ok := p.pending[r.PostForm.Get("code")]
+	delete(p.pending, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	audience := p.audience
+	if audience == "" {
+		audience = "sealchat"
+	}
+	now := time.Now()
+	raw, err := jwt.Signed(p.signer).Claims(jwt.Claims{
+		Issuer:   p.server.URL,
+		Subject:  p.subject,
+		Audience: jwt.Audience{audience},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}).Claims(map[string]any{
+		"nonce":              req.nonce,
+		"email":              p.email,
+		"email_verified":     p.verified,
+		"preferred_username": p.username,
+	}).Serialize()
+	if err != nil {
+		p.t.Errorf("sign id token failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access-" + p.subject,
+		"id_token":     raw,
+		"token_type":   "Bearer",
+	})
+}
+
+func (p *mockOIDCProvider) login(t *testing.T, linkUserID string) (*OIDCLoginResult, error) {
+	t.Helper()
+	authURL, err := OIDCBuildAuthURL("http://chat.test/api/v1/oidc/callback", "/chat", linkUserID)
+	if err != nil {
+		t.Fatalf("build auth url failed: %v", err)
+	}
+	code, state := p.authorize(authURL)
+	return OIDCHandleCallback(t.Context(), code, state, "http://chat.test/api/v1/oidc/callback")
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	provider := newMockOIDCProvider(t)
+
+	cfg := &utils.AppConfig{OIDC: utils.OIDCConfig{
+		Enabled:  true,
+		Issuer:   provider.server.URL,
+		ClientID: "sealchat",
+	}}
+	oldConfig := oidcAppConfig
+	oidcAppConfig = func() *utils.AppConfig { return cfg }
+	oidcProvider = &oidcProviderCache{}
+	t.Cleanup(func() {
+		oidcAppConfig = oldConfig
+		oidcProvider = &oidcProviderCache{}
+	})
+
+	provider.subject = "sub-alice"
+	provider.username = "alice"
+	provider.email = "alice@example.com"
+	provider.verified = true
+
+	if _, err := provider.login(t, ""); err != ErrOIDCSignupDisabled {
+		t.Fatalf("expected signup disabled error, got %v", err)
+	}
+
+	cfg.OIDC.AllowSignup = true
+	result, err := provider.login(t, "")
+	if err != nil {
+		t.Fatalf("signup login failed: %v", err)
+	}
+	if !result.Created || result.User.Username != "alice" || result.User.Nickname != "Userinfo Name" || result.RedirectPath != "/chat" {
+		t.Fatalf("unexpected signup result: %+v user=%+v", result, result.User)
+	}
+	aliceID := result.User.ID
+
+	result, err = provider.login(t, "")
+	if err != nil || result.Created || result.User.ID != aliceID {
+		t.Fatalf("expected existing identity to log in, got %+v err=%v", result, err)
+	}
+
+	// 回调 state 只能使用一次
+	authURL, _ := OIDCBuildAuthURL("http://chat.test/cb", "", "")
+	code, state := provider.authorize(authURL)
+	if _, err := OIDCHandleCallback(t.Context(), code, state, "http://chat.test/cb"); err != nil {
+		t.Fatalf("callback failed: %v", err)
+	}
+	if _, err := OIDCHandleCallback(t.Context(), code, state, "http://chat.test/cb"); err != model.ErrOIDCStateInvalid {
+		t.Fatalf("expected replayed state to be rejected, got %v", err)
+	}
+
+	// 受众不匹配的 ID Token 必须拒绝
+	provider.audience = "other-client"
+	if _, err := provider.login(t, ""); err != ErrOIDCTokenInvalid {
+		t.Fatalf("expected audience mismatch to be rejected, got %v", err)
+	}
+	provider.audience = ""
+
+	// 同名用户名被占用时自动追加后缀
+	provider.subject = "sub-alice-2"
+	provider.email = ""
+	result, err = provider.login(t, "")
+	if err != nil || result.User.Username != "alice_2" {
+		t.Fatalf("expected suffixed username, got %+v err=%v", result, err)
+	}
+
+	// 按已验证邮箱关联已有账号
+	db := model.GetDB()
+	bob, err := model.UserCreate("bob", "pw-bob-123", "Bob")
+	if err != nil {
+		t.Fatalf("create bob failed: %v", err)
+	}
+	if err := db.Model(&model.UserModel{}).Where("id = ?", bob.ID).
+		Updates(map[string]any{"email": "bob@example.com", "email_verified": true}).Error; err != nil {
+		t.Fatalf("update bob email failed: %v", err)
+	}
+	cfg.OIDC.LinkByEmail = true
+	provider.subject = "sub-bob"
+	provider.username = "bobby"
+	provider.email = "bob@example.com"
+	provider.verified = false
+	result, err = provider.login(t, "")
+	if err != nil || result.User.ID == bob.ID {
+		t.Fatalf("unverified provider email must not link existing account, got %+v err=%v", result, err)
+	}
+	provider.subject = "sub-bob-verified"
+	provider.verified = true
+	result, err = provider.login(t, "")
+	if err != nil || result.User.ID != bob.ID || result.Created {
+		t.Fatalf("expected verified email to link bob, got %+v err=%v", result, err)
+	}
+
+	// 已登录用户绑定：已被他人关联的身份不能再次绑定
+	provider.subject = "sub-alice"
+	if _, err := provider.login(t, bob.ID); err != ErrOIDCIdentityInUse {
+		t.Fatalf("expected identity in use error, got %v", err)
+	}
+	provider.subject = "sub-bob-second"
+	result, err = provider.login(t, bob.ID)
+	if err != nil || !result.Linked || result.User.ID != bob.ID {
+		t.Fatalf("expected link to bob, got %+v err=%v", result, err)
+	}
+	items, err := model.UserOIDCIdentityListByUser(bob.ID)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("expected 2 identities for bob, got %d err=%v", len(items), err)
+	}
+	if err := OIDCUnlink(aliceID, items[0].ID); err == nil {
+		t.Fatalf("expected unlink of foreign identity to fail")
+	}
+	if err := OIDCUnlink(bob.ID, items[0].ID); err != nil {
+		t.Fatalf("unlink failed: %v", err)
+	}
+
+	ticket, err := model.OIDCLoginTicketCreate(bob.ID)
+	if err != nil {
+		t.Fatalf("create ticket failed: %v", err)
+	}
+	if userID, err := model.OIDCLoginTicketConsume(ticket); err != nil || userID != bob.ID {
+		t.Fatalf("consume ticket failed: %s err=%v", userID, err)
+	}
+	if _, err := model.OIDCLoginTicketConsume(ticket); err != model.ErrOIDCTicketInvalid {
+		t.Fatalf("expected consumed ticket to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sealchat/model"
+)
+
+const (
+	WidgetTypePoll = "poll"
+
+	WidgetOperationPollVote  = "poll_vote"
+	WidgetOperationPollClose = "poll_close"
+
+	pollQuestionMaxLen = 200
+	pollOptionMaxLen   = 100
+	pollOptionMin      = 2
+	pollOptionMax      = 10
+	// 投票截止时间最长可设置的范围
+	pollMaxDuration = 30 * 24 * time.Hour
+
+	PollWorkerInterval = 15 * time.Second
+)
+
+var (
+	ErrPollNotFound   = errors.New("投票不存在")
+	ErrPollClosed     = errors.New("投票已结束")
+	ErrPollPermission = errors.New("只有发起人或世界管理员可以结束投票")
+)
+
+// PollCreateInput 发起投票的参数，ClosesAt 为毫秒时间戳，0 表示不自动截止
+type PollCreateInput struct {
+	Question  string   `json:"question"`
+	Options   []string `json:"options"`
+	Multiple  bool     `json:"multiple"`
+	Anonymous bool     `json:"anonymous"`
+	ClosesAt  int64    `json:"closes_at"`
+}
+
+// PollVoter 实名投票时记录的投票人
+type PollVoter struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+}
+
+type PollOptionState struct {
+	Text   string      `json:"text"`
+	Count  int         `json:"count"`
+	Voters []PollVoter `json:"voters,omitempty"`
+}
+
+// PollWidgetState 投票在 WidgetData 中的快照，匿名投票不包含投票人
+type PollWidgetState struct {
+	Question    string            `json:"question"`
+	Options     []PollOptionState `json:"options"`
+	Multiple    bool              `json:"multiple"`
+	Anonymous   bool              `json:"anonymous"`
+	ClosesAt    int64             `json:"closesAt,omitempty"`
+	Closed      bool              `json:"closed"`
+	ClosedAt    int64             `json:"closedAt,omitempty"`
+	TotalVoters int               `json:"totalVoters"`
+}
+
+// PollClosedNotifyFunc 由 API 层提供，用于在投票自动截止后广播结果
+type PollClosedNotifyFunc func(messageID string)
+
+var (
+	pollWorkerOnce sync.Once
+	pollNow        = time.Now
+)
+
+// NormalizePollInput 清理并校验投票参数
+func NormalizePollInput(input *PollCreateInput) error {
+	if input == nil {
+		return errors.New("投票参数不能为空")
+	}
+	input.Question = strings.TrimSpace(input.Question)
+	if input.Question == "" {
+		return errors.New("投票问题不能为空")
+	}
+	if utf8.RuneCountInString(input.Question) > pollQuestionMaxLen {
+		return fmt.Errorf("投票问题不能超过 %d 个字符", pollQuestionMaxLen)
+	}
+	options := make([]string, 0, len(input.Options))
+	seen := map[string]struct{}{}
+	for _, option := range input.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		if utf8.RuneCountInString(option) > pollOptionMaxLen {
+			return fmt.Errorf("投票选项不能超过 %d 个字符", pollOptionMaxLen)
+		}
+		if _, ok := seen[option]; ok {
+			return fmt.Errorf("投票选项重复：%s", option)
+		}
+		seen[option] = struct{}{}
+		options = append(options, option)
+	}
+	if len(options) < pollOptionMin || len(options) > pollOptionMax {
+		return fmt.Errorf("投票选项数量需在 %d 到 %d 之间", pollOptionMin, pollOptionMax)
+	}
+	input.Options = options
+	if input.ClosesAt > 0 {
+		closesAt := time.UnixMilli(input.ClosesAt)
+		now := pollNow()
+		if !closesAt.After(now) {
+			return errors.New("截止时间必须晚于当前时间")
+		}
+		if closesAt.Sub(now) > pollMaxDuration {
+			return errors.New("截止时间不能超过 30 天")
+		}
+	} else {
+		input.ClosesAt = 0
+	}
+	return nil
+}
+
+// BuildPollWidgetData 构造新投票的 WidgetData，调用前需先通过 NormalizePollInput
+func BuildPollWidgetData(input *PollCreateInput) string {
+	state := &PollWidgetState{
+		Question:  input.Question,
+		Options:   make([]PollOptionState, len(input.Options)),
+		Multiple:  input.Multiple,
+		Anonymous: input.Anonymous,
+		ClosesAt:  input.ClosesAt,
+	}
+	for i, option := range input.Options {
+		state.Options[i] = PollOptionState{Text: option}
+	}
+	return marshalStateWidgetEntries([]StateWidgetEntry{newPollWidgetEntry(state)})
+}
+
+// NewPollModel 根据投票参数生成与消息关联的投票记录
+func NewPollModel(input *PollCreateInput, messageID, channelID, userID string) *model.PollModel {
+	item := &model.PollModel{
+		MessageID: messageID,
+		ChannelID: channelID,
+		UserID:    userID,
+		Multiple:  input.Multiple,
+		Anonymous: input.Anonymous,
+	}
+	if input.ClosesAt > 0 {
+		closesAt := time.UnixMilli(input.ClosesAt)
+		item.ClosesAt = &closesAt
+	}
+	return item
+}
+
+func newPollWidgetEntry(state *PollWidgetState) StateWidgetEntry {
+	texts := make([]string, len(state.Options))
+	for i, option := range state.Options {
+		texts[i] = option.Text
+	}
+	return StateWidgetEntry{
+		Type:    WidgetTypePoll,
+		Options: texts,
+		Poll:    state,
+	}
+}
+
+// ParsePollWidget 从 WidgetData 中取出投票快照，没有投票时返回 nil
+func ParsePollWidget(widgetData string) *PollWidgetState {
+	if strings.TrimSpace(widgetData) == "" {
+		return nil
+	}
+	var entries []StateWidgetEntry
+	if err := json.Unmarshal([]byte(widgetData), &entries); err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Type == WidgetTypePoll && entry.Poll != nil {
+			return entry.Poll
+		}
+	}
+	return nil
+}
+
+// PollVote 用 optionIndexes 覆盖用户的投票，传空数组即撤回投票，返回更新后的 WidgetData
+func PollVote(messageID, userID string, optionIndexes []int) (string, error) {
+	var widgetData string
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		msg, poll, state, err := loadPollForUpdate(tx, messageID)
+		if err != nil {
+			return err
+		}
+		if poll.ClosedAt != nil || state.Closed {
+			return ErrPollClosed
+		}
+		if poll.ClosesAt != nil && !poll.ClosesAt.After(pollNow()) {
+			return ErrPollClosed
+		}
+
+		indexes, err := normalizePollVoteIndexes(optionIndexes, len(state.Options), poll.Multiple)
+		if err != nil {
+			return err
+		}
+		if err := model.PollVoteReplace(tx, messageID, userID, indexes); err != nil {
+			return err
+		}
+		widgetData, err = rebuildPollWidgetData(tx, msg.WidgetData, poll, state)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.MessageModel{}).Where("id = ?", messageID).UpdateColumn("widget_data", widgetData).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return widgetData, nil
+}
+
+// PollClose 结束投票。仅发起人或世界管理员可操作，已结束时 changed 为 false
+func PollClose(messageID, userID string) (string, bool, error) {
+	return pollClose(messageID, userID, false)
+}
+
+func pollClose(messageID, userID string, system bool) (string, bool, error) {
+	if !system {
+		// 权限检查放在事务外，避免 SQLite 单连接下事务内再开查询
+		poll, err := model.PollGetByMessageID(nil, messageID)
+		if err != nil {
+			return "", false, err
+		}
+		if poll == nil {
+			return "", false, ErrPollNotFound
+		}
+		if poll.UserID != userID {
+			channel, _ := model.ChannelGet(poll.ChannelID)
+			if channel == nil || channel.WorldID == "" || !IsWorldAdmin(channel.WorldID, userID) {
+				return "", false, ErrPollPermission
+			}
+		}
+	}
+
+	var widgetData string
+	var changed bool
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		msg, poll, state, err := loadPollForUpdate(tx, messageID)
+		if err != nil {
+			return err
+		}
+		if poll.ClosedAt != nil {
+			widgetData = msg.WidgetData
+			return nil
+		}
+
+		now := pollNow()
+		poll.ClosedAt = &now
+		poll.ClosedBy = userID
+		if err := tx.Model(&model.PollModel{}).Where("id = ?", poll.ID).Updates(map[string]any{
+			"closed_at": now,
+			"closed_by": userID,
+		}).Error; err != nil {
+			return err
+		}
+		widgetData, err = rebuildPollWidgetData(tx, msg.WidgetData, poll, state)
+		if err != nil {
+			return err
+		}
+		changed = true
+		return tx.Model(&model.MessageModel{}).Where("id = ?", messageID).UpdateColumn("widget_data", widgetData).Error
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return widgetData, changed, nil
+}
+
+// PollMyVotes 查询用户在多条投票消息中已选择的选项
+func PollMyVotes(messageIDs []string, userID string) (map[string][]int, error) {
+	return model.PollVoteMapByUser(messageIDs, userID)
+}
+
+func loadPollForUpdate(tx *gorm.DB, messageID string) (*model.MessageModel, *model.PollModel, *PollWidgetState, error) {
+	query := tx
+	if !model.IsSQLite() {
+		query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var msg model.MessageModel
+	if err := query.Select("id, widget_data, is_deleted, is_revoked").
+		Where("id = ?", messageID).Limit(1).Find(&msg).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	if msg.ID == "" || msg.IsDeleted || msg.IsRevoked {
+		return nil, nil, nil, ErrPollNotFound
+	}
+	state := ParsePollWidget(msg.WidgetData)
+	if state == nil {
+		return nil, nil, nil, ErrPollNotFound
+	}
+	poll, err := model.PollGetByMessageID(tx, messageID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if poll == nil {
+		return nil, nil, nil, ErrPollNotFound
+	}
+	return &msg, poll, state, nil
+}
+
+func normalizePollVoteIndexes(indexes []int, optionCount int, multiple bool) ([]int, error) {
+	seen := map[int]struct{}{}
+	result := make([]int, 0, len(indexes))
+	for _, idx := range indexes {
+		if idx < 0 || idx >= optionCount {
+			return nil, fmt.Errorf("投票选项 %d 不存在", idx)
+		}
+		if _, ok := seen[idx]; ok {
+			continue
+		}
+		seen[idx] = struct{}{}
+		result = append(result, idx)
+	}
+	if !multiple && len(result) > 1 {
+		return nil, errors.New("该投票只能选择一个选项")
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+// rebuildPollWidgetData 根据投票记录重新统计结果并替换 WidgetData 中的投票快照
+func rebuildPollWidgetData(tx *gorm.DB, widgetData string, poll *model.PollModel, state *PollWidgetState) (string, error) {
+	votes, err := model.PollVoteList(tx, poll.MessageID)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range state.Options {
+		state.Options[i].Count = 0
+		state.Options[i].Voters = nil
+	}
+	voterNames := map[string]string{}
+	voters := map[string]struct{}{}
+	for _, vote := range votes {
+		if vote.OptionIndex < 0 || vote.OptionIndex >= len(state.Options) {
+			continue
+		}
+		option := &state.Options[vote.OptionIndex]
+		option.Count++
+		voters[vote.UserID] = struct{}{}
+		if poll.Anonymous {
+			continue
+		}
+		name, ok := voterNames[vote.UserID]
+		if !ok {
+			var user model.UserModel
+			if err := tx.Select("id, username, nickname").Where("id = ?", vote.UserID).Limit(1).Find(&user).Error; err == nil {
+				name = user.Nickname
+				if name == "" {
+					name = user.Username
+				}
+			}
+			voterNames[vote.UserID] = name
+		}
+		option.Voters = append(option.Voters, PollVoter{UserID: vote.UserID, Name: name})
+	}
+	state.TotalVoters = len(voters)
+	state.Multiple = poll.Multiple
+	state.Anonymous = poll.Anonymous
+	if poll.ClosesAt != nil {
+		state.ClosesAt = poll.ClosesAt.UnixMilli()
+	}
+	if poll.ClosedAt != nil {
+		state.Closed = true
+		state.ClosedAt = poll.ClosedAt.UnixMilli()
+	}
+
+	var entries []StateWidgetEntry
+	if err := json.Unmarshal([]byte(widgetData), &entries); err != nil {
+		return "", fmt.Errorf("invalid widget data: %w", err)
+	}
+	for i := range entries {
+		if entries[i].Type == WidgetTypePoll {
+			entries[i] = newPollWidgetEntry(state)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func StartPollWorker(notify PollClosedNotifyFunc) {
+	pollWorkerOnce.Do(func() {
+		log.Println("poll: worker 启动")
+		go runPollWorker(notify)
+	})
+}
+
+func runPollWorker(notify PollClosedNotifyFunc) {
+	ticker := time.NewTicker(PollWorkerInterval)
+	defer ticker.Stop()
+	for {
+		processDuePolls(notify)
+		<-ticker.C
+	}
+}
+
+// processDuePolls 关闭已到截止时间的投票并通知广播结果
+func processDuePolls(notify PollClosedNotifyFunc) {
+	items, err := model.PollListDue(pollNow(), 50)
+	if err != nil {
+		log.Printf("poll: 读取到期投票失败: %v", err)
+		return
+	}
+	for _, item := range items {
+		_, changed, err := pollClose(item.MessageID, "", true)
+		if err != nil {
+			if errors.Is(err, ErrPollNotFound) {
+				// 消息已删除，直接标记关闭避免反复扫描
+				now := pollNow()
+				_ = model.GetDB().Model(&model.PollModel{}).Where("id = ?", item.ID).Update("closed_at", now).Error
+				continue
+			}
+			log.Printf("poll: 自动结束失败 message=%s err=%v", item.MessageID, err)
+			continue
+		}
+		if changed && notify != nil {
+			notify(item.MessageID)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func createPollForTest(t *testing.T, input *PollCreateInput) *model.MessageModel {
+	t.Helper()
+	if err := NormalizePollInput(input); err != nil {
+		t.Fatalf("normalize poll failed: %v", err)
+	}
+	msg := &model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		ChannelID:         "ch-poll",
+		UserID:            "user-poll-owner",
+		Content:           input.Question,
+		WidgetData:        BuildPollWidgetData(input),
+	}
+	if err := model.GetDB().Create(msg).Error; err != nil {
+		t.Fatalf("create poll message failed: %v", err)
+	}
+	if err := model.PollCreate(nil, NewPollModel(input, msg.ID, msg.ChannelID, msg.UserID)); err != nil {
+		t.Fatalf("create poll failed: %v", err)
+	}
+	return msg
+}
+
+func createPollVoterForTest(t *testing.T, id, nickname string) {
+	t.Helper()
+	if err := model.GetDB().Create(&model.UserModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: id},
+		Username:          "u_" + id,
+		Nickname:          nickname,
+		Password:          "pw",
+		Salt:              "salt",
+	}).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+}
+
+func TestNormalizePollInput(t *testing.T) {
+	input := &PollCreateInput{Question: "  下次跑团时间？ ", Options: []string{" 周五 ", "", "周六"}}
+	if err := NormalizePollInput(input); err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if input.Question != "下次跑团时间？" || len(input.Options) != 2 || input.Options[0] != "周五" {
+		t.Fatalf("unexpected normalized input: %+v", input)
+	}
+
+	invalid := []*PollCreateInput{
+		{Question: "", Options: []string{"a", "b"}},
+		{Question: "q", Options: []string{"a"}},
+		{Question: "q", Options: []string{"a", "a"}},
+		{Question: "q", Options: []string{"a", "b"}, ClosesAt: time.Now().Add(-time.Minute).UnixMilli()},
+		{Question: "q", Options: []string{"a", "b"}, ClosesAt: time.Now().Add(31 * 24 * time.Hour).UnixMilli()},
+	}
+	for i, item := range invalid {
+		if err := NormalizePollInput(item); err == nil {
+			t.Fatalf("case %d should be rejected", i)
+		}
+	}
+}
+
+func TestPollVoteSingleChoice(t *testing.T) {
+	initTestDB(t)
+	createPollVoterForTest(t, "voter-a", "Alice")
+	createPollVoterForTest(t, "voter-b", "Bob")
+	msg := createPollForTest(t, &PollCreateInput{Question: "规则", Options: []string{"保留", "取消"}})
+
+	if _, err := PollVote(msg.ID, "voter-a", []int{0, 1}); err == nil {
+		t.Fatalf("single choice poll should reject multiple options")
+	}
+	if _, err := PollVote(msg.ID, "voter-a", []int{0}); err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	if _, err := PollVote(msg.ID, "voter-b", []int{0}); err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	// 改票会覆盖之前的选择
+	widgetData, err := PollVote(msg.ID, "voter-a", []int{1})
+	if err != nil {
+		t.Fatalf("revote failed: %v", err)
+	}
+
+	state := ParsePollWidget(widgetData)
+	if state == nil {
+		t.Fatalf("poll widget missing: %s", widgetData)
+	}
+	if state.Options[0].Count != 1 || state.Options[1].Count != 1 || state.TotalVoters != 2 {
+		t.Fatalf("unexpected counts: %+v", state)
+	}
+	if len(state.Options[1].Voters) != 1 || state.Options[1].Voters[0].Name != "Alice" {
+		t.Fatalf("expected visible voter Alice, got %+v", state.Options[1].Voters)
+	}
+
+	mine, err := PollMyVotes([]string{msg.ID}, "voter-a")
+	if err != nil || len(mine[msg.ID]) != 1 || mine[msg.ID][0] != 1 {
+		t.Fatalf("unexpected my votes: %v err=%v", mine, err)
+	}
+}
+
+func TestPollVoteMultipleAnonymous(t *testing.T) {
+	initTestDB(t)
+	msg := createPollForTest(t, &PollCreateInput{
+		Question:  "可以参加的日期",
+		Options:   []string{"周五", "周六", "周日"},
+		Multiple:  true,
+		Anonymous: true,
+	})
+
+	if _, err := PollVote(msg.ID, "voter-a", []int{0, 2, 2}); err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	widgetData, err := PollVote(msg.ID, "voter-b", []int{2})
+	if err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	if _, err := PollVote(msg.ID, "voter-b", []int{3}); err == nil {
+		t.Fatalf("out of range option should be rejected")
+	}
+
+	state := ParsePollWidget(widgetData)
+	if state.Options[0].Count != 1 || state.Options[1].Count != 0 || state.Options[2].Count != 2 {
+		t.Fatalf("unexpected counts: %+v", state.Options)
+	}
+	if state.TotalVoters != 2 {
+		t.Fatalf("expected 2 voters, got %d", state.TotalVoters)
+	}
+	if strings.Contains(widgetData, "voter-a") || strings.Contains(widgetData, "voters") {
+		t.Fatalf("anonymous poll should not expose voters: %s", widgetData)
+	}
+}
+
+func TestPollClose(t *testing.T) {
+	initTestDB(t)
+	msg := createPollForTest(t, &PollCreateInput{Question: "q", Options: []string{"a", "b"}})
+
+	if _, _, err := PollClose(msg.ID, "someone-else"); !errors.Is(err, ErrPollPermission) {
+		t.Fatalf("expected permission error, got %v", err)
+	}
+	widgetData, changed, err := PollClose(msg.ID, msg.UserID)
+	if err != nil || !changed {
+		t.Fatalf("close failed: changed=%v err=%v", changed, err)
+	}
+	if state := ParsePollWidget(widgetData); state == nil || !state.Closed || state.ClosedAt == 0 {
+		t.Fatalf("poll should be closed: %s", widgetData)
+	}
+	if _, changed, err := PollClose(msg.ID, msg.UserID); err != nil || changed {
+		t.Fatalf("closing twice should be a no-op: changed=%v err=%v", changed, err)
+	}
+	if _, err := PollVote(msg.ID, "voter-a", []int{0}); !errors.Is(err, ErrPollClosed) {
+		t.Fatalf("expected closed error, got %v", err)
+	}
+}
+
+func TestProcessDuePollsClosesExpired(t *testing.T) {
+	initTestDB(t)
+	msg := createPollForTest(t, &PollCreateInput{
+		Question: "q",
+		Options:  []string{"a", "b"},
+		ClosesAt: time.Now().Add(time.Hour).UnixMilli(),
+	})
+	if _, err := PollVote(msg.ID, "voter-a", []int{1}); err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+
+	originalNow := pollNow
+	pollNow = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	defer func() { pollNow = originalNow }()
+
+	var notified []string
+	processDuePolls(func(messageID string) {
+		notified = append(notified, messageID)
+	})
+	if len(notified) != 1 || notified[0] != msg.ID {
+		t.Fatalf("expected notification for %s, got %v", msg.ID, notified)
+	}
+
+	poll, err := model.PollGetByMessageID(nil, msg.ID)
+	if err != nil || poll == nil || poll.ClosedAt == nil {
+		t.Fatalf("poll should be closed: %+v err=%v", poll, err)
+	}
+	var reloaded model.MessageModel
+	model.GetDB().Where("id = ?", msg.ID).Take(&reloaded)
+	state := ParsePollWidget(reloaded.WidgetData)
+	if state == nil || !state.Closed || state.Options[1].Count != 1 {
+		t.Fatalf("unexpected final state: %s", reloaded.WidgetData)
+	}
+}
+
+func TestBuildStateWidgetDataKeepsPollOnEdit(t *testing.T) {
+	input := &PollCreateInput{Question: "q", Options: []string{"a", "b"}}
+	if err := NormalizePollInput(input); err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	previous := BuildPollWidgetData(input)
+	rebuilt := BuildStateWidgetDataFromContentWithPrevious("新的问题 [x|y]", previous)
+	if state := ParsePollWidget(rebuilt); state == nil || state.Question != "q" {
+		t.Fatalf("poll should survive edit: %s", rebuilt)
+	}
+	if _, err := RotateWidgetIndex(previous, 0); err == nil {
+		t.Fatalf("poll widget should not rotate")
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+const (
+	rateLimitSweepInterval = 5 * time.Minute
+	rateLimitExemptTTL     = time.Minute
+)
+
+const (
+	RateLimitScopeUser = "user"
+	RateLimitScopeIP   = "ip"
+)
+
+// rateLimitAppConfig/rateLimitNow 测试中可替换
+var (
+	rateLimitAppConfig = utils.GetConfig
+	rateLimitNow       = time.Now
+)
+
+// RateLimitSubject 一次请求的限流主体
+type RateLimitSubject struct {
+	UserID  string
+	IP      string
+	WorldID string
+}
+
+// RateLimitDecision 限流判定结果，被拒绝时给出命中的维度与建议等待时长
+type RateLimitDecision struct {
+	Allowed    bool
+	Route      string
+	Scope      string
+	Burst      int
+	PerMinute  float64
+	RetryAfter time.Duration
+}
+
+// RetryAfterMs 建议的重试等待毫秒数
+func (d RateLimitDecision) RetryAfterMs() int64 {
+	return d.RetryAfter.Milliseconds()
+}
+
+type rateLimitBucket struct {
+	tokens   float64
+	updated  time.Time
+	capacity float64
+}
+
+type rateLimitExemptEntry struct {
+	exempt    bool
+	checkedAt time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	nextSweep time.Time
+
+	exemptMu sync.Mutex
+	exempt   map[string]rateLimitExemptEntry
+}
+
+var globalRateLimiter = newRateLimiter()
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: map[string]*rateLimitBucket{},
+		exempt:  map[string]rateLimitExemptEntry{},
+	}
+}
+
+// RateLimitAllow 按配置为 route 消耗一个令牌，用户与 IP 两个维度需同时放行
+func RateLimitAllow(route string, subject RateLimitSubject) RateLimitDecision {
+	return globalRateLimiter.allow(route, subject)
+}
+
+func (l *rateLimiter) allow(route string, subject RateLimitSubject) RateLimitDecision {
+	decision := RateLimitDecision{Allowed: true, Route: route}
+	cfg := rateLimitAppConfig()
+	if cfg == nil || !cfg.RateLimit.Enabled {
+		return decision
+	}
+	rules, scoped := resolveRateLimitRules(cfg.RateLimit, route, subject.WorldID)
+	if subject.UserID != "" && l.isExempt(subject.UserID, cfg.RateLimit.ExemptSystemRoles) {
+		return decision
+	}
+
+	keyPrefix := route + "|"
+	if scoped {
+		// 世界覆盖规则使用独立的令牌桶，避免与全局规则互相消耗
+		keyPrefix += "w:" + subject.WorldID + "|"
+	}
+	type check struct {
+		scope string
+		id    string
+		rule  utils.RateLimitRule
+	}
+	checks := []check{
+		{scope: RateLimitScopeUser, id: subject.UserID, rule: rules.User},
+		{scope: RateLimitScopeIP, id: strings.TrimSpace(subject.IP), rule: rules.IP},
+	}
+
+	now := rateLimitNow()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweepLocked(now)
+
+	// 先确认所有维度都有余量再统一扣减，避免一个维度拒绝时另一个维度白白消耗
+	pending := make([]*rateLimitBucket, 0, len(checks))
+	for _, item := range checks {
+		if item.id == "" || item.rule.Burst <= 0 {
+			continue
+		}
+		bucket := l.refillLocked(keyPrefix+item.scope+"|"+item.id, item.rule, now)
+		if bucket.tokens < 1 {
+			decision.Allowed = false
+			decision.Scope = item.scope
+			decision.Burst = item.rule.Burst
+			decision.PerMinute = item.rule.PerMinute
+			decision.RetryAfter = rateLimitRetryAfter(bucket.tokens, item.rule)
+			return decision
+		}
+		pending = append(pending, bucket)
+	}
+	for _, bucket := range pending {
+		bucket.tokens--
+	}
+	return decision
+}
+
+func (l *rateLimiter) refillLocked(key string, rule utils.RateLimitRule, now time.Time) *rateLimitBucket {
+	capacity := float64(rule.Burst)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{tokens: capacity, updated: now, capacity: capacity}
+		l.buckets[key] = bucket
+		return bucket
+	}
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 && rule.PerMinute > 0 {
+		bucket.tokens += elapsed.Minutes() * rule.PerMinute
+	}
+	bucket.updated = now
+	bucket.capacity = capacity
+	if bucket.tokens > capacity {
+		bucket.tokens = capacity
+	}
+	return bucket
+}
+
+// sweepLocked 回收已补满的桶，桶补满后与新建无异
+func (l *rateLimiter) sweepLocked(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	l.nextSweep = now.Add(rateLimitSweepInterval)
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= rateLimitSweepInterval && bucket.tokens >= bucket.capacity-1 {
+			delete(l.buckets, key)
+		}
+	}
+	l.exemptMu.Lock()
+	for userID, entry := range l.exempt {
+		if now.Sub(entry.checkedAt) >= rateLimitExemptTTL {
+			delete(l.exempt, userID)
+		}
+	}
+	l.exemptMu.Unlock()
+}
+
+func rateLimitRetryAfter(tokens float64, rule utils.RateLimitRule) time.Duration {
+	if rule.PerMinute <= 0 {
+		return time.Minute
+	}
+	missing := 1 - tokens
+	wait := time.Duration(math.Ceil(missing / rule.PerMinute * float64(time.Minute)))
+	if wait < 100*time.Millisecond {
+		wait = 100 * time.Millisecond
+	}
+	return wait
+}
+
+// resolveRateLimitRules 依次取世界覆盖、配置中的路由规则与内置默认值；第二个返回值表示是否命中世界覆盖
+func resolveRateLimitRules(cfg utils.RateLimitConfig, route string, worldID string) (utils.RateLimitRouteConfig, bool) {
+	if worldID != "" {
+		for _, item := range cfg.WorldOverrides {
+			if item.Route == route && item.WorldID == worldID {
+				return item, true
+			}
+		}
+	}
+	for _, item := range cfg.Routes {
+		if item.Route == route && item.WorldID == "" {
+			return item, false
+		}
+	}
+	for _, item := range utils.DefaultRateLimitRoutes() {
+		if item.Route == route {
+			return item, false
+		}
+	}
+	return utils.RateLimitRouteConfig{Route: route}, false
+}
+
+// isExempt 拥有豁免系统角色的用户与内置机器人不受限流
+func (l *rateLimiter) isExempt(userID string, roles []string) bool {
+	now := rateLimitNow()
+	l.exemptMu.Lock()
+	entry, ok := l.exempt[userID]
+	l.exemptMu.Unlock()
+	if ok && now.Sub(entry.checkedAt) < rateLimitExemptTTL {
+		return entry.exempt
+	}
+
+	exempt, _ := model.IsInternalBotUser(userID)
+	if !exempt && len(roles) > 0 {
+		userRoles, _ := model.UserRoleMappingListByUserID(userID, "", "system")
+		for _, role := range userRoles {
+			if slices.Contains(roles, role) {
+				exempt = true
+				break
+			}
+		}
+	}
+	l.exemptMu.Lock()
+	l.exempt[userID] = rateLimitExemptEntry{exempt: exempt, checkedAt: now}
+	l.exemptMu.Unlock()
+	return exempt
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func setupRateLimitTest(t *testing.T, cfg utils.RateLimitConfig) *time.Time {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	appCfg := &utils.AppConfig{RateLimit: cfg}
+	prevCfg, prevNow, prevLimiter := rateLimitAppConfig, rateLimitNow, globalRateLimiter
+	rateLimitAppConfig = func() *utils.AppConfig { return appCfg }
+	rateLimitNow = func() time.Time { return now }
+	globalRateLimiter = newRateLimiter()
+	t.Cleanup(func() {
+		rateLimitAppConfig, rateLimitNow, globalRateLimiter = prevCfg, prevNow, prevLimiter
+	})
+	return &now
+}
+
+func TestRateLimitBurstAndRefill(t *testing.T) {
+	initTestDB(t)
+	now := setupRateLimitTest(t, utils.RateLimitConfig{
+		Enabled: true,
+		Routes: []utils.RateLimitRouteConfig{{
+			Route: utils.RateLimitRouteMessageCreate,
+			User:  utils.RateLimitRule{Burst: 3, PerMinute: 60},
+			IP:    utils.RateLimitRule{Burst: 100, PerMinute: 100},
+		}},
+	})
+	subject := RateLimitSubject{UserID: "rl-user-1", IP: "10.0.0.1"}
+
+	for i := 0; i < 3; i++ {
+		if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, subject); !d.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	d := RateLimitAllow(utils.RateLimitRouteMessageCreate, subject)
+	if d.Allowed || d.Scope != RateLimitScopeUser || d.Burst != 3 {
+		t.Fatalf("expected user scope rejection, got %+v", d)
+	}
+	if d.RetryAfter <= 0 || d.RetryAfter > time.Second {
+		t.Fatalf("unexpected retry after: %v", d.RetryAfter)
+	}
+
+	*now = now.Add(time.Second)
+	if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, subject); !d.Allowed {
+		t.Fatalf("expected refill after one second, got %+v", d)
+	}
+	if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, subject); d.Allowed {
+		t.Fatalf("expected rejection after refilled token consumed")
+	}
+
+	// 其他用户不受影响
+	if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, RateLimitSubject{UserID: "rl-user-2", IP: "10.0.0.1"}); !d.Allowed {
+		t.Fatalf("other user should not be limited, got %+v", d)
+	}
+}
+
+func TestRateLimitIPScopeAndWorldOverride(t *testing.T) {
+	initTestDB(t)
+	setupRateLimitTest(t, utils.RateLimitConfig{
+		Enabled: true,
+		Routes: []utils.RateLimitRouteConfig{{
+			Route: utils.RateLimitRouteAttachmentUpload,
+			User:  utils.RateLimitRule{Burst: 10, PerMinute: 10},
+			IP:    utils.RateLimitRule{Burst: 2, PerMinute: 1},
+		}},
+		WorldOverrides: []utils.RateLimitRouteConfig{{
+			Route:   utils.RateLimitRouteAttachmentUpload,
+			WorldID: "world-busy",
+			User:    utils.RateLimitRule{Burst: 5, PerMinute: 5},
+		}},
+	})
+
+	route := utils.RateLimitRouteAttachmentUpload
+	RateLimitAllow(route, RateLimitSubject{UserID: "rl-a", IP: "10.0.0.9"})
+	RateLimitAllow(route, RateLimitSubject{UserID: "rl-b", IP: "10.0.0.9"})
+	d := RateLimitAllow(route, RateLimitSubject{UserID: "rl-c", IP: "10.0.0.9"})
+	if d.Allowed || d.Scope != RateLimitScopeIP {
+		t.Fatalf("expected ip scope rejection, got %+v", d)
+	}
+	if d.RetryAfter < 59*time.Second {
+		t.Fatalf("unexpected retry after for 1/min rule: %v", d.RetryAfter)
+	}
+	// IP 维度拒绝时不应扣减用户维度
+	for i := 0; i < 2; i++ {
+		if d := RateLimitAllow(route, RateLimitSubject{UserID: "rl-c", IP: "10.0.0.10"}); !d.Allowed {
+			t.Fatalf("request %d from new ip should be allowed, got %+v", i, d)
+		}
+	}
+
+	// 世界覆盖使用独立的桶，且未配置 IP 规则时 IP 维度不限制
+	for i := 0; i < 5; i++ {
+		if d := RateLimitAllow(route, RateLimitSubject{UserID: "rl-a", IP: "10.0.0.9", WorldID: "world-busy"}); !d.Allowed {
+			t.Fatalf("world override request %d should be allowed, got %+v", i, d)
+		}
+	}
+	d = RateLimitAllow(route, RateLimitSubject{UserID: "rl-a", IP: "10.0.0.9", WorldID: "world-busy"})
+	if d.Allowed || d.Burst != 5 {
+		t.Fatalf("expected world override rejection, got %+v", d)
+	}
+}
+
+func TestRateLimitExemptAndDisabled(t *testing.T) {
+	initTestDB(t)
+	setupRateLimitTest(t, utils.RateLimitConfig{
+		Enabled:           true,
+		ExemptSystemRoles: []string{"sys-admin"},
+		Routes: []utils.RateLimitRouteConfig{{
+			Route: utils.RateLimitRouteMessageCreate,
+			User:  utils.RateLimitRule{Burst: 1, PerMinute: 1},
+		}},
+	})
+
+	admin, err := model.UserCreate("rl_admin", "pw-rate-limit", "Admin")
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	if _, err := model.UserRoleLink([]string{"sys-admin"}, []string{admin.ID}); err != nil {
+		t.Fatalf("link role failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, RateLimitSubject{UserID: admin.ID}); !d.Allowed {
+			t.Fatalf("exempt user should not be limited, got %+v", d)
+		}
+	}
+
+	RateLimitAllow(utils.RateLimitRouteMessageCreate, RateLimitSubject{UserID: "rl-normal"})
+	if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, RateLimitSubject{UserID: "rl-normal"}); d.Allowed {
+		t.Fatalf("normal user should be limited")
+	}
+
+	setupRateLimitTest(t, utils.RateLimitConfig{Enabled: false})
+	for i := 0; i < 5; i++ {
+		if d := RateLimitAllow(utils.RateLimitRouteMessageCreate, RateLimitSubject{UserID: "rl-normal"}); !d.Allowed {
+			t.Fatalf("disabled limiter should allow all requests")
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+const (
+	// ReadReceiptPreferenceKey 用户偏好键，值为 "true" 时表示开启已读回执
+	ReadReceiptPreferenceKey = "read_receipts_enabled"
+
+	readReceiptDefaultMaxMembers = 20
+	readReceiptScanLimit         = 200
+)
+
+var ErrReadReceiptUnavailable = errors.New("该消息不支持已读回执")
+
+var readReceiptAppConfig = utils.GetConfig
+
+// ReadReceiptReader 已读用户，LastReadAt 为其最近一次已读标记的时间
+type ReadReceiptReader struct {
+	UserID     string `json:"userId"`
+	LastReadAt int64  `json:"lastReadAt"`
+}
+
+func ReadReceiptEnabled() bool {
+	cfg := readReceiptAppConfig()
+	return cfg != nil && cfg.ReadReceipts.Enabled
+}
+
+func readReceiptMaxChannelMembers() int {
+	cfg := readReceiptAppConfig()
+	if cfg == nil || cfg.ReadReceipts.MaxChannelMembers <= 0 {
+		return readReceiptDefaultMaxMembers
+	}
+	return cfg.ReadReceipts.MaxChannelMembers
+}
+
+// ReadReceiptOptedIn 已读回执是双向选择加入的：开启后才会公开自己的阅读状态，也才能看到他人的
+func ReadReceiptOptedIn(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	record, err := model.UserPreferenceGet(userID, ReadReceiptPreferenceKey)
+	return err == nil && record != nil && record.PrefValue == "true"
+}
+
+func readReceiptOptedInSet(userIDs []string) map[string]bool {
+	result := map[string]bool{}
+	if len(userIDs) == 0 {
+		return result
+	}
+	var ids []string
+	model.GetDB().Model(&model.UserPreferenceModel{}).
+		Where("user_id IN ? AND pref_key = ? AND pref_value = ?", userIDs, ReadReceiptPreferenceKey, "true").
+		Pluck("user_id", &ids)
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result
+}
+
+// readReceiptChannelEligible 私聊频道始终可用，普通频道要求成员数不超过上限以控制开销
+func readReceiptChannelEligible(channelID string) bool {
+	if channelID == "" {
+		return false
+	}
+	if len(channelID) >= 30 {
+		return true
+	}
+	count, err := ChannelMemberCount(channelID)
+	return err == nil && count <= readReceiptMaxChannelMembers()
+}
+
+// ReadReceiptMessageEligible 悄悄话总是支持已读回执，其余消息取决于所在频道
+func ReadReceiptMessageEligible(msg *model.MessageModel) bool {
+	if msg == nil || msg.ID == "" || msg.IsDeleted || msg.IsRevoked || msg.ThreadID != "" {
+		return false
+	}
+	if msg.IsWhisper {
+		return true
+	}
+	return readReceiptChannelEligible(msg.ChannelID)
+}
+
+func readReceiptWhisperAudience(msg *model.MessageModel) []string {
+	ids := model.GetWhisperRecipientIDs(msg.ID)
+	if msg.WhisperTo != "" {
+		ids = append(ids, msg.WhisperTo)
+	}
+	return ids
+}
+
+// ReadReceiptList 根据频道已读标记推导出已读过该消息、且开启了已读回执的用户
+func ReadReceiptList(msg *model.MessageModel) ([]*ReadReceiptReader, error) {
+	if !ReadReceiptEnabled() || !ReadReceiptMessageEligible(msg) {
+		return nil, ErrReadReceiptUnavailable
+	}
+	q := model.GetDB().Model(&model.ChannelLatestReadModel{}).
+		Select("user_id, message_time").
+		Where("channel_id = ? AND message_time >= ? AND user_id <> ?", msg.ChannelID, msg.CreatedAt.UnixMilli(), msg.UserID).
+		Where(`EXISTS (SELECT 1 FROM user_preferences p WHERE p.user_id = channel_latest_read.user_id AND p.pref_key = ? AND p.pref_value = ?)`,
+			ReadReceiptPreferenceKey, "true")
+	if msg.IsWhisper {
+		audience := readReceiptWhisperAudience(msg)
+		if len(audience) == 0 {
+			return []*ReadReceiptReader{}, nil
+		}
+		q = q.Where("user_id IN ?", audience)
+	}
+	var rows []model.ChannelLatestReadModel
+	if err := q.Order("message_time asc").Limit(readReceiptScanLimit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	readers := make([]*ReadReceiptReader, 0, len(rows))
+	for _, row := range rows {
+		readers = append(readers, &ReadReceiptReader{UserID: row.UserId, LastReadAt: row.MessageTime})
+	}
+	return readers, nil
+}
+
+// ReadReceiptCollect 计算已读标记从 prevMark 推进到 newMark 时阅读者新读到的消息，按发送者分组；
+// 只返回开启了已读回执的发送者
+func ReadReceiptCollect(channelID, readerID string, prevMark, newMark int64) (map[string][]string, error) {
+	if !ReadReceiptEnabled() || channelID == "" || newMark <= prevMark || !ReadReceiptOptedIn(readerID) {
+		return nil, nil
+	}
+	q := model.GetDB().Model(&model.MessageModel{}).
+		Select("id, user_id").
+		Where("channel_id = ? AND created_at > ? AND created_at <= ?", channelID, time.UnixMilli(prevMark), time.UnixMilli(newMark)).
+		Where("user_id <> ? AND is_deleted = ? AND thread_id = ''", readerID, false)
+	whisperToReader := `(whisper_to = ? OR EXISTS (
+		SELECT 1 FROM message_whisper_recipients r WHERE r.message_id = messages.id AND r.user_id = ?
+	))`
+	if readReceiptChannelEligible(channelID) {
+		q = q.Where("(is_whisper = ? OR "+whisperToReader+")", false, readerID, readerID)
+	} else {
+		q = q.Where("is_whisper = ? AND "+whisperToReader, true, readerID, readerID)
+	}
+	var items []model.MessageModel
+	if err := q.Order("created_at desc").Limit(readReceiptScanLimit).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	senders := make([]string, 0, len(items))
+	for _, item := range items {
+		senders = append(senders, item.UserID)
+	}
+	optedIn := readReceiptOptedInSet(senders)
+	result := map[string][]string{}
+	// 逆序遍历，使消息 ID 按时间正序排列
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if optedIn[item.UserID] {
+			result[item.UserID] = append(result[item.UserID], item.ID)
+		}
+	}
+	return result, nil
+}
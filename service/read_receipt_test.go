@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func setupReadReceiptTest(t *testing.T, maxMembers int) {
+	t.Helper()
+	initTestDB(t)
+	cfg := &utils.AppConfig{ReadReceipts: utils.ReadReceiptConfig{Enabled: true, MaxChannelMembers: maxMembers}}
+	prev := readReceiptAppConfig
+	readReceiptAppConfig = func() *utils.AppConfig { return cfg }
+	t.Cleanup(func() { readReceiptAppConfig = prev })
+
+	db := model.GetDB()
+	for _, uid := range []string{"u-a", "u-b", "u-c", "u-d"} {
+		if err := db.Create(&model.MemberModel{ChannelID: "ch-rr", UserID: uid}).Error; err != nil {
+			t.Fatalf("create member failed: %v", err)
+		}
+	}
+	for _, uid := range []string{"u-a", "u-b", "u-c"} {
+		if _, err := model.UserPreferenceUpsert(uid, ReadReceiptPreferenceKey, "true"); err != nil {
+			t.Fatalf("opt in failed: %v", err)
+		}
+	}
+}
+
+func createReadReceiptMessage(t *testing.T, id, whisperTo string, at time.Time) *model.MessageModel {
+	t.Helper()
+	msg := &model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: id, CreatedAt: at, UpdatedAt: at},
+		ChannelID:         "ch-rr",
+		UserID:            "u-a",
+		Content:           id,
+		IsWhisper:         whisperTo != "",
+		WhisperTo:         whisperTo,
+	}
+	if err := model.GetDB().Create(msg).Error; err != nil {
+		t.Fatalf("create message failed: %v", err)
+	}
+	return msg
+}
+
+func setReadMarkForTest(t *testing.T, userID string, at time.Time) {
+	t.Helper()
+	if err := model.GetDB().Create(&model.ChannelLatestReadModel{
+		ChannelId:   "ch-rr",
+		UserId:      userID,
+		MessageTime: at.UnixMilli(),
+	}).Error; err != nil {
+		t.Fatalf("create read mark failed: %v", err)
+	}
+}
+
+func readReceiptUserIDs(readers []*ReadReceiptReader) []string {
+	ids := make([]string, 0, len(readers))
+	for _, r := range readers {
+		ids = append(ids, r.UserID)
+	}
+	return ids
+}
+
+func TestReadReceiptListRespectsOptInAndWhisperAudience(t *testing.T) {
+	setupReadReceiptTest(t, 10)
+	base := time.Now().Add(-time.Hour)
+	public := createReadReceiptMessage(t, "m-public", "", base)
+	whisper := createReadReceiptMessage(t, "m-whisper", "u-b", base.Add(time.Minute))
+
+	setReadMarkForTest(t, "u-a", base.Add(time.Hour))
+	setReadMarkForTest(t, "u-b", base.Add(10*time.Minute))
+	setReadMarkForTest(t, "u-c", base.Add(30*time.Second)) // 只读到公开消息
+	setReadMarkForTest(t, "u-d", base.Add(time.Hour))      // 未开启已读回执
+
+	readers, err := ReadReceiptList(public)
+	if got := readReceiptUserIDs(readers); err != nil || len(got) != 2 || got[0] != "u-c" || got[1] != "u-b" {
+		t.Fatalf("unexpected public readers: %v err=%v", got, err)
+	}
+	readers, err = ReadReceiptList(whisper)
+	if got := readReceiptUserIDs(readers); err != nil || len(got) != 1 || got[0] != "u-b" {
+		t.Fatalf("whisper receipts should be limited to recipients: %v err=%v", got, err)
+	}
+}
+
+func TestReadReceiptLimitedToSmallChannels(t *testing.T) {
+	setupReadReceiptTest(t, 3)
+	base := time.Now().Add(-time.Hour)
+	public := createReadReceiptMessage(t, "m-public", "", base)
+	whisper := createReadReceiptMessage(t, "m-whisper", "u-b", base.Add(time.Minute))
+	setReadMarkForTest(t, "u-b", base.Add(time.Hour))
+
+	if _, err := ReadReceiptList(public); !errors.Is(err, ErrReadReceiptUnavailable) {
+		t.Fatalf("channel over member limit should not support receipts, got %v", err)
+	}
+	if readers, err := ReadReceiptList(whisper); err != nil || len(readers) != 1 {
+		t.Fatalf("whispers should always support receipts: %+v err=%v", readers, err)
+	}
+
+	groups, err := ReadReceiptCollect("ch-rr", "u-b", 0, time.Now().UnixMilli())
+	if err != nil || len(groups["u-a"]) != 1 || groups["u-a"][0] != "m-whisper" {
+		t.Fatalf("only whispers should be collected in large channels: %+v err=%v", groups, err)
+	}
+}
+
+func TestReadReceiptCollectGroupsBySender(t *testing.T) {
+	setupReadReceiptTest(t, 10)
+	base := time.Now().Add(-time.Hour)
+	createReadReceiptMessage(t, "m-1", "", base)
+	createReadReceiptMessage(t, "m-2", "u-c", base.Add(time.Minute)) // 发给别人的悄悄话
+	createReadReceiptMessage(t, "m-3", "u-b", base.Add(2*time.Minute))
+	createReadReceiptMessage(t, "m-4", "", base.Add(3*time.Minute))
+
+	groups, err := ReadReceiptCollect("ch-rr", "u-b", base.Add(30*time.Second).UnixMilli(), time.Now().UnixMilli())
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+	got := groups["u-a"]
+	if len(groups) != 1 || len(got) != 2 || got[0] != "m-3" || got[1] != "m-4" {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+
+	if groups, _ := ReadReceiptCollect("ch-rr", "u-d", 0, time.Now().UnixMilli()); len(groups) != 0 {
+		t.Fatalf("readers without opt-in should not emit receipts: %+v", groups)
+	}
+	if err := model.UserPreferenceDelete("u-a", ReadReceiptPreferenceKey); err != nil {
+		t.Fatalf("opt out failed: %v", err)
+	}
+	if groups, _ := ReadReceiptCollect("ch-rr", "u-b", 0, time.Now().UnixMilli()); len(groups) != 0 {
+		t.Fatalf("senders without opt-in should not receive receipts: %+v", groups)
+	}
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+var (
+	ErrScheduledMessageNotFound   = errors.New("scheduled message not found")
+	ErrScheduledMessagePermission = errors.New("scheduled message permission denied")
+)
+
+const (
+	scheduledMessageContentMaxLen = 4000
+	scheduledMessageNoteMaxLen    = 128
+	// 单个用户在单个频道内同时生效的定时消息上限
+	scheduledMessageActiveLimit = 50
+	// 循环消息两次触发的最小间隔
+	scheduledMessageMinInterval = 5 * time.Minute
+	// 超过该时长仍未发出（例如服务停机）的触发将被跳过，避免过期提醒
+	scheduledMessageMissedGrace = 30 * time.Minute
+	// 一次性消息发送失败后的重试间隔与次数
+	scheduledMessageRetryDelay = time.Minute
+	scheduledMessageMaxRetries = 3
+
+	ScheduledMessageWorkerInterval = 15 * time.Second
+)
+
+// ScheduledMessageInput 创建或修改定时消息的参数；RunAt 与 CronExpr 二选一
+type ScheduledMessageInput struct {
+	Content           string `json:"content"`
+	IdentityID        string `json:"identityId"`
+	IdentityVariantID string `json:"identityVariantId"`
+	ICMode            string `json:"icMode"`
+	RunAt             int64  `json:"runAt"` // 毫秒时间戳
+	CronExpr          string `json:"cronExpr"`
+	Timezone          string `json:"timezone"`
+	Note              string `json:"note"`
+}
+
+// ScheduledMessageDeliverFunc 由 API 层提供，按 message.create 的流程发出消息并返回消息 ID。
+// clientID 对同一次触发保持不变，用于重启后重试时去重。
+type ScheduledMessageDeliverFunc func(item *model.ScheduledMessageModel, clientID string) (string, error)
+
+var (
+	scheduledMessageWorkerOnce sync.Once
+	scheduledMessageNow        = time.Now
+)
+
+func canSendInChannel(userID string, channel *model.ChannelModel) bool {
+	return pm.CanWithChannelRole(userID, channel.ID, pm.PermFuncChannelTextSend, pm.PermFuncChannelTextSendAll)
+}
+
+func resolveScheduledMessageLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("未知时区: %s", name)
+	}
+	return loc, nil
+}
+
+// validateScheduledMessageCron 校验 cron 表达式并检查触发间隔不低于下限
+func validateScheduledMessageCron(expr string, loc *time.Location, now time.Time) (*cronSchedule, error) {
+	schedule, err := parseCronExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	prev := schedule.Next(now.In(loc))
+	if prev.IsZero() {
+		return nil, errors.New("cron 表达式在未来没有触发时间")
+	}
+	for i := 0; i < 10; i++ {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if next.Sub(prev) < scheduledMessageMinInterval {
+			return nil, fmt.Errorf("循环消息的触发间隔不能小于 %d 分钟", int(scheduledMessageMinInterval/time.Minute))
+		}
+		prev = next
+	}
+	return schedule, nil
+}
+
+// normalizeScheduledMessageInput 校验输入并计算首次触发时间
+func normalizeScheduledMessageInput(userID string, channel *model.ChannelModel, input *ScheduledMessageInput, now time.Time) (*time.Time, error) {
+	if input == nil {
+		return nil, errors.New("缺少定时消息内容")
+	}
+	input.Content = strings.TrimSpace(input.Content)
+	input.IdentityID = strings.TrimSpace(input.IdentityID)
+	input.IdentityVariantID = strings.TrimSpace(input.IdentityVariantID)
+	input.ICMode = strings.ToLower(strings.TrimSpace(input.ICMode))
+	input.CronExpr = strings.TrimSpace(input.CronExpr)
+	input.Timezone = strings.TrimSpace(input.Timezone)
+	input.Note = strings.TrimSpace(input.Note)
+
+	if input.Content == "" {
+		return nil, errors.New("消息内容不能为空")
+	}
+	if utf8.RuneCountInString(input.Content) > scheduledMessageContentMaxLen {
+		return nil, fmt.Errorf("消息内容不能超过 %d 个字符", scheduledMessageContentMaxLen)
+	}
+	if utf8.RuneCountInString(input.Note) > scheduledMessageNoteMaxLen {
+		return nil, fmt.Errorf("备注不能超过 %d 个字符", scheduledMessageNoteMaxLen)
+	}
+	if input.ICMode == "" {
+		input.ICMode = "ic"
+	}
+	if input.ICMode != "ic" && input.ICMode != "ooc" {
+		return nil, errors.New("场内场外模式无效")
+	}
+
+	identity, err := ChannelIdentityValidateMessageIdentity(userID, channel.ID, input.IdentityID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ChannelIdentityVariantValidateMessageVariant(userID, channel.ID, identity, input.IdentityVariantID); err != nil {
+		return nil, err
+	}
+
+	loc, err := resolveScheduledMessageLocation(input.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if input.CronExpr != "" {
+		schedule, err := validateScheduledMessageCron(input.CronExpr, loc, now)
+		if err != nil {
+			return nil, err
+		}
+		next := schedule.Next(now.In(loc))
+		return &next, nil
+	}
+	if input.RunAt <= 0 {
+		return nil, errors.New("请设置发送时间或循环规则")
+	}
+	runAt := time.UnixMilli(input.RunAt)
+	if !runAt.After(now) {
+		return nil, errors.New("发送时间需晚于当前时间")
+	}
+	return &runAt, nil
+}
+
+func loadScheduledMessageChannel(channelID string) (*model.ChannelModel, error) {
+	channelID = strings.TrimSpace(channelID)
+	// 私聊频道不支持定时消息
+	if channelID == "" || len(channelID) >= 30 {
+		return nil, ErrChannelNotFound
+	}
+	channel, err := model.ChannelGet(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil || channel.ID == "" {
+		return nil, ErrChannelNotFound
+	}
+	return channel, nil
+}
+
+// canManageScheduledMessage 创建者本人，或频道所属世界的管理员可管理
+func canManageScheduledMessage(userID string, item *model.ScheduledMessageModel) bool {
+	if item.UserID == userID {
+		return true
+	}
+	if item.WorldID != "" && IsWorldAdmin(item.WorldID, userID) {
+		return true
+	}
+	return pm.CanWithSystemRole(userID, pm.PermModAdmin)
+}
+
+func ScheduledMessageCreate(userID, channelID string, input *ScheduledMessageInput) (*model.ScheduledMessageModel, error) {
+	channel, err := loadScheduledMessageChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !canSendInChannel(userID, channel) {
+		return nil, ErrScheduledMessagePermission
+	}
+	count, err := model.ScheduledMessageCountActive(channel.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= scheduledMessageActiveLimit {
+		return nil, fmt.Errorf("每个频道最多同时保留 %d 条定时消息", scheduledMessageActiveLimit)
+	}
+	nextRunAt, err := normalizeScheduledMessageInput(userID, channel, input, scheduledMessageNow())
+	if err != nil {
+		return nil, err
+	}
+	item := &model.ScheduledMessageModel{
+		ChannelID:         channel.ID,
+		WorldID:           channel.WorldID,
+		UserID:            userID,
+		Content:           input.Content,
+		IdentityID:        input.IdentityID,
+		IdentityVariantID: input.IdentityVariantID,
+		ICMode:            input.ICMode,
+		CronExpr:          input.CronExpr,
+		Timezone:          input.Timezone,
+		Note:              input.Note,
+		Status:            model.ScheduledMessageStatusActive,
+		NextRunAt:         nextRunAt,
+	}
+	if err := model.ScheduledMessageCreate(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ScheduledMessageUpdate 修改定时消息，仅创建者可修改；已结束的消息修改后重新生效
+func ScheduledMessageUpdate(userID, id string, input *ScheduledMessageInput) (*model.ScheduledMessageModel, error) {
+	item, err := model.ScheduledMessageGet(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrScheduledMessageNotFound
+	}
+	if item.UserID != userID {
+		return nil, ErrScheduledMessagePermission
+	}
+	channel, err := loadScheduledMessageChannel(item.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if !canSendInChannel(userID, channel) {
+		return nil, ErrScheduledMessagePermission
+	}
+	nextRunAt, err := normalizeScheduledMessageInput(userID, channel, input, scheduledMessageNow())
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{
+		"content":             input.Content,
+		"identity_id":         input.IdentityID,
+		"identity_variant_id": input.IdentityVariantID,
+		"ic_mode":             input.ICMode,
+		"cron_expr":           input.CronExpr,
+		"timezone":            input.Timezone,
+		"note":                input.Note,
+		"status":              model.ScheduledMessageStatusActive,
+		"next_run_at":         nextRunAt,
+		"fail_count":          0,
+		"last_error":          "",
+		"revision":            item.Revision + 1,
+	}
+	if err := model.ScheduledMessageUpdate(item.ID, values); err != nil {
+		return nil, err
+	}
+	return model.ScheduledMessageGet(item.ID)
+}
+
+// ScheduledMessageCancel 取消定时消息，记录保留以便查看
+func ScheduledMessageCancel(userID, id string) (*model.ScheduledMessageModel, error) {
+	item, err := model.ScheduledMessageGet(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrScheduledMessageNotFound
+	}
+	if !canManageScheduledMessage(userID, item) {
+		return nil, ErrScheduledMessagePermission
+	}
+	if item.Status != model.ScheduledMessageStatusActive {
+		return item, nil
+	}
+	if err := model.ScheduledMessageUpdate(item.ID, map[string]any{
+		"status":      model.ScheduledMessageStatusCanceled,
+		"next_run_at": nil,
+	}); err != nil {
+		return nil, err
+	}
+	item.Status = model.ScheduledMessageStatusCanceled
+	item.NextRunAt = nil
+	return item, nil
+}
+
+// ScheduledMessageList 列出频道内自己的定时消息；世界管理员可查看全部
+func ScheduledMessageList(userID, channelID string, includeFinished bool) ([]*model.ScheduledMessageModel, error) {
+	channel, err := loadScheduledMessageChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	filterUserID := userID
+	if channel.WorldID != "" && IsWorldAdmin(channel.WorldID, userID) {
+		filterUserID = ""
+	} else if !canSendInChannel(userID, channel) {
+		return nil, ErrScheduledMessagePermission
+	}
+	return model.ScheduledMessageListByChannel(channel.ID, filterUserID, includeFinished)
+}
+
+func StartScheduledMessageWorker(deliver ScheduledMessageDeliverFunc) {
+	if deliver == nil {
+		return
+	}
+	scheduledMessageWorkerOnce.Do(func() {
+		log.Println("scheduled-message: worker 启动")
+		go runScheduledMessageWorker(deliver)
+	})
+}
+
+func runScheduledMessageWorker(deliver ScheduledMessageDeliverFunc) {
+	ticker := time.NewTicker(ScheduledMessageWorkerInterval)
+	defer ticker.Stop()
+	for {
+		processDueScheduledMessages(deliver)
+		<-ticker.C
+	}
+}
+
+func processDueScheduledMessages(deliver ScheduledMessageDeliverFunc) {
+	now := scheduledMessageNow()
+	items, err := model.ScheduledMessageListDue(now, 50)
+	if err != nil {
+		log.Printf("scheduled-message: 读取到期消息失败: %v", err)
+		return
+	}
+	for _, item := range items {
+		if err := processScheduledMessage(item, now, deliver); err != nil {
+			log.Printf("scheduled-message: 处理失败 id=%s channel=%s err=%v", item.ID, item.ChannelID, err)
+		}
+	}
+}
+
+// scheduledMessageClientID 同一次触发的去重键：循环消息按触发时间，一次性消息按修改版本
+func scheduledMessageClientID(item *model.ScheduledMessageModel, runAt time.Time) string {
+	if item.IsRecurring() {
+		return fmt.Sprintf("scheduled:%s:%d", item.ID, runAt.Unix())
+	}
+	return fmt.Sprintf("scheduled:%s:r%d", item.ID, item.Revision)
+}
+
+// processScheduledMessage 先推进 next_run_at 占用本次触发，再投递消息。
+// 一次性消息在投递成功前保持 active 并按重试间隔顺延，重启后会以相同 client_id 重试。
+func processScheduledMessage(item *model.ScheduledMessageModel, now time.Time, deliver ScheduledMessageDeliverFunc) error {
+	if item == nil || item.NextRunAt == nil {
+		return nil
+	}
+	runAt := *item.NextRunAt
+	var nextRunAt *time.Time
+	if item.IsRecurring() {
+		loc, err := resolveScheduledMessageLocation(item.Timezone)
+		if err != nil {
+			loc = time.Local
+		}
+		schedule, err := parseCronExpr(item.CronExpr)
+		if err != nil {
+			_, claimErr := model.ScheduledMessageClaim(item.ID, runAt, map[string]any{
+				"status":      model.ScheduledMessageStatusFailed,
+				"next_run_at": nil,
+				"last_error":  truncateAuditText(err.Error(), 255),
+			})
+			return claimErr
+		}
+		if next := schedule.Next(now.In(loc)); !next.IsZero() {
+			nextRunAt = &next
+		}
+	} else {
+		retryAt := now.Add(scheduledMessageRetryDelay)
+		nextRunAt = &retryAt
+	}
+
+	claimValues := map[string]any{"next_run_at": nextRunAt}
+	if item.IsRecurring() && nextRunAt == nil {
+		claimValues["status"] = model.ScheduledMessageStatusCompleted
+	}
+	claimed, err := model.ScheduledMessageClaim(item.ID, runAt, claimValues)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if now.Sub(runAt) > scheduledMessageMissedGrace {
+		values := map[string]any{
+			"last_error": "错过发送时间，已跳过",
+			"fail_count": item.FailCount + 1,
+		}
+		if !item.IsRecurring() {
+			values["status"] = model.ScheduledMessageStatusFailed
+			values["next_run_at"] = nil
+		}
+		return model.ScheduledMessageUpdate(item.ID, values)
+	}
+
+	messageID, deliverErr := deliver(item, scheduledMessageClientID(item, runAt))
+	if deliverErr != nil {
+		failCount := item.FailCount + 1
+		values := map[string]any{
+			"last_error": truncateAuditText(deliverErr.Error(), 255),
+			"fail_count": failCount,
+		}
+		if !item.IsRecurring() && failCount >= scheduledMessageMaxRetries {
+			values["status"] = model.ScheduledMessageStatusFailed
+			values["next_run_at"] = nil
+		}
+		if err := model.ScheduledMessageUpdate(item.ID, values); err != nil {
+			return err
+		}
+		return deliverErr
+	}
+
+	values := map[string]any{
+		"last_run_at":     now,
+		"last_message_id": messageID,
+		"last_error":      "",
+		"fail_count":      0,
+		"run_count":       item.RunCount + 1,
+	}
+	if !item.IsRecurring() {
+		values["status"] = model.ScheduledMessageStatusCompleted
+		values["next_run_at"] = nil
+	}
+	return model.ScheduledMessageUpdate(item.ID, values)
+}
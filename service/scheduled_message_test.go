@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	base := time.Date(2026, 3, 14, 10, 30, 15, 0, loc) // 周六
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "*/15 * * * *", want: time.Date(2026, 3, 14, 10, 45, 0, 0, loc)},
+		{expr: "0 20 * * fri", want: time.Date(2026, 3, 20, 20, 0, 0, 0, loc)},
+		{expr: "0 9 1 * *", want: time.Date(2026, 4, 1, 9, 0, 0, 0, loc)},
+		{expr: "30 10 * * *", want: time.Date(2026, 3, 15, 10, 30, 0, 0, loc)},
+		{expr: "0 0 * * 7", want: time.Date(2026, 3, 15, 0, 0, 0, 0, loc)},
+		{expr: "0 12 13 * 1", want: time.Date(2026, 3, 16, 12, 0, 0, 0, loc)}, // 日与周按任一匹配
+		{expr: "0 8-10/2 * jan-mar mon-fri", want: time.Date(2026, 3, 16, 8, 0, 0, 0, loc)},
+		{expr: "@daily", want: time.Date(2026, 3, 15, 0, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		schedule, err := parseCronExpr(tc.expr)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", tc.expr, err)
+		}
+		if got := schedule.Next(base); !got.Equal(tc.want) {
+			t.Fatalf("%q next=%v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := parseCronExpr(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+
+	if _, err := validateScheduledMessageCron("* * * * *", time.UTC, base); err == nil {
+		t.Fatalf("every-minute schedule should be rejected")
+	}
+	if _, err := validateScheduledMessageCron("0 9 30 2 *", time.UTC, base); err == nil {
+		t.Fatalf("schedule that never fires should be rejected")
+	}
+}
+
+func createScheduledMessageForTest(t *testing.T, cronExpr string, runAt time.Time) *model.ScheduledMessageModel {
+	t.Helper()
+	item := &model.ScheduledMessageModel{
+		ChannelID: "ch-scheduled",
+		UserID:    "user-scheduled",
+		Content:   "Session starts in 1 hour",
+		ICMode:    "ooc",
+		CronExpr:  cronExpr,
+		Timezone:  "UTC",
+		Status:    model.ScheduledMessageStatusActive,
+		NextRunAt: &runAt,
+	}
+	if err := model.ScheduledMessageCreate(item); err != nil {
+		t.Fatalf("create scheduled message failed: %v", err)
+	}
+	return item
+}
+
+func reloadScheduledMessage(t *testing.T, id string) *model.ScheduledMessageModel {
+	t.Helper()
+	item, err := model.ScheduledMessageGet(id)
+	if err != nil || item == nil {
+		t.Fatalf("reload scheduled message failed: %v", err)
+	}
+	return item
+}
+
+func TestProcessScheduledMessageOneShot(t *testing.T) {
+	initTestDB(t)
+	now := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+	item := createScheduledMessageForTest(t, "", now.Add(-time.Second))
+
+	var clientIDs []string
+	attempts := 0
+	deliver := func(target *model.ScheduledMessageModel, clientID string) (string, error) {
+		attempts++
+		clientIDs = append(clientIDs, clientID)
+		if attempts == 1 {
+			return "", errors.New("rate limited")
+		}
+		return "msg-1", nil
+	}
+
+	if err := processScheduledMessage(item, now, deliver); err == nil {
+		t.Fatalf("expected first delivery error")
+	}
+	retry := reloadScheduledMessage(t, item.ID)
+	if retry.Status != model.ScheduledMessageStatusActive || retry.NextRunAt == nil || !retry.NextRunAt.Equal(now.Add(scheduledMessageRetryDelay)) {
+		t.Fatalf("one-shot should be retried later, got status=%s next=%v", retry.Status, retry.NextRunAt)
+	}
+	if retry.FailCount != 1 || retry.LastError == "" {
+		t.Fatalf("failure should be recorded, got %+v", retry)
+	}
+
+	// 未到重试时间不应再次处理
+	due, err := model.ScheduledMessageListDue(now, 10)
+	if err != nil || len(due) != 0 {
+		t.Fatalf("retry should not be due yet, got %d err=%v", len(due), err)
+	}
+
+	later := now.Add(2 * time.Minute)
+	if err := processScheduledMessage(retry, later, deliver); err != nil {
+		t.Fatalf("retry delivery failed: %v", err)
+	}
+	done := reloadScheduledMessage(t, item.ID)
+	if done.Status != model.ScheduledMessageStatusCompleted || done.NextRunAt != nil {
+		t.Fatalf("one-shot should complete, got status=%s next=%v", done.Status, done.NextRunAt)
+	}
+	if done.LastMessageID != "msg-1" || done.RunCount != 1 || done.FailCount != 0 {
+		t.Fatalf("unexpected completion state: %+v", done)
+	}
+	if len(clientIDs) != 2 || clientIDs[0] != clientIDs[1] {
+		t.Fatalf("retries must reuse the same client id, got %v", clientIDs)
+	}
+}
+
+func TestProcessScheduledMessageRecurringAndMissed(t *testing.T) {
+	initTestDB(t)
+	now := time.Date(2026, 3, 14, 20, 0, 5, 0, time.UTC)
+	item := createScheduledMessageForTest(t, "0 20 * * *", time.Date(2026, 3, 14, 20, 0, 0, 0, time.UTC))
+
+	delivered := 0
+	deliver := func(target *model.ScheduledMessageModel, clientID string) (string, error) {
+		delivered++
+		return "msg-cron", nil
+	}
+	if err := processScheduledMessage(item, now, deliver); err != nil {
+		t.Fatalf("recurring delivery failed: %v", err)
+	}
+	next := reloadScheduledMessage(t, item.ID)
+	if next.Status != model.ScheduledMessageStatusActive || next.NextRunAt == nil || !next.NextRunAt.Equal(time.Date(2026, 3, 15, 20, 0, 0, 0, time.UTC)) {
+		t.Fatalf("recurring message should advance to next day, got %v", next.NextRunAt)
+	}
+
+	// 旧快照的 next_run_at 已过期，重复处理不应再次投递
+	if err := processScheduledMessage(item, now, deliver); err != nil {
+		t.Fatalf("stale process failed: %v", err)
+	}
+	if delivered != 1 {
+		t.Fatalf("stale snapshot must not deliver twice, delivered=%d", delivered)
+	}
+
+	// 服务停机错过发送时间：一次性消息标记失败，不投递
+	missed := createScheduledMessageForTest(t, "", now.Add(-2*time.Hour))
+	if err := processScheduledMessage(missed, now, deliver); err != nil {
+		t.Fatalf("missed process failed: %v", err)
+	}
+	missedState := reloadScheduledMessage(t, missed.ID)
+	if missedState.Status != model.ScheduledMessageStatusFailed || delivered != 1 {
+		t.Fatalf("missed one-shot should fail without delivery, got status=%s delivered=%d", missedState.Status, delivered)
+	}
+}
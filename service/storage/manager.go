@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"sealchat/service/metrics/telemetry"
 	"sealchat/utils"
 )
 
@@ -127,9 +128,10 @@ func (m *Manager) Upload(ctx context.Context, input UploadInput) (*UploadResult,
 		if err == nil {
 			return result, nil
 		}
-		logS3Fallback(err)
+		logS3Fallback(observeBackendErr(BackendS3, "upload", err))
 	}
-	return m.local.upload(input)
+	result, err := m.local.upload(input)
+	return result, observeBackendErr(BackendLocal, "upload", err)
 }
 
 func (m *Manager) UploadAttachment(ctx context.Context, input UploadInput) (*UploadResult, error) {
@@ -143,9 +145,10 @@ func (m *Manager) UploadAttachment(ctx context.Context, input UploadInput) (*Upl
 		if err == nil {
 			return result, nil
 		}
-		logS3Fallback(err)
+		logS3Fallback(observeBackendErr(BackendS3, "upload", err))
 	}
-	return m.local.upload(input)
+	result, err := m.local.upload(input)
+	return result, observeBackendErr(BackendLocal, "upload", err)
 }
 
 func (m *Manager) UploadToS3(ctx context.Context, input UploadInput) (*UploadResult, error) {
@@ -156,7 +159,8 @@ func (m *Manager) UploadToS3(ctx context.Context, input UploadInput) (*UploadRes
 		return nil, fmt.Errorf("未启用 S3 存储")
 	}
 	input.ContentType = normalizeContentType(input.ContentType, input.ObjectKey)
-	return m.remote.upload(ctx, input)
+	result, err := m.remote.upload(ctx, input)
+	return result, observeBackendErr(BackendS3, "upload", err)
 }
 
 func (m *Manager) UploadWithBackend(ctx context.Context, backend BackendType, input UploadInput) (*UploadResult, error) {
@@ -169,9 +173,11 @@ func (m *Manager) UploadWithBackend(ctx context.Context, backend BackendType, in
 		if m.remote == nil {
 			return nil, fmt.Errorf("未启用 S3 存储")
 		}
-		return m.remote.upload(ctx, input)
+		result, err := m.remote.upload(ctx, input)
+		return result, observeBackendErr(BackendS3, "upload", err)
 	default:
-		return m.local.upload(input)
+		result, err := m.local.upload(input)
+		return result, observeBackendErr(BackendLocal, "upload", err)
 	}
 }
 
@@ -181,9 +187,11 @@ func (m *Manager) Exists(ctx context.Context, backend BackendType, objectKey str
 		if m.remote == nil {
 			return false, fmt.Errorf("未启用 S3 存储")
 		}
-		return m.remote.exists(ctx, objectKey)
+		exists, err := m.remote.exists(ctx, objectKey)
+		return exists, observeBackendErr(BackendS3, "exists", err)
 	default:
-		return m.local.exists(objectKey)
+		exists, err := m.local.exists(objectKey)
+		return exists, observeBackendErr(BackendLocal, "exists", err)
 	}
 }
 
@@ -193,9 +201,9 @@ func (m *Manager) Delete(ctx context.Context, backend BackendType, objectKey str
 		if m.remote == nil {
 			return nil
 		}
-		return m.remote.delete(ctx, objectKey)
+		return observeBackendErr(BackendS3, "delete", m.remote.delete(ctx, objectKey))
 	default:
-		return m.local.delete(objectKey)
+		return observeBackendErr(BackendLocal, "delete", m.local.delete(objectKey))
 	}
 }
 
@@ -205,9 +213,9 @@ func (m *Manager) DeletePrefix(ctx context.Context, backend BackendType, objectK
 		if m.remote == nil {
 			return nil
 		}
-		return m.remote.deletePrefix(ctx, objectKey)
+		return observeBackendErr(BackendS3, "delete", m.remote.deletePrefix(ctx, objectKey))
 	default:
-		return m.local.deletePrefix(objectKey)
+		return observeBackendErr(BackendLocal, "delete", m.local.deletePrefix(objectKey))
 	}
 }
 
@@ -217,9 +225,9 @@ func (m *Manager) DownloadToPath(ctx context.Context, backend BackendType, objec
 		if m.remote == nil {
 			return fmt.Errorf("未启用 S3 存储")
 		}
-		return m.remote.downloadToPath(ctx, objectKey, targetPath)
+		return observeBackendErr(BackendS3, "download", m.remote.downloadToPath(ctx, objectKey, targetPath))
 	default:
-		return m.local.downloadToPath(objectKey, targetPath)
+		return observeBackendErr(BackendLocal, "download", m.local.downloadToPath(objectKey, targetPath))
 	}
 }
 
@@ -273,6 +281,14 @@ func (m *Manager) ResolveLocalPath(objectKey string) (string, error) {
 	return m.local.resolvePath(objectKey)
 }
 
+// observeBackendErr 累计存储后端的失败次数，原样返回 err
+func observeBackendErr(backend BackendType, op string, err error) error {
+	if err != nil {
+		telemetry.StorageBackendErrors.Inc(string(backend), op)
+	}
+	return err
+}
+
 func normalizeContentType(contentType, objectKey string) string {
 	ct := strings.TrimSpace(strings.ToLower(contentType))
 	if ct != "" && ct != "application/octet-stream" {
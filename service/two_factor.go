@@ -0,0 +1,321 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+const (
+	totpPeriodSeconds  = 30
+	totpDigits         = 6
+	totpSkewSteps      = 1 // 允许前后各一个时间步的时钟偏差
+	totpSecretBytes    = 20
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	recoveryCodeChars  = "abcdefghjkmnpqrstuvwxyz23456789"
+	defaultTOTPIssuer  = "SealChat"
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("尚未启用两步验证")
+	ErrTwoFactorAlreadyEnabled = errors.New("两步验证已启用")
+	ErrTwoFactorNotPending     = errors.New("请先生成两步验证密钥")
+	ErrTwoFactorCodeInvalid    = errors.New("验证码错误")
+	ErrTwoFactorRequired       = errors.New("当前账号必须启用两步验证")
+)
+
+var totpBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TwoFactorSetup struct {
+	Secret      string `json:"secret"`
+	OtpauthURL  string `json:"otpauthUrl"`
+	Issuer      string `json:"issuer"`
+	AccountName string `json:"accountName"`
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+	Required               bool       `json:"required"`
+}
+
+// TwoFactorRequiredForUser 按配置判断该用户是否必须启用两步验证
+func TwoFactorRequiredForUser(userID string) bool {
+	cfg := utils.GetConfig()
+	if cfg == nil || !cfg.TwoFactor.RequireForAdmins {
+		return false
+	}
+	return pm.CanWithSystemRole(userID, pm.PermModAdmin)
+}
+
+// TwoFactorSetupPending 用户按策略必须启用两步验证但尚未启用
+func TwoFactorSetupPending(userID string) bool {
+	return TwoFactorRequiredForUser(userID) && !model.UserTwoFactorIsEnabled(userID)
+}
+
+func TwoFactorStatusGet(userID string) (*TwoFactorStatus, error) {
+	item, err := model.UserTwoFactorGet(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{Required: TwoFactorRequiredForUser(userID)}
+	if item != nil && item.Enabled {
+		status.Enabled = true
+		status.EnabledAt = item.EnabledAt
+		status.RecoveryCodesRemaining = len(item.RecoveryCodeHashes())
+	}
+	return status, nil
+}
+
+// TwoFactorBeginSetup 生成新的 TOTP 密钥，需调用 TwoFactorConfirmSetup 验证后才会生效
+func TwoFactorBeginSetup(user *model.UserModel) (*TwoFactorSetup, error) {
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if model.UserTwoFactorIsEnabled(user.ID) {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	raw := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := totpBase32.EncodeToString(raw)
+	if err := model.UserTwoFactorSavePending(user.ID, secret); err != nil {
+		return nil, err
+	}
+	issuer := defaultTOTPIssuer
+	if cfg := utils.GetConfig(); cfg != nil && strings.TrimSpace(cfg.TwoFactor.Issuer) != "" {
+		issuer = strings.TrimSpace(cfg.TwoFactor.Issuer)
+	}
+	return &TwoFactorSetup{
+		Secret:      secret,
+		OtpauthURL:  buildOtpauthURL(issuer, user.Username, secret),
+		Issuer:      issuer,
+		AccountName: user.Username,
+	}, nil
+}
+
+// TwoFactorConfirmSetup 校验首个验证码并启用两步验证，返回明文恢复码（仅此一次）
+func TwoFactorConfirmSetup(userID string, code string) ([]string, error) {
+	item, err := model.UserTwoFactorGet(userID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrTwoFactorNotPending
+	}
+	if item.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	step, ok := totpMatch(item.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrTwoFactorCodeInvalid
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := model.UserTwoFactorEnable(userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// TwoFactorVerifyCode 校验 TOTP 验证码或恢复码，恢复码使用后即作废
+func TwoFactorVerifyCode(userID string, code string) error {
+	item, err := model.UserTwoFactorGet(userID)
+	if err != nil {
+		return err
+	}
+	if item == nil || !item.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+	code = strings.TrimSpace(code)
+	if step, ok := totpMatch(item.Secret, code, time.Now()); ok {
+		fresh, err := model.UserTwoFactorMarkStepUsed(userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrTwoFactorCodeInvalid
+		}
+		return nil
+	}
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return ErrTwoFactorCodeInvalid
+	}
+	consumed, err := model.UserTwoFactorConsumeRecoveryCode(item, hashRecoveryCode(normalized))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+// TwoFactorDisable 用户自行关闭两步验证，需要当前验证码；策略强制启用时不可关闭
+func TwoFactorDisable(userID string, code string) error {
+	if TwoFactorRequiredForUser(userID) {
+		return ErrTwoFactorRequired
+	}
+	if err := TwoFactorVerifyCode(userID, code); err != nil {
+		return err
+	}
+	return model.UserTwoFactorDelete(userID)
+}
+
+// TwoFactorRegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部作废
+func TwoFactorRegenerateRecoveryCodes(userID string, code string) ([]string, error) {
+	if err := TwoFactorVerifyCode(userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := model.UserTwoFactorSetRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// TwoFactorSigninBegin 密码校验通过后调用；未启用两步验证时返回空挑战
+func TwoFactorSigninBegin(userID string, signInIP string) (string, error) {
+	if !model.UserTwoFactorIsEnabled(userID) {
+		return "", nil
+	}
+	return model.UserTwoFactorChallengeCreate(userID, signInIP)
+}
+
+// TwoFactorSigninComplete 校验登录挑战与验证码，成功后返回对应用户
+func TwoFactorSigninComplete(challenge string, code string) (*model.UserModel, error) {
+	challenge = strings.TrimSpace(challenge)
+	if challenge == "" {
+		return nil, model.ErrTwoFactorChallengeInvalid
+	}
+	item, err := model.UserTwoFactorChallengeGet(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if err := TwoFactorVerifyCode(item.UserID, code); err != nil {
+		return nil, err
+	}
+	consumed, err := model.UserTwoFactorChallengeConsume(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, model.ErrTwoFactorChallengeInvalid
+	}
+	user := model.UserGet(item.UserID)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// TwoFactorAdminReset 管理员清除用户的两步验证，用户丢失设备时使用
+func TwoFactorAdminReset(userID string) error {
+	if model.UserGet(userID) == nil {
+		return ErrUserNotFound
+	}
+	return model.UserTwoFactorDelete(userID)
+}
+
+func buildOtpauthURL(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriodSeconds))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// totpCodeAt 按 RFC 6238 计算指定时间步的验证码
+func totpCodeAt(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// totpMatch 返回匹配的时间步
+func totpMatch(secret string, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpBase32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriodSeconds
+	for delta := -totpSkewSteps; delta <= totpSkewSteps; delta++ {
+		step := current + int64(delta)
+		if subtle.ConstantTimeCompare([]byte(totpCodeAt(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	charCount := big.NewInt(int64(len(recoveryCodeChars)))
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeLength)
+		for j := range raw {
+			n, err := rand.Int(rand.Reader, charCount)
+			if err != nil {
+				return nil, nil, err
+			}
+			raw[j] = recoveryCodeChars[n.Int64()]
+		}
+		code := string(raw)
+		half := recoveryCodeLength / 2
+		codes = append(codes, code[:half]+"-"+code[half:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func hashRecoveryCode(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for ts, want := range cases {
+		if got := totpCodeAt(key, ts/totpPeriodSeconds); got != want {
+			t.Fatalf("totp at %d = %s, want %s", ts, got, want)
+		}
+	}
+
+	secret := totpBase32.EncodeToString(key)
+	now := time.Unix(1111111109, 0)
+	prev := totpCodeAt(key, now.Unix()/totpPeriodSeconds-1)
+	if step, ok := totpMatch(secret, prev, now); !ok || step != now.Unix()/totpPeriodSeconds-1 {
+		t.Fatalf("expected previous step to be accepted within skew")
+	}
+	stale := totpCodeAt(key, now.Unix()/totpPeriodSeconds-3)
+	if _, ok := totpMatch(secret, stale, now); ok {
+		t.Fatalf("expected stale code to be rejected")
+	}
+}
+
+func TestTwoFactorEnrollAndSignin(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+
+	userID := "user-" + utils.NewID()
+	user := &model.UserModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: userID},
+		Username:          "totp_" + userID,
+		Nickname:          "TOTP",
+		Password:          "pw",
+		Salt:              "salt",
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+
+	challenge, err := TwoFactorSigninBegin(userID, "127.0.0.1")
+	if err != nil || challenge != "" {
+		t.Fatalf("expected no challenge before enrollment, got %q err=%v", challenge, err)
+	}
+
+	setup, err := TwoFactorBeginSetup(user)
+	if err != nil {
+		t.Fatalf("begin setup failed: %v", err)
+	}
+	if !strings.HasPrefix(setup.OtpauthURL, "otpauth://totp/") || !strings.Contains(setup.OtpauthURL, "secret="+setup.Secret) {
+		t.Fatalf("unexpected otpauth url: %s", setup.OtpauthURL)
+	}
+	key, err := totpBase32.DecodeString(setup.Secret)
+	if err != nil {
+		t.Fatalf("decode secret failed: %v", err)
+	}
+	currentStep := time.Now().Unix() / totpPeriodSeconds
+
+	if _, err := TwoFactorConfirmSetup(userID, "000000x"); err != ErrTwoFactorCodeInvalid {
+		t.Fatalf("expected invalid code error, got %v", err)
+	}
+	codes, err := TwoFactorConfirmSetup(userID, totpCodeAt(key, currentStep))
+	if err != nil {
+		t.Fatalf("confirm setup failed: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+	if _, err := TwoFactorBeginSetup(user); err != ErrTwoFactorAlreadyEnabled {
+		t.Fatalf("expected already enabled error, got %v", err)
+	}
+
+	// 启用时使用过的动态码不能再次用于登录
+	if err := TwoFactorVerifyCode(userID, totpCodeAt(key, currentStep)); err != ErrTwoFactorCodeInvalid {
+		t.Fatalf("expected replayed code to be rejected, got %v", err)
+	}
+
+	challenge, err = TwoFactorSigninBegin(userID, "127.0.0.1")
+	if err != nil || challenge == "" {
+		t.Fatalf("expected signin challenge, got %q err=%v", challenge, err)
+	}
+	if _, err := TwoFactorSigninComplete(challenge, "abcde-fghjk"); err != ErrTwoFactorCodeInvalid {
+		t.Fatalf("expected wrong recovery code to fail, got %v", err)
+	}
+	signed, err := TwoFactorSigninComplete(challenge, strings.ToUpper(codes[0]))
+	if err != nil || signed == nil || signed.ID != userID {
+		t.Fatalf("recovery code signin failed: %v", err)
+	}
+	if _, err := TwoFactorSigninComplete(challenge, codes[1]); err != model.ErrTwoFactorChallengeInvalid {
+		t.Fatalf("expected consumed challenge to be rejected, got %v", err)
+	}
+
+	challenge, _ = TwoFactorSigninBegin(userID, "127.0.0.1")
+	if _, err := TwoFactorSigninComplete(challenge, codes[0]); err != ErrTwoFactorCodeInvalid {
+		t.Fatalf("expected used recovery code to be rejected, got %v", err)
+	}
+	status, err := TwoFactorStatusGet(userID)
+	if err != nil || !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Fatalf("unexpected status: %+v err=%v", status, err)
+	}
+
+	if err := TwoFactorAdminReset(userID); err != nil {
+		t.Fatalf("admin reset failed: %v", err)
+	}
+	if model.UserTwoFactorIsEnabled(userID) {
+		t.Fatalf("expected two factor to be cleared after reset")
+	}
+}
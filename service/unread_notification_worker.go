@@ -91,7 +91,19 @@ func processUserChannelNotification(setting *model.EmailNotificationSettingsMode
 		return // 已达到小时限制
 	}
 
-	// 2. 获取用户最后阅读时间
+	// 2. 检查通知偏好：静音、免打扰或静默时段内暂不发送，结束后再汇总未读
+	policy, err := model.NotificationPolicyForChannel(userID, channelID)
+	if err != nil {
+		log.Printf("email-notification: 获取通知偏好失败 user=%s channel=%s: %v", userID, channelID, err)
+		return
+	}
+	now := notificationNow()
+	if !policy.AllowsLevel(model.NotificationKindMention) || policy.Snoozed(now) || policy.InQuietHours(now) {
+		return
+	}
+	mentionsOnly := !policy.AllowsLevel(model.NotificationKindMessage)
+
+	// 3. 获取用户最后阅读时间
 	readRecords, err := model.ChannelReadListByUserId([]string{channelID}, userID)
 	if err != nil {
 		log.Printf("email-notification: 获取已读记录失败 user=%s channel=%s: %v", userID, channelID, err)
@@ -103,7 +115,7 @@ func processUserChannelNotification(setting *model.EmailNotificationSettingsMode
 		lastReadTime = readRecords[0].MessageTime
 	}
 
-	// 3. 获取用户最后一次推送时间（避免重复推送）
+	// 4. 获取用户最后一次推送时间（避免重复推送）
 	lastLog, err := model.EmailNotificationLogGetLatest(userID, channelID)
 	if err != nil {
 		log.Printf("email-notification: 获取推送记录失败 user=%s channel=%s: %v", userID, channelID, err)
@@ -115,12 +127,12 @@ func processUserChannelNotification(setting *model.EmailNotificationSettingsMode
 		lastPushTime = lastLog.SentAt
 	}
 
-	// 4. 计算延迟时间阈值
+	// 5. 计算延迟时间阈值
 	delayMs := int64(delayMinutes) * 60 * 1000
 	cutoffTime := time.Now().UnixMilli() - delayMs
 
-	// 5. 查询未读消息
-	unreadMessages, err := getUnreadMessagesForNotification(channelID, userID, lastReadTime, lastPushTime, cutoffTime)
+	// 6. 查询未读消息
+	unreadMessages, err := getUnreadMessagesForNotification(channelID, userID, lastReadTime, lastPushTime, cutoffTime, mentionsOnly)
 	if err != nil {
 		log.Printf("email-notification: 查询未读消息失败 user=%s channel=%s: %v", userID, channelID, err)
 		return
@@ -130,11 +142,11 @@ func processUserChannelNotification(setting *model.EmailNotificationSettingsMode
 		return // 没有需要通知的消息
 	}
 
-	// 6. 获取频道名称
+	// 7. 获取频道名称
 	channelName := resolveChannelNameForEmail(channelID)
 	channelURL := resolveChannelURLForEmail(channelID, cfg.SiteURL)
 
-	// 7. 构建并发送邮件
+	// 8. 构建并发送邮件
 	htmlBody := BuildUnreadDigestHTML(channelName, unreadMessages, normalizeSiteURL(cfg.SiteURL), channelURL)
 	subject := "【SealChat】您有 " + formatMessageCount(len(unreadMessages)) + " 条未读消息"
 
@@ -163,7 +175,7 @@ func processUserChannelNotification(setting *model.EmailNotificationSettingsMode
 		return
 	}
 
-	// 8. 记录推送日志
+	// 9. 记录推送日志
 	if err := model.EmailNotificationLogCreate(userID, channelID, len(unreadMessages)); err != nil {
 		log.Printf("email-notification: 记录推送日志失败 user=%s channel=%s: %v", userID, channelID, err)
 	}
@@ -171,7 +183,7 @@ func processUserChannelNotification(setting *model.EmailNotificationSettingsMode
 	log.Printf("email-notification: 已发送 user=%s channel=%s messages=%d custom_smtp=%v", userID, channelID, len(unreadMessages), setting.UseCustomSMTP)
 }
 
-func getUnreadMessagesForNotification(channelID, userID string, lastReadTime, lastPushTime, cutoffTime int64) ([]MessageSummary, error) {
+func getUnreadMessagesForNotification(channelID, userID string, lastReadTime, lastPushTime, cutoffTime int64, mentionsOnly bool) ([]MessageSummary, error) {
 	db := model.GetDB()
 
 	// 消息需满足：
@@ -191,6 +203,16 @@ func getUnreadMessagesForNotification(channelID, userID string, lastReadTime, la
 	if lastPushTime > 0 {
 		query = query.Where("created_at > ?", time.UnixMilli(lastPushTime))
 	}
+	// 通知级别为「仅提及」时，只汇总 @提及、悄悄话和回复自己的消息
+	if mentionsOnly {
+		mentionSub := db.Model(&model.MentionModel{}).Select("related_id").
+			Where("receiver_id = ? AND related_type = ?", userID, "message")
+		ownSub := db.Model(&model.MessageModel{}).Select("id").
+			Where("channel_id = ? AND user_id = ?", channelID, userID)
+		query = query.Where(db.Where("id IN (?)", mentionSub).
+			Or("whisper_to = ?", userID).
+			Or("quote_id IN (?)", ownSub))
+	}
 
 	if err := query.Order("created_at ASC").Limit(20).Find(&messages).Error; err != nil {
 		return nil, err
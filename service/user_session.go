@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"sealchat/model"
+)
+
+var ErrUserSessionNotFound = errors.New("会话不存在或已失效")
+
+// UserSessionInfo 展示给用户的登录会话
+type UserSessionInfo struct {
+	ID         string     `json:"id"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	ExpiredAt  time.Time  `json:"expiredAt"`
+	Current    bool       `json:"current"`
+}
+
+// UserSessionList 列出用户的登录会话，currentTokenID 对应的会话标记为当前设备
+func UserSessionList(userID string, currentTokenID string) ([]*UserSessionInfo, error) {
+	tokens, err := model.AccessTokenListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*UserSessionInfo, 0, len(tokens))
+	for _, token := range tokens {
+		items = append(items, &UserSessionInfo{
+			ID:         model.AccessTokenSessionID(token.ID),
+			Device:     DescribeUserAgent(token.UserAgent),
+			UserAgent:  token.UserAgent,
+			IP:         token.IP,
+			CreatedAt:  token.CreatedAt,
+			LastSeenAt: token.LastSeenAt,
+			ExpiredAt:  token.ExpiredAt,
+			Current:    token.ID == currentTokenID,
+		})
+	}
+	return items, nil
+}
+
+// UserSessionRevoke 注销指定会话，返回被注销的 token ID，供调用方断开对应连接
+func UserSessionRevoke(userID string, sessionID string) ([]string, error) {
+	tokenIDs, err := model.AccessTokenDeleteBySessionIDs(userID, []string{strings.TrimSpace(sessionID)})
+	if err != nil {
+		return nil, err
+	}
+	if len(tokenIDs) == 0 {
+		return nil, ErrUserSessionNotFound
+	}
+	return tokenIDs, nil
+}
+
+// UserSessionRevokeOthers 注销当前设备以外的全部会话
+func UserSessionRevokeOthers(userID string, currentTokenID string) ([]string, error) {
+	return model.AccessTokenDeleteOthers(userID, currentTokenID)
+}
+
+// DescribeUserAgent 从 User-Agent 粗略识别浏览器与系统，用于会话列表展示
+func DescribeUserAgent(ua string) string {
+	if strings.TrimSpace(ua) == "" {
+		return "未知设备"
+	}
+	lower := strings.ToLower(ua)
+
+	browser := ""
+	switch {
+	case strings.Contains(lower, "electron"):
+		browser = "桌面客户端"
+	case strings.Contains(lower, "edg/"):
+		browser = "Edge"
+	case strings.Contains(lower, "opr/") || strings.Contains(lower, "opera"):
+		browser = "Opera"
+	case strings.Contains(lower, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(lower, "micromessenger"):
+		browser = "微信"
+	case strings.Contains(lower, "chrome/") || strings.Contains(lower, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(lower, "safari/"):
+		browser = "Safari"
+	}
+
+	system := ""
+	switch {
+	case strings.Contains(lower, "android"):
+		system = "Android"
+	case strings.Contains(lower, "iphone") || strings.Contains(lower, "ipad"):
+		system = "iOS"
+	case strings.Contains(lower, "windows"):
+		system = "Windows"
+	case strings.Contains(lower, "mac os x") || strings.Contains(lower, "macintosh"):
+		system = "macOS"
+	case strings.Contains(lower, "linux"):
+		system = "Linux"
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " · " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	if len([]rune(ua)) > 40 {
+		return string([]rune(ua)[:40]) + "…"
+	}
+	return ua
+}
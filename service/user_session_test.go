@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+
+	"sealchat/model"
+)
+
+func TestUserSessionListAndRevoke(t *testing.T) {
+	initTestDB(t)
+
+	user, err := model.UserCreate("session_user", "pw-session-1", "Session")
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	const chromeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+	const iosUA = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+
+	var tokenIDs []string
+	for _, meta := range []model.AccessTokenMeta{
+		{UserAgent: chromeUA, IP: "10.0.0.1"},
+		{UserAgent: iosUA, IP: "10.0.0.2"},
+		{UserAgent: "", IP: "10.0.0.3"},
+	} {
+		signed, err := model.UserGenerateAccessTokenWithMeta(user.ID, meta)
+		if err != nil {
+			t.Fatalf("generate token failed: %v", err)
+		}
+		verified, err := model.UserVerifyAccessToken(signed)
+		if err != nil {
+			t.Fatalf("verify token failed: %v", err)
+		}
+		tokenIDs = append(tokenIDs, verified.AccessToken.ID)
+	}
+
+	items, err := UserSessionList(user.ID, tokenIDs[0])
+	if err != nil || len(items) != 3 {
+		t.Fatalf("expected 3 sessions, got %d err=%v", len(items), err)
+	}
+	devices := map[string]bool{}
+	currentCount := 0
+	for _, item := range items {
+		devices[item.Device] = true
+		if item.Current {
+			currentCount++
+			if item.IP != "10.0.0.1" {
+				t.Fatalf("unexpected current session ip: %s", item.IP)
+			}
+		}
+		for _, tokenID := range tokenIDs {
+			if item.ID == tokenID {
+				t.Fatalf("session id must not expose raw token id")
+			}
+		}
+	}
+	if currentCount != 1 || !devices["Chrome · Windows"] || !devices["Safari · iOS"] || !devices["未知设备"] {
+		t.Fatalf("unexpected sessions: %+v", devices)
+	}
+
+	var iosSessionID string
+	for _, item := range items {
+		if item.IP == "10.0.0.2" {
+			iosSessionID = item.ID
+		}
+	}
+	revoked, err := UserSessionRevoke(user.ID, iosSessionID)
+	if err != nil || len(revoked) != 1 || revoked[0] != tokenIDs[1] {
+		t.Fatalf("revoke failed: %v err=%v", revoked, err)
+	}
+	if _, err := UserSessionRevoke(user.ID, iosSessionID); err != ErrUserSessionNotFound {
+		t.Fatalf("expected not found on second revoke, got %v", err)
+	}
+
+	other, err := model.UserCreate("session_other", "pw-session-2", "Other")
+	if err != nil {
+		t.Fatalf("create other user failed: %v", err)
+	}
+	if _, err := UserSessionRevoke(other.ID, items[0].ID); err != ErrUserSessionNotFound {
+		t.Fatalf("expected foreign session revoke to fail, got %v", err)
+	}
+
+	revoked, err = UserSessionRevokeOthers(user.ID, tokenIDs[0])
+	if err != nil || len(revoked) != 1 || revoked[0] != tokenIDs[2] {
+		t.Fatalf("revoke others failed: %v err=%v", revoked, err)
+	}
+	items, err = UserSessionList(user.ID, tokenIDs[0])
+	if err != nil || len(items) != 1 || !items[0].Current {
+		t.Fatalf("expected only current session to remain, got %+v err=%v", items, err)
+	}
+}
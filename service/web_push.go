@@ -0,0 +1,595 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+const (
+	WebPushKindMention = "mention"
+	WebPushKindWhisper = "whisper"
+	WebPushKindReply   = "reply"
+	WebPushKindTest    = "test"
+
+	webPushTTLSeconds    = 24 * 60 * 60
+	webPushRecordSize    = 4096
+	webPushBodyMaxRunes  = 140
+	webPushMaxFailCount  = 5
+	webPushJWTExpiration = 12 * time.Hour
+)
+
+var (
+	ErrWebPushDisabled            = errors.New("浏览器推送未启用")
+	ErrWebPushInvalidSubscription = errors.New("推送订阅信息无效")
+	ErrWebPushSubscriptionGone    = errors.New("推送订阅已失效")
+	ErrWebPushBlockedEndpoint     = errors.New("推送端点不允许指向内网地址")
+)
+
+var (
+	webPushHTTPClient = newWebPushHTTPClient()
+	webPushNow        = time.Now
+	// webPushAllowInsecure 是否放行 http 与内网端点，仅在配置显式开启时为真
+	webPushAllowInsecure = func() bool {
+		cfg := utils.GetConfig()
+		return cfg != nil && cfg.WebPush.AllowInsecureEndpoints
+	}
+	// webPushCanRead 校验接收者能否看到该频道，避免把内容推给无权限的用户
+	webPushCanRead = CanReadChannelByUserId
+
+	webPushVAPIDMu     sync.Mutex
+	webPushVAPIDCached *webPushVAPIDKeys
+)
+
+type webPushVAPIDKeys struct {
+	publicKey  string
+	privateKey *ecdsa.PrivateKey
+}
+
+// WebPushPayload 推送到浏览器的通知内容，由 Service Worker 解析展示
+type WebPushPayload struct {
+	Kind      string `json:"kind"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	URL       string `json:"url,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	ChannelID string `json:"channelId,omitempty"`
+	MessageID string `json:"messageId,omitempty"`
+}
+
+// WebPushMessageEvent 一条新消息涉及的推送对象
+type WebPushMessageEvent struct {
+	ChannelID     string
+	WorldID       string
+	ChannelName   string
+	MessageID     string
+	SenderID      string
+	SenderName    string
+	Content       string
+	MentionIDs    []string
+	WhisperIDs    []string
+	ReplyToUserID string
+}
+
+// WebPushEnabled 判断站点是否开启浏览器推送
+func WebPushEnabled() bool {
+	cfg := utils.GetConfig()
+	return cfg != nil && cfg.WebPush.Enabled
+}
+
+// WebPushVAPIDPublicKey 返回供浏览器 PushManager.subscribe 使用的 applicationServerKey
+func WebPushVAPIDPublicKey() (string, error) {
+	keys, err := loadWebPushVAPIDKeys()
+	if err != nil {
+		return "", err
+	}
+	return keys.publicKey, nil
+}
+
+// loadWebPushVAPIDKeys 读取 VAPID 密钥对，首次使用时生成并写入数据库
+func loadWebPushVAPIDKeys() (*webPushVAPIDKeys, error) {
+	webPushVAPIDMu.Lock()
+	defer webPushVAPIDMu.Unlock()
+	if webPushVAPIDCached != nil {
+		return webPushVAPIDCached, nil
+	}
+
+	record, err := model.WebPushVAPIDKeyGet()
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := webPushEncodePublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		record, err = model.WebPushVAPIDKeyCreateIfAbsent(publicKey, base64.RawURLEncoding.EncodeToString(der))
+		if err != nil {
+			return nil, err
+		}
+		log.Println("web-push: 已生成 VAPID 密钥对")
+	}
+
+	der, err := base64.RawURLEncoding.DecodeString(record.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析 VAPID 私钥失败: %w", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析 VAPID 私钥失败: %w", err)
+	}
+	privateKey, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("VAPID 私钥类型错误")
+	}
+	webPushVAPIDCached = &webPushVAPIDKeys{publicKey: record.PublicKey, privateKey: privateKey}
+	return webPushVAPIDCached, nil
+}
+
+func webPushEncodePublicKey(pub *ecdsa.PublicKey) (string, error) {
+	ecdhKey, err := pub.ECDH()
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(ecdhKey.Bytes()), nil
+}
+
+// webPushDecodeKey 兼容浏览器给出的 base64url（有无填充）与标准 base64
+func webPushDecodeKey(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	if value == "" {
+		return nil, ErrWebPushInvalidSubscription
+	}
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	decoded, err := base64.RawStdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrWebPushInvalidSubscription
+	}
+	return decoded, nil
+}
+
+// newWebPushHTTPClient 推送专用客户端：拨号时拒绝内网地址，不走代理也不跟随跳转，
+// 避免订阅端点被用来探测服务器所在的内网
+func newWebPushHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if webPushAllowInsecure() {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || webPushBlockedIP(ip) {
+				return ErrWebPushBlockedEndpoint
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        32,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func webPushBlockedIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	// 100.64.0.0/10 运营商级 NAT 地址
+	if v4 := ip.To4(); v4 != nil && v4[0] == 100 && v4[1]&0xc0 == 64 {
+		return true
+	}
+	return false
+}
+
+// webPushCheckEndpoint 校验端点协议与主机：默认只允许 https 且不能是内网地址字面量，
+// 域名解析到内网的情况由拨号时再次拦截
+func webPushCheckEndpoint(endpoint string) error {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || parsed.Hostname() == "" {
+		return ErrWebPushInvalidSubscription
+	}
+	insecure := webPushAllowInsecure()
+	if parsed.Scheme != "https" && !(insecure && parsed.Scheme == "http") {
+		return ErrWebPushInvalidSubscription
+	}
+	if insecure {
+		return nil
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebPushBlockedEndpoint
+	}
+	if ip := net.ParseIP(host); ip != nil && webPushBlockedIP(ip) {
+		return ErrWebPushBlockedEndpoint
+	}
+	return nil
+}
+
+// ValidateWebPushSubscription 校验浏览器提交的订阅信息
+func ValidateWebPushSubscription(endpoint, p256dh, auth string) error {
+	if err := webPushCheckEndpoint(endpoint); err != nil {
+		return err
+	}
+	pub, err := webPushDecodeKey(p256dh)
+	if err != nil {
+		return err
+	}
+	if _, err := ecdh.P256().NewPublicKey(pub); err != nil {
+		return ErrWebPushInvalidSubscription
+	}
+	authSecret, err := webPushDecodeKey(auth)
+	if err != nil || len(authSecret) != 16 {
+		return ErrWebPushInvalidSubscription
+	}
+	return nil
+}
+
+// webPushEncrypt 按 RFC 8291 (aes128gcm) 加密推送内容
+func webPushEncrypt(plaintext []byte, p256dh, auth string) ([]byte, error) {
+	uaPublicRaw, err := webPushDecodeKey(p256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := webPushDecodeKey(auth)
+	if err != nil {
+		return nil, err
+	}
+	curve := ecdh.P256()
+	uaPublic, err := curve.NewPublicKey(uaPublicRaw)
+	if err != nil {
+		return nil, ErrWebPushInvalidSubscription
+	}
+	asPrivate, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	asPublicRaw := asPrivate.PublicKey().Bytes()
+
+	keyInfo := make([]byte, 0, 14+65+65)
+	keyInfo = append(keyInfo, "WebPush: info\x00"...)
+	keyInfo = append(keyInfo, uaPublicRaw...)
+	keyInfo = append(keyInfo, asPublicRaw...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 单条记录，末尾追加 0x02 作为最后一条记录的分隔符
+	record := make([]byte, 0, len(plaintext)+1)
+	record = append(record, plaintext...)
+	record = append(record, 0x02)
+	if len(record)+gcm.Overhead() > webPushRecordSize-86 {
+		return nil, errors.New("推送内容过长")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(salt)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(webPushRecordSize))
+	buf.WriteByte(byte(len(asPublicRaw)))
+	buf.Write(asPublicRaw)
+	buf.Write(gcm.Seal(nil, nonce, record, nil))
+	return buf.Bytes(), nil
+}
+
+func webPushSubject() string {
+	cfg := utils.GetConfig()
+	if cfg == nil {
+		return "mailto:admin@localhost"
+	}
+	if subject := strings.TrimSpace(cfg.WebPush.Subject); subject != "" {
+		return subject
+	}
+	if site := normalizeSiteURL(cfg.Domain); site != "" {
+		return site
+	}
+	return "mailto:admin@localhost"
+}
+
+// webPushVAPIDAuthorization 生成 RFC 8292 的 Authorization 头
+func webPushVAPIDAuthorization(endpoint string, keys *webPushVAPIDKeys) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]any{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": webPushNow().Add(webPushJWTExpiration).Unix(),
+		"sub": webPushSubject(),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, keys.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, keys.publicKey), nil
+}
+
+// sendWebPush 向单个订阅投递一条通知
+func sendWebPush(sub *model.WebPushSubscriptionModel, payload []byte, urgency string) error {
+	if err := webPushCheckEndpoint(sub.Endpoint); err != nil {
+		return err
+	}
+	keys, err := loadWebPushVAPIDKeys()
+	if err != nil {
+		return err
+	}
+	body, err := webPushEncrypt(payload, sub.P256dh, sub.Auth)
+	if err != nil {
+		return err
+	}
+	authorization, err := webPushVAPIDAuthorization(sub.Endpoint, keys)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(webPushTTLSeconds))
+	req.Header.Set("Authorization", authorization)
+	if urgency != "" {
+		req.Header.Set("Urgency", urgency)
+	}
+	resp, err := webPushHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrWebPushSubscriptionGone
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	default:
+		return fmt.Errorf("推送服务返回 %d", resp.StatusCode)
+	}
+}
+
+// deliverWebPush 投递并维护订阅状态：失效或连续失败过多的订阅会被移除
+func deliverWebPush(sub *model.WebPushSubscriptionModel, payload []byte, urgency string) bool {
+	err := sendWebPush(sub, payload, urgency)
+	if err == nil {
+		_ = model.WebPushSubscriptionMarkResult(sub.ID, true)
+		return true
+	}
+	if errors.Is(err, ErrWebPushSubscriptionGone) || errors.Is(err, ErrWebPushBlockedEndpoint) ||
+		errors.Is(err, ErrWebPushInvalidSubscription) || sub.FailCount+1 >= webPushMaxFailCount {
+		_ = model.WebPushSubscriptionDeleteByID(sub.ID)
+	} else {
+		_ = model.WebPushSubscriptionMarkResult(sub.ID, false)
+	}
+	log.Printf("web-push: 投递失败 user=%s subscription=%s err=%v", sub.UserID, sub.ID, err)
+	return false
+}
+
+// WebPushSendTest 向用户的所有设备发送测试通知，返回成功数量
+func WebPushSendTest(userID string) (int, error) {
+	if !WebPushEnabled() {
+		return 0, ErrWebPushDisabled
+	}
+	subs, err := model.WebPushSubscriptionListByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	payload, _ := json.Marshal(&WebPushPayload{
+		Kind:  WebPushKindTest,
+		Title: "SealChat",
+		Body:  "浏览器推送已开启",
+		Tag:   "sealchat-test",
+	})
+	sent := 0
+	for _, sub := range subs {
+		if deliverWebPush(sub, payload, "normal") {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// WebPushNotifyMessage 新消息产生后推送给被提及、被悄悄话、被回复的用户
+func WebPushNotifyMessage(ev *WebPushMessageEvent) {
+	if ev == nil || !WebPushEnabled() {
+		return
+	}
+	dispatchWebPushMessage(ev)
+}
+
+// resolveWebPushTargets 计算每个接收者的推送类型，同一人只推一次，优先级：悄悄话 > 提及 > 回复
+func resolveWebPushTargets(ev *WebPushMessageEvent) map[string]string {
+	targets := map[string]string{}
+	assign := func(userID, kind string) {
+		userID = strings.TrimSpace(userID)
+		if userID == "" || userID == ev.SenderID {
+			return
+		}
+		if _, exists := targets[userID]; !exists {
+			targets[userID] = kind
+		}
+	}
+	for _, id := range ev.WhisperIDs {
+		assign(id, WebPushKindWhisper)
+	}
+	for _, id := range ev.MentionIDs {
+		assign(id, WebPushKindMention)
+	}
+	assign(ev.ReplyToUserID, WebPushKindReply)
+	return targets
+}
+
+func buildWebPushMessagePayload(ev *WebPushMessageEvent, kind string) *WebPushPayload {
+	sender := strings.TrimSpace(ev.SenderName)
+	if sender == "" {
+		sender = "有人"
+	}
+	channelName := strings.TrimSpace(ev.ChannelName)
+	var title string
+	switch kind {
+	case WebPushKindWhisper:
+		title = sender + " 对你说了悄悄话"
+	case WebPushKindReply:
+		title = sender + " 回复了你"
+	default:
+		title = sender + " 提到了你"
+	}
+	if channelName != "" {
+		title += "（" + channelName + "）"
+	}
+	body := strings.TrimSpace(NormalizeMessageContentToPlainText(ev.Content))
+	if runes := []rune(body); len(runes) > webPushBodyMaxRunes {
+		body = string(runes[:webPushBodyMaxRunes]) + "…"
+	}
+	payload := &WebPushPayload{
+		Kind:      kind,
+		Title:     title,
+		Body:      body,
+		Tag:       "sealchat-" + ev.ChannelID,
+		ChannelID: ev.ChannelID,
+		MessageID: ev.MessageID,
+	}
+	if ev.WorldID != "" {
+		payload.URL = "#/" + url.PathEscape(ev.WorldID) + "/" + url.PathEscape(ev.ChannelID)
+	}
+	return payload
+}
+
+// dispatchWebPushMessage 返回成功投递的订阅数量
+func dispatchWebPushMessage(ev *WebPushMessageEvent) int {
+	targets := resolveWebPushTargets(ev)
+	if len(targets) == 0 {
+		return 0
+	}
+	userIDs := make([]string, 0, len(targets))
+	for id := range targets {
+		userIDs = append(userIDs, id)
+	}
+	subs, err := model.WebPushSubscriptionListByUsers(userIDs)
+	if err != nil {
+		log.Printf("web-push: 获取订阅失败 channel=%s err=%v", ev.ChannelID, err)
+		return 0
+	}
+	if len(subs) == 0 {
+		return 0
+	}
+	subsByUser := map[string][]*model.WebPushSubscriptionModel{}
+	subscribed := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		if _, ok := subsByUser[sub.UserID]; !ok {
+			subscribed = append(subscribed, sub.UserID)
+		}
+		subsByUser[sub.UserID] = append(subsByUser[sub.UserID], sub)
+	}
+	sent := 0
+	now := notificationNow()
+	for _, userID := range subscribed {
+		// 提及、悄悄话、回复都属于直接相关的通知，仍受频道级别、免打扰与静默时段约束
+		policy, err := model.NotificationPolicyResolve(nil, userID, ev.WorldID, ev.ChannelID)
+		if err != nil {
+			log.Printf("web-push: 获取通知偏好失败 user=%s channel=%s err=%v", userID, ev.ChannelID, err)
+			continue
+		}
+		if !policy.Allows(model.NotificationKindMention, now) {
+			continue
+		}
+		kind := targets[userID]
+		if kind != WebPushKindWhisper && !webPushCanRead(userID, ev.ChannelID) {
+			continue
+		}
+		urgency := "normal"
+		if kind != WebPushKindReply {
+			urgency = "high"
+		}
+		payload, _ := json.Marshal(buildWebPushMessagePayload(ev, kind))
+		for _, sub := range subsByUser[userID] {
+			if deliverWebPush(sub, payload, urgency) {
+				sent++
+			}
+		}
+	}
+	return sent
+}
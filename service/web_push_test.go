@@ -0,0 +1,327 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"sealchat/model"
+)
+
+// fakeWebPushClient 模拟浏览器端的订阅密钥
+type fakeWebPushClient struct {
+	privateKey *ecdh.PrivateKey
+	auth       []byte
+}
+
+func newFakeWebPushClient(t *testing.T) *fakeWebPushClient {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	return &fakeWebPushClient{privateKey: key, auth: auth}
+}
+
+func (c *fakeWebPushClient) p256dh() string {
+	return base64.RawURLEncoding.EncodeToString(c.privateKey.PublicKey().Bytes())
+}
+
+func (c *fakeWebPushClient) authSecret() string {
+	return base64.RawURLEncoding.EncodeToString(c.auth)
+}
+
+// decrypt 按 RFC 8291 解密推送内容
+func (c *fakeWebPushClient) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	if len(body) < 86 {
+		t.Fatalf("payload too short: %d", len(body))
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != webPushRecordSize {
+		t.Fatalf("unexpected record size: %d", rs)
+	}
+	idLen := int(body[20])
+	asPublicRaw := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicRaw)
+	if err != nil {
+		t.Fatalf("invalid server key: %v", err)
+	}
+	shared, err := c.privateKey.ECDH(asPublic)
+	if err != nil {
+		t.Fatalf("ecdh failed: %v", err)
+	}
+	info := append([]byte("WebPush: info\x00"), c.privateKey.PublicKey().Bytes()...)
+	info = append(info, asPublicRaw...)
+	ikm, _ := hkdf.Key(sha256.New, shared, c.auth, string(info), 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing record delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+func verifyWebPushVAPIDHeader(t *testing.T, header string) {
+	t.Helper()
+	if !strings.HasPrefix(header, "vapid t=") {
+		t.Fatalf("unexpected authorization header: %s", header)
+	}
+	var token, publicKey string
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "t="):
+			token = strings.TrimPrefix(part, "t=")
+		case strings.HasPrefix(part, "k="):
+			publicKey = strings.TrimPrefix(part, "k=")
+		}
+	}
+	parts := strings.Split(token, ".")
+	raw, err := base64.RawURLEncoding.DecodeString(publicKey)
+	if len(parts) != 3 || err != nil || len(raw) != 65 {
+		t.Fatalf("malformed vapid header: %s", header)
+	}
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[1:33]),
+		Y:     new(big.Int).SetBytes(raw[33:]),
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(signature) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Fatalf("vapid signature invalid")
+	}
+}
+
+type fakeWebPushServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	clients  map[string]*fakeWebPushClient
+	gone     map[string]bool
+	received map[string][]WebPushPayload
+}
+
+// newFakeWebPushServer 本地假推送服务：校验请求头与签名并解密内容，监听在回环地址上因此需放行内网端点
+func newFakeWebPushServer(t *testing.T) *fakeWebPushServer {
+	stubWebPushAllowInsecure(t, true)
+	srv := &fakeWebPushServer{
+		clients:  map[string]*fakeWebPushClient{},
+		gone:     map[string]bool{},
+		received: map[string][]WebPushPayload{},
+	}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		if srv.gone[r.URL.Path] {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		client := srv.clients[r.URL.Path]
+		if client == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+			t.Errorf("missing push headers: %v", r.Header)
+		}
+		verifyWebPushVAPIDHeader(t, r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		var payload WebPushPayload
+		if err := json.Unmarshal(client.decrypt(t, body), &payload); err != nil {
+			t.Errorf("decode payload failed: %v", err)
+		}
+		srv.received[r.URL.Path] = append(srv.received[r.URL.Path], payload)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *fakeWebPushServer) subscribe(t *testing.T, userID, path string) {
+	t.Helper()
+	client := newFakeWebPushClient(t)
+	s.mu.Lock()
+	s.clients[path] = client
+	s.mu.Unlock()
+	if _, err := model.WebPushSubscriptionUpsert(userID, s.URL+path, client.p256dh(), client.authSecret(), "test"); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+}
+
+func stubWebPushAllowInsecure(t *testing.T, allowed bool) {
+	original := webPushAllowInsecure
+	webPushAllowInsecure = func() bool { return allowed }
+	t.Cleanup(func() { webPushAllowInsecure = original })
+}
+
+func stubWebPushCanRead(t *testing.T, allowed map[string]bool) {
+	original := webPushCanRead
+	webPushCanRead = func(userID, channelID string) bool { return allowed[userID] }
+	t.Cleanup(func() { webPushCanRead = original })
+}
+
+func TestWebPushDispatchMentionWhisperReply(t *testing.T) {
+	initTestDB(t)
+	srv := newFakeWebPushServer(t)
+	stubWebPushCanRead(t, map[string]bool{"u-mention": true, "u-reply": true, "u-sender": true})
+
+	srv.subscribe(t, "u-mention", "/mention")
+	srv.subscribe(t, "u-whisper", "/whisper")
+	srv.subscribe(t, "u-reply", "/reply")
+	srv.subscribe(t, "u-sender", "/sender")
+	srv.subscribe(t, "u-outsider", "/outsider")
+
+	sent := dispatchWebPushMessage(&WebPushMessageEvent{
+		ChannelID:     "ch-push",
+		WorldID:       "w-push",
+		ChannelName:   "大厅",
+		MessageID:     "msg-1",
+		SenderID:      "u-sender",
+		SenderName:    "Alice",
+		Content:       "今晚开团吗？",
+		MentionIDs:    []string{"u-mention", "u-sender", "u-outsider", "u-whisper"},
+		WhisperIDs:    []string{"u-whisper"},
+		ReplyToUserID: "u-reply",
+	})
+	if sent != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", sent)
+	}
+	if len(srv.received["/sender"]) != 0 || len(srv.received["/outsider"]) != 0 {
+		t.Fatalf("sender and unreadable users should not be notified: %+v", srv.received)
+	}
+	if got := srv.received["/whisper"]; len(got) != 1 || got[0].Kind != WebPushKindWhisper {
+		t.Fatalf("whisper should win over mention: %+v", got)
+	}
+	mention := srv.received["/mention"]
+	if len(mention) != 1 || mention[0].Kind != WebPushKindMention || mention[0].Body != "今晚开团吗？" {
+		t.Fatalf("unexpected mention payload: %+v", mention)
+	}
+	if mention[0].URL != "#/w-push/ch-push" || !strings.Contains(mention[0].Title, "Alice") {
+		t.Fatalf("unexpected mention target: %+v", mention[0])
+	}
+	if got := srv.received["/reply"]; len(got) != 1 || got[0].Kind != WebPushKindReply {
+		t.Fatalf("unexpected reply payload: %+v", got)
+	}
+}
+
+func TestWebPushRespectsNotificationPreferenceAndDropsGoneSubscriptions(t *testing.T) {
+	initTestDB(t)
+	srv := newFakeWebPushServer(t)
+	stubWebPushCanRead(t, map[string]bool{"u-a": true, "u-b": true})
+
+	srv.subscribe(t, "u-a", "/a")
+	srv.subscribe(t, "u-b", "/b-old")
+	srv.subscribe(t, "u-b", "/b-new")
+	srv.mu.Lock()
+	srv.gone["/b-old"] = true
+	srv.mu.Unlock()
+
+	mute := &model.NotificationPreferenceModel{
+		UserID:    "u-a",
+		ScopeType: model.NotificationScopeChannel,
+		ScopeID:   "ch-mute",
+		Level:     model.NotificationLevelNone,
+	}
+	if err := model.NotificationPreferenceUpsert(mute); err != nil {
+		t.Fatalf("mute failed: %v", err)
+	}
+	event := &WebPushMessageEvent{
+		ChannelID:  "ch-mute",
+		MessageID:  "msg-2",
+		SenderID:   "u-sender",
+		Content:    "hi",
+		MentionIDs: []string{"u-a", "u-b"},
+	}
+	if sent := dispatchWebPushMessage(event); sent != 1 {
+		t.Fatalf("expected 1 delivery, got %d", sent)
+	}
+	if len(srv.received["/a"]) != 0 {
+		t.Fatalf("muted channel should not push")
+	}
+	subs, _ := model.WebPushSubscriptionListByUser("u-b")
+	if len(subs) != 1 || !strings.HasSuffix(subs[0].Endpoint, "/b-new") {
+		t.Fatalf("gone subscription should be removed: %+v", subs)
+	}
+
+	if err := model.NotificationPreferenceDelete("u-a", model.NotificationScopeChannel, "ch-mute"); err != nil {
+		t.Fatalf("unmute failed: %v", err)
+	}
+	if sent := dispatchWebPushMessage(event); sent != 2 {
+		t.Fatalf("expected 2 deliveries after unmute, got %d", sent)
+	}
+}
+
+func TestValidateWebPushSubscription(t *testing.T) {
+	stubWebPushAllowInsecure(t, false)
+	client := newFakeWebPushClient(t)
+	if err := ValidateWebPushSubscription("https://push.example.com/x", client.p256dh(), client.authSecret()); err != nil {
+		t.Fatalf("valid subscription rejected: %v", err)
+	}
+	invalid := [][3]string{
+		{"ftp://push.example.com/x", client.p256dh(), client.authSecret()},
+		{"http://push.example.com/x", client.p256dh(), client.authSecret()},
+		{"https://127.0.0.1/x", client.p256dh(), client.authSecret()},
+		{"https://localhost:8443/x", client.p256dh(), client.authSecret()},
+		{"https://10.0.0.5/x", client.p256dh(), client.authSecret()},
+		{"https://169.254.169.254/latest", client.p256dh(), client.authSecret()},
+		{"https://[::1]/x", client.p256dh(), client.authSecret()},
+		{"https://push.example.com/x", "not-a-key", client.authSecret()},
+		{"https://push.example.com/x", client.p256dh(), "c2hvcnQ"},
+	}
+	for i, item := range invalid {
+		if err := ValidateWebPushSubscription(item[0], item[1], item[2]); err == nil {
+			t.Fatalf("case %d should be rejected", i)
+		}
+	}
+}
+
+func TestWebPushDialRejectsPrivateAddress(t *testing.T) {
+	initTestDB(t)
+	srv := newFakeWebPushServer(t)
+	stubWebPushCanRead(t, map[string]bool{"u-dial": true})
+	srv.subscribe(t, "u-dial", "/dial")
+	// 关闭放行后，即便端点是 https 形式，拨号到回环地址也会被拦截
+	stubWebPushAllowInsecure(t, false)
+	_, err := webPushHTTPClient.Post(strings.Replace(srv.URL, "http://", "https://", 1)+"/dial", "application/octet-stream", nil)
+	if !errors.Is(err, ErrWebPushBlockedEndpoint) {
+		t.Fatalf("dial to loopback should be blocked, got %v", err)
+	}
+	subs, err := model.WebPushSubscriptionListByUser("u-dial")
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("list subscriptions failed: %v", err)
+	}
+	if deliverWebPush(subs[0], []byte(`{}`), "") {
+		t.Fatal("http endpoint should not be delivered")
+	}
+	if subs, _ := model.WebPushSubscriptionListByUser("u-dial"); len(subs) != 0 {
+		t.Fatalf("blocked subscription should be removed, got %d", len(subs))
+	}
+}
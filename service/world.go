@@ -964,6 +964,15 @@ func ListWorldArchives(userID string) ([]string, error) {
 	return ids, nil
 }
 
+// GetWorldMemberRole 返回用户在世界中的角色，非成员返回空字符串
+func GetWorldMemberRole(worldID, userID string) string {
+	var member model.WorldMemberModel
+	if err := model.GetDB().Where("world_id = ? AND user_id = ?", worldID, userID).Limit(1).Find(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
 func worldRoleEquals(worldID, userID, role string) bool {
 	var member model.WorldMemberModel
 	err := model.GetDB().Where("world_id = ? AND user_id = ?", worldID, userID).Limit(1).Find(&member).Error
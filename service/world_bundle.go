@@ -0,0 +1,607 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/service/storage"
+)
+
+const (
+	worldBundleFormat       = "sealchat-world-bundle"
+	worldBundleVersion      = 1
+	worldBundleManifestFile = "manifest.json"
+	maxWorldBundleBlobSize  = 512 * 1024 * 1024
+)
+
+var ErrWorldBundleInvalid = errors.New("世界包格式无效")
+
+// WorldBundleManifest 世界包清单，记录来源、各数据文件条目数与附带的二进制文件
+type WorldBundleManifest struct {
+	Format        string            `json:"format"`
+	Version       int               `json:"version"`
+	ExportedAt    time.Time         `json:"exportedAt"`
+	SourceWorldID string            `json:"sourceWorldId"`
+	WorldName     string            `json:"worldName"`
+	Counts        map[string]int    `json:"counts"`
+	Attachments   []WorldBundleBlob `json:"attachments"`
+	AudioAssets   []WorldBundleBlob `json:"audioAssets"`
+}
+
+type WorldBundleBlob struct {
+	ID       string `json:"id"`
+	File     string `json:"file"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType,omitempty"`
+	Size     int64  `json:"size"`
+}
+
+// WorldBundleWorld 只保留可跨实例迁移的世界设置，BOT 与旁观链接等实例相关配置不导出
+type WorldBundleWorld struct {
+	ID                                    string `json:"id"`
+	Name                                  string `json:"name"`
+	Description                           string `json:"description"`
+	Avatar                                string `json:"avatar"`
+	Visibility                            string `json:"visibility"`
+	EnforceMembership                     bool   `json:"enforceMembership"`
+	AllowAdminEditMessages                bool   `json:"allowAdminEditMessages"`
+	AllowManageOtherUserChannelIdentities bool   `json:"allowManageOtherUserChannelIdentities"`
+	AllowMemberEditKeywords               bool   `json:"allowMemberEditKeywords"`
+	StrictWhisperPrivacy                  bool   `json:"strictWhisperPrivacy"`
+	CharacterCardBadgeTemplate            string `json:"characterCardBadgeTemplate"`
+	DefaultChannelID                      string `json:"defaultChannelId"`
+}
+
+// WorldBundleUser 用于在目标实例按用户名匹配账号
+type WorldBundleUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+}
+
+// worldBundleVariant 补充模型中不参与 JSON 序列化的外观配置
+type worldBundleVariant struct {
+	model.ChannelIdentityVariantModel
+	AppearanceJSON string `json:"appearanceJson"`
+}
+
+type worldBundleData struct {
+	World                WorldBundleWorld
+	Users                []WorldBundleUser
+	Members              []model.WorldMemberModel
+	Channels             []model.ChannelModel
+	ChannelMembers       []model.MemberModel
+	Roles                []model.ChannelRoleModel
+	RolePermissions      []model.RolePermissionModel
+	RoleMappings         []model.UserRoleMappingModel
+	Identities           []model.ChannelIdentityModel
+	IdentityFolders      []model.ChannelIdentityFolderModel
+	IdentityFolderItems  []model.ChannelIdentityFolderMemberModel
+	IdentityVariants     []worldBundleVariant
+	CharacterCards       []model.CharacterCardModel
+	CardTemplates        []model.CharacterCardTemplateModel
+	WorldCardTemplates   []model.WorldCharacterCardTemplateBindingModel
+	CardTemplateBindings []model.CharacterCardTemplateBindingModel
+	CardAvatarBindings   []model.CharacterCardAvatarBindingModel
+	KeywordCategories    []model.WorldKeywordCategoryModel
+	Keywords             []model.WorldKeywordModel
+	StickyNoteFolders    []model.StickyNoteFolderModel
+	StickyNotes          []model.StickyNoteModel
+	IForms               []model.ChannelIFormModel
+	WorldIForms          []model.WorldIFormBindingModel
+	DiceMacros           []model.DiceMacroModel
+	InitiativeTrackers   []model.InitiativeTrackerModel
+	InitiativeCombatants []model.InitiativeCombatantModel
+	AudioScenes          []model.AudioScene
+	AudioAssets          []model.AudioAsset
+}
+
+type worldBundleSection struct {
+	Name  string
+	Value any
+}
+
+// sections 定义数据文件与字段的对应关系，导出与导入共用
+func (d *worldBundleData) sections() []worldBundleSection {
+	return []worldBundleSection{
+		{"world", &d.World},
+		{"users", &d.Users},
+		{"members", &d.Members},
+		{"channels", &d.Channels},
+		{"channelMembers", &d.ChannelMembers},
+		{"roles", &d.Roles},
+		{"rolePermissions", &d.RolePermissions},
+		{"roleMappings", &d.RoleMappings},
+		{"identities", &d.Identities},
+		{"identityFolders", &d.IdentityFolders},
+		{"identityFolderItems", &d.IdentityFolderItems},
+		{"identityVariants", &d.IdentityVariants},
+		{"characterCards", &d.CharacterCards},
+		{"cardTemplates", &d.CardTemplates},
+		{"worldCardTemplates", &d.WorldCardTemplates},
+		{"cardTemplateBindings", &d.CardTemplateBindings},
+		{"cardAvatarBindings", &d.CardAvatarBindings},
+		{"keywordCategories", &d.KeywordCategories},
+		{"keywords", &d.Keywords},
+		{"stickyNoteFolders", &d.StickyNoteFolders},
+		{"stickyNotes", &d.StickyNotes},
+		{"iforms", &d.IForms},
+		{"worldIForms", &d.WorldIForms},
+		{"diceMacros", &d.DiceMacros},
+		{"initiativeTrackers", &d.InitiativeTrackers},
+		{"initiativeCombatants", &d.InitiativeCombatants},
+		{"audioScenes", &d.AudioScenes},
+		{"audioAssets", &d.AudioAssets},
+	}
+}
+
+func worldBundleSectionFile(name string) string {
+	return "data/" + name + ".json"
+}
+
+// WorldBundleExport 将世界及其频道配置打包为 zip 写入 w
+func WorldBundleExport(worldID string, actorID string, w io.Writer) (*WorldBundleManifest, error) {
+	worldID = strings.TrimSpace(worldID)
+	if worldID == "" {
+		return nil, errors.New("worldId 不能为空")
+	}
+	if !IsWorldOwner(worldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	var world model.WorldModel
+	if err := model.GetDB().Where("id = ? AND status <> ?", worldID, "deleted").Limit(1).Find(&world).Error; err != nil {
+		return nil, err
+	}
+	if world.ID == "" {
+		return nil, ErrWorldNotFound
+	}
+
+	data, err := loadWorldBundleData(&world)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &WorldBundleManifest{
+		Format:        worldBundleFormat,
+		Version:       worldBundleVersion,
+		ExportedAt:    time.Now(),
+		SourceWorldID: world.ID,
+		WorldName:     world.Name,
+		Counts:        map[string]int{},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, section := range data.sections() {
+		if rv := reflect.ValueOf(section.Value).Elem(); rv.Kind() == reflect.Slice {
+			manifest.Counts[section.Name] = rv.Len()
+		}
+		if err := writeWorldBundleJSON(zw, worldBundleSectionFile(section.Name), section.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, token := range collectWorldBundleAttachmentTokens(data) {
+		att, _ := ResolveAttachment(token)
+		blob, mimeType, _, err := loadAttachmentBytes(token, att)
+		if err != nil || len(blob) == 0 {
+			continue
+		}
+		entry := WorldBundleBlob{ID: token, MimeType: mimeType, Size: int64(len(blob))}
+		if att != nil {
+			entry.Filename = att.Filename
+			if entry.MimeType == "" {
+				entry.MimeType = att.MimeType
+			}
+		}
+		entry.File = "attachments/" + token + worldBundleBlobExt(entry.Filename, entry.MimeType)
+		fw, err := zw.Create(entry.File)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(blob); err != nil {
+			return nil, err
+		}
+		manifest.Attachments = append(manifest.Attachments, entry)
+	}
+
+	for i := range data.AudioAssets {
+		asset := &data.AudioAssets[i]
+		entry := WorldBundleBlob{ID: asset.ID, Filename: asset.Name}
+		entry.File = "audio/" + asset.ID + worldBundleBlobExt(asset.ObjectKey, "")
+		fw, err := zw.Create(entry.File)
+		if err != nil {
+			return nil, err
+		}
+		size, err := copyWorldBundleAudio(asset, fw)
+		if err != nil {
+			// 音频文件缺失时保留场景配置，导入时对应轨道会被清空
+			continue
+		}
+		entry.Size = size
+		manifest.AudioAssets = append(manifest.AudioAssets, entry)
+	}
+
+	if err := writeWorldBundleJSON(zw, worldBundleManifestFile, manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeWorldBundleJSON(zw *zip.Writer, name string, value any) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(fw)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func worldBundleBlobExt(name string, mimeType string) string {
+	if ext := strings.ToLower(path.Ext(strings.TrimSpace(name))); ext != "" && len(ext) <= 8 {
+		return ext
+	}
+	if mimeType != "" {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
+
+func loadWorldBundleData(world *model.WorldModel) (*worldBundleData, error) {
+	db := model.GetDB()
+	data := &worldBundleData{
+		World: WorldBundleWorld{
+			ID:                                    world.ID,
+			Name:                                  world.Name,
+			Description:                           world.Description,
+			Avatar:                                world.Avatar,
+			Visibility:                            world.Visibility,
+			EnforceMembership:                     world.EnforceMembership,
+			AllowAdminEditMessages:                world.AllowAdminEditMessages,
+			AllowManageOtherUserChannelIdentities: world.AllowManageOtherUserChannelIdentities,
+			AllowMemberEditKeywords:               world.AllowMemberEditKeywords,
+			StrictWhisperPrivacy:                  world.StrictWhisperPrivacy,
+			CharacterCardBadgeTemplate:            world.CharacterCardBadgeTemplate,
+			DefaultChannelID:                      world.DefaultChannelID,
+		},
+	}
+
+	if err := db.Where("world_id = ?", world.ID).Find(&data.Members).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("world_id = ? AND status <> ? AND is_private = ?", world.ID, model.ChannelStatusDeleted, false).
+		Order("created_at asc").Find(&data.Channels).Error; err != nil {
+		return nil, err
+	}
+	channelIDs := make([]string, 0, len(data.Channels))
+	for _, ch := range data.Channels {
+		channelIDs = append(channelIDs, ch.ID)
+	}
+
+	if len(channelIDs) > 0 {
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.ChannelMembers).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.Roles).Error; err != nil {
+			return nil, err
+		}
+		roleIDs := make([]string, 0, len(data.Roles))
+		for _, role := range data.Roles {
+			roleIDs = append(roleIDs, role.ID)
+		}
+		if len(roleIDs) > 0 {
+			if err := db.Where("role_id IN ?", roleIDs).Find(&data.RolePermissions).Error; err != nil {
+				return nil, err
+			}
+			if err := db.Where("role_type = ? AND role_id IN ?", "channel", roleIDs).Find(&data.RoleMappings).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		if err := db.Where("channel_id IN ? AND is_temporary = ?", channelIDs, false).
+			Order("sort_order asc").Find(&data.Identities).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.IdentityFolders).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.IdentityFolderItems).Error; err != nil {
+			return nil, err
+		}
+		var variants []model.ChannelIdentityVariantModel
+		if err := db.Where("channel_id IN ?", channelIDs).
+			Order("identity_id asc, sort_order asc, created_at asc").Find(&variants).Error; err != nil {
+			return nil, err
+		}
+		for _, variant := range variants {
+			data.IdentityVariants = append(data.IdentityVariants, worldBundleVariant{
+				ChannelIdentityVariantModel: variant,
+				AppearanceJSON:              variant.AppearanceJSON,
+			})
+		}
+
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.CharacterCards).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.CardTemplateBindings).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.CardAvatarBindings).Error; err != nil {
+			return nil, err
+		}
+
+		if err := db.Where("channel_id IN ? AND is_deleted = ?", channelIDs, false).Find(&data.StickyNoteFolders).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ? AND is_deleted = ?", channelIDs, false).
+			Order("order_index asc").Find(&data.StickyNotes).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Order("order_index asc").Find(&data.IForms).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.DiceMacros).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Find(&data.InitiativeTrackers).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("channel_id IN ?", channelIDs).Order("sort_order asc").Find(&data.InitiativeCombatants).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.Where("world_id = ?", world.ID).Find(&data.WorldCardTemplates).Error; err != nil {
+		return nil, err
+	}
+	templateIDs := map[string]struct{}{}
+	for _, binding := range data.WorldCardTemplates {
+		templateIDs[binding.TemplateID] = struct{}{}
+	}
+	for _, binding := range data.CardTemplateBindings {
+		if binding.TemplateID != "" {
+			templateIDs[binding.TemplateID] = struct{}{}
+		}
+	}
+	if len(templateIDs) > 0 {
+		if err := db.Where("id IN ?", worldBundleSetKeys(templateIDs)).Find(&data.CardTemplates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.Where("world_id = ?", world.ID).Order("priority desc").Find(&data.KeywordCategories).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("world_id = ?", world.ID).Order("sort_order desc").Find(&data.Keywords).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("world_id = ?", world.ID).Find(&data.WorldIForms).Error; err != nil {
+		return nil, err
+	}
+	formIDs := map[string]struct{}{}
+	for _, form := range data.IForms {
+		formIDs[form.ID] = struct{}{}
+	}
+	for _, binding := range data.WorldIForms {
+		if _, ok := formIDs[binding.FormID]; ok {
+			continue
+		}
+		// 世界共享的 iForm 可能来自已不在导出范围内的频道
+		var form model.ChannelIFormModel
+		if err := db.Where("id = ?", binding.FormID).Limit(1).Find(&form).Error; err != nil {
+			return nil, err
+		}
+		if form.ID != "" {
+			formIDs[form.ID] = struct{}{}
+			data.IForms = append(data.IForms, form)
+		}
+	}
+
+	sceneQuery := db.Where("scope = ? AND world_id = ?", model.AudioScopeWorld, world.ID)
+	if len(channelIDs) > 0 {
+		sceneQuery = db.Where("channel_scope IN ? OR (scope = ? AND world_id = ?)", channelIDs, model.AudioScopeWorld, world.ID)
+	}
+	if err := sceneQuery.Clauses(model.BuildOrderBy(model.OrderField{Name: "order"})).Find(&data.AudioScenes).Error; err != nil {
+		return nil, err
+	}
+	assetIDs := map[string]struct{}{}
+	for _, scene := range data.AudioScenes {
+		for _, track := range scene.Tracks {
+			if track.AssetID != nil && *track.AssetID != "" {
+				assetIDs[*track.AssetID] = struct{}{}
+			}
+			for _, id := range track.PlaylistAssetIDs {
+				if id != "" {
+					assetIDs[id] = struct{}{}
+				}
+			}
+		}
+	}
+	if len(assetIDs) > 0 {
+		if err := db.Where("id IN ? AND deleted_at IS NULL", worldBundleSetKeys(assetIDs)).Find(&data.AudioAssets).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	data.Users = collectWorldBundleUsers(data)
+	return data, nil
+}
+
+// collectWorldBundleUsers 汇总数据中出现的所有用户，用于在目标实例按用户名匹配
+func collectWorldBundleUsers(data *worldBundleData) []WorldBundleUser {
+	ids := map[string]struct{}{}
+	add := func(id string) {
+		if id = strings.TrimSpace(id); id != "" {
+			ids[id] = struct{}{}
+		}
+	}
+	for _, item := range data.Members {
+		add(item.UserID)
+	}
+	for _, item := range data.ChannelMembers {
+		add(item.UserID)
+	}
+	for _, item := range data.RoleMappings {
+		add(item.UserID)
+	}
+	for _, item := range data.Identities {
+		add(item.UserID)
+	}
+	for _, item := range data.CharacterCards {
+		add(item.UserID)
+	}
+	for _, item := range data.CardTemplates {
+		add(item.UserID)
+	}
+	for _, item := range data.StickyNotes {
+		add(item.CreatorID)
+	}
+	for _, item := range data.DiceMacros {
+		add(item.UserID)
+	}
+	for _, item := range data.InitiativeCombatants {
+		add(item.UserID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var users []model.UserModel
+	if err := model.GetDB().Where("id IN ?", worldBundleSetKeys(ids)).Find(&users).Error; err != nil {
+		return nil
+	}
+	result := make([]WorldBundleUser, 0, len(users))
+	for _, user := range users {
+		result = append(result, WorldBundleUser{ID: user.ID, Username: user.Username, Nickname: user.Nickname})
+	}
+	return result
+}
+
+// collectWorldBundleAttachmentTokens 收集世界配置中引用的站内附件
+func collectWorldBundleAttachmentTokens(data *worldBundleData) []string {
+	seen := map[string]struct{}{}
+	var tokens []string
+	add := func(token string) {
+		token = strings.TrimSpace(token)
+		if token == "" || strings.Contains(token, "/") {
+			return
+		}
+		token = normalizeRoundTripAttachmentToken(token)
+		if token == "" {
+			return
+		}
+		if _, ok := seen[token]; ok {
+			return
+		}
+		seen[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+	addContent := func(content string) {
+		for _, match := range roundTripAttachmentRefPattern.FindAllStringSubmatch(content, -1) {
+			add(match[2])
+		}
+	}
+	add(data.World.Avatar)
+	for _, item := range data.Channels {
+		add(item.BackgroundAttachmentId)
+	}
+	for _, item := range data.Identities {
+		add(item.AvatarAttachmentID)
+	}
+	for _, item := range data.IdentityVariants {
+		add(item.AvatarAttachmentID)
+	}
+	for _, item := range data.CardAvatarBindings {
+		add(item.AvatarAttachmentID)
+	}
+	for _, item := range data.StickyNotes {
+		addContent(item.Content)
+	}
+	for _, item := range data.Keywords {
+		addContent(item.Description)
+	}
+	return tokens
+}
+
+func copyWorldBundleAudio(asset *model.AudioAsset, w io.Writer) (int64, error) {
+	if asset.StorageType == model.StorageS3 {
+		manager := GetStorageManager()
+		if manager == nil {
+			return 0, errors.New("存储服务未初始化")
+		}
+		tempFile, err := os.CreateTemp("", "sealchat-bundle-audio-*")
+		if err != nil {
+			return 0, err
+		}
+		tempPath := tempFile.Name()
+		_ = tempFile.Close()
+		defer os.Remove(tempPath)
+		if err := manager.DownloadToPath(context.Background(), storage.BackendS3, asset.ObjectKey, tempPath); err != nil {
+			return 0, err
+		}
+		f, err := os.Open(tempPath)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		return io.Copy(w, f)
+	}
+	f, _, _, err := AudioOpenLocalVariant(asset, "")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+func worldBundleSetKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func worldBundleBlobTooLarge(f *zip.File) bool {
+	return f.UncompressedSize64 > maxWorldBundleBlobSize
+}
+
+func readWorldBundleFile(f *zip.File) ([]byte, error) {
+	if worldBundleBlobTooLarge(f) {
+		return nil, fmt.Errorf("%s 超过大小限制", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxWorldBundleBlobSize+1))
+}
+
+// WorldBundleFileName 生成世界包的下载文件名
+func WorldBundleFileName(manifest *WorldBundleManifest) string {
+	name := "世界"
+	ts := time.Now()
+	if manifest != nil {
+		if sanitized := sanitizeFileName(strings.TrimSpace(manifest.WorldName)); sanitized != "" {
+			name = sanitized
+		}
+		ts = manifest.ExportedAt
+	}
+	return fmt.Sprintf("%s-世界包-%s.zip", name, ts.Format("20060102-150405"))
+}
@@ -0,0 +1,1003 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+// WorldBundleImportReport 导入结果，IDMap 按数据类型记录旧 ID 到新 ID 的映射
+type WorldBundleImportReport struct {
+	WorldID          string                       `json:"worldId"`
+	DefaultChannelID string                       `json:"defaultChannelId"`
+	Counts           map[string]int               `json:"counts"`
+	IDMap            map[string]map[string]string `json:"idMap"`
+	UserMap          map[string]string            `json:"userMap"`
+	UnmatchedUsers   []WorldBundleUser            `json:"unmatchedUsers,omitempty"`
+	Warnings         []string                     `json:"warnings,omitempty"`
+}
+
+func (r *WorldBundleImportReport) mapID(kind string, oldID string, newID string) {
+	if oldID == "" || newID == "" {
+		return
+	}
+	if r.IDMap[kind] == nil {
+		r.IDMap[kind] = map[string]string{}
+	}
+	r.IDMap[kind][oldID] = newID
+	r.Counts[kind]++
+}
+
+func (r *WorldBundleImportReport) lookup(kind string, oldID string) string {
+	return r.IDMap[kind][oldID]
+}
+
+func (r *WorldBundleImportReport) warn(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+type worldBundleImporter struct {
+	actorID string
+	worldID string
+	// trusted 为真时由系统管理员导入，可按用户名自动匹配账号并保留管理员身份
+	trusted     bool
+	userMapping map[string]string
+	data        *worldBundleData
+	report      *WorldBundleImportReport
+}
+
+// WorldBundleImport 读取世界包并在本实例重建世界，所有数据使用新 ID。
+// userMapping 为导入者指定的“包内用户 ID -> 本站用户名”映射，未映射的用户数据归属到导入者
+func WorldBundleImport(r io.ReaderAt, size int64, actorID string, userMapping map[string]string) (*WorldBundleImportReport, error) {
+	actorID = strings.TrimSpace(actorID)
+	if actorID == "" {
+		return nil, errors.New("未登录")
+	}
+	if config := utils.GetConfig(); config != nil && !config.Audio.AllowNonAdminCreateWorld {
+		if !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+			return nil, ErrWorldCreateForbidden
+		}
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrWorldBundleInvalid
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	manifest := &WorldBundleManifest{}
+	if err := decodeWorldBundleFile(files[worldBundleManifestFile], manifest); err != nil || manifest.Format != worldBundleFormat {
+		return nil, ErrWorldBundleInvalid
+	}
+	if manifest.Version > worldBundleVersion {
+		return nil, fmt.Errorf("世界包版本 %d 高于当前支持的版本 %d", manifest.Version, worldBundleVersion)
+	}
+	data := &worldBundleData{}
+	for _, section := range data.sections() {
+		if err := decodeWorldBundleFile(files[worldBundleSectionFile(section.Name)], section.Value); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrWorldBundleInvalid, section.Name)
+		}
+	}
+	if strings.TrimSpace(data.World.Name) == "" {
+		return nil, ErrWorldBundleInvalid
+	}
+
+	imp := &worldBundleImporter{
+		actorID:     actorID,
+		trusted:     pm.CanWithSystemRole(actorID, pm.PermModAdmin),
+		userMapping: userMapping,
+		data:        data,
+		report: &WorldBundleImportReport{
+			Counts:  map[string]int{},
+			IDMap:   map[string]map[string]string{},
+			UserMap: map[string]string{},
+		},
+	}
+	imp.matchUsers()
+	if err := imp.createWorld(); err != nil {
+		return nil, err
+	}
+	imp.restoreBlobs(files, manifest)
+	if err := imp.createChannels(); err != nil {
+		imp.cleanup()
+		return nil, err
+	}
+
+	rolePerms := map[string][]string{}
+	err = model.GetDB().Transaction(func(tx *gorm.DB) error {
+		steps := []func(tx *gorm.DB) error{
+			imp.importMembers,
+			func(tx *gorm.DB) error {
+				var err error
+				rolePerms, err = imp.importRoles(tx)
+				return err
+			},
+			imp.importCharacterCards,
+			imp.importIdentities,
+			imp.importKeywords,
+			imp.importStickyNotes,
+			imp.importIForms,
+			imp.importDiceMacros,
+			imp.importInitiative,
+			imp.importAudioScenes,
+		}
+		for _, step := range steps {
+			if err := step(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		imp.cleanup()
+		return nil, err
+	}
+	applyRolePermsToMemory(rolePerms)
+	return imp.report, nil
+}
+
+func decodeWorldBundleFile(f *zip.File, target any) error {
+	if f == nil {
+		return nil
+	}
+	content, err := readWorldBundleFile(f)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, target)
+}
+
+// matchUsers 将包内用户映射到本站账号，未匹配的用户数据归属到导入者。
+// 只有系统管理员导入时才按用户名自动匹配；普通用户只能映射到自己，
+// 或映射到自己担任拥有者/管理员的某个世界中的成员
+func (imp *worldBundleImporter) matchUsers() {
+	for _, user := range imp.data.Users {
+		if user.ID == "" {
+			continue
+		}
+		target := strings.TrimSpace(imp.userMapping[user.ID])
+		if target == "" && imp.trusted {
+			target = user.Username
+		}
+		if target != "" {
+			existing, err := model.UserGetByUsername(target)
+			switch {
+			case err != nil || existing == nil || existing.ID == "":
+				imp.report.warn("用户 %s 映射的账号 %s 不存在", user.Username, target)
+			case !imp.trusted && existing.ID != imp.actorID && !worldBundleActorManagesUser(imp.actorID, existing.ID):
+				imp.report.warn("无权将用户 %s 映射到账号 %s", user.Username, target)
+			default:
+				imp.report.UserMap[user.ID] = existing.ID
+				continue
+			}
+		}
+		imp.report.UnmatchedUsers = append(imp.report.UnmatchedUsers, user)
+	}
+}
+
+// worldBundleActorManagesUser 判断导入者是否在某个世界中担任拥有者或管理员，且该世界包含目标用户
+func worldBundleActorManagesUser(actorID, userID string) bool {
+	var count int64
+	err := model.GetDB().Table("world_members AS managed").
+		Joins("JOIN world_members AS target ON target.world_id = managed.world_id AND target.user_id = ?", userID).
+		Joins("JOIN worlds ON worlds.id = managed.world_id AND worlds.status = ?", "active").
+		Where("managed.user_id = ? AND managed.role IN ?", actorID, []string{model.WorldRoleOwner, model.WorldRoleAdmin}).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+func (imp *worldBundleImporter) mapUser(userID string) string {
+	if mapped := imp.report.UserMap[userID]; mapped != "" {
+		return mapped
+	}
+	return imp.actorID
+}
+
+func (imp *worldBundleImporter) userMatched(userID string) bool {
+	return imp.report.UserMap[userID] != ""
+}
+
+func (imp *worldBundleImporter) mapUserIDList(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return raw
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(raw), &ids); err != nil {
+		return raw
+	}
+	mapped := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if target := imp.report.UserMap[id]; target != "" {
+			mapped = append(mapped, target)
+		}
+	}
+	encoded, _ := json.Marshal(mapped)
+	return string(encoded)
+}
+
+func (imp *worldBundleImporter) mapAttachment(token string) string {
+	trimmed := strings.TrimSpace(token)
+	if trimmed == "" {
+		return token
+	}
+	if newID := imp.report.lookup("attachments", normalizeRoundTripAttachmentToken(trimmed)); newID != "" {
+		if strings.HasPrefix(trimmed, "id:") {
+			return "id:" + newID
+		}
+		return newID
+	}
+	return token
+}
+
+func (imp *worldBundleImporter) mapAttachmentRefs(content string) string {
+	if content == "" || len(imp.report.IDMap["attachments"]) == 0 {
+		return content
+	}
+	return roundTripAttachmentRefPattern.ReplaceAllStringFunc(content, func(match string) string {
+		groups := roundTripAttachmentRefPattern.FindStringSubmatch(match)
+		if newID := imp.report.lookup("attachments", groups[2]); newID != "" {
+			return groups[1] + "id:" + newID
+		}
+		return match
+	})
+}
+
+func (imp *worldBundleImporter) createWorld() error {
+	src := imp.data.World
+	visibility := src.Visibility
+	if visibility == "" {
+		visibility = model.WorldVisibilityPublic
+	}
+	world := &model.WorldModel{
+		Name:                                  src.Name,
+		Description:                           src.Description,
+		Visibility:                            visibility,
+		OwnerID:                               imp.actorID,
+		EnforceMembership:                     src.EnforceMembership,
+		AllowAdminEditMessages:                src.AllowAdminEditMessages,
+		AllowManageOtherUserChannelIdentities: src.AllowManageOtherUserChannelIdentities,
+		AllowMemberEditKeywords:               src.AllowMemberEditKeywords,
+		StrictWhisperPrivacy:                  src.StrictWhisperPrivacy,
+		CharacterCardBadgeTemplate:            src.CharacterCardBadgeTemplate,
+		ChannelDefaultDiceMode:                model.WorldChannelDefaultDiceModeBuiltin,
+		Status:                                "active",
+	}
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(world).Error; err != nil {
+			return err
+		}
+		if !src.StrictWhisperPrivacy {
+			// 字段带数据库默认值，false 需要显式写入
+			if err := tx.Model(world).Update("strict_whisper_privacy", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&model.WorldMemberModel{
+			WorldID:  world.ID,
+			UserID:   imp.actorID,
+			Role:     model.WorldRoleOwner,
+			JoinedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	imp.worldID = world.ID
+	imp.report.WorldID = world.ID
+	imp.report.mapID("worlds", src.ID, world.ID)
+	return nil
+}
+
+// restoreBlobs 保存世界包中的附件与音频文件，单个文件失败只记录警告
+func (imp *worldBundleImporter) restoreBlobs(files map[string]*zip.File, manifest *WorldBundleManifest) {
+	for _, entry := range manifest.Attachments {
+		f := files[entry.File]
+		if f == nil {
+			imp.report.warn("附件 %s 缺失", entry.ID)
+			continue
+		}
+		content, err := readWorldBundleFile(f)
+		if err != nil || len(content) == 0 {
+			imp.report.warn("附件 %s 读取失败", entry.ID)
+			continue
+		}
+		filename := entry.Filename
+		if filename == "" {
+			filename = entry.ID + worldBundleBlobExt("", entry.MimeType)
+		}
+		att, err := ImportAttachmentFromBytes(content, filename, entry.MimeType, imp.actorID, "")
+		if err != nil || att == nil {
+			imp.report.warn("附件 %s 保存失败: %v", entry.ID, err)
+			continue
+		}
+		imp.report.mapID("attachments", entry.ID, att.ID)
+	}
+
+	if avatar := imp.mapAttachment(imp.data.World.Avatar); avatar != "" {
+		model.GetDB().Model(&model.WorldModel{}).Where("id = ?", imp.worldID).Update("avatar", avatar)
+	}
+
+	assets := make(map[string]*model.AudioAsset, len(imp.data.AudioAssets))
+	for i := range imp.data.AudioAssets {
+		assets[imp.data.AudioAssets[i].ID] = &imp.data.AudioAssets[i]
+	}
+	for _, entry := range manifest.AudioAssets {
+		asset := assets[entry.ID]
+		f := files[entry.File]
+		if asset == nil || f == nil || worldBundleBlobTooLarge(f) {
+			imp.report.warn("音频 %s 缺失", entry.ID)
+			continue
+		}
+		newAsset, err := imp.restoreAudioAsset(f, asset)
+		if err != nil {
+			imp.report.warn("音频 %s 导入失败: %v", asset.Name, err)
+			continue
+		}
+		imp.report.mapID("audioAssets", entry.ID, newAsset.ID)
+	}
+}
+
+func (imp *worldBundleImporter) restoreAudioAsset(f *zip.File, asset *model.AudioAsset) (*model.AudioAsset, error) {
+	tempFile, err := os.CreateTemp("", "sealchat-bundle-audio-*"+worldBundleBlobExt(f.Name, ""))
+	if err != nil {
+		return nil, err
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+	rc, err := f.Open()
+	if err != nil {
+		_ = tempFile.Close()
+		return nil, err
+	}
+	_, copyErr := io.Copy(tempFile, rc)
+	_ = rc.Close()
+	if closeErr := tempFile.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		return nil, copyErr
+	}
+	worldID := imp.worldID
+	return AudioCreateAssetFromImport(tempPath, AudioUploadOptions{
+		Name:        asset.Name,
+		Tags:        asset.Tags,
+		Description: asset.Description,
+		Visibility:  asset.Visibility,
+		CreatedBy:   imp.actorID,
+		Scope:       model.AudioScopeWorld,
+		WorldID:     &worldID,
+	})
+}
+
+// createChannels 按层级顺序创建频道，父频道总是先于子频道
+func (imp *worldBundleImporter) createChannels() error {
+	channels := append([]model.ChannelModel(nil), imp.data.Channels...)
+	known := make(map[string]struct{}, len(channels))
+	for _, ch := range channels {
+		known[ch.ID] = struct{}{}
+	}
+	depth := func(ch model.ChannelModel) int {
+		if _, ok := known[ch.ParentID]; ch.ParentID != "" && ok {
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return depth(channels[i]) < depth(channels[j])
+	})
+
+	db := model.GetDB()
+	for _, src := range channels {
+		parentID := imp.report.lookup("channels", src.ParentID)
+		permType := src.PermType
+		if permType == "" {
+			permType = "public"
+		}
+		name := strings.TrimSpace(src.Name)
+		if name == "" {
+			name = "未命名频道"
+		}
+		created := ChannelNew(utils.NewID(), permType, name, imp.worldID, imp.actorID, parentID)
+		if created == nil || created.ID == "" {
+			return errors.New("创建频道失败")
+		}
+		imp.report.mapID("channels", src.ID, created.ID)
+		status := src.Status
+		if status != model.ChannelStatusArchived {
+			status = model.ChannelStatusActive
+		}
+		if err := db.Model(&model.ChannelModel{}).Where("id = ?", created.ID).Updates(map[string]any{
+			"note":                     src.Note,
+			"sort_order":               src.SortOrder,
+			"default_dice_expr":        src.DefaultDiceExpr,
+			"dice_rule_system":         src.DiceRuleSystem,
+			"built_in_dice_enabled":    src.BuiltInDiceEnabled,
+			"verifiable_dice_enabled":  src.VerifiableDiceEnabled,
+			"background_attachment_id": imp.mapAttachment(src.BackgroundAttachmentId),
+			"background_settings":      imp.mapAttachmentRefs(src.BackgroundSettings),
+			"status":                   status,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	defaultChannelID := imp.report.lookup("channels", imp.data.World.DefaultChannelID)
+	if defaultChannelID == "" {
+		for _, src := range channels {
+			if mapped := imp.report.lookup("channels", src.ID); mapped != "" && src.ParentID == "" {
+				defaultChannelID = mapped
+				break
+			}
+		}
+	}
+	if defaultChannelID != "" {
+		imp.report.DefaultChannelID = defaultChannelID
+		return db.Model(&model.WorldModel{}).Where("id = ?", imp.worldID).Update("default_channel_id", defaultChannelID).Error
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) cleanup() {
+	for _, channelID := range imp.report.IDMap["channels"] {
+		cleanupClonedChannel(channelID)
+	}
+	if imp.worldID == "" {
+		return
+	}
+	db := model.GetDB()
+	db.Where("world_id = ?", imp.worldID).Delete(&model.WorldMemberModel{})
+	db.Where("world_id = ?", imp.worldID).Delete(&model.WorldKeywordModel{})
+	db.Where("world_id = ?", imp.worldID).Delete(&model.WorldKeywordCategoryModel{})
+	db.Where("world_id = ?", imp.worldID).Delete(&model.WorldCharacterCardTemplateBindingModel{})
+	db.Where("world_id = ?", imp.worldID).Delete(&model.WorldIFormBindingModel{})
+	db.Where("id = ?", imp.worldID).Delete(&model.WorldModel{})
+}
+
+func (imp *worldBundleImporter) importMembers(tx *gorm.DB) error {
+	for _, member := range imp.data.Members {
+		if !imp.userMatched(member.UserID) {
+			continue
+		}
+		userID := imp.mapUser(member.UserID)
+		if userID == imp.actorID {
+			continue
+		}
+		role := member.Role
+		if role == model.WorldRoleOwner {
+			role = model.WorldRoleAdmin
+		}
+		if !imp.trusted && role == model.WorldRoleAdmin {
+			role = model.WorldRoleMember
+		}
+		if err := tx.Create(&model.WorldMemberModel{
+			WorldID:  imp.worldID,
+			UserID:   userID,
+			Role:     role,
+			JoinedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		imp.report.Counts["members"]++
+	}
+
+	existing := map[string]struct{}{}
+	var current []model.MemberModel
+	if err := tx.Where("channel_id IN ?", worldBundleMapValues(imp.report.IDMap["channels"])).Find(&current).Error; err != nil {
+		return err
+	}
+	for _, member := range current {
+		existing[member.ChannelID+"|"+member.UserID] = struct{}{}
+	}
+	for _, member := range imp.data.ChannelMembers {
+		channelID := imp.report.lookup("channels", member.ChannelID)
+		if channelID == "" || !imp.userMatched(member.UserID) {
+			continue
+		}
+		userID := imp.mapUser(member.UserID)
+		key := channelID + "|" + userID
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		existing[key] = struct{}{}
+		if err := tx.Create(&model.MemberModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			Nickname:          member.Nickname,
+			ChannelID:         channelID,
+			UserID:            userID,
+		}).Error; err != nil {
+			return err
+		}
+		imp.report.Counts["channelMembers"]++
+	}
+	return nil
+}
+
+// importRoles 按 ch-<频道>-<key> 的规则重建频道角色，并替换 ChannelNew 生成的默认权限
+func (imp *worldBundleImporter) importRoles(tx *gorm.DB) (map[string][]string, error) {
+	roleMap := map[string]string{}
+	for _, role := range imp.data.Roles {
+		channelID := imp.report.lookup("channels", role.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		key, ok := extractRoleKey(role.ID, role.ChannelID)
+		if !ok {
+			continue
+		}
+		newRoleID := fmt.Sprintf("ch-%s-%s", channelID, key)
+		roleMap[role.ID] = newRoleID
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "desc"}),
+		}).Create(&model.ChannelRoleModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: newRoleID},
+			Name:              role.Name,
+			Desc:              role.Desc,
+			ChannelID:         channelID,
+		}).Error; err != nil {
+			return nil, err
+		}
+		imp.report.mapID("roles", role.ID, newRoleID)
+	}
+	if len(roleMap) > 0 {
+		if err := tx.Where("role_id IN ?", worldBundleMapValues(roleMap)).Delete(&model.RolePermissionModel{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	rolePerms := map[string][]string{}
+	perms := make([]model.RolePermissionModel, 0, len(imp.data.RolePermissions))
+	for _, perm := range imp.data.RolePermissions {
+		newRoleID := roleMap[perm.RoleID]
+		if newRoleID == "" || perm.PermissionID == "" {
+			continue
+		}
+		rolePerms[newRoleID] = append(rolePerms[newRoleID], perm.PermissionID)
+		perms = append(perms, model.RolePermissionModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			RoleID:            newRoleID,
+			PermissionID:      perm.PermissionID,
+		})
+	}
+	if len(perms) > 0 {
+		if err := tx.Create(&perms).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	mappings := make([]model.UserRoleMappingModel, 0, len(imp.data.RoleMappings))
+	for _, mapping := range imp.data.RoleMappings {
+		newRoleID := roleMap[mapping.RoleID]
+		if newRoleID == "" || !imp.userMatched(mapping.UserID) {
+			continue
+		}
+		mappings = append(mappings, model.UserRoleMappingModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			RoleType:          "channel",
+			UserID:            imp.mapUser(mapping.UserID),
+			RoleID:            newRoleID,
+		})
+	}
+	if len(mappings) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&mappings).Error; err != nil {
+			return nil, err
+		}
+		imp.report.Counts["roleMappings"] += len(mappings)
+	}
+	return rolePerms, nil
+}
+
+func (imp *worldBundleImporter) importCharacterCards(tx *gorm.DB) error {
+	for _, tpl := range imp.data.CardTemplates {
+		clone := tpl
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.UserID = imp.mapUser(tpl.UserID)
+		// 默认模板是用户级设置，迁移后不应覆盖目标用户已有的默认项
+		clone.IsGlobalDefault = false
+		clone.IsSheetDefault = false
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("cardTemplates", tpl.ID, clone.ID)
+	}
+	for _, binding := range imp.data.WorldCardTemplates {
+		templateID := imp.report.lookup("cardTemplates", binding.TemplateID)
+		if templateID == "" {
+			continue
+		}
+		if err := tx.Create(&model.WorldCharacterCardTemplateBindingModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			WorldID:           imp.worldID,
+			TemplateID:        templateID,
+			CreatedBy:         imp.actorID,
+			UpdatedBy:         imp.actorID,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, card := range imp.data.CharacterCards {
+		channelID := imp.report.lookup("channels", card.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := card
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.UserID = imp.mapUser(card.UserID)
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("characterCards", card.ID, clone.ID)
+	}
+
+	for _, binding := range imp.data.CardTemplateBindings {
+		channelID := imp.report.lookup("channels", binding.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := binding
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.UserID = imp.mapUser(binding.UserID)
+		clone.TemplateID = imp.report.lookup("cardTemplates", binding.TemplateID)
+		if clone.TemplateID == "" {
+			clone.Mode = model.CharacterCardTemplateModeDetached
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	for _, binding := range imp.data.CardAvatarBindings {
+		channelID := imp.report.lookup("channels", binding.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := binding
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.UserID = imp.mapUser(binding.UserID)
+		clone.AvatarAttachmentID = imp.mapAttachment(binding.AvatarAttachmentID)
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) importIdentities(tx *gorm.DB) error {
+	for _, identity := range imp.data.Identities {
+		channelID := imp.report.lookup("channels", identity.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := model.ChannelIdentityModel{
+			StringPKBaseModel:  model.StringPKBaseModel{ID: utils.NewID()},
+			ChannelID:          channelID,
+			UserID:             imp.mapUser(identity.UserID),
+			DisplayName:        identity.DisplayName,
+			Color:              identity.Color,
+			AvatarAttachmentID: imp.mapAttachment(identity.AvatarAttachmentID),
+			AvatarDecorations:  identity.AvatarDecorations,
+			CharacterCardID:    imp.report.lookup("characterCards", identity.CharacterCardID),
+			// 归属到导入者的角色不抢占导入者自己的默认角色
+			IsDefault: identity.IsDefault && imp.userMatched(identity.UserID),
+			IsHidden:  identity.IsHidden,
+			SortOrder: identity.SortOrder,
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("identities", identity.ID, clone.ID)
+	}
+
+	for _, folder := range imp.data.IdentityFolders {
+		channelID := imp.report.lookup("channels", folder.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := model.ChannelIdentityFolderModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			ChannelID:         channelID,
+			UserID:            imp.mapUser(folder.UserID),
+			Name:              folder.Name,
+			SortOrder:         folder.SortOrder,
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("identityFolders", folder.ID, clone.ID)
+	}
+	for _, item := range imp.data.IdentityFolderItems {
+		folderID := imp.report.lookup("identityFolders", item.FolderID)
+		identityID := imp.report.lookup("identities", item.IdentityID)
+		if folderID == "" || identityID == "" {
+			continue
+		}
+		if err := tx.Create(&model.ChannelIdentityFolderMemberModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			ChannelID:         imp.report.lookup("channels", item.ChannelID),
+			UserID:            imp.mapUser(item.UserID),
+			FolderID:          folderID,
+			IdentityID:        identityID,
+			SortOrder:         item.SortOrder,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, variant := range imp.data.IdentityVariants {
+		identityID := imp.report.lookup("identities", variant.IdentityID)
+		if identityID == "" {
+			continue
+		}
+		clone := model.ChannelIdentityVariantModel{
+			StringPKBaseModel:  model.StringPKBaseModel{ID: utils.NewID()},
+			IdentityID:         identityID,
+			ChannelID:          imp.report.lookup("channels", variant.ChannelID),
+			UserID:             imp.mapUser(variant.UserID),
+			SelectorEmoji:      variant.SelectorEmoji,
+			Keyword:            variant.Keyword,
+			Note:               variant.Note,
+			AvatarAttachmentID: imp.mapAttachment(variant.AvatarAttachmentID),
+			DisplayName:        variant.DisplayName,
+			Color:              variant.Color,
+			AppearanceJSON:     variant.AppearanceJSON,
+			SortOrder:          variant.SortOrder,
+			Enabled:            variant.Enabled,
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("identityVariants", variant.ID, clone.ID)
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) importKeywords(tx *gorm.DB) error {
+	for _, category := range imp.data.KeywordCategories {
+		clone := category
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.WorldID = imp.worldID
+		clone.CreatedBy = imp.actorID
+		clone.UpdatedBy = imp.actorID
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("keywordCategories", category.ID, clone.ID)
+	}
+	for _, keyword := range imp.data.Keywords {
+		clone := keyword
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.WorldID = imp.worldID
+		clone.Description = imp.mapAttachmentRefs(keyword.Description)
+		clone.CreatedBy = imp.mapUser(keyword.CreatedBy)
+		clone.UpdatedBy = imp.mapUser(keyword.UpdatedBy)
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("keywords", keyword.ID, clone.ID)
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) importStickyNotes(tx *gorm.DB) error {
+	for _, folder := range imp.data.StickyNoteFolders {
+		imp.report.mapID("stickyNoteFolders", folder.ID, utils.NewID())
+	}
+	for _, folder := range imp.data.StickyNoteFolders {
+		channelID := imp.report.lookup("channels", folder.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := folder
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: imp.report.lookup("stickyNoteFolders", folder.ID)}
+		clone.ChannelID = channelID
+		clone.WorldID = imp.worldID
+		clone.ParentID = imp.report.lookup("stickyNoteFolders", folder.ParentID)
+		clone.CreatorID = imp.mapUser(folder.CreatorID)
+		clone.Children = nil
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	for _, note := range imp.data.StickyNotes {
+		channelID := imp.report.lookup("channels", note.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := note
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.WorldID = imp.worldID
+		clone.FolderID = imp.report.lookup("stickyNoteFolders", note.FolderID)
+		clone.Content = imp.mapAttachmentRefs(note.Content)
+		clone.CreatorID = imp.mapUser(note.CreatorID)
+		clone.ViewerIDs = imp.mapUserIDList(note.ViewerIDs)
+		clone.EditorIDs = imp.mapUserIDList(note.EditorIDs)
+		clone.EditingLockUserID = ""
+		clone.EditingLockSessionID = ""
+		clone.EditingLockExpireAt = nil
+		clone.Creator = nil
+		clone.EditingLockUser = nil
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("stickyNotes", note.ID, clone.ID)
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) importIForms(tx *gorm.DB) error {
+	for _, form := range imp.data.IForms {
+		channelID := imp.report.lookup("channels", form.ChannelID)
+		if channelID == "" {
+			// 频道未导出的共享 iForm 挂到默认频道
+			channelID = imp.report.DefaultChannelID
+		}
+		if channelID == "" {
+			continue
+		}
+		clone := form
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.CreatedBy = imp.actorID
+		clone.UpdatedBy = imp.actorID
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("iforms", form.ID, clone.ID)
+	}
+	for _, binding := range imp.data.WorldIForms {
+		formID := imp.report.lookup("iforms", binding.FormID)
+		if formID == "" {
+			continue
+		}
+		if err := tx.Create(&model.WorldIFormBindingModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			WorldID:           imp.worldID,
+			FormID:            formID,
+			CreatedBy:         imp.actorID,
+			UpdatedBy:         imp.actorID,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) importDiceMacros(tx *gorm.DB) error {
+	for _, macro := range imp.data.DiceMacros {
+		channelID := imp.report.lookup("channels", macro.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := macro
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.UserID = imp.mapUser(macro.UserID)
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("diceMacros", macro.ID, clone.ID)
+	}
+	return nil
+}
+
+// importInitiative 导入先攻表，未导入的角色转为由导入者控制的 NPC
+func (imp *worldBundleImporter) importInitiative(tx *gorm.DB) error {
+	for _, combatant := range imp.data.InitiativeCombatants {
+		channelID := imp.report.lookup("channels", combatant.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := combatant
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.UserID = imp.mapUser(combatant.UserID)
+		if combatant.IdentityID != "" {
+			clone.IdentityID = imp.report.lookup("identities", combatant.IdentityID)
+			if clone.IdentityID == "" {
+				clone.IsNPC = true
+				clone.UserID = imp.actorID
+			}
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("initiativeCombatants", combatant.ID, clone.ID)
+	}
+	for _, tracker := range imp.data.InitiativeTrackers {
+		channelID := imp.report.lookup("channels", tracker.ChannelID)
+		if channelID == "" {
+			continue
+		}
+		clone := tracker
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.CurrentCombatantID = imp.report.lookup("initiativeCombatants", tracker.CurrentCombatantID)
+		clone.Version = 0
+		clone.UpdatedBy = imp.actorID
+		if clone.CurrentCombatantID == "" {
+			clone.Active = false
+			clone.Round = 0
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *worldBundleImporter) importAudioScenes(tx *gorm.DB) error {
+	mapAsset := func(id string) string {
+		return imp.report.lookup("audioAssets", id)
+	}
+	for _, scene := range imp.data.AudioScenes {
+		clone := scene
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.CreatedBy = imp.actorID
+		clone.UpdatedBy = imp.actorID
+		if scene.ChannelScope != nil && *scene.ChannelScope != "" {
+			channelID := imp.report.lookup("channels", *scene.ChannelScope)
+			if channelID == "" {
+				continue
+			}
+			clone.ChannelScope = &channelID
+		}
+		if scene.Scope == model.AudioScopeWorld {
+			worldID := imp.worldID
+			clone.WorldID = &worldID
+		}
+		tracks := make(model.JSONList[model.AudioSceneTrack], 0, len(scene.Tracks))
+		for _, track := range scene.Tracks {
+			if track.AssetID != nil {
+				if mapped := mapAsset(*track.AssetID); mapped != "" {
+					track.AssetID = &mapped
+				} else {
+					track.AssetID = nil
+				}
+			}
+			if len(track.PlaylistAssetIDs) > 0 {
+				ids := make([]string, 0, len(track.PlaylistAssetIDs))
+				for _, id := range track.PlaylistAssetIDs {
+					if mapped := mapAsset(id); mapped != "" {
+						ids = append(ids, mapped)
+					}
+				}
+				track.PlaylistAssetIDs = ids
+			}
+			// 播放列表文件夹属于源实例，迁移后改为直接引用资源列表
+			track.PlaylistFolderID = nil
+			tracks = append(tracks, track)
+		}
+		clone.Tracks = tracks
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		imp.report.mapID("audioScenes", scene.ID, clone.ID)
+	}
+	return nil
+}
+
+func worldBundleMapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+}
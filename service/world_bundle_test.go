@@ -0,0 +1,294 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+func TestWorldBundleExportImportRoundTrip(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	db := model.GetDB()
+
+	baseDir := t.TempDir()
+	if _, err := InitStorageManager(utils.StorageConfig{
+		Local: utils.LocalStorageConfig{
+			UploadDir: baseDir + "/upload",
+			AudioDir:  baseDir + "/audio",
+			FontDir:   baseDir + "/fonts",
+		},
+	}); err != nil {
+		t.Fatalf("init storage failed: %v", err)
+	}
+	t.Cleanup(func() { objectStorage = nil })
+
+	newUser := func(username string) string {
+		id := "user-" + utils.NewID()
+		if err := db.Create(&model.UserModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id},
+			Username:          username + "_" + id,
+			Nickname:          username,
+			Password:          "pw",
+			Salt:              "salt",
+		}).Error; err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+		return id
+	}
+	ownerID := newUser("owner")
+	playerID := newUser("player")
+	ghostID := newUser("ghost")
+	importerID := newUser("importer")
+
+	worldID := "world-" + utils.NewID()
+	if err := db.Create(&model.WorldModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: worldID},
+		Name:              "迁移测试",
+		Description:       "bundle",
+		Visibility:        model.WorldVisibilityPublic,
+		OwnerID:           ownerID,
+		InviteSlug:        "slug-" + utils.NewID(),
+		Status:            "active",
+	}).Error; err != nil {
+		t.Fatalf("create world failed: %v", err)
+	}
+	for userID, role := range map[string]string{ownerID: model.WorldRoleOwner, playerID: model.WorldRoleMember, ghostID: model.WorldRoleMember} {
+		if err := db.Create(&model.WorldMemberModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			WorldID:           worldID,
+			UserID:            userID,
+			Role:              role,
+			JoinedAt:          time.Now(),
+		}).Error; err != nil {
+			t.Fatalf("create member failed: %v", err)
+		}
+	}
+
+	parent := ChannelNew("ch-"+utils.NewID(), "public", "大厅", worldID, ownerID, "")
+	child := ChannelNew("ch-"+utils.NewID(), "public", "副本", worldID, ownerID, parent.ID)
+	if parent == nil || child == nil {
+		t.Fatalf("create channels failed")
+	}
+
+	avatar, err := ImportAttachmentFromBytes([]byte("\x89PNG\r\n\x1a\nbundle-avatar"), "avatar.png", "image/png", playerID, parent.ID)
+	if err != nil {
+		t.Fatalf("import avatar failed: %v", err)
+	}
+	identity := &model.ChannelIdentityModel{
+		StringPKBaseModel:  model.StringPKBaseModel{ID: utils.NewID()},
+		ChannelID:          parent.ID,
+		UserID:             playerID,
+		DisplayName:        "调查员",
+		Color:              "#336699",
+		AvatarAttachmentID: avatar.ID,
+		IsDefault:          true,
+	}
+	ghostIdentity := &model.ChannelIdentityModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		ChannelID:         child.ID,
+		UserID:            ghostID,
+		DisplayName:       "幽灵",
+		IsDefault:         true,
+	}
+	for _, item := range []*model.ChannelIdentityModel{identity, ghostIdentity} {
+		if err := db.Create(item).Error; err != nil {
+			t.Fatalf("create identity failed: %v", err)
+		}
+	}
+	if err := db.Create(&model.ChannelIdentityVariantModel{
+		StringPKBaseModel:  model.StringPKBaseModel{ID: utils.NewID()},
+		IdentityID:         identity.ID,
+		ChannelID:          parent.ID,
+		UserID:             playerID,
+		Keyword:            "受伤",
+		AvatarAttachmentID: avatar.ID,
+		AppearanceJSON:     `{"color":"#ff0000"}`,
+		Enabled:            true,
+	}).Error; err != nil {
+		t.Fatalf("create variant failed: %v", err)
+	}
+	if err := db.Create(&model.StickyNoteModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		ChannelID:         child.ID,
+		WorldID:           worldID,
+		Title:             "线索",
+		Content:           `<p><img src="id:` + avatar.ID + `"></p>`,
+		CreatorID:         playerID,
+		IsPublic:          true,
+	}).Error; err != nil {
+		t.Fatalf("create sticky note failed: %v", err)
+	}
+	if err := db.Create(&model.DiceMacroModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		UserID:            playerID,
+		ChannelID:         parent.ID,
+		Digits:            "1",
+		Label:             "侦查",
+		Expr:              "ra 侦查",
+	}).Error; err != nil {
+		t.Fatalf("create dice macro failed: %v", err)
+	}
+
+	if _, err := WorldBundleExport(worldID, playerID, &bytes.Buffer{}); err != ErrWorldPermission {
+		t.Fatalf("expected member export to be rejected, got %v", err)
+	}
+	var buf bytes.Buffer
+	manifest, err := WorldBundleExport(worldID, ownerID, &buf)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if manifest.Counts["channels"] != 2 || manifest.Counts["identities"] != 2 || len(manifest.Attachments) != 1 {
+		t.Fatalf("unexpected manifest: counts=%v attachments=%d", manifest.Counts, len(manifest.Attachments))
+	}
+	if !strings.HasSuffix(WorldBundleFileName(manifest), ".zip") {
+		t.Fatalf("unexpected bundle file name: %s", WorldBundleFileName(manifest))
+	}
+
+	// 模拟目标实例上不存在的用户
+	if err := db.Unscoped().Where("id = ?", ghostID).Delete(&model.UserModel{}).Error; err != nil {
+		t.Fatalf("delete ghost user failed: %v", err)
+	}
+
+	if _, err := WorldBundleImport(bytes.NewReader([]byte("not a zip")), 9, importerID, nil); err != ErrWorldBundleInvalid {
+		t.Fatalf("expected invalid bundle error, got %v", err)
+	}
+	usernameOf := func(id string) string {
+		var user model.UserModel
+		db.Where("id = ?", id).Limit(1).Find(&user)
+		return user.Username
+	}
+
+	// 未指定映射时普通用户导入不会按用户名匹配任何账号
+	report, err := WorldBundleImport(bytes.NewReader(buf.Bytes()), int64(buf.Len()), importerID, nil)
+	if err != nil {
+		t.Fatalf("import without mapping failed: %v", err)
+	}
+	if len(report.UserMap) != 0 || len(report.UnmatchedUsers) != 3 {
+		t.Fatalf("users should not be matched by username: map=%v unmatched=%+v", report.UserMap, report.UnmatchedUsers)
+	}
+
+	// 导入者只能映射到自己管理的世界中的成员
+	managedWorldID := "world-managed-" + utils.NewID()
+	if err := db.Create(&model.WorldModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: managedWorldID},
+		Name:              "导入者的世界",
+		Visibility:        model.WorldVisibilityPublic,
+		OwnerID:           importerID,
+		Status:            "active",
+	}).Error; err != nil {
+		t.Fatalf("create managed world failed: %v", err)
+	}
+	for userID, role := range map[string]string{importerID: model.WorldRoleOwner, playerID: model.WorldRoleMember} {
+		if err := db.Create(&model.WorldMemberModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			WorldID:           managedWorldID,
+			UserID:            userID,
+			Role:              role,
+			JoinedAt:          time.Now(),
+		}).Error; err != nil {
+			t.Fatalf("create managed member failed: %v", err)
+		}
+	}
+	report, err = WorldBundleImport(bytes.NewReader(buf.Bytes()), int64(buf.Len()), importerID, map[string]string{
+		playerID: usernameOf(playerID),
+		ownerID:  usernameOf(ownerID),
+	})
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if report.WorldID == "" || report.WorldID == worldID {
+		t.Fatalf("expected a new world id, got %q", report.WorldID)
+	}
+	if report.UserMap[playerID] != playerID || report.UserMap[ownerID] != "" || len(report.UnmatchedUsers) != 2 {
+		t.Fatalf("unexpected user mapping: map=%v unmatched=%+v", report.UserMap, report.UnmatchedUsers)
+	}
+	var ownerMemberships int64
+	db.Model(&model.WorldMemberModel{}).Where("world_id = ? AND user_id = ?", report.WorldID, ownerID).Count(&ownerMemberships)
+	if ownerMemberships != 0 {
+		t.Fatal("unmapped owner should not be enrolled into the imported world")
+	}
+
+	var imported model.WorldModel
+	if err := db.Where("id = ?", report.WorldID).Limit(1).Find(&imported).Error; err != nil || imported.ID == "" {
+		t.Fatalf("load imported world failed: %v", err)
+	}
+	if imported.OwnerID != importerID || imported.Name != "迁移测试" {
+		t.Fatalf("unexpected imported world: owner=%s name=%s", imported.OwnerID, imported.Name)
+	}
+
+	newParentID := report.lookup("channels", parent.ID)
+	newChildID := report.lookup("channels", child.ID)
+	if newParentID == "" || newChildID == "" || newParentID == parent.ID {
+		t.Fatalf("channels were not remapped: %v", report.IDMap["channels"])
+	}
+	var newChild model.ChannelModel
+	if err := db.Where("id = ?", newChildID).Limit(1).Find(&newChild).Error; err != nil {
+		t.Fatalf("load child channel failed: %v", err)
+	}
+	if newChild.ParentID != newParentID || newChild.WorldID != report.WorldID {
+		t.Fatalf("child channel not linked: parent=%s world=%s", newChild.ParentID, newChild.WorldID)
+	}
+
+	countPerms := func(channelID string) int64 {
+		var count int64
+		db.Model(&model.RolePermissionModel{}).Where("role_id LIKE ?", "ch-"+channelID+"-%").Count(&count)
+		return count
+	}
+	if src, dst := countPerms(parent.ID), countPerms(newParentID); src == 0 || src != dst {
+		t.Fatalf("role permissions not copied: src=%d dst=%d", src, dst)
+	}
+
+	newAvatarID := report.lookup("attachments", avatar.ID)
+	if newAvatarID == "" || newAvatarID == avatar.ID {
+		t.Fatalf("attachment not restored: %v", report.IDMap["attachments"])
+	}
+	var identities []*model.ChannelIdentityModel
+	if err := db.Where("channel_id IN ?", []string{newParentID, newChildID}).Find(&identities).Error; err != nil {
+		t.Fatalf("load identities failed: %v", err)
+	}
+	if len(identities) != 2 {
+		t.Fatalf("expected 2 identities, got %d", len(identities))
+	}
+	for _, item := range identities {
+		switch item.DisplayName {
+		case "调查员":
+			if item.UserID != playerID || item.AvatarAttachmentID != newAvatarID || !item.IsDefault {
+				t.Fatalf("unexpected matched identity: %+v", item)
+			}
+		case "幽灵":
+			if item.UserID != importerID || item.IsDefault {
+				t.Fatalf("unexpected unmatched identity: %+v", item)
+			}
+		}
+	}
+
+	var variant model.ChannelIdentityVariantModel
+	if err := db.Where("channel_id = ?", newParentID).Limit(1).Find(&variant).Error; err != nil || variant.ID == "" {
+		t.Fatalf("load variant failed: %v", err)
+	}
+	if variant.AppearanceJSON != `{"color":"#ff0000"}` || variant.AvatarAttachmentID != newAvatarID {
+		t.Fatalf("unexpected variant: %+v", variant)
+	}
+
+	var note model.StickyNoteModel
+	if err := db.Where("channel_id = ?", newChildID).Limit(1).Find(&note).Error; err != nil || note.ID == "" {
+		t.Fatalf("load sticky note failed: %v", err)
+	}
+	if note.WorldID != report.WorldID || !strings.Contains(note.Content, "id:"+newAvatarID) {
+		t.Fatalf("unexpected sticky note: world=%s content=%s", note.WorldID, note.Content)
+	}
+
+	var macros []*model.DiceMacroModel
+	if err := db.Where("channel_id = ?", newParentID).Find(&macros).Error; err != nil || len(macros) != 1 {
+		t.Fatalf("dice macro not imported: %v", err)
+	}
+	if report.Counts["channels"] != 2 || report.Counts["identities"] != 2 {
+		t.Fatalf("unexpected report counts: %v", report.Counts)
+	}
+}
@@ -0,0 +1,50 @@
+/* SealChat 离线推送 Service Worker：仅负责展示推送通知与点击跳转 */
+
+self.addEventListener('install', () => {
+  self.skipWaiting();
+});
+
+self.addEventListener('activate', (event) => {
+  event.waitUntil(self.clients.claim());
+});
+
+self.addEventListener('push', (event) => {
+  let payload = {};
+  try {
+    payload = event.data ? event.data.json() : {};
+  } catch (e) {
+    payload = { title: 'SealChat', body: event.data ? event.data.text() : '' };
+  }
+  const title = payload.title || 'SealChat';
+  const options = {
+    body: payload.body || '',
+    icon: new URL('./favicon.ico', self.registration.scope).href,
+    tag: payload.tag || undefined,
+    renotify: !!payload.tag,
+    data: {
+      url: payload.url || '',
+      channelId: payload.channelId || '',
+      messageId: payload.messageId || '',
+    },
+  };
+  event.waitUntil(self.registration.showNotification(title, options));
+});
+
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close();
+  const data = event.notification.data || {};
+  const target = new URL(data.url || './', self.registration.scope).href;
+  event.waitUntil((async () => {
+    const windows = await self.clients.matchAll({ type: 'window', includeUncontrolled: true });
+    for (const client of windows) {
+      if (client.url.startsWith(self.registration.scope) && 'focus' in client) {
+        await client.focus();
+        if (data.url && 'navigate' in client) {
+          await client.navigate(target).catch(() => {});
+        }
+        return;
+      }
+    }
+    await self.clients.openWindow(target);
+  })());
+});
@@ -2,7 +2,7 @@ import { ref, computed } from 'vue'
 import { defineStore } from 'pinia'
 import { WebSocketSubject, webSocket } from 'rxjs/webSocket';
 import type { User, Opcode, GatewayPayloadStructure, Channel, Event, GuildMember } from '@satorijs/protocol'
-import type { APIChannelCreateResp, APIChannelListResp, APIMessage, AvatarDecoration, BotWhisperForwardConfig, ChannelAddWorldMembersResponse, ChannelIcOocRoleConfig, ChannelIdentity, ChannelIdentityFolder, ChannelIdentityManageCandidate, ChannelIdentityManageCandidatesResponse, ChannelIdentityVariant, ChannelMemberCandidatesResponse, ChannelRoleModel, ExportTaskListResponse, FriendInfo, FriendRequestModel, MessageReaction, MessageReactionEvent, PaginationListResponse, SatoriMessage, SChannel, UserInfo, UserRoleModel } from '@/types';
+import type { APIChannelCreateResp, APIChannelListResp, APIMessage, AuditLogListResult, AuditLogQueryParams, AvatarDecoration, BotWhisperForwardConfig, ChannelAddWorldMembersResponse, DiceFairnessVerifyResult, DiceFairnessWindow, DiceLuckQuery, DiceLuckReport, DiceRuleSystem, ChannelIcOocRoleConfig, ChannelIdentity, ChannelIdentityFolder, ChannelIdentityManageCandidate, ChannelIdentityManageCandidatesResponse, ChannelIdentityVariant, ChannelMemberCandidatesResponse, ChannelRoleModel, ExportTaskListResponse, FriendInfo, FriendRequestModel, InitiativeCombatantInput, InitiativeEventPayload, InitiativeTracker, InitiativeTurnAction, MessageDraft, MessageReaction, MessageReactionEvent, MessageReadEventPayload, MessageReadReceiptReader, MessageThreadEventPayload, PaginationListResponse, PollCreatePayload, PollEventPayload, RateLimitEventPayload, SatoriMessage, SChannel, UserInfo, UserRoleModel } from '@/types';
 import type { AudioPlaybackStatePayload } from '@/types/audio';
 import { nanoid } from 'nanoid'
 import { groupBy } from 'lodash-es';
@@ -329,6 +329,7 @@ interface ChannelCopyOptions {
   copyGallery: boolean;
   copyIForms: boolean;
   copyDiceMacros: boolean;
+  copyInitiative: boolean;
   copyAudioScenes: boolean;
   copyAudioState: boolean;
   copyWebhooks: boolean;
@@ -355,6 +356,17 @@ interface PendingApiRequest {
   reject: (reason?: any) => void;
 }
 
+const buildDiceLuckParams = (query: DiceLuckQuery) => {
+  const params: Record<string, string | number> = {};
+  if (query.start) params.start = query.start;
+  if (query.end) params.end = query.end;
+  if (query.groupBy) params.groupBy = query.groupBy;
+  if (query.userId) params.userId = query.userId;
+  if (query.identityId) params.identityId = query.identityId;
+  if (query.channelIds?.length) params.channelIds = query.channelIds.join(',');
+  return params;
+};
+
 const REVOKED_DRAFT_CACHE_TTL_MS = 2 * 60 * 60 * 1000;
 const REVOKED_DRAFT_CACHE_MAX = 240;
 const REVOKED_DRAFT_SESSION_KEY = 'sealchat_revoked_drafts_v1';
@@ -916,6 +928,12 @@ interface ChatEventMap {
   'channel-identity-updated': (payload?: ChannelIdentityUpdatedEvent) => void;
   'channel-identities-updated': (event?: ChannelIdentitiesGatewayEvent) => void;
   'search-jump': (payload?: SearchJumpEvent) => void;
+  'rate-limited': (event?: { rateLimit?: RateLimitEventPayload }) => void;
+  'message-thread-updated': (event?: { thread?: MessageThreadEventPayload }) => void;
+  'message-thread-open': (root?: any) => void;
+  'poll-closed': (event?: { poll?: PollEventPayload; message?: any }) => void;
+  'initiative-updated': (event?: { initiative?: InitiativeEventPayload }) => void;
+  'message-read': (event?: { messageRead?: MessageReadEventPayload }) => void;
 }
 
 export const chatEvent = new Emitter<ChatEventMap>();
@@ -2188,6 +2206,51 @@ export const useChatStore = defineStore({
       return resp.data;
     },
 
+    async worldBundleExport(worldId: string) {
+      const resp = await api.get<Blob>(`/api/v1/worlds/${worldId}/bundle`, {
+        responseType: 'blob',
+        timeout: 300000,
+      });
+      const headers = resp.headers ?? {};
+      const disposition = (headers['content-disposition'] || headers['Content-Disposition']) as string | undefined;
+      let fileName = `world-${worldId}.zip`;
+      if (disposition) {
+        const match = disposition.match(/filename\*?=(?:UTF-8'')?\"?([^\";]+)\"?/i);
+        if (match && match[1]) {
+          try {
+            fileName = decodeURIComponent(match[1]);
+          } catch {
+            fileName = match[1];
+          }
+        }
+      }
+      return {
+        blob: resp.data,
+        fileName,
+      };
+    },
+
+    // userMap：包内用户 ID -> 本站用户名；仅能映射到自己或自己管理的世界中的成员
+    async worldBundleImport(file: File, userMap?: Record<string, string>) {
+      const formData = new FormData();
+      formData.append('file', file);
+      if (userMap && Object.keys(userMap).length) {
+        formData.append('userMap', JSON.stringify(userMap));
+      }
+      const resp = await api.post('/api/v1/worlds/bundle/import', formData, {
+        headers: { 'Content-Type': 'multipart/form-data' },
+        timeout: 300000,
+      });
+      const worldId = resp.data?.report?.worldId;
+      if (worldId) {
+        await this.initWorlds();
+        if (!this.joinedWorldIds.includes(worldId)) {
+          this.joinedWorldIds.push(worldId);
+        }
+      }
+      return resp.data?.report;
+    },
+
     async worldAckEditNotice(worldId: string) {
       const resp = await api.post(`/api/v1/worlds/${worldId}/ack-edit-notice`);
       if (this.worldDetailMap[worldId]) {
@@ -2248,6 +2311,11 @@ export const useChatStore = defineStore({
       return resp.data;
     },
 
+    async worldAuditLogs(worldId: string, params?: AuditLogQueryParams) {
+      const resp = await api.get<AuditLogListResult>(`/api/v1/worlds/${worldId}/audit-logs`, { params });
+      return resp.data;
+    },
+
     async worldMemberRemove(worldId: string, userId: string) {
       const resp = await api.delete(`/api/v1/worlds/${worldId}/members/${userId}`);
       return resp.data;
@@ -4044,6 +4112,86 @@ export const useChatStore = defineStore({
       this.patchChannelDefaultDice(channelId, nextExpr);
     },
 
+    async updateChannelDiceRuleSystem(ruleSystem: DiceRuleSystem) {
+      if (!this.curChannel?.id) {
+        return;
+      }
+      const resp = await this.sendAPI('channel.dice.default.set', {
+        channel_id: this.curChannel.id,
+        dice_rule_system: ruleSystem,
+      }) as { data?: { channel_id?: string; dice_rule_system?: DiceRuleSystem } };
+      const payload = resp?.data;
+      const channelId = payload?.channel_id || this.curChannel.id;
+      this.patchChannelAttributes(channelId, { diceRuleSystem: payload?.dice_rule_system ?? ruleSystem });
+    },
+
+    async updateChannelVerifiableDice(enabled: boolean) {
+      if (!this.curChannel?.id) {
+        return;
+      }
+      const resp = await this.sendAPI('channel.dice.default.set', {
+        channel_id: this.curChannel.id,
+        verifiable_dice_enabled: enabled,
+      }) as { data?: { channel_id?: string; verifiable_dice_enabled?: boolean } };
+      const payload = resp?.data;
+      const channelId = payload?.channel_id || this.curChannel.id;
+      this.patchChannelAttributes(channelId, { verifiableDiceEnabled: payload?.verifiable_dice_enabled ?? enabled });
+    },
+
+    async diceFairnessWindows(channelId: string, limit = 24) {
+      const resp = await this.sendAPI('dice.fairness.windows', { channel_id: channelId, limit }) as { data?: { items?: DiceFairnessWindow[] } };
+      return resp?.data?.items || [];
+    },
+
+    async diceFairnessVerify(channelId: string, messageId: string) {
+      const resp = await this.sendAPI('dice.fairness.verify', { channel_id: channelId, message_id: messageId }) as { data?: { rolls?: DiceFairnessVerifyResult[] }; err?: string };
+      if (resp?.err) {
+        throw new Error(resp.err);
+      }
+      return resp?.data?.rolls || [];
+    },
+
+    async initiativeGet(channelId: string) {
+      const resp = await this.sendAPI('initiative.get', { channel_id: channelId }) as { data?: { tracker?: InitiativeTracker; canManage?: boolean }; err?: string };
+      if (resp?.err) {
+        throw new Error(resp.err);
+      }
+      return { tracker: resp?.data?.tracker || null, canManage: !!resp?.data?.canManage };
+    },
+
+    // 先攻表的修改接口都返回最新快照，同时会广播 initiative-updated
+    async initiativeRequest(api: string, payload: Record<string, any>) {
+      const resp = await this.sendAPI(api, payload) as { data?: InitiativeTracker; err?: string };
+      if (resp?.err) {
+        throw new Error(resp.err);
+      }
+      return resp?.data || null;
+    },
+
+    async initiativeCombatantAdd(channelId: string, input: InitiativeCombatantInput) {
+      return this.initiativeRequest('initiative.combatant.add', { channel_id: channelId, ...input });
+    },
+
+    async initiativeCombatantUpdate(channelId: string, combatantId: string, input: InitiativeCombatantInput) {
+      return this.initiativeRequest('initiative.combatant.update', { channel_id: channelId, combatant_id: combatantId, ...input });
+    },
+
+    async initiativeCombatantRemove(channelId: string, combatantId: string) {
+      return this.initiativeRequest('initiative.combatant.remove', { channel_id: channelId, combatant_id: combatantId });
+    },
+
+    async initiativeRoll(channelId: string, combatantIds: string[] = [], formula = '') {
+      return this.initiativeRequest('initiative.roll', { channel_id: channelId, combatant_ids: combatantIds, formula });
+    },
+
+    async initiativeTurn(channelId: string, action: InitiativeTurnAction) {
+      return this.initiativeRequest('initiative.turn', { channel_id: channelId, action });
+    },
+
+    async initiativeConditionSet(channelId: string, combatantId: string, name: string, rounds = 0, remove = false) {
+      return this.initiativeRequest('initiative.condition.set', { channel_id: channelId, combatant_id: combatantId, name, rounds, remove });
+    },
+
     async updateChannelFeatures(channelId: string, updates: { builtInDiceEnabled?: boolean; botFeatureEnabled?: boolean; primaryBotId?: string | null; eventBotIds?: string[] | null }) {
       if (!channelId) {
         return null;
@@ -4189,6 +4337,7 @@ export const useChatStore = defineStore({
       includeRoleless?: boolean;
       limit?: number;
       direction?: 'before' | 'after';
+      threadId?: string;
     }) {
       const payload: Record<string, any> = {
         channel_id: channelId,
@@ -4227,12 +4376,53 @@ export const useChatStore = defineStore({
         if (options.direction === 'after') {
           payload.direction = 'after';
         }
+        if (options.threadId) {
+          payload.thread_id = options.threadId;
+        }
       }
       const resp = await this.sendAPI('message.list', payload as APIMessage);
-      this.canReorderAllMessages = !!resp.data?.can_reorder_all;
+      if (!options?.threadId) {
+        this.canReorderAllMessages = !!resp.data?.can_reorder_all;
+      }
       return resp.data;
     },
 
+    async messageThreadList(channelId: string, limit?: number) {
+      const resp = await this.sendAPI('message.thread.list', { channel_id: channelId, limit } as APIMessage);
+      return resp.data as { data: any[]; unread: Record<string, number> };
+    },
+
+    async messageThreadRead(channelId: string, threadId: string) {
+      const resp = await this.sendAPI('message.thread.read', { channel_id: channelId, thread_id: threadId } as APIMessage);
+      return resp.data;
+    },
+
+    async messageThreadUnread(channelId: string) {
+      const resp = await this.sendAPI('message.thread.unread', { channel_id: channelId } as APIMessage);
+      return (resp.data?.unread || {}) as Record<string, number>;
+    },
+
+    async messageDraftGet(channelId: string) {
+      const resp = await this.sendAPI('message.draft.get', { channel_id: channelId } as APIMessage);
+      return (resp.data?.draft || null) as MessageDraft | null;
+    },
+
+    async messageDraftSave(payload: { channelId: string; mode: 'plain' | 'rich'; content: string; extra?: string; updatedAt: number }) {
+      const resp = await this.sendAPI('message.draft.save', {
+        channel_id: payload.channelId,
+        mode: payload.mode,
+        content: payload.content,
+        extra: payload.extra || '',
+        updated_at: payload.updatedAt,
+      } as APIMessage);
+      return resp.data as { applied: boolean; draft: MessageDraft | null };
+    },
+
+    async messageReadList(channelId: string, messageId: string) {
+      const resp = await this.sendAPI('message.read.list', { channel_id: channelId, message_id: messageId } as APIMessage);
+      return (resp.data?.readers || []) as MessageReadReceiptReader[];
+    },
+
     async messageListDuring(channelId: string, fromTime: any, toTime: any, options?: {
       includeArchived?: boolean;
       includeOoc?: boolean;
@@ -4463,15 +4653,34 @@ export const useChatStore = defineStore({
       return this.getRevokedDraft(resultChannelId, resultMessageId);
     },
 
-    async interactWithWidget(messageId: string, widgetIndex: number, operation: 'rotate' | 'reveal' = 'rotate') {
+    async interactWithWidget(
+      messageId: string,
+      widgetIndex: number,
+      operation: 'rotate' | 'reveal' | 'poll_vote' | 'poll_close' = 'rotate',
+      options?: number[],
+    ) {
       if (this.connectState !== 'connected') return
-      await this.sendAPI('widget.interact', {
+      return await this.sendAPI('widget.interact', {
         message_id: messageId,
         widget_index: widgetIndex,
         operation,
+        options,
       })
     },
 
+    async pollCreate(payload: PollCreatePayload) {
+      const message = await this.messageCreate('', undefined, undefined, nanoid(), undefined, undefined, [], undefined, undefined, undefined, undefined, payload);
+      return message;
+    },
+
+    async pollVotesMine(channelId: string, messageIds: string[]) {
+      const resp = await this.sendAPI<{ data: { votes: Record<string, number[]> } }>('poll.votes.mine', {
+        channel_id: channelId,
+        message_ids: messageIds,
+      });
+      return (resp as any)?.data?.votes || {};
+    },
+
     async messageGetById(channel_id: string, message_id: string): Promise<{ id: string; channel_id: string; created_at: number; display_order: number } | null> {
       const resp = await this.sendAPI<{ data: { id: string; channel_id: string; created_at: number; display_order: number } | null }>('message.get', { channel_id, message_id });
       return (resp as any)?.data || null;
@@ -4750,6 +4959,8 @@ export const useChatStore = defineStore({
       typingDurationMs?: number,
       position?: { beforeId?: string; afterId?: string },
       identityVariantId?: string,
+      threadId?: string,
+      poll?: PollCreatePayload,
     ) {
       const payload: Record<string, any> = {
         channel_id: this.curChannel?.id,
@@ -4759,6 +4970,12 @@ export const useChatStore = defineStore({
       if (quote_id) {
         payload.quote_id = quote_id;
       }
+      if (threadId) {
+        payload.thread_id = threadId;
+      }
+      if (poll) {
+        payload.poll = poll;
+      }
       const explicitWhisperIds = Array.isArray(whisperTargetIds)
         ? whisperTargetIds
         : this.whisperTargets.map((target) => target?.id);
@@ -4795,6 +5012,9 @@ export const useChatStore = defineStore({
         payload.typing_duration_ms = Math.floor(typingDurationMs);
       }
       const resp = await this.sendAPI('message.create', payload, { timeoutMs: 5_000 });
+      if ((resp as any)?.err) {
+        throw new Error(String((resp as any).err));
+      }
       const message = resp?.data;
       if (!message || typeof message !== 'object') {
         return null;
@@ -6069,6 +6289,8 @@ export const useChatStore = defineStore({
       includeArchived?: boolean;
       includeImages?: boolean;
       includeDiceCommands?: boolean;
+      includeThreadReplies?: boolean;
+      includeRoundTrip?: boolean;
       withoutTimestamp?: boolean;
       mergeMessages?: boolean;
       textColorizeBBCode?: boolean;
@@ -6086,6 +6308,8 @@ export const useChatStore = defineStore({
         include_archived: params.includeArchived ?? false,
         include_images: params.includeImages ?? true,
         include_dice_commands: params.includeDiceCommands ?? true,
+        include_thread_replies: params.includeThreadReplies ?? false,
+        include_round_trip: params.includeRoundTrip ?? false,
         without_timestamp: params.withoutTimestamp ?? false,
         merge_messages: params.mergeMessages ?? true,
       };
@@ -6145,6 +6369,21 @@ export const useChatStore = defineStore({
       };
     },
 
+    async diceLuckStats(channelId: string, query: DiceLuckQuery = {}) {
+      const resp = await api.get<DiceLuckReport>(`api/v1/channels/${channelId}/dice-stats`, {
+        params: buildDiceLuckParams(query),
+      });
+      return resp.data;
+    },
+
+    async diceLuckStatsExport(channelId: string, query: DiceLuckQuery = {}, format: 'csv' | 'markdown' = 'csv') {
+      const resp = await api.get<Blob>(`api/v1/channels/${channelId}/dice-stats`, {
+        params: { ...buildDiceLuckParams(query), format },
+        responseType: 'blob',
+      });
+      return resp.data;
+    },
+
     async listExportTasks(
       channelId: string,
       opts?: { page?: number; size?: number; status?: string; keyword?: string }
@@ -6566,6 +6805,12 @@ chatEvent.on('channel-updated', (event) => {
   if (event.channel?.defaultDiceExpr) {
     patch.defaultDiceExpr = event.channel.defaultDiceExpr;
   }
+  if (typeof event.channel?.diceRuleSystem === 'string') {
+    patch.diceRuleSystem = event.channel.diceRuleSystem;
+  }
+  if (typeof event.channel?.verifiableDiceEnabled === 'boolean') {
+    patch.verifiableDiceEnabled = event.channel.verifiableDiceEnabled;
+  }
   if (typeof (event.channel as any)?.botWhisperForwardConfig === 'string') {
     patch.botWhisperForwardConfig = (event.channel as any).botWhisperForwardConfig;
   }
@@ -0,0 +1,154 @@
+import { defineStore } from 'pinia';
+import { ref } from 'vue';
+import { api } from '@/stores/_config';
+
+export type NotificationScopeType = 'global' | 'world' | 'channel';
+export type NotificationLevel = 'all' | 'mentions' | 'none';
+
+export interface NotificationPreference {
+    scopeType: NotificationScopeType;
+    scopeId: string;
+    level: NotificationLevel | '';
+    snoozeUntil?: string | null;
+    quietHoursEnabled: boolean;
+    quietHoursStart: number;
+    quietHoursEnd: number;
+    timezone: string;
+}
+
+export interface NotificationPreferenceInput {
+    scopeType: NotificationScopeType;
+    scopeId: string;
+    level: NotificationLevel | '';
+    snoozeUntil: number;
+    quietHours: { enabled: boolean; start: number; end: number };
+    timezone: string;
+}
+
+export const NOTIFICATION_LEVEL_LABELS: Record<NotificationLevel, string> = {
+    all: '所有消息',
+    mentions: '仅@提及、悄悄话与回复',
+    none: '不通知',
+};
+
+const scopeKey = (scopeType: NotificationScopeType, scopeId: string) => `${scopeType}:${scopeId}`;
+
+const minuteOfDay = (now: Date, timezone: string): number => {
+    if (timezone) {
+        try {
+            const parts = new Intl.DateTimeFormat('en-GB', {
+                timeZone: timezone,
+                hour: '2-digit',
+                minute: '2-digit',
+                hourCycle: 'h23',
+            }).formatToParts(now);
+            const hour = Number(parts.find((p) => p.type === 'hour')?.value || 0);
+            const minute = Number(parts.find((p) => p.type === 'minute')?.value || 0);
+            return hour * 60 + minute;
+        } catch {
+            // 时区无效时回退到本地时间
+        }
+    }
+    return now.getHours() * 60 + now.getMinutes();
+};
+
+const inQuietHours = (item: NotificationPreference, now: Date): boolean => {
+    if (!item.quietHoursEnabled || item.quietHoursStart === item.quietHoursEnd) return false;
+    const minute = minuteOfDay(now, item.timezone);
+    const { quietHoursStart: start, quietHoursEnd: end } = item;
+    if (start < end) {
+        return minute >= start && minute < end;
+    }
+    // 跨越午夜，如 23:00-07:00
+    return minute >= start || minute < end;
+};
+
+export const defaultTimezone = (): string => {
+    try {
+        return Intl.DateTimeFormat().resolvedOptions().timeZone || '';
+    } catch {
+        return '';
+    }
+};
+
+/**
+ * 通知偏好 Store
+ *
+ * 级别按 频道 → 世界 → 全局 逐级继承；免打扰与静默时段在任一层级生效即静音。
+ * 与服务端 model.NotificationPolicyResolve 的解析规则保持一致
+ */
+export const useNotificationPreferenceStore = defineStore('notificationPreference', () => {
+    const items = ref<Record<string, NotificationPreference>>({});
+    const loaded = ref(false);
+
+    const load = async () => {
+        try {
+            const resp = await api.get<{ items: NotificationPreference[] }>('api/v1/notification-preferences');
+            const next: Record<string, NotificationPreference> = {};
+            for (const item of resp.data?.items || []) {
+                next[scopeKey(item.scopeType, item.scopeId)] = item;
+            }
+            items.value = next;
+            loaded.value = true;
+        } catch (error) {
+            console.warn('[NotificationPreference] 获取通知设置失败', error);
+        }
+    };
+
+    const get = (scopeType: NotificationScopeType, scopeId = ''): NotificationPreference | undefined => {
+        return items.value[scopeKey(scopeType, scopeType === 'global' ? '' : scopeId)];
+    };
+
+    const upsert = async (input: NotificationPreferenceInput) => {
+        const resp = await api.post<{ item: NotificationPreference }>('api/v1/notification-preferences', input);
+        const item = resp.data?.item;
+        if (item) {
+            items.value = { ...items.value, [scopeKey(item.scopeType, item.scopeId)]: item };
+        }
+        return item;
+    };
+
+    const reset = async (scopeType: NotificationScopeType, scopeId = '') => {
+        await api.delete('api/v1/notification-preferences', { params: { scopeType, scopeId } });
+        const next = { ...items.value };
+        delete next[scopeKey(scopeType, scopeType === 'global' ? '' : scopeId)];
+        items.value = next;
+    };
+
+    const scopeChain = (channelId: string, worldId: string) => {
+        return [get('channel', channelId), worldId ? get('world', worldId) : undefined, get('global')]
+            .filter((item): item is NotificationPreference => !!item);
+    };
+
+    const resolveLevel = (channelId: string, worldId: string): NotificationLevel => {
+        for (const item of scopeChain(channelId, worldId)) {
+            if (item.level) return item.level;
+        }
+        return 'all';
+    };
+
+    /**
+     * 判断此刻是否应就该频道的消息提醒用户
+     * @param direct 是否为 @提及、悄悄话或回复自己
+     */
+    const allows = (channelId: string, worldId: string, direct: boolean, now = new Date()): boolean => {
+        const level = resolveLevel(channelId, worldId);
+        if (level === 'none' || (level === 'mentions' && !direct)) return false;
+        for (const item of scopeChain(channelId, worldId)) {
+            if (item.snoozeUntil && new Date(item.snoozeUntil).getTime() > now.getTime()) return false;
+            if (inQuietHours(item, now)) return false;
+        }
+        return true;
+    };
+
+    return {
+        items,
+        loaded,
+        load,
+        get,
+        upsert,
+        reset,
+        resolveLevel,
+        allows,
+    };
+});
@@ -0,0 +1,68 @@
+import { defineStore } from 'pinia';
+import { ref } from 'vue';
+import { api } from '@/stores/_config';
+import type { MessageReadEventPayload } from '@/types';
+
+const PREF_KEY = 'read_receipts_enabled';
+
+/**
+ * 已读回执 Store
+ *
+ * 已读回执需要双方都开启：开启后才会公开自己的阅读状态，也才能看到他人的。
+ * 仅悄悄话、私聊与成员较少的频道支持，服务端会在阅读时推送 message-read 事件
+ */
+export const useReadReceiptStore = defineStore('readReceipt', () => {
+    const enabled = ref(false);
+    const loaded = ref(false);
+    // messageId -> 已读用户 ID 列表
+    const readers = ref<Record<string, string[]>>({});
+
+    const load = async () => {
+        try {
+            const resp = await api.get<{ value: string; exists: boolean }>('api/v1/user/preferences', {
+                params: { key: PREF_KEY },
+            });
+            enabled.value = resp.data?.value === 'true';
+            loaded.value = true;
+        } catch (error) {
+            console.warn('[ReadReceipt] 获取已读回执设置失败', error);
+        }
+    };
+
+    const setEnabled = async (value: boolean) => {
+        await api.post('api/v1/user/preferences', { key: PREF_KEY, value: value ? 'true' : 'false' });
+        enabled.value = value;
+        if (!value) {
+            readers.value = {};
+        }
+    };
+
+    const applyEvent = (payload?: MessageReadEventPayload) => {
+        if (!payload?.userId || !payload.messageIds?.length) return;
+        const next = { ...readers.value };
+        for (const id of payload.messageIds) {
+            const list = next[id] || [];
+            if (!list.includes(payload.userId)) {
+                next[id] = [...list, payload.userId];
+            }
+        }
+        readers.value = next;
+    };
+
+    const setReaders = (messageId: string, userIds: string[]) => {
+        readers.value = { ...readers.value, [messageId]: userIds };
+    };
+
+    const readCount = (messageId: string) => readers.value[messageId]?.length || 0;
+
+    return {
+        enabled,
+        loaded,
+        readers,
+        load,
+        setEnabled,
+        applyEvent,
+        setReaders,
+        readCount,
+    };
+});
@@ -1,5 +1,5 @@
 import { defineStore } from "pinia"
-import type { UserEmojiModel, UserInfo } from "@/types";
+import type { UserEmojiModel, UserInfo, UserSession } from "@/types";
 // import router from "@/router";
 import type { AxiosResponse } from "axios";
 import { api } from "./_config";
@@ -88,8 +88,12 @@ export const useUserStore = defineStore({
         capToken: payload.capToken,
       })
 
-      const data = resp.data as { token: string, message: string };
-      const accessToken = data.token;
+      const data = resp.data as { token?: string, message: string, twoFactorRequired?: boolean, challenge?: string };
+      // 已启用两步验证时，需要再调用 signInTwoFactor 才会拿到 token
+      if (data.twoFactorRequired) {
+        return resp;
+      }
+      const accessToken = data.token || '';
 
       // 将 accessToken 存入 localStorage 中
       // Cookies.set('accessToken', accessToken, { expires: 7 })
@@ -98,6 +102,75 @@ export const useUserStore = defineStore({
       return resp;
     },
 
+    async signInTwoFactor(challenge: string, code: string) {
+      const resp = await api.post('api/v1/user-signin/2fa', { challenge, code });
+      const data = resp.data as { token: string, message: string };
+      this._accessToken = persistAccessToken(data.token);
+      return resp;
+    },
+
+    async sessionList() {
+      const resp = await api.get('api/v1/user/sessions');
+      return (resp.data?.items || []) as UserSession[];
+    },
+
+    async sessionRevoke(id: string) {
+      const resp = await api.post('api/v1/user/sessions/revoke', { id });
+      return resp.data as { message: string; current: boolean; closedConnections: number };
+    },
+
+    async sessionRevokeOthers() {
+      const resp = await api.post('api/v1/user/sessions/revoke-others');
+      return resp.data as { message: string; revoked: number; closedConnections: number };
+    },
+
+    async oidcExchange(ticket: string) {
+      const resp = await api.post('api/v1/oidc/exchange', { ticket });
+      const data = resp.data as { token: string, message: string, twoFactorSetupRequired?: boolean };
+      this._accessToken = persistAccessToken(data.token);
+      return resp;
+    },
+
+    async oidcLinkStart(redirect: string) {
+      const resp = await api.post('api/v1/oidc/link', { redirect });
+      return resp.data as { url: string };
+    },
+
+    async oidcIdentities() {
+      const resp = await api.get('api/v1/oidc/identities');
+      return (resp.data?.items || []) as { id: string; issuer: string; subject: string; email?: string; lastLoginAt?: string; createdAt?: string }[];
+    },
+
+    async oidcUnlink(id: string) {
+      const resp = await api.post('api/v1/oidc/unlink', { id });
+      return resp.data as { message: string };
+    },
+
+    async twoFactorStatus() {
+      const resp = await api.get('api/v1/user/2fa');
+      return resp.data as { enabled: boolean; enabledAt?: string; recoveryCodesRemaining: number; required: boolean };
+    },
+
+    async twoFactorSetup() {
+      const resp = await api.post('api/v1/user/2fa/setup');
+      return resp.data as { secret: string; otpauthUrl: string; issuer: string; accountName: string };
+    },
+
+    async twoFactorEnable(code: string) {
+      const resp = await api.post('api/v1/user/2fa/enable', { code });
+      return resp.data as { message: string; recoveryCodes: string[] };
+    },
+
+    async twoFactorDisable(code: string) {
+      const resp = await api.post('api/v1/user/2fa/disable', { code });
+      return resp.data as { message: string };
+    },
+
+    async twoFactorRegenerateRecoveryCodes(code: string) {
+      const resp = await api.post('api/v1/user/2fa/recovery-codes', { code });
+      return resp.data as { message: string; recoveryCodes: string[] };
+    },
+
     async timelineList() {
       const resp = await api.get('api/v1/timeline-list', {
         headers: { 'Authorization': this.token }
@@ -5,6 +5,8 @@ import type {
   AdminAIQuotaDetail,
   AdminAIQuotaListResult,
   AdminAIUsageLogListResult,
+  AuditLogListResult,
+  AuditLogQueryParams,
   BotOneBotConfig,
   CertificateConfig,
   ServerConfig,
@@ -417,6 +419,14 @@ export const useUtilsStore = defineStore({
       });
     },
 
+    async adminAuditLogs(params?: AuditLogQueryParams) {
+      const user = useUserStore();
+      return await api.get<AuditLogListResult>('api/v1/admin/audit-logs', {
+        headers: { 'Authorization': user.token },
+        params,
+      });
+    },
+
     async adminAIUsageLogsCleanup(payload?: { retentionDays?: number }) {
       const user = useUserStore();
       return await api.post<{ affectedRows: number }>('api/v1/admin/ai/usage-logs/cleanup', payload || {}, {
@@ -556,6 +566,15 @@ export const useUtilsStore = defineStore({
       return resp
     },
 
+    async userTwoFactorReset(id: string) {
+      const user = useUserStore();
+      const resp = await api.post(`api/v1/admin/user-2fa-reset`, null, {
+        headers: { 'Authorization': user.token },
+        params: { id },
+      })
+      return resp
+    },
+
     // 添加用户角色
     async userRoleLinkByUserId(userId: string, roleIds: string[]) {
       const user = useUserStore();
@@ -0,0 +1,142 @@
+import { defineStore } from 'pinia';
+import { computed, ref } from 'vue';
+import { api } from '@/stores/_config';
+
+const SERVICE_WORKER_URL = './sw-push.js';
+
+const urlBase64ToUint8Array = (value: string): Uint8Array => {
+    const padding = '='.repeat((4 - (value.length % 4)) % 4);
+    const base64 = (value + padding).replace(/-/g, '+').replace(/_/g, '/');
+    const raw = window.atob(base64);
+    const output = new Uint8Array(raw.length);
+    for (let i = 0; i < raw.length; i++) {
+        output[i] = raw.charCodeAt(i);
+    }
+    return output;
+};
+
+/**
+ * 离线推送 Store（VAPID Web Push）
+ *
+ * 与前台通知不同，离线推送由 Service Worker 接收，
+ * 关闭页面后仍能收到 @提及、悄悄话与回复通知
+ */
+export const useWebPushStore = defineStore('webPush', () => {
+    const serverEnabled = ref(false);
+    const publicKey = ref('');
+    const subscribed = ref(false);
+    const loading = ref(false);
+
+    const supported = computed(() => {
+        return typeof window !== 'undefined'
+            && window.isSecureContext
+            && 'serviceWorker' in navigator
+            && 'PushManager' in window
+            && 'Notification' in window;
+    });
+
+    const available = computed(() => supported.value && serverEnabled.value && !!publicKey.value);
+
+    const getRegistration = async (): Promise<ServiceWorkerRegistration> => {
+        const existing = await navigator.serviceWorker.getRegistration(SERVICE_WORKER_URL);
+        if (existing) return existing;
+        await navigator.serviceWorker.register(SERVICE_WORKER_URL);
+        return navigator.serviceWorker.ready;
+    };
+
+    /**
+     * 读取服务端配置与当前设备的订阅状态
+     */
+    const refresh = async () => {
+        if (!supported.value) return;
+        try {
+            const resp = await api.get<{ enabled: boolean; publicKey?: string }>('api/v1/web-push/config');
+            serverEnabled.value = !!resp.data?.enabled;
+            publicKey.value = resp.data?.publicKey || '';
+        } catch {
+            serverEnabled.value = false;
+        }
+        if (!available.value) {
+            subscribed.value = false;
+            return;
+        }
+        try {
+            const registration = await navigator.serviceWorker.getRegistration(SERVICE_WORKER_URL);
+            const subscription = await registration?.pushManager.getSubscription();
+            subscribed.value = !!subscription;
+            if (subscription) {
+                // 重新上报，保证订阅归属当前登录账号
+                await api.post('api/v1/web-push/subscriptions', subscription.toJSON());
+            }
+        } catch (error) {
+            console.warn('[WebPush] 检查订阅状态失败', error);
+        }
+    };
+
+    const subscribe = async (): Promise<boolean> => {
+        if (!available.value || loading.value) return false;
+        loading.value = true;
+        try {
+            const permission = await Notification.requestPermission();
+            if (permission !== 'granted') return false;
+            const registration = await getRegistration();
+            let subscription = await registration.pushManager.getSubscription();
+            if (!subscription) {
+                subscription = await registration.pushManager.subscribe({
+                    userVisibleOnly: true,
+                    applicationServerKey: urlBase64ToUint8Array(publicKey.value),
+                });
+            }
+            await api.post('api/v1/web-push/subscriptions', subscription.toJSON());
+            subscribed.value = true;
+            return true;
+        } catch (error) {
+            console.error('[WebPush] 订阅失败', error);
+            return false;
+        } finally {
+            loading.value = false;
+        }
+    };
+
+    const unsubscribe = async (): Promise<void> => {
+        if (!supported.value || loading.value) return;
+        loading.value = true;
+        try {
+            const registration = await navigator.serviceWorker.getRegistration(SERVICE_WORKER_URL);
+            const subscription = await registration?.pushManager.getSubscription();
+            if (subscription) {
+                await api.post('api/v1/web-push/unsubscribe', { endpoint: subscription.endpoint }).catch(() => {});
+                await subscription.unsubscribe();
+            }
+            subscribed.value = false;
+        } finally {
+            loading.value = false;
+        }
+    };
+
+    const toggle = async (): Promise<boolean> => {
+        if (subscribed.value) {
+            await unsubscribe();
+            return true;
+        }
+        return subscribe();
+    };
+
+    const sendTest = async (): Promise<number> => {
+        const resp = await api.post<{ sent: number }>('api/v1/web-push/test');
+        return resp.data?.sent || 0;
+    };
+
+    return {
+        serverEnabled,
+        subscribed,
+        loading,
+        supported,
+        available,
+        refresh,
+        subscribe,
+        unsubscribe,
+        toggle,
+        sendTest,
+    };
+});
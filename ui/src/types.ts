@@ -30,11 +30,16 @@ declare module '@satorijs/protocol' {
     deletedAt?: number;
     deletedBy?: string;
     reactions?: MessageReaction[];
+    threadId?: string;
+    threadReplyCount?: number;
+    threadLastReplyAt?: number;
   }
   interface Channel {
     defaultDiceExpr?: string;
+    diceRuleSystem?: DiceRuleSystem;
     botCommandPrefixes?: string[];
     builtInDiceEnabled?: boolean;
+    verifiableDiceEnabled?: boolean;
     botFeatureEnabled?: boolean;
     primaryBotId?: string;
     eventBotIds?: string[];
@@ -157,6 +162,7 @@ export interface BattleReportPayload {
   aiProviderId?: string;
   aiModel?: string;
   aiFeatureKey?: string;
+  includeDiceStats?: boolean;
 }
 
 export interface BattleReportDisplayChannel {
@@ -365,6 +371,68 @@ export interface PerformanceProfilerConfig {
   retentionDays: number;
 }
 
+export interface RateLimitRule {
+  burst: number;
+  perMinute: number;
+}
+
+export interface RateLimitRouteConfig {
+  route: string;
+  worldId?: string;
+  user: RateLimitRule;
+  ip: RateLimitRule;
+}
+
+export interface RateLimitConfig {
+  enabled: boolean;
+  exemptSystemRoles?: string[];
+  routes?: RateLimitRouteConfig[];
+  worldOverrides?: RateLimitRouteConfig[];
+}
+
+export interface AuditChange {
+  field: string;
+  before: unknown;
+  after: unknown;
+}
+
+export interface AuditLogItem {
+  id: string;
+  createdAt: string;
+  actorId: string;
+  actorName: string;
+  action: string;
+  targetType: string;
+  targetId: string;
+  targetName: string;
+  worldId: string;
+  channelId: string;
+  ip: string;
+  before?: unknown;
+  after?: unknown;
+  changes: AuditChange[];
+}
+
+export interface AuditLogListResult {
+  items: AuditLogItem[];
+  page: number;
+  pageSize: number;
+  total: number;
+}
+
+export interface AuditLogQueryParams {
+  page?: number;
+  pageSize?: number;
+  action?: string;
+  actorId?: string;
+  targetType?: string;
+  targetId?: string;
+  worldId?: string;
+  keyword?: string;
+  start?: number;
+  end?: number;
+}
+
 export type AIRoutingMode = 'round_robin';
 export type AIFeatureAccessMode = 'all' | 'users' | 'worlds' | 'users_or_worlds';
 export type AIRunSource = 'platform' | 'user';
@@ -554,6 +622,24 @@ export interface ServerConfig {
   emailAuth?: {
     enabled: boolean;
   };
+  twoFactor?: {
+    requireForAdmins: boolean;
+    issuer?: string;
+  };
+  oidc?: {
+    enabled: boolean;
+    displayName?: string;
+    issuer?: string;
+    clientId?: string;
+    clientSecret?: string;
+    redirectUrl?: string;
+    scopes?: string[];
+    allowSignup?: boolean;
+    linkByEmail?: boolean;
+    usernameClaim?: string;
+    nicknameClaim?: string;
+    avatarClaim?: string;
+  };
   backup?: BackupConfig;
   sqlite?: SQLiteConfig;
   audio?: ServerAudioConfig;
@@ -565,6 +651,11 @@ export interface ServerConfig {
   certificate?: CertificateConfig;
   ai?: AIConfig;
   performanceProfiler?: PerformanceProfilerConfig;
+  rateLimit?: RateLimitConfig;
+  metricsExport?: {
+    enabled: boolean;
+    token?: string;
+  };
 }
 
 export interface UserInfo {
@@ -584,6 +675,126 @@ export interface UserInfo {
   email?: string;
   emailVerified?: boolean;
   emailVerifiedAt?: string;
+  twoFactorEnabled?: boolean;
+}
+
+export interface RateLimitEventPayload {
+  route: 'message_create' | 'attachment_upload' | 'webhook_message' | string;
+  scope: 'user' | 'ip' | string;
+  channelId?: string;
+  clientId?: string;
+  retryAfterMs: number;
+  burst?: number;
+  perMinute?: number;
+}
+
+export interface MessageThreadEventPayload {
+  rootId: string;
+  channelId: string;
+  replyCount: number;
+  lastReplyAt?: number;
+  lastReplyId?: string;
+  lastReplyUserId?: string;
+}
+
+export interface MessageReadEventPayload {
+  channelId: string;
+  userId: string;
+  messageIds: string[];
+  readAt: number;
+}
+
+export interface MessageReadReceiptReader {
+  user: { id: string; name?: string; nick?: string; avatar?: string };
+  lastReadAt: number;
+}
+
+// 频道检定规则系统，空字符串表示不启用
+export type DiceRuleSystem = '' | 'coc7' | 'dnd5e';
+
+export interface DiceFairnessWindow {
+  id: string;
+  channelId: string;
+  windowStart: string;
+  windowEnd: string;
+  seedHash: string;
+  seed?: string;
+  revealed: boolean;
+}
+
+export type DiceFairnessStatus = 'verified' | 'mismatch' | 'pending' | 'unverifiable';
+
+export interface DiceFairnessVerifyResult {
+  rollIndex: number;
+  formula: string;
+  status: DiceFairnessStatus;
+  resultDetail: string;
+  resultValueText: string;
+  expectedDetail?: string;
+  expectedValue?: string;
+  window?: DiceFairnessWindow;
+}
+
+export type DiceLuckGroupBy = 'user' | 'identity' | 'channel';
+
+export interface DiceLuckSidesStats {
+  sides: number;
+  count: number;
+  mean: number;
+  expected: number;
+  histogram?: number[];
+  chiSquare: number;
+  degreesOfFreedom: number;
+  pValue: number;
+  reliable: boolean;
+}
+
+export interface DiceLuckStats {
+  key: string;
+  name: string;
+  rollCount: number;
+  diceCount: number;
+  luckScore: number;
+  luckZ: number;
+  sides: DiceLuckSidesStats[];
+  outcomes?: Record<string, Record<string, number>>;
+}
+
+export interface DiceLuckReport {
+  groupBy: DiceLuckGroupBy;
+  startTime?: string;
+  endTime?: string;
+  overall: DiceLuckStats;
+  groups: DiceLuckStats[];
+}
+
+export interface DiceLuckQuery {
+  start?: number;
+  end?: number;
+  groupBy?: DiceLuckGroupBy;
+  userId?: string;
+  identityId?: string;
+  channelIds?: string[];
+}
+
+export interface MessageDraft {
+  channelId: string;
+  mode: 'plain' | 'rich' | string;
+  content: string;
+  extra: string;
+  clientUpdatedAt: number;
+  expiresAt: string;
+}
+
+export interface UserSession {
+  id: string;
+  device: string;
+  userAgent: string;
+  ip: string;
+  createdAt: string;
+  lastSeenAt?: string;
+  expiredAt: string;
+  current: boolean;
 }
 
 export interface AvatarDecorationSettings {
@@ -682,7 +893,9 @@ export interface SChannel extends Channel {
   desc?: string;
   note?: string;
   defaultDiceExpr?: string;
+  diceRuleSystem?: DiceRuleSystem;
   builtInDiceEnabled?: boolean;
+  verifiableDiceEnabled?: boolean;
   botFeatureEnabled?: boolean;
   primaryBotId?: string;
   eventBotIds?: string[];
@@ -849,6 +1062,130 @@ export interface ChannelIdentity {
   folderIds?: string[];
 }
 
+export type ScheduledMessageStatus = 'active' | 'completed' | 'canceled' | 'failed';
+
+export interface ScheduledMessage {
+  id: string;
+  channelId: string;
+  worldId: string;
+  userId: string;
+  content: string;
+  identityId: string;
+  identityVariantId: string;
+  icMode: 'ic' | 'ooc';
+  cronExpr: string;
+  timezone: string;
+  note: string;
+  status: ScheduledMessageStatus;
+  nextRunAt?: string | null;
+  lastRunAt?: string | null;
+  lastMessageId: string;
+  lastError: string;
+  runCount: number;
+  failCount: number;
+  revision: number;
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export interface ScheduledMessagePayload {
+  content: string;
+  identityId?: string;
+  identityVariantId?: string;
+  icMode: 'ic' | 'ooc';
+  runAt?: number;
+  cronExpr?: string;
+  timezone?: string;
+  note?: string;
+}
+
+export interface PollVoter {
+  userId: string;
+  name: string;
+}
+
+export interface PollOptionState {
+  text: string;
+  count: number;
+  voters?: PollVoter[];
+}
+
+export interface PollWidgetState {
+  question: string;
+  options: PollOptionState[];
+  multiple: boolean;
+  anonymous: boolean;
+  closesAt?: number;
+  closed: boolean;
+  closedAt?: number;
+  totalVoters: number;
+}
+
+export interface PollCreatePayload {
+  question: string;
+  options: string[];
+  multiple: boolean;
+  anonymous: boolean;
+  closes_at?: number;
+}
+
+export interface PollEventPayload {
+  messageId: string;
+  channelId: string;
+  question: string;
+  options: { text: string; count: number }[];
+  totalVoters: number;
+  closedAt: number;
+}
+
+export interface InitiativeCondition {
+  name: string;
+  rounds: number; // 剩余轮数，0 表示持续到手动移除
+}
+
+export interface InitiativeCombatant {
+  id: string;
+  name: string;
+  identityId?: string;
+  userId?: string;
+  isNpc: boolean;
+  color?: string;
+  avatarAttachment?: string;
+  hasInitiative: boolean;
+  initiative: number;
+  initiativeFormula?: string;
+  initiativeDetail?: string;
+  tiebreak: number;
+  conditions: InitiativeCondition[];
+}
+
+export interface InitiativeTracker {
+  channelId: string;
+  active: boolean;
+  round: number;
+  currentCombatantId?: string;
+  version: number;
+  updatedAt?: number;
+  combatants: InitiativeCombatant[];
+}
+
+export interface InitiativeEventPayload {
+  channelId: string;
+  action: string;
+  tracker: InitiativeTracker;
+}
+
+export interface InitiativeCombatantInput {
+  name?: string;
+  identity_id?: string;
+  initiative?: number;
+  formula?: string;
+  tiebreak?: number;
+  roll?: boolean;
+}
+
+export type InitiativeTurnAction = 'start' | 'next' | 'prev' | 'end' | 'clear';
+
 export interface ChannelIdentityVariant {
   id: string;
   identityId: string;
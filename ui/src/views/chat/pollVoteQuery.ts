@@ -0,0 +1,36 @@
+import { useChatStore } from '@/stores/chat'
+
+// 同一时间渲染的多条投票合并为一次 poll.votes.mine 请求
+const pendingQueries = new Map<string, Map<string, ((votes: number[]) => void)[]>>()
+let flushTimer: ReturnType<typeof setTimeout> | null = null
+
+const flushQueries = () => {
+  flushTimer = null
+  const chat = useChatStore()
+  const batches = Array.from(pendingQueries.entries())
+  pendingQueries.clear()
+  for (const [channelId, waiters] of batches) {
+    const ids = Array.from(waiters.keys())
+    chat.pollVotesMine(channelId, ids)
+      .then((votes: Record<string, number[]>) => {
+        ids.forEach((id) => waiters.get(id)?.forEach((resolve) => resolve(votes[id] || [])))
+      })
+      .catch(() => {
+        ids.forEach((id) => waiters.get(id)?.forEach((resolve) => resolve([])))
+      })
+  }
+}
+
+export const queryMyPollVotes = (channelId: string, messageId: string) => new Promise<number[]>((resolve) => {
+  let waiters = pendingQueries.get(channelId)
+  if (!waiters) {
+    waiters = new Map()
+    pendingQueries.set(channelId, waiters)
+  }
+  const list = waiters.get(messageId) || []
+  list.push(resolve)
+  waiters.set(messageId, list)
+  if (!flushTimer) {
+    flushTimer = setTimeout(flushQueries, 50)
+  }
+})
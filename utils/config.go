@@ -93,6 +93,8 @@ const (
 	defaultAuthTokenMaxAgeDays      = 15
 	defaultAuthRefreshThresholdDays = 7
 	defaultCertificateStorageDir    = "./data/certmagic"
+	defaultBroadcastBackend         = "local"
+	defaultBroadcastPostgresChannel = "sealchat_broadcast"
 )
 
 type CaptchaMode string
@@ -225,12 +227,116 @@ type BackupConfig struct {
 	Path           string `json:"path" yaml:"path"`
 }
 
+// BroadcastConfig 多节点 WebSocket 广播总线配置（仅服务端读取）
+type BroadcastConfig struct {
+	// 后端类型：local（单进程，默认）/ postgres（LISTEN/NOTIFY）
+	Backend string `json:"-" yaml:"backend"`
+	// 节点标识，留空时启动自动生成
+	NodeID string `json:"-" yaml:"nodeId"`
+	// Postgres 连接串，留空时复用 dbUrl
+	PostgresDSN string `json:"-" yaml:"postgresDsn"`
+	// LISTEN/NOTIFY 使用的通道名
+	PostgresChannel string `json:"-" yaml:"postgresChannel"`
+}
+
 // AuthSessionConfig 登录会话配置
 type AuthSessionConfig struct {
 	MaxAgeDays           int `json:"maxAgeDays" yaml:"maxAgeDays"`
 	RefreshThresholdDays int `json:"refreshThresholdDays" yaml:"refreshThresholdDays"`
 }
 
+// TwoFactorConfig 两步验证配置
+type TwoFactorConfig struct {
+	RequireForAdmins bool   `json:"requireForAdmins" yaml:"requireForAdmins"` // 要求平台管理员启用两步验证后才能访问管理接口
+	Issuer           string `json:"issuer" yaml:"issuer"`                     // 验证器应用中显示的服务名称
+}
+
+// OIDCConfig OpenID Connect 单点登录配置
+type OIDCConfig struct {
+	Enabled       bool     `json:"enabled" yaml:"enabled"`
+	DisplayName   string   `json:"displayName" yaml:"displayName"` // 登录按钮上显示的身份提供方名称
+	Issuer        string   `json:"issuer" yaml:"issuer"`           // 用于发现 /.well-known/openid-configuration
+	ClientID      string   `json:"clientId" yaml:"clientId"`
+	ClientSecret  string   `json:"clientSecret" yaml:"clientSecret"`   // 公共客户端可留空，仅依赖 PKCE
+	RedirectURL   string   `json:"redirectUrl" yaml:"redirectUrl"`     // 留空时按请求地址推导回调地址
+	Scopes        []string `json:"scopes" yaml:"scopes"`               // 留空时为 openid profile email
+	AllowSignup   bool     `json:"allowSignup" yaml:"allowSignup"`     // 未关联的身份自动创建账号
+	LinkByEmail   bool     `json:"linkByEmail" yaml:"linkByEmail"`     // 按已验证邮箱自动关联现有账号
+	UsernameClaim string   `json:"usernameClaim" yaml:"usernameClaim"` // 默认 preferred_username
+	NicknameClaim string   `json:"nicknameClaim" yaml:"nicknameClaim"` // 默认 name
+	AvatarClaim   string   `json:"avatarClaim" yaml:"avatarClaim"`     // 默认 picture
+}
+
+// 限流路由标识
+const (
+	RateLimitRouteMessageCreate    = "message_create"    // WebSocket message.create
+	RateLimitRouteAttachmentUpload = "attachment_upload" // 附件上传
+	RateLimitRouteWebhookMessage   = "webhook_message"   // webhook 写入消息
+)
+
+// RateLimitRule 令牌桶参数，Burst 为 0 表示不限制
+type RateLimitRule struct {
+	Burst     int     `json:"burst" yaml:"burst"`         // 桶容量，即允许的瞬时突发数
+	PerMinute float64 `json:"perMinute" yaml:"perMinute"` // 每分钟补充的令牌数
+}
+
+// RateLimitRouteConfig 单个路由的限流规则，WorldID 仅在世界覆盖项中使用
+type RateLimitRouteConfig struct {
+	Route   string        `json:"route" yaml:"route"`
+	WorldID string        `json:"worldId,omitempty" yaml:"worldId,omitempty"`
+	User    RateLimitRule `json:"user" yaml:"user"` // 按用户计数
+	IP      RateLimitRule `json:"ip" yaml:"ip"`     // 按来源 IP 计数
+}
+
+// RateLimitConfig 消息与上传接口的限流配置
+type RateLimitConfig struct {
+	Enabled           bool                   `json:"enabled" yaml:"enabled"`
+	ExemptSystemRoles []string               `json:"exemptSystemRoles" yaml:"exemptSystemRoles"` // 拥有这些系统角色的用户不受限流
+	Routes            []RateLimitRouteConfig `json:"routes" yaml:"routes"`                       // 未列出的路由使用内置默认值
+	WorldOverrides    []RateLimitRouteConfig `json:"worldOverrides" yaml:"worldOverrides"`       // 按世界覆盖路由规则
+}
+
+// DefaultRateLimitRoutes 内置的路由限流默认值
+func DefaultRateLimitRoutes() []RateLimitRouteConfig {
+	return []RateLimitRouteConfig{
+		{
+			Route: RateLimitRouteMessageCreate,
+			User:  RateLimitRule{Burst: 20, PerMinute: 60},
+			IP:    RateLimitRule{Burst: 60, PerMinute: 180},
+		},
+		{
+			Route: RateLimitRouteAttachmentUpload,
+			User:  RateLimitRule{Burst: 10, PerMinute: 30},
+			IP:    RateLimitRule{Burst: 30, PerMinute: 90},
+		},
+		{
+			Route: RateLimitRouteWebhookMessage,
+			User:  RateLimitRule{Burst: 30, PerMinute: 60},
+			IP:    RateLimitRule{Burst: 60, PerMinute: 120},
+		},
+	}
+}
+
+// WebPushConfig 浏览器推送（VAPID Web Push）配置，密钥对首次使用时自动生成并保存在数据库
+type WebPushConfig struct {
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	Subject string `json:"subject" yaml:"subject"` // VAPID 联系方式（mailto: 或 https:），留空时使用站点地址
+	// AllowInsecureEndpoints 允许 http 及内网地址的推送端点，仅用于本地调试假推送服务
+	AllowInsecureEndpoints bool `json:"allowInsecureEndpoints" yaml:"allowInsecureEndpoints"`
+}
+
+// ReadReceiptConfig 已读回执配置，仅对悄悄话、私聊与成员数不超过上限的频道生效
+type ReadReceiptConfig struct {
+	Enabled           bool `json:"enabled" yaml:"enabled"`
+	MaxChannelMembers int  `json:"maxChannelMembers" yaml:"maxChannelMembers"` // 普通频道成员数上限，0 表示使用默认值
+}
+
+// MetricsExportConfig /metrics 指标导出配置，Token 为空时接口不可用
+type MetricsExportConfig struct {
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	Token   string `json:"token" yaml:"token"` // 抓取时通过 Authorization: Bearer 或 ?token= 传入
+}
+
 // LoginBackgroundConfig 登录页背景配置
 type LoginBackgroundConfig struct {
 	AttachmentId        string `json:"attachmentId" yaml:"attachmentId"`
@@ -414,12 +520,19 @@ type AppConfig struct {
 	UpdateCheck               UpdateCheckConfig         `json:"updateCheck" yaml:"updateCheck"`
 	Backup                    BackupConfig              `json:"backup" yaml:"backup"`
 	AuthSession               AuthSessionConfig         `json:"authSession" yaml:"authSession"`
+	TwoFactor                 TwoFactorConfig           `json:"twoFactor" yaml:"twoFactor"`
+	OIDC                      OIDCConfig                `json:"oidc" yaml:"oidc"`
+	RateLimit                 RateLimitConfig           `json:"rateLimit" yaml:"rateLimit"`
+	MetricsExport             MetricsExportConfig       `json:"metricsExport" yaml:"metricsExport"`
+	WebPush                   WebPushConfig             `json:"webPush" yaml:"webPush"`
+	ReadReceipts              ReadReceiptConfig         `json:"readReceipts" yaml:"readReceipts"`
 	LoginBackground           LoginBackgroundConfig     `json:"loginBackground" yaml:"loginBackground"`
 	ThemeManagement           ThemeManagementConfig     `json:"themeManagement" yaml:"themeManagement"`
 	UITextReplace             UITextReplaceConfig       `json:"uiTextReplace" yaml:"uiTextReplace"`
 	Certificate               CertificateConfig         `json:"certificate" yaml:"certificate"`
 	AI                        AIConfig                  `json:"ai" yaml:"ai"`
 	PerformanceProfiler       PerformanceProfilerConfig `json:"performanceProfiler" yaml:"performanceProfiler"`
+	Broadcast                 BroadcastConfig           `json:"-" yaml:"broadcast"`
 }
 
 type ExportConfig struct {
@@ -581,6 +694,18 @@ func ReadConfig() *AppConfig {
 			MaxAgeDays:           defaultAuthTokenMaxAgeDays,
 			RefreshThresholdDays: defaultAuthRefreshThresholdDays,
 		},
+		RateLimit: RateLimitConfig{
+			Enabled:           true,
+			ExemptSystemRoles: []string{"sys-admin"},
+			Routes:            DefaultRateLimitRoutes(),
+		},
+		WebPush: WebPushConfig{
+			Enabled: true,
+		},
+		ReadReceipts: ReadReceiptConfig{
+			Enabled:           true,
+			MaxChannelMembers: 20,
+		},
 		LoginBackground: LoginBackgroundConfig{
 			Mode:                "cover",
 			Opacity:             30,
@@ -612,6 +737,10 @@ func ReadConfig() *AppConfig {
 			CPUProfileDurationSec:  300,
 			RetentionDays:          3,
 		},
+		Broadcast: BroadcastConfig{
+			Backend:         defaultBroadcastBackend,
+			PostgresChannel: defaultBroadcastPostgresChannel,
+		},
 	}
 
 	lo.Must0(k.Load(structs.Provider(&config, "yaml"), nil))
@@ -690,6 +819,7 @@ func ReadConfig() *AppConfig {
 	config.Certificate = NormalizeCertificateConfig(config.Certificate)
 	config.AI = NormalizeAIConfig(config.AI)
 	applyPerformanceProfilerDefaults(&config.PerformanceProfiler)
+	applyBroadcastDefaults(&config.Broadcast)
 
 	k.Print()
 	currentConfig = &config
@@ -1465,6 +1595,22 @@ func applyPerformanceProfilerDefaults(cfg *PerformanceProfilerConfig) {
 	}
 }
 
+func applyBroadcastDefaults(cfg *BroadcastConfig) {
+	if cfg == nil {
+		return
+	}
+	cfg.Backend = strings.ToLower(strings.TrimSpace(cfg.Backend))
+	if cfg.Backend == "" {
+		cfg.Backend = defaultBroadcastBackend
+	}
+	cfg.NodeID = strings.TrimSpace(cfg.NodeID)
+	cfg.PostgresDSN = strings.TrimSpace(cfg.PostgresDSN)
+	cfg.PostgresChannel = strings.TrimSpace(cfg.PostgresChannel)
+	if cfg.PostgresChannel == "" {
+		cfg.PostgresChannel = defaultBroadcastPostgresChannel
+	}
+}
+
 func ResolveAuthSessionMaxAgeDays() int {
 	maxAgeDays := defaultAuthTokenMaxAgeDays
 	if cfg := GetConfig(); cfg != nil && cfg.AuthSession.MaxAgeDays > 0 {
@@ -1764,6 +1910,41 @@ func WriteConfig(config *AppConfig) {
 		_ = k.Set("authSession.maxAgeDays", config.AuthSession.MaxAgeDays)
 		_ = k.Set("authSession.refreshThresholdDays", config.AuthSession.RefreshThresholdDays)
 
+		// 两步验证配置
+		_ = k.Set("twoFactor.requireForAdmins", config.TwoFactor.RequireForAdmins)
+		_ = k.Set("twoFactor.issuer", config.TwoFactor.Issuer)
+
+		// OIDC 单点登录配置
+		_ = k.Set("oidc.enabled", config.OIDC.Enabled)
+		_ = k.Set("oidc.displayName", config.OIDC.DisplayName)
+		_ = k.Set("oidc.issuer", config.OIDC.Issuer)
+		_ = k.Set("oidc.clientId", config.OIDC.ClientID)
+		_ = k.Set("oidc.clientSecret", config.OIDC.ClientSecret)
+		_ = k.Set("oidc.redirectUrl", config.OIDC.RedirectURL)
+		_ = k.Set("oidc.scopes", config.OIDC.Scopes)
+		_ = k.Set("oidc.allowSignup", config.OIDC.AllowSignup)
+		_ = k.Set("oidc.linkByEmail", config.OIDC.LinkByEmail)
+		_ = k.Set("oidc.usernameClaim", config.OIDC.UsernameClaim)
+		_ = k.Set("oidc.nicknameClaim", config.OIDC.NicknameClaim)
+		_ = k.Set("oidc.avatarClaim", config.OIDC.AvatarClaim)
+
+		// 限流配置
+		_ = k.Set("rateLimit.enabled", config.RateLimit.Enabled)
+		_ = k.Set("rateLimit.exemptSystemRoles", config.RateLimit.ExemptSystemRoles)
+		_ = k.Set("rateLimit.routes", config.RateLimit.Routes)
+		_ = k.Set("rateLimit.worldOverrides", config.RateLimit.WorldOverrides)
+
+		// 指标导出配置
+		_ = k.Set("metricsExport.enabled", config.MetricsExport.Enabled)
+		_ = k.Set("metricsExport.token", config.MetricsExport.Token)
+
+		// 浏览器推送配置
+		_ = k.Set("webPush.enabled", config.WebPush.Enabled)
+		_ = k.Set("webPush.subject", config.WebPush.Subject)
+		_ = k.Set("webPush.allowInsecureEndpoints", config.WebPush.AllowInsecureEndpoints)
+		_ = k.Set("readReceipts.enabled", config.ReadReceipts.Enabled)
+		_ = k.Set("readReceipts.maxChannelMembers", config.ReadReceipts.MaxChannelMembers)
+
 		// 登录页背景配置
 		_ = k.Set("loginBackground.attachmentId", config.LoginBackground.AttachmentId)
 		_ = k.Set("loginBackground.mode", config.LoginBackground.Mode)